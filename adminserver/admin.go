@@ -0,0 +1,215 @@
+// Package adminserver serves the internal endpoints of a service (health,
+// metrics, configuration dump, log level, recent logs, pprof, error
+// catalog, Casbin admin, cache warmup, maintenance, workers, fault
+// injection) on a second echo instance bound to an internal port, behind
+// one token and CIDR check:
+//
+//	ring := log.NewRingSink(0)
+//	logger := log.NewFromLogConfig(log.LogConfig{Ring: ring}, env)
+//	admin, err := adminserver.NewAdmin(cfg.Admin,
+//	    adminserver.WithHealth(healthReg),
+//	    adminserver.WithMetrics(metrics.Handler()),
+//	    adminserver.WithConfigDump(store),
+//	    adminserver.WithLogLevel(logger.(log.LevelController)),
+//	    adminserver.WithRecentLogs(ring),
+//	    adminserver.WithPprof(),
+//	)
+//	runner := server.NewRunner(srv, cfg.System, manager, admin)
+package adminserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/lifecycle"
+	"github.com/NSObjects/go-kit/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// Defaults applied when the corresponding AdminConfig field is empty.
+const (
+	DefaultHost = "127.0.0.1"
+	DefaultPort = "9090"
+)
+
+// Feature names, as used in AdminConfig.Features.
+const (
+	FeatureHealth         = "health"
+	FeatureMetrics        = "metrics"
+	FeatureConfig         = "config"
+	FeatureLogLevel       = "log_level"
+	FeatureRecentLogs     = "logs"
+	FeaturePprof          = "pprof"
+	FeatureErrorCodes     = "errors"
+	FeatureCasbin         = "casbin"
+	FeatureCacheWarmup    = "cache_warmup"
+	FeatureMaintenance    = "maintenance"
+	FeatureWorkers        = "workers"
+	FeatureFaultInjection = "fault_injection"
+)
+
+// Option registers a feature on the admin server.
+type Option func(a *Admin)
+
+// Admin is the admin server. It implements server.Component, so the
+// Runner starts it with the other components and stops it gracefully.
+type Admin struct {
+	cfg      config.AdminConfig
+	echo     *echo.Echo
+	server   *http.Server
+	cidrs    []netip.Prefix
+	features []string
+}
+
+// NewAdmin creates the admin server of cfg with the features of opts;
+// features switched off in cfg.Features are not mounted and 404. It fails
+// when cfg.AllowCIDRs is invalid, or when the server would listen beyond
+// loopback without a token or CIDRs.
+func NewAdmin(cfg config.AdminConfig, opts ...Option) (*Admin, error) {
+	if cfg.Host == "" {
+		cfg.Host = DefaultHost
+	}
+	if cfg.Port == "" {
+		cfg.Port = DefaultPort
+	}
+	a := &Admin{cfg: cfg, echo: echo.New()}
+	for _, entry := range cfg.AllowCIDRs {
+		p, err := parsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("admin.allow_cidrs: %w", err)
+		}
+		a.cidrs = append(a.cidrs, p)
+	}
+	if cfg.Token == "" && len(a.cidrs) == 0 && !isLoopback(cfg.Host) {
+		return nil, fmt.Errorf("admin server on %s requires admin.token or admin.allow_cidrs", cfg.Host)
+	}
+
+	e := a.echo
+	e.HideBanner = true
+	e.HidePort = true
+	middleware.RegisterErrorHandling(e)
+	e.IPExtractor = echo.ExtractIPDirect()
+	e.Use(middleware.Recovery(), a.authorize)
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	a.server = &http.Server{
+		Addr:              net.JoinHostPort(cfg.Host, strings.TrimPrefix(cfg.Port, ":")),
+		Handler:           e,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return a, nil
+}
+
+// Echo returns the echo instance of the admin server, to mount routes
+// that have no Option. They are behind the same authorization.
+func (a *Admin) Echo() *echo.Echo {
+	return a.echo
+}
+
+// Features returns the names of the mounted features.
+func (a *Admin) Features() []string {
+	return append([]string(nil), a.features...)
+}
+
+// Handler returns the HTTP handler of the admin server.
+func (a *Admin) Handler() http.Handler {
+	return a.echo
+}
+
+// Addr returns the listen address of the admin server.
+func (a *Admin) Addr() string {
+	return a.server.Addr
+}
+
+// Start listens on the admin address and serves in the background. A
+// listen failure, e.g. a port in use, is returned.
+func (a *Admin) Start(ctx context.Context) error {
+	ln, err := (&net.ListenConfig{}).Listen(ctx, "tcp", a.server.Addr)
+	if err != nil {
+		return fmt.Errorf("admin server: %w", err)
+	}
+	slog.Info("Admin server starting", slog.String("addr", ln.Addr().String()), slog.Any("features", a.features))
+	lifecycle.Go(context.WithoutCancel(ctx), "adminserver", func(context.Context) error {
+		if err := a.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Admin server failed", slog.String("error", err.Error()))
+		}
+		return nil
+	}, lifecycle.WorkerOptions{Once: true})
+	return nil
+}
+
+// Stop shuts the admin server down gracefully.
+func (a *Admin) Stop(ctx context.Context) error {
+	return a.server.Shutdown(ctx)
+}
+
+// mount runs register on the admin echo instance unless feature is
+// switched off.
+func (a *Admin) mount(feature string, register func(e *echo.Echo)) {
+	if on, ok := a.cfg.Features[feature]; ok && !on {
+		return
+	}
+	a.features = append(a.features, feature)
+	register(a.echo)
+}
+
+// authorize applies the CIDR allow list and the token to every request.
+func (a *Admin) authorize(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if len(a.cidrs) > 0 {
+			ip, err := netip.ParseAddr(c.RealIP())
+			if err != nil || !containsAddr(a.cidrs, ip.Unmap()) {
+				return code.NewErrorf(code.ErrForbidden, "admin access denied for %s", c.RealIP())
+			}
+		}
+		if a.cfg.Token != "" {
+			token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.cfg.Token)) != 1 {
+				return code.NewErrorf(code.ErrUnauthorized, "invalid admin token")
+			}
+		}
+		return next(c)
+	}
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		return p.Masked(), err
+	}
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
+
+func containsAddr(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.IsLoopback()
+}
@@ -0,0 +1,235 @@
+package adminserver
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+
+	"github.com/NSObjects/go-kit/cache"
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/faultinject"
+	"github.com/NSObjects/go-kit/health"
+	"github.com/NSObjects/go-kit/lifecycle"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/middleware"
+	"github.com/NSObjects/go-kit/resp"
+	"github.com/labstack/echo/v4"
+)
+
+// WithHealth mounts the checks of reg:
+//
+//	GET /health          all checks
+//	GET /health/live     liveness
+//	GET /health/ready    readiness
+//	GET /health/startup  startup
+func WithHealth(reg *health.Registry) Option {
+	return func(a *Admin) {
+		a.mount(FeatureHealth, func(e *echo.Echo) {
+			e.GET("/health", health.Handler(reg))
+			e.GET("/health/live", health.LivenessHandler())
+			e.GET("/health/ready", health.ReadinessHandler(reg))
+			e.GET("/health/startup", health.StartupHandler(reg))
+		})
+	}
+}
+
+// WithMetrics mounts handler, typically metrics.Handler(), at GET /metrics.
+func WithMetrics(handler echo.HandlerFunc) Option {
+	return func(a *Admin) {
+		a.mount(FeatureMetrics, func(e *echo.Echo) {
+			e.GET("/metrics", handler)
+		})
+	}
+}
+
+// WithConfigDump mounts the redacted configuration of store at GET /config
+// (see middleware.ConfigHandler).
+func WithConfigDump[T any](store *config.Store[T]) Option {
+	return func(a *Admin) {
+		a.mount(FeatureConfig, func(e *echo.Echo) {
+			e.GET("/config", middleware.ConfigHandler(store))
+		})
+	}
+}
+
+// LogLevel is the body of the log level endpoint.
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+// WithLogLevel mounts the level of logger:
+//
+//	GET /log/level                      {"level": "INFO"}
+//	PUT /log/level  {"level": "debug"}  changes it until restart
+func WithLogLevel(logger log.LevelController) Option {
+	return func(a *Admin) {
+		a.mount(FeatureLogLevel, func(e *echo.Echo) {
+			e.GET("/log/level", func(c echo.Context) error {
+				return resp.SuccessJSON(c, LogLevel{Level: logger.Level().String()})
+			})
+			e.PUT("/log/level", func(c echo.Context) error {
+				var req LogLevel
+				if err := c.Bind(&req); err != nil {
+					return code.WrapError(err, code.ErrBind, "invalid request body")
+				}
+				level, err := log.ParseLevel(req.Level)
+				if err != nil {
+					return code.WrapError(err, code.ErrBadRequest, err.Error())
+				}
+				old := logger.Level()
+				logger.SetLevel(level)
+				slog.Warn("Log level changed",
+					slog.String("from", old.String()),
+					slog.String("to", level.String()),
+					slog.String("remote_ip", c.RealIP()),
+				)
+				return resp.SuccessJSON(c, LogLevel{Level: level.String()})
+			})
+		})
+	}
+}
+
+// WithRecentLogs mounts the records of ring at GET /logs, newest first,
+// filtered by ?level= (default debug) and ?limit= (default 100).
+func WithRecentLogs(ring *log.RingSink) Option {
+	return func(a *Admin) {
+		a.mount(FeatureRecentLogs, func(e *echo.Echo) {
+			e.GET("/logs", func(c echo.Context) error {
+				level := slog.LevelDebug
+				if v := c.QueryParam("level"); v != "" {
+					var err error
+					if level, err = log.ParseLevel(v); err != nil {
+						return code.WrapError(err, code.ErrBadRequest, err.Error())
+					}
+				}
+				limit := 100
+				if v := c.QueryParam("limit"); v != "" {
+					n, err := strconv.Atoi(v)
+					if err != nil || n < 0 {
+						return code.NewErrorf(code.ErrBadRequest, "invalid limit %q", v)
+					}
+					limit = n
+				}
+				records := ring.Records(level, limit)
+				return resp.ListDataResponse(c, records, int64(len(records)))
+			})
+		})
+	}
+}
+
+// WithPprof mounts the net/http/pprof profiles under /debug/pprof/.
+func WithPprof() Option {
+	return func(a *Admin) {
+		a.mount(FeaturePprof, func(e *echo.Echo) {
+			g := e.Group("/debug/pprof")
+			g.GET("/", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
+			g.GET("/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+			g.GET("/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)))
+			g.GET("/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+			g.POST("/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+			g.GET("/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)))
+			g.GET("/:profile", func(c echo.Context) error {
+				pprof.Handler(c.Param("profile")).ServeHTTP(c.Response(), c.Request())
+				return nil
+			})
+		})
+	}
+}
+
+// WithErrorCodes mounts the error catalog at GET /errors (see
+// middleware.ErrorCodesHandler).
+func WithErrorCodes() Option {
+	return func(a *Admin) {
+		a.mount(FeatureErrorCodes, func(e *echo.Echo) {
+			e.GET("/errors", middleware.ErrorCodesHandler())
+		})
+	}
+}
+
+// WithCasbin mounts the Casbin policy API of admin under /casbin (see
+// middleware.CasbinAdmin.Register).
+func WithCasbin(admin *middleware.CasbinAdmin) Option {
+	return func(a *Admin) {
+		a.mount(FeatureCasbin, func(e *echo.Echo) {
+			admin.Register(e.Group("/casbin"))
+		})
+	}
+}
+
+// WithCacheWarmer mounts the warmups of w:
+//
+//	GET  /cache/warmup                   report of the latest warmup
+//	POST /cache/warmup?warmer=products   runs the given warmers, or all
+//
+// A POST waits for the warmup and returns its report; it runs to
+// completion even if the client goes away.
+func WithCacheWarmer(w *cache.Warmer) Option {
+	return func(a *Admin) {
+		a.mount(FeatureCacheWarmup, func(e *echo.Echo) {
+			e.GET("/cache/warmup", func(c echo.Context) error {
+				report, ok := w.LastReport()
+				if !ok {
+					return code.NewError(code.ErrNotFound, "no cache warmup ran yet")
+				}
+				return resp.SuccessJSON(c, report)
+			})
+			e.POST("/cache/warmup", func(c echo.Context) error {
+				names := c.QueryParams()["warmer"]
+				slog.Warn("Cache warmup triggered",
+					slog.Any("warmers", names),
+					slog.String("remote_ip", c.RealIP()),
+				)
+				report, err := w.Warm(context.WithoutCancel(c.Request().Context()), names...)
+				if err != nil {
+					return err
+				}
+				return resp.SuccessJSON(c, report)
+			})
+		})
+	}
+}
+
+// WithMaintenance mounts the maintenance API of m under /maintenance (see
+// middleware.Maintenance.Register).
+func WithMaintenance(m *middleware.Maintenance) Option {
+	return func(a *Admin) {
+		a.mount(FeatureMaintenance, func(e *echo.Echo) {
+			m.Register(e.Group("/maintenance"))
+		})
+	}
+}
+
+// WithWorkers mounts the inventory of the background workers of the kit
+// (see lifecycle.Go):
+//
+//	GET /workers                 running workers with restarts and last error
+//	GET /workers/stacks?name=x   goroutine stacks of the workers named x
+func WithWorkers() Option {
+	return func(a *Admin) {
+		a.mount(FeatureWorkers, func(e *echo.Echo) {
+			e.GET("/workers", func(c echo.Context) error {
+				return resp.SuccessJSON(c, lifecycle.Workers())
+			})
+			e.GET("/workers/stacks", func(c echo.Context) error {
+				name := c.QueryParam("name")
+				if name == "" {
+					return code.NewError(code.ErrBadRequest, "name is required")
+				}
+				return c.String(http.StatusOK, lifecycle.WorkerStacks(name))
+			})
+		})
+	}
+}
+
+// WithFaultInjection mounts the runtime control of a fault injector under
+// /fault_injection (see faultinject.Injector.Register).
+func WithFaultInjection(i *faultinject.Injector) Option {
+	return func(a *Admin) {
+		a.mount(FeatureFaultInjection, func(e *echo.Echo) {
+			i.Register(e.Group("/fault_injection"))
+		})
+	}
+}
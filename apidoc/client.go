@@ -0,0 +1,531 @@
+package apidoc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ClientOptions configure GenerateClient.
+type ClientOptions struct {
+	// Package is the package name of the generated file; default "client".
+	Package string
+	// Service names the remote service in errors; default the document
+	// title.
+	Service string
+}
+
+// GenerateClient returns the Go source of a client package for the OpenAPI
+// document doc, as served by Registry.Handler:
+//
+//   - one method per operation, named after its operationId, taking a
+//     typed request (path and query parameters and body fields) and
+//     returning the typed data of the response envelope;
+//   - the component schemas as Go types;
+//   - the error codes of the "x-error-codes" extension as constants, to
+//     match with errors.IsCode.
+//
+// Calls go through httpclient.Client, so retries, trace propagation and
+// the decoding of coded errors apply. See cmd/clientgen for the command
+// line and go:generate use.
+func GenerateClient(doc []byte, opts ClientOptions) ([]byte, error) {
+	var d clientDoc
+	if err := json.Unmarshal(doc, &d); err != nil {
+		return nil, fmt.Errorf("parse OpenAPI document: %w", err)
+	}
+	if opts.Package == "" {
+		opts.Package = "client"
+	}
+	if opts.Service == "" {
+		opts.Service = d.Info.Title
+	}
+
+	g := &clientGen{types: map[string]string{}, imports: map[string]bool{}}
+	g.generate(&d, opts)
+	src, err := format.Source(g.out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated client: %w\n%s", err, g.out.Bytes())
+	}
+	return src, nil
+}
+
+// GenerateClient returns the Go source of a client package for the
+// registered routes (see the package-level GenerateClient).
+func (r *Registry) GenerateClient(opts ClientOptions) ([]byte, error) {
+	doc, err := r.JSON()
+	if err != nil {
+		return nil, err
+	}
+	return GenerateClient(doc, opts)
+}
+
+// clientDoc is the subset of an OpenAPI document read by GenerateClient.
+type clientDoc struct {
+	Info       Info                                  `json:"info"`
+	Paths      map[string]map[string]clientOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]*clientSchema `json:"schemas"`
+	} `json:"components"`
+	ErrorCodes []ErrorCode `json:"x-error-codes"`
+}
+
+type clientOperation struct {
+	OperationID string            `json:"operationId"`
+	Summary     string            `json:"summary"`
+	Parameters  []clientParameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema *clientSchema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]struct {
+			Schema *clientSchema `json:"schema"`
+		} `json:"content"`
+	} `json:"responses"`
+}
+
+type clientParameter struct {
+	Name     string        `json:"name"`
+	In       string        `json:"in"`
+	Required bool          `json:"required"`
+	Schema   *clientSchema `json:"schema"`
+}
+
+type clientSchema struct {
+	Ref                  string                   `json:"$ref"`
+	Type                 string                   `json:"type"`
+	Format               string                   `json:"format"`
+	ContentEncoding      string                   `json:"contentEncoding"`
+	Items                *clientSchema            `json:"items"`
+	AdditionalProperties *clientSchema            `json:"additionalProperties"`
+	Properties           map[string]*clientSchema `json:"properties"`
+	Required             []string                 `json:"required"`
+}
+
+// clientGen accumulates the generated source.
+type clientGen struct {
+	out     bytes.Buffer
+	body    bytes.Buffer // methods
+	types   map[string]string
+	imports map[string]bool
+}
+
+func (g *clientGen) generate(d *clientDoc, opts ClientOptions) {
+	g.imports["context"] = true
+	g.imports["net/http"] = true
+	g.imports["github.com/NSObjects/go-kit/httpclient"] = true
+
+	for _, name := range sortedKeys(d.Components.Schemas) {
+		g.structType(goName(name), d.Components.Schemas[name])
+	}
+
+	type op struct {
+		path, method string
+		clientOperation
+	}
+	var ops []op
+	for path, item := range d.Paths {
+		for method, o := range item {
+			ops = append(ops, op{path: path, method: strings.ToUpper(method), clientOperation: o})
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].path != ops[j].path {
+			return ops[i].path < ops[j].path
+		}
+		return ops[i].method < ops[j].method
+	})
+	for _, o := range ops {
+		g.method(o.path, o.method, o.clientOperation)
+	}
+
+	w := &g.out
+	fmt.Fprintf(w, "// Code generated by clientgen from the %q OpenAPI document. DO NOT EDIT.\n\n", d.Info.Title)
+	fmt.Fprintf(w, "// Package %s is a client of %s.\npackage %s\n\n", opts.Package, d.Info.Title, opts.Package)
+	w.WriteString("import (\n")
+	var std, third []string
+	for _, imp := range sortedKeys(g.imports) {
+		if strings.Contains(imp, ".") {
+			third = append(third, imp)
+		} else {
+			std = append(std, imp)
+		}
+	}
+	for _, imp := range std {
+		fmt.Fprintf(w, "\t%q\n", imp)
+	}
+	w.WriteString("\n")
+	for _, imp := range third {
+		fmt.Fprintf(w, "\t%q\n", imp)
+	}
+	w.WriteString(")\n\n")
+
+	if len(d.ErrorCodes) > 0 {
+		w.WriteString("// Error codes the API may return, for errors.IsCode.\nconst (\n")
+		used := map[string]bool{}
+		for _, ec := range d.ErrorCodes {
+			name := "Err" + goName(ec.Message)
+			if ec.Message == "" || used[name] {
+				name = "ErrCode" + strconv.Itoa(ec.Code)
+			}
+			used[name] = true
+			if ec.Message != "" {
+				fmt.Fprintf(w, "\t%s = %d // %s\n", name, ec.Code, ec.Message)
+			} else {
+				fmt.Fprintf(w, "\t%s = %d\n", name, ec.Code)
+			}
+		}
+		w.WriteString(")\n\n")
+	}
+
+	fmt.Fprintf(w, `// Client calls %[1]s.
+type Client struct {
+	http *httpclient.Client
+}
+
+// New creates a client of the API at baseURL. opts configure the
+// underlying httpclient.Client, e.g. httpclient.WithRetry.
+func New(baseURL string, opts ...httpclient.Option) *Client {
+	opts = append([]httpclient.Option{httpclient.WithBaseURL(baseURL), httpclient.WithServiceName(%[2]q)}, opts...)
+	return &Client{http: httpclient.New(opts...)}
+}
+
+// envelope is the response envelope of the API.
+type envelope[T any] struct {
+	Code int    `+"`json:\"code\"`"+`
+	Msg  string `+"`json:\"msg\"`"+`
+	Data T      `+"`json:\"data\"`"+`
+}
+
+// List is the data of list responses.
+type List[T any] struct {
+	List  []T   `+"`json:\"list\"`"+`
+	Total int64 `+"`json:\"total\"`"+`
+}
+
+`, d.Info.Title, opts.Service)
+
+	for _, name := range sortedKeys(g.types) {
+		w.WriteString(g.types[name])
+	}
+	w.Write(g.body.Bytes())
+}
+
+// method generates the method of an operation and its request type.
+func (g *clientGen) method(path, method string, o clientOperation) {
+	name := goName(o.OperationID)
+	if name == "" {
+		name = goName(strings.ToLower(method) + " " + path)
+	}
+
+	// Request: parameters and body fields.
+	var params []clientParameter
+	var fields []string
+	hasReq := false
+	for _, p := range o.Parameters {
+		if p.In != "path" && p.In != "query" {
+			continue
+		}
+		params = append(params, p)
+		fields = append(fields, fmt.Sprintf("\t%s %s `json:\"-\"` // %s parameter %q\n", goName(p.Name), g.goType(p.Schema, name+goName(p.Name)), p.In, p.Name))
+		hasReq = true
+	}
+	var body *clientSchema
+	if o.RequestBody != nil {
+		body = o.RequestBody.Content["application/json"].Schema
+	}
+	bodyField := ""
+	if body != nil {
+		hasReq = true
+		if body.Type == "object" && body.Properties != nil {
+			fields = append(fields, g.structFields(name+"Request", body)...)
+		} else {
+			bodyField = "Body"
+			fields = append(fields, fmt.Sprintf("\tBody %s `json:\"-\"`\n", g.goType(body, name+"Body")))
+		}
+	}
+	reqType := name + "Request"
+	if hasReq {
+		var b strings.Builder
+		fmt.Fprintf(&b, "// %s is the request of %s.\ntype %s struct {\n", reqType, name, reqType)
+		for _, f := range fields {
+			b.WriteString(f)
+		}
+		b.WriteString("}\n\n")
+		g.types[reqType] = b.String()
+	}
+
+	// Response: the data of the success envelope.
+	resType := ""
+	if res, ok := o.Responses["200"]; ok {
+		if env := res.Content["application/json"].Schema; env != nil {
+			if data := env.Properties["data"]; data != nil && !data.empty() {
+				resType = g.goType(data, name+"Data")
+			}
+		}
+	}
+
+	w := &g.body
+	summary := method + " " + path
+	if o.Summary != "" {
+		summary += ": " + o.Summary
+	}
+	fmt.Fprintf(w, "// %s calls %s.\n", name, summary)
+	fmt.Fprintf(w, "func (c *Client) %s(ctx context.Context", name)
+	if hasReq {
+		fmt.Fprintf(w, ", req %s", reqType)
+	}
+	if resType != "" {
+		fmt.Fprintf(w, ") (%s, error) {\n", resType)
+	} else {
+		w.WriteString(") error {\n")
+	}
+
+	fmt.Fprintf(w, "\tpath := %s\n", g.pathExpr(path))
+	query := false
+	for _, p := range params {
+		if p.In == "query" {
+			query = true
+		}
+	}
+	if query {
+		g.imports["net/url"] = true
+		w.WriteString("\tquery := url.Values{}\n")
+		for _, p := range params {
+			if p.In == "query" {
+				g.queryParam(w, p)
+			}
+		}
+		w.WriteString("\tif len(query) > 0 {\n\t\tpath += \"?\" + query.Encode()\n\t}\n")
+	}
+
+	payload := "nil"
+	if body != nil && hasBodyMethod(method) {
+		payload = "req"
+		if bodyField != "" {
+			payload = "req." + bodyField
+		}
+	}
+	if resType != "" {
+		fmt.Fprintf(w, "\tres, err := httpclient.DoJSON[envelope[%s]](ctx, c.http, %s, path, %s)\n", resType, httpMethodConst(method), payload)
+		w.WriteString("\treturn res.Data, err\n}\n\n")
+	} else {
+		fmt.Fprintf(w, "\t_, err := httpclient.DoJSON[envelope[struct{}]](ctx, c.http, %s, path, %s)\n", httpMethodConst(method), payload)
+		w.WriteString("\treturn err\n}\n\n")
+	}
+}
+
+// pathExpr returns the Go expression building path, with its {params}
+// taken from req.
+func (g *clientGen) pathExpr(path string) string {
+	var parts []string
+	rest := path
+	for {
+		start := strings.Index(rest, "{")
+		end := strings.Index(rest, "}")
+		if start < 0 || end < start {
+			break
+		}
+		if start > 0 {
+			parts = append(parts, strconv.Quote(rest[:start]))
+		}
+		g.imports["fmt"] = true
+		g.imports["net/url"] = true
+		parts = append(parts, fmt.Sprintf("url.PathEscape(fmt.Sprint(req.%s))", goName(rest[start+1:end])))
+		rest = rest[end+1:]
+	}
+	if rest != "" || len(parts) == 0 {
+		parts = append(parts, strconv.Quote(rest))
+	}
+	return strings.Join(parts, " + ")
+}
+
+// queryParam writes the statement adding a query parameter when set.
+func (g *clientGen) queryParam(w *bytes.Buffer, p clientParameter) {
+	field := "req." + goName(p.Name)
+	typ := g.goType(p.Schema, "")
+	switch {
+	case strings.HasPrefix(typ, "[]"):
+		g.imports["fmt"] = true
+		fmt.Fprintf(w, "\tfor _, v := range %s {\n\t\tquery.Add(%q, fmt.Sprint(v))\n\t}\n", field, p.Name)
+	case typ == "string":
+		fmt.Fprintf(w, "\tif %s != \"\" {\n\t\tquery.Set(%q, %s)\n\t}\n", field, p.Name, field)
+	case typ == "bool":
+		fmt.Fprintf(w, "\tif %s {\n\t\tquery.Set(%q, \"true\")\n\t}\n", field, p.Name)
+	case typ == "time.Time":
+		fmt.Fprintf(w, "\tif !%s.IsZero() {\n\t\tquery.Set(%q, %s.Format(time.RFC3339))\n\t}\n", field, p.Name, field)
+	case typ == "int64" || typ == "int32" || typ == "float64":
+		g.imports["fmt"] = true
+		fmt.Fprintf(w, "\tif %s != 0 {\n\t\tquery.Set(%q, fmt.Sprint(%s))\n\t}\n", field, p.Name, field)
+	default:
+		g.imports["fmt"] = true
+		fmt.Fprintf(w, "\tquery.Set(%q, fmt.Sprint(%s))\n", p.Name, field)
+	}
+}
+
+// structType generates the struct type name for an object schema.
+func (g *clientGen) structType(name string, s *clientSchema) {
+	if _, ok := g.types[name]; ok {
+		return
+	}
+	g.types[name] = "" // placeholder for recursive types
+	if s.Type != "object" || s.Properties == nil {
+		g.types[name] = fmt.Sprintf("// %s is a schema of the API.\ntype %s %s\n\n", name, name, g.goType(s, name+"Value"))
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s is a schema of the API.\ntype %s struct {\n", name, name)
+	for _, f := range g.structFields(name, s) {
+		b.WriteString(f)
+	}
+	b.WriteString("}\n\n")
+	g.types[name] = b.String()
+}
+
+// structFields returns the field declarations of an object schema.
+func (g *clientGen) structFields(parent string, s *clientSchema) []string {
+	required := map[string]bool{}
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	var fields []string
+	for _, prop := range sortedKeys(s.Properties) {
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+		}
+		field := goName(prop)
+		fields = append(fields, fmt.Sprintf("\t%s %s `json:%q`\n", field, g.goType(s.Properties[prop], parent+field), tag))
+	}
+	return fields
+}
+
+// goType returns the Go type of s; inline objects become types named name.
+func (g *clientGen) goType(s *clientSchema, name string) string {
+	if s == nil || s.empty() {
+		return "any"
+	}
+	if s.Ref != "" {
+		return goName(s.Ref[strings.LastIndex(s.Ref, "/")+1:])
+	}
+	switch s.Type {
+	case "string":
+		if s.Format == "date-time" {
+			g.imports["time"] = true
+			return "time.Time"
+		}
+		if s.ContentEncoding == "base64" {
+			return "[]byte"
+		}
+		return "string"
+	case "integer":
+		if s.Format == "int32" {
+			return "int32"
+		}
+		return "int64"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "array":
+		return "[]" + g.goType(s.Items, name+"Item")
+	case "object":
+		if list, ok := s.Properties["list"]; ok && len(s.Properties) == 2 && s.Properties["total"] != nil && list.Type == "array" {
+			return "List[" + g.goType(list.Items, name+"Item") + "]"
+		}
+		if s.Properties == nil {
+			if s.AdditionalProperties != nil {
+				return "map[string]" + g.goType(s.AdditionalProperties, name+"Value")
+			}
+			return "map[string]any"
+		}
+		if name == "" {
+			return "map[string]any"
+		}
+		g.structType(name, s)
+		return name
+	}
+	return "any"
+}
+
+func (s *clientSchema) empty() bool {
+	return s.Ref == "" && s.Type == "" && s.Items == nil && s.Properties == nil && s.AdditionalProperties == nil
+}
+
+// goInitialisms are words written in upper case in Go names.
+var goInitialisms = map[string]string{
+	"id": "ID", "ids": "IDs", "url": "URL", "uri": "URI", "api": "API", "http": "HTTP",
+	"ip": "IP", "json": "JSON", "uuid": "UUID", "ulid": "ULID", "sql": "SQL",
+}
+
+// goName returns s as an exported Go identifier: "user_id" → "UserID",
+// "getApiUsersId" → "GetAPIUsersID".
+func goName(s string) string {
+	var words []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, string(cur))
+			cur = cur[:0]
+		}
+	}
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && len(cur) > 0 && (unicode.IsLower(cur[len(cur)-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))):
+			flush()
+			cur = append(cur, r)
+		default:
+			cur = append(cur, r)
+		}
+	}
+	flush()
+
+	var b strings.Builder
+	for _, w := range words {
+		if up, ok := goInitialisms[strings.ToLower(w)]; ok {
+			b.WriteString(up)
+			continue
+		}
+		r := []rune(w)
+		b.WriteString(strings.ToUpper(string(r[0])) + string(r[1:]))
+	}
+	name := b.String()
+	if name != "" && unicode.IsDigit([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
+
+func httpMethodConst(method string) string {
+	switch method {
+	case "GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS":
+		return "http.Method" + method[:1] + strings.ToLower(method[1:])
+	}
+	return strconv.Quote(method)
+}
+
+func hasBodyMethod(method string) bool {
+	switch method {
+	case "POST", "PUT", "PATCH":
+		return true
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,387 @@
+// Package apidoc generates an OpenAPI 3.1 document from registered route
+// metadata and the Go types of requests and responses.
+//
+//	docs := apidoc.NewRegistry(apidoc.Info{Title: "Orders API", Version: "1.0.0"})
+//	docs.Add(apidoc.Route{
+//	    Method:   http.MethodPost,
+//	    Path:     "/api/orders",
+//	    Summary:  "Create an order",
+//	    Request:  CreateOrderReq{},
+//	    Response: Order{},
+//	    Auth:     true,
+//	    Errors:   []int{code.ErrValidation, code.ErrAlreadyExists},
+//	})
+//	e.GET("/openapi.json", docs.Handler())
+//
+// Routes declaring the API versions they serve (see Route.Versions) are
+// also documented in one document per version, served with
+// /openapi.json?version=v2.
+package apidoc
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/labstack/echo/v4"
+)
+
+// Info is the document's info section.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Route describes one endpoint.
+type Route struct {
+	Method  string
+	Path    string // echo path, e.g. "/api/users/:id"
+	Summary string
+	Tags    []string
+
+	// Request is a value of the request type (nil for none). Fields tagged
+	// `param` and `query` become parameters; the rest form the JSON body.
+	Request any
+	// Response is a value of the response data type (nil for none).
+	Response any
+	// List marks Response as the item type of a ListResponse.
+	List bool
+
+	// Auth requires a bearer token.
+	Auth bool
+	// Errors are the error codes the route may return.
+	Errors []int
+
+	// Deprecated marks the route as deprecated: it is flagged in the
+	// document and middleware.ClientVersion adds a Deprecation header to
+	// its responses, with Sunset when set.
+	Deprecated bool
+	// Sunset is when a deprecated route stops working (optional).
+	Sunset time.Time
+	// Link documents the replacement of a deprecated route (optional).
+	Link string
+
+	// Versions are the API versions the route serves, e.g. "v1" (see
+	// middleware.VersionedRoute); empty serves every version. In the
+	// document of a version, a ":version" path parameter is replaced by
+	// the version.
+	Versions []string
+}
+
+// VersionParam is the path parameter replaced by the version in the
+// document of a version, as in "/api/:version/users".
+const VersionParam = "version"
+
+// serves reports whether route serves version; every route serves "".
+func (route Route) serves(version string) bool {
+	return version == "" || len(route.Versions) == 0 || slices.Contains(route.Versions, version)
+}
+
+// Registry collects routes and builds the OpenAPI document.
+type Registry struct {
+	info Info
+
+	mu     sync.Mutex
+	routes []Route
+	index  map[string]int    // method + " " + path -> last route added
+	docs   map[string][]byte // version ("" for all) -> document
+}
+
+// NewRegistry creates a Registry.
+func NewRegistry(info Info) *Registry {
+	return &Registry{info: info, index: make(map[string]int)}
+}
+
+// Add registers a route.
+func (r *Registry) Add(route Route) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = append(r.routes, route)
+	r.index[route.Method+" "+route.Path] = len(r.routes) - 1
+	r.docs = nil
+}
+
+// Lookup returns the route registered with method and echo path.
+func (r *Registry) Lookup(method, path string) (Route, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i, ok := r.index[method+" "+path]
+	if !ok {
+		return Route{}, false
+	}
+	return r.routes[i], true
+}
+
+// Routes returns the registered routes.
+func (r *Registry) Routes() []Route {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Route(nil), r.routes...)
+}
+
+// Versions returns the API versions declared by the routes, sorted.
+func (r *Registry) Versions() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var versions []string
+	for _, route := range r.routes {
+		for _, v := range route.Versions {
+			if !slices.Contains(versions, v) {
+				versions = append(versions, v)
+			}
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// JSON returns the OpenAPI document as indented JSON. The output is
+// deterministic, so it can be compared against golden files.
+func (r *Registry) JSON() ([]byte, error) {
+	return r.VersionJSON("")
+}
+
+// VersionJSON returns the OpenAPI document of the routes serving version,
+// with version as the info version; "" documents every route like JSON.
+func (r *Registry) VersionJSON(version string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if doc, ok := r.docs[version]; ok {
+		return doc, nil
+	}
+	doc, err := json.MarshalIndent(r.build(version), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if r.docs == nil {
+		r.docs = make(map[string][]byte)
+	}
+	r.docs[version] = doc
+	return doc, nil
+}
+
+// Handler serves the OpenAPI document (mount at /openapi.json), or the
+// document of the version in the "version" query parameter.
+func (r *Registry) Handler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		version := c.QueryParam("version")
+		if version != "" && !slices.Contains(r.Versions(), version) {
+			return code.NewErrorf(code.ErrAPIVersionUnsupported, "no routes of API version %s", version)
+		}
+		doc, err := r.VersionJSON(version)
+		if err != nil {
+			return err
+		}
+		return c.JSONBlob(http.StatusOK, doc)
+	}
+}
+
+var pathParamRe = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// build assembles the document of version ("" for all routes). Caller
+// holds r.mu.
+func (r *Registry) build(version string) map[string]any {
+	gen := newSchemaGen()
+	paths := map[string]any{}
+	secured := false
+
+	var routes []Route
+	for _, route := range r.routes {
+		if !route.serves(version) {
+			continue
+		}
+		if version != "" {
+			route.Path = strings.ReplaceAll(route.Path, "/:"+VersionParam, "/"+version)
+		}
+		routes = append(routes, route)
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	for _, route := range routes {
+		path := pathParamRe.ReplaceAllString(route.Path, "{$1}")
+		item, _ := paths[path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[path] = item
+		}
+
+		op := map[string]any{
+			"operationId": operationID(route),
+			"responses":   r.responses(gen, route),
+		}
+		if route.Summary != "" {
+			op["summary"] = route.Summary
+		}
+		if len(route.Tags) > 0 {
+			op["tags"] = route.Tags
+		}
+		if route.Deprecated {
+			op["deprecated"] = true
+		}
+		if route.Auth {
+			secured = true
+			op["security"] = []any{map[string]any{"bearerAuth": []string{}}}
+		}
+		if route.Request != nil {
+			params, body := gen.request(route.Request)
+			if len(params) > 0 {
+				op["parameters"] = params
+			}
+			if body != nil && hasBody(route.Method) {
+				op["requestBody"] = map[string]any{
+					"required": true,
+					"content":  map[string]any{"application/json": map[string]any{"schema": body}},
+				}
+			}
+		}
+		item[strings.ToLower(route.Method)] = op
+	}
+
+	components := map[string]any{"schemas": gen.components}
+	if secured {
+		components["securitySchemes"] = map[string]any{
+			"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+		}
+	}
+	info := r.info
+	if version != "" {
+		info.Version = version
+	}
+	doc := map[string]any{
+		"openapi":    "3.1.0",
+		"info":       info,
+		"paths":      paths,
+		"components": components,
+	}
+	if codes := errorCatalog(routes); len(codes) > 0 {
+		doc["x-error-codes"] = codes
+	}
+	return doc
+}
+
+// ErrorCode is an entry of the "x-error-codes" extension of the document:
+// the error codes the routes may return, with their registered messages.
+type ErrorCode struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// errorCatalog returns the error codes of routes, sorted.
+func errorCatalog(routes []Route) []ErrorCode {
+	seen := map[int]bool{}
+	var codes []ErrorCode
+	for _, route := range routes {
+		for _, c := range route.Errors {
+			if seen[c] {
+				continue
+			}
+			seen[c] = true
+			ec := ErrorCode{Code: c}
+			if coder, ok := errors.Lookup(c); ok {
+				ec.Message = coder.Message()
+			}
+			codes = append(codes, ec)
+		}
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i].Code < codes[j].Code })
+	return codes
+}
+
+// responses builds the success envelope and one error response per status.
+func (r *Registry) responses(gen *schemaGen, route Route) map[string]any {
+	data := map[string]any{}
+	if route.Response != nil {
+		data = gen.schemaOf(route.Response)
+		if route.List {
+			data = map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"list":  map[string]any{"type": "array", "items": data},
+					"total": map[string]any{"type": "integer", "format": "int64"},
+				},
+				"required": []string{"list", "total"},
+			}
+		}
+	}
+	out := map[string]any{
+		"200": map[string]any{
+			"description": "Success",
+			"content":     map[string]any{"application/json": map[string]any{"schema": envelope(data, nil)}},
+		},
+	}
+
+	byStatus := map[int][]int{}
+	for _, c := range route.Errors {
+		status := errors.HTTPStatus(c)
+		byStatus[status] = append(byStatus[status], c)
+	}
+	for status, codes := range byStatus {
+		sort.Ints(codes)
+		var msgs []string
+		for _, c := range codes {
+			if coder, ok := errors.Lookup(c); ok {
+				msgs = append(msgs, coder.Message())
+			}
+		}
+		out[itoa(status)] = map[string]any{
+			"description": strings.Join(msgs, "; "),
+			"content":     map[string]any{"application/json": map[string]any{"schema": envelope(nil, codes)}},
+		}
+	}
+	return out
+}
+
+// envelope wraps data in the resp.Response schema.
+func envelope(data map[string]any, codes []int) map[string]any {
+	codeSchema := map[string]any{"type": "integer"}
+	if len(codes) > 0 {
+		codeSchema["enum"] = codes
+	}
+	props := map[string]any{
+		"code": codeSchema,
+		"msg":  map[string]any{"type": "string"},
+	}
+	if data != nil {
+		props["data"] = data
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": props,
+		"required":   []string{"code", "msg"},
+	}
+}
+
+func operationID(route Route) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(route.Method))
+	for _, seg := range strings.Split(route.Path, "/") {
+		seg = strings.TrimPrefix(seg, ":")
+		if seg == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(seg[:1]))
+		b.WriteString(seg[1:])
+	}
+	return b.String()
+}
+
+func hasBody(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	}
+	return false
+}
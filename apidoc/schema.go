@@ -0,0 +1,309 @@
+package apidoc
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaGen converts Go types to JSON Schema, collecting named structs as
+// components.
+type schemaGen struct {
+	components map[string]any
+}
+
+func newSchemaGen() *schemaGen {
+	return &schemaGen{components: map[string]any{}}
+}
+
+func (g *schemaGen) schemaOf(v any) map[string]any {
+	return g.schema(reflect.TypeOf(v))
+}
+
+// request splits a request type into parameters (`param`/`query` tags) and
+// a body schema of the remaining JSON fields (nil if none).
+func (g *schemaGen) request(v any) ([]any, map[string]any) {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, g.schema(t)
+	}
+
+	var params []any
+	body := map[string]any{"type": "object"}
+	props := map[string]any{}
+	var required []string
+
+	for _, f := range structFields(t) {
+		rules := parseValidate(f.Tag.Get("validate"))
+		if name := f.Tag.Get("param"); name != "" {
+			params = append(params, map[string]any{
+				"name": name, "in": "path", "required": true, "schema": g.field(f.Type, rules),
+			})
+			continue
+		}
+		if name := f.Tag.Get("query"); name != "" {
+			p := map[string]any{"name": name, "in": "query", "schema": g.field(f.Type, rules)}
+			if _, ok := rules["required"]; ok {
+				p["required"] = true
+			}
+			params = append(params, p)
+			continue
+		}
+		name, ok := jsonName(f)
+		if !ok {
+			continue
+		}
+		props[name] = g.field(f.Type, rules)
+		if _, ok := rules["required"]; ok {
+			required = append(required, name)
+		}
+	}
+
+	if len(props) == 0 {
+		return params, nil
+	}
+	body["properties"] = props
+	if len(required) > 0 {
+		body["required"] = required
+	}
+	return params, body
+}
+
+// schema returns the schema for t, using $ref for named structs.
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		name := schemaName(t)
+		if name == "" {
+			return g.object(t)
+		}
+		if _, ok := g.components[name]; !ok {
+			g.components[name] = map[string]any{} // placeholder for recursive types
+			g.components[name] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+// object builds an inline object schema for struct t.
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	for _, f := range structFields(t) {
+		name, ok := jsonName(f)
+		if !ok {
+			continue
+		}
+		rules := parseValidate(f.Tag.Get("validate"))
+		props[name] = g.field(f.Type, rules)
+		if _, ok := rules["required"]; ok {
+			required = append(required, name)
+		}
+	}
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// field returns the schema of a field with validate constraints applied.
+func (g *schemaGen) field(t reflect.Type, rules map[string]string) map[string]any {
+	base := g.schema(t)
+	if _, isRef := base["$ref"]; isRef || len(rules) == 0 {
+		return base
+	}
+
+	s := make(map[string]any, len(base)+2)
+	for k, v := range base {
+		s[k] = v
+	}
+	typ, _ := s["type"].(string)
+	for rule, arg := range rules {
+		switch rule {
+		case "min", "max", "len", "gte", "lte", "gt", "lt":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			applyBound(s, typ, rule, n)
+		case "oneof":
+			var enum []any
+			for _, v := range strings.Fields(arg) {
+				enum = append(enum, enumValue(typ, v))
+			}
+			s["enum"] = enum
+		case "email":
+			s["format"] = "email"
+		case "url", "uri":
+			s["format"] = "uri"
+		case "uuid", "uuid4":
+			s["format"] = "uuid"
+		case "ip":
+			s["format"] = "ip"
+		case "datetime":
+			s["format"] = "date-time"
+		}
+	}
+	return s
+}
+
+// applyBound maps a numeric validate rule onto the keyword for typ.
+func applyBound(s map[string]any, typ, rule string, n float64) {
+	var lo, hi string
+	switch typ {
+	case "string":
+		lo, hi = "minLength", "maxLength"
+	case "array":
+		lo, hi = "minItems", "maxItems"
+	case "object":
+		lo, hi = "minProperties", "maxProperties"
+	default:
+		lo, hi = "minimum", "maximum"
+	}
+	switch rule {
+	case "min", "gte":
+		s[lo] = n
+	case "max", "lte":
+		s[hi] = n
+	case "len":
+		s[lo], s[hi] = n, n
+	case "gt":
+		if lo == "minimum" {
+			s["exclusiveMinimum"] = n
+		} else {
+			s[lo] = n + 1
+		}
+	case "lt":
+		if hi == "maximum" {
+			s["exclusiveMaximum"] = n
+		} else {
+			s[hi] = n - 1
+		}
+	}
+}
+
+func enumValue(typ, v string) any {
+	switch typ {
+	case "integer":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
+
+// parseValidate parses a validate tag into rule → argument. Only the
+// top-level rules are considered; "dive" and later rules apply to elements.
+func parseValidate(tag string) map[string]string {
+	if tag == "" {
+		return nil
+	}
+	rules := map[string]string{}
+	for _, part := range strings.Split(tag, ",") {
+		if part == "dive" {
+			break
+		}
+		name, arg, _ := strings.Cut(part, "=")
+		rules[name] = arg
+	}
+	return rules
+}
+
+// structFields returns the exported fields of t with embedded structs
+// flattened.
+func structFields(t reflect.Type) []reflect.StructField {
+	var out []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				out = append(out, structFields(ft)...)
+				continue
+			}
+		}
+		if f.IsExported() {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// jsonName returns the JSON property name of f; false if it is skipped.
+func jsonName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	return name, true
+}
+
+// schemaName returns the component name of a named struct type, e.g.
+// "Page_User" for Page[User]; empty for anonymous structs.
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	if name == "" {
+		return ""
+	}
+	r := strings.NewReplacer("[", "_", "]", "", "*", "", ",", "_", " ", "")
+	name = r.Replace(name)
+	// Drop package paths of type arguments: "Page_github.com/x/y.User" → "Page_User".
+	parts := strings.Split(name, "_")
+	for i, p := range parts {
+		if idx := strings.LastIndex(p, "."); idx >= 0 {
+			parts[i] = p[idx+1:]
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+func itoa(n int) string {
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,45 @@
+package apidoc
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/labstack/echo/v4"
+)
+
+// swaggerPage loads Swagger UI from a CDN and points it at the spec URL.
+var swaggerPage = template.Must(template.New("swagger").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: {{.SpecURL}}, dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`))
+
+// SwaggerUIHandler serves a Swagger UI page for the spec at specURL. It
+// responds 404 when env is "prod".
+//
+//	e.GET("/docs", apidoc.SwaggerUIHandler("/openapi.json", cfg.System.Env))
+func SwaggerUIHandler(specURL, env string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if env == "prod" {
+			return code.NewNotFoundError("page")
+		}
+		c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+		c.Response().WriteHeader(http.StatusOK)
+		return swaggerPage.Execute(c.Response(), map[string]string{
+			"Title":   "API Documentation",
+			"SpecURL": specURL,
+		})
+	}
+}
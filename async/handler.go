@@ -0,0 +1,54 @@
+package async
+
+import (
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/resp"
+	"github.com/labstack/echo/v4"
+)
+
+// StatusHandler serves the Job with the ID of the :id path parameter.
+// With ?wait=30s a job that is not done is long-polled: the response is
+// sent on its next update or when the wait (capped by Options.MaxWait)
+// elapses, whichever comes first. Unknown and expired jobs are 404.
+func (s *JobStore) StatusHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		id := c.Param("id")
+
+		var wait time.Duration
+		if v := c.QueryParam("wait"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				return code.NewErrorf(code.ErrBadRequest, "invalid wait %q: expected a duration such as 30s", v)
+			}
+			wait = min(d, s.opts.MaxWait)
+		}
+
+		// Watch before reading, so an update in between is not missed.
+		updated, release := s.watch(id)
+		defer release()
+
+		job, err := s.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if wait == 0 || job.Status.Done() {
+			return resp.SuccessJSON(c, job)
+		}
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-updated:
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if job, err = s.Get(ctx, id); err != nil {
+			return err
+		}
+		return resp.SuccessJSON(c, job)
+	}
+}
@@ -0,0 +1,320 @@
+// Package async tracks operations that outlive their request: an endpoint
+// starts a job, answers 202 Accepted with a link to its status, and clients
+// poll or long-poll the status route until the job is done.
+//
+//	jobs := async.NewJobStore(cache.NewRedisCache(manager.Redis, "app"), async.Options{Bus: bus})
+//	go jobs.Listen(ctx)
+//	e.GET("/api/jobs/:id", jobs.StatusHandler()).Name = resp.JobStatusRoute
+//
+//	e.POST("/api/reports", func(c echo.Context) error {
+//	    id, err := jobs.StartJob(c.Request().Context(), func(ctx context.Context, progress async.Progress) (any, error) {
+//	        return buildReport(ctx, progress)
+//	    })
+//	    if err != nil {
+//	        return err
+//	    }
+//	    return resp.AcceptedJob(c, id)
+//	})
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/NSObjects/go-kit/cache"
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/lifecycle"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/pubsub"
+	"github.com/NSObjects/go-kit/resp"
+	"github.com/NSObjects/go-kit/utils"
+)
+
+// Status is the state of a job.
+type Status string
+
+// Job states. Succeeded and failed are final.
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// Done reports whether s is final.
+func (s Status) Done() bool {
+	return s == StatusSucceeded || s == StatusFailed
+}
+
+// Job is the status of a job, as served by StatusHandler.
+type Job struct {
+	ID     string `json:"id"`
+	Status Status `json:"status"`
+	// Progress is the completion percentage, 0-100.
+	Progress int `json:"progress"`
+	// Result is the JSON result of a succeeded job.
+	Result json.RawMessage `json:"result,omitempty"`
+	// Error is the coded error of a failed job, as APIError would send it.
+	Error     *JobError `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// ExpiresAt is when the job is forgotten: TTL after the last update.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// JobError is the error of a failed job.
+type JobError struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// Err returns the error of a failed job as a coded error, or nil.
+func (j Job) Err() error {
+	if j.Error == nil {
+		return nil
+	}
+	return errors.WithCode(j.Error.Code, "%s", j.Error.Msg)
+}
+
+// Progress reports the completion percentage of a running job. Values are
+// clamped to 0-100.
+type Progress func(percent int)
+
+// Func is the work of a job. Its result is stored as JSON; its error as a
+// coded error (see resp.ClientError).
+type Func func(ctx context.Context, progress Progress) (any, error)
+
+// Options configure a JobStore.
+type Options struct {
+	// TTL is how long a job is kept after its last update; default 24h.
+	// Expired jobs are not found.
+	TTL time.Duration
+	// Prefix is the key prefix of jobs in the cache; default "async:job:".
+	Prefix string
+	// Bus carries job updates between instances, so long-polls on one
+	// instance wake up on updates made by another (see Listen). Without
+	// it only updates of the same process wake them.
+	Bus pubsub.Bus
+	// Topic is the Bus topic of job updates; default "async.jobs".
+	Topic string
+	// MaxWait caps the ?wait of StatusHandler; default 60s.
+	MaxWait time.Duration
+	// Lifecycle, when set, gets a hook waiting on stop for the running
+	// jobs of this process (see Wait).
+	Lifecycle *lifecycle.Lifecycle
+}
+
+// JobStore runs jobs and stores their status in a cache.
+type JobStore struct {
+	jobs *cache.Typed[Job]
+	opts Options
+
+	mu      sync.Mutex
+	waiters map[string]map[chan struct{}]struct{}
+	running sync.WaitGroup
+}
+
+// NewJobStore creates a JobStore on top of c.
+func NewJobStore(c cache.Cache, opts Options) *JobStore {
+	if opts.TTL <= 0 {
+		opts.TTL = 24 * time.Hour
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "async:job:"
+	}
+	if opts.Topic == "" {
+		opts.Topic = "async.jobs"
+	}
+	if opts.MaxWait <= 0 {
+		opts.MaxWait = time.Minute
+	}
+	s := &JobStore{
+		jobs:    cache.NewTyped[Job](c),
+		opts:    opts,
+		waiters: make(map[string]map[chan struct{}]struct{}),
+	}
+	if opts.Lifecycle != nil {
+		opts.Lifecycle.Append(lifecycle.Hook{Name: "async.jobs", OnStop: s.Wait})
+	}
+	return s
+}
+
+// Get returns the job id. Unknown and expired jobs are code.ErrNotFound.
+func (s *JobStore) Get(ctx context.Context, id string) (Job, error) {
+	job, ok, err := s.jobs.Get(ctx, s.opts.Prefix+id)
+	if err != nil {
+		return Job{}, code.WrapError(err, code.ErrInternalServer, "get job")
+	}
+	if !ok {
+		return Job{}, code.NewErrorf(code.ErrNotFound, "job %s not found", id)
+	}
+	return job, nil
+}
+
+// StartJob stores a pending job and runs fn in a goroutine, recording its
+// progress, result or error, and returns the job ID. fn runs with the
+// values of ctx but not its cancellation, so it outlives the request; a
+// panic in fn fails the job. Wait waits for the running jobs.
+func (s *JobStore) StartJob(ctx context.Context, fn Func) (string, error) {
+	now := time.Now()
+	job := Job{ID: utils.NewULID(), Status: StatusPending, CreatedAt: now}
+	if err := s.save(ctx, &job, now); err != nil {
+		return "", err
+	}
+
+	ctx = utils.DetachContext(ctx)
+	s.running.Add(1)
+	lifecycle.Go(ctx, "async.job", func(ctx context.Context) error {
+		defer s.running.Done()
+		s.run(ctx, job, fn)
+		return nil
+	}, lifecycle.WorkerOptions{Once: true})
+	return job.ID, nil
+}
+
+// run runs fn and records its outcome.
+func (s *JobStore) run(ctx context.Context, job Job, fn Func) {
+	var mu sync.Mutex // serializes progress and the final update
+	done := false
+	update := func(apply func(*Job)) {
+		mu.Lock()
+		defer mu.Unlock()
+		if done {
+			return
+		}
+		apply(&job)
+		done = job.Status.Done()
+		if err := s.save(ctx, &job, time.Now()); err != nil {
+			slog.WarnContext(ctx, "Job update failed",
+				slog.String("job_id", job.ID),
+				slog.String("status", string(job.Status)),
+				log.Err(err),
+			)
+		}
+	}
+
+	update(func(j *Job) { j.Status = StatusRunning })
+	progress := func(percent int) {
+		update(func(j *Job) { j.Progress = min(max(percent, 0), 100) })
+	}
+
+	result, err := safeRun(ctx, fn, progress)
+	var data []byte
+	if err == nil && result != nil {
+		if data, err = json.Marshal(result); err != nil {
+			err = code.WrapError(err, code.ErrEncodingFailed, "encode job result")
+		}
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Job failed", slog.String("job_id", job.ID), log.Err(err))
+		errorCode, msg := resp.ClientError(err)
+		update(func(j *Job) {
+			j.Status = StatusFailed
+			j.Error = &JobError{Code: errorCode, Msg: msg}
+		})
+		return
+	}
+	update(func(j *Job) {
+		j.Status = StatusSucceeded
+		j.Progress = 100
+		j.Result = data
+	})
+}
+
+// safeRun runs fn and converts a panic into a coded error (see
+// errors.FromPanic).
+func safeRun(ctx context.Context, fn Func, progress Progress) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.FromPanic(r)
+		}
+	}()
+	return fn(ctx, progress)
+}
+
+// save stores job, extending its expiry, and announces the update.
+func (s *JobStore) save(ctx context.Context, job *Job, now time.Time) error {
+	job.UpdatedAt = now
+	job.ExpiresAt = now.Add(s.opts.TTL)
+	if err := s.jobs.Set(ctx, s.opts.Prefix+job.ID, *job, s.opts.TTL); err != nil {
+		return code.WrapError(err, code.ErrInternalServer, "store job")
+	}
+	s.notify(job.ID)
+	if s.opts.Bus != nil {
+		if err := pubsub.Publish(ctx, s.opts.Bus, s.opts.Topic, jobUpdate{ID: job.ID}); err != nil {
+			slog.WarnContext(ctx, "Job update not published", slog.String("job_id", job.ID), log.Err(err))
+		}
+	}
+	return nil
+}
+
+// Wait waits until the running jobs of this process are done or ctx is
+// done, e.g. on shutdown.
+func (s *JobStore) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// jobUpdate is the Bus message of a job update.
+type jobUpdate struct {
+	ID string `json:"id"`
+}
+
+// Listen wakes the long-polls of this process on the job updates of other
+// instances, read from Options.Bus, until ctx is done. Without a Bus it
+// returns at once.
+func (s *JobStore) Listen(ctx context.Context) error {
+	if s.opts.Bus == nil {
+		return nil
+	}
+	return pubsub.Subscribe(ctx, s.opts.Bus, s.opts.Topic, func(ctx context.Context, u jobUpdate) error {
+		s.notify(u.ID)
+		return nil
+	})
+}
+
+// watch returns a channel closed on the next update of job id, and a
+// function releasing it.
+func (s *JobStore) watch(id string) (<-chan struct{}, func()) {
+	ch := make(chan struct{})
+	s.mu.Lock()
+	if s.waiters[id] == nil {
+		s.waiters[id] = make(map[chan struct{}]struct{})
+	}
+	s.waiters[id][ch] = struct{}{}
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.waiters[id][ch]; ok {
+			delete(s.waiters[id], ch)
+			if len(s.waiters[id]) == 0 {
+				delete(s.waiters, id)
+			}
+		}
+	}
+}
+
+// notify wakes the watchers of job id.
+func (s *JobStore) notify(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.waiters[id] {
+		close(ch)
+	}
+	delete(s.waiters, id)
+}
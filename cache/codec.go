@@ -0,0 +1,21 @@
+package cache
+
+import "encoding/json"
+
+// Codec encodes and decodes cached values.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec is the default Codec using encoding/json.
+type JSONCodec struct{}
+
+// Marshal implements Codec.
+func (JSONCodec) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+// Unmarshal implements Codec.
+func (JSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// DefaultCodec is the codec used when none is configured.
+var DefaultCodec Codec = JSONCodec{}
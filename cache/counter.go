@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Counter is implemented by caches with atomic integer counters, such as
+// RedisCache. Counters are readable with Get into an integer.
+type Counter interface {
+	// IncrBy adds n to the counter at key, creating it at 0, and returns
+	// the new value. expiration applies when the counter is created; 0
+	// keeps the counter forever.
+	IncrBy(ctx context.Context, key string, n int64, expiration time.Duration) (int64, error)
+}
+
+// ErrCounterUnsupported is returned by IncrBy of a prefixed cache whose
+// underlying cache does not implement Counter.
+var ErrCounterUnsupported = errors.New("cache: counters not supported")
+
+// incrScript increments the counter and sets its TTL when it has none.
+var incrScript = redis.NewScript(`
+local v = redis.call("incrby", KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 and redis.call("pttl", KEYS[1]) == -1 then
+	redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return v
+`)
+
+// IncrBy implements Counter.
+func (c *RedisCache) IncrBy(ctx context.Context, key string, n int64, expiration time.Duration) (int64, error) {
+	return incrScript.Run(ctx, c.client, []string{c.key(key)}, n, expiration.Milliseconds()).Int64()
+}
+
+// IncrBy implements Counter when the underlying cache does.
+func (p prefixCache) IncrBy(ctx context.Context, key string, n int64, expiration time.Duration) (int64, error) {
+	counter, ok := p.cache.(Counter)
+	if !ok {
+		return 0, ErrCounterUnsupported
+	}
+	return counter.IncrBy(ctx, p.prefix+key, n, expiration)
+}
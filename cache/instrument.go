@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Observer receives the result of every operation of an InstrumentedCache:
+// op is "get", "set", "delete", "exists", "incr" or "cas"; result is "hit"
+// or "miss" for reads, "ok" for writes, "conflict" for a lost
+// CompareAndSet, "decode_error" for undecodable values (see DecodeError)
+// and "error" on other failures. metrics.CacheMetrics
+// implements it.
+type Observer interface {
+	ObserveCache(name, op, result string, duration time.Duration)
+}
+
+// Stats are the operation counts of an InstrumentedCache. Errors include
+// DecodeErrors.
+type Stats struct {
+	Hits         uint64
+	Misses       uint64
+	Sets         uint64
+	Errors       uint64
+	DecodeErrors uint64
+}
+
+// InstrumentedCache is a Cache decorator counting hits, misses and errors.
+type InstrumentedCache struct {
+	cache    Cache
+	name     string
+	observer Observer
+
+	hits, misses, sets, errs, decodeErrs atomic.Uint64
+}
+
+// Instrument wraps c. name labels the observations; observer may be nil.
+//
+//	c := cache.Instrument(redisCache, "users", metrics.NewCacheMetrics("app"))
+func Instrument(c Cache, name string, observer Observer) *InstrumentedCache {
+	return &InstrumentedCache{cache: c, name: name, observer: observer}
+}
+
+// Stats returns the counts since creation.
+func (c *InstrumentedCache) Stats() Stats {
+	return Stats{
+		Hits:         c.hits.Load(),
+		Misses:       c.misses.Load(),
+		Sets:         c.sets.Load(),
+		Errors:       c.errs.Load(),
+		DecodeErrors: c.decodeErrs.Load(),
+	}
+}
+
+// Get implements Cache.
+func (c *InstrumentedCache) Get(ctx context.Context, key string, dest any) error {
+	start := time.Now()
+	err := c.cache.Get(ctx, key, dest)
+	c.observeRead(err == nil, errors.Is(err, ErrNotFound), err, start)
+	return err
+}
+
+// GetResult implements ResultGetter.
+func (c *InstrumentedCache) GetResult(ctx context.Context, key string, dest any) (bool, error) {
+	start := time.Now()
+	found, err := GetResult(ctx, c.cache, key, dest)
+	c.observeRead(found, !found && err == nil, err, start)
+	return found, err
+}
+
+// GetWithTTL implements TTLGetter when the underlying cache does.
+func (c *InstrumentedCache) GetWithTTL(ctx context.Context, key string, dest any) (bool, time.Duration, error) {
+	start := time.Now()
+	found, ttl, err := GetWithTTL(ctx, c.cache, key, dest)
+	if errors.Is(err, ErrTTLUnsupported) {
+		return found, ttl, err
+	}
+	c.observeRead(found, !found && err == nil, err, start)
+	return found, ttl, err
+}
+
+// Set implements Cache.
+func (c *InstrumentedCache) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	start := time.Now()
+	err := c.cache.Set(ctx, key, value, expiration)
+	if err == nil {
+		c.sets.Add(1)
+	}
+	c.observeWrite("set", err, start)
+	return err
+}
+
+// Delete implements Cache.
+func (c *InstrumentedCache) Delete(ctx context.Context, key string) error {
+	start := time.Now()
+	err := c.cache.Delete(ctx, key)
+	c.observeWrite("delete", err, start)
+	return err
+}
+
+// Exists implements Cache.
+func (c *InstrumentedCache) Exists(ctx context.Context, key string) (bool, error) {
+	start := time.Now()
+	ok, err := c.cache.Exists(ctx, key)
+	switch {
+	case err != nil:
+		c.errs.Add(1)
+		c.observe("exists", "error", start)
+	case ok:
+		c.observe("exists", "hit", start)
+	default:
+		c.observe("exists", "miss", start)
+	}
+	return ok, err
+}
+
+// IncrBy implements Counter when the underlying cache does.
+func (c *InstrumentedCache) IncrBy(ctx context.Context, key string, n int64, expiration time.Duration) (int64, error) {
+	counter, ok := c.cache.(Counter)
+	if !ok {
+		return 0, ErrCounterUnsupported
+	}
+	start := time.Now()
+	v, err := counter.IncrBy(ctx, key, n, expiration)
+	c.observeWrite("incr", err, start)
+	return v, err
+}
+
+// observeRead records a get: a hit when found, a miss when missing and a
+// failure otherwise.
+func (c *InstrumentedCache) observeRead(found, missing bool, err error, start time.Time) {
+	switch {
+	case found:
+		c.hits.Add(1)
+		c.observe("get", "hit", start)
+	case missing:
+		c.misses.Add(1)
+		c.observe("get", "miss", start)
+	case IsDecodeError(err):
+		c.errs.Add(1)
+		c.decodeErrs.Add(1)
+		c.observe("get", "decode_error", start)
+	default:
+		c.errs.Add(1)
+		c.observe("get", "error", start)
+	}
+}
+
+func (c *InstrumentedCache) observeWrite(op string, err error, start time.Time) {
+	if err != nil {
+		c.errs.Add(1)
+		c.observe(op, "error", start)
+		return
+	}
+	c.observe(op, "ok", start)
+}
+
+func (c *InstrumentedCache) observe(op, result string, start time.Time) {
+	if c.observer != nil {
+		c.observer.ObserveCache(c.name, op, result, time.Since(start))
+	}
+}
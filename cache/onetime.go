@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"time"
+)
+
+// One-time token layout: the expiry in Unix milliseconds, then 256 random
+// bits, the first 128 of which select the key and the last 128 of which
+// are verified against their stored hash.
+const (
+	oneTimeExpiryLen   = 8
+	oneTimeSelectorLen = 16
+	oneTimeVerifierLen = 16
+	oneTimeTokenLen    = oneTimeExpiryLen + oneTimeSelectorLen + oneTimeVerifierLen
+)
+
+// OneTimeObserver receives the outcome of every operation of a OneTime:
+// result is "issued", "consumed", "verified" by ConsumePeek, "expired" for
+// tokens past their TTL, "invalid" for unknown, forged or already
+// consumed tokens and "error" on failures. metrics.OneTimeMetrics
+// implements it.
+type OneTimeObserver interface {
+	ObserveOneTime(purpose, result string)
+}
+
+// OneTimeOptions configure a OneTime.
+type OneTimeOptions struct {
+	// Observer, when set, counts the operations by purpose and result.
+	Observer OneTimeObserver
+	// Clock is the time source of the expiries; default the real clock.
+	// utils.Clock implements it, e.g. a utils.FakeClock in tests.
+	Clock interface{ Now() time.Time }
+	// Entropy provides the random bits of the tokens; default
+	// crypto/rand. Only tests should set it, e.g. to a seeded utils.Rand.
+	Entropy io.Reader
+}
+
+// OneTime issues tokens usable exactly once before their TTL, for
+// password-reset links, email verification codes or webhook nonces. Each
+// token maps to a payload stored in Redis under
+// "<cache prefix>:onetime:<purpose>:<selector>"; only a hash of its
+// secret half is stored, and it is compared in constant time.
+//
+//	tokens := cache.NewOneTime(redisCache)
+//	token, err := tokens.Issue(ctx, "password_reset", 30*time.Minute, userID)
+//	// ... later, from the link:
+//	var userID int64
+//	ok, err := tokens.Consume(ctx, "password_reset", token, &userID)
+//	if err == nil && !ok {
+//	    return code.NewError(code.ErrTokenInvalid, "reset link expired or already used")
+//	}
+type OneTime struct {
+	cache    *RedisCache
+	observer OneTimeObserver
+	now      func() time.Time
+	entropy  io.Reader
+}
+
+// NewOneTime creates a OneTime storing its tokens in c, with its prefix
+// and codec.
+func NewOneTime(c *RedisCache, opts ...OneTimeOptions) *OneTime {
+	var o OneTimeOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	t := &OneTime{cache: c, observer: o.Observer, now: time.Now, entropy: rand.Reader}
+	if o.Clock != nil {
+		t.now = o.Clock.Now
+	}
+	if o.Entropy != nil {
+		t.entropy = o.Entropy
+	}
+	return t
+}
+
+// Issue stores payload for ttl and returns a new URL-safe token for it,
+// usable once for purpose.
+func (t *OneTime) Issue(ctx context.Context, purpose string, ttl time.Duration, payload any) (string, error) {
+	if ttl <= 0 {
+		return "", errors.New("cache: one-time token TTL must be positive")
+	}
+	data, err := t.cache.codec.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	var raw [oneTimeTokenLen]byte
+	binary.BigEndian.PutUint64(raw[:oneTimeExpiryLen], uint64(t.now().Add(ttl).UnixMilli()))
+	if _, err := io.ReadFull(t.entropy, raw[oneTimeExpiryLen:]); err != nil {
+		return "", err
+	}
+	selector, verifier := raw[oneTimeExpiryLen:oneTimeExpiryLen+oneTimeSelectorLen], raw[oneTimeExpiryLen+oneTimeSelectorLen:]
+
+	sum := sha256.Sum256(verifier)
+	value := append(sum[:], data...)
+	if err := t.cache.client.Set(ctx, t.key(purpose, selector), value, ttl).Err(); err != nil {
+		t.observe(purpose, "error")
+		return "", err
+	}
+	t.observe(purpose, "issued")
+	return base64.RawURLEncoding.EncodeToString(raw[:]), nil
+}
+
+// Consume decodes the payload of token into dest, when dest is not nil,
+// and invalidates the token. It reports false, without an error, for
+// expired, unknown, malformed and already consumed tokens: of parallel
+// calls with one token, exactly one reports true.
+func (t *OneTime) Consume(ctx context.Context, purpose, token string, dest any) (bool, error) {
+	return t.consume(ctx, purpose, token, dest, true)
+}
+
+// ConsumePeek verifies token and decodes its payload into dest like
+// Consume, but leaves the token valid, for flows that finalize later:
+// showing a password-reset form checks the token with ConsumePeek and
+// submitting it calls Consume.
+func (t *OneTime) ConsumePeek(ctx context.Context, purpose, token string, dest any) (bool, error) {
+	return t.consume(ctx, purpose, token, dest, false)
+}
+
+func (t *OneTime) consume(ctx context.Context, purpose, token string, dest any, remove bool) (bool, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != oneTimeTokenLen {
+		t.observe(purpose, "invalid")
+		return false, nil
+	}
+	expiry := time.UnixMilli(int64(binary.BigEndian.Uint64(raw[:oneTimeExpiryLen])))
+	if !t.now().Before(expiry) {
+		t.observe(purpose, "expired")
+		return false, nil
+	}
+	selector, verifier := raw[oneTimeExpiryLen:oneTimeExpiryLen+oneTimeSelectorLen], raw[oneTimeExpiryLen+oneTimeSelectorLen:]
+	key := t.key(purpose, selector)
+
+	value, err := t.cache.client.Get(ctx, key).Bytes()
+	if errors.Is(err, ErrNotFound) {
+		t.observe(purpose, "invalid")
+		return false, nil
+	}
+	if err != nil {
+		t.observe(purpose, "error")
+		return false, err
+	}
+	sum := sha256.Sum256(verifier)
+	if len(value) < len(sum) || subtle.ConstantTimeCompare(value[:len(sum)], sum[:]) != 1 {
+		t.observe(purpose, "invalid")
+		return false, nil
+	}
+
+	if remove {
+		// Delete the value read only, so that one of parallel consumers
+		// wins.
+		n, err := releaseScript.Run(ctx, t.cache.client, []string{key}, value).Int()
+		if err != nil {
+			t.observe(purpose, "error")
+			return false, err
+		}
+		if n == 0 {
+			t.observe(purpose, "invalid")
+			return false, nil
+		}
+	}
+	if dest != nil {
+		if err := t.cache.decode(key, value[len(sum):], dest); err != nil {
+			t.observe(purpose, "error")
+			return false, err
+		}
+	}
+	if remove {
+		t.observe(purpose, "consumed")
+	} else {
+		t.observe(purpose, "verified")
+	}
+	return true, nil
+}
+
+// key returns the cache key of the token with selector.
+func (t *OneTime) key(purpose string, selector []byte) string {
+	return t.cache.key("onetime" + KeySeparator + segment(purpose) + KeySeparator + hex.EncodeToString(selector))
+}
+
+func (t *OneTime) observe(purpose, result string) {
+	if t.observer != nil {
+		t.observer.ObserveOneTime(purpose, result)
+	}
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// WithPrefix returns a Cache storing every key of c under "prefix:", e.g.
+// to keep tenants apart. An empty prefix returns c.
+func WithPrefix(c Cache, prefix string) Cache {
+	if prefix == "" {
+		return c
+	}
+	return prefixCache{cache: c, prefix: prefix + ":"}
+}
+
+type prefixCache struct {
+	cache  Cache
+	prefix string
+}
+
+func (p prefixCache) Get(ctx context.Context, key string, dest any) error {
+	return p.cache.Get(ctx, p.prefix+key, dest)
+}
+
+func (p prefixCache) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	return p.cache.Set(ctx, p.prefix+key, value, expiration)
+}
+
+func (p prefixCache) Delete(ctx context.Context, key string) error {
+	return p.cache.Delete(ctx, p.prefix+key)
+}
+
+func (p prefixCache) Exists(ctx context.Context, key string) (bool, error) {
+	return p.cache.Exists(ctx, p.prefix+key)
+}
+
+func (p prefixCache) GetResult(ctx context.Context, key string, dest any) (bool, error) {
+	return GetResult(ctx, p.cache, p.prefix+key, dest)
+}
+
+func (p prefixCache) GetWithTTL(ctx context.Context, key string, dest any) (bool, time.Duration, error) {
+	return GetWithTTL(ctx, p.cache, p.prefix+key, dest)
+}
@@ -3,13 +3,15 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
-// Cache provides a simple cache interface.
+// Cache provides a simple cache interface. Get returns ErrNotFound for
+// missing keys; GetResult tells misses, undecodable values and failures
+// apart without it.
 type Cache interface {
 	Get(ctx context.Context, key string, dest any) error
 	Set(ctx context.Context, key string, value any, expiration time.Duration) error
@@ -17,45 +19,150 @@ type Cache interface {
 	Exists(ctx context.Context, key string) (bool, error)
 }
 
+// MultiGetter is implemented by caches that read many keys in one round
+// trip (RedisCache, ShardedCache). MGet decodes the value of each found key
+// into the pointer newDest returns for it and returns how many were found;
+// missing keys are skipped. Typed.MGet uses it when available.
+type MultiGetter interface {
+	MGet(ctx context.Context, keys []string, newDest func(key string) any) (int, error)
+}
+
 // RedisCache implements Cache using Redis.
 type RedisCache struct {
 	client *redis.Client
 	prefix string
+	keys   *KeyBuilder
+	codec  Codec
 }
 
 // NewRedisCache creates a new Redis cache.
 func NewRedisCache(client *redis.Client, prefix string) *RedisCache {
+	return NewRedisCacheWithCodec(client, prefix, DefaultCodec)
+}
+
+// NewRedisCacheWithCodec creates a new Redis cache with a custom codec.
+func NewRedisCacheWithCodec(client *redis.Client, prefix string, codec Codec) *RedisCache {
 	return &RedisCache{
 		client: client,
 		prefix: prefix,
+		codec:  codec,
+	}
+}
+
+// NewRedisCacheWithKeys creates a Redis cache namespaced by keys. Keys
+// built with keys are used as is; other keys are prefixed with
+// keys.Prefix().
+func NewRedisCacheWithKeys(client *redis.Client, keys KeyBuilder) *RedisCache {
+	return &RedisCache{
+		client: client,
+		prefix: keys.Prefix(),
+		keys:   &keys,
+		codec:  DefaultCodec,
 	}
 }
 
 func (c *RedisCache) key(k string) string {
+	if c.keys != nil && c.keys.Owns(k) {
+		return k
+	}
 	if c.prefix == "" {
 		return k
 	}
 	return c.prefix + ":" + k
 }
 
-// Get retrieves a value from cache.
+// Get retrieves a value from cache. It returns ErrNotFound for missing
+// keys and a DecodeError for values the codec cannot decode.
 func (c *RedisCache) Get(ctx context.Context, key string, dest any) error {
 	data, err := c.client.Get(ctx, c.key(key)).Bytes()
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(data, dest)
+	return c.decode(key, data, dest)
+}
+
+// GetResult implements ResultGetter.
+func (c *RedisCache) GetResult(ctx context.Context, key string, dest any) (bool, error) {
+	err := c.Get(ctx, key, dest)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetWithTTL implements TTLGetter, reading the value and its TTL in one
+// transaction.
+func (c *RedisCache) GetWithTTL(ctx context.Context, key string, dest any) (bool, time.Duration, error) {
+	var (
+		get *redis.StringCmd
+		ttl *redis.DurationCmd
+	)
+	k := c.key(key)
+	_, err := c.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		get = p.Get(ctx, k)
+		ttl = p.PTTL(ctx, k)
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	data, _ := get.Bytes()
+	if err := c.decode(key, data, dest); err != nil {
+		return false, 0, err
+	}
+	// PTTL is negative for keys without expiration.
+	return true, max(ttl.Val(), 0), nil
+}
+
+// decode decodes the value data of key into dest, unwrapping the
+// envelope of versioned values (see Versioner).
+func (c *RedisCache) decode(key string, data []byte, dest any) error {
+	_, data = unpackVersion(data)
+	if err := c.codec.Unmarshal(data, dest); err != nil {
+		return newDecodeError(key, data, err)
+	}
+	return nil
 }
 
 // Set stores a value in cache.
 func (c *RedisCache) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
-	data, err := json.Marshal(value)
+	data, err := c.codec.Marshal(value)
 	if err != nil {
 		return err
 	}
 	return c.client.Set(ctx, c.key(key), data, expiration).Err()
 }
 
+// MGet implements MultiGetter with one MGET.
+func (c *RedisCache) MGet(ctx context.Context, keys []string, newDest func(key string) any) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	full := make([]string, len(keys))
+	for i, k := range keys {
+		full[i] = c.key(k)
+	}
+	values, err := c.client.MGet(ctx, full...).Result()
+	if err != nil {
+		return 0, err
+	}
+	found := 0
+	for i, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if err := c.decode(keys[i], []byte(s), newDest(keys[i])); err != nil {
+			return found, err
+		}
+		found++
+	}
+	return found, nil
+}
+
 // Delete removes a value from cache.
 func (c *RedisCache) Delete(ctx context.Context, key string) error {
 	return c.client.Del(ctx, c.key(key)).Err()
@@ -66,3 +173,37 @@ func (c *RedisCache) Exists(ctx context.Context, key string) (bool, error) {
 	result, err := c.client.Exists(ctx, c.key(key)).Result()
 	return result > 0, err
 }
+
+// DeleteByPattern deletes the keys matching the SCAN MATCH pattern, e.g.
+// from KeyBuilder.MustPattern, and returns how many were deleted. It scans
+// incrementally, so it does not block Redis, but keys written during the
+// scan may survive.
+func (c *RedisCache) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	if c.keys == nil || !c.keys.Owns(pattern) {
+		pattern = c.key(pattern)
+	}
+	var deleted int64
+	iter := c.client.Scan(ctx, 0, pattern, 500).Iterator()
+	batch := make([]string, 0, 500)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := c.client.Unlink(ctx, batch...).Result()
+		deleted += n
+		batch = batch[:0]
+		return err
+	}
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == cap(batch) {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return deleted, err
+	}
+	return deleted, flush()
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNotFound is returned by Get for missing keys. It is redis.Nil, so
+// checks against either match.
+var ErrNotFound = redis.Nil
+
+// ErrTTLUnsupported is returned by GetWithTTL for caches that cannot read
+// expirations.
+var ErrTTLUnsupported = errors.New("cache: ttl not supported")
+
+// decodeSampleSize bounds the payload sample of a DecodeError.
+const decodeSampleSize = 64
+
+// DecodeError is returned when a cached value cannot be decoded, e.g.
+// after the type of the values stored at a key changed. It is neither a
+// miss nor a transport error: the value is there but unusable, and is best
+// overwritten.
+type DecodeError struct {
+	// Key is the key of the value.
+	Key string
+	// Sample is the start of the payload, for diagnosis.
+	Sample string
+	// Err is the error of the codec.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("cache: decode %s: %v (payload %q)", e.Key, e.Err, e.Sample)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// IsDecodeError reports whether err is or wraps a DecodeError.
+func IsDecodeError(err error) bool {
+	var de *DecodeError
+	return errors.As(err, &de)
+}
+
+// newDecodeError returns the DecodeError of decoding data at key.
+func newDecodeError(key string, data []byte, err error) *DecodeError {
+	sample := data
+	if len(sample) > decodeSampleSize {
+		sample = sample[:decodeSampleSize]
+	}
+	return &DecodeError{Key: key, Sample: string(sample), Err: err}
+}
+
+// ResultGetter is implemented by caches telling misses from failures
+// without a sentinel error (RedisCache, ShardedCache, InstrumentedCache
+// and prefixed caches of those). GetResult decodes the value at key into
+// dest and reports whether it was found: a miss is (false, nil), an
+// undecodable value a DecodeError, and any other error a failure of the
+// cache. dest is meaningful only when found.
+type ResultGetter interface {
+	GetResult(ctx context.Context, key string, dest any) (bool, error)
+}
+
+// TTLGetter is implemented by caches reading a value and its remaining
+// time to live at once. GetWithTTL is GetResult returning the TTL too, 0
+// for values stored without expiration.
+type TTLGetter interface {
+	GetWithTTL(ctx context.Context, key string, dest any) (bool, time.Duration, error)
+}
+
+// GetResult reads key from c into dest like ResultGetter, in one call
+// instead of Exists followed by Get, which races with writers. Caches that
+// are not ResultGetters are read with Get.
+func GetResult(ctx context.Context, c Cache, key string, dest any) (bool, error) {
+	if rg, ok := c.(ResultGetter); ok {
+		return rg.GetResult(ctx, key, dest)
+	}
+	err := c.Get(ctx, key, dest)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetWithTTL reads key from c into dest like TTLGetter, or returns
+// ErrTTLUnsupported when c is not one.
+func GetWithTTL(ctx context.Context, c Cache, key string, dest any) (bool, time.Duration, error) {
+	if tg, ok := c.(TTLGetter); ok {
+		return tg.GetWithTTL(ctx, key, dest)
+	}
+	return false, 0, ErrTTLUnsupported
+}
@@ -0,0 +1,252 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrReplaceUnsupported is returned by Replace for caches without
+// conditional writes.
+var ErrReplaceUnsupported = errors.New("cache: replace not supported")
+
+// ErrSetUnsupported is returned by SetAdd, SetRemove and SetMembers for
+// caches without sets.
+var ErrSetUnsupported = errors.New("cache: sets not supported")
+
+// Replacer is implemented by caches that write a key only while it exists
+// (RedisCache, ShardedCache, InstrumentedCache and prefixed caches of
+// those), so that a write racing a Delete cannot bring the key back.
+type Replacer interface {
+	// Replace stores value at key if the key exists and reports whether
+	// it did. ttl 0 keeps the value forever.
+	Replace(ctx context.Context, key string, value any, ttl time.Duration) (bool, error)
+}
+
+// SetStore is implemented by caches holding sets of strings at a key
+// (RedisCache, ShardedCache, InstrumentedCache and prefixed caches of
+// those). Each operation is atomic, so concurrent writers on different
+// instances do not lose each other's members.
+type SetStore interface {
+	// SetAdd adds members to the set at key and sets its TTL; ttl 0
+	// keeps it forever.
+	SetAdd(ctx context.Context, key string, ttl time.Duration, members ...string) error
+	// SetRemove removes members from the set at key.
+	SetRemove(ctx context.Context, key string, members ...string) error
+	// SetMembers returns the members of the set at key, none for a
+	// missing key.
+	SetMembers(ctx context.Context, key string) ([]string, error)
+}
+
+// Replace writes key to c like Replacer, or returns ErrReplaceUnsupported
+// when c is not one.
+func Replace(ctx context.Context, c Cache, key string, value any, ttl time.Duration) (bool, error) {
+	if r, ok := c.(Replacer); ok {
+		return r.Replace(ctx, key, value, ttl)
+	}
+	return false, ErrReplaceUnsupported
+}
+
+// SetAdd adds members to the set at key of c like SetStore, or returns
+// ErrSetUnsupported when c is not one.
+func SetAdd(ctx context.Context, c Cache, key string, ttl time.Duration, members ...string) error {
+	if s, ok := c.(SetStore); ok {
+		return s.SetAdd(ctx, key, ttl, members...)
+	}
+	return ErrSetUnsupported
+}
+
+// SetRemove removes members from the set at key of c like SetStore, or
+// returns ErrSetUnsupported when c is not one.
+func SetRemove(ctx context.Context, c Cache, key string, members ...string) error {
+	if s, ok := c.(SetStore); ok {
+		return s.SetRemove(ctx, key, members...)
+	}
+	return ErrSetUnsupported
+}
+
+// SetMembers reads the set at key of c like SetStore, or returns
+// ErrSetUnsupported when c is not one.
+func SetMembers(ctx context.Context, c Cache, key string) ([]string, error) {
+	if s, ok := c.(SetStore); ok {
+		return s.SetMembers(ctx, key)
+	}
+	return nil, ErrSetUnsupported
+}
+
+// Replace implements Replacer with SET XX.
+func (c *RedisCache) Replace(ctx context.Context, key string, value any, ttl time.Duration) (bool, error) {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	err = c.client.SetArgs(ctx, c.key(key), data, redis.SetArgs{Mode: "XX", TTL: ttl}).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SetAdd implements SetStore with SADD and PEXPIRE in a transaction.
+func (c *RedisCache) SetAdd(ctx context.Context, key string, ttl time.Duration, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	k := c.key(key)
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, k, stringsToAny(members)...)
+		if ttl > 0 {
+			pipe.PExpire(ctx, k, ttl)
+		} else {
+			pipe.Persist(ctx, k)
+		}
+		return nil
+	})
+	return err
+}
+
+// SetRemove implements SetStore with SREM.
+func (c *RedisCache) SetRemove(ctx context.Context, key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	return c.client.SRem(ctx, c.key(key), stringsToAny(members)...).Err()
+}
+
+// SetMembers implements SetStore with SMEMBERS.
+func (c *RedisCache) SetMembers(ctx context.Context, key string) ([]string, error) {
+	return c.client.SMembers(ctx, c.key(key)).Result()
+}
+
+func stringsToAny(s []string) []any {
+	out := make([]any, len(s))
+	for i, v := range s {
+		out[i] = v
+	}
+	return out
+}
+
+// Replace implements Replacer when the shard of key does. It fails with
+// ErrShardUnavailable on a down shard, even with FailOpen.
+func (s *ShardedCache) Replace(ctx context.Context, key string, value any, ttl time.Duration) (bool, error) {
+	st := s.ring.Load().lookup(key)
+	if !st.available(time.Now()) {
+		return false, ErrShardUnavailable
+	}
+	ok, err := Replace(ctx, st.cache, key, value, ttl)
+	if !errors.Is(err, ErrReplaceUnsupported) {
+		s.record(st, err)
+	}
+	return ok, err
+}
+
+// SetAdd implements SetStore when the shard of key does. Set operations
+// fail with ErrShardUnavailable on a down shard, even with FailOpen: an
+// empty set is not a safe answer for an index.
+func (s *ShardedCache) SetAdd(ctx context.Context, key string, ttl time.Duration, members ...string) error {
+	st := s.ring.Load().lookup(key)
+	if !st.available(time.Now()) {
+		return ErrShardUnavailable
+	}
+	err := SetAdd(ctx, st.cache, key, ttl, members...)
+	if !errors.Is(err, ErrSetUnsupported) {
+		s.record(st, err)
+	}
+	return err
+}
+
+// SetRemove implements SetStore when the shard of key does.
+func (s *ShardedCache) SetRemove(ctx context.Context, key string, members ...string) error {
+	st := s.ring.Load().lookup(key)
+	if !st.available(time.Now()) {
+		return ErrShardUnavailable
+	}
+	err := SetRemove(ctx, st.cache, key, members...)
+	if !errors.Is(err, ErrSetUnsupported) {
+		s.record(st, err)
+	}
+	return err
+}
+
+// SetMembers implements SetStore when the shard of key does.
+func (s *ShardedCache) SetMembers(ctx context.Context, key string) ([]string, error) {
+	st := s.ring.Load().lookup(key)
+	if !st.available(time.Now()) {
+		return nil, ErrShardUnavailable
+	}
+	members, err := SetMembers(ctx, st.cache, key)
+	if !errors.Is(err, ErrSetUnsupported) {
+		s.record(st, err)
+	}
+	return members, err
+}
+
+// Replace implements Replacer when the underlying cache does.
+func (p prefixCache) Replace(ctx context.Context, key string, value any, ttl time.Duration) (bool, error) {
+	return Replace(ctx, p.cache, p.prefix+key, value, ttl)
+}
+
+// SetAdd implements SetStore when the underlying cache does.
+func (p prefixCache) SetAdd(ctx context.Context, key string, ttl time.Duration, members ...string) error {
+	return SetAdd(ctx, p.cache, p.prefix+key, ttl, members...)
+}
+
+// SetRemove implements SetStore when the underlying cache does.
+func (p prefixCache) SetRemove(ctx context.Context, key string, members ...string) error {
+	return SetRemove(ctx, p.cache, p.prefix+key, members...)
+}
+
+// SetMembers implements SetStore when the underlying cache does.
+func (p prefixCache) SetMembers(ctx context.Context, key string) ([]string, error) {
+	return SetMembers(ctx, p.cache, p.prefix+key)
+}
+
+// Replace implements Replacer when the underlying cache does. A missing
+// key is observed as a "miss" of op "replace".
+func (c *InstrumentedCache) Replace(ctx context.Context, key string, value any, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	ok, err := Replace(ctx, c.cache, key, value, ttl)
+	switch {
+	case errors.Is(err, ErrReplaceUnsupported):
+	case err == nil && !ok:
+		c.observe("replace", "miss", start)
+	default:
+		if ok {
+			c.sets.Add(1)
+		}
+		c.observeWrite("replace", err, start)
+	}
+	return ok, err
+}
+
+// SetAdd implements SetStore when the underlying cache does.
+func (c *InstrumentedCache) SetAdd(ctx context.Context, key string, ttl time.Duration, members ...string) error {
+	start := time.Now()
+	err := SetAdd(ctx, c.cache, key, ttl, members...)
+	if !errors.Is(err, ErrSetUnsupported) {
+		c.observeWrite("sadd", err, start)
+	}
+	return err
+}
+
+// SetRemove implements SetStore when the underlying cache does.
+func (c *InstrumentedCache) SetRemove(ctx context.Context, key string, members ...string) error {
+	start := time.Now()
+	err := SetRemove(ctx, c.cache, key, members...)
+	if !errors.Is(err, ErrSetUnsupported) {
+		c.observeWrite("srem", err, start)
+	}
+	return err
+}
+
+// SetMembers implements SetStore when the underlying cache does.
+func (c *InstrumentedCache) SetMembers(ctx context.Context, key string) ([]string, error) {
+	start := time.Now()
+	members, err := SetMembers(ctx, c.cache, key)
+	if !errors.Is(err, ErrSetUnsupported) {
+		c.observeWrite("smembers", err, start)
+	}
+	return members, err
+}
@@ -0,0 +1,417 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NSObjects/go-kit/config"
+	"github.com/cespare/xxhash/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrShardUnavailable is returned for keys of a shard marked down when
+// ShardedOptions.FailOpen is off.
+var ErrShardUnavailable = errors.New("cache: shard unavailable")
+
+// Defaults of ShardedOptions.
+const (
+	DefaultVirtualNodes     = 160
+	DefaultFailureThreshold = 3
+	DefaultShardRetryAfter  = 10 * time.Second
+)
+
+// Shard is a cache of a ShardedCache. Name places it on the hash ring, so
+// it must be stable across restarts and instances, e.g. the Redis address.
+type Shard struct {
+	Name  string
+	Cache Cache
+}
+
+// RedisShards returns a shard per client, named after its address.
+func RedisShards(clients []*redis.Client, prefix string) []Shard {
+	shards := make([]Shard, len(clients))
+	for i, c := range clients {
+		shards[i] = Shard{Name: c.Options().Addr, Cache: NewRedisCache(c, prefix)}
+	}
+	return shards
+}
+
+// ShardedOptions configure a ShardedCache.
+type ShardedOptions struct {
+	// VirtualNodes is the number of ring points per shard; default
+	// DefaultVirtualNodes. More points spread keys more evenly.
+	VirtualNodes int
+	// FailureThreshold is the number of consecutive failures that mark a
+	// shard down; default DefaultFailureThreshold.
+	FailureThreshold int
+	// RetryAfter is how long a down shard is skipped before requests
+	// probe it again; default DefaultShardRetryAfter.
+	RetryAfter time.Duration
+	// FailOpen makes operations on the keys of a down shard succeed as
+	// misses (reads) and no-ops (writes) instead of returning
+	// ErrShardUnavailable. Deletes skipped meanwhile are lost, so cached
+	// values should have TTLs.
+	FailOpen bool
+}
+
+// ShardedCache spreads keys over several caches with a consistent-hash
+// ring: adding or removing a shard only moves the keys of its share of the
+// ring. A shard that keeps failing is marked down and skipped for
+// RetryAfter, so one dead node does not slow every request down.
+//
+//	sharded, err := cache.NewShardedCache(cache.RedisShards(clients, "app"), cache.ShardedOptions{FailOpen: true})
+type ShardedCache struct {
+	opts ShardedOptions
+	ring atomic.Pointer[hashRing]
+}
+
+// NewShardedCache creates a ShardedCache over shards.
+func NewShardedCache(shards []Shard, opts ShardedOptions) (*ShardedCache, error) {
+	if opts.VirtualNodes <= 0 {
+		opts.VirtualNodes = DefaultVirtualNodes
+	}
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = DefaultFailureThreshold
+	}
+	if opts.RetryAfter <= 0 {
+		opts.RetryAfter = DefaultShardRetryAfter
+	}
+	s := &ShardedCache{opts: opts}
+	if err := s.SetShards(shards); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetShards replaces the shards and rebuilds the ring. Shards keeping
+// their name keep their health state. In-flight operations finish on the
+// previous ring.
+func (s *ShardedCache) SetShards(shards []Shard) error {
+	if len(shards) == 0 {
+		return errors.New("cache: sharded cache needs at least one shard")
+	}
+	old := map[string]*shardState{}
+	if r := s.ring.Load(); r != nil {
+		for _, st := range r.shards {
+			old[st.name] = st
+		}
+	}
+
+	r := &hashRing{}
+	seen := map[string]bool{}
+	for _, sh := range shards {
+		if sh.Name == "" || sh.Cache == nil {
+			return errors.New("cache: shard needs a name and a cache")
+		}
+		if seen[sh.Name] {
+			return fmt.Errorf("cache: duplicate shard %q", sh.Name)
+		}
+		seen[sh.Name] = true
+		st := &shardState{name: sh.Name, cache: sh.Cache}
+		if prev, ok := old[sh.Name]; ok && prev.cache == sh.Cache {
+			st = prev
+		}
+		r.shards = append(r.shards, st)
+	}
+	for i, st := range r.shards {
+		for v := 0; v < s.opts.VirtualNodes; v++ {
+			r.points = append(r.points, ringPoint{hash: xxhash.Sum64String(st.name + "#" + strconv.Itoa(v)), shard: i})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i].hash < r.points[j].hash })
+	s.ring.Store(r)
+	return nil
+}
+
+// Shards returns the names of the shards.
+func (s *ShardedCache) Shards() []string {
+	r := s.ring.Load()
+	names := make([]string, len(r.shards))
+	for i, st := range r.shards {
+		names[i] = st.name
+	}
+	return names
+}
+
+// ShardFor returns the name of the shard owning key.
+func (s *ShardedCache) ShardFor(key string) string {
+	return s.ring.Load().lookup(key).name
+}
+
+// Get implements Cache.
+func (s *ShardedCache) Get(ctx context.Context, key string, dest any) error {
+	st := s.ring.Load().lookup(key)
+	if !st.available(time.Now()) {
+		return s.unavailable(ErrNotFound)
+	}
+	err := st.cache.Get(ctx, key, dest)
+	s.record(st, err)
+	return err
+}
+
+// GetResult implements ResultGetter.
+func (s *ShardedCache) GetResult(ctx context.Context, key string, dest any) (bool, error) {
+	st := s.ring.Load().lookup(key)
+	if !st.available(time.Now()) {
+		return false, s.unavailable(nil)
+	}
+	found, err := GetResult(ctx, st.cache, key, dest)
+	s.record(st, err)
+	return found, err
+}
+
+// GetWithTTL implements TTLGetter.
+func (s *ShardedCache) GetWithTTL(ctx context.Context, key string, dest any) (bool, time.Duration, error) {
+	st := s.ring.Load().lookup(key)
+	if !st.available(time.Now()) {
+		return false, 0, s.unavailable(nil)
+	}
+	found, ttl, err := GetWithTTL(ctx, st.cache, key, dest)
+	if !errors.Is(err, ErrTTLUnsupported) {
+		s.record(st, err)
+	}
+	return found, ttl, err
+}
+
+// Set implements Cache.
+func (s *ShardedCache) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	st := s.ring.Load().lookup(key)
+	if !st.available(time.Now()) {
+		return s.unavailable(nil)
+	}
+	err := st.cache.Set(ctx, key, value, expiration)
+	s.record(st, err)
+	return err
+}
+
+// Delete implements Cache.
+func (s *ShardedCache) Delete(ctx context.Context, key string) error {
+	st := s.ring.Load().lookup(key)
+	if !st.available(time.Now()) {
+		return s.unavailable(nil)
+	}
+	err := st.cache.Delete(ctx, key)
+	s.record(st, err)
+	return err
+}
+
+// Exists implements Cache.
+func (s *ShardedCache) Exists(ctx context.Context, key string) (bool, error) {
+	st := s.ring.Load().lookup(key)
+	if !st.available(time.Now()) {
+		return false, s.unavailable(nil)
+	}
+	ok, err := st.cache.Exists(ctx, key)
+	s.record(st, err)
+	return ok, err
+}
+
+// MGet implements MultiGetter: keys are grouped by shard and the shards
+// are read concurrently, with one batch each when the shard is a
+// MultiGetter. newDest is never called concurrently. Keys of down shards
+// are misses with FailOpen; otherwise the first shard error is returned
+// along with the values found on the other shards.
+func (s *ShardedCache) MGet(ctx context.Context, keys []string, newDest func(key string) any) (int, error) {
+	r := s.ring.Load()
+	groups := map[*shardState][]string{}
+	for _, k := range keys {
+		st := r.lookup(k)
+		groups[st] = append(groups[st], k)
+	}
+
+	var mu sync.Mutex
+	dest := func(key string) any {
+		mu.Lock()
+		defer mu.Unlock()
+		return newDest(key)
+	}
+
+	var (
+		wg    sync.WaitGroup
+		found atomic.Int64
+		errMu sync.Mutex
+		first error
+	)
+	fail := func(err error) {
+		errMu.Lock()
+		defer errMu.Unlock()
+		if first == nil {
+			first = err
+		}
+	}
+	now := time.Now()
+	for st, group := range groups {
+		if !st.available(now) {
+			if err := s.unavailable(nil); err != nil {
+				fail(err)
+			}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := shardMGet(ctx, st.cache, group, dest)
+			found.Add(int64(n))
+			s.record(st, err)
+			if err != nil {
+				fail(fmt.Errorf("shard %s: %w", st.name, err))
+			}
+		}()
+	}
+	wg.Wait()
+	return int(found.Load()), first
+}
+
+// shardMGet reads keys from c in one batch if it can, key by key
+// otherwise.
+func shardMGet(ctx context.Context, c Cache, keys []string, newDest func(string) any) (int, error) {
+	if mg, ok := c.(MultiGetter); ok {
+		return mg.MGet(ctx, keys, newDest)
+	}
+	found := 0
+	for _, k := range keys {
+		ok, err := c.Exists(ctx, k)
+		if err != nil {
+			return found, err
+		}
+		if !ok {
+			continue
+		}
+		if err := c.Get(ctx, k, newDest(k)); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return found, err
+		}
+		found++
+	}
+	return found, nil
+}
+
+// unavailable is the result of an operation on a down shard: miss with
+// FailOpen, ErrShardUnavailable otherwise.
+func (s *ShardedCache) unavailable(miss error) error {
+	if s.opts.FailOpen {
+		return miss
+	}
+	return ErrShardUnavailable
+}
+
+// record updates the health of st after an operation. Misses and
+// undecodable values are answers of a healthy shard.
+func (s *ShardedCache) record(st *shardState, err error) {
+	if err == nil || errors.Is(err, ErrNotFound) || IsDecodeError(err) || errors.Is(err, context.Canceled) {
+		st.failures.Store(0)
+		return
+	}
+	if n := st.failures.Add(1); n == int64(s.opts.FailureThreshold) {
+		st.downUntil.Store(time.Now().Add(s.opts.RetryAfter).UnixNano())
+		slog.Warn("Cache shard marked down",
+			slog.String("shard", st.name),
+			slog.Duration("retry_after", s.opts.RetryAfter),
+			slog.String("error", err.Error()),
+		)
+	} else if n > int64(s.opts.FailureThreshold) {
+		// A failed probe keeps the shard down for another period.
+		st.downUntil.Store(time.Now().Add(s.opts.RetryAfter).UnixNano())
+	}
+}
+
+// WatchShards keeps the shards of s in sync with store until ctx is done.
+// names selects the shard names (e.g. Redis addresses) from the
+// configuration; open creates the cache of a new name. Shards removed
+// from the configuration are closed if they implement io.Closer.
+func WatchShards[T any](ctx context.Context, s *ShardedCache, store *config.Store[T], names func(T) []string, open func(name string) (Cache, error)) {
+	events := store.SubscribeEvents()
+	update := func(cfg T) {
+		r := s.ring.Load()
+		current := map[string]Cache{}
+		for _, st := range r.shards {
+			current[st.name] = st.cache
+		}
+		want := names(cfg)
+		if slices.Equal(slices.Sorted(slices.Values(want)), slices.Sorted(slices.Values(s.Shards()))) {
+			return
+		}
+		shards := make([]Shard, 0, len(want))
+		for _, name := range want {
+			c, ok := current[name]
+			if !ok {
+				var err error
+				if c, err = open(name); err != nil {
+					slog.Error("Cache shard not opened, keeping the current shards",
+						slog.String("shard", name), slog.String("error", err.Error()))
+					return
+				}
+			}
+			shards = append(shards, Shard{Name: name, Cache: c})
+		}
+		if err := s.SetShards(shards); err != nil {
+			slog.Error("Cache shards not updated", slog.String("error", err.Error()))
+			return
+		}
+		for name, c := range current {
+			if !slices.Contains(want, name) {
+				if closer, ok := c.(io.Closer); ok {
+					_ = closer.Close()
+				}
+			}
+		}
+		slog.Info("Cache shards updated", slog.Any("shards", want))
+	}
+
+	update(store.Current())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			update(ev.New)
+		}
+	}
+}
+
+// hashRing maps keys to shards.
+type hashRing struct {
+	shards []*shardState
+	points []ringPoint
+}
+
+type ringPoint struct {
+	hash  uint64
+	shard int
+}
+
+// lookup returns the shard of the first ring point at or after the hash
+// of key.
+func (r *hashRing) lookup(key string) *shardState {
+	h := xxhash.Sum64String(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.shards[r.points[i].shard]
+}
+
+// shardState is a shard with its health.
+type shardState struct {
+	name      string
+	cache     Cache
+	failures  atomic.Int64
+	downUntil atomic.Int64 // unix nanoseconds
+}
+
+// available reports whether st may be used: it is up, or down long enough
+// to be probed again.
+func (st *shardState) available(now time.Time) bool {
+	return now.UnixNano() >= st.downUntil.Load()
+}
@@ -0,0 +1,284 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/redis/go-redis/v9"
+)
+
+// StreamMessage is a message read from a Redis stream.
+type StreamMessage struct {
+	ID     string
+	Stream string
+	Values map[string]any
+	// Deliveries is the number of times the message has been delivered,
+	// including the current delivery.
+	Deliveries int64
+}
+
+// StreamHandler processes a stream message. Returning nil acknowledges it.
+type StreamHandler func(ctx context.Context, msg StreamMessage) error
+
+// StreamConsumerOptions configures a StreamConsumer.
+type StreamConsumerOptions struct {
+	// BatchSize is the number of messages read per call; default 10.
+	BatchSize int64
+	// Block is how long XREADGROUP blocks waiting for messages; default 2s.
+	Block time.Duration
+	// VisibilityTimeout is how long a pending message stays with a consumer
+	// before it is reclaimed for retry; default 30s.
+	VisibilityTimeout time.Duration
+	// MaxDeliveries is the number of deliveries before a message is moved to
+	// the dead-letter stream; default 5.
+	MaxDeliveries int64
+	// DeadLetterStream receives poison messages; default "<stream>:dead".
+	DeadLetterStream string
+	// StartID is where a newly created group starts reading; default "$"
+	// (only new messages). Use "0" to consume the existing backlog.
+	StartID string
+}
+
+// StreamConsumer consumes a Redis stream as part of a consumer group with
+// at-least-once semantics.
+type StreamConsumer struct {
+	client   *redis.Client
+	stream   string
+	group    string
+	consumer string
+	opts     StreamConsumerOptions
+}
+
+// NewStreamConsumer creates a consumer for stream in group, identified by consumerName.
+func NewStreamConsumer(client *redis.Client, stream, group, consumerName string, opts StreamConsumerOptions) *StreamConsumer {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 10
+	}
+	if opts.Block <= 0 {
+		opts.Block = 2 * time.Second
+	}
+	if opts.VisibilityTimeout <= 0 {
+		opts.VisibilityTimeout = 30 * time.Second
+	}
+	if opts.MaxDeliveries <= 0 {
+		opts.MaxDeliveries = 5
+	}
+	if opts.DeadLetterStream == "" {
+		opts.DeadLetterStream = stream + ":dead"
+	}
+	if opts.StartID == "" {
+		opts.StartID = "$"
+	}
+	return &StreamConsumer{
+		client:   client,
+		stream:   stream,
+		group:    group,
+		consumer: consumerName,
+		opts:     opts,
+	}
+}
+
+// Run consumes messages until ctx is canceled. It creates the group if
+// missing, ACKs messages whose handler succeeds, reclaims messages pending
+// longer than the visibility timeout for retry, and moves messages exceeding
+// MaxDeliveries to the dead-letter stream.
+//
+// On cancellation, the handler in flight runs to completion (its context is
+// not canceled) before Run returns.
+func (s *StreamConsumer) Run(ctx context.Context, handler StreamHandler) error {
+	if err := s.ensureGroup(ctx); err != nil {
+		return err
+	}
+
+	handlerCtx := context.WithoutCancel(ctx)
+	for ctx.Err() == nil {
+		if err := s.reclaim(ctx, handlerCtx, handler); err != nil && ctx.Err() == nil {
+			slog.Warn("Stream reclaim failed",
+				slog.String("stream", s.stream),
+				slog.String("error", err.Error()),
+			)
+		}
+
+		streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    s.group,
+			Consumer: s.consumer,
+			Streams:  []string{s.stream, ">"},
+			Count:    s.opts.BatchSize,
+			Block:    s.opts.Block,
+		}).Result()
+		if err != nil {
+			if err == redis.Nil || ctx.Err() != nil {
+				continue
+			}
+			return code.WrapRedisError(err, "xreadgroup")
+		}
+
+		for _, st := range streams {
+			for _, m := range st.Messages {
+				s.handle(handlerCtx, handler, m, 1)
+				if ctx.Err() != nil {
+					return nil
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Lag returns the group's backlog: entries not yet delivered plus entries
+// delivered but not acknowledged. Undelivered entries are only counted on
+// Redis 7+.
+func (s *StreamConsumer) Lag(ctx context.Context) (int64, error) {
+	groups, err := s.client.XInfoGroups(ctx, s.stream).Result()
+	if err != nil {
+		return 0, code.WrapRedisError(err, "xinfo groups")
+	}
+	for _, g := range groups {
+		if g.Name == s.group {
+			return max(g.Lag, 0) + g.Pending, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *StreamConsumer) ensureGroup(ctx context.Context) error {
+	err := s.client.XGroupCreateMkStream(ctx, s.stream, s.group, s.opts.StartID).Err()
+	if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+		return code.WrapRedisError(err, "xgroup create")
+	}
+	return nil
+}
+
+// reclaim claims messages idle longer than the visibility timeout and retries
+// or dead-letters them.
+func (s *StreamConsumer) reclaim(ctx, handlerCtx context.Context, handler StreamHandler) error {
+	msgs, _, err := s.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   s.stream,
+		Group:    s.group,
+		Consumer: s.consumer,
+		MinIdle:  s.opts.VisibilityTimeout,
+		Start:    "0-0",
+		Count:    s.opts.BatchSize,
+	}).Result()
+	if err != nil || len(msgs) == 0 {
+		return err
+	}
+
+	pending, err := s.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   s.stream,
+		Group:    s.group,
+		Start:    msgs[0].ID,
+		End:      msgs[len(msgs)-1].ID,
+		Count:    int64(len(msgs)),
+		Consumer: s.consumer,
+	}).Result()
+	if err != nil {
+		return err
+	}
+	deliveries := make(map[string]int64, len(pending))
+	for _, p := range pending {
+		deliveries[p.ID] = p.RetryCount
+	}
+
+	for _, m := range msgs {
+		n := deliveries[m.ID]
+		if n > s.opts.MaxDeliveries {
+			s.deadLetter(handlerCtx, m, n)
+			continue
+		}
+		s.handle(handlerCtx, handler, m, n)
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *StreamConsumer) handle(ctx context.Context, handler StreamHandler, m redis.XMessage, deliveries int64) {
+	err := handler(ctx, StreamMessage{
+		ID:         m.ID,
+		Stream:     s.stream,
+		Values:     m.Values,
+		Deliveries: deliveries,
+	})
+	if err != nil {
+		// Leave the message pending; it is reclaimed after the visibility timeout.
+		slog.Warn("Stream handler failed",
+			slog.String("stream", s.stream),
+			slog.String("id", m.ID),
+			slog.Int64("deliveries", deliveries),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	if err := s.client.XAck(ctx, s.stream, s.group, m.ID).Err(); err != nil {
+		slog.Warn("Stream ack failed",
+			slog.String("stream", s.stream),
+			slog.String("id", m.ID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// deadLetter copies a poison message to the dead-letter stream and ACKs it.
+func (s *StreamConsumer) deadLetter(ctx context.Context, m redis.XMessage, deliveries int64) {
+	values := make(map[string]any, len(m.Values)+3)
+	for k, v := range m.Values {
+		values[k] = v
+	}
+	values["_origin_stream"] = s.stream
+	values["_origin_id"] = m.ID
+	values["_deliveries"] = deliveries
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{Stream: s.opts.DeadLetterStream, Values: values})
+		pipe.XAck(ctx, s.stream, s.group, m.ID)
+		return nil
+	})
+	if err != nil {
+		slog.Error("Stream dead-letter failed",
+			slog.String("stream", s.stream),
+			slog.String("id", m.ID),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	slog.Warn("Stream message dead-lettered",
+		slog.String("stream", s.stream),
+		slog.String("dead_letter_stream", s.opts.DeadLetterStream),
+		slog.String("id", m.ID),
+		slog.Int64("deliveries", deliveries),
+	)
+}
+
+// StreamProducer appends messages to Redis streams.
+type StreamProducer struct {
+	client *redis.Client
+	// MaxLen approximately trims streams to this length; 0 disables trimming.
+	MaxLen int64
+}
+
+// NewStreamProducer creates a stream producer trimming streams to about maxLen entries.
+func NewStreamProducer(client *redis.Client, maxLen int64) *StreamProducer {
+	return &StreamProducer{client: client, MaxLen: maxLen}
+}
+
+// Add appends values to stream and returns the message ID.
+func (p *StreamProducer) Add(ctx context.Context, stream string, values map[string]any) (string, error) {
+	args := &redis.XAddArgs{
+		Stream: stream,
+		Values: values,
+	}
+	if p.MaxLen > 0 {
+		args.MaxLen = p.MaxLen
+		args.Approx = true
+	}
+	id, err := p.client.XAdd(ctx, args).Result()
+	if err != nil {
+		return "", code.WrapRedisError(err, "xadd")
+	}
+	return id, nil
+}
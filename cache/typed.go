@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Typed is a Cache holding values of type T.
+type Typed[T any] struct {
+	cache Cache
+	group singleflight.Group
+}
+
+// NewTyped creates a Typed cache on top of c.
+func NewTyped[T any](c Cache) *Typed[T] {
+	return &Typed[T]{cache: c}
+}
+
+// Get returns the value at key and whether it was found. Misses are not
+// errors; a value that is not a T is a DecodeError.
+func (t *Typed[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var v T
+	found, err := GetResult(ctx, t.cache, key, &v)
+	if !found {
+		var zero T
+		return zero, false, err
+	}
+	return v, true, nil
+}
+
+// GetWithTTL is Get returning the remaining time to live of the value too,
+// 0 for values without expiration. It returns ErrTTLUnsupported when the
+// cache is not a TTLGetter.
+func (t *Typed[T]) GetWithTTL(ctx context.Context, key string) (T, time.Duration, bool, error) {
+	var v T
+	found, ttl, err := GetWithTTL(ctx, t.cache, key, &v)
+	if !found {
+		var zero T
+		return zero, 0, false, err
+	}
+	return v, ttl, true, nil
+}
+
+// Set stores v at key.
+func (t *Typed[T]) Set(ctx context.Context, key string, v T, expiration time.Duration) error {
+	return t.cache.Set(ctx, key, v, expiration)
+}
+
+// MGet returns the values of the found keys. It reads them in one batch
+// when the cache is a MultiGetter and key by key otherwise.
+func (t *Typed[T]) MGet(ctx context.Context, keys []string) (map[string]T, error) {
+	out := make(map[string]T, len(keys))
+	if mg, ok := t.cache.(MultiGetter); ok {
+		ptrs := make(map[string]*T, len(keys))
+		_, err := mg.MGet(ctx, keys, func(key string) any {
+			v := new(T)
+			ptrs[key] = v
+			return v
+		})
+		for k, v := range ptrs {
+			out[k] = *v
+		}
+		return out, err
+	}
+	for _, key := range keys {
+		v, ok, err := t.Get(ctx, key)
+		if err != nil {
+			return out, err
+		}
+		if ok {
+			out[key] = v
+		}
+	}
+	return out, nil
+}
+
+// Delete removes key.
+func (t *Typed[T]) Delete(ctx context.Context, key string) error {
+	return t.cache.Delete(ctx, key)
+}
+
+// GetOrSet returns the value at key, or loads, stores and returns it on a
+// miss. Concurrent misses for the same key share one load. Cache errors
+// are treated as misses so the cache never fails a read, and a value that
+// cannot be decoded is replaced by the loaded one; load errors are
+// returned and not cached.
+func (t *Typed[T]) GetOrSet(ctx context.Context, key string, expiration time.Duration, load func(ctx context.Context) (T, error)) (T, error) {
+	if v, ok, err := t.Get(ctx, key); err == nil && ok {
+		return v, nil
+	}
+	v, err, _ := t.group.Do(key, func() (any, error) {
+		v, err := load(ctx)
+		if err != nil {
+			return v, err
+		}
+		_ = t.cache.Set(ctx, key, v, expiration)
+		return v, nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return v.(T), nil
+}
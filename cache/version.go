@@ -0,0 +1,314 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Defaults of UpdateOptions.
+const (
+	DefaultUpdateAttempts = 10
+	DefaultUpdateBackoff  = 5 * time.Millisecond
+)
+
+// maxUpdateBackoff bounds the pause between attempts of Typed.UpdateFn.
+const maxUpdateBackoff = time.Second
+
+// ErrVersionUnsupported is returned by GetWithVersion and CompareAndSet
+// for caches that do not version their values.
+var ErrVersionUnsupported = errors.New("cache: versions not supported")
+
+// ErrVersionConflict is returned by Typed.UpdateFn when the value kept
+// changing under it for all its attempts.
+var ErrVersionConflict = errors.New("cache: version conflict")
+
+// Versioner is implemented by caches storing a version with each value,
+// for optimistic concurrency on values updated from several places
+// (RedisCache, ShardedCache, InstrumentedCache and prefixed caches of
+// those). Versions are opaque strings that increase with each
+// CompareAndSet of a key.
+//
+// Versioned values are stored in an envelope that Get and the other reads
+// of RedisCache unwrap. A value stored with Set has version "0".
+type Versioner interface {
+	// GetWithVersion decodes the value at key into dest and returns its
+	// version. It returns ErrNotFound for missing keys.
+	GetWithVersion(ctx context.Context, key string, dest any) (string, error)
+	// CompareAndSet stores value at key, with a new version, if the
+	// version of the key is still expectedVersion, "" meaning that the key
+	// must not exist, and reports whether it did. ttl 0 keeps the value
+	// forever.
+	CompareAndSet(ctx context.Context, key string, value any, expectedVersion string, ttl time.Duration) (bool, error)
+}
+
+// GetWithVersion reads key from c like Versioner, or returns
+// ErrVersionUnsupported when c is not one.
+func GetWithVersion(ctx context.Context, c Cache, key string, dest any) (string, error) {
+	if v, ok := c.(Versioner); ok {
+		return v.GetWithVersion(ctx, key, dest)
+	}
+	return "", ErrVersionUnsupported
+}
+
+// CompareAndSet writes key to c like Versioner, or returns
+// ErrVersionUnsupported when c is not one.
+func CompareAndSet(ctx context.Context, c Cache, key string, value any, expectedVersion string, ttl time.Duration) (bool, error) {
+	if v, ok := c.(Versioner); ok {
+		return v.CompareAndSet(ctx, key, value, expectedVersion, ttl)
+	}
+	return false, ErrVersionUnsupported
+}
+
+// versionMark starts a versioned value, followed by the version, ':' and
+// the encoded value. No codec output starts with a NUL byte.
+const versionMark = 0
+
+// casScript stores ARGV[2] with the next version when the version of the
+// key is ARGV[1], and returns the new version, or -1 on a mismatch.
+var casScript = redis.NewScript(`
+local cur = redis.call("get", KEYS[1])
+local ver = 0
+if cur then
+	if string.byte(cur, 1) == 0 then
+		local sep = string.find(cur, ":", 2, true)
+		ver = tonumber(string.sub(cur, 2, sep - 1))
+	end
+	if ARGV[1] ~= tostring(ver) then
+		return -1
+	end
+elseif ARGV[1] ~= "" then
+	return -1
+end
+ver = ver + 1
+local v = "\0" .. tostring(ver) .. ":" .. ARGV[2]
+if tonumber(ARGV[3]) > 0 then
+	redis.call("set", KEYS[1], v, "px", ARGV[3])
+else
+	redis.call("set", KEYS[1], v)
+end
+return ver
+`)
+
+// GetWithVersion implements Versioner.
+func (c *RedisCache) GetWithVersion(ctx context.Context, key string, dest any) (string, error) {
+	data, err := c.client.Get(ctx, c.key(key)).Bytes()
+	if err != nil {
+		return "", err
+	}
+	version, _ := unpackVersion(data)
+	return version, c.decode(key, data, dest)
+}
+
+// CompareAndSet implements Versioner with a script, so the comparison and
+// the write are atomic. Deleting a key restarts its versions: a version
+// read before the delete matches again once the key was recreated as many
+// times.
+func (c *RedisCache) CompareAndSet(ctx context.Context, key string, value any, expectedVersion string, ttl time.Duration) (bool, error) {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	v, err := casScript.Run(ctx, c.client, []string{c.key(key)}, expectedVersion, data, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return v > 0, nil
+}
+
+// unpackVersion splits a stored value into its version and encoded value.
+func unpackVersion(data []byte) (string, []byte) {
+	if len(data) == 0 || data[0] != versionMark {
+		return "0", data
+	}
+	sep := bytes.IndexByte(data, ':')
+	if sep < 0 {
+		return "0", data
+	}
+	return string(data[1:sep]), data[sep+1:]
+}
+
+// GetWithVersion implements Versioner when the shard of key does. A down
+// shard answers as Get does.
+func (s *ShardedCache) GetWithVersion(ctx context.Context, key string, dest any) (string, error) {
+	st := s.ring.Load().lookup(key)
+	if !st.available(time.Now()) {
+		return "", s.unavailable(ErrNotFound)
+	}
+	version, err := GetWithVersion(ctx, st.cache, key, dest)
+	if !errors.Is(err, ErrVersionUnsupported) {
+		s.record(st, err)
+	}
+	return version, err
+}
+
+// CompareAndSet implements Versioner when the shard of key does. It fails
+// with ErrShardUnavailable on a down shard, even with FailOpen: a write
+// that did not happen must not look like a lost race.
+func (s *ShardedCache) CompareAndSet(ctx context.Context, key string, value any, expectedVersion string, ttl time.Duration) (bool, error) {
+	st := s.ring.Load().lookup(key)
+	if !st.available(time.Now()) {
+		return false, ErrShardUnavailable
+	}
+	ok, err := CompareAndSet(ctx, st.cache, key, value, expectedVersion, ttl)
+	if !errors.Is(err, ErrVersionUnsupported) {
+		s.record(st, err)
+	}
+	return ok, err
+}
+
+// GetWithVersion implements Versioner when the underlying cache does.
+func (p prefixCache) GetWithVersion(ctx context.Context, key string, dest any) (string, error) {
+	return GetWithVersion(ctx, p.cache, p.prefix+key, dest)
+}
+
+// CompareAndSet implements Versioner when the underlying cache does.
+func (p prefixCache) CompareAndSet(ctx context.Context, key string, value any, expectedVersion string, ttl time.Duration) (bool, error) {
+	return CompareAndSet(ctx, p.cache, p.prefix+key, value, expectedVersion, ttl)
+}
+
+// GetWithVersion implements Versioner when the underlying cache does.
+func (c *InstrumentedCache) GetWithVersion(ctx context.Context, key string, dest any) (string, error) {
+	start := time.Now()
+	version, err := GetWithVersion(ctx, c.cache, key, dest)
+	if errors.Is(err, ErrVersionUnsupported) {
+		return version, err
+	}
+	c.observeRead(err == nil, errors.Is(err, ErrNotFound), err, start)
+	return version, err
+}
+
+// CompareAndSet implements Versioner when the underlying cache does. A
+// lost comparison is observed as a "conflict" of op "cas".
+func (c *InstrumentedCache) CompareAndSet(ctx context.Context, key string, value any, expectedVersion string, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	ok, err := CompareAndSet(ctx, c.cache, key, value, expectedVersion, ttl)
+	switch {
+	case errors.Is(err, ErrVersionUnsupported):
+	case err == nil && !ok:
+		c.observe("cas", "conflict", start)
+	default:
+		if ok {
+			c.sets.Add(1)
+		}
+		c.observeWrite("cas", err, start)
+	}
+	return ok, err
+}
+
+// UpdateOptions configure Typed.UpdateFn.
+type UpdateOptions struct {
+	// MaxAttempts bounds the read-modify-write attempts; default
+	// DefaultUpdateAttempts.
+	MaxAttempts int
+	// Backoff is the base of the jittered pause between attempts, doubled
+	// after each; default DefaultUpdateBackoff.
+	Backoff time.Duration
+	// Rand draws the jitter; default math/rand/v2. utils.Rand implements
+	// it, e.g. a seeded one for reproducible tests.
+	Rand interface{ Int64N(n int64) int64 }
+	// Sleep pauses between attempts, returning ctx.Err() when ctx is done
+	// first; default a real timer. Tests pass utils.Sleep on a FakeClock.
+	Sleep func(ctx context.Context, d time.Duration) error
+}
+
+// UpdateFn applies fn to the value at key and stores its result with
+// CompareAndSet, retrying from a fresh read when another writer got there
+// first, so that no update is lost. fn gets the zero T for a missing key
+// and may be called several times, so it must not have side effects; its
+// error aborts the update and is returned. After MaxAttempts lost races
+// UpdateFn returns ErrVersionConflict; the cache must be a Versioner.
+//
+//	cart, err := carts.UpdateFn(ctx, "cart:"+userID, time.Hour, func(c Cart) (Cart, error) {
+//	    c.Items = append(c.Items, item)
+//	    return c, nil
+//	})
+func (t *Typed[T]) UpdateFn(ctx context.Context, key string, ttl time.Duration, fn func(current T) (T, error), opts ...UpdateOptions) (T, error) {
+	var o UpdateOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = DefaultUpdateAttempts
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = DefaultUpdateBackoff
+	}
+	jitter := func(n time.Duration) time.Duration { return rand.N(n) }
+	if o.Rand != nil {
+		jitter = func(n time.Duration) time.Duration { return time.Duration(o.Rand.Int64N(int64(n))) }
+	}
+	if o.Sleep == nil {
+		o.Sleep = sleep
+	}
+
+	var zero T
+	backoff := o.Backoff
+	for attempt := 1; ; attempt++ {
+		var current T
+		version, err := GetWithVersion(ctx, t.cache, key, &current)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			version = ""
+		case err != nil:
+			return zero, err
+		}
+		next, err := fn(current)
+		if err != nil {
+			return zero, err
+		}
+		ok, err := CompareAndSet(ctx, t.cache, key, next, version, ttl)
+		if err != nil {
+			return zero, err
+		}
+		if ok {
+			return next, nil
+		}
+		if attempt == o.MaxAttempts {
+			return zero, ErrVersionConflict
+		}
+
+		if err := o.Sleep(ctx, backoff/2+jitter(backoff/2+1)); err != nil {
+			return zero, err
+		}
+		backoff = min(2*backoff, maxUpdateBackoff)
+	}
+}
+
+// GetWithVersion returns the value at key, its version and whether it was
+// found (see Versioner).
+func (t *Typed[T]) GetWithVersion(ctx context.Context, key string) (T, string, bool, error) {
+	var v T
+	version, err := GetWithVersion(ctx, t.cache, key, &v)
+	if errors.Is(err, ErrNotFound) {
+		var zero T
+		return zero, "", false, nil
+	}
+	if err != nil {
+		var zero T
+		return zero, "", false, err
+	}
+	return v, version, true, nil
+}
+
+// CompareAndSet stores v at key if its version is still expectedVersion
+// (see Versioner).
+func (t *Typed[T]) CompareAndSet(ctx context.Context, key string, v T, expectedVersion string, ttl time.Duration) (bool, error) {
+	return CompareAndSet(ctx, t.cache, key, v, expectedVersion, ttl)
+}
+
+// sleep waits d or until ctx is done, and returns ctx.Err() in that case.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,326 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+)
+
+// Defaults of WarmerOptions.
+const (
+	DefaultWarmupParallelism = 4
+	DefaultWarmupTimeout     = 30 * time.Second
+)
+
+// Warmup statuses of WarmResult.Status.
+const (
+	WarmStatusOK      = "ok"
+	WarmStatusError   = "error"
+	WarmStatusTimeout = "timeout"
+)
+
+// WarmFunc loads data into c. Successful Set calls on c are counted as
+// loaded keys.
+type WarmFunc func(ctx context.Context, c Cache) error
+
+// WarmOptions configures a registered warmer.
+type WarmOptions struct {
+	// Timeout bounds the warmer; default WarmerOptions.Timeout.
+	Timeout time.Duration
+	// Critical warmers fail Run when they fail, so that a startup hook
+	// running it keeps the service from becoming ready. Failures of other
+	// warmers are only logged.
+	Critical bool
+	// Count returns the number of keys loaded, for warmers filling more
+	// than the Cache they are given (local maps, policies). By default the
+	// Set calls on that Cache are counted.
+	Count func() int64
+}
+
+// WarmupObserver receives the outcome of every warmer run; status is one
+// of the WarmStatus constants. metrics.WarmupMetrics implements it.
+type WarmupObserver interface {
+	ObserveWarmup(name, status string, duration time.Duration, keys int64)
+}
+
+// WarmerOptions configures a Warmer.
+type WarmerOptions struct {
+	// Parallelism is the number of warmers running at once; default
+	// DefaultWarmupParallelism.
+	Parallelism int
+	// Timeout is the default timeout of a warmer; default
+	// DefaultWarmupTimeout.
+	Timeout time.Duration
+	// Observer records the runs (optional).
+	Observer WarmupObserver
+}
+
+// WarmResult is the outcome of one warmer.
+type WarmResult struct {
+	Name     string        `json:"name"`
+	Critical bool          `json:"critical"`
+	Status   string        `json:"status"`
+	Duration time.Duration `json:"duration"`
+	Keys     int64         `json:"keys"`
+	Error    string        `json:"error,omitempty"`
+
+	err error
+}
+
+// WarmReport is the outcome of a warmup, with results in registration
+// order.
+type WarmReport struct {
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	Results  []WarmResult  `json:"results"`
+}
+
+// Warmer primes caches before the service takes traffic, and again on
+// demand. Warmers run concurrently, so they must not depend on each other.
+//
+//	w := cache.NewWarmer(redisCache, cache.WarmerOptions{Observer: metrics.NewWarmupMetrics("app")})
+//	_ = w.Register("products", loadProducts, cache.WarmOptions{Critical: true})
+//	_ = w.Register("banners", loadBanners, cache.WarmOptions{Timeout: 5 * time.Second})
+//
+//	// Before readiness; the hook timeout bounds the whole warmup.
+//	runner.RegisterStartup("cache.warmup", server.PhaseWarmup, w.Run, server.HookTimeout(2*time.Minute))
+//	// Hourly, and on demand with adminserver.WithCacheWarmer.
+//	_ = sched.Register("cache.warmup", "@hourly", w.Run, scheduler.JobOptions{})
+type Warmer struct {
+	cache Cache
+	opts  WarmerOptions
+
+	mu      sync.Mutex
+	warmers []*warmer
+	last    *WarmReport
+
+	running atomic.Bool
+}
+
+type warmer struct {
+	name string
+	fn   WarmFunc
+	opts WarmOptions
+}
+
+// NewWarmer creates a Warmer of c.
+func NewWarmer(c Cache, opts ...WarmerOptions) *Warmer {
+	var o WarmerOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Parallelism <= 0 {
+		o.Parallelism = DefaultWarmupParallelism
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultWarmupTimeout
+	}
+	return &Warmer{cache: c, opts: o}
+}
+
+// Register adds a warmer. Names must be unique.
+func (w *Warmer) Register(name string, fn WarmFunc, opts WarmOptions) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if slices.ContainsFunc(w.warmers, func(r *warmer) bool { return r.name == name }) {
+		return code.NewErrorf(code.ErrBadRequest, "cache warmer %s already registered", name)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = w.opts.Timeout
+	}
+	w.warmers = append(w.warmers, &warmer{name: name, fn: fn, opts: opts})
+	return nil
+}
+
+// Run runs all warmers and fails when a critical one failed. It fits
+// server.Runner.RegisterStartup and scheduler.Scheduler.Register.
+func (w *Warmer) Run(ctx context.Context) error {
+	report, err := w.Warm(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, r := range report.Results {
+		if r.Critical && r.err != nil {
+			errs = append(errs, errors.Wrapf(r.err, "cache warmer %s", r.Name))
+		}
+	}
+	if len(errs) > 0 {
+		return code.WrapError(errors.Join(errs...), code.ErrServiceUnavailable, "critical cache warmers failed")
+	}
+	return nil
+}
+
+// Warm runs the named warmers, or all of them, and reports their outcome.
+// It fails without running any when a name is unknown
+// (code.ErrNotFound) or a warmup is already running
+// (code.ErrAlreadyExists).
+func (w *Warmer) Warm(ctx context.Context, names ...string) (WarmReport, error) {
+	w.mu.Lock()
+	warmers := slices.Clone(w.warmers)
+	w.mu.Unlock()
+	if len(names) > 0 {
+		selected := make([]*warmer, 0, len(names))
+		for _, name := range names {
+			i := slices.IndexFunc(warmers, func(r *warmer) bool { return r.name == name })
+			if i < 0 {
+				return WarmReport{}, code.NewErrorf(code.ErrNotFound, "cache warmer %s not registered", name)
+			}
+			selected = append(selected, warmers[i])
+		}
+		warmers = selected
+	}
+	if !w.running.CompareAndSwap(false, true) {
+		return WarmReport{}, code.NewError(code.ErrAlreadyExists, "cache warmup already running")
+	}
+	defer w.running.Store(false)
+
+	report := WarmReport{Started: time.Now(), Results: make([]WarmResult, len(warmers))}
+	slog.InfoContext(ctx, "Cache warmup started",
+		slog.Int("warmers", len(warmers)),
+		slog.Int("parallelism", w.opts.Parallelism),
+	)
+
+	var (
+		wg   sync.WaitGroup
+		done atomic.Int64
+		sem  = make(chan struct{}, w.opts.Parallelism)
+	)
+	for i, r := range warmers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				report.Results[i] = w.run(ctx, r)
+			case <-ctx.Done():
+				report.Results[i] = result(r, ctx.Err(), 0, 0)
+			}
+			w.log(ctx, report.Results[i], done.Add(1), len(warmers))
+		}()
+	}
+	wg.Wait()
+
+	report.Duration = time.Since(report.Started)
+	var keys int64
+	failed := 0
+	for _, r := range report.Results {
+		keys += r.Keys
+		if r.err != nil {
+			failed++
+		}
+	}
+	level := slog.LevelInfo
+	if failed > 0 {
+		level = slog.LevelWarn
+	}
+	slog.Log(ctx, level, "Cache warmup completed",
+		slog.Duration("duration", report.Duration),
+		slog.Int64("keys", keys),
+		slog.Int("failed", failed),
+	)
+
+	w.mu.Lock()
+	w.last = &report
+	w.mu.Unlock()
+	return report, nil
+}
+
+// LastReport returns the report of the latest warmup, and false before
+// the first one.
+func (w *Warmer) LastReport() (WarmReport, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.last == nil {
+		return WarmReport{}, false
+	}
+	return *w.last, true
+}
+
+// run runs one warmer with its timeout. A warmer ignoring its context is
+// abandoned when the timeout elapses; a panic fails it.
+func (w *Warmer) run(ctx context.Context, r *warmer) WarmResult {
+	ctx, cancel := context.WithTimeout(ctx, r.opts.Timeout)
+	defer cancel()
+	c := &countingCache{Cache: w.cache}
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				errc <- errors.FromPanic(p)
+			}
+		}()
+		errc <- r.fn(ctx, c)
+	}()
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	keys := c.sets.Load()
+	if r.opts.Count != nil {
+		keys = r.opts.Count()
+	}
+	res := result(r, err, time.Since(start), keys)
+	if w.opts.Observer != nil {
+		w.opts.Observer.ObserveWarmup(r.name, res.Status, res.Duration, res.Keys)
+	}
+	return res
+}
+
+func result(r *warmer, err error, d time.Duration, keys int64) WarmResult {
+	res := WarmResult{Name: r.name, Critical: r.opts.Critical, Status: WarmStatusOK, Duration: d, Keys: keys, err: err}
+	if err != nil {
+		res.Status = WarmStatusError
+		if errors.Is(err, context.DeadlineExceeded) {
+			res.Status = WarmStatusTimeout
+		}
+		res.Error = err.Error()
+	}
+	return res
+}
+
+func (w *Warmer) log(ctx context.Context, r WarmResult, done int64, total int) {
+	attrs := []any{
+		slog.String("warmer", r.Name),
+		slog.String("status", r.Status),
+		slog.Duration("duration", r.Duration),
+		slog.Int64("keys", r.Keys),
+		slog.Bool("critical", r.Critical),
+		slog.String("progress", strconv.FormatInt(done, 10)+"/"+strconv.Itoa(total)),
+	}
+	switch {
+	case r.err == nil:
+		slog.InfoContext(ctx, "Cache warmer completed", attrs...)
+	case r.Critical:
+		slog.ErrorContext(ctx, "Cache warmer failed", append(attrs, slog.String("error", r.Error))...)
+	default:
+		slog.WarnContext(ctx, "Cache warmer failed", append(attrs, slog.String("error", r.Error))...)
+	}
+}
+
+// countingCache counts the keys a warmer sets.
+type countingCache struct {
+	Cache
+	sets atomic.Int64
+}
+
+func (c *countingCache) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	err := c.Cache.Set(ctx, key, value, expiration)
+	if err == nil {
+		c.sets.Add(1)
+	}
+	return err
+}
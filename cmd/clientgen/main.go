@@ -0,0 +1,81 @@
+// Command clientgen generates a typed Go client from the OpenAPI document
+// of a go-kit service (see apidoc.Registry.Handler):
+//
+//	//go:generate go run github.com/NSObjects/go-kit/cmd/clientgen -url http://localhost:8080/openapi.json -package orders -out client.go
+//	//go:generate go run github.com/NSObjects/go-kit/cmd/clientgen -file openapi.json -package orders -out client.go
+//
+// Services can also generate from the in-process registry with
+// apidoc.Registry.GenerateClient.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/NSObjects/go-kit/apidoc"
+)
+
+func main() {
+	url := flag.String("url", "", "URL of the OpenAPI document, e.g. http://localhost:8080/openapi.json")
+	file := flag.String("file", "", "path of the OpenAPI document")
+	pkg := flag.String("package", "client", "package name of the generated file")
+	service := flag.String("service", "", "service name used in errors (default: the document title)")
+	out := flag.String("out", "", "output file (default: stdout)")
+	flag.Parse()
+
+	if err := run(*url, *file, *out, apidoc.ClientOptions{Package: *pkg, Service: *service}); err != nil {
+		fmt.Fprintln(os.Stderr, "clientgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(url, file, out string, opts apidoc.ClientOptions) error {
+	var doc []byte
+	var err error
+	switch {
+	case url != "" && file != "":
+		return fmt.Errorf("-url and -file are exclusive")
+	case url != "":
+		doc, err = fetch(url)
+	case file != "":
+		doc, err = os.ReadFile(file)
+	default:
+		return fmt.Errorf("one of -url or -file is required")
+	}
+	if err != nil {
+		return err
+	}
+
+	src, err := apidoc.GenerateClient(doc, opts)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
+
+func fetch(url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: %s", url, res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
@@ -0,0 +1,17 @@
+package code_test
+
+import (
+	"testing"
+
+	_ "github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/kitlint"
+)
+
+func TestErrorCodes(t *testing.T) {
+	consts, err := kitlint.ScanCodes(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errors.RunAuditTest(t, errors.AuditOptions{Constants: consts})
+}
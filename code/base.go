@@ -12,7 +12,7 @@ const (
 	// ErrSuccess - 200: OK.
 	ErrSuccess int = iota + 100001
 
-	// ErrUnknown - 500: Internal server error.
+	// ErrUnknown - 500: Unknown error.
 	ErrUnknown
 
 	// ErrBind - 400: Error occurred while binding the request body to the struct.
@@ -23,6 +23,15 @@ const (
 
 	// ErrTokenInvalid - 401: Token invalid.
 	ErrTokenInvalid
+
+	// ErrStartup - 500: Application startup failed.
+	ErrStartup
+
+	// ErrConflict - 409: Resource was modified concurrently.
+	ErrConflict
+
+	// ErrAPIVersionUnsupported - 404: API version not supported.
+	ErrAPIVersionUnsupported
 )
 
 // Database/Infrastructure errors (100101-100199)
@@ -38,6 +47,12 @@ const (
 
 	// ErrExternalService - 500: External service error.
 	ErrExternalService
+
+	// ErrCircuitOpen - 503: Service temporarily unavailable (circuit breaker open).
+	ErrCircuitOpen
+
+	// ErrSecret - 500: Secret could not be resolved.
+	ErrSecret
 )
 
 // HTTP status code related errors (explicit values for clarity)
@@ -50,8 +65,26 @@ const (
 	ErrForbidden int = 100403
 	// ErrNotFound - 404: Not found.
 	ErrNotFound int = 100404
+	// ErrMethodNotAllowed - 405: Method not allowed on the route.
+	ErrMethodNotAllowed int = 100405
+	// ErrAlreadyExists - 409: Resource already exists.
+	ErrAlreadyExists int = 100409
+	// ErrPayloadTooLarge - 413: Request payload too large.
+	ErrPayloadTooLarge int = 100413
+	// ErrUnsupportedMediaType - 415: Unsupported media type.
+	ErrUnsupportedMediaType int = 100415
+	// ErrUpgradeRequired - 426: Client version too old, upgrade required.
+	ErrUpgradeRequired int = 100426
+	// ErrTooManyRequests - 429: Too many requests (rate limit or quota exceeded).
+	ErrTooManyRequests int = 100429
+	// ErrClientClosedRequest - 499: Client closed the request (non-standard, as nginx).
+	ErrClientClosedRequest int = 100499
 	// ErrInternalServer - 500: Internal server error.
 	ErrInternalServer int = 100500
+	// ErrServiceUnavailable - 503: Service unavailable (overloaded).
+	ErrServiceUnavailable int = 100503
+	// ErrTimeout - 504: Operation timed out.
+	ErrTimeout int = 100504
 )
 
 // Authentication/Authorization errors (100201-100299)
@@ -114,46 +147,79 @@ const (
 	ErrDecodingYaml
 )
 
+// KitNamespace is the error namespace of the codes of this package, which
+// reserves 100001 to 100999 (see errors.Namespace).
+const KitNamespace = "kit"
+
 func init() {
+	kit := errors.Namespace(KitNamespace, 100000)
+
+	errors.PanicCode = ErrInternalServer
+	errors.TimeoutCode = ErrTimeout
+	errors.CanceledCode = ErrClientClosedRequest
+	errors.RetryableCode = isRetryableCode
+
+	// Reserve the statuses of the ranges (see errors.Audit)
+	kit.ReserveStatus(1, 1, 200, 200) // ErrSuccess
+	kit.ReserveStatus(101, 199, 500, 599)
+	kit.ReserveStatus(201, 299, 401, 403)
+	kit.ReserveStatus(301, 399, 500, 599)
+	kit.ReserveStatus(400, 499, 400, 499)
+	kit.ReserveStatus(500, 599, 500, 599)
+
 	// Register basic errors
-	errors.Register(ErrSuccess, 200, "OK")
-	errors.Register(ErrUnknown, 500, "Internal server error")
-	errors.Register(ErrBind, 400, "Error binding request")
-	errors.Register(ErrValidation, 400, "Validation failed")
-	errors.Register(ErrTokenInvalid, 401, "Token invalid")
+	kit.RegisterCode(ErrSuccess, 200, "OK")
+	kit.RegisterCode(ErrUnknown, 500, "Unknown error")
+	kit.RegisterCode(ErrBind, 400, "Error binding request")
+	kit.RegisterCode(ErrValidation, 400, "Validation failed")
+	kit.RegisterCode(ErrTokenInvalid, 401, "Token invalid")
+	kit.RegisterCode(ErrStartup, 500, "Startup failed")
+	kit.RegisterCode(ErrConflict, 409, "Conflict")
+	kit.RegisterCode(ErrAPIVersionUnsupported, 404, "API version not supported")
 
 	// Register database errors
-	errors.Register(ErrDatabase, 500, "Database error")
-	errors.Register(ErrRedis, 500, "Redis error")
-	errors.Register(ErrKafka, 500, "Kafka error")
-	errors.Register(ErrExternalService, 500, "External service error")
+	kit.RegisterCode(ErrDatabase, 500, "Database error")
+	kit.RegisterCode(ErrRedis, 500, "Redis error")
+	kit.RegisterCode(ErrKafka, 500, "Kafka error")
+	kit.RegisterCode(ErrExternalService, 500, "External service error")
+	kit.RegisterCode(ErrCircuitOpen, 503, "Service temporarily unavailable")
+	kit.RegisterCode(ErrSecret, 500, "Secret could not be resolved")
 
 	// Register HTTP status errors
-	errors.Register(ErrBadRequest, 400, "Bad request")
-	errors.Register(ErrUnauthorized, 401, "Unauthorized")
-	errors.Register(ErrForbidden, 403, "Forbidden")
-	errors.Register(ErrNotFound, 404, "Not found")
-	errors.Register(ErrInternalServer, 500, "Internal server error")
+	kit.RegisterCode(ErrBadRequest, 400, "Bad request")
+	kit.RegisterCode(ErrUnauthorized, 401, "Unauthorized")
+	kit.RegisterCode(ErrForbidden, 403, "Forbidden")
+	kit.RegisterCode(ErrNotFound, 404, "Not found")
+	kit.RegisterCode(ErrMethodNotAllowed, 405, "Method not allowed")
+	kit.RegisterCode(ErrAlreadyExists, 409, "Already exists")
+	kit.RegisterCode(ErrPayloadTooLarge, 413, "Payload too large")
+	kit.RegisterCode(ErrUnsupportedMediaType, 415, "Unsupported media type")
+	kit.RegisterCode(ErrUpgradeRequired, 426, "Upgrade required")
+	kit.RegisterCode(ErrTooManyRequests, 429, "Too many requests")
+	kit.RegisterCode(ErrInternalServer, 500, "Internal server error")
+	kit.RegisterCode(ErrServiceUnavailable, 503, "Service unavailable")
+	kit.RegisterCode(ErrTimeout, 504, "Operation timed out")
+	kit.RegisterCode(ErrClientClosedRequest, 499, "Client closed request")
 
 	// Register auth errors
-	errors.Register(ErrEncrypt, 401, "Encryption failed")
-	errors.Register(ErrSignatureInvalid, 401, "Signature is invalid")
-	errors.Register(ErrExpired, 401, "Token expired")
-	errors.Register(ErrInvalidAuthHeader, 401, "Invalid authorization header")
-	errors.Register(ErrMissingHeader, 401, "Authorization header missing")
-	errors.Register(ErrPasswordIncorrect, 401, "Password incorrect")
-	errors.Register(ErrPermissionDenied, 403, "Permission denied")
-	errors.Register(ErrAccountLocked, 403, "Account locked")
-	errors.Register(ErrAccountDisabled, 403, "Account disabled")
-	errors.Register(ErrTooManyAttempts, 403, "Too many attempts")
+	kit.RegisterCode(ErrEncrypt, 401, "Encryption failed")
+	kit.RegisterCode(ErrSignatureInvalid, 401, "Signature is invalid")
+	kit.RegisterCode(ErrExpired, 401, "Token expired")
+	kit.RegisterCode(ErrInvalidAuthHeader, 401, "Invalid authorization header")
+	kit.RegisterCode(ErrMissingHeader, 401, "Authorization header missing")
+	kit.RegisterCode(ErrPasswordIncorrect, 401, "Password incorrect")
+	kit.RegisterCode(ErrPermissionDenied, 403, "Permission denied")
+	kit.RegisterCode(ErrAccountLocked, 403, "Account locked")
+	kit.RegisterCode(ErrAccountDisabled, 403, "Account disabled")
+	kit.RegisterCode(ErrTooManyAttempts, 403, "Too many attempts")
 
 	// Register encoding errors
-	errors.Register(ErrEncodingFailed, 500, "Encoding failed")
-	errors.Register(ErrDecodingFailed, 500, "Decoding failed")
-	errors.Register(ErrInvalidJSON, 500, "Invalid JSON")
-	errors.Register(ErrEncodingJSON, 500, "JSON encoding failed")
-	errors.Register(ErrDecodingJSON, 500, "JSON decoding failed")
-	errors.Register(ErrInvalidYaml, 500, "Invalid YAML")
-	errors.Register(ErrEncodingYaml, 500, "YAML encoding failed")
-	errors.Register(ErrDecodingYaml, 500, "YAML decoding failed")
+	kit.RegisterCode(ErrEncodingFailed, 500, "Encoding failed")
+	kit.RegisterCode(ErrDecodingFailed, 500, "Decoding failed")
+	kit.RegisterCode(ErrInvalidJSON, 500, "Invalid JSON")
+	kit.RegisterCode(ErrEncodingJSON, 500, "JSON encoding failed")
+	kit.RegisterCode(ErrDecodingJSON, 500, "JSON decoding failed")
+	kit.RegisterCode(ErrInvalidYaml, 500, "Invalid YAML")
+	kit.RegisterCode(ErrEncodingYaml, 500, "YAML encoding failed")
+	kit.RegisterCode(ErrDecodingYaml, 500, "YAML decoding failed")
 }
@@ -21,6 +21,7 @@ package code
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/NSObjects/go-kit/errors"
 )
@@ -70,26 +71,42 @@ func wrapOrNewf(err error, code int, format string, args ...any) error {
 	return errors.WrapCode(err, code, format, args...)
 }
 
+// wrapInfraError is wrapIfError that keeps context deadline and
+// cancellation errors as ErrTimeout and ErrClientClosedRequest.
+func wrapInfraError(err error, code int, format string, args ...any) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := errors.FromContextError(err); ctxErr != err {
+		code = errors.GetCode(ctxErr)
+	}
+	return errors.WrapCode(err, code, format, args...)
+}
+
 // ========== Infrastructure Error Wrappers ==========
 
-// WrapDatabaseError wraps a database error.
+// WrapDatabaseError wraps a database error. Context deadline and
+// cancellation errors become ErrTimeout and ErrClientClosedRequest.
 func WrapDatabaseError(err error, operation string) error {
-	return wrapIfError(err, ErrDatabase, "database %s failed", operation)
+	return wrapInfraError(err, ErrDatabase, "database %s failed", operation)
 }
 
-// WrapRedisError wraps a Redis error.
+// WrapRedisError wraps a Redis error. Context deadline and cancellation
+// errors become ErrTimeout and ErrClientClosedRequest.
 func WrapRedisError(err error, operation string) error {
-	return wrapIfError(err, ErrRedis, "redis %s failed", operation)
+	return wrapInfraError(err, ErrRedis, "redis %s failed", operation)
 }
 
-// WrapKafkaError wraps a Kafka error.
+// WrapKafkaError wraps a Kafka error. Context deadline and cancellation
+// errors become ErrTimeout and ErrClientClosedRequest.
 func WrapKafkaError(err error, operation string) error {
-	return wrapIfError(err, ErrKafka, "kafka %s failed", operation)
+	return wrapInfraError(err, ErrKafka, "kafka %s failed", operation)
 }
 
-// WrapExternalError wraps an external service error.
+// WrapExternalError wraps an external service error. Context deadline and
+// cancellation errors become ErrTimeout and ErrClientClosedRequest.
 func WrapExternalError(err error, service, operation string) error {
-	return wrapIfError(err, ErrExternalService, "external service %s %s failed", service, operation)
+	return wrapInfraError(err, ErrExternalService, "external service %s %s failed", service, operation)
 }
 
 // ========== HTTP Error Wrappers ==========
@@ -258,6 +275,70 @@ func classifyErrorType(errCode int) ErrorType {
 	return BusinessError
 }
 
+// CategoryOf returns the category of an error code. Codes of the kit have
+// the categories above; codes of other namespaces (see errors.Namespace)
+// are in the category named after their namespace, or the one set with
+// SetNamespaceCategory; other codes are CategoryBusiness.
+func CategoryOf(errCode int) ErrorCategory {
+	return classifyErrorCategory(errCode)
+}
+
+var (
+	namespaceCategories   = make(map[string]ErrorCategory)
+	namespaceCategoriesMu sync.RWMutex
+)
+
+// SetNamespaceCategory sets the category of the codes of namespace,
+// instead of the namespace name.
+//
+//	code.SetNamespaceCategory("billing", code.CategoryBusiness)
+func SetNamespaceCategory(namespace string, category ErrorCategory) {
+	namespaceCategoriesMu.Lock()
+	defer namespaceCategoriesMu.Unlock()
+	namespaceCategories[namespace] = category
+}
+
+// namespaceCategory returns the category of the codes of namespace.
+func namespaceCategory(namespace string) ErrorCategory {
+	namespaceCategoriesMu.RLock()
+	defer namespaceCategoriesMu.RUnlock()
+	if c, ok := namespaceCategories[namespace]; ok {
+		return c
+	}
+	return ErrorCategory(namespace)
+}
+
+// HasCategory reports whether any code in err's chain belongs to category,
+// e.g. code.HasCategory(err, code.CategoryDatabase).
+func HasCategory(err error, category ErrorCategory) bool {
+	for _, c := range errors.CodesIn(err) {
+		if classifyErrorCategory(c) == category {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRetryable reports whether err is transient, judged by its outermost
+// code: infrastructure failures, timeouts, unavailability and open
+// circuits are; client errors and business errors are not.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	return isRetryableCode(errors.GetCode(err))
+}
+
+// isRetryableCode reports whether errors with code are transient.
+func isRetryableCode(code int) bool {
+	switch code {
+	case ErrDatabase, ErrRedis, ErrKafka, ErrExternalService, ErrCircuitOpen,
+		ErrServiceUnavailable, ErrTimeout:
+		return true
+	}
+	return false
+}
+
 func classifyErrorCategory(errCode int) ErrorCategory {
 	switch errCode {
 	case ErrDatabase:
@@ -266,18 +347,23 @@ func classifyErrorCategory(errCode int) ErrorCategory {
 		return CategoryRedis
 	case ErrKafka:
 		return CategoryKafka
-	case ErrExternalService:
+	case ErrExternalService, ErrCircuitOpen:
 		return CategoryExternal
-	case ErrValidation, ErrBind, ErrBadRequest:
+	case ErrValidation, ErrBind, ErrBadRequest, ErrPayloadTooLarge, ErrUnsupportedMediaType:
 		return CategoryValidation
 	case ErrUnauthorized, ErrTokenInvalid, ErrExpired, ErrInvalidAuthHeader, ErrMissingHeader, ErrSignatureInvalid, ErrPasswordIncorrect:
 		return CategoryAuth
-	case ErrForbidden, ErrPermissionDenied, ErrAccountLocked, ErrAccountDisabled, ErrTooManyAttempts:
+	case ErrForbidden, ErrPermissionDenied, ErrAccountLocked, ErrAccountDisabled, ErrTooManyAttempts, ErrTooManyRequests:
 		return CategoryPermission
+	case ErrServiceUnavailable, ErrStartup, ErrTimeout, ErrClientClosedRequest:
+		return CategorySystem
 	default:
 		if errCode >= 100300 && errCode < 100400 {
 			return CategorySystem
 		}
+		if ns := errors.NamespaceOf(errCode); ns != "" && ns != KitNamespace {
+			return namespaceCategory(ns)
+		}
 		return CategoryBusiness
 	}
 }
@@ -0,0 +1,41 @@
+package code
+
+import "strings"
+
+// FieldError describes an invalid request field or parameter.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors is an ErrBadRequest error listing every invalid field.
+// resp.APIError renders the list as the response data:
+//
+//	{"code":100400,"msg":"Bad request","data":[{"field":"id","message":"expected integer"}]}
+type ValidationErrors []FieldError
+
+// NewFieldError creates a ValidationErrors with a single field.
+func NewFieldError(field, message string) error {
+	return ValidationErrors{{Field: field, Message: message}}
+}
+
+// Error implements error.
+func (v ValidationErrors) Error() string {
+	var b strings.Builder
+	for i, fe := range v {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		if fe.Field != "" {
+			b.WriteString(fe.Field)
+			b.WriteString(": ")
+		}
+		b.WriteString(fe.Message)
+	}
+	return b.String()
+}
+
+// Code implements errors.CodedError.
+func (v ValidationErrors) Code() int {
+	return ErrBadRequest
+}
@@ -0,0 +1,761 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severity classifies a configuration Problem.
+type Severity string
+
+const (
+	// SeverityError problems prevent a correct startup.
+	SeverityError Severity = "error"
+	// SeverityWarn problems are suspicious but not fatal.
+	SeverityWarn Severity = "warn"
+)
+
+// Problem is one configuration diagnostic. Key is the dotted config path.
+type Problem struct {
+	Severity Severity `json:"severity"`
+	Key      string   `json:"key"`
+	Message  string   `json:"message"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("[%s] %s: %s", p.Severity, p.Key, p.Message)
+}
+
+// Problems is a list of configuration diagnostics.
+type Problems []Problem
+
+// HasErrors reports whether any problem has SeverityError.
+func (ps Problems) HasErrors() bool {
+	return slices.ContainsFunc(ps, func(p Problem) bool { return p.Severity == SeverityError })
+}
+
+// Report formats the problems one per line, errors first.
+func (ps Problems) Report() string {
+	sorted := slices.Clone(ps)
+	slices.SortStableFunc(sorted, func(a, b Problem) int {
+		if a.Severity == b.Severity {
+			return 0
+		}
+		if a.Severity == SeverityError {
+			return -1
+		}
+		return 1
+	})
+	var b strings.Builder
+	for _, p := range sorted {
+		b.WriteString(p.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Err returns an error carrying the report when there are errors, else nil.
+func (ps Problems) Err() error {
+	if !ps.HasErrors() {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration:\n%s", ps.Report())
+}
+
+// Checker is implemented by configurations that can check themselves.
+// Config implements it, so application configs embedding Config do too.
+type Checker interface {
+	CheckConfig() Problems
+}
+
+// CheckConfig implements Checker.
+func (c Config) CheckConfig() Problems {
+	return Check(c)
+}
+
+// Check runs every sanity check of the sections owned by the kit and
+// returns all problems at once.
+func Check(cfg Config) Problems {
+	var ck checker
+	ck.system(cfg.System)
+	ck.database(cfg.Database, cfg.System.Env)
+	ck.redis(cfg.Redis)
+	ck.mongo(cfg.Mongodb)
+	ck.kafka(cfg.Kafka)
+	ck.log(cfg.Log, cfg.System.Env)
+	ck.jwt(cfg.JWT)
+	ck.cors(cfg.CORS)
+	ck.casbin(cfg.Casbin)
+	ck.otel(cfg.Otel)
+	ck.ipFilter(cfg.IPFilter)
+	ck.admin(cfg.Admin)
+	ck.secrets(cfg)
+	ck.storage(cfg.Storage)
+	ck.notify(cfg.Notify)
+	ck.concurrency(cfg.Concurrency)
+	ck.clientVersion(cfg.ClientVersion)
+	ck.bind(cfg.Bind)
+	ck.maintenance(cfg.Maintenance)
+	ck.serviceAuth(cfg.ServiceAuth)
+	ck.faultInjection(cfg.FaultInjection, cfg.System.Env)
+	ck.features(cfg.Features)
+	ck.profile(cfg.Profile)
+	return ck.problems
+}
+
+// RunCheck loads the config file at path strictly (see FileSource.Strict),
+// checks it and writes the report to w. It returns the process exit code: 0 when there are no errors. Use
+// it for a --check-config mode that validates config files in CI without
+// starting the app:
+//
+//	if config.HasCheckFlag(os.Args[1:]) {
+//	    os.Exit(config.RunCheck[AppConfig](os.Stdout, "config.toml"))
+//	}
+func RunCheck[T any](w io.Writer, path string) int {
+	cfg, err := FileSource[T]{Path: path, Strict: true}.Load(context.Background())
+	if err != nil {
+		fmt.Fprintf(w, "%s: %v\n", path, err)
+		return 1
+	}
+	checker, ok := any(cfg).(Checker)
+	if !ok {
+		fmt.Fprintf(w, "%s: loaded, no checks for %T\n", path, cfg)
+		return 0
+	}
+	problems := checker.CheckConfig()
+	if len(problems) == 0 {
+		fmt.Fprintf(w, "%s: OK\n", path)
+		return 0
+	}
+	fmt.Fprintf(w, "%s:\n%s", path, problems.Report())
+	if problems.HasErrors() {
+		return 1
+	}
+	return 0
+}
+
+// HasCheckFlag reports whether args contain --check-config.
+func HasCheckFlag(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if arg == "--check-config" || arg == "-check-config" {
+			return true
+		}
+	}
+	return false
+}
+
+// checker accumulates problems.
+type checker struct {
+	problems Problems
+}
+
+func (ck *checker) errorf(key, format string, args ...any) {
+	ck.problems = append(ck.problems, Problem{Severity: SeverityError, Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+func (ck *checker) warnf(key, format string, args ...any) {
+	ck.problems = append(ck.problems, Problem{Severity: SeverityWarn, Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+func (ck *checker) nonNegative(key string, n int64) {
+	if n < 0 {
+		ck.errorf(key, "must not be negative, got %d", n)
+	}
+}
+
+// port checks a required port number.
+func (ck *checker) port(key string, port int) {
+	if port <= 0 || port > 65535 {
+		ck.errorf(key, "must be between 1 and 65535, got %d", port)
+	}
+}
+
+var (
+	knownEnvs      = []string{"dev", "test", "prod"}
+	knownLogLevels = []string{"debug", "info", "warn", "warning", "error"}
+	proxyPresets   = []string{"loopback", "private", "cloudflare"}
+)
+
+// RegisterEnv adds env to the environments accepted by Check (kit.RegisterProfile
+// does this). Call it during startup.
+func RegisterEnv(env string) {
+	if env != "" && !slices.Contains(knownEnvs, env) {
+		knownEnvs = append(knownEnvs, env)
+	}
+}
+
+func (ck *checker) system(c SystemConfig) {
+	if c.Port != "" {
+		if p, err := strconv.Atoi(c.Port); err != nil || p < 0 || p > 65535 {
+			ck.errorf("system.port", "must be a port number, got %q", c.Port)
+		}
+	}
+	if c.Env != "" && !slices.Contains(knownEnvs, c.Env) {
+		ck.warnf("system.env", "unknown environment %q (expected %s)", c.Env, strings.Join(knownEnvs, ", "))
+	}
+	if c.Level != "" && !slices.Contains(knownLogLevels, strings.ToLower(c.Level)) {
+		ck.warnf("system.level", "unknown log level %q", c.Level)
+	}
+	ck.nonNegative("system.read_timeout", int64(c.ReadTimeout))
+	ck.nonNegative("system.read_header_timeout", int64(c.ReadHeaderTimeout))
+	ck.nonNegative("system.write_timeout", int64(c.WriteTimeout))
+	ck.nonNegative("system.idle_timeout", int64(c.IdleTimeout))
+	ck.nonNegative("system.shutdown_timeout", int64(c.ShutdownTimeout))
+	ck.nonNegative("system.drain_delay", int64(c.DrainDelay))
+	ck.nonNegative("system.max_header_bytes", int64(c.MaxHeaderBytes))
+	if c.ShutdownTimeout > 0 && c.DrainDelay >= c.ShutdownTimeout {
+		ck.warnf("system.drain_delay", "drain delay %s leaves no time of the %s shutdown timeout", c.DrainDelay, c.ShutdownTimeout)
+	}
+	ck.trustedProxies("system.trusted_proxies", c.TrustedProxies)
+	if c.TimeZone != "" {
+		if _, err := time.LoadLocation(c.TimeZone); err != nil {
+			ck.errorf("system.timezone", "unknown location %q", c.TimeZone)
+		}
+	}
+	if f := c.TimeFormat; f != "" && f != "rfc3339" && f != "unix" && f != "unix_milli" && !strings.ContainsAny(f, "0123456789") {
+		ck.errorf("system.time_format", "unknown format %q (expected rfc3339, unix, unix_milli or a Go time layout)", f)
+	}
+
+	tls := c.TLS
+	if (tls.CertFile == "") != (tls.KeyFile == "") {
+		ck.errorf("system.tls", "cert_file and key_file must be set together")
+	}
+	ck.fileExists("system.tls.cert_file", tls.CertFile)
+	ck.fileExists("system.tls.key_file", tls.KeyFile)
+	if tls.Autocert.Enabled {
+		if len(tls.Autocert.Hosts) == 0 {
+			ck.errorf("system.tls.autocert.hosts", "required when autocert is enabled")
+		}
+		if tls.CertFile != "" {
+			ck.warnf("system.tls.autocert", "enabled together with cert_file; the static certificate is ignored")
+		}
+		if c.Env == "dev" && !tls.Autocert.AllowInDev {
+			ck.warnf("system.tls.autocert", "enabled in dev without allow_in_dev")
+		}
+	}
+	ck.fileExists("system.tls.client_ca_file", tls.ClientCAFile)
+	if a := tls.ClientAuth; a != "" && a != "require" && a != "request" {
+		ck.errorf("system.tls.client_auth", "unknown mode %q (expected require or request)", a)
+	}
+	if tls.ClientCAFile == "" && (tls.ClientAuth != "" || len(tls.ClientSANs) > 0) {
+		ck.errorf("system.tls.client_ca_file", "required with client_auth or client_sans")
+	}
+}
+
+func (ck *checker) database(c DatabaseConfig, env string) {
+	driver := c.Driver
+	if driver == "" {
+		if c.Host == "" && c.Database == "" {
+			return // database not configured
+		}
+		driver = "mysql" // db.NewDialector default
+	}
+	switch driver {
+	case "mysql", "postgres":
+		if c.Host == "" {
+			ck.errorf("database.host", "required for %s", driver)
+		}
+		ck.port("database.port", c.Port)
+		if c.Database == "" {
+			ck.errorf("database.database", "required for %s", driver)
+		}
+	case "sqlite":
+		if c.Database == "" {
+			ck.errorf("database.database", "sqlite file path required")
+		}
+		if m := strings.ToLower(c.Sqlite.JournalMode); m != "" &&
+			!slices.Contains([]string{"wal", "delete", "truncate", "persist", "memory", "off"}, m) {
+			ck.errorf("database.sqlite.journal_mode", "unknown journal mode %q", c.Sqlite.JournalMode)
+		}
+		if s := strings.ToLower(c.Sqlite.Synchronous); s != "" &&
+			!slices.Contains([]string{"off", "normal", "full", "extra"}, s) {
+			ck.errorf("database.sqlite.synchronous", "unknown synchronous mode %q", c.Sqlite.Synchronous)
+		}
+		ck.nonNegative("database.sqlite.busy_timeout_ms", int64(c.Sqlite.BusyTimeoutMS))
+	default:
+		ck.errorf("database.driver", "unknown driver %q (expected mysql, postgres or sqlite)", c.Driver)
+	}
+	if c.Driver == "postgres" && c.SSLMode != "" &&
+		!slices.Contains([]string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}, c.SSLMode) {
+		ck.errorf("database.ssl_mode", "unknown ssl mode %q", c.SSLMode)
+	}
+
+	ck.nonNegative("database.max_idle_conns", int64(c.MaxIdleConns))
+	ck.nonNegative("database.max_open_conns", int64(c.MaxOpenConns))
+	ck.nonNegative("database.max_lifetime", int64(c.MaxLifetime))
+	ck.nonNegative("database.conn_max_idle_time", int64(c.ConnMaxIdleTime))
+	ck.nonNegative("database.validate_idle", int64(c.ValidateIdle))
+	ck.nonNegative("database.default_query_timeout", int64(c.DefaultQueryTimeout))
+	ck.nonNegative("database.slow_query.threshold", int64(c.SlowQuery.Threshold))
+	ck.nonNegative("database.slow_query.explain_timeout", int64(c.SlowQuery.ExplainTimeout))
+	ck.nonNegative("database.slow_query.explain_interval", int64(c.SlowQuery.ExplainInterval))
+	ck.nonNegative("database.request_stats.repeat_threshold", int64(c.RequestStats.RepeatThreshold))
+	if c.SlowQuery.Explain && env != "dev" {
+		ck.warnf("database.slow_query.explain", "ignored in %q: EXPLAIN only runs in dev", env)
+	}
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		ck.warnf("database.max_idle_conns", "%d exceeds max_open_conns %d and is capped to it", c.MaxIdleConns, c.MaxOpenConns)
+	}
+	if c.MaxLifetime > 0 && c.ConnMaxIdleTime > c.MaxLifetime {
+		ck.warnf("database.conn_max_idle_time", "%ds exceeds max_lifetime %ds and has no effect", c.ConnMaxIdleTime, c.MaxLifetime)
+	}
+}
+
+func (ck *checker) redis(c RedisConfig) {
+	if c.Host == "" {
+		return
+	}
+	ck.port("redis.port", c.Port)
+	ck.nonNegative("redis.pool_size", int64(c.PoolSize))
+	ck.nonNegative("redis.min_idle_conns", int64(c.MinIdleConns))
+	ck.nonNegative("redis.conn_max_idle_time", int64(c.ConnMaxIdleTime))
+	ck.nonNegative("redis.conn_max_lifetime", int64(c.ConnMaxLifetime))
+	ck.nonNegative("redis.default_query_timeout", int64(c.DefaultQueryTimeout))
+	if c.DB < 0 || c.DB > 15 {
+		ck.warnf("redis.database", "database %d is outside the default 0-15 range", c.DB)
+	}
+}
+
+func (ck *checker) mongo(c MongoConfig) {
+	switch {
+	case c.URI != "":
+		if c.Host != "" || c.Port != 0 || c.User != "" || c.Password != "" {
+			ck.errorf("mongodb.uri", "cannot be combined with host, port, user or password")
+		}
+		if !strings.HasPrefix(c.URI, "mongodb://") && !strings.HasPrefix(c.URI, "mongodb+srv://") {
+			ck.errorf("mongodb.uri", "must start with mongodb:// or mongodb+srv://")
+		}
+	case c.Host != "":
+		ck.port("mongodb.port", c.Port)
+		if (c.User == "") != (c.Password == "") {
+			ck.warnf("mongodb.user", "user and password should be set together")
+		}
+	default:
+		return
+	}
+	if c.Database == "" {
+		ck.errorf("mongodb.database", "required when mongodb is configured")
+	}
+	if c.MaxPoolSize > 0 && c.MinPoolSize > c.MaxPoolSize {
+		ck.errorf("mongodb.min_pool_size", "%d exceeds max_pool_size %d", c.MinPoolSize, c.MaxPoolSize)
+	}
+	ck.nonNegative("mongodb.connect_timeout", int64(c.ConnectTimeout))
+	ck.nonNegative("mongodb.server_selection_timeout", int64(c.ServerSelectionTimeout))
+	switch strings.ToLower(c.ReadPreference) {
+	case "", "primary", "primarypreferred", "secondary", "secondarypreferred", "nearest":
+	default:
+		ck.errorf("mongodb.read_preference", "unknown read preference %q", c.ReadPreference)
+	}
+	if w := c.WriteConcern.W; w != "" && w != "majority" {
+		if n, err := strconv.Atoi(w); err != nil || n < 0 {
+			ck.errorf("mongodb.write_concern.w", `must be "majority" or a number of nodes, got %q`, w)
+		}
+	}
+	ck.nonNegative("mongodb.write_concern.wtimeout", int64(c.WriteConcern.WTimeout))
+
+	t := c.TLS
+	if !t.Enabled {
+		if t.CAFile != "" || t.CertFile != "" || t.KeyFile != "" {
+			ck.warnf("mongodb.tls.enabled", "tls files are set but tls is disabled")
+		}
+		return
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		ck.errorf("mongodb.tls.cert_file", "cert_file and key_file must be set together")
+	}
+	ck.fileExists("mongodb.tls.ca_file", t.CAFile)
+	ck.fileExists("mongodb.tls.cert_file", t.CertFile)
+	ck.fileExists("mongodb.tls.key_file", t.KeyFile)
+	if t.InsecureSkipVerify {
+		ck.warnf("mongodb.tls.insecure_skip_verify", "certificate verification is disabled")
+	}
+}
+
+func (ck *checker) kafka(c KafkaConfig) {
+	if len(c.Brokers) == 0 {
+		if c.Topic != "" || c.ClientID != "" {
+			ck.errorf("kafka.brokers", "required when kafka settings are present")
+		}
+		return
+	}
+	for i, b := range c.Brokers {
+		if _, port, ok := strings.Cut(b, ":"); !ok || port == "" {
+			ck.errorf(fmt.Sprintf("kafka.brokers[%d]", i), "expected host:port, got %q", b)
+		}
+	}
+}
+
+func (ck *checker) log(c LogConfig, env string) {
+	level := strings.ToLower(c.Level)
+	if level != "" && !slices.Contains(knownLogLevels, level) {
+		ck.errorf("log.level", "unknown level %q (expected debug, info, warn or error)", c.Level)
+	}
+	if env == "prod" && level == "debug" {
+		ck.warnf("log.level", "debug logging in prod")
+	}
+	if c.Format != "" && !slices.Contains([]string{"json", "text", "color"}, c.Format) {
+		ck.errorf("log.format", "unknown format %q (expected json, text or color)", c.Format)
+	}
+	if c.Output != "" && c.Output != "stdout" && c.Output != "stderr" {
+		ck.errorf("log.output", "unknown output %q (expected stdout or stderr)", c.Output)
+	}
+	if c.File.Filename != "" {
+		ck.nonNegative("log.file.max_size", int64(c.File.MaxSize))
+		ck.nonNegative("log.file.max_backups", int64(c.File.MaxBackups))
+		ck.nonNegative("log.file.max_age", int64(c.File.MaxAge))
+	}
+}
+
+func (ck *checker) jwt(c JWTConfig) {
+	if !c.Enabled {
+		return
+	}
+	switch {
+	case c.SecretFile != "":
+		if c.Secret != "" {
+			ck.warnf("jwt.secret", "ignored when jwt.secret_file is set")
+		}
+	case c.Secret == "":
+		ck.errorf("jwt.secret", "required when jwt is enabled")
+	case len(c.Secret) < 32:
+		ck.warnf("jwt.secret", "shorter than 32 bytes")
+	}
+	ck.nonNegative("jwt.expire", int64(c.Expire))
+	ck.nonNegative("jwt.key_overlap", int64(c.KeyOverlap))
+}
+
+func (ck *checker) cors(c CORSConfig) {
+	ck.corsPolicy("cors", c)
+	for prefix, g := range c.Groups {
+		key := "cors.groups." + prefix
+		if !strings.HasPrefix(prefix, "/") {
+			ck.errorf(key, "group must be a path prefix starting with /")
+		}
+		if len(g.Groups) > 0 {
+			ck.warnf(key+".groups", "nested groups are ignored")
+		}
+		ck.corsPolicy(key, g)
+	}
+}
+
+func (ck *checker) corsPolicy(key string, c CORSConfig) {
+	if c.AllowCredentials && slices.Contains(c.AllowOrigins, "*") {
+		ck.errorf(key+".allow_origins", `"*" cannot be combined with allow_credentials`)
+	}
+	for _, origin := range c.AllowOrigins {
+		if origin != "*" && strings.Count(origin, "*") > 1 {
+			ck.errorf(key+".allow_origins", "origin %q has more than one wildcard", origin)
+		}
+	}
+}
+
+func (ck *checker) casbin(c CasbinConfig) {
+	if !c.Enabled {
+		return
+	}
+	switch {
+	case c.Model == "" && c.ModelFile == "":
+		ck.errorf("casbin.model", "model or model_file required when casbin is enabled")
+	case c.ModelFile != "":
+		ck.fileExists("casbin.model_file", c.ModelFile)
+	}
+	switch c.Unannotated {
+	case "", "path", "deny", "allow":
+	default:
+		ck.errorf("casbin.unannotated", "unknown policy %q (expected path, deny or allow)", c.Unannotated)
+	}
+	ck.nonNegative("casbin.decision_cache.size", int64(c.DecisionCache.Size))
+	ck.nonNegative("casbin.decision_cache.ttl", int64(c.DecisionCache.TTL))
+}
+
+func (ck *checker) otel(c OtelConfig) {
+	if c.SamplingRatio < 0 || c.SamplingRatio > 1 {
+		ck.errorf("otel.sampling_ratio", "must be between 0 and 1, got %g", c.SamplingRatio)
+	}
+	if !c.Enabled {
+		return
+	}
+	switch c.ExporterType {
+	case "", "otlp":
+		if c.OTLPEndpoint == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+			ck.warnf("otel.otlp_endpoint", "not set; spans are sent to localhost:4317")
+		}
+	case "stdout":
+	default:
+		ck.errorf("otel.exporter_type", "unknown exporter %q (expected otlp or stdout)", c.ExporterType)
+	}
+}
+
+func (ck *checker) ipFilter(c IPFilterConfig) {
+	ck.cidrs("ip_filter.allow", c.Allow)
+	ck.cidrs("ip_filter.deny", c.Deny)
+	ck.trustedProxies("ip_filter.trusted_proxies", c.TrustedProxies)
+}
+
+func (ck *checker) admin(c AdminConfig) {
+	if !c.Enabled {
+		return
+	}
+	ck.cidrs("admin.allow_cidrs", c.AllowCIDRs)
+	if c.Port != "" {
+		if p, err := strconv.Atoi(c.Port); err != nil {
+			ck.errorf("admin.port", "invalid port %q", c.Port)
+		} else {
+			ck.port("admin.port", p)
+		}
+	}
+	if c.Token == "" && len(c.AllowCIDRs) == 0 && c.Host != "" && !isLoopbackHost(c.Host) {
+		ck.errorf("admin.token", "required (or admin.allow_cidrs) when the admin server listens on %q", c.Host)
+	}
+}
+
+// isLoopbackHost reports whether host is localhost or a loopback IP.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.IsLoopback()
+}
+
+func (ck *checker) secrets(cfg Config) {
+	c := cfg.Secrets
+	ck.nonNegative("secrets.cache_ttl", int64(c.CacheTTL))
+	ck.nonNegative("secrets.vault.timeout", int64(c.Vault.Timeout))
+	if c.Vault.Address != "" && c.Vault.Token == "" && c.Vault.KubernetesRole == "" {
+		ck.errorf("secrets.vault.token", "required (or secrets.vault.kubernetes_role) when secrets.vault.address is set")
+	}
+	if c.Vault.Address != "" {
+		return
+	}
+	type ref struct{ key, value string }
+	refs := []ref{
+		{"database.password", cfg.Database.Password},
+		{"redis.password", cfg.Redis.Password},
+		{"mongodb.password", cfg.Mongodb.Password},
+		{"mongodb.uri", cfg.Mongodb.URI},
+		{"storage.local.signing_key", cfg.Storage.Local.SigningKey},
+		{"storage.s3.access_key", cfg.Storage.S3.AccessKey},
+		{"storage.s3.secret_key", cfg.Storage.S3.SecretKey},
+		{"service_auth.secret", cfg.ServiceAuth.Secret},
+	}
+	for i, p := range cfg.Notify.Providers {
+		refs = append(refs, ref{fmt.Sprintf("notify.providers[%d].smtp.password", i), p.SMTP.Password})
+	}
+	for _, f := range refs {
+		if strings.HasPrefix(f.value, "vault:") {
+			ck.errorf(f.key, "vault reference requires secrets.vault.address")
+		}
+	}
+}
+
+func (ck *checker) storage(c StorageConfig) {
+	switch c.Driver {
+	case "", "local":
+		if c.Local.Dir == "" && (c.Driver != "" || c.Local.BaseURL != "") {
+			ck.errorf("storage.local.dir", "required")
+		}
+		if c.Local.BaseURL != "" && c.Local.SigningKey == "" {
+			ck.errorf("storage.local.signing_key", "required when storage.local.base_url is set")
+		}
+	case "s3":
+		if c.S3.Endpoint == "" {
+			ck.errorf("storage.s3.endpoint", "required")
+		}
+		if c.S3.Bucket == "" {
+			ck.errorf("storage.s3.bucket", "required")
+		}
+		ck.nonNegative("storage.s3.part_size", c.S3.PartSize)
+		if c.S3.PartSize > 0 && c.S3.PartSize < 5<<20 {
+			ck.errorf("storage.s3.part_size", "must be at least 5MiB, got %d", c.S3.PartSize)
+		}
+	default:
+		ck.errorf("storage.driver", "unknown driver %q (expected local or s3)", c.Driver)
+	}
+}
+
+func (ck *checker) notify(c NotifyConfig) {
+	ck.nonNegative("notify.rate_limit", int64(c.RateLimit))
+	ck.nonNegative("notify.rate_window", int64(c.RateWindow))
+	seen := make(map[string]bool)
+	for i, p := range c.Providers {
+		key := fmt.Sprintf("notify.providers[%d]", i)
+		switch {
+		case p.Name == "":
+			ck.errorf(key+".name", "required")
+		case seen[p.Name]:
+			ck.errorf(key+".name", "duplicate provider %q", p.Name)
+		}
+		seen[p.Name] = true
+		ck.nonNegative(key+".max_attempts", int64(p.MaxAttempts))
+		switch p.Type {
+		case "smtp":
+			if p.SMTP.Host == "" {
+				ck.errorf(key+".smtp.host", "required")
+			}
+			if p.SMTP.Port != 0 {
+				ck.port(key+".smtp.port", p.SMTP.Port)
+			}
+			if t := p.SMTP.TLS; t != "" && t != "starttls" && t != "tls" && t != "none" {
+				ck.errorf(key+".smtp.tls", "unknown mode %q (expected starttls, tls or none)", t)
+			}
+		case "webhook":
+			if p.Webhook.URL == "" {
+				ck.errorf(key+".webhook.url", "required")
+			}
+		default:
+			ck.errorf(key+".type", "unknown type %q (expected smtp or webhook)", p.Type)
+		}
+	}
+}
+
+func (ck *checker) concurrency(c ConcurrencyConfig) {
+	ck.nonNegative("concurrency.default", int64(c.Default))
+	ck.nonNegative("concurrency.max_queue", int64(c.MaxQueue))
+	ck.nonNegative("concurrency.queue_timeout", int64(c.QueueTimeout))
+	for _, key := range slices.Sorted(maps.Keys(c.Limits)) {
+		ck.nonNegative("concurrency.limits."+key, int64(c.Limits[key]))
+	}
+}
+
+func (ck *checker) clientVersion(c ClientVersionConfig) {
+	if c.Missing != "" && c.Missing != "allow" && c.Missing != "deny" {
+		ck.errorf("client_version.missing", "unknown policy %q (expected allow or deny)", c.Missing)
+	}
+}
+
+func (ck *checker) bind(c BindConfig) {
+	ck.nonNegative("bind.max_depth", int64(c.MaxDepth))
+	ck.nonNegative("bind.max_string_length", int64(c.MaxStringLength))
+	ck.nonNegative("bind.max_tokens", int64(c.MaxTokens))
+	ck.nonNegative("bind.decode_timeout", int64(c.DecodeTimeout))
+}
+
+func (ck *checker) maintenance(c MaintenanceConfig) {
+	for i, w := range c.Windows {
+		key := fmt.Sprintf("maintenance.windows[%d]", i)
+		for _, d := range w.Days {
+			if _, err := ParseWeekday(d); err != nil {
+				ck.errorf(key+".days", "%v", err)
+			}
+		}
+		if _, err := time.Parse("15:04", w.Start); err != nil {
+			ck.errorf(key+".start", "expected HH:MM, got %q", w.Start)
+		}
+		if w.Duration <= 0 || w.Duration > 7*24*time.Hour {
+			ck.errorf(key+".duration", "must be between 0 and a week, got %s", w.Duration)
+		}
+		if _, err := time.LoadLocation(w.TimeZone); err != nil {
+			ck.errorf(key+".time_zone", "%v", err)
+		}
+	}
+}
+
+func (ck *checker) serviceAuth(c ServiceAuthConfig) {
+	if !c.Enabled {
+		return
+	}
+	if c.Service == "" {
+		ck.errorf("service_auth.service", "required when service_auth is enabled")
+	}
+	switch c.Algorithm {
+	case "", "hs256":
+		if c.Secret == "" {
+			ck.errorf("service_auth.secret", "required with algorithm hs256")
+		} else if len(c.Secret) < 32 {
+			ck.warnf("service_auth.secret", "shorter than 32 bytes")
+		}
+	case "ed25519":
+		if c.PrivateKeyFile == "" && len(c.PublicKeyFiles) == 0 {
+			ck.errorf("service_auth.private_key_file", "private_key_file or public_key_files required with algorithm ed25519")
+		}
+		ck.fileExists("service_auth.private_key_file", c.PrivateKeyFile)
+		for _, name := range slices.Sorted(maps.Keys(c.PublicKeyFiles)) {
+			ck.fileExists("service_auth.public_key_files."+name, c.PublicKeyFiles[name])
+		}
+	default:
+		ck.errorf("service_auth.algorithm", "unknown algorithm %q (expected hs256 or ed25519)", c.Algorithm)
+	}
+	ck.nonNegative("service_auth.token_ttl", int64(c.TokenTTL))
+	ck.nonNegative("service_auth.leeway", int64(c.Leeway))
+	if m := c.ClientCert; m != "" && m != "none" && m != "alternative" && m != "required" {
+		ck.errorf("service_auth.client_cert", "unknown mode %q (expected none, alternative or required)", m)
+	}
+}
+
+func (ck *checker) faultInjection(c FaultInjectionConfig, env string) {
+	if c.Enabled && env == "prod" {
+		ck.warnf("fault_injection.enabled", "fault injection is refused in prod")
+	}
+	for _, k := range []struct {
+		kind  string
+		rules []FaultRuleConfig
+	}{{"http", c.HTTP}, {"cache", c.Cache}, {"database", c.Database}} {
+		for i, r := range k.rules {
+			if err := r.Validate(k.kind); err != nil {
+				ck.errorf(fmt.Sprintf("fault_injection.%s[%d]", k.kind, i), "%v", err)
+			}
+		}
+	}
+}
+
+func (ck *checker) features(flags map[string]FeatureFlag) {
+	for _, name := range slices.Sorted(maps.Keys(flags)) {
+		if f := flags[name]; f.Percentage < 0 || f.Percentage > 100 {
+			ck.errorf("features."+name+".percentage", "must be between 0 and 100, got %d", f.Percentage)
+		}
+	}
+}
+
+func (ck *checker) profile(c ProfileConfig) {
+	if r := c.AccessLogSampleRate; r != nil && (*r < 0 || *r > 1) {
+		ck.errorf("profile.access_log_sample_rate", "must be between 0 and 1, got %g", *r)
+	}
+}
+
+func (ck *checker) cidrs(key string, entries []string) {
+	for i, e := range entries {
+		if !validIPOrCIDR(e) {
+			ck.errorf(fmt.Sprintf("%s[%d]", key, i), "invalid IP or CIDR %q", e)
+		}
+	}
+}
+
+func (ck *checker) trustedProxies(key string, entries []string) {
+	for i, e := range entries {
+		if !slices.Contains(proxyPresets, strings.ToLower(strings.TrimSpace(e))) && !validIPOrCIDR(e) {
+			ck.errorf(fmt.Sprintf("%s[%d]", key, i), "invalid IP, CIDR or preset %q", e)
+		}
+	}
+}
+
+func (ck *checker) fileExists(key, path string) {
+	if path == "" {
+		return
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		ck.errorf(key, "file %q does not exist", path)
+	}
+}
+
+func validIPOrCIDR(s string) bool {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		_, err := netip.ParsePrefix(s)
+		return err == nil
+	}
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
@@ -4,12 +4,20 @@ package config
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
-	"sync"
-	"sync/atomic"
 	"time"
 
+	"github.com/NSObjects/go-kit/internal/deprecation"
+	"github.com/NSObjects/go-kit/lifecycle"
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
@@ -26,26 +34,96 @@ type Config struct {
 	CORS     CORSConfig     `mapstructure:"cors"`
 	Casbin   CasbinConfig   `mapstructure:"casbin"`
 	Otel     OtelConfig     `mapstructure:"otel"`
+	IPFilter IPFilterConfig `mapstructure:"ip_filter"`
+	Admin    AdminConfig    `mapstructure:"admin"`
+	Secrets  SecretsConfig  `mapstructure:"secrets"`
+	Storage  StorageConfig  `mapstructure:"storage"`
+	Notify   NotifyConfig   `mapstructure:"notify"`
+
+	Concurrency   ConcurrencyConfig   `mapstructure:"concurrency"`
+	ClientVersion ClientVersionConfig `mapstructure:"client_version"`
+	Bind          BindConfig          `mapstructure:"bind"`
+	Maintenance   MaintenanceConfig   `mapstructure:"maintenance"`
+	ServiceAuth   ServiceAuthConfig   `mapstructure:"service_auth"`
+
+	FaultInjection FaultInjectionConfig `mapstructure:"fault_injection"`
+
+	Features map[string]FeatureFlag `mapstructure:"features"`
+
+	Profile ProfileConfig `mapstructure:"profile"`
 }
 
 // SystemConfig contains system-level settings.
 type SystemConfig struct {
+	Host    string `mapstructure:"host"` // listen host (default: all interfaces)
 	Port    string `mapstructure:"port"`
 	Env     string `mapstructure:"env"` // dev, test, prod
 	Name    string `mapstructure:"name"`
 	Version string `mapstructure:"version"`
 	Level   string `mapstructure:"level"`
+
+	// HTTP server settings (zero values use safe defaults)
+	ReadTimeout       time.Duration `mapstructure:"read_timeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+	WriteTimeout      time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout"`
+	MaxHeaderBytes    int           `mapstructure:"max_header_bytes"`
+	ShutdownTimeout   time.Duration `mapstructure:"shutdown_timeout"`
+	DrainDelay        time.Duration `mapstructure:"drain_delay"` // wait after readiness turns "draining" before shutdown
+
+	// TrustedProxies are proxies whose X-Forwarded-For/X-Real-IP headers are
+	// honored: IPs, CIDRs or the presets "private", "loopback", "cloudflare".
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+
+	// TimeZone is the IANA location API times are rendered in and client
+	// times without an offset are read in (default: UTC); TimeFormat is the
+	// JSON format of utils.Time: rfc3339 (default), unix, unix_milli or a
+	// Go time layout.
+	TimeZone   string `mapstructure:"timezone"`
+	TimeFormat string `mapstructure:"time_format"`
+
+	TLS TLSConfig `mapstructure:"tls"`
+}
+
+// TLSConfig contains TLS termination settings.
+// Either CertFile/KeyFile or Autocert may be used.
+type TLSConfig struct {
+	CertFile string         `mapstructure:"cert_file"`
+	KeyFile  string         `mapstructure:"key_file"`
+	Reload   bool           `mapstructure:"reload"` // reload cert_file and key_file when they change
+	Autocert AutocertConfig `mapstructure:"autocert"`
+
+	// ClientCAFile enables mutual TLS: client certificates are verified
+	// against the CAs of this PEM file.
+	ClientCAFile string `mapstructure:"client_ca_file"`
+	// ClientAuth is "require" (default with client_ca_file: clients
+	// without a valid certificate are refused) or "request" (clients may
+	// send one).
+	ClientAuth string `mapstructure:"client_auth"`
+	// ClientSANs allowlists the SANs (DNS names, URIs, emails or IPs) of
+	// client certificates; a trailing * matches any suffix. Empty admits
+	// every certificate of the CAs.
+	ClientSANs []string `mapstructure:"client_sans"`
+}
+
+// AutocertConfig contains ACME (Let's Encrypt) certificate settings.
+type AutocertConfig struct {
+	Enabled    bool     `mapstructure:"enabled"`
+	Hosts      []string `mapstructure:"hosts"`        // host allowlist
+	CacheDir   string   `mapstructure:"cache_dir"`    // certificate cache directory
+	Email      string   `mapstructure:"email"`        // ACME account contact
+	AllowInDev bool     `mapstructure:"allow_in_dev"` // explicit override to run autocert when env is dev
 }
 
 // DatabaseConfig contains database connection settings.
 // Supports mysql, postgres, and sqlite drivers.
 type DatabaseConfig struct {
-	Driver   string `mapstructure:"driver"`   // mysql, postgres, sqlite
-	Host     string `mapstructure:"host"`     // mysql/postgres: server host
-	Port     int    `mapstructure:"port"`     // mysql/postgres: server port
-	User     string `mapstructure:"user"`     // mysql/postgres: username
-	Password string `mapstructure:"password"` // mysql/postgres: password
-	Database string `mapstructure:"database"` // database name or sqlite file path
+	Driver   string `mapstructure:"driver"`                    // mysql, postgres, sqlite
+	Host     string `mapstructure:"host"`                      // mysql/postgres: server host
+	Port     int    `mapstructure:"port"`                      // mysql/postgres: server port
+	User     string `mapstructure:"user"`                      // mysql/postgres: username
+	Password string `mapstructure:"password" sensitive:"true"` // mysql/postgres: password
+	Database string `mapstructure:"database"`                  // database name or sqlite file path
 
 	// MySQL specific
 	Charset string `mapstructure:"charset"` // mysql: character set (default: utf8mb4)
@@ -60,25 +138,118 @@ type DatabaseConfig struct {
 	MaxOpenConns    int    `mapstructure:"max_open_conns"`     // max open connections in pool
 	MaxLifetime     int    `mapstructure:"max_lifetime"`       // max connection lifetime in seconds
 	ConnMaxIdleTime int    `mapstructure:"conn_max_idle_time"` // max connection idle time in seconds
+
+	// ValidateIdle pings mysql/postgres connections idle longer than this
+	// before reusing them, replacing dead ones transparently, e.g. those a
+	// load balancer dropped (0 disables)
+	ValidateIdle time.Duration `mapstructure:"validate_idle"`
+
+	// DefaultQueryTimeout bounds queries whose context has no deadline (0 disables)
+	DefaultQueryTimeout time.Duration `mapstructure:"default_query_timeout"`
+
+	// SlowQuery logs statements slower than a threshold
+	SlowQuery SlowQueryConfig `mapstructure:"slow_query"`
+
+	// RequestStats counts the statements of each HTTP request
+	RequestStats RequestStatsConfig `mapstructure:"request_stats"`
+
+	// Sqlite holds the pragmas of sqlite databases
+	Sqlite SqliteConfig `mapstructure:"sqlite"`
+
+	// TranslateErrors opens the connection with gorm's TranslateError, so
+	// queries return gorm.ErrDuplicatedKey and the other gorm errors
+	// instead of the driver's; db.TranslateError codes both either way
+	TranslateErrors bool `mapstructure:"translate_errors"`
+}
+
+// SqliteConfig holds the pragmas set on each sqlite connection (see
+// db.NewDialector). The defaults suit a service writing from several
+// goroutines: WAL, so readers do not block the writer, and a busy timeout,
+// so writers wait for each other instead of failing with "database is
+// locked".
+type SqliteConfig struct {
+	JournalMode   string `mapstructure:"journal_mode"`    // wal (default), delete, truncate, persist, memory or off; other than wal limits the pool to one connection
+	BusyTimeoutMS int    `mapstructure:"busy_timeout_ms"` // wait for locks up to this (default: 5000)
+	ForeignKeys   *bool  `mapstructure:"foreign_keys"`    // enforce foreign keys (default: true)
+	CacheSize     int    `mapstructure:"cache_size"`      // page cache in pages, or in KiB when negative (default: sqlite's)
+	Synchronous   string `mapstructure:"synchronous"`     // off, normal, full or extra (default: normal)
+}
+
+// SlowQueryConfig configures the slow-query log (see db.EnableSlowQueryLog).
+type SlowQueryConfig struct {
+	Threshold time.Duration `mapstructure:"threshold"` // log statements slower than this (0 disables)
+	// Explain logs the plan of slow statements; only honored when
+	// system.env is "dev".
+	Explain         bool          `mapstructure:"explain"`
+	ExplainTimeout  time.Duration `mapstructure:"explain_timeout"`  // bound of each EXPLAIN (default: 2s)
+	ExplainInterval time.Duration `mapstructure:"explain_interval"` // per-fingerprint EXPLAIN interval (default: 10m)
+}
+
+// RequestStatsConfig configures the per-request database statistics (see
+// middleware.DBStats).
+type RequestStatsConfig struct {
+	Enabled bool `mapstructure:"enabled"` // count statements and database time per request, on the access log
+	// RepeatThreshold warns about requests running one statement at least
+	// this many times, likely N+1 queries (0 disables)
+	RepeatThreshold int `mapstructure:"repeat_threshold"`
 }
 
 // RedisConfig contains Redis connection settings.
 type RedisConfig struct {
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
-	Password string `mapstructure:"password"`
+	Password string `mapstructure:"password" sensitive:"true"`
 	DB       int    `mapstructure:"database"`
-	Database int    `mapstructure:"database"` // Alias for DB
-	PoolSize int    `mapstructure:"pool_size"`
+	// Database is an alias for DB, used by db.NewRedis when DB is zero.
+	//
+	// Deprecated: use DB.
+	Database int `mapstructure:"database"`
+	PoolSize int `mapstructure:"pool_size"`
+
+	MinIdleConns    int           `mapstructure:"min_idle_conns"`     // idle connections kept open
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"` // default 30m (driver); keep below load balancer idle timeouts
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`  // 0 keeps connections open
+
+	// DefaultQueryTimeout bounds commands whose context has no deadline (0 disables)
+	DefaultQueryTimeout time.Duration `mapstructure:"default_query_timeout"`
 }
 
 // MongoConfig contains MongoDB connection settings.
 type MongoConfig struct {
+	// URI is a full connection string (mongodb:// or mongodb+srv://, e.g.
+	// for Atlas) used instead of Host, Port, User and Password.
+	URI      string `mapstructure:"uri" sensitive:"true"`
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
 	User     string `mapstructure:"user"`
-	Password string `mapstructure:"password"`
+	Password string `mapstructure:"password" sensitive:"true"`
 	Database string `mapstructure:"database"`
+
+	MaxPoolSize            uint64        `mapstructure:"max_pool_size"`            // default 100 (driver)
+	MinPoolSize            uint64        `mapstructure:"min_pool_size"`            // connections kept open
+	ConnectTimeout         time.Duration `mapstructure:"connect_timeout"`          // default 30s (driver)
+	ServerSelectionTimeout time.Duration `mapstructure:"server_selection_timeout"` // default 30s (driver); also bounds health pings
+	ReadPreference         string        `mapstructure:"read_preference"`          // primary, primaryPreferred, secondary, secondaryPreferred, nearest
+
+	WriteConcern MongoWriteConcern `mapstructure:"write_concern"`
+	TLS          MongoTLSConfig    `mapstructure:"tls"`
+}
+
+// MongoWriteConcern contains the default write concern of a MongoDB
+// client. Zero values keep the server default.
+type MongoWriteConcern struct {
+	W        string        `mapstructure:"w"`        // "majority" or a number of nodes
+	Journal  *bool         `mapstructure:"journal"`  // wait for the on-disk journal
+	WTimeout time.Duration `mapstructure:"wtimeout"` // bound on replication acknowledgment
+}
+
+// MongoTLSConfig contains MongoDB client TLS settings.
+type MongoTLSConfig struct {
+	Enabled            bool   `mapstructure:"enabled"`
+	CAFile             string `mapstructure:"ca_file"`              // PEM roots; default system pool
+	CertFile           string `mapstructure:"cert_file"`            // client certificate (X.509 auth)
+	KeyFile            string `mapstructure:"key_file"`             // client key
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"` // never in production
 }
 
 // KafkaConfig contains Kafka connection settings.
@@ -94,6 +265,9 @@ type LogConfig struct {
 	Format string        `mapstructure:"format"` // json, text, color
 	Output string        `mapstructure:"output"` // stdout, stderr
 	File   LogFileConfig `mapstructure:"file"`
+	// SilenceDeprecations stops the warnings of deprecated kit APIs (see
+	// log.DeprecationWarn), e.g. in CI.
+	SilenceDeprecations bool `mapstructure:"silence_deprecations"`
 }
 
 // LogFileConfig contains file logging settings.
@@ -108,18 +282,33 @@ type LogFileConfig struct {
 
 // JWTConfig contains JWT settings.
 type JWTConfig struct {
-	Secret    string        `mapstructure:"secret"`
+	Secret    string        `mapstructure:"secret" sensitive:"true"`
 	Expire    time.Duration `mapstructure:"expire"`
-	SkipPaths []string      `mapstructure:"skip_paths"`
+	SkipPaths []string      `mapstructure:"skip_paths"` // "/exact" or "/prefix/*", or patterns with skip_patterns
 	Enabled   bool          `mapstructure:"enabled"`
+	// SkipPatterns matches skip_paths with the pattern syntax of
+	// middleware.PathMatcher ("/users/:id", "POST /webhooks/*") instead
+	// of exactly or by prefix. Off by default: "/users/:id" also skips
+	// authentication for a literal "/users/me" route.
+	SkipPatterns bool `mapstructure:"skip_patterns"`
+
+	// SecretFile holds the secret instead of Secret, reloaded when it
+	// changes; the previous secret keeps validating for KeyOverlap.
+	SecretFile string        `mapstructure:"secret_file"`
+	KeyOverlap time.Duration `mapstructure:"key_overlap"` // default 5m
 }
 
-// CORSConfig contains CORS settings.
+// CORSConfig contains CORS settings. Origins may hold a wildcard, as in
+// "https://*.example.com".
 type CORSConfig struct {
 	AllowOrigins     []string `mapstructure:"allow_origins"`
 	AllowMethods     []string `mapstructure:"allow_methods"`
 	AllowHeaders     []string `mapstructure:"allow_headers"`
 	AllowCredentials bool     `mapstructure:"allow_credentials"`
+	// Groups override the settings for the paths below their key, e.g.
+	// "/api/admin"; the longest matching prefix wins. Their own Groups
+	// are ignored.
+	Groups map[string]CORSConfig `mapstructure:"groups"`
 }
 
 // CasbinConfig contains Casbin settings.
@@ -127,8 +316,407 @@ type CasbinConfig struct {
 	Model      string   `mapstructure:"model"`
 	ModelFile  string   `mapstructure:"model_file"`
 	Enabled    bool     `mapstructure:"enabled"`
-	SkipPaths  []string `mapstructure:"skip_paths"`
+	SkipPaths  []string `mapstructure:"skip_paths"` // "/exact" or "/prefix/*", or patterns with skip_patterns
 	AdminUsers []string `mapstructure:"admin_users"`
+	// SkipPatterns matches skip_paths with the pattern syntax of
+	// middleware.PathMatcher ("/users/:id", "POST /webhooks/*") instead
+	// of exactly or by prefix. Off by default: "/users/:id" also skips
+	// authorization for a literal "/users/me" route.
+	SkipPatterns bool `mapstructure:"skip_patterns"`
+	// Unannotated is the enforcement of routes without a declared
+	// permission: "path" (default), "deny" or "allow".
+	Unannotated string `mapstructure:"unannotated"`
+	// DecisionCache caches enforcement results (see
+	// middleware.DecisionCache).
+	DecisionCache CasbinDecisionCacheConfig `mapstructure:"decision_cache"`
+}
+
+// CasbinDecisionCacheConfig configures the cache of Casbin enforcement
+// results. Policy changes through the admin API clear it; TTL bounds the
+// staleness of changes made otherwise.
+//
+//	casbin:
+//	  decision_cache:
+//	    enabled: true
+//	    size: 10000
+//	    ttl: 5s
+type CasbinDecisionCacheConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Size    int           `mapstructure:"size"` // entries; default 10000
+	TTL     time.Duration `mapstructure:"ttl"`  // default 5s
+}
+
+// IPFilterConfig contains IP allow/deny lists (IPs or CIDRs).
+type IPFilterConfig struct {
+	Allow          []string `mapstructure:"allow"`           // empty allows all not denied
+	Deny           []string `mapstructure:"deny"`            // evaluated before Allow
+	TrustedProxies []string `mapstructure:"trusted_proxies"` // peers whose forwarding headers are honored
+}
+
+// AdminConfig configures the internal admin server (see adminserver):
+//
+//	admin:
+//	  enabled: true
+//	  port: 9090
+//	  token: ${ADMIN_TOKEN}
+//	  allow_cidrs: [10.0.0.0/8]
+//	  features:
+//	    pprof: false
+type AdminConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Host    string `mapstructure:"host"` // listen host (default: 127.0.0.1)
+	Port    string `mapstructure:"port"` // default: 9090
+	// Token is required as a bearer token when set; AllowCIDRs restricts
+	// the peers (forwarding headers are ignored). Both apply when set.
+	// Without either the server only listens on loopback.
+	Token      string   `mapstructure:"token" sensitive:"true"`
+	AllowCIDRs []string `mapstructure:"allow_cidrs"`
+	// Features switches registered features off by name (health, metrics,
+	// config, log_level, logs, pprof, errors, casbin, cache_warmup);
+	// unlisted features are on.
+	Features map[string]bool `mapstructure:"features"`
+}
+
+// SecretsConfig configures the secret providers resolving references in
+// password fields (see SecretResolver):
+//
+//	database:
+//	  password: vault:secret/data/db#password
+//	secrets:
+//	  vault:
+//	    address: https://vault.internal:8200
+//	    kubernetes_role: orders
+type SecretsConfig struct {
+	// CacheTTL is how long a resolved secret is reused; default 1m. A
+	// failed connection drops it sooner.
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+	Vault    VaultConfig   `mapstructure:"vault"`
+}
+
+// VaultConfig configures the HashiCorp Vault secret provider (KV v2). It
+// authenticates with Token, else with the Kubernetes auth method when
+// KubernetesRole is set.
+type VaultConfig struct {
+	Address   string        `mapstructure:"address"`   // e.g. https://vault:8200; empty disables the provider
+	Namespace string        `mapstructure:"namespace"` // Vault Enterprise namespace
+	Token     string        `mapstructure:"token" sensitive:"true"`
+	Timeout   time.Duration `mapstructure:"timeout"` // per request; default 10s
+
+	KubernetesRole      string `mapstructure:"kubernetes_role"`
+	KubernetesMount     string `mapstructure:"kubernetes_mount"`      // auth mount path; default "kubernetes"
+	KubernetesTokenPath string `mapstructure:"kubernetes_token_path"` // default the service account token
+}
+
+// StorageConfig configures the object store (see storage.New): a
+// directory on local disk or an S3-compatible bucket.
+//
+//	storage:
+//	  driver: s3
+//	  s3:
+//	    endpoint: minio:9000
+//	    bucket: app
+//	    access_key: app
+//	    secret_key: vault:secret/data/minio#secret_key
+//	    insecure: true
+//	    path_style: true
+type StorageConfig struct {
+	Driver string             `mapstructure:"driver"` // local (default) or s3
+	Local  LocalStorageConfig `mapstructure:"local"`
+	S3     S3Config           `mapstructure:"s3"`
+}
+
+// LocalStorageConfig configures the local disk store. Presigned URLs need
+// BaseURL, the public URL its handler is mounted at, and SigningKey.
+type LocalStorageConfig struct {
+	Dir        string `mapstructure:"dir"`
+	BaseURL    string `mapstructure:"base_url"`                     // e.g. https://api.example.com/files
+	SigningKey string `mapstructure:"signing_key" sensitive:"true"` // may be a secret reference
+}
+
+// S3Config configures the S3-compatible store. The keys may be secret
+// references (see SecretResolver).
+type S3Config struct {
+	Endpoint  string `mapstructure:"endpoint"` // host[:port], e.g. s3.amazonaws.com
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	AccessKey string `mapstructure:"access_key" sensitive:"true"`
+	SecretKey string `mapstructure:"secret_key" sensitive:"true"`
+	Insecure  bool   `mapstructure:"insecure"`   // plain HTTP
+	PathStyle bool   `mapstructure:"path_style"` // bucket in the path, as MinIO expects
+	PartSize  int64  `mapstructure:"part_size"`  // multipart chunk of uploads of unknown size; default 16MiB
+}
+
+// NotifyConfig configures outgoing notifications (see notify.New): the
+// providers, tried in order until one accepts a message, and a rate limit
+// per recipient.
+//
+//	notify:
+//	  rate_limit: 5
+//	  rate_window: 1h
+//	  providers:
+//	    - name: ses
+//	      type: smtp
+//	      channels: [email]
+//	      smtp:
+//	        host: email-smtp.eu-west-1.amazonaws.com
+//	        port: 587
+//	        username: ${SES_USER}
+//	        password: vault:secret/data/ses#password
+//	        from: "Example <no-reply@example.com>"
+//	    - name: sms-gateway
+//	      type: webhook
+//	      channels: [sms]
+//	      webhook:
+//	        url: https://sms.internal/send
+//	        headers:
+//	          Authorization: Bearer ${SMS_TOKEN}
+//	      max_attempts: 5
+type NotifyConfig struct {
+	Providers  []NotifyProviderConfig `mapstructure:"providers"`
+	RateLimit  int                    `mapstructure:"rate_limit"`  // messages per recipient and channel per RateWindow; 0 is unlimited
+	RateWindow time.Duration          `mapstructure:"rate_window"` // default 1h
+}
+
+// NotifyProviderConfig configures a notification provider.
+type NotifyProviderConfig struct {
+	Name     string   `mapstructure:"name"`
+	Type     string   `mapstructure:"type"`     // smtp or webhook
+	Channels []string `mapstructure:"channels"` // email, sms, ...; empty handles every channel
+
+	SMTP    SMTPConfig          `mapstructure:"smtp"`
+	Webhook NotifyWebhookConfig `mapstructure:"webhook"`
+
+	// Retries of the provider before failing over; zero values use
+	// utils.DefaultRetryPolicy.
+	MaxAttempts    int           `mapstructure:"max_attempts"`
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+}
+
+// SMTPConfig configures an SMTP server. Password may be a secret
+// reference.
+type SMTPConfig struct {
+	Host     string        `mapstructure:"host"`
+	Port     int           `mapstructure:"port"` // default 587
+	Username string        `mapstructure:"username"`
+	Password string        `mapstructure:"password" sensitive:"true"`
+	From     string        `mapstructure:"from"` // default sender address
+	TLS      string        `mapstructure:"tls"`  // starttls (default), tls or none
+	Timeout  time.Duration `mapstructure:"timeout"`
+}
+
+// NotifyWebhookConfig configures a provider receiving messages as JSON
+// over HTTP, such as an SMS gateway.
+type NotifyWebhookConfig struct {
+	URL     string            `mapstructure:"url"`
+	Headers map[string]string `mapstructure:"headers" sensitive:"true"`
+	Timeout time.Duration     `mapstructure:"timeout"`
+}
+
+// ConcurrencyConfig limits in-flight requests per route template or named
+// group. A limit of 0 means unlimited.
+//
+//	concurrency:
+//	  default: 200
+//	  max_queue: 50
+//	  queue_timeout: 500ms
+//	  limits:
+//	    /api/reports/:id: 4
+//	    exports: 2
+type ConcurrencyConfig struct {
+	Default      int            `mapstructure:"default"`       // limit for keys not in Limits
+	Limits       map[string]int `mapstructure:"limits"`        // per route template or group name
+	MaxQueue     int            `mapstructure:"max_queue"`     // waiters per key; 0 rejects immediately
+	QueueTimeout time.Duration  `mapstructure:"queue_timeout"` // max wait for a slot (default 1s)
+}
+
+// BindConfig hardens the binding of request bodies by the binder of
+// middleware.Setup (see validator.CheckJSON). Zero limits use the
+// validator defaults.
+//
+//	bind:
+//	  strict_json: true
+//	  max_depth: 32
+//	  max_string_length: 65536
+//	  max_tokens: 10000
+//	  decode_timeout: 1s
+type BindConfig struct {
+	StrictJSON      bool          `mapstructure:"strict_json"`       // reject duplicate keys and bodies beyond the limits
+	MaxDepth        int           `mapstructure:"max_depth"`         // deepest nesting of objects and arrays
+	MaxStringLength int           `mapstructure:"max_string_length"` // longest string, in encoded bytes
+	MaxTokens       int           `mapstructure:"max_tokens"`        // most keys and values per body
+	DecodeTimeout   time.Duration `mapstructure:"decode_timeout"`    // within the request deadline
+}
+
+// MaintenanceConfig declares maintenance mode (see middleware.Maintenance):
+// a manual switch and weekly windows during which requests are refused.
+//
+//	maintenance:
+//	  message: Scheduled maintenance
+//	  windows:
+//	    - days: [sunday]
+//	      start: "02:00"
+//	      duration: 30m
+//	      time_zone: Asia/Shanghai
+type MaintenanceConfig struct {
+	Enabled bool                      `mapstructure:"enabled"` // manual switch, on until turned off
+	Message string                    `mapstructure:"message"` // error message of refused requests
+	Windows []MaintenanceWindowConfig `mapstructure:"windows"`
+}
+
+// MaintenanceWindowConfig is a weekly maintenance window.
+type MaintenanceWindowConfig struct {
+	Days     []string      `mapstructure:"days"`      // weekday names (sunday or sun); empty means every day
+	Start    string        `mapstructure:"start"`     // local start time, HH:MM
+	Duration time.Duration `mapstructure:"duration"`  // at most a week
+	TimeZone string        `mapstructure:"time_zone"` // IANA name; default UTC
+}
+
+// weekdays maps the weekday names of MaintenanceWindowConfig.Days.
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// ParseWeekday parses a weekday name of MaintenanceWindowConfig.Days, such
+// as "sunday" or "Sun".
+func ParseWeekday(name string) (time.Weekday, error) {
+	d, ok := weekdays[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("unknown weekday %q", name)
+	}
+	return d, nil
+}
+
+// ServiceAuthConfig configures the authentication of internal calls
+// between services with short-lived signed service tokens (see package
+// serviceauth and middleware.ServiceAuth):
+//
+//	service_auth:
+//	  enabled: true
+//	  service: orders
+//	  algorithm: ed25519
+//	  private_key_file: /etc/keys/orders.pem
+//	  public_key_files:
+//	    billing: /etc/keys/billing.pub.pem
+//	  allowed_services: [billing]
+type ServiceAuthConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Service is the name of this service: the issuer of the tokens it
+	// mints and the audience of the tokens it accepts.
+	Service   string `mapstructure:"service"`
+	Algorithm string `mapstructure:"algorithm"`               // hs256 (default) or ed25519
+	Secret    string `mapstructure:"secret" sensitive:"true"` // hs256: secret shared by the services
+	// PrivateKeyFile is the PKCS#8 PEM Ed25519 key signing the tokens of
+	// this service; PublicKeyFiles the PEM public key of each calling
+	// service.
+	PrivateKeyFile string            `mapstructure:"private_key_file"`
+	PublicKeyFiles map[string]string `mapstructure:"public_key_files"`
+	TokenTTL       time.Duration     `mapstructure:"token_ttl"` // default 5m
+	Leeway         time.Duration     `mapstructure:"leeway"`    // clock skew tolerated; default 30s
+	// AllowedServices are the callers accepted; empty accepts every
+	// caller with a valid token.
+	AllowedServices []string `mapstructure:"allowed_services"`
+	// ClientCert is how verified client certificates (see
+	// TLSConfig.ClientCAFile) count: "none" (default), "alternative" to a
+	// token or "required" in addition to it.
+	ClientCert string `mapstructure:"client_cert"`
+	// CertServices maps client certificate SANs to service names; by
+	// default the service is the SAN.
+	CertServices map[string]string `mapstructure:"cert_services"`
+}
+
+// FaultInjectionConfig configures the faults injected for resilience
+// testing (see package faultinject). It is refused when system.env is
+// "prod". The first rule matching a target applies:
+//
+//	fault_injection:
+//	  enabled: true
+//	  http:
+//	    - target: /api/orders/:id
+//	      latency: 2s
+//	      latency_rate: 0.2
+//	      error_rate: 0.05
+//	      status: 503
+//	      reset_rate: 0.01
+//	  cache:
+//	    - target: get
+//	      error_rate: 0.1
+//	  database:
+//	    - latency: 500ms
+//	      latency_rate: 0.1
+type FaultInjectionConfig struct {
+	Enabled  bool              `mapstructure:"enabled" json:"enabled"`
+	HTTP     []FaultRuleConfig `mapstructure:"http" json:"http"`
+	Cache    []FaultRuleConfig `mapstructure:"cache" json:"cache"`
+	Database []FaultRuleConfig `mapstructure:"database" json:"database"`
+}
+
+// FaultRuleConfig is a fault injection rule. Rates are fractions (0..1)
+// of the matched operations.
+type FaultRuleConfig struct {
+	// Target is the route template of HTTP rules, the operation of cache
+	// rules (get, set, delete, exists) and of database rules (create,
+	// query, update, delete, raw, row); empty matches every target.
+	Target      string        `mapstructure:"target" json:"target,omitempty"`
+	Latency     time.Duration `mapstructure:"latency" json:"latency,omitempty"`
+	LatencyRate float64       `mapstructure:"latency_rate" json:"latency_rate,omitempty"`
+	ErrorRate   float64       `mapstructure:"error_rate" json:"error_rate,omitempty"`
+	Status      int           `mapstructure:"status" json:"status,omitempty"`         // HTTP errors: 500 or 503 (default)
+	ResetRate   float64       `mapstructure:"reset_rate" json:"reset_rate,omitempty"` // HTTP only: connection resets
+}
+
+// Validate checks r as a rule of kind: "http", "cache" or "database".
+func (r FaultRuleConfig) Validate(kind string) error {
+	for _, rate := range []struct {
+		name  string
+		value float64
+	}{{"latency_rate", r.LatencyRate}, {"error_rate", r.ErrorRate}, {"reset_rate", r.ResetRate}} {
+		if rate.value < 0 || rate.value > 1 {
+			return fmt.Errorf("%s must be between 0 and 1, got %g", rate.name, rate.value)
+		}
+	}
+	if r.ErrorRate+r.ResetRate > 1 {
+		return fmt.Errorf("error_rate and reset_rate add up to more than 1")
+	}
+	if r.Latency < 0 {
+		return fmt.Errorf("latency must not be negative")
+	}
+	if r.LatencyRate > 0 && r.Latency == 0 {
+		return fmt.Errorf("latency_rate requires a latency")
+	}
+	if kind != "http" && (r.Status != 0 || r.ResetRate != 0) {
+		return fmt.Errorf("status and reset_rate apply to http rules only")
+	}
+	if r.Status != 0 && r.Status != 500 && r.Status != 503 {
+		return fmt.Errorf("status must be 500 or 503, got %d", r.Status)
+	}
+	return nil
+}
+
+// ClientVersionConfig sets the minimum app versions of clients (see
+// middleware.ClientVersion). Versions are semantic versions; an empty
+// minimum admits every version.
+//
+//	client_version:
+//	  minimum: 2.0.0
+//	  missing: deny
+//	  routes:
+//	    POST /api/orders: 2.3.0
+//	    /api/legacy/*: 1.0.0
+//	  upgrade_url: https://example.com/download
+//	  upgrade_urls:
+//	    ios: https://apps.apple.com/app/id000000000
+type ClientVersionConfig struct {
+	Minimum     string            `mapstructure:"minimum"`      // for routes not in Routes
+	Routes      map[string]string `mapstructure:"routes"`       // route pattern (see middleware.PathMatcher) -> minimum
+	Missing     string            `mapstructure:"missing"`      // requests without a valid version: allow (default) or deny
+	UpgradeURL  string            `mapstructure:"upgrade_url"`  // returned to rejected clients
+	UpgradeURLs map[string]string `mapstructure:"upgrade_urls"` // by platform, over UpgradeURL
 }
 
 // OtelConfig contains OpenTelemetry settings.
@@ -137,11 +725,40 @@ type OtelConfig struct {
 	ServiceName   string  `mapstructure:"service_name"`   // 服务名称
 	Environment   string  `mapstructure:"environment"`    // 环境标识: dev, test, prod
 	OTLPEndpoint  string  `mapstructure:"otlp_endpoint"`  // OTLP gRPC 端点地址
+	Insecure      bool    `mapstructure:"insecure"`       // OTLP 不使用 TLS
 	ExporterType  string  `mapstructure:"exporter_type"`  // 导出器类型: otlp, stdout
 	SamplingRatio float64 `mapstructure:"sampling_ratio"` // 采样率: 0.0 - 1.0
 }
 
-// BaseConfig is an alias for Config for backward compatibility.
+// FeatureFlag declares a feature flag. Allowlisted users and tenants always
+// get the feature; otherwise Percentage (1-100) rolls it out to a stable
+// share of users, and Enabled applies when no percentage is set.
+//
+//	features:
+//	  new_checkout:
+//	    percentage: 20
+//	    tenants: [acme]
+type FeatureFlag struct {
+	Enabled    bool     `mapstructure:"enabled" json:"enabled"`
+	Percentage int      `mapstructure:"percentage" json:"percentage,omitempty"`
+	Users      []string `mapstructure:"users" json:"users,omitempty"`
+	Tenants    []string `mapstructure:"tenants" json:"tenants,omitempty"`
+}
+
+// ProfileConfig overrides defaults of the environment profile (see
+// kit.Profile). Unset values keep the profile default.
+type ProfileConfig struct {
+	DebugRoutes         *bool    `mapstructure:"debug_routes"`
+	AccessLogSampleRate *float64 `mapstructure:"access_log_sample_rate"` // 0..1, failed requests are always logged
+	VerboseRecovery     *bool    `mapstructure:"verbose_recovery"`
+	DebugErrors         *bool    `mapstructure:"debug_errors"` // error text and stack in responses; dev only
+	RuntimeMetrics      *bool    `mapstructure:"runtime_metrics"`
+}
+
+// BaseConfig is an alias for Config for backward compatibility. Aliases
+// cannot be detected at run time, so its uses are not reported.
+//
+// Deprecated: use Config.
 type BaseConfig = Config
 
 // Source abstracts configuration loading from various sources.
@@ -160,6 +777,12 @@ func Load[T any](path string) T {
 	return LoadFrom(FileSource[T]{Path: path})
 }
 
+// LoadStrict loads configuration from the given path, panicking on unknown
+// keys and type mismatches (see FileSource.Strict).
+func LoadStrict[T any](path string) T {
+	return LoadFrom(FileSource[T]{Path: path, Strict: true})
+}
+
 // LoadFrom loads configuration from a custom source.
 func LoadFrom[T any](src Source[T]) T {
 	c, err := src.Load(context.Background())
@@ -169,24 +792,229 @@ func LoadFrom[T any](src Source[T]) T {
 	return c
 }
 
+// LoadWithOverrides loads configuration from path and applies explicit
+// overrides on top. Keys use the dotted config path (e.g. "system.port").
+//
+// Precedence (lowest to highest): file < dotenv < environment < overrides.
+//
+// Usage:
+//
+//	cfg := config.LoadWithOverrides[config.Config]("config.toml", config.ParseOverrideArgs(os.Args[1:]))
+func LoadWithOverrides[T any](path string, overrides map[string]string) T {
+	return LoadFrom(FileSource[T]{Path: path, Overrides: overrides})
+}
+
 // FileSource loads configuration from a local file.
 type FileSource[T any] struct {
 	Path string
+	// Overrides are applied after file and environment resolution.
+	// Values are coerced to the destination field type (ints, bools,
+	// durations, comma-separated string slices).
+	Overrides map[string]string
+	// Strict fails loading when the file has keys that map to no field
+	// (e.g. a typo like "databse") or values of the wrong type, listing
+	// every offending key path.
+	Strict bool
+	// Dotenv lists .env files whose KEY=VALUE pairs act as environment
+	// variables (SYSTEM_PORT overrides system.port) without overriding the
+	// real environment. Later files win; missing files are skipped. See
+	// ReadDotenv for the syntax.
+	Dotenv []string
+	// Profile selects the block of the file's top-level "profiles" map
+	// merged over the rest of the file (see ProfileEnvVar). Default: the
+	// ProfileEnvVar variable, else system.env.
+	Profile string
+	// Debounce is the quiet period Watch waits for after a change before
+	// reloading, so the events of one save cause one reload; default 300ms.
+	Debounce time.Duration
 }
 
+// ProfileEnvVar is the environment variable selecting the profile block
+// of a config file when FileSource.Profile is unset:
+//
+//	system:
+//	  name: orders
+//	  env: dev
+//	database:
+//	  host: localhost
+//	profiles:
+//	  prod:
+//	    system:
+//	      env: prod
+//	    database:
+//	      host: db.internal
+//
+// The selected block is deep-merged over the rest of the file before
+// environment variables and overrides apply. Selecting a profile the file
+// does not define is an error, except through system.env, where the base
+// is used as is.
+const ProfileEnvVar = "APP_PROFILE"
+
 // Load loads configuration from file.
 func (f FileSource[T]) Load(ctx context.Context) (T, error) {
+	c, _, err := f.load()
+	return c, err
+}
+
+// load is Load also returning the files read (see Files).
+func (f FileSource[T]) load() (T, []string, error) {
 	var zero T
 	if f.Path == "" {
-		return zero, nil
+		return zero, nil, nil
 	}
 
+	v, files, err := f.readFiles()
+	if err != nil {
+		return zero, nil, err
+	}
+
+	// Strict checks only see the file; environment and override values are
+	// strings and keep being coerced.
+	if f.Strict {
+		if err := checkStrict[T](v); err != nil {
+			return zero, nil, fmt.Errorf("%s: %w", f.Path, err)
+		}
+	}
+
+	if err := f.applyOverrides(v); err != nil {
+		return zero, nil, err
+	}
+
+	var c T
+	if err := v.Unmarshal(&c); err != nil {
+		return zero, nil, err
+	}
+
+	return c, files, nil
+}
+
+// Files returns the files the configuration is read from: Path, then the
+// files it includes in resolution order (see IncludeKey).
+func (f FileSource[T]) Files() ([]string, error) {
+	if f.Path == "" {
+		return nil, nil
+	}
+	_, files, err := f.readFiles()
+	return files, err
+}
+
+// read reads the file into a new viper instance.
+func (f FileSource[T]) read() (*viper.Viper, error) {
+	v, _, err := f.readFiles()
+	return v, err
+}
+
+// readFiles reads the file and its includes into a new viper instance,
+// with the active profile resolved, and returns the files read.
+func (f FileSource[T]) readFiles() (*viper.Viper, []string, error) {
+	r := includeResolver{seen: make(map[string]bool)}
+	settings, err := r.resolve(f.Path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	v := viper.New()
+	if err := v.MergeConfigMap(settings); err != nil {
+		return nil, nil, err
+	}
+	if v, err = f.resolveProfile(v); err != nil {
+		return nil, nil, err
+	}
+	return v, r.files, nil
+}
+
+// IncludeKey is the top-level key listing files merged under a config
+// file, relative to it:
+//
+//	include = ["database.toml", "logging.yaml"]
+//
+// Included files may include others and use any supported format. They
+// are merged in order, and the including file wins over all of them.
+const IncludeKey = "include"
+
+// includeResolver reads a config file and its includes.
+type includeResolver struct {
+	files []string
+	seen  map[string]bool
+}
+
+// resolve returns the settings of path merged over its includes. chain is
+// the files including path, outermost first.
+func (r *includeResolver) resolve(path string, chain []string) (map[string]any, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, parent := range chain {
+		if parentAbs, _ := filepath.Abs(parent); parentAbs == abs {
+			return nil, fmt.Errorf("config include cycle: %s", strings.Join(append(chain, path), " -> "))
+		}
+	}
+
+	settings, err := readSettings(path)
+	if err != nil {
+		if len(chain) > 0 && errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("config include %s not found (include chain: %s)", path, strings.Join(append(chain, path), " -> "))
+		}
+		return nil, err
+	}
+	if !r.seen[abs] {
+		r.seen[abs] = true
+		r.files = append(r.files, path)
+	}
+
+	includes, err := includeList(settings[IncludeKey])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	delete(settings, IncludeKey)
+
+	merged := make(map[string]any)
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+		sub, err := r.resolve(include, append(slices.Clone(chain), path))
+		if err != nil {
+			return nil, err
+		}
+		mergeSettings(merged, sub)
+	}
+	mergeSettings(merged, settings)
+	return merged, nil
+}
+
+// includeList returns the value of the include key as file paths.
+func includeList(value any) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []any:
+		paths := make([]string, len(v))
+		for i, item := range v {
+			path, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("include must be a list of file paths, got %T", item)
+			}
+			paths[i] = path
+		}
+		return paths, nil
+	}
+	return nil, fmt.Errorf("include must be a list of file paths, got %T", value)
+}
+
+// readSettings reads the config file at path, in the format of its
+// extension (TOML by default).
+func readSettings(path string) (map[string]any, error) {
 	v := viper.New()
 
 	// Set config type based on extension
 	ext := ""
-	if dot := strings.LastIndex(f.Path, "."); dot >= 0 {
-		ext = strings.ToLower(f.Path[dot+1:])
+	if dot := strings.LastIndex(path, "."); dot >= 0 {
+		ext = strings.ToLower(path[dot+1:])
 	}
 	switch ext {
 	case "json":
@@ -198,102 +1026,310 @@ func (f FileSource[T]) Load(ctx context.Context) (T, error) {
 	}
 
 	// Read file
-	content, err := os.ReadFile(f.Path)
+	content, err := os.ReadFile(path)
 	if err != nil {
-		return zero, err
+		return nil, err
 	}
 
 	if err := v.ReadConfig(bytes.NewBuffer(content)); err != nil {
-		return zero, err
+		return nil, fmt.Errorf("%s: %w", path, err)
 	}
+	return v.AllSettings(), nil
+}
 
+// resolveProfile returns v with its "profiles" key removed and the active
+// profile block merged over it.
+func (f FileSource[T]) resolveProfile(v *viper.Viper) (*viper.Viper, error) {
+	if !v.IsSet("profiles") {
+		return v, nil
+	}
+	profiles, ok := v.Get("profiles").(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: profiles must be a map of profile names to config blocks", f.Path)
+	}
+
+	name, requested := f.Profile, true
+	if name == "" {
+		name = os.Getenv(ProfileEnvVar)
+	}
+	if name == "" {
+		requested = false
+		if name = os.Getenv("SYSTEM_ENV"); name == "" {
+			name = v.GetString("system.env")
+		}
+	}
+
+	settings := v.AllSettings()
+	delete(settings, "profiles")
+	if block, ok := profiles[strings.ToLower(name)]; ok {
+		overlay, ok := block.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: profile %q must be a config block", f.Path, name)
+		}
+		mergeSettings(settings, overlay)
+	} else if requested {
+		return nil, fmt.Errorf("%s: profile %q not found (defined: %s)", f.Path, name, strings.Join(slices.Sorted(maps.Keys(profiles)), ", "))
+	}
+
+	merged := viper.New()
+	if err := merged.MergeConfigMap(settings); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// mergeSettings deep-merges src into dst: nested maps are merged, other
+// values (including lists) replace dst's.
+func mergeSettings(dst, src map[string]any) {
+	for key, value := range src {
+		if sub, ok := value.(map[string]any); ok {
+			if base, ok := dst[key].(map[string]any); ok {
+				mergeSettings(base, sub)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
+
+// applyOverrides layers dotenv files, environment variables and explicit
+// overrides on v.
+func (f FileSource[T]) applyOverrides(v *viper.Viper) error {
 	// Support environment variable override
 	v.AutomaticEnv()
-	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.SetEnvKeyReplacer(envKeyReplacer)
 
-	var c T
-	if err := v.Unmarshal(&c); err != nil {
-		return zero, err
+	// Dotenv values stand in for environment variables that are not set
+	if len(f.Dotenv) > 0 {
+		vars, err := ReadDotenv(f.Dotenv...)
+		if err != nil {
+			return err
+		}
+		for _, key := range v.AllKeys() {
+			name := strings.ToUpper(envKeyReplacer.Replace(key))
+			if _, set := os.LookupEnv(name); set {
+				continue
+			}
+			if value, ok := vars[name]; ok {
+				v.Set(key, value)
+			}
+		}
 	}
 
-	return c, nil
+	// Explicit overrides win over file and environment
+	for key, value := range f.Overrides {
+		v.Set(key, value)
+	}
+	return nil
 }
 
-// Watch watches for file changes and calls onChange.
+// envKeyReplacer maps config keys to environment variable names.
+var envKeyReplacer = strings.NewReplacer(".", "_")
+
+// defaultDebounce is the default of FileSource.Debounce.
+const defaultDebounce = 300 * time.Millisecond
+
+// Watch watches the file and the files it includes and calls onChange with
+// the reloaded config, the includes and profile resolved again. Changes
+// are debounced (see Debounce) and reloads run one at a time: onChange
+// returns before the next reload starts. Reloads equal to the last config
+// are not notified. It stops when ctx is done.
 func (f FileSource[T]) Watch(ctx context.Context, onChange func(T)) error {
+	return f.watch(ctx, func(c T, _ []string) { onChange(c) })
+}
+
+// watch is Watch also passing the files read.
+func (f FileSource[T]) watch(ctx context.Context, onChange func(T, []string)) error {
 	if f.Path == "" {
 		return nil
 	}
+	last, files, err := f.load()
+	if err != nil {
+		return err
+	}
+	debounce := f.Debounce
+	if debounce <= 0 {
+		debounce = defaultDebounce
+	}
 
-	v := viper.New()
-	v.SetConfigFile(f.Path)
-	v.WatchConfig()
-	v.OnConfigChange(func(e fsnotify.Event) {
-		var c T
-		if err := v.Unmarshal(&c); err == nil {
-			onChange(c)
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// Directories are watched, so files replaced by editors keep being seen.
+	watched := make(map[string]bool)
+	dirs := make(map[string]bool)
+	watchFiles := func(files []string) {
+		for _, file := range files {
+			abs, err := filepath.Abs(file)
+			if err != nil {
+				continue
+			}
+			watched[abs] = true
+			if dir := filepath.Dir(abs); !dirs[dir] {
+				if err := w.Add(dir); err != nil {
+					slog.Warn("Config watch failed", slog.String("path", dir), slog.String("error", err.Error()))
+					continue
+				}
+				dirs[dir] = true
+			}
 		}
-	})
+	}
+	watchFiles(files)
 
+	context.AfterFunc(ctx, func() { _ = w.Close() })
+	lifecycle.Go(ctx, "config.watch", func(ctx context.Context) error {
+		var timer *time.Timer
+		var fire <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return nil
+			case event, ok := <-w.Events:
+				if !ok {
+					return nil
+				}
+				if !event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) || !watched[filepath.Clean(event.Name)] {
+					continue
+				}
+				if timer == nil {
+					timer = time.NewTimer(debounce)
+				} else {
+					timer.Reset(debounce)
+				}
+				fire = timer.C
+			case <-fire:
+				fire = nil
+				c, files, err := f.load()
+				if err != nil {
+					slog.Error("Config reload failed", slog.String("path", f.Path), slog.String("error", err.Error()))
+					continue
+				}
+				watchFiles(files)
+				if reflect.DeepEqual(c, last) {
+					continue
+				}
+				last = c
+				onChange(c, files)
+			case err, ok := <-w.Errors:
+				if !ok {
+					return nil
+				}
+				slog.Warn("Config watch error", slog.String("path", f.Path), slog.String("error", err.Error()))
+			}
+		}
+	})
 	return nil
 }
 
-// Store provides atomic read/update for configuration with hot-reload.
-type Store[T any] struct {
-	v    atomic.Value
-	mu   sync.RWMutex
-	subs map[string][]chan T
+// StrictMode selects strict loading in Bootstrap.
+type StrictMode int
+
+const (
+	// StrictAuto loads strictly unless system.env is "prod", so typos fail
+	// in development and CI without taking production down.
+	StrictAuto StrictMode = iota
+	// StrictOn always loads strictly.
+	StrictOn
+	// StrictOff never loads strictly.
+	StrictOff
+)
+
+// BootstrapOption configures Bootstrap.
+type BootstrapOption func(*bootstrapOptions)
+
+type bootstrapOptions struct {
+	strict   StrictMode
+	dotenv   []string
+	profile  string
+	debounce time.Duration
 }
 
-// NewStore creates a new configuration store.
-func NewStore[T any](initial T) *Store[T] {
-	s := &Store[T]{subs: make(map[string][]chan T)}
-	s.v.Store(initial)
-	return s
+// WithStrict controls unknown-key and type checking (see FileSource.Strict);
+// default StrictAuto.
+func WithStrict(mode StrictMode) BootstrapOption {
+	return func(o *bootstrapOptions) {
+		o.strict = mode
+	}
 }
 
-// Current returns the current configuration.
-func (s *Store[T]) Current() T {
-	c, _ := s.v.Load().(T)
-	return c
+// WithDotenv loads .env files as environment variables (see
+// FileSource.Dotenv). Without paths it loads ".env" and ".env.local", the
+// latter winning.
+func WithDotenv(paths ...string) BootstrapOption {
+	if len(paths) == 0 {
+		paths = []string{".env", ".env.local"}
+	}
+	return func(o *bootstrapOptions) {
+		o.dotenv = paths
+	}
 }
 
-// Update updates the configuration and notifies subscribers.
-func (s *Store[T]) Update(c T) {
-	s.v.Store(c)
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	for _, ch := range s.subs["*"] {
-		select {
-		case ch <- c:
-		default:
-		}
+// WithProfile selects the profile block of the config file (see
+// ProfileEnvVar).
+func WithProfile(name string) BootstrapOption {
+	return func(o *bootstrapOptions) {
+		o.profile = name
 	}
 }
 
-// Subscribe subscribes to configuration updates.
-func (s *Store[T]) Subscribe(key string) <-chan T {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	ch := make(chan T, 1)
-	s.subs[key] = append(s.subs[key], ch)
-	return ch
+// WithDebounce sets the quiet period of hot-reload (see
+// FileSource.Debounce).
+func WithDebounce(d time.Duration) BootstrapOption {
+	return func(o *bootstrapOptions) {
+		o.debounce = d
+	}
 }
 
-// Bootstrap loads configuration from file and sets up hot-reload.
-func Bootstrap[T any](path string) (T, *Store[T]) {
-	cfg := Load[T](path)
-	store := NewStore(cfg)
+// Bootstrap loads configuration from file and sets up hot-reload. When T
+// implements Checker (Config and configs embedding it do), the config is
+// checked first: warnings are logged and errors panic with the full report.
+//
+//	cfg, store := config.Bootstrap[AppConfig]("config.toml", config.WithDotenv())
+func Bootstrap[T any](path string, opts ...BootstrapOption) (T, *Store[T]) {
+	var o bootstrapOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	src := FileSource[T]{Path: path, Strict: o.strict == StrictOn, Dotenv: o.dotenv, Profile: o.profile}
+	if o.strict == StrictAuto && path != "" {
+		src.Strict = fileEnv(path, o.dotenv, o.profile) != "prod"
+	}
+
+	cfg := LoadFrom[T](src)
+	if checker, ok := any(cfg).(Checker); ok {
+		problems := checker.CheckConfig()
+		for _, p := range problems {
+			if p.Severity == SeverityWarn {
+				slog.Warn("Config check", slog.String("key", p.Key), slog.String("problem", p.Message))
+			}
+		}
+		if err := problems.Err(); err != nil {
+			panic(err)
+		}
+	}
+	store := NewStoreWithSource(cfg, path)
+	if files, err := src.Files(); err == nil {
+		store.SetFiles(files)
+	}
 
-	// Set up file watching for hot-reload
-	_ = FileSource[T]{Path: path}.Watch(context.Background(), func(newCfg T) {
-		store.Update(newCfg)
+	// Set up file watching for hot-reload, as strict as the first load
+	src.Debounce = o.debounce
+	_ = src.watch(context.Background(), func(newCfg T, files []string) {
+		store.SetFiles(files)
+		store.UpdateFrom(newCfg, "file:"+path)
 	})
 
 	return cfg, store
 }
 
 // NewCfg loads configuration from file (alias for Load).
+//
+// Deprecated: use Load.
 func NewCfg[T any](path string) T {
+	deprecation.Warn("config.NewCfg", "config.NewCfg is deprecated, use config.Load", 0)
 	return Load[T](path)
 }
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type dotenvConfig struct {
+	System struct {
+		Name string `mapstructure:"name"`
+		Host string `mapstructure:"host"`
+		Port int    `mapstructure:"port"`
+		Mode string `mapstructure:"mode"`
+	} `mapstructure:"system"`
+}
+
+func TestDotenvPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	writeConfig(t, path, "[system]\nname = \"file\"\nhost = \"file\"\nport = 1\nmode = \"file\"\n")
+	dotenv := filepath.Join(dir, ".env")
+	local := filepath.Join(dir, ".env.local")
+	writeConfig(t, dotenv, "SYSTEM_HOST=dotenv\nSYSTEM_PORT=2\nSYSTEM_MODE=dotenv\n")
+	writeConfig(t, local, "SYSTEM_PORT=3\nSYSTEM_MODE=local\n")
+	t.Setenv("SYSTEM_MODE", "env")
+	for _, name := range []string{"SYSTEM_NAME", "SYSTEM_HOST", "SYSTEM_PORT"} {
+		t.Setenv(name, "") // restored after the test
+		os.Unsetenv(name)
+	}
+
+	src := FileSource[dotenvConfig]{Path: path, Dotenv: []string{dotenv, local, filepath.Join(dir, ".env.missing")}}
+	cfg, err := src.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := cfg.System
+	for _, c := range []struct{ name, got, want string }{
+		{"file only", got.Name, "file"},
+		{".env over file", got.Host, "dotenv"},
+		{"real env over .env.local", got.Mode, "env"},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: %q, want %q", c.name, c.got, c.want)
+		}
+	}
+	if got.Port != 3 {
+		t.Errorf(".env.local over .env: port %d, want 3", got.Port)
+	}
+}
+
+func TestReadDotenvSyntax(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	writeConfig(t, path, `# comment
+export EXPORTED=yes
+PLAIN = value # trailing comment
+SINGLE='literal \n # kept'
+DOUBLE="line\nbreak \"quoted\""
+EMPTY=
+`)
+	got, err := ReadDotenv(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"EXPORTED": "yes",
+		"PLAIN":    "value",
+		"SINGLE":   `literal \n # kept`,
+		"DOUBLE":   "line\nbreak \"quoted\"",
+		"EMPTY":    "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadDotenv = %q, want %q", got, want)
+	}
+
+	writeConfig(t, path, "NOEQUALS\n")
+	if _, err := ReadDotenv(path); err == nil {
+		t.Fatal("line without '=': no error")
+	}
+}
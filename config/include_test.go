@@ -0,0 +1,121 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type includeConfig struct {
+	System struct {
+		Name string `mapstructure:"name"`
+		Port int    `mapstructure:"port"`
+	} `mapstructure:"system"`
+	Database struct {
+		Host string `mapstructure:"host"`
+		Port int    `mapstructure:"port"`
+	} `mapstructure:"database"`
+	Log struct {
+		Level string `mapstructure:"level"`
+	} `mapstructure:"log"`
+}
+
+// writeFiles writes files under a temp directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		writeConfig(t, path, content)
+	}
+	return dir
+}
+
+func TestIncludeNested(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.toml":        "include = [\"conf/database.yaml\", \"log.json\"]\n[system]\nname = \"app\"\n[database]\nport = 5433\n",
+		"conf/database.yaml": "include: common.yaml\ndatabase:\n  host: db\n  port: 5432\n",
+		"conf/common.yaml":   "system:\n  port: 8080\n  name: common\n",
+		"log.json":           `{"log": {"level": "debug"}}`,
+	})
+	path := filepath.Join(dir, "config.toml")
+	src := FileSource[includeConfig]{Path: path, Strict: true}
+	cfg, err := src.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The including file wins over its includes, and later includes over
+	// earlier ones.
+	if cfg.System.Name != "app" || cfg.System.Port != 8080 || cfg.Database.Host != "db" || cfg.Database.Port != 5433 || cfg.Log.Level != "debug" {
+		t.Fatalf("cfg = %+v", cfg)
+	}
+
+	files, err := src.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{path, filepath.Join(dir, "conf/database.yaml"), filepath.Join(dir, "conf/common.yaml"), filepath.Join(dir, "log.json")}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("files = %q, want %q", files, want)
+	}
+	if _, store := Bootstrap[includeConfig](path); !reflect.DeepEqual(store.Meta().Files, want) {
+		t.Fatalf("store files = %q, want %q", store.Meta().Files, want)
+	}
+}
+
+func TestIncludeCycle(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.toml": "include = [\"a.toml\"]\n",
+		"a.toml":      "include = [\"b.toml\"]\n",
+		"b.toml":      "include = [\"a.toml\"]\n",
+	})
+	_, err := FileSource[includeConfig]{Path: filepath.Join(dir, "config.toml")}.Load(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "config include cycle") ||
+		!strings.Contains(err.Error(), "a.toml -> "+filepath.Join(dir, "b.toml")+" -> "+filepath.Join(dir, "a.toml")) {
+		t.Fatalf("err = %v, want the cycle", err)
+	}
+}
+
+func TestIncludeMissing(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.toml": "include = [\"a.toml\"]\n",
+		"a.toml":      "include = [\"missing.toml\"]\n",
+	})
+	path := filepath.Join(dir, "config.toml")
+	_, err := FileSource[includeConfig]{Path: path}.Load(context.Background())
+	chain := path + " -> " + filepath.Join(dir, "a.toml") + " -> " + filepath.Join(dir, "missing.toml")
+	if err == nil || !strings.Contains(err.Error(), "(include chain: "+chain+")") {
+		t.Fatalf("err = %v, want the include chain %s", err, chain)
+	}
+}
+
+func TestIncludeReloadOnIncludedFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.toml":        "include = [\"conf/database.yaml\"]\n[system]\nname = \"app\"\n",
+		"conf/database.yaml": "database:\n  host: db1\n",
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan includeConfig, 1)
+	src := FileSource[includeConfig]{Path: filepath.Join(dir, "config.toml"), Debounce: 50 * time.Millisecond}
+	if err := src.Watch(ctx, func(c includeConfig) { changes <- c }); err != nil {
+		t.Fatal(err)
+	}
+
+	writeConfig(t, filepath.Join(dir, "conf/database.yaml"), "database:\n  host: db2\n")
+	select {
+	case c := <-changes:
+		if c.Database.Host != "db2" {
+			t.Fatalf("host = %q, want db2", c.Database.Host)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("editing an included file did not reload")
+	}
+}
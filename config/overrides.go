@@ -0,0 +1,42 @@
+package config
+
+import (
+	"flag"
+	"strings"
+)
+
+// ParseOverrideArgs extracts config overrides of the form --key.path=value
+// from command-line arguments (e.g. os.Args[1:]). Arguments without "=" or
+// not starting with "--" are ignored, so application flags can coexist.
+//
+//	app --system.port=9090 --log.level=debug --cors.allow_origins=a.com,b.com
+func ParseOverrideArgs(args []string) map[string]string {
+	overrides := make(map[string]string)
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		kv, ok := strings.CutPrefix(arg, "--")
+		if !ok {
+			continue
+		}
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			continue
+		}
+		overrides[strings.ToLower(key)] = value
+	}
+	return overrides
+}
+
+// OverridesFromFlagSet returns the flags that were explicitly set on fs as
+// config overrides. Flag names are used as dotted config keys, so define
+// flags like fs.String("system.port", "", "listen port").
+// fs must already be parsed.
+func OverridesFromFlagSet(fs *flag.FlagSet) map[string]string {
+	overrides := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		overrides[strings.ToLower(f.Name)] = f.Value.String()
+	})
+	return overrides
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"flag"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type overrideConfig struct {
+	System struct {
+		Port    int           `mapstructure:"port"`
+		Debug   bool          `mapstructure:"debug"`
+		Timeout time.Duration `mapstructure:"timeout"`
+		Origins []string      `mapstructure:"origins"`
+	} `mapstructure:"system"`
+}
+
+func overrideFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeConfig(t, path, "[system]\nport = 8000\ndebug = false\ntimeout = \"1s\"\norigins = [\"file.com\"]\n")
+	return path
+}
+
+func TestOverridePrecedence(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       string
+		overrides map[string]string
+		want      int
+	}{
+		{"file", "", nil, 8000},
+		{"env over file", "8001", nil, 8001},
+		{"flag over file", "", map[string]string{"system.port": "8002"}, 8002},
+		{"flag over env", "8001", map[string]string{"system.port": "8002"}, 8002},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("SYSTEM_PORT", tt.env)
+			}
+			cfg := LoadWithOverrides[overrideConfig](overrideFile(t), tt.overrides)
+			if cfg.System.Port != tt.want {
+				t.Fatalf("port = %d, want %d", cfg.System.Port, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverrideCoercion(t *testing.T) {
+	args := []string{"serve", "--system.port=9090", "--system.debug=true", "--system.timeout=2m", "--system.origins=a.com,b.com", "-v", "--", "--system.port=1"}
+	cfg := LoadWithOverrides[overrideConfig](overrideFile(t), ParseOverrideArgs(args))
+	if cfg.System.Port != 9090 || !cfg.System.Debug || cfg.System.Timeout != 2*time.Minute {
+		t.Fatalf("system = %+v", cfg.System)
+	}
+	if want := []string{"a.com", "b.com"}; !reflect.DeepEqual(cfg.System.Origins, want) {
+		t.Fatalf("origins = %q, want %q", cfg.System.Origins, want)
+	}
+}
+
+func TestOverridesFromFlagSet(t *testing.T) {
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	fs.String("system.port", "", "listen port")
+	fs.String("system.debug", "", "debug mode")
+	if err := fs.Parse([]string{"-system.port=9091"}); err != nil {
+		t.Fatal(err)
+	}
+	got := OverridesFromFlagSet(fs)
+	if want := map[string]string{"system.port": "9091"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("overrides = %v, want %v (unset flags excluded)", got, want)
+	}
+}
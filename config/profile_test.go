@@ -0,0 +1,135 @@
+package config
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type profileConfig struct {
+	System struct {
+		Env  string `mapstructure:"env"`
+		Port int    `mapstructure:"port"`
+	} `mapstructure:"system"`
+	Database struct {
+		Host string `mapstructure:"host"`
+		Pool struct {
+			Size int `mapstructure:"size"`
+			Idle int `mapstructure:"idle"`
+		} `mapstructure:"pool"`
+	} `mapstructure:"database"`
+}
+
+const profileFile = `
+system:
+  env: dev
+  port: 8080
+database:
+  host: localhost
+  pool:
+    size: 5
+    idle: 2
+profiles:
+  prod:
+    system:
+      env: prod
+    database:
+      host: db.internal
+      pool:
+        size: 50
+  staging:
+    database:
+      host: db.staging
+`
+
+func profilePath(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, content)
+	return path
+}
+
+func TestProfileBaseOnly(t *testing.T) {
+	t.Setenv(ProfileEnvVar, "")
+	t.Setenv("SYSTEM_ENV", "")
+	path := profilePath(t, "system:\n  env: dev\n  port: 8080\ndatabase:\n  host: localhost\n")
+	cfg, err := FileSource[profileConfig]{Path: path, Strict: true}.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.System.Port != 8080 || cfg.Database.Host != "localhost" {
+		t.Fatalf("cfg = %+v", cfg)
+	}
+}
+
+func TestProfileOverridesNestedKeys(t *testing.T) {
+	t.Setenv(ProfileEnvVar, "")
+	t.Setenv("SYSTEM_ENV", "")
+	cfg, err := FileSource[profileConfig]{Path: profilePath(t, profileFile), Profile: "prod", Strict: true}.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := cfg.Database
+	if cfg.System.Env != "prod" || cfg.System.Port != 8080 || db.Host != "db.internal" || db.Pool.Size != 50 || db.Pool.Idle != 2 {
+		t.Fatalf("cfg = %+v, want prod merged over the base", cfg)
+	}
+}
+
+func TestProfileSelection(t *testing.T) {
+	path := profilePath(t, profileFile)
+	tests := []struct {
+		name, option, envVar, systemEnv string
+		wantHost                        string
+	}{
+		{"base system.env", "", "", "", "localhost"},
+		{"env var", "", "staging", "", "db.staging"},
+		{"option over env var", "prod", "staging", "", "db.internal"},
+		{"SYSTEM_ENV", "", "", "prod", "db.internal"},
+		{"env var over SYSTEM_ENV", "", "staging", "prod", "db.staging"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ProfileEnvVar, tt.envVar)
+			t.Setenv("SYSTEM_ENV", tt.systemEnv)
+			cfg, err := FileSource[profileConfig]{Path: path, Profile: tt.option}.Load(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Database.Host != tt.wantHost {
+				t.Fatalf("host = %q, want %q", cfg.Database.Host, tt.wantHost)
+			}
+		})
+	}
+}
+
+func TestProfileMissing(t *testing.T) {
+	t.Setenv(ProfileEnvVar, "qa")
+	_, err := FileSource[profileConfig]{Path: profilePath(t, profileFile)}.Load(context.Background())
+	if err == nil || !strings.Contains(err.Error(), `profile "qa" not found (defined: prod, staging)`) {
+		t.Fatalf("err = %v, want the missing profile", err)
+	}
+}
+
+func TestProfileWatchReresolves(t *testing.T) {
+	t.Setenv(ProfileEnvVar, "prod")
+	path := profilePath(t, profileFile)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan profileConfig, 1)
+	src := FileSource[profileConfig]{Path: path, Debounce: 50 * time.Millisecond}
+	if err := src.Watch(ctx, func(c profileConfig) { changes <- c }); err != nil {
+		t.Fatal(err)
+	}
+
+	writeConfig(t, path, strings.Replace(profileFile, "size: 50", "size: 60", 1))
+	select {
+	case c := <-changes:
+		if c.Database.Pool.Size != 60 || c.Database.Host != "db.internal" {
+			t.Fatalf("reloaded %+v, want the edited prod profile", c.Database)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload")
+	}
+}
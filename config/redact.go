@@ -0,0 +1,179 @@
+package config
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// redactedValue replaces sensitive values.
+const redactedValue = "******"
+
+// Redacted returns a JSON-friendly representation of cfg with every field
+// tagged `sensitive:"true"` masked. Struct fields are keyed by their
+// mapstructure tag. cfg is not modified.
+func Redacted(cfg any) any {
+	if cfg == nil {
+		return nil
+	}
+	return redactValue(reflect.ValueOf(cfg), false)
+}
+
+// String implements fmt.Stringer with sensitive fields masked, so logging a
+// Config does not leak secrets.
+func (c Config) String() string {
+	data, err := json.Marshal(Redacted(c))
+	if err != nil {
+		return "config.Config{<unprintable>}"
+	}
+	return string(data)
+}
+
+// dsnParam matches the key=value parameters of DSNs and connection
+// strings, such as "password=secret" or "sslkey=...".
+var dsnParam = regexp.MustCompile(`([A-Za-z_]+)=([^&;\s]*)`)
+
+// RedactEndpoint masks the credentials of an endpoint, for logs: the
+// password of URLs ("redis://:secret@host:6379") and of DSNs
+// ("user:secret@tcp(host:3306)/db"), and parameters named like secrets
+// ("password=secret"). Endpoints without credentials are returned as is.
+func RedactEndpoint(endpoint string) string {
+	if u, err := url.Parse(endpoint); err == nil && u.Scheme != "" && u.Host != "" {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redactedValue)
+		}
+		q := u.Query()
+		for k := range q {
+			if isSensitiveKey(k) {
+				q.Set(k, redactedValue)
+			}
+		}
+		if len(q) > 0 {
+			u.RawQuery = q.Encode()
+		}
+		// Keep the mask readable: the query encoding escapes "*".
+		if s, err := url.PathUnescape(u.String()); err == nil {
+			return s
+		}
+		return u.String()
+	}
+	if at := strings.LastIndex(endpoint, "@"); at >= 0 {
+		if user, _, ok := strings.Cut(endpoint[:at], ":"); ok {
+			endpoint = user + ":" + redactedValue + endpoint[at:]
+		}
+	}
+	return dsnParam.ReplaceAllStringFunc(endpoint, func(param string) string {
+		key, _, _ := strings.Cut(param, "=")
+		if isSensitiveKey(key) {
+			return key + "=" + redactedValue
+		}
+		return param
+	})
+}
+
+func redactValue(v reflect.Value, sensitive bool) any {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem(), sensitive)
+
+	case reflect.Struct:
+		// Leaf types such as time.Time marshal themselves.
+		if _, ok := v.Interface().(json.Marshaler); ok {
+			if sensitive {
+				return redactedValue
+			}
+			return v.Interface()
+		}
+		t := v.Type()
+		out := make(map[string]any, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := fieldKey(field)
+			if name == "-" {
+				continue
+			}
+			fieldSensitive := sensitive || field.Tag.Get("sensitive") == "true"
+			val := redactValue(v.Field(i), fieldSensitive)
+			if field.Anonymous && field.Tag.Get("mapstructure") == "" {
+				// Embedded struct: flatten its fields.
+				if m, ok := val.(map[string]any); ok {
+					for k, mv := range m {
+						out[k] = mv
+					}
+					continue
+				}
+			}
+			out[name] = val
+		}
+		return out
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		out := make([]any, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out[i] = redactValue(v.Index(i), sensitive)
+		}
+		return out
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key()
+			k := key.String()
+			if key.Kind() != reflect.String {
+				b, _ := json.Marshal(key.Interface())
+				k = string(b)
+			}
+			out[k] = redactValue(iter.Value(), sensitive || isSensitiveKey(k))
+		}
+		return out
+
+	default:
+		if sensitive {
+			if v.IsZero() {
+				return v.Interface()
+			}
+			return redactedValue
+		}
+		return v.Interface()
+	}
+}
+
+// fieldKey returns the configuration key of a struct field.
+func fieldKey(field reflect.StructField) string {
+	if tag := field.Tag.Get("mapstructure"); tag != "" {
+		name := strings.SplitN(tag, ",", 2)[0]
+		if name != "" {
+			return name
+		}
+	}
+	return strings.ToLower(field.Name)
+}
+
+// sensitiveKeyHints are map key fragments treated as sensitive in
+// untyped sections (e.g. map[string]string labels or headers).
+var sensitiveKeyHints = []string{"password", "passwd", "pwd", "secret", "token", "pepper", "apikey", "api_key", "private", "sslkey"}
+
+func isSensitiveKey(key string) bool {
+	k := strings.ToLower(key)
+	for _, hint := range sensitiveKeyHints {
+		if strings.Contains(k, hint) {
+			return true
+		}
+	}
+	return false
+}
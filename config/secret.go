@@ -0,0 +1,308 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+)
+
+// Defaults of SecretsConfig and VaultConfig.
+const (
+	DefaultSecretCacheTTL       = time.Minute
+	DefaultVaultTimeout         = 10 * time.Second
+	DefaultVaultKubernetesMount = "kubernetes"
+	DefaultVaultKubernetesToken = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+// SecretProvider returns the secret a reference points to. The reference
+// is the part after the scheme: "DB_PASSWORD" for "env:DB_PASSWORD".
+type SecretProvider interface {
+	Get(ctx context.Context, ref string) (string, error)
+}
+
+// SecretResolver resolves password fields holding a reference of the form
+// "<scheme>:<ref>", such as "vault:secret/data/db#password", with the
+// provider registered for the scheme; other values are plaintext and
+// returned as is. Resolved secrets are cached for the cache TTL.
+//
+// Errors name the reference, never the resolved value.
+type SecretResolver struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	providers map[string]SecretProvider
+	cache     map[string]cachedSecret
+}
+
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+// NewSecretResolver creates a SecretResolver with the env and file
+// providers, and the vault provider when cfg.Vault.Address is set.
+func NewSecretResolver(cfg SecretsConfig) *SecretResolver {
+	ttl := cfg.CacheTTL
+	if ttl <= 0 {
+		ttl = DefaultSecretCacheTTL
+	}
+	r := &SecretResolver{
+		ttl:       ttl,
+		providers: make(map[string]SecretProvider),
+		cache:     make(map[string]cachedSecret),
+	}
+	r.Register("env", EnvSecretProvider{})
+	r.Register("file", FileSecretProvider{})
+	if cfg.Vault.Address != "" {
+		r.Register("vault", NewVaultSecretProvider(cfg.Vault))
+	}
+	return r
+}
+
+// Register sets the provider of scheme, replacing any.
+func (r *SecretResolver) Register(scheme string, p SecretProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[scheme] = p
+}
+
+// IsRef reports whether value is a reference to a registered scheme. A
+// nil resolver has none.
+func (r *SecretResolver) IsRef(value string) bool {
+	_, _, ok := r.provider(value)
+	return ok
+}
+
+func (r *SecretResolver) provider(value string) (SecretProvider, string, bool) {
+	if r == nil {
+		return nil, "", false
+	}
+	scheme, ref, ok := strings.Cut(value, ":")
+	if !ok {
+		return nil, "", false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.providers[scheme]
+	return p, ref, ok
+}
+
+// Resolve returns the secret value refers to, or value itself when it is
+// not a reference. Failures are coded code.ErrSecret errors.
+func (r *SecretResolver) Resolve(ctx context.Context, value string) (string, error) {
+	p, ref, ok := r.provider(value)
+	if !ok {
+		return value, nil
+	}
+
+	r.mu.Lock()
+	c, hit := r.cache[value]
+	r.mu.Unlock()
+	if hit && time.Now().Before(c.expires) {
+		return c.value, nil
+	}
+
+	secret, err := p.Get(ctx, ref)
+	if err != nil {
+		return "", code.WrapErrorf(err, code.ErrSecret, "resolve secret %s", value)
+	}
+	r.mu.Lock()
+	r.cache[value] = cachedSecret{value: secret, expires: time.Now().Add(r.ttl)}
+	r.mu.Unlock()
+	return secret, nil
+}
+
+// Invalidate drops the cached secret of value, e.g. after a connection
+// failed with it, so that the next Resolve reads a rotated secret.
+func (r *SecretResolver) Invalidate(value string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.cache, value)
+}
+
+// EnvSecretProvider reads secrets from environment variables:
+// "env:DB_PASSWORD".
+type EnvSecretProvider struct{}
+
+// Get implements SecretProvider.
+func (EnvSecretProvider) Get(_ context.Context, ref string) (string, error) {
+	v, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s not set", ref)
+	}
+	return v, nil
+}
+
+// FileSecretProvider reads secrets from files, such as mounted Kubernetes
+// or Docker secrets: "file:/run/secrets/db_password". A trailing newline
+// is removed.
+type FileSecretProvider struct{}
+
+// Get implements SecretProvider.
+func (FileSecretProvider) Get(_ context.Context, ref string) (string, error) {
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// VaultSecretProvider reads secrets from the KV v2 engine of HashiCorp
+// Vault. A reference is the API path of the secret and the field:
+// "secret/data/db#password".
+type VaultSecretProvider struct {
+	cfg    VaultConfig
+	client *http.Client
+
+	mu    sync.Mutex
+	token string
+}
+
+// NewVaultSecretProvider creates a VaultSecretProvider. With the
+// Kubernetes auth method, it logs in on first use and again when its token
+// is rejected.
+func NewVaultSecretProvider(cfg VaultConfig) *VaultSecretProvider {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultVaultTimeout
+	}
+	if cfg.KubernetesMount == "" {
+		cfg.KubernetesMount = DefaultVaultKubernetesMount
+	}
+	if cfg.KubernetesTokenPath == "" {
+		cfg.KubernetesTokenPath = DefaultVaultKubernetesToken
+	}
+	cfg.Address = strings.TrimRight(cfg.Address, "/")
+	return &VaultSecretProvider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		token:  cfg.Token,
+	}
+}
+
+// Get implements SecretProvider.
+func (v *VaultSecretProvider) Get(ctx context.Context, ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("vault reference must be <path>#<field>")
+	}
+
+	token, err := v.currentToken(ctx, false)
+	if err != nil {
+		return "", err
+	}
+	var body struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	status, err := v.do(ctx, http.MethodGet, "/v1/"+strings.TrimLeft(path, "/"), token, nil, &body)
+	if status == http.StatusForbidden && v.cfg.Token == "" && v.cfg.KubernetesRole != "" {
+		// The login token expired; log in again once.
+		if token, err = v.currentToken(ctx, true); err != nil {
+			return "", err
+		}
+		_, err = v.do(ctx, http.MethodGet, "/v1/"+strings.TrimLeft(path, "/"), token, nil, &body)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := body.Data.Data[field]
+	if !ok {
+		return "", fmt.Errorf("vault secret has no field %q", field)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret field %q is not a string", field)
+	}
+	return s, nil
+}
+
+// currentToken returns the token to use, logging in with the Kubernetes
+// auth method when there is none or renew is set.
+func (v *VaultSecretProvider) currentToken(ctx context.Context, renew bool) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.token != "" && !renew {
+		return v.token, nil
+	}
+	if v.cfg.KubernetesRole == "" {
+		return "", fmt.Errorf("vault token or kubernetes role required")
+	}
+	jwt, err := os.ReadFile(v.cfg.KubernetesTokenPath)
+	if err != nil {
+		return "", fmt.Errorf("read kubernetes service account token: %w", err)
+	}
+	var body struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	login := map[string]string{"role": v.cfg.KubernetesRole, "jwt": strings.TrimSpace(string(jwt))}
+	if _, err := v.do(ctx, http.MethodPost, "/v1/auth/"+v.cfg.KubernetesMount+"/login", "", login, &body); err != nil {
+		return "", fmt.Errorf("vault kubernetes login: %w", err)
+	}
+	if body.Auth.ClientToken == "" {
+		return "", fmt.Errorf("vault kubernetes login: no client token")
+	}
+	v.token = body.Auth.ClientToken
+	return v.token, nil
+}
+
+// do sends a request to Vault and decodes a successful response into out.
+// Errors carry the messages of Vault, which do not include secrets.
+func (v *VaultSecretProvider) do(ctx context.Context, method, path, token string, in, out any) (int, error) {
+	var reqBody io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.cfg.Address+path, reqBody)
+	if err != nil {
+		return 0, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.cfg.Namespace)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		var e struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.NewDecoder(io.LimitReader(res.Body, 64<<10)).Decode(&e)
+		if len(e.Errors) > 0 {
+			return res.StatusCode, fmt.Errorf("vault: %s: %s", res.Status, strings.Join(e.Errors, "; "))
+		}
+		return res.StatusCode, fmt.Errorf("vault: %s", res.Status)
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return res.StatusCode, fmt.Errorf("vault: decode response: %w", err)
+	}
+	return res.StatusCode, nil
+}
@@ -0,0 +1,239 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultHistorySize is the number of changes kept by a Store.
+const defaultHistorySize = 20
+
+// Meta describes where the current configuration came from and when it was loaded.
+type Meta struct {
+	Source     string    `json:"source"`
+	Version    uint64    `json:"version"`
+	LoadedAt   time.Time `json:"loaded_at"`
+	ReloadedAt time.Time `json:"reloaded_at,omitzero"`
+	// Files are the config files read, the main file first and then its
+	// includes (see FileSource.Files).
+	Files []string `json:"files,omitempty"`
+}
+
+// Change records one applied configuration update.
+type Change struct {
+	Version     uint64    `json:"version"`
+	Time        time.Time `json:"time"`
+	Source      string    `json:"source"`
+	ChangedKeys []string  `json:"changed_keys"`
+}
+
+// ChangeEvent is delivered to SubscribeEvents subscribers on every update.
+type ChangeEvent[T any] struct {
+	New         T
+	Old         T
+	Version     uint64
+	ChangedKeys []string
+}
+
+// ReloadLogger receives a log line for every applied reload.
+// log.Logger satisfies this interface.
+type ReloadLogger interface {
+	Info(msg string, attrs ...slog.Attr)
+}
+
+// slogReloadLogger adapts the default slog logger.
+type slogReloadLogger struct{}
+
+func (slogReloadLogger) Info(msg string, attrs ...slog.Attr) {
+	slog.Default().LogAttrs(context.Background(), slog.LevelInfo, msg, attrs...)
+}
+
+// Store provides atomic read/update for configuration with hot-reload.
+type Store[T any] struct {
+	v    atomic.Value
+	mu   sync.RWMutex
+	subs map[string][]chan T
+
+	eventSubs []chan ChangeEvent[T]
+	meta      Meta
+	history   []Change // ring buffer of the last historySize changes
+	next      int
+	logger    ReloadLogger
+}
+
+// NewStore creates a new configuration store.
+func NewStore[T any](initial T) *Store[T] {
+	return NewStoreWithSource(initial, "")
+}
+
+// NewStoreWithSource creates a new configuration store recording source
+// (e.g. the config file path) in its metadata.
+func NewStoreWithSource[T any](initial T, source string) *Store[T] {
+	s := &Store[T]{
+		subs:    make(map[string][]chan T),
+		meta:    Meta{Source: source, Version: 1, LoadedAt: time.Now()},
+		history: make([]Change, 0, defaultHistorySize),
+		logger:  slogReloadLogger{},
+	}
+	s.v.Store(initial)
+	return s
+}
+
+// SetLogger sets the logger used to report applied reloads.
+func (s *Store[T]) SetLogger(l ReloadLogger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = l
+}
+
+// Meta returns the store's load metadata.
+func (s *Store[T]) Meta() Meta {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.meta
+}
+
+// SetFiles records the config files the current configuration was read
+// from in the metadata.
+func (s *Store[T]) SetFiles(files []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.meta.Files = slices.Clone(files)
+}
+
+// History returns up to n of the most recent changes, newest first.
+// Only change metadata is retained, never previous configurations.
+func (s *Store[T]) History(n int) []Change {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	size := len(s.history)
+	if n <= 0 || n > size {
+		n = size
+	}
+	out := make([]Change, 0, n)
+	for i := 0; i < n; i++ {
+		idx := (s.next - 1 - i + size) % size
+		out = append(out, s.history[idx])
+	}
+	return out
+}
+
+// Current returns the current configuration.
+func (s *Store[T]) Current() T {
+	c, _ := s.v.Load().(T)
+	return c
+}
+
+// Update updates the configuration and notifies subscribers.
+func (s *Store[T]) Update(c T) {
+	s.UpdateFrom(c, "")
+}
+
+// UpdateFrom updates the configuration, recording source as the origin of
+// the change, and notifies subscribers.
+func (s *Store[T]) UpdateFrom(c T, source string) {
+	s.mu.Lock()
+	old := s.Current()
+	s.v.Store(c)
+
+	now := time.Now()
+	s.meta.Version++
+	s.meta.ReloadedAt = now
+	if source != "" {
+		s.meta.Source = source
+	}
+	change := Change{
+		Version:     s.meta.Version,
+		Time:        now,
+		Source:      s.meta.Source,
+		ChangedKeys: ChangedKeys(old, c),
+	}
+	s.record(change)
+
+	event := ChangeEvent[T]{New: c, Old: old, Version: change.Version, ChangedKeys: change.ChangedKeys}
+	for _, ch := range s.subs["*"] {
+		select {
+		case ch <- c:
+		default:
+		}
+	}
+	for _, ch := range s.eventSubs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	logger := s.logger
+	s.mu.Unlock()
+
+	logger.Info("Configuration reloaded",
+		slog.Uint64("version", change.Version),
+		slog.String("source", change.Source),
+		slog.Any("changed_keys", change.ChangedKeys),
+	)
+}
+
+// record appends a change to the history ring. Caller holds s.mu.
+func (s *Store[T]) record(c Change) {
+	if len(s.history) < cap(s.history) {
+		s.history = append(s.history, c)
+		s.next = len(s.history) % cap(s.history)
+		return
+	}
+	s.history[s.next] = c
+	s.next = (s.next + 1) % len(s.history)
+}
+
+// Subscribe subscribes to configuration updates.
+func (s *Store[T]) Subscribe(key string) <-chan T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := make(chan T, 1)
+	s.subs[key] = append(s.subs[key], ch)
+	return ch
+}
+
+// SubscribeEvents subscribes to configuration updates with change details.
+// Slow subscribers miss events rather than blocking updates.
+func (s *Store[T]) SubscribeEvents() <-chan ChangeEvent[T] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := make(chan ChangeEvent[T], 1)
+	s.eventSubs = append(s.eventSubs, ch)
+	return ch
+}
+
+// ChangedKeys returns the top-level sections that differ between old and
+// new, keyed by their mapstructure tag. Non-struct values report "*" when
+// they differ.
+func ChangedKeys(old, new any) []string {
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(new)
+	for ov.Kind() == reflect.Pointer && nv.Kind() == reflect.Pointer && !ov.IsNil() && !nv.IsNil() {
+		ov, nv = ov.Elem(), nv.Elem()
+	}
+	if ov.Kind() != reflect.Struct || nv.Kind() != reflect.Struct || ov.Type() != nv.Type() {
+		if reflect.DeepEqual(old, new) {
+			return nil
+		}
+		return []string{"*"}
+	}
+
+	var keys []string
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if !reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			keys = append(keys, fieldKey(field))
+		}
+	}
+	return keys
+}
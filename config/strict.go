@@ -0,0 +1,78 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+)
+
+// checkStrict decodes the settings of v into a T without weak type
+// conversion and reports keys that map to no field and values of the wrong
+// type, one Problem per key path.
+func checkStrict[T any](v *viper.Viper) error {
+	var c T
+	err := v.Unmarshal(&c, func(dc *mapstructure.DecoderConfig) {
+		dc.WeaklyTypedInput = false
+		dc.ErrorUnused = true
+	})
+	problems := decodeProblems(err)
+	slices.SortStableFunc(problems, func(a, b Problem) int { return strings.Compare(a.Key, b.Key) })
+	return problems.Err()
+}
+
+// decodeProblems flattens mapstructure decode errors into problems.
+func decodeProblems(err error) Problems {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var problems Problems
+		for _, e := range joined.Unwrap() {
+			problems = append(problems, decodeProblems(e)...)
+		}
+		return problems
+	}
+
+	de, ok := err.(*mapstructure.DecodeError)
+	if !ok {
+		if inner := errors.Unwrap(err); inner != nil {
+			return decodeProblems(inner)
+		}
+		return Problems{{Severity: SeverityError, Message: err.Error()}}
+	}
+	msg := de.Unwrap().Error()
+	// ErrorUnused reports all unknown keys of a section in one error.
+	if keys, ok := strings.CutPrefix(msg, "has invalid keys: "); ok {
+		var problems Problems
+		for _, key := range strings.Split(keys, ", ") {
+			if de.Name() != "" {
+				key = de.Name() + "." + key
+			}
+			problems = append(problems, Problem{Severity: SeverityError, Key: key, Message: "unknown key"})
+		}
+		return problems
+	}
+	var ue *mapstructure.UnconvertibleTypeError
+	if errors.As(de, &ue) {
+		msg = fmt.Sprintf("expected %s, got %T (%v)", ue.Expected.Type(), ue.Value, ue.Value)
+	}
+	return Problems{{Severity: SeverityError, Key: de.Name(), Message: msg}}
+}
+
+// fileEnv returns system.env of the file at path after profile, dotenv and
+// environment overrides, or "" when the files cannot be read.
+func fileEnv(path string, dotenv []string, profile string) string {
+	src := FileSource[struct{}]{Path: path, Dotenv: dotenv, Profile: profile}
+	v, err := src.read()
+	if err != nil {
+		return ""
+	}
+	if err := src.applyOverrides(v); err != nil {
+		return ""
+	}
+	return v.GetString("system.env")
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type strictConfig struct {
+	System struct {
+		Name string `mapstructure:"name"`
+		Env  string `mapstructure:"env"`
+		Port int    `mapstructure:"port"`
+	} `mapstructure:"system"`
+	Database struct {
+		Host string `mapstructure:"host"`
+		Port int    `mapstructure:"port"`
+	} `mapstructure:"database"`
+}
+
+func TestStrictFixtures(t *testing.T) {
+	tests := []struct {
+		file    string
+		want    []string // problems in the error; none for a valid file
+		lenient bool     // whether the file loads without Strict
+	}{
+		{"valid.yaml", nil, true},
+		{"typo.yaml", []string{"databse: unknown key"}, true},
+		{"extra.yaml", []string{"system.prot: unknown key", "database.pool: unknown key"}, true},
+		{"wrongtype.yaml", []string{"system.port: expected int, got string (eighty)"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			src := FileSource[strictConfig]{Path: "testdata/strict/" + tt.file, Strict: true}
+			_, err := src.Load(context.Background())
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("loaded, want an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not report %q", err, want)
+				}
+			}
+
+			src.Strict = false
+			if _, err := src.Load(context.Background()); (err == nil) != tt.lenient {
+				t.Fatalf("lenient: err = %v, want loaded %v", err, tt.lenient)
+			}
+		})
+	}
+}
+
+func TestBootstrapStrictByEnv(t *testing.T) {
+	// Lenient in prod: the typo is ignored.
+	cfg, _ := Bootstrap[strictConfig]("testdata/strict/prod.yaml")
+	if cfg.System.Env != "prod" {
+		t.Fatalf("env = %q, want prod", cfg.System.Env)
+	}
+
+	// Strict elsewhere, unless turned off.
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("Bootstrap of a file with a typo did not panic outside prod")
+			}
+		}()
+		Bootstrap[strictConfig]("testdata/strict/typo.yaml")
+	}()
+	if cfg, _ := Bootstrap[strictConfig]("testdata/strict/typo.yaml", WithStrict(StrictOff)); cfg.System.Name != "orders" {
+		t.Fatalf("StrictOff: name = %q", cfg.System.Name)
+	}
+
+	// And forced on in prod.
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("StrictOn: Bootstrap of a file with a typo did not panic in prod")
+			}
+		}()
+		Bootstrap[strictConfig]("testdata/strict/prod.yaml", WithStrict(StrictOn))
+	}()
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type watchConfig struct {
+	System struct {
+		Port int `mapstructure:"port"`
+	} `mapstructure:"system"`
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchCoalescesRapidWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeConfig(t, path, "[system]\nport = 8000\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan watchConfig, 10)
+	src := FileSource[watchConfig]{Path: path, Debounce: 200 * time.Millisecond}
+	if err := src.Watch(ctx, func(c watchConfig) { changes <- c }); err != nil {
+		t.Fatal(err)
+	}
+
+	for port := 8001; port <= 8010; port++ {
+		writeConfig(t, path, "[system]\nport = "+strconv.Itoa(port)+"\n")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case c := <-changes:
+		if c.System.Port != 8010 {
+			t.Fatalf("port = %d, want the final 8010", c.System.Port)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload")
+	}
+	select {
+	case c := <-changes:
+		t.Fatalf("second reload with port %d, want exactly one", c.System.Port)
+	case <-time.After(600 * time.Millisecond):
+	}
+}
+
+func TestBootstrapReloadStaysStrict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeConfig(t, path, "[system]\nport = 8000\n")
+
+	_, store := Bootstrap[watchConfig](path, WithStrict(StrictOn), WithDebounce(50*time.Millisecond))
+	events := store.SubscribeEvents()
+
+	writeConfig(t, path, "[system]\nport = 8001\n[databse]\nhost = \"db\"\n")
+	select {
+	case ev := <-events:
+		t.Fatalf("reloaded a config with an unknown key: %+v", ev)
+	case <-time.After(500 * time.Millisecond):
+	}
+	if got := store.Current().System.Port; got != 8000 {
+		t.Fatalf("port = %d after a rejected reload, want 8000", got)
+	}
+
+	writeConfig(t, path, "[system]\nport = 8002\n")
+	select {
+	case <-events:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload of the fixed config")
+	}
+	if got := store.Current().System.Port; got != 8002 {
+		t.Fatalf("port = %d, want 8002", got)
+	}
+}
@@ -0,0 +1,337 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/NSObjects/go-kit/cache"
+	kitlog "github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// CacheOptions configures a CachedRepository. Zero values use the
+// defaults.
+type CacheOptions struct {
+	// TTL of cached entities and lists; default 5m.
+	TTL time.Duration
+	// KeyPrefix of the cache keys; default the table name.
+	KeyPrefix string
+	// SchemaVersion is part of every entity and list key, so entries of an
+	// older struct shape are never decoded into a newer one. Default: a
+	// hash of T's fields, names, types and tags.
+	SchemaVersion string
+	// WriteThrough stores entities in the cache on Create and Update
+	// instead of only invalidating them.
+	WriteThrough bool
+	// ListTags are invalidated by every write (see ListCached).
+	ListTags []string
+	// Tenant scopes reads to the tenant in ctx (see TenantScope) and keeps
+	// each tenant's entries apart.
+	Tenant bool
+}
+
+// CachedRepository is a Repository serving GetByID from a read-through
+// cache. Writes through its methods invalidate the entity and the
+// configured list tags; writes through DB must call Invalidate.
+//
+// Invalidation bumps a per-entity generation that is part of the entity
+// key instead of deleting the key, so a read racing with a write can only
+// store the old row under the old generation, which is never read again:
+// a GetByID that starts after Update returned never sees the old row.
+// Generation keys have no TTL.
+//
+//	users := db.NewCachedRepository(db.NewRepository[User](m), redisCache, db.CacheOptions{
+//	    TTL:      10 * time.Minute,
+//	    ListTags: []string{"users"},
+//	})
+//	u, err := users.GetByID(ctx, id)
+//	// consistency-critical read:
+//	u, err = users.GetByID(utils.WithCacheBypass(ctx), id)
+type CachedRepository[T any] struct {
+	*Repository[T]
+	cache  cache.Cache
+	typed  *cache.Typed[T]
+	lists  *cache.Typed[cachedList[T]]
+	opts   CacheOptions
+	schema *schema.Schema
+	err    error
+}
+
+// cachedList is the cached form of a List result.
+type cachedList[T any] struct {
+	Items []T   `json:"items"`
+	Total int64 `json:"total"`
+}
+
+// NewCachedRepository wraps repo with a read-through cache in c.
+func NewCachedRepository[T any](repo *Repository[T], c cache.Cache, opts CacheOptions) *CachedRepository[T] {
+	r := &CachedRepository[T]{
+		Repository: repo,
+		cache:      c,
+		typed:      cache.NewTyped[T](c),
+		lists:      cache.NewTyped[cachedList[T]](c),
+	}
+	stmt := &gorm.Statement{DB: repo.db}
+	if err := stmt.Parse(new(T)); err != nil {
+		r.err = fmt.Errorf("cached repository: parse model: %w", err)
+	} else {
+		r.schema = stmt.Schema
+	}
+
+	if opts.TTL <= 0 {
+		opts.TTL = 5 * time.Minute
+	}
+	if opts.KeyPrefix == "" && r.schema != nil {
+		opts.KeyPrefix = r.schema.Table
+	}
+	if opts.SchemaVersion == "" {
+		opts.SchemaVersion = schemaVersion(reflect.TypeFor[T]())
+	}
+	r.opts = opts
+	return r
+}
+
+// GetByID returns the entity with primary key id from the cache, loading
+// it on a miss. Calls with scopes, and contexts marked with
+// utils.WithCacheBypass, read the database directly.
+func (r *CachedRepository[T]) GetByID(ctx context.Context, id any, scopes ...Scope) (*T, error) {
+	if r.opts.Tenant {
+		scopes = append(scopes, TenantScope(ctx))
+	}
+	if len(scopes) > r.tenantScopes() || utils.CacheBypass(ctx) || r.err != nil {
+		return r.Repository.GetByID(ctx, id, scopes...)
+	}
+
+	key := r.entityKey(ctx, id, r.generation(ctx, r.genKey(id)))
+	entity, err := r.typed.GetOrSet(ctx, key, r.opts.TTL, func(ctx context.Context) (T, error) {
+		e, err := r.Repository.GetByID(ctx, id, scopes...)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		return *e, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// ListCached is List cached under tag. key must identify scopes, e.g.
+// "status=active"; the page is added to it. Every write invalidates the
+// configured ListTags; call InvalidateTags for others.
+func (r *CachedRepository[T]) ListCached(ctx context.Context, tag, key string, page Pagination, scopes ...Scope) ([]T, int64, error) {
+	if r.opts.Tenant {
+		scopes = append(scopes, TenantScope(ctx))
+	}
+	if utils.CacheBypass(ctx) {
+		return r.Repository.List(ctx, page, scopes...)
+	}
+
+	page = page.Normalize()
+	gen := r.generation(ctx, r.tagKey(tag))
+	listKey := r.scopedKey(ctx, "list:"+tag+":"+strconv.FormatInt(gen, 36)+":"+key+":"+
+		strconv.Itoa(page.Page)+":"+strconv.Itoa(page.Size))
+	list, err := r.lists.GetOrSet(ctx, listKey, r.opts.TTL, func(ctx context.Context) (cachedList[T], error) {
+		items, total, err := r.Repository.List(ctx, page, scopes...)
+		return cachedList[T]{Items: items, Total: total}, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return list.Items, list.Total, nil
+}
+
+// Create inserts entity and invalidates the list tags. With WriteThrough
+// the entity is cached as well.
+func (r *CachedRepository[T]) Create(ctx context.Context, entity *T) error {
+	if err := r.Repository.Create(ctx, entity); err != nil {
+		return err
+	}
+	if r.opts.WriteThrough {
+		r.store(ctx, entity)
+	}
+	r.invalidateTags(ctx)
+	return nil
+}
+
+// BatchCreate inserts entities and invalidates the list tags.
+func (r *CachedRepository[T]) BatchCreate(ctx context.Context, entities []T, batchSize int) error {
+	if err := r.Repository.BatchCreate(ctx, entities, batchSize); err != nil {
+		return err
+	}
+	r.invalidateTags(ctx)
+	return nil
+}
+
+// Update writes entity and invalidates it and the list tags. With
+// WriteThrough entity is cached as the new value, so it must be complete.
+func (r *CachedRepository[T]) Update(ctx context.Context, entity *T) error {
+	if err := r.Repository.Update(ctx, entity); err != nil {
+		return err
+	}
+	if r.opts.WriteThrough {
+		r.store(ctx, entity)
+	} else if id, ok := r.primaryKey(ctx, entity); ok {
+		r.invalidate(ctx, id)
+	}
+	r.invalidateTags(ctx)
+	return nil
+}
+
+// UpdateFields updates columns of the entity with primary key id and
+// invalidates it and the list tags.
+func (r *CachedRepository[T]) UpdateFields(ctx context.Context, id any, fields map[string]any, scopes ...Scope) error {
+	if err := r.Repository.UpdateFields(ctx, id, fields, scopes...); err != nil {
+		return err
+	}
+	r.invalidate(ctx, id)
+	r.invalidateTags(ctx)
+	return nil
+}
+
+// Delete deletes the entity with primary key id and invalidates it and the
+// list tags.
+func (r *CachedRepository[T]) Delete(ctx context.Context, id any, scopes ...Scope) error {
+	if err := r.Repository.Delete(ctx, id, scopes...); err != nil {
+		return err
+	}
+	r.invalidate(ctx, id)
+	r.invalidateTags(ctx)
+	return nil
+}
+
+// Invalidate drops the cached entity with primary key id, after writes
+// made through DB.
+func (r *CachedRepository[T]) Invalidate(ctx context.Context, id any) error {
+	return r.cache.Set(ctx, r.genKey(id), time.Now().UnixNano(), 0)
+}
+
+// InvalidateTags drops the cached lists of tags.
+func (r *CachedRepository[T]) InvalidateTags(ctx context.Context, tags ...string) error {
+	gen := time.Now().UnixNano()
+	for _, tag := range tags {
+		if err := r.cache.Set(ctx, r.tagKey(tag), gen, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// store bumps the generation of entity and caches it under the new one.
+func (r *CachedRepository[T]) store(ctx context.Context, entity *T) {
+	id, ok := r.primaryKey(ctx, entity)
+	if !ok {
+		return
+	}
+	gen := time.Now().UnixNano()
+	if err := r.cache.Set(ctx, r.genKey(id), gen, 0); err != nil {
+		r.logFailure(ctx, "Cache invalidation failed", err)
+		return
+	}
+	if err := r.typed.Set(ctx, r.entityKey(ctx, id, gen), *entity, r.opts.TTL); err != nil {
+		r.logFailure(ctx, "Cache write-through failed", err)
+	}
+}
+
+// invalidate is Invalidate with failures logged: the database write
+// already succeeded, and the entry expires after TTL at the latest.
+func (r *CachedRepository[T]) invalidate(ctx context.Context, id any) {
+	if err := r.Invalidate(ctx, id); err != nil {
+		r.logFailure(ctx, "Cache invalidation failed", err)
+	}
+}
+
+func (r *CachedRepository[T]) invalidateTags(ctx context.Context) {
+	if err := r.InvalidateTags(ctx, r.opts.ListTags...); err != nil {
+		r.logFailure(ctx, "Cache list invalidation failed", err)
+	}
+}
+
+// generation returns the generation stored at key; 0 when never bumped.
+func (r *CachedRepository[T]) generation(ctx context.Context, key string) int64 {
+	var gen int64
+	if err := r.cache.Get(ctx, key, &gen); err != nil && !errors.Is(err, redis.Nil) {
+		r.logFailure(ctx, "Cache generation read failed", err)
+	}
+	return gen
+}
+
+// primaryKey returns the primary key value of entity.
+func (r *CachedRepository[T]) primaryKey(ctx context.Context, entity *T) (any, bool) {
+	if r.schema == nil || r.schema.PrioritizedPrimaryField == nil {
+		return nil, false
+	}
+	id, zero := r.schema.PrioritizedPrimaryField.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+	return id, !zero
+}
+
+func (r *CachedRepository[T]) tenantScopes() int {
+	if r.opts.Tenant {
+		return 1
+	}
+	return 0
+}
+
+func (r *CachedRepository[T]) genKey(id any) string {
+	return r.opts.KeyPrefix + ":gen:" + fmt.Sprint(id)
+}
+
+func (r *CachedRepository[T]) tagKey(tag string) string {
+	return r.opts.KeyPrefix + ":gen:tag:" + tag
+}
+
+func (r *CachedRepository[T]) entityKey(ctx context.Context, id any, gen int64) string {
+	return r.scopedKey(ctx, fmt.Sprint(id)+":"+strconv.FormatInt(gen, 36))
+}
+
+// scopedKey adds the prefix, schema version and, with Tenant, the tenant.
+func (r *CachedRepository[T]) scopedKey(ctx context.Context, key string) string {
+	prefix := r.opts.KeyPrefix + ":v" + r.opts.SchemaVersion + ":"
+	if r.opts.Tenant {
+		prefix += "t:" + utils.GetTenantID(ctx) + ":"
+	}
+	return prefix + key
+}
+
+func (r *CachedRepository[T]) logFailure(ctx context.Context, msg string, err error) {
+	slog.WarnContext(ctx, msg, slog.String("prefix", r.opts.KeyPrefix), kitlog.Err(err))
+}
+
+// schemaVersion hashes the shape of t: field names, types and tags,
+// recursively for struct fields.
+func schemaVersion(t reflect.Type) string {
+	h := fnv.New32a()
+	seen := make(map[reflect.Type]bool)
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+			if t.Kind() == reflect.Map {
+				walk(t.Key())
+			}
+			t = t.Elem()
+		}
+		fmt.Fprint(h, t.String(), ";")
+		if t.Kind() != reflect.Struct || seen[t] {
+			return
+		}
+		seen[t] = true
+		for i := range t.NumField() {
+			f := t.Field(i)
+			fmt.Fprint(h, f.Name, " ", f.Tag, "{")
+			walk(f.Type)
+			fmt.Fprint(h, "}")
+		}
+	}
+	walk(t)
+	return strconv.FormatUint(uint64(h.Sum32()), 36)
+}
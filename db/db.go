@@ -3,17 +3,29 @@ package db
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"net"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 
+	kit "github.com/NSObjects/go-kit"
 	"github.com/NSObjects/go-kit/config"
+	kitlog "github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/metrics"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
@@ -26,37 +38,45 @@ type Manager struct {
 	DB      *gorm.DB // Generic database connection (MySQL/PostgreSQL)
 	Redis   *redis.Client
 	MongoDB *mongo.Database
-	Config  *config.BaseConfig
+	Config  *config.Config
+	// Tenants routes tenants to their own database (see DBForTenant);
+	// optional.
+	Tenants *TenantRouter
+	// Secrets resolves the password references of the connections (see
+	// config.SecretResolver); register custom providers on it.
+	Secrets *config.SecretResolver
+
+	mu sync.Mutex // serializes ApplyConfig
 }
 
-// NewManager creates a new database manager.
+// NewManager creates a new database manager with the components cfg
+// configures: the database when it has a host (SQLite: a database file),
+// Redis and MongoDB when they have a host or URI. Use New to pick the
+// components explicitly; extra options such as WithCheckOnly apply on top.
 // ctx is used for connection timeouts during initialization.
-func NewManager(ctx context.Context, cfg config.BaseConfig) (*Manager, error) {
-	dm := &Manager{Config: &cfg}
-
-	// Initialize database if configured
-	if cfg.Database.Host != "" {
-		db, err := NewDatabase(cfg.Database, os.Stdout)
-		if err != nil {
-			return nil, fmt.Errorf("database init: %w", err)
-		}
-		dm.DB = db
+//
+// Password fields may hold secret references such as
+// "vault:secret/data/db#password", resolved with the providers of
+// cfg.Secrets when connections are opened.
+func NewManager(ctx context.Context, cfg config.Config, extra ...Option) (*Manager, error) {
+	opts := []Option{
+		WithSecrets(config.NewSecretResolver(cfg.Secrets)),
+		WithEnv(cfg.System.Env),
+	}
+	if cfg.Database.Host != "" || (cfg.Database.Driver == "sqlite" && cfg.Database.Database != "") {
+		opts = append(opts, WithDatabase(cfg.Database))
 	}
-
-	// Initialize Redis if configured
 	if cfg.Redis.Host != "" {
-		dm.Redis = NewRedis(cfg.Redis)
+		opts = append(opts, WithRedis(cfg.Redis))
 	}
-
-	// Initialize MongoDB if configured
-	if cfg.Mongodb.Host != "" {
-		db, err := NewMongoDB(ctx, cfg.Mongodb)
-		if err != nil {
-			return nil, fmt.Errorf("mongodb init: %w", err)
-		}
-		dm.MongoDB = db
+	if cfg.Mongodb.Host != "" || cfg.Mongodb.URI != "" {
+		opts = append(opts, WithMongo(cfg.Mongodb))
 	}
-
+	dm, err := New(ctx, append(opts, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	dm.Config = &cfg
 	return dm, nil
 }
 
@@ -78,9 +98,28 @@ func (m *Manager) Start(ctx context.Context) error {
 		}
 	}
 
+	// Check MongoDB
+	if m.MongoDB != nil {
+		if err := m.pingMongo(ctx); err != nil {
+			return fmt.Errorf("mongodb ping: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// pingMongo pings the primary, bounded by the server selection timeout so
+// an unreachable cluster fails the check instead of blocking it.
+func (m *Manager) pingMongo(ctx context.Context) error {
+	timeout := m.Config.Mongodb.ServerSelectionTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return m.MongoDB.Client().Ping(ctx, readpref.Primary())
+}
+
 // Stop closes all database connections.
 func (m *Manager) Stop(ctx context.Context) error {
 	var errs []error
@@ -94,6 +133,12 @@ func (m *Manager) Stop(ctx context.Context) error {
 		}
 	}
 
+	if m.Tenants != nil {
+		if err := m.Tenants.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("tenant databases close: %w", err))
+		}
+	}
+
 	// Close Redis
 	if m.Redis != nil {
 		if err := m.Redis.Close(); err != nil {
@@ -101,6 +146,13 @@ func (m *Manager) Stop(ctx context.Context) error {
 		}
 	}
 
+	// Disconnect MongoDB
+	if m.MongoDB != nil {
+		if err := m.MongoDB.Client().Disconnect(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("mongodb disconnect: %w", err))
+		}
+	}
+
 	if len(errs) > 0 {
 		return errs[0]
 	}
@@ -119,12 +171,19 @@ func (m *Manager) Health(ctx context.Context) map[string]error {
 		}
 	}
 
+	// Only the pools currently open are checked.
+	if m.Tenants != nil {
+		for tenantID, err := range m.Tenants.Health(ctx) {
+			health["database:tenant:"+tenantID] = err
+		}
+	}
+
 	if m.Redis != nil {
 		health["redis"] = m.Redis.Ping(ctx).Err()
 	}
 
 	if m.MongoDB != nil {
-		health["mongodb"] = nil // Simplified
+		health["mongodb"] = m.pingMongo(ctx)
 	}
 
 	return health
@@ -141,7 +200,7 @@ func (m *Manager) DBWithContext(ctx context.Context) *gorm.DB {
 // IsEnabled checks if a component is enabled.
 func (m *Manager) IsEnabled(component string) bool {
 	switch component {
-	case "database", "mysql", "postgres":
+	case "database", "mysql", "postgres", "sqlite":
 		return m.DB != nil
 	case "redis":
 		return m.Redis != nil
@@ -152,47 +211,131 @@ func (m *Manager) IsEnabled(component string) bool {
 	}
 }
 
-// NewDialector creates a GORM dialector based on the driver type.
-func NewDialector(cfg config.DatabaseConfig) (gorm.Dialector, error) {
-	switch cfg.Driver {
-	case "mysql", "":
-		charset := cfg.Charset
-		if charset == "" {
-			charset = "utf8mb4"
+// Components lists the connected components of m with their endpoints,
+// for the startup summary (see kit.NewStartupSummary). Endpoints carry no
+// passwords; kit masks them again in case the hosts embed credentials.
+func (m *Manager) Components() []kit.Component {
+	var cfg config.Config
+	if m.Config != nil {
+		cfg = *m.Config
+	}
+	var components []kit.Component
+	if m.DB != nil {
+		c := cfg.Database
+		endpoint := c.Database
+		if c.Driver != "sqlite" {
+			endpoint = net.JoinHostPort(c.Host, strconv.Itoa(c.Port)) + "/" + c.Database
 		}
-		loc := cfg.TimeZone
-		if loc == "" {
-			loc = "Local"
+		components = append(components, kit.Component{Name: "database", Kind: m.DB.Dialector.Name(), Endpoint: endpoint})
+	}
+	if m.Redis != nil {
+		opts := m.Redis.Options()
+		components = append(components, kit.Component{Name: "redis", Kind: "redis",
+			Endpoint: opts.Addr + "/" + strconv.Itoa(opts.DB)})
+	}
+	if m.MongoDB != nil {
+		c := cfg.Mongodb
+		endpoint := config.RedactEndpoint(c.URI)
+		if endpoint == "" {
+			endpoint = net.JoinHostPort(c.Host, strconv.Itoa(c.Port)) + "/" + m.MongoDB.Name()
 		}
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=true&loc=%s",
-			cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Database, charset, loc)
-		return mysql.Open(dsn), nil
-	case "postgres":
-		sslMode := cfg.SSLMode
-		if sslMode == "" {
-			sslMode = "disable"
+		components = append(components, kit.Component{Name: "mongodb", Kind: "mongodb", Endpoint: endpoint})
+	}
+	return components
+}
+
+// RegisterPoolCollectors registers on reg the pool metrics of the SQL
+// database and Redis client of m, labeled name (see
+// metrics.RegisterSQLPoolCollector and metrics.RegisterRedisPoolCollector),
+// so pool waits and timeouts can be watched. They read the connections of
+// m at the time of the call. A nil reg registers on the default registry.
+func (m *Manager) RegisterPoolCollectors(reg prometheus.Registerer, name string) error {
+	if m.DB != nil {
+		sqlDB, err := m.DB.DB()
+		if err != nil {
+			return err
 		}
-		dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-			cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Database, sslMode)
-		if cfg.TimeZone != "" {
-			dsn += fmt.Sprintf(" TimeZone=%s", cfg.TimeZone)
+		if err := metrics.RegisterSQLPoolCollector(reg, name, sqlDB); err != nil {
+			return err
 		}
-		if cfg.Schema != "" {
-			dsn += fmt.Sprintf(" search_path=%s", cfg.Schema)
+	}
+	if m.Redis != nil {
+		if err := metrics.RegisterRedisPoolCollector(reg, name, m.Redis); err != nil {
+			return err
 		}
-		return postgres.Open(dsn), nil
+	}
+	return nil
+}
+
+// NewDialector creates a GORM dialector based on the driver type.
+func NewDialector(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case "mysql", "":
+		return mysql.Open(mysqlDSN(cfg)), nil
+	case "postgres":
+		return postgres.Open(postgresDSN(cfg)), nil
 	case "sqlite":
-		// SQLite uses Database field as file path (e.g., "test.db" or ":memory:")
-		return sqlite.Open(cfg.Database), nil
+		// SQLite uses Database field as file path (e.g., "test.db" or
+		// ":memory:"), with the pragmas of cfg.Sqlite as parameters
+		return sqlite.Open(sqliteDSN(cfg)), nil
 	default:
 		return nil, fmt.Errorf("unsupported database driver: %s", cfg.Driver)
 	}
 }
 
+func mysqlDSN(cfg config.DatabaseConfig) string {
+	charset := cfg.Charset
+	if charset == "" {
+		charset = "utf8mb4"
+	}
+	loc := cfg.TimeZone
+	if loc == "" {
+		loc = "Local"
+	}
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=true&loc=%s",
+		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Database, charset, loc)
+}
+
+func postgresDSN(cfg config.DatabaseConfig) string {
+	sslMode := cfg.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Database, sslMode)
+	if cfg.TimeZone != "" {
+		dsn += fmt.Sprintf(" TimeZone=%s", cfg.TimeZone)
+	}
+	if cfg.Schema != "" {
+		dsn += fmt.Sprintf(" search_path=%s", cfg.Schema)
+	}
+	return dsn
+}
+
 // NewDatabase creates a database connection with connection pooling.
 // logOutput is the writer for SQL logs (use io.Discard to suppress).
 func NewDatabase(cfg config.DatabaseConfig, logOutput io.Writer) (*gorm.DB, error) {
-	dialector, err := NewDialector(cfg)
+	return NewDatabaseWithSecrets(cfg, logOutput, nil)
+}
+
+// NewDatabaseWithSecrets is NewDatabase resolving a password reference
+// with secrets (see config.SecretResolver). The password is resolved
+// whenever the pool opens a connection, so rotated secrets are picked up
+// as connections are recycled.
+func NewDatabaseWithSecrets(cfg config.DatabaseConfig, logOutput io.Writer, secrets *config.SecretResolver) (*gorm.DB, error) {
+	if cfg.Driver == "sqlite" {
+		if err := prepareSqliteFile(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	var dialector gorm.Dialector
+	var err error
+	if (secrets.IsRef(cfg.Password) || cfg.ValidateIdle > 0) && cfg.Driver != "sqlite" {
+		dialector, err = newConnectorDialector(cfg, secrets)
+	} else {
+		dialector, err = NewDialector(cfg)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("create dialector: %w", err)
 	}
@@ -208,7 +351,7 @@ func NewDatabase(cfg config.DatabaseConfig, logOutput io.Writer) (*gorm.DB, erro
 		},
 	)
 
-	db, err := gorm.Open(dialector, &gorm.Config{Logger: newLogger})
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: newLogger, TranslateError: cfg.TranslateErrors})
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
@@ -218,21 +361,30 @@ func NewDatabase(cfg config.DatabaseConfig, logOutput io.Writer) (*gorm.DB, erro
 		configurePool(sqlDB, cfg)
 	}
 
+	if err := db.Use(Plugin{}); err != nil {
+		return nil, fmt.Errorf("register model plugin: %w", err)
+	}
+	if err := db.Use(RequestStatsPlugin{}); err != nil {
+		return nil, fmt.Errorf("register request stats plugin: %w", err)
+	}
+
+	if cfg.DefaultQueryTimeout > 0 {
+		if err := EnableContextDeadlineGuard(db, cfg.DefaultQueryTimeout); err != nil {
+			return nil, fmt.Errorf("enable deadline guard: %w", err)
+		}
+	}
+
 	return db, nil
 }
 
 func configurePool(sqlDB *sql.DB, cfg config.DatabaseConfig) {
-	if cfg.MaxOpenConns > 0 {
-		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	if cfg.MaxOpenConns > 0 || sqliteSingleConnection(cfg) {
+		sqlDB.SetMaxOpenConns(maxOpenConns(cfg))
 	}
 	if cfg.MaxIdleConns > 0 {
 		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
 	}
-	if cfg.MaxLifetime > 0 {
-		sqlDB.SetConnMaxLifetime(time.Duration(cfg.MaxLifetime) * time.Second)
-	} else {
-		sqlDB.SetConnMaxLifetime(5 * time.Minute)
-	}
+	sqlDB.SetConnMaxLifetime(connMaxLifetime(cfg.MaxLifetime))
 	if cfg.ConnMaxIdleTime > 0 {
 		sqlDB.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime) * time.Second)
 	}
@@ -240,27 +392,162 @@ func configurePool(sqlDB *sql.DB, cfg config.DatabaseConfig) {
 
 // NewRedis creates a Redis client.
 func NewRedis(cfg config.RedisConfig) *redis.Client {
-	return redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-		Password: cfg.Password,
-		DB:       cfg.DB,
-		PoolSize: cfg.PoolSize,
-	})
+	return NewRedisWithSecrets(cfg, nil)
 }
 
-// NewMongoDB creates a MongoDB connection.
-// ctx is used for connection timeout.
+// NewRedisWithSecrets is NewRedis resolving a password reference with
+// secrets whenever the client opens a connection. New connections are
+// logged at debug level.
+func NewRedisWithSecrets(cfg config.RedisConfig, secrets *config.SecretResolver) *redis.Client {
+	if cfg.DB == 0 && cfg.Database != 0 {
+		kitlog.DeprecationWarn("config.RedisConfig.Database", "config.RedisConfig.Database is deprecated, use DB")
+		cfg.DB = cfg.Database
+	}
+	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	opts := &redis.Options{
+		Addr:            addr,
+		Password:        cfg.Password,
+		DB:              cfg.DB,
+		PoolSize:        cfg.PoolSize,
+		MinIdleConns:    cfg.MinIdleConns,
+		ConnMaxIdleTime: cfg.ConnMaxIdleTime,
+		ConnMaxLifetime: cfg.ConnMaxLifetime,
+		OnConnect: func(ctx context.Context, _ *redis.Conn) error {
+			slog.DebugContext(ctx, "Redis connection opened", slog.String("addr", addr), slog.Int("db", cfg.DB))
+			return nil
+		},
+	}
+	if secrets.IsRef(cfg.Password) {
+		opts.Password = ""
+		opts.CredentialsProvider = redisCredentials(cfg.Password, secrets)
+	}
+	client := redis.NewClient(opts)
+	if cfg.DefaultQueryTimeout > 0 {
+		client.AddHook(NewRedisDeadlineHook(cfg.DefaultQueryTimeout))
+	}
+	return client
+}
+
+// NewMongoDB creates a MongoDB connection with the options of
+// MongoClientOptions. ctx is used for connection timeout.
 func NewMongoDB(ctx context.Context, cfg config.MongoConfig) (*mongo.Database, error) {
-	uri := "mongodb://"
-	if cfg.User != "" && cfg.Password != "" {
-		uri += cfg.User + ":" + cfg.Password + "@"
+	return NewMongoDBWithSecrets(ctx, cfg, nil)
+}
+
+// NewMongoDBWithSecrets is NewMongoDB resolving references in the URI and
+// password with secrets. They are resolved once, at connect time: the
+// driver keeps the credentials for its own reconnects.
+func NewMongoDBWithSecrets(ctx context.Context, cfg config.MongoConfig, secrets *config.SecretResolver) (*mongo.Database, error) {
+	var err error
+	if cfg.URI, err = secrets.Resolve(ctx, cfg.URI); err != nil {
+		return nil, err
+	}
+	if cfg.Password, err = secrets.Resolve(ctx, cfg.Password); err != nil {
+		return nil, err
+	}
+	opts, err := MongoClientOptions(cfg)
+	if err != nil {
+		return nil, err
 	}
-	uri += fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
 
 	return client.Database(cfg.Database), nil
 }
+
+// MongoClientOptions builds the client options of cfg: cfg.URI, or a URI of
+// Host and Port with User and Password as credentials, then the pool,
+// timeout, read preference, write concern and TLS settings.
+func MongoClientOptions(cfg config.MongoConfig) (*options.ClientOptions, error) {
+	opts := options.Client()
+	if cfg.URI != "" {
+		opts.ApplyURI(cfg.URI)
+	} else {
+		opts.ApplyURI(fmt.Sprintf("mongodb://%s:%d", cfg.Host, cfg.Port))
+		if cfg.User != "" && cfg.Password != "" {
+			opts.SetAuth(options.Credential{Username: cfg.User, Password: cfg.Password})
+		}
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("mongodb uri: %w", err)
+	}
+
+	if cfg.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(cfg.MaxPoolSize)
+	}
+	if cfg.MinPoolSize > 0 {
+		opts.SetMinPoolSize(cfg.MinPoolSize)
+	}
+	if cfg.ConnectTimeout > 0 {
+		opts.SetConnectTimeout(cfg.ConnectTimeout)
+	}
+	if cfg.ServerSelectionTimeout > 0 {
+		opts.SetServerSelectionTimeout(cfg.ServerSelectionTimeout)
+	}
+
+	if cfg.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(cfg.ReadPreference)
+		if err != nil {
+			return nil, fmt.Errorf("mongodb read preference: %w", err)
+		}
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return nil, fmt.Errorf("mongodb read preference: %w", err)
+		}
+		opts.SetReadPreference(rp)
+	}
+
+	if wc := cfg.WriteConcern; wc.W != "" || wc.Journal != nil || wc.WTimeout > 0 {
+		concern := &writeconcern.WriteConcern{Journal: wc.Journal, WTimeout: wc.WTimeout}
+		switch wc.W {
+		case "":
+		case "majority":
+			concern.W = "majority"
+		default:
+			n, err := strconv.Atoi(wc.W)
+			if err != nil {
+				return nil, fmt.Errorf("mongodb write concern: invalid w %q", wc.W)
+			}
+			concern.W = n
+		}
+		opts.SetWriteConcern(concern)
+	}
+
+	if cfg.TLS.Enabled {
+		tlsCfg, err := mongoTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsCfg)
+	}
+	return opts, nil
+}
+
+func mongoTLSConfig(cfg config.MongoTLSConfig) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify, //nolint:gosec // opt-in, warned by config.Check
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("mongodb tls ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("mongodb tls ca: no certificates in %s", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("mongodb tls certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
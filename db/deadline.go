@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	gormutils "gorm.io/gorm/utils"
+)
+
+// deadlineWarnInterval rate-limits missing-deadline warnings per call site.
+const deadlineWarnInterval = time.Minute
+
+// deadlineCancelKey stores the cancel func of a guarded statement.
+const deadlineCancelKey = "go-kit:deadline_cancel"
+
+// deadlineWarner logs at most one warning per call site per interval.
+type deadlineWarner struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func (w *deadlineWarner) warn(site, component string, timeout time.Duration) {
+	now := time.Now()
+	w.mu.Lock()
+	if t, ok := w.last[site]; ok && now.Sub(t) < deadlineWarnInterval {
+		w.mu.Unlock()
+		return
+	}
+	w.last[site] = now
+	w.mu.Unlock()
+
+	slog.Warn("Query context has no deadline; applying default timeout",
+		slog.String("component", component),
+		slog.String("caller", site),
+		slog.Duration("timeout", timeout),
+	)
+}
+
+// EnableContextDeadlineGuard registers callbacks that give every statement
+// whose context has no deadline a default timeout. Each call site that
+// bypasses DBWithContext (or passes a context without deadline) is logged
+// at most once per minute.
+func EnableContextDeadlineGuard(gdb *gorm.DB, timeout time.Duration) error {
+	w := &deadlineWarner{last: make(map[string]time.Time)}
+
+	before := func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if _, ok := ctx.Deadline(); ok {
+			return
+		}
+		w.warn(gormutils.FileWithLineNum(), "database", timeout)
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		db.Statement.Context = ctx
+		db.InstanceSet(deadlineCancelKey, cancel)
+	}
+	after := func(db *gorm.DB) {
+		if v, ok := db.InstanceGet(deadlineCancelKey); ok {
+			v.(context.CancelFunc)()
+		}
+	}
+
+	cb := gdb.Callback()
+	for _, p := range []struct {
+		name          string
+		before, after callbackRegistrar
+	}{
+		{"create", cb.Create().Before("*"), cb.Create().After("*")},
+		{"query", cb.Query().Before("*"), cb.Query().After("*")},
+		{"update", cb.Update().Before("*"), cb.Update().After("*")},
+		{"delete", cb.Delete().Before("*"), cb.Delete().After("*")},
+		{"raw", cb.Raw().Before("*"), cb.Raw().After("*")},
+	} {
+		name := "go-kit:deadline_guard_" + p.name
+		if err := p.before.Register(name+":before", before); err != nil {
+			return err
+		}
+		if err := p.after.Register(name+":after", after); err != nil {
+			return err
+		}
+	}
+
+	// Row/Rows results are consumed after the callbacks return, so the
+	// context is left to expire on its own instead of being canceled.
+	return cb.Row().Before("*").Register("go-kit:deadline_guard_row:before", before)
+}
+
+// callbackRegistrar is the callback registration API of gorm processors.
+type callbackRegistrar interface {
+	Register(name string, fn func(*gorm.DB)) error
+}
+
+// blockingRedisCommands are commands that legitimately wait longer than a
+// query timeout; they are never given a default deadline.
+var blockingRedisCommands = map[string]bool{
+	"blpop": true, "brpop": true, "brpoplpush": true, "blmove": true, "blmpop": true,
+	"bzpopmin": true, "bzpopmax": true, "bzmpop": true,
+	"xread": true, "xreadgroup": true, "wait": true,
+}
+
+// RedisDeadlineHook applies a default timeout to Redis commands whose
+// context has no deadline. Blocking commands are left alone.
+type RedisDeadlineHook struct {
+	timeout time.Duration
+}
+
+// NewRedisDeadlineHook creates a RedisDeadlineHook.
+func NewRedisDeadlineHook(timeout time.Duration) *RedisDeadlineHook {
+	return &RedisDeadlineHook{timeout: timeout}
+}
+
+// DialHook implements redis.Hook.
+func (h *RedisDeadlineHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+// ProcessHook implements redis.Hook.
+func (h *RedisDeadlineHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if _, ok := ctx.Deadline(); ok || blockingRedisCommands[strings.ToLower(cmd.Name())] {
+			return next(ctx, cmd)
+		}
+		ctx, cancel := context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+		return next(ctx, cmd)
+	}
+}
+
+// ProcessPipelineHook implements redis.Hook.
+func (h *RedisDeadlineHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if _, ok := ctx.Deadline(); ok {
+			return next(ctx, cmds)
+		}
+		ctx, cancel := context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+		return next(ctx, cmds)
+	}
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/NSObjects/go-kit/utils"
+	"gorm.io/gorm/schema"
+)
+
+var fieldEncryptor atomic.Pointer[utils.AEAD]
+
+// SetFieldEncryptor sets the AEAD used by EncryptedString and the
+// "encrypted" GORM serializer. It must be called before any encrypted field
+// is read or written.
+func SetFieldEncryptor(a *utils.AEAD) {
+	fieldEncryptor.Store(a)
+}
+
+func currentEncryptor() (*utils.AEAD, error) {
+	a := fieldEncryptor.Load()
+	if a == nil {
+		return nil, errors.New("field encryptor not configured: call db.SetFieldEncryptor")
+	}
+	return a, nil
+}
+
+// EncryptedString is a string column that is encrypted transparently on save
+// and decrypted on load using the encryptor set by SetFieldEncryptor.
+//
+// Usage:
+//
+//	type Integration struct {
+//	    ID           uint
+//	    RefreshToken db.EncryptedString `gorm:"type:text"`
+//	}
+type EncryptedString string
+
+// Value implements driver.Valuer.
+func (s EncryptedString) Value() (driver.Value, error) {
+	a, err := currentEncryptor()
+	if err != nil {
+		return nil, err
+	}
+	return a.EncryptString(string(s))
+}
+
+// Scan implements sql.Scanner.
+func (s *EncryptedString) Scan(value any) error {
+	if value == nil {
+		*s = ""
+		return nil
+	}
+
+	var ciphertext string
+	switch v := value.(type) {
+	case string:
+		ciphertext = v
+	case []byte:
+		ciphertext = string(v)
+	default:
+		return fmt.Errorf("unsupported type for EncryptedString: %T", value)
+	}
+
+	a, err := currentEncryptor()
+	if err != nil {
+		return err
+	}
+	plaintext, err := a.DecryptString(ciphertext)
+	if err != nil {
+		return err
+	}
+	*s = EncryptedString(plaintext)
+	return nil
+}
+
+// EncryptedSerializer is a GORM serializer for plain string fields, enabled
+// with the `gorm:"serializer:encrypted"` tag.
+type EncryptedSerializer struct{}
+
+func init() {
+	schema.RegisterSerializer("encrypted", EncryptedSerializer{})
+}
+
+// Scan implements schema.SerializerInterface.
+func (EncryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue any) error {
+	var s EncryptedString
+	if err := s.Scan(dbValue); err != nil {
+		return err
+	}
+	return field.Set(ctx, dst, string(s))
+}
+
+// Value implements schema.SerializerValuerInterface.
+func (EncryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue any) (any, error) {
+	switch v := fieldValue.(type) {
+	case string:
+		return EncryptedString(v).Value()
+	case *string:
+		if v == nil {
+			return nil, nil
+		}
+		return EncryptedString(*v).Value()
+	default:
+		return nil, fmt.Errorf("encrypted serializer: unsupported type %T", fieldValue)
+	}
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"strings"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"gorm.io/gorm"
+)
+
+// TranslateError converts a GORM/driver error into a coded error:
+// record not found → code.ErrNotFound, unique violations →
+// code.ErrAlreadyExists, deadlines → code.ErrTimeout, anything else →
+// code.ErrDatabase. Errors that already carry a code are returned as is.
+func TranslateError(err error, operation string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.GetCode(err) != 0:
+		return err
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return code.WrapError(err, code.ErrNotFound, "record not found")
+	case isDuplicateKey(err):
+		return code.WrapError(err, code.ErrAlreadyExists, "record already exists")
+	case errors.Is(err, context.DeadlineExceeded):
+		return code.WrapErrorf(err, code.ErrTimeout, "database %s timed out", operation)
+	default:
+		return code.WrapDatabaseError(err, operation)
+	}
+}
+
+// isDuplicateKey detects unique violations, including from connections
+// opened without gorm.Config.TranslateError.
+func isDuplicateKey(err error) bool {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "Duplicate entry") || // MySQL 1062
+		strings.Contains(msg, "duplicate key value") || // PostgreSQL 23505
+		strings.Contains(msg, "UNIQUE constraint failed") // SQLite
+}
+
+// isConstraintViolation detects foreign key, NOT NULL and CHECK
+// violations, including from connections opened without
+// gorm.Config.TranslateError.
+func isConstraintViolation(err error) bool {
+	if errors.Is(err, gorm.ErrForeignKeyViolated) || errors.Is(err, gorm.ErrCheckConstraintViolated) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "foreign key constraint fails") || // MySQL 1451, 1452
+		strings.Contains(msg, "cannot be null") || // MySQL 1048
+		strings.Contains(msg, "Check constraint") || // MySQL 3819
+		strings.Contains(msg, "violates foreign key constraint") || // PostgreSQL 23503
+		strings.Contains(msg, "violates not-null constraint") || // PostgreSQL 23502
+		strings.Contains(msg, "violates check constraint") || // PostgreSQL 23514
+		strings.Contains(msg, "FOREIGN KEY constraint failed") || // SQLite
+		strings.Contains(msg, "NOT NULL constraint failed") || // SQLite
+		strings.Contains(msg, "CHECK constraint failed") // SQLite
+}
+
+// isRepeatedConflict detects an upsert batch holding two rows with the
+// same conflict key, which PostgreSQL refuses to update twice.
+func isRepeatedConflict(err error) bool {
+	return strings.Contains(err.Error(), "cannot affect row a second time") // PostgreSQL 21000
+}
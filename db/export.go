@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// DefaultExportBatchSize is the batch size of ExportRows when none is
+// given.
+const DefaultExportBatchSize = 500
+
+// ExportRows returns the rows of an export (see resp.StreamCSV): query is
+// read batchSize records at a time with FindInBatches, ordered by primary
+// key, and each record is mapped to a row by mapRow, so that memory holds
+// one batch whatever the size of the table. The iteration stops at the
+// first error of yield, such as a client gone, or when ctx is done.
+//
+//	rows := db.ExportRows(ctx, gdb.Model(&Order{}).Where("status = ?", "paid"), 1000,
+//	    func(o Order) []string { return []string{o.Number, o.Total.String()} })
+//	return resp.StreamCSV(c, []string{"number", "total"}, rows)
+func ExportRows[T any](ctx context.Context, query *gorm.DB, batchSize int, mapRow func(T) []string) func(yield func([]string) error) error {
+	if batchSize <= 0 {
+		batchSize = DefaultExportBatchSize
+	}
+	return func(yield func([]string) error) error {
+		var batch []T
+		var yieldErr error
+		res := query.WithContext(ctx).FindInBatches(&batch, batchSize, func(_ *gorm.DB, _ int) error {
+			for _, record := range batch {
+				if err := yield(mapRow(record)); err != nil {
+					yieldErr = err
+					return err
+				}
+			}
+			return ctx.Err()
+		})
+		if yieldErr != nil {
+			return yieldErr
+		}
+		return TranslateError(res.Error, "export")
+	}
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/NSObjects/go-kit/health"
+)
+
+// Checkers returns a health checker per component of m ("database",
+// "redis", "mongodb"), pinging it:
+//
+//	for _, c := range dm.Checkers() {
+//	    registry.Register(c)
+//	}
+func (m *Manager) Checkers() []health.Checker {
+	var checkers []health.Checker
+	if m.DB != nil {
+		checkers = append(checkers, pingChecker{name: "database", ping: func(ctx context.Context) error {
+			sqlDB, err := m.DB.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		}})
+	}
+	if m.Redis != nil {
+		checkers = append(checkers, pingChecker{name: "redis", ping: func(ctx context.Context) error {
+			return m.Redis.Ping(ctx).Err()
+		}})
+	}
+	if m.MongoDB != nil {
+		checkers = append(checkers, pingChecker{name: "mongodb", ping: m.pingMongo})
+	}
+	return checkers
+}
+
+type pingChecker struct {
+	name string
+	ping func(ctx context.Context) error
+}
+
+func (c pingChecker) Name() string { return c.name }
+
+func (c pingChecker) Check(ctx context.Context) health.Check {
+	start := time.Now()
+	err := c.ping(ctx)
+	check := health.Check{Name: c.name, Status: health.StatusHealthy, Latency: time.Since(start)}
+	if err != nil {
+		check.Status = health.StatusUnhealthy
+		check.Message = err.Error()
+	}
+	return check
+}
@@ -0,0 +1,264 @@
+package db
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// JSONField is a JSON column holding a T, stored as JSON on MySQL and
+// SQLite and JSONB on PostgreSQL. A nil V is SQL NULL and JSON null, so a
+// NULL column and an empty document ({} or []) stay distinct.
+//
+// Scanning and unmarshaling always decode into a new T, never merging into
+// or aliasing the previous value or the buffers of the driver. Copies of a
+// JSONField share V; use Get or Clone for an independent copy.
+//
+// When a request struct is bound and validated, the validation tags of T
+// are enforced when the field is present and skipped when it is absent or
+// null:
+//
+//	type Product struct {
+//	    ID    uint
+//	    Attrs db.JSONField[Attrs]
+//	}
+//
+//	type Attrs struct {
+//	    Color string `json:"color" validate:"required"`
+//	    Sizes []int  `json:"sizes"`
+//	}
+type JSONField[T any] struct {
+	V *T
+}
+
+// NewJSONField returns a JSONField holding v.
+func NewJSONField[T any](v T) JSONField[T] {
+	return JSONField[T]{V: &v}
+}
+
+// Valid reports whether f holds a value, i.e. is not NULL.
+func (f JSONField[T]) Valid() bool {
+	return f.V != nil
+}
+
+// Get returns a deep copy of the value of f, or the zero T and false for
+// NULL. The copy is made through a JSON round trip: parts of T that JSON
+// does not encode are not stored by the column either.
+func (f JSONField[T]) Get() (T, bool) {
+	if f.V == nil {
+		var zero T
+		return zero, false
+	}
+	b, err := json.Marshal(f.V)
+	if err != nil {
+		return *f.V, true
+	}
+	var v T
+	if err := json.Unmarshal(b, &v); err != nil {
+		return *f.V, true
+	}
+	return v, true
+}
+
+// Clone returns a JSONField holding a deep copy of the value of f.
+func (f JSONField[T]) Clone() JSONField[T] {
+	v, ok := f.Get()
+	if !ok {
+		return JSONField[T]{}
+	}
+	return JSONField[T]{V: &v}
+}
+
+// Value implements driver.Valuer.
+func (f JSONField[T]) Value() (driver.Value, error) {
+	if f.V == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(f.V)
+	if err != nil {
+		return nil, fmt.Errorf("encode JSON field: %w", err)
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (f *JSONField[T]) Scan(value any) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		f.V = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for JSONField: %T", value)
+	}
+	if err := f.decode(data); err != nil {
+		return fmt.Errorf("decode JSON field: %w", err)
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler; NULL is null.
+func (f JSONField[T]) MarshalJSON() ([]byte, error) {
+	if f.V == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(f.V)
+}
+
+// UnmarshalJSON implements json.Unmarshaler; null is NULL.
+func (f *JSONField[T]) UnmarshalJSON(data []byte) error {
+	return f.decode(data)
+}
+
+// decode sets f to the JSON document data, decoded into a new T.
+func (f *JSONField[T]) decode(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		f.V = nil
+		return nil
+	}
+	v := new(T)
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	f.V = v
+	return nil
+}
+
+// GormDataType implements schema.GormDataTypeInterface.
+func (JSONField[T]) GormDataType() string {
+	return "json"
+}
+
+// GormDBDataType implements migrator.GormDataTypeInterface.
+func (JSONField[T]) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	switch db.Dialector.Name() {
+	case "mysql", "sqlite":
+		return "JSON"
+	case "postgres":
+		return "JSONB"
+	}
+	return ""
+}
+
+// JSONEq returns a predicate matching the rows whose JSON column has value
+// at path, a dot-separated list of object keys:
+//
+//	db.Where(db.JSONEq("attrs", "dimensions.unit", "cm")).Find(&products)
+//
+// Strings compare with the unquoted JSON strings, other values with their
+// JSON encoding (true, 42, 1.5). The predicate is built for the dialect of
+// the statement: JSON_EXTRACT on MySQL, jsonb_extract_path_text on
+// PostgreSQL and json_extract on SQLite; other dialects fail the statement.
+func JSONEq(column, path string, value any) clause.Expression {
+	return jsonPredicate{column: column, keys: strings.Split(path, "."), value: value}
+}
+
+// JSONHasKey returns a predicate matching the rows whose JSON column has
+// path, a dot-separated list of object keys, including keys holding null.
+func JSONHasKey(column, path string) clause.Expression {
+	return jsonPredicate{column: column, keys: strings.Split(path, "."), hasKey: true}
+}
+
+// jsonPredicate is the expression of JSONEq and JSONHasKey.
+type jsonPredicate struct {
+	column string
+	keys   []string
+	value  any
+	hasKey bool
+}
+
+// Build implements clause.Expression.
+func (p jsonPredicate) Build(builder clause.Builder) {
+	stmt, ok := builder.(*gorm.Statement)
+	if !ok {
+		return
+	}
+	col := clause.Column{Name: p.column}
+	switch name := stmt.Dialector.Name(); name {
+	case "mysql":
+		if p.hasKey {
+			builder.WriteString("JSON_EXTRACT(")
+			builder.WriteQuoted(col)
+			builder.WriteString(", ")
+			builder.AddVar(builder, jsonPath(p.keys))
+			builder.WriteString(") IS NOT NULL")
+			return
+		}
+		builder.WriteString("JSON_UNQUOTE(JSON_EXTRACT(")
+		builder.WriteQuoted(col)
+		builder.WriteString(", ")
+		builder.AddVar(builder, jsonPath(p.keys))
+		builder.WriteString(")) = ")
+		builder.AddVar(builder, jsonText(p.value))
+	case "postgres":
+		if p.hasKey {
+			builder.WriteString("jsonb_extract_path(")
+		} else {
+			builder.WriteString("jsonb_extract_path_text(")
+		}
+		builder.WriteQuoted(col)
+		builder.WriteString("::jsonb")
+		for _, key := range p.keys {
+			builder.WriteString(", ")
+			builder.AddVar(builder, key)
+		}
+		if p.hasKey {
+			builder.WriteString(") IS NOT NULL")
+			return
+		}
+		builder.WriteString(") = ")
+		builder.AddVar(builder, jsonText(p.value))
+	case "sqlite":
+		if p.hasKey {
+			builder.WriteString("json_type(")
+		} else {
+			builder.WriteString("json_extract(")
+		}
+		builder.WriteQuoted(col)
+		builder.WriteString(", ")
+		builder.AddVar(builder, jsonPath(p.keys))
+		if p.hasKey {
+			builder.WriteString(") IS NOT NULL")
+			return
+		}
+		builder.WriteString(") = ")
+		builder.AddVar(builder, p.value)
+	default:
+		_ = stmt.AddError(fmt.Errorf("JSON predicates are not supported by %s", name))
+	}
+}
+
+// jsonPath returns the MySQL and SQLite path of keys, e.g. $."a"."b".
+func jsonPath(keys []string) string {
+	var b strings.Builder
+	b.WriteByte('$')
+	for _, key := range keys {
+		b.WriteString(`."`)
+		b.WriteString(strings.ReplaceAll(key, `"`, `\"`))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+// jsonText returns the text of value as extracted from a JSON document:
+// strings as is, other values JSON encoded.
+func jsonText(value any) any {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	return string(b)
+}
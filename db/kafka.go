@@ -25,3 +25,19 @@ type KafkaConfig struct {
 //		sc.Producer.Return.Successes = true
 //		return sarama.NewSyncProducer(cfg.Brokers, sc)
 //	}
+//
+// Kafka-backed pubsub implementations should carry msg.Metadata as record
+// headers and restore them with pubsub.ContextWithMetadata on consumption, so
+// request IDs and trace context survive the hop:
+//
+//	func (p *KafkaBus) Publish(ctx context.Context, msg pubsub.Message) error {
+//		if msg.Metadata == nil {
+//			msg.Metadata = pubsub.MetadataFromContext(ctx)
+//		}
+//		rec := &sarama.ProducerMessage{Topic: msg.Topic, Key: sarama.StringEncoder(msg.Key), Value: sarama.ByteEncoder(msg.Payload)}
+//		for k, v := range msg.Metadata {
+//			rec.Headers = append(rec.Headers, sarama.RecordHeader{Key: []byte(k), Value: []byte(v)})
+//		}
+//		_, _, err := p.producer.SendMessage(rec)
+//		return err
+//	}
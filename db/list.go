@@ -0,0 +1,336 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// List query conventions shared by list endpoints:
+//
+//	GET /users?page=2&size=50&sort=-created_at,name&from=2024-01-01&to=2024-02-01&filter=status:in:active|locked
+//
+// Query field names are mapped to columns through allowlists, so clients
+// never name columns directly, and all values are bound as parameters.
+// Invalid input is reported as code.ValidationErrors (ErrBadRequest).
+//
+//	var q db.ListQuery
+//	if err := c.Bind(&q); err != nil {
+//	    return err
+//	}
+//	scopes, err := q.Scopes(db.ListSpec{
+//	    Sort:        map[string]string{"created_at": "created_at", "name": "name"},
+//	    Filter:      map[string]string{"status": "status", "age": "age"},
+//	    DefaultSort: "-created_at",
+//	    TimeColumn:  "created_at",
+//	    TimeRange:   db.TimeRangeOptions{MaxSpan: 90 * 24 * time.Hour, Location: loc},
+//	})
+//	if err != nil {
+//	    return err
+//	}
+//	users, total, err := repo.List(ctx, q.Pagination, scopes...)
+
+// ListQuery is a list request DTO: pagination, sorting, a time range and
+// filters. Embed it in handler DTOs and bind it from the query string.
+type ListQuery struct {
+	Pagination
+	// Sort is a comma list of fields, "-" prefixed for descending order.
+	Sort string `json:"sort" query:"sort"`
+	// From and To bound the time range (see ParseTimeRange).
+	From string `json:"from" query:"from"`
+	To   string `json:"to" query:"to"`
+	// Filter holds field:op:value expressions (see ParseFilters).
+	Filter []string `json:"filter" query:"filter"`
+}
+
+// ListSpec declares what a list endpoint accepts.
+type ListSpec struct {
+	// Sort maps sortable query fields to columns.
+	Sort map[string]string
+	// Filter maps filterable query fields to columns.
+	Filter map[string]string
+	// DefaultSort applies when the request has no sort.
+	DefaultSort string
+	// TimeColumn is the column From/To apply to; empty disables the range.
+	TimeColumn string
+	// TimeRange configures time range parsing.
+	TimeRange TimeRangeOptions
+}
+
+// Scopes validates q against spec and returns the filter, time range and
+// order scopes. Errors of all parts are aggregated.
+func (q ListQuery) Scopes(spec ListSpec) ([]Scope, error) {
+	sortExpr := q.Sort
+	if sortExpr == "" {
+		sortExpr = spec.DefaultSort
+	}
+	sort, errSort := ParseSort(sortExpr, spec.Sort)
+	filters, errFilter := ParseFilters(q.Filter, spec.Filter)
+
+	var (
+		rng      TimeRange
+		errRange error
+	)
+	if spec.TimeColumn != "" {
+		rng, errRange = ParseTimeRange(q.From, q.To, spec.TimeRange)
+	} else if q.From != "" || q.To != "" {
+		errRange = code.NewFieldError("from", "time range not supported")
+	}
+	if err := utils.MustParams(errSort, errFilter, errRange); err != nil {
+		return nil, err
+	}
+
+	scopes := []Scope{Where(filters)}
+	if spec.TimeColumn != "" {
+		scopes = append(scopes, rng.Scope(spec.TimeColumn))
+	}
+	return append(scopes, OrderBy(sort)), nil
+}
+
+// ========== Sorting ==========
+
+// SortField is one ORDER BY column.
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// ParseSort parses a comma list such as "-created_at,name" into sort
+// fields. A "-" prefix sorts descending. Fields are mapped to columns
+// through allowed; unknown fields are rejected.
+func ParseSort(s string, allowed map[string]string) ([]SortField, error) {
+	var fields []SortField
+	for part := range strings.SplitSeq(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, desc := strings.CutPrefix(part, "-")
+		if !desc {
+			name = strings.TrimPrefix(name, "+")
+		}
+		column, ok := allowed[name]
+		if !ok {
+			return nil, code.NewFieldError("sort", fmt.Sprintf("cannot sort by %q", name))
+		}
+		fields = append(fields, SortField{Column: column, Desc: desc})
+	}
+	return fields, nil
+}
+
+// OrderBy returns a scope ordering by fields.
+func OrderBy(fields []SortField) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(fields) == 0 {
+			return db
+		}
+		columns := make([]clause.OrderByColumn, len(fields))
+		for i, f := range fields {
+			columns[i] = clause.OrderByColumn{Column: clause.Column{Name: f.Column}, Desc: f.Desc}
+		}
+		return db.Order(clause.OrderBy{Columns: columns})
+	}
+}
+
+// ========== Time Range ==========
+
+// TimeRange is a half-open interval [From, To). A zero bound is open.
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// TimeRangeOptions configures ParseTimeRange.
+type TimeRangeOptions struct {
+	// MaxSpan caps To-From; 0 is unlimited. With MaxSpan set, a missing
+	// To defaults to now and a missing From to To-MaxSpan.
+	MaxSpan time.Duration
+	// Location interprets dates and times without a zone, typically the
+	// configured database timezone; default UTC.
+	Location *time.Location
+}
+
+// ParseTimeRange parses from and to, each RFC3339, "2006-01-02T15:04:05"
+// or a date, in opts.Location when they carry no zone. A date in to
+// includes the whole day.
+func ParseTimeRange(from, to string, opts TimeRangeOptions) (TimeRange, error) {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	var (
+		r          TimeRange
+		errs       code.ValidationErrors
+		err        error
+		toIsDate   bool
+		invalidMsg = "expected RFC3339 time or date"
+	)
+	if from != "" {
+		if r.From, _, err = parseRangeTime(from, loc); err != nil {
+			errs = append(errs, code.FieldError{Field: "from", Message: invalidMsg})
+		}
+	}
+	if to != "" {
+		if r.To, toIsDate, err = parseRangeTime(to, loc); err != nil {
+			errs = append(errs, code.FieldError{Field: "to", Message: invalidMsg})
+		} else if toIsDate {
+			r.To = r.To.AddDate(0, 0, 1)
+		}
+	}
+	if len(errs) > 0 {
+		return TimeRange{}, errs
+	}
+
+	if opts.MaxSpan > 0 {
+		if r.To.IsZero() {
+			r.To = time.Now().In(loc)
+		}
+		if r.From.IsZero() {
+			r.From = r.To.Add(-opts.MaxSpan)
+		}
+	}
+	if !r.From.IsZero() && !r.To.IsZero() {
+		if !r.From.Before(r.To) {
+			return TimeRange{}, code.NewFieldError("to", "must be after from")
+		}
+		if opts.MaxSpan > 0 && r.To.Sub(r.From) > opts.MaxSpan {
+			return TimeRange{}, code.NewFieldError("to", "time range exceeds "+opts.MaxSpan.String())
+		}
+	}
+	return r, nil
+}
+
+// parseRangeTime parses s in loc and reports whether it was a date.
+func parseRangeTime(s string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.ParseInLocation(time.DateTime, strings.Replace(s, "T", " ", 1), loc); err == nil {
+		return t, false, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, s, loc)
+	return t, err == nil, err
+}
+
+// Scope returns a scope restricting column to r.
+func (r TimeRange) Scope(column string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		col := clause.Column{Name: column}
+		if !r.From.IsZero() {
+			db = db.Where(clause.Gte{Column: col, Value: r.From})
+		}
+		if !r.To.IsZero() {
+			db = db.Where(clause.Lt{Column: col, Value: r.To})
+		}
+		return db
+	}
+}
+
+// ========== Filters ==========
+
+// FilterOp is a filter comparison.
+type FilterOp string
+
+// Filter operators.
+const (
+	OpEq   FilterOp = "eq"
+	OpNe   FilterOp = "ne"
+	OpGt   FilterOp = "gt"
+	OpLt   FilterOp = "lt"
+	OpIn   FilterOp = "in"   // values separated by "|"
+	OpLike FilterOp = "like" // substring match
+)
+
+var filterOps = []FilterOp{OpEq, OpNe, OpGt, OpLt, OpIn, OpLike}
+
+// Filter is one parsed filter condition.
+type Filter struct {
+	Column string
+	Op     FilterOp
+	Value  any // string, or []any for OpIn
+}
+
+// ParseFilters parses field:op:value expressions, such as "age:gt:18" or
+// "status:in:active|locked". The value may contain ":". Fields are mapped
+// to columns through allowed; unknown fields and operators are rejected.
+func ParseFilters(exprs []string, allowed map[string]string) ([]Filter, error) {
+	var (
+		filters []Filter
+		errs    code.ValidationErrors
+	)
+	for _, expr := range exprs {
+		parts := strings.SplitN(expr, ":", 3)
+		if len(parts) != 3 {
+			errs = append(errs, code.FieldError{Field: "filter", Message: fmt.Sprintf("%q: expected field:op:value", expr)})
+			continue
+		}
+		name, op, value := parts[0], FilterOp(parts[1]), parts[2]
+		column, ok := allowed[name]
+		if !ok {
+			errs = append(errs, code.FieldError{Field: "filter", Message: fmt.Sprintf("cannot filter by %q", name)})
+			continue
+		}
+		if !slices.Contains(filterOps, op) {
+			errs = append(errs, code.FieldError{Field: "filter", Message: fmt.Sprintf("unknown operator %q", op)})
+			continue
+		}
+		f := Filter{Column: column, Op: op, Value: value}
+		if op == OpIn {
+			var values []any
+			for v := range strings.SplitSeq(value, "|") {
+				values = append(values, v)
+			}
+			f.Value = values
+		}
+		filters = append(filters, f)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return filters, nil
+}
+
+// Where returns a scope applying filters, ANDed, as parameterized
+// conditions.
+func Where(filters []Filter) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, f := range filters {
+			db = db.Where(f.expression())
+		}
+		return db
+	}
+}
+
+func (f Filter) expression() clause.Expression {
+	col := clause.Column{Name: f.Column}
+	switch f.Op {
+	case OpNe:
+		return clause.Neq{Column: col, Value: f.Value}
+	case OpGt:
+		return clause.Gt{Column: col, Value: f.Value}
+	case OpLt:
+		return clause.Lt{Column: col, Value: f.Value}
+	case OpIn:
+		values, _ := f.Value.([]any)
+		return clause.IN{Column: col, Values: values}
+	case OpLike:
+		return clause.Like{Column: col, Value: "%" + escapeLike(fmt.Sprint(f.Value)) + "%"}
+	default:
+		return clause.Eq{Column: col, Value: f.Value}
+	}
+}
+
+// likeEscaper escapes LIKE wildcards so values match literally. The
+// backslash is the default escape character of MySQL and PostgreSQL.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,184 @@
+// Package mongox provides MongoDB repository helpers with coded errors and
+// pagination on top of the official driver.
+//
+//	user, err := mongox.FindOne[User](ctx, db.Collection("users"), bson.M{"_id": id})
+//	if errors.IsCode(err, code.ErrNotFound) { ... }
+//
+//	page, err := mongox.FindPage[User](ctx, coll, bson.M{"active": true},
+//	    bson.D{{Key: "created_at", Value: -1}}, mongox.PageRequest{Page: 1, Size: 20})
+package mongox
+
+import (
+	"context"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MaxPageSize caps PageRequest.Size.
+const MaxPageSize = 1000
+
+// PageRequest selects a page. Page is 1-based; Size defaults to 20.
+type PageRequest struct {
+	Page int `json:"page" query:"page"`
+	Size int `json:"size" query:"size"`
+}
+
+func (p PageRequest) normalize() PageRequest {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.Size < 1 {
+		p.Size = 20
+	}
+	if p.Size > MaxPageSize {
+		p.Size = MaxPageSize
+	}
+	return p
+}
+
+// Page is a page of results with the total number of matching documents.
+type Page[T any] struct {
+	Items []T   `json:"items"`
+	Total int64 `json:"total"`
+	Page  int   `json:"page"`
+	Size  int   `json:"size"`
+}
+
+// TranslateError converts a driver error into a coded error:
+// mongo.ErrNoDocuments → code.ErrNotFound, duplicate key (E11000) →
+// code.ErrAlreadyExists, timeouts and deadlines → code.ErrTimeout, anything
+// else → code.ErrDatabase. Errors that already carry a code are returned as is.
+func TranslateError(err error, operation string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.GetCode(err) != 0:
+		return err
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return code.WrapError(err, code.ErrNotFound, "document not found")
+	case mongo.IsDuplicateKeyError(err):
+		return code.WrapError(err, code.ErrAlreadyExists, "document already exists")
+	case mongo.IsTimeout(err), errors.Is(err, context.DeadlineExceeded):
+		return code.WrapErrorf(err, code.ErrTimeout, "mongodb %s timed out", operation)
+	default:
+		return code.WrapErrorf(err, code.ErrDatabase, "mongodb %s failed", operation)
+	}
+}
+
+// FindOne returns the first document matching filter.
+func FindOne[T any](ctx context.Context, coll *mongo.Collection, filter any, opts ...*options.FindOneOptions) (T, error) {
+	var out T
+	if err := coll.FindOne(ctx, filter, opts...).Decode(&out); err != nil {
+		var zero T
+		return zero, TranslateError(err, "find one")
+	}
+	return out, nil
+}
+
+// FindPage returns one page of documents matching filter ordered by sort
+// (optional), together with the total count, in a single $facet aggregation.
+func FindPage[T any](ctx context.Context, coll *mongo.Collection, filter any, sort bson.D, page PageRequest) (Page[T], error) {
+	page = page.normalize()
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	items := bson.A{}
+	if len(sort) > 0 {
+		items = append(items, bson.D{{Key: "$sort", Value: sort}})
+	}
+	items = append(items,
+		bson.D{{Key: "$skip", Value: int64(page.Page-1) * int64(page.Size)}},
+		bson.D{{Key: "$limit", Value: int64(page.Size)}},
+	)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$facet", Value: bson.D{
+			{Key: "items", Value: items},
+			{Key: "total", Value: bson.A{bson.D{{Key: "$count", Value: "count"}}}},
+		}}},
+	}
+
+	cur, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return Page[T]{}, TranslateError(err, "find page")
+	}
+	defer cur.Close(ctx)
+
+	var result []struct {
+		Items []T `bson:"items"`
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+	}
+	if err := cur.All(ctx, &result); err != nil {
+		return Page[T]{}, TranslateError(err, "find page")
+	}
+
+	out := Page[T]{Items: []T{}, Page: page.Page, Size: page.Size}
+	if len(result) > 0 {
+		if result[0].Items != nil {
+			out.Items = result[0].Items
+		}
+		if len(result[0].Total) > 0 {
+			out.Total = result[0].Total[0].Count
+		}
+	}
+	return out, nil
+}
+
+// InsertOne inserts doc and returns its _id.
+func InsertOne(ctx context.Context, coll *mongo.Collection, doc any, opts ...*options.InsertOneOptions) (any, error) {
+	res, err := coll.InsertOne(ctx, doc, opts...)
+	if err != nil {
+		return nil, TranslateError(err, "insert")
+	}
+	return res.InsertedID, nil
+}
+
+// UpdateOne updates the first document matching filter. It returns
+// code.ErrNotFound when nothing matched and nothing was upserted.
+func UpdateOne(ctx context.Context, coll *mongo.Collection, filter, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	res, err := coll.UpdateOne(ctx, filter, update, opts...)
+	if err != nil {
+		return nil, TranslateError(err, "update")
+	}
+	if res.MatchedCount == 0 && res.UpsertedCount == 0 {
+		return res, code.NewNotFoundError("document")
+	}
+	return res, nil
+}
+
+// DeleteOne deletes the first document matching filter. It returns
+// code.ErrNotFound when nothing matched.
+func DeleteOne(ctx context.Context, coll *mongo.Collection, filter any, opts ...*options.DeleteOptions) error {
+	res, err := coll.DeleteOne(ctx, filter, opts...)
+	if err != nil {
+		return TranslateError(err, "delete")
+	}
+	if res.DeletedCount == 0 {
+		return code.NewNotFoundError("document")
+	}
+	return nil
+}
+
+// WithMongoSession runs fn inside a multi-document transaction. It requires
+// a replica set or sharded cluster. The driver retries fn on transient
+// transaction errors, so fn must be idempotent and must use the session
+// context for all operations.
+func WithMongoSession(ctx context.Context, db *mongo.Database, fn func(sc mongo.SessionContext) error) error {
+	sess, err := db.Client().StartSession()
+	if err != nil {
+		return TranslateError(err, "start session")
+	}
+	defer sess.EndSession(ctx)
+
+	_, err = sess.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		return nil, fn(sc)
+	})
+	return TranslateError(err, "transaction")
+}
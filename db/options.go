@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/lifecycle"
+)
+
+// Option configures a Manager created with New.
+type Option func(*managerOptions)
+
+type managerOptions struct {
+	database  *config.DatabaseConfig
+	redis     *config.RedisConfig
+	mongo     *config.MongoConfig
+	secrets   *config.SecretResolver
+	logOutput io.Writer
+	env       string
+	checkOnly bool
+}
+
+// WithDatabase opens the SQL database of cfg (MySQL, PostgreSQL or
+// SQLite).
+func WithDatabase(cfg config.DatabaseConfig) Option {
+	return func(o *managerOptions) { o.database = &cfg }
+}
+
+// WithRedis creates the Redis client of cfg.
+func WithRedis(cfg config.RedisConfig) Option {
+	return func(o *managerOptions) { o.redis = &cfg }
+}
+
+// WithMongo connects to the MongoDB database of cfg.
+func WithMongo(cfg config.MongoConfig) Option {
+	return func(o *managerOptions) { o.mongo = &cfg }
+}
+
+// WithSecrets resolves password references with r (see
+// config.SecretResolver); default a resolver with the built-in providers.
+func WithSecrets(r *config.SecretResolver) Option {
+	return func(o *managerOptions) { o.secrets = r }
+}
+
+// WithLogOutput sets the writer of SQL logs; default os.Stdout.
+func WithLogOutput(w io.Writer) Option {
+	return func(o *managerOptions) { o.logOutput = w }
+}
+
+// WithEnv sets the environment of the service, such as "dev", which
+// enables EXPLAIN in the slow query log (see EnableSlowQueryLog).
+func WithEnv(env string) Option {
+	return func(o *managerOptions) { o.env = env }
+}
+
+// WithCheckOnly opens the components for health checks only, as by a
+// diagnostics command next to the running service: a single connection
+// per pool, no SQL logs, no slow query log and no lifecycle hooks.
+func WithCheckOnly() Option {
+	return func(o *managerOptions) { o.checkOnly = true }
+}
+
+// New creates a Manager of the components given as options only, so that
+// a service using Redis alone never opens a database:
+//
+//	m, err := db.New(ctx, db.WithRedis(cfg.Redis))
+//
+// Manager.Config holds the configuration of the components. ctx is used
+// for connection timeouts during initialization. When ctx carries a
+// lifecycle.Lifecycle (see lifecycle.NewContext), the Manager registers
+// its Start and Stop with it.
+func New(ctx context.Context, opts ...Option) (*Manager, error) {
+	var o managerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.secrets == nil {
+		o.secrets = config.NewSecretResolver(config.SecretsConfig{})
+	}
+	if o.logOutput == nil {
+		o.logOutput = os.Stdout
+	}
+	if o.checkOnly {
+		o.logOutput = io.Discard
+		o.shrinkPools()
+	}
+
+	cfg := &config.Config{System: config.SystemConfig{Env: o.env}}
+	m := &Manager{Config: cfg, Secrets: o.secrets}
+
+	if o.database != nil {
+		cfg.Database = *o.database
+		gdb, err := NewDatabaseWithSecrets(cfg.Database, o.logOutput, m.Secrets)
+		if err != nil {
+			return nil, fmt.Errorf("database init: %w", err)
+		}
+		if !o.checkOnly {
+			if err := EnableSlowQueryLog(gdb, cfg.Database.SlowQuery, o.env); err != nil {
+				return nil, fmt.Errorf("enable slow query log: %w", err)
+			}
+		}
+		m.DB = gdb
+	}
+
+	if o.redis != nil {
+		cfg.Redis = *o.redis
+		m.Redis = NewRedisWithSecrets(cfg.Redis, m.Secrets)
+	}
+
+	if o.mongo != nil {
+		cfg.Mongodb = *o.mongo
+		mdb, err := NewMongoDBWithSecrets(ctx, cfg.Mongodb, m.Secrets)
+		if err != nil {
+			_ = m.Stop(ctx)
+			return nil, fmt.Errorf("mongodb init: %w", err)
+		}
+		m.MongoDB = mdb
+	}
+
+	if lc := lifecycle.FromContext(ctx); lc != nil && !o.checkOnly {
+		lc.Append(lifecycle.Hook{Name: "db", OnStart: m.Start, OnStop: m.Stop})
+	}
+	return m, nil
+}
+
+// shrinkPools limits the pools of the components to one connection.
+func (o *managerOptions) shrinkPools() {
+	if o.database != nil {
+		c := *o.database
+		c.MaxOpenConns, c.MaxIdleConns = 1, 1
+		o.database = &c
+	}
+	if o.redis != nil {
+		c := *o.redis
+		c.PoolSize, c.MinIdleConns = 1, 0
+		o.redis = &c
+	}
+	if o.mongo != nil {
+		c := *o.mongo
+		c.MaxPoolSize, c.MinPoolSize = 1, 0
+		o.mongo = &c
+	}
+}
@@ -0,0 +1,209 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/metrics"
+	"github.com/NSObjects/go-kit/pubsub"
+	"github.com/NSObjects/go-kit/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxEntry is a pending event written in the same transaction as the
+// business change it describes.
+type OutboxEntry struct {
+	ID          uint64     `gorm:"primaryKey;autoIncrement"`
+	Topic       string     `gorm:"size:255;not null"`
+	Key         string     `gorm:"size:255"`
+	Payload     []byte     `gorm:"not null"`
+	Metadata    string     `gorm:"type:text"`
+	CreatedAt   time.Time  `gorm:"not null;index"`
+	PublishedAt *time.Time `gorm:"index"`
+}
+
+// TableName implements gorm's Tabler.
+func (OutboxEntry) TableName() string {
+	return "outbox_entries"
+}
+
+// OutboxEnqueue stores an event in the outbox using tx. Call it inside
+// WithTransaction so the event is persisted atomically with the business data.
+// The request ID and trace context of tx's context are stored as metadata.
+//
+// Usage:
+//
+//	err := db.WithTransaction(ctx, m.DB, func(tx *gorm.DB) error {
+//	    if err := tx.Create(&order).Error; err != nil {
+//	        return err
+//	    }
+//	    return db.OutboxEnqueue(tx, "order.created", order.No, order)
+//	})
+func OutboxEnqueue(tx *gorm.DB, topic, key string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return code.WrapError(err, code.ErrEncodingJSON, "encode outbox payload")
+	}
+
+	var metadata string
+	if tx.Statement != nil && tx.Statement.Context != nil {
+		if md := pubsub.MetadataFromContext(tx.Statement.Context); len(md) > 0 {
+			b, _ := json.Marshal(md)
+			metadata = string(b)
+		}
+	}
+
+	entry := OutboxEntry{
+		Topic:     topic,
+		Key:       key,
+		Payload:   data,
+		Metadata:  metadata,
+		CreatedAt: time.Now(),
+	}
+	return code.WrapDatabaseError(tx.Create(&entry).Error, "outbox enqueue")
+}
+
+// OutboxRelayOptions configures OutboxRelay.
+type OutboxRelayOptions struct {
+	// PollInterval between polls when the outbox is drained; default 1s.
+	PollInterval time.Duration
+	// BatchSize is the number of entries claimed per poll; default 100.
+	BatchSize int
+	// Retention keeps published entries for this long before deletion;
+	// default 24h. Negative deletes entries right after publishing.
+	Retention time.Duration
+	// Metrics records publish counts, failures and lag (optional).
+	Metrics *metrics.OutboxMetrics
+}
+
+// OutboxRelay publishes outbox entries until ctx is canceled.
+//
+// Entries are claimed in batches with SELECT ... FOR UPDATE SKIP LOCKED (on
+// MySQL and PostgreSQL) so several replicas can relay concurrently. Delivery
+// is at-least-once: an entry is marked published only after the producer
+// accepted it, so a crash between publish and commit re-publishes at most one
+// batch. Entries are published in ID order; a failure stops the batch so
+// later entries are not published ahead of it.
+func OutboxRelay(ctx context.Context, m *Manager, producer pubsub.Publisher, opts OutboxRelayOptions) error {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.Retention == 0 {
+		opts.Retention = 24 * time.Hour
+	}
+
+	lastCleanup := time.Now()
+	for {
+		n, err := relayBatch(ctx, m.DB, producer, opts)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Outbox relay failed", slog.String("error", err.Error()))
+		}
+
+		if opts.Metrics != nil {
+			opts.Metrics.Lag.Set(outboxLag(ctx, m.DB).Seconds())
+		}
+
+		if time.Since(lastCleanup) >= time.Minute {
+			cleanupOutbox(ctx, m.DB, opts.Retention)
+			lastCleanup = time.Now()
+		}
+
+		// Keep draining while full batches are returned.
+		if n == opts.BatchSize && err == nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.PollInterval):
+		}
+	}
+}
+
+func relayBatch(ctx context.Context, db *gorm.DB, producer pubsub.Publisher, opts OutboxRelayOptions) (int, error) {
+	published := 0
+	var pubErr error
+	err := WithTransaction(ctx, db, func(tx *gorm.DB) error {
+		q := tx.Where("published_at IS NULL").Order("id").Limit(opts.BatchSize)
+		if tx.Dialector.Name() != "sqlite" {
+			q = q.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+
+		var entries []OutboxEntry
+		if err := q.Find(&entries).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var ids []uint64
+		for _, e := range entries {
+			msg := pubsub.Message{Topic: e.Topic, Key: e.Key, Payload: e.Payload}
+			if e.Metadata != "" {
+				_ = json.Unmarshal([]byte(e.Metadata), &msg.Metadata)
+			}
+			// Each relay gets its own request ID; the message keeps the
+			// request ID of the transaction that enqueued it.
+			pubCtx := utils.WithNewRequestID(pubsub.ContextWithMetadata(ctx, msg.Metadata))
+			if pubErr = producer.Publish(pubCtx, msg); pubErr != nil {
+				if opts.Metrics != nil {
+					opts.Metrics.Failures.WithLabelValues(e.Topic).Inc()
+				}
+				break
+			}
+			if opts.Metrics != nil {
+				opts.Metrics.Published.WithLabelValues(e.Topic).Inc()
+			}
+			ids = append(ids, e.ID)
+		}
+
+		if len(ids) > 0 {
+			var err error
+			if opts.Retention < 0 {
+				err = tx.Delete(&OutboxEntry{}, ids).Error
+			} else {
+				err = tx.Model(&OutboxEntry{}).Where("id IN ?", ids).Update("published_at", now).Error
+			}
+			if err != nil {
+				return err
+			}
+		}
+		published = len(ids)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return published, pubErr
+}
+
+func outboxLag(ctx context.Context, db *gorm.DB) time.Duration {
+	var oldest OutboxEntry
+	err := db.WithContext(ctx).Where("published_at IS NULL").Order("id").Limit(1).Find(&oldest).Error
+	if err != nil || oldest.ID == 0 {
+		return 0
+	}
+	return time.Since(oldest.CreatedAt)
+}
+
+func cleanupOutbox(ctx context.Context, db *gorm.DB, retention time.Duration) {
+	if retention < 0 {
+		return
+	}
+	err := db.WithContext(ctx).
+		Where("published_at IS NOT NULL AND published_at < ?", time.Now().Add(-retention)).
+		Delete(&OutboxEntry{}).Error
+	if err != nil && ctx.Err() == nil {
+		slog.Warn("Outbox cleanup failed", slog.String("error", err.Error()))
+	}
+}
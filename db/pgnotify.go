@@ -0,0 +1,435 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/health"
+	"github.com/NSObjects/go-kit/lifecycle"
+	kitlog "github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/pubsub"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// PGListener is the dedicated connection PGNotifier listens on. The
+// default, dialed with the DSN, is a *pgx.Conn.
+type PGListener interface {
+	Listen(ctx context.Context, channel string) error
+	Unlisten(ctx context.Context, channel string) error
+	// WaitForNotification blocks until a notification arrives. A canceled
+	// ctx must leave the connection usable.
+	WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+	Close(ctx context.Context) error
+}
+
+// PGNotifierOptions configures a PGNotifier. Zero values use the defaults.
+type PGNotifierOptions struct {
+	// Prefix of the channel names; default "events:", like RedisBus.
+	Prefix string
+	// Dial opens the listener connection; default pgx.Connect with the
+	// DSN.
+	Dial func(ctx context.Context) (PGListener, error)
+	// InitialBackoff is the delay before the first reconnect; default 1s.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between reconnects; default 30s.
+	MaxBackoff time.Duration
+	// OnResync is called with the subscribed topics after a reconnect,
+	// since notifications sent while disconnected are lost. Reload what
+	// the notifications invalidate, e.g. Casbin policies or cache tags.
+	OnResync func(ctx context.Context, topics []string)
+	// Buffer is the number of notifications queued per subscription
+	// before new ones are dropped; default 64.
+	Buffer int
+}
+
+func (o PGNotifierOptions) withDefaults() PGNotifierOptions {
+	if o.Prefix == "" {
+		o.Prefix = "events:"
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 30 * time.Second
+	}
+	if o.Buffer <= 0 {
+		o.Buffer = 64
+	}
+	return o
+}
+
+// PGNotifier implements pubsub.Bus with PostgreSQL LISTEN/NOTIFY, for
+// cross-instance invalidation in deployments without Redis:
+//
+//	bus := db.NewPGNotifier(m.DB, cfg.Postgres.DSN(), db.PGNotifierOptions{
+//	    OnResync: func(ctx context.Context, topics []string) { _ = enforcer.LoadPolicy() },
+//	})
+//	defer bus.Close()
+//	registry.Register(bus.Checker())
+//
+// Messages are published with pg_notify. Like RedisBus, delivery is
+// at-most-once: notifications sent while the listener is reconnecting are
+// lost, which OnResync compensates for. PostgreSQL limits payloads to
+// 8000 bytes and channel names (prefix included) to 63.
+//
+// All subscriptions share one listener connection, which reconnects with
+// exponential backoff.
+type PGNotifier struct {
+	db   *gorm.DB
+	opts PGNotifierOptions
+
+	mu         sync.Mutex
+	subs       map[string][]*pgSubscription // channel -> subscriptions
+	wake       chan struct{}
+	cancelWait context.CancelFunc
+	stop       context.CancelFunc
+	done       <-chan struct{}
+	closed     bool
+
+	connected atomic.Bool
+	lastErr   atomic.Pointer[error]
+}
+
+type pgSubscription struct {
+	ch chan pubsub.Message
+}
+
+// NewPGNotifier creates a PGNotifier publishing through gdb and listening
+// on a dedicated connection to dsn.
+func NewPGNotifier(gdb *gorm.DB, dsn string, opts ...PGNotifierOptions) *PGNotifier {
+	var o PGNotifierOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Dial == nil {
+		o.Dial = func(ctx context.Context) (PGListener, error) {
+			conn, err := pgx.Connect(ctx, dsn)
+			if err != nil {
+				return nil, err
+			}
+			return pgxListener{conn}, nil
+		}
+	}
+	return &PGNotifier{
+		db:   gdb,
+		opts: o.withDefaults(),
+		subs: make(map[string][]*pgSubscription),
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// Publish implements pubsub.Publisher with pg_notify. Messages without
+// metadata get the request and trace information of ctx.
+func (n *PGNotifier) Publish(ctx context.Context, msg pubsub.Message) error {
+	if msg.Metadata == nil {
+		msg.Metadata = pubsub.MetadataFromContext(ctx)
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return code.WrapError(err, code.ErrEncodingJSON, "encode message")
+	}
+	return n.Notify(ctx, msg.Topic, string(data))
+}
+
+// Notify sends payload on the channel of topic as is, for listeners
+// outside the kit.
+func (n *PGNotifier) Notify(ctx context.Context, topic, payload string) error {
+	err := n.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", n.opts.Prefix+topic, payload).Error
+	return code.WrapDatabaseError(err, "notify")
+}
+
+// Subscribe implements pubsub.Subscriber. Messages are handled
+// sequentially in arrival order, with their metadata restored into the
+// handler's context. It returns when ctx is canceled or the notifier is
+// closed; connection failures are retried, not returned.
+func (n *PGNotifier) Subscribe(ctx context.Context, topic string, handler pubsub.Handler) error {
+	channel := n.opts.Prefix + topic
+	sub := &pgSubscription{ch: make(chan pubsub.Message, n.opts.Buffer)}
+
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.subs[channel] = append(n.subs[channel], sub)
+	n.startLocked()
+	n.wakeLocked()
+	n.mu.Unlock()
+	defer n.unsubscribe(channel, sub)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-sub.ch:
+			if !ok {
+				return nil
+			}
+			_ = handler(pubsub.ContextWithMetadata(ctx, msg.Metadata), msg)
+		}
+	}
+}
+
+// Connected reports whether the listener connection is up.
+func (n *PGNotifier) Connected() bool {
+	return n.connected.Load()
+}
+
+// Checker returns a health checker reporting degraded status while the
+// listener is disconnected: publishing still works, but this instance
+// misses invalidations until it reconnects.
+func (n *PGNotifier) Checker() health.Checker {
+	return pgNotifierChecker{n: n}
+}
+
+// Close stops the listener and ends all subscriptions.
+func (n *PGNotifier) Close() error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.closed = true
+	stop, done := n.stop, n.done
+	for channel, subs := range n.subs {
+		for _, sub := range subs {
+			close(sub.ch)
+		}
+		delete(n.subs, channel)
+	}
+	n.mu.Unlock()
+
+	if stop != nil {
+		stop()
+		<-done
+	}
+	return nil
+}
+
+// startLocked starts the listener loop on the first subscription.
+func (n *PGNotifier) startLocked() {
+	if n.stop != nil {
+		return
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	n.stop = stop
+	n.done = lifecycle.Go(ctx, "db.pg_notifier", func(ctx context.Context) error {
+		n.run(ctx)
+		return nil
+	}).Done()
+}
+
+// wakeLocked interrupts the current wait so the loop updates its LISTEN
+// set.
+func (n *PGNotifier) wakeLocked() {
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+	if n.cancelWait != nil {
+		n.cancelWait()
+	}
+}
+
+func (n *PGNotifier) unsubscribe(channel string, sub *pgSubscription) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	subs := n.subs[channel]
+	for i, s := range subs {
+		if s == sub {
+			n.subs[channel] = append(subs[:i:i], subs[i+1:]...)
+			break
+		}
+	}
+	if len(n.subs[channel]) == 0 {
+		delete(n.subs, channel)
+	}
+	n.wakeLocked()
+}
+
+// run keeps a listener connection open until ctx is canceled.
+func (n *PGNotifier) run(ctx context.Context) {
+	backoff := n.opts.InitialBackoff
+	reconnect := false
+	for ctx.Err() == nil {
+		err := n.session(ctx, reconnect, func() { backoff = n.opts.InitialBackoff })
+		n.connected.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+		n.lastErr.Store(&err)
+		slog.Warn("Postgres listener disconnected, reconnecting",
+			slog.Duration("backoff", backoff), kitlog.Err(err))
+		reconnect = true
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, n.opts.MaxBackoff)
+	}
+}
+
+// session dials a connection and dispatches its notifications until it
+// fails or ctx is canceled. connected is called once LISTEN succeeded.
+func (n *PGNotifier) session(ctx context.Context, resync bool, connected func()) error {
+	conn, err := n.opts.Dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	listening := make(map[string]bool)
+	first := true
+	for {
+		if err := n.syncListens(ctx, conn, listening); err != nil {
+			return err
+		}
+		if first {
+			first = false
+			n.connected.Store(true)
+			connected()
+			if resync && n.opts.OnResync != nil {
+				n.opts.OnResync(ctx, n.topics(listening))
+			}
+		}
+
+		waitCtx, cancel := context.WithCancel(ctx)
+		n.mu.Lock()
+		n.cancelWait = cancel
+		n.mu.Unlock()
+		select {
+		case <-n.wake:
+			cancel()
+		default:
+		}
+
+		note, err := conn.WaitForNotification(waitCtx)
+		woken := waitCtx.Err() != nil
+		cancel()
+		n.mu.Lock()
+		n.cancelWait = nil
+		n.mu.Unlock()
+
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case note != nil:
+			n.dispatch(note)
+		case err != nil && !woken:
+			return err
+		}
+	}
+}
+
+// syncListens issues LISTEN and UNLISTEN so conn listens to exactly the
+// subscribed channels.
+func (n *PGNotifier) syncListens(ctx context.Context, conn PGListener, listening map[string]bool) error {
+	n.mu.Lock()
+	want := make(map[string]bool, len(n.subs))
+	for channel := range n.subs {
+		want[channel] = true
+	}
+	n.mu.Unlock()
+
+	for channel := range want {
+		if !listening[channel] {
+			if err := conn.Listen(ctx, channel); err != nil {
+				return err
+			}
+			listening[channel] = true
+		}
+	}
+	for channel := range listening {
+		if !want[channel] {
+			if err := conn.Unlisten(ctx, channel); err != nil {
+				return err
+			}
+			delete(listening, channel)
+		}
+	}
+	return nil
+}
+
+// dispatch queues note for the subscriptions of its channel, dropping it
+// for subscriptions whose queue is full.
+func (n *PGNotifier) dispatch(note *pgconn.Notification) {
+	var msg pubsub.Message
+	if err := json.Unmarshal([]byte(note.Payload), &msg); err != nil {
+		slog.Error("Malformed message dropped",
+			slog.String("channel", note.Channel),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, sub := range n.subs[note.Channel] {
+		select {
+		case sub.ch <- msg:
+		default:
+			slog.Warn("Subscription queue full, message dropped", slog.String("channel", note.Channel))
+		}
+	}
+}
+
+func (n *PGNotifier) topics(listening map[string]bool) []string {
+	topics := make([]string, 0, len(listening))
+	for channel := range listening {
+		topics = append(topics, channel[len(n.opts.Prefix):])
+	}
+	return topics
+}
+
+// pgxListener adapts *pgx.Conn to PGListener.
+type pgxListener struct {
+	conn *pgx.Conn
+}
+
+func (l pgxListener) Listen(ctx context.Context, channel string) error {
+	_, err := l.conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize())
+	return err
+}
+
+func (l pgxListener) Unlisten(ctx context.Context, channel string) error {
+	_, err := l.conn.Exec(ctx, "UNLISTEN "+pgx.Identifier{channel}.Sanitize())
+	return err
+}
+
+func (l pgxListener) WaitForNotification(ctx context.Context) (*pgconn.Notification, error) {
+	return l.conn.WaitForNotification(ctx)
+}
+
+func (l pgxListener) Close(ctx context.Context) error {
+	return l.conn.Close(ctx)
+}
+
+type pgNotifierChecker struct {
+	n *PGNotifier
+}
+
+func (c pgNotifierChecker) Name() string { return "pg_notifier" }
+
+func (c pgNotifierChecker) Check(ctx context.Context) health.Check {
+	check := health.Check{Name: c.Name(), Status: health.StatusHealthy}
+	c.n.mu.Lock()
+	idle := c.n.stop == nil
+	c.n.mu.Unlock()
+	if !idle && !c.n.Connected() {
+		check.Status = health.StatusDegraded
+		check.Message = "listener disconnected"
+		if err := c.n.lastErr.Load(); err != nil && *err != nil {
+			check.Message += ": " + (*err).Error()
+		}
+	}
+	return check
+}
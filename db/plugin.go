@@ -0,0 +1,169 @@
+package db
+
+import (
+	"reflect"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// OptimisticLock adds a version column to a model. With Plugin registered,
+// updates of a loaded record (Save, Model(&m).Updates) only match the row
+// when its version is unchanged, and increment it; a stale record fails
+// with code.ErrConflict. Updates through a zero model, such as
+// Model(&User{}).Where(...), are not version checked.
+//
+//	type Order struct {
+//	    ID uint
+//	    db.OptimisticLock
+//	    db.AuditColumns
+//	}
+type OptimisticLock struct {
+	Version int64 `gorm:"not null;default:1" json:"version"`
+}
+
+func (*OptimisticLock) optimisticLock() {}
+
+// AuditColumns adds created_by/updated_by columns to a model. With Plugin
+// registered they are set from the user_id in the statement context (see
+// DBWithContext and utils.BuildContext).
+type AuditColumns struct {
+	CreatedBy string `gorm:"size:64" json:"created_by"`
+	UpdatedBy string `gorm:"size:64" json:"updated_by"`
+}
+
+func (*AuditColumns) auditColumns() {}
+
+type optimisticLocker interface{ optimisticLock() }
+
+type auditor interface{ auditColumns() }
+
+// Plugin is a GORM plugin maintaining OptimisticLock and AuditColumns.
+// NewDatabase registers it; models without the mixins are unaffected.
+//
+//	err := gdb.Use(db.Plugin{})
+type Plugin struct{}
+
+// Name implements gorm.Plugin.
+func (Plugin) Name() string {
+	return "go-kit:model"
+}
+
+// Initialize implements gorm.Plugin.
+func (Plugin) Initialize(gdb *gorm.DB) error {
+	cb := gdb.Callback()
+	if err := cb.Create().Before("gorm:create").Register("go-kit:model_create", beforeCreate); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("go-kit:model_update", beforeUpdate); err != nil {
+		return err
+	}
+	return cb.Update().After("gorm:update").Register("go-kit:model_update_check", afterUpdate)
+}
+
+// lockVersionKey stores the version an update was checked against.
+const lockVersionKey = "go-kit:lock_version"
+
+func beforeCreate(db *gorm.DB) {
+	stmt := db.Statement
+	if stmt.Schema == nil {
+		return
+	}
+	lock, audit := mixins(stmt.Schema)
+	if !lock && !audit {
+		return
+	}
+	userID := utils.GetUserID(stmt.Context)
+	eachModel(stmt.ReflectValue, func(rv reflect.Value) {
+		if lock {
+			setIfZero(stmt, rv, "Version", int64(1))
+		}
+		if audit && userID != "" {
+			setIfZero(stmt, rv, "CreatedBy", userID)
+			setIfZero(stmt, rv, "UpdatedBy", userID)
+		}
+	})
+}
+
+func beforeUpdate(db *gorm.DB) {
+	stmt := db.Statement
+	if stmt.Schema == nil {
+		return
+	}
+	lock, audit := mixins(stmt.Schema)
+	if audit {
+		if userID := utils.GetUserID(stmt.Context); userID != "" {
+			setColumn(stmt, "UpdatedBy", userID)
+		}
+	}
+	if !lock || stmt.ReflectValue.Kind() != reflect.Struct {
+		return
+	}
+	field := stmt.Schema.LookUpField("Version")
+	v, zero := field.ValueOf(stmt.Context, stmt.ReflectValue)
+	version, _ := v.(int64)
+	if zero || version == 0 {
+		return
+	}
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: version},
+	}})
+	setColumn(stmt, "Version", version+1)
+	db.InstanceSet(lockVersionKey, version)
+}
+
+func afterUpdate(db *gorm.DB) {
+	version, ok := db.InstanceGet(lockVersionKey)
+	if !ok || db.Error != nil || db.DryRun || db.RowsAffected > 0 {
+		return
+	}
+	// Keep the caller's record at the version it was loaded with.
+	if rv := db.Statement.ReflectValue; rv.CanAddr() {
+		_ = db.Statement.Schema.LookUpField("Version").Set(db.Statement.Context, rv, version)
+	}
+	_ = db.AddError(code.NewErrorf(code.ErrConflict,
+		"%s was modified concurrently (version %d)", db.Statement.Table, version))
+}
+
+// mixins reports which mixins the model of s embeds.
+func mixins(s *schema.Schema) (lock, audit bool) {
+	ptr := reflect.New(s.ModelType).Interface()
+	_, lock = ptr.(optimisticLocker)
+	_, audit = ptr.(auditor)
+	return lock, audit
+}
+
+// setColumn sets an update column, also on the model when the update
+// values are a map, so the caller's record reflects the write.
+func setColumn(stmt *gorm.Statement, name string, value any) {
+	stmt.SetColumn(name, value, true)
+	if _, ok := stmt.Dest.(map[string]any); ok && stmt.ReflectValue.Kind() == reflect.Struct && stmt.ReflectValue.CanAddr() {
+		_ = stmt.Schema.LookUpField(name).Set(stmt.Context, stmt.ReflectValue, value)
+	}
+}
+
+func setIfZero(stmt *gorm.Statement, rv reflect.Value, name string, value any) {
+	field := stmt.Schema.LookUpField(name)
+	if _, zero := field.ValueOf(stmt.Context, rv); zero {
+		stmt.AddError(field.Set(stmt.Context, rv, value))
+	}
+}
+
+// eachModel calls fn for the struct or each element of the slice rv.
+func eachModel(rv reflect.Value, fn func(reflect.Value)) {
+	switch rv.Kind() {
+	case reflect.Struct:
+		if rv.CanAddr() {
+			fn(rv)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if elem := reflect.Indirect(rv.Index(i)); elem.Kind() == reflect.Struct && elem.CanAddr() {
+				fn(elem)
+			}
+		}
+	}
+}
@@ -0,0 +1,575 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/NSObjects/go-kit/cache"
+	kitlog "github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/cespare/xxhash/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/schema"
+)
+
+// Defaults of QueryCacheOptions.
+const (
+	DefaultQueryCachePrefix   = "querycache"
+	DefaultQueryCacheMaxRows  = 1000
+	DefaultQueryCacheMaxBytes = 1 << 20
+)
+
+// QueryCacheOptions configure a QueryCache. Zero values use the defaults.
+type QueryCacheOptions struct {
+	// Prefix of the cache keys; default DefaultQueryCachePrefix.
+	Prefix string
+	// MaxRows is the largest result cached, in rows; default
+	// DefaultQueryCacheMaxRows.
+	MaxRows int
+	// MaxBytes is the largest result cached, in encoded bytes; default
+	// DefaultQueryCacheMaxBytes.
+	MaxBytes int
+	// Name labels the observations of Observer; default "query".
+	Name string
+	// Observer receives a "get" hit or miss per hinted query and a "set"
+	// per cached result; metrics.CacheMetrics implements it.
+	Observer cache.Observer
+}
+
+// QueryCache is a GORM plugin answering the queries of contexts marked
+// with utils.WithQueryCache from a cache. Queries without the hint, in
+// transactions or bypassing caches (utils.WithCacheBypass) read the
+// database as usual.
+//
+// The key of a result is the SQL of the query, its bound variables and the
+// type it is scanned into, under a generation of the table of the query.
+// Creates, updates and deletes through GORM bump the generation of their
+// table, so a hinted query after a write never reads a result cached
+// before it. Raw statements and writes through other clients must call
+// Invalidate. Only the table of the query is tracked: a query joining
+// other tables may miss their writes for up to its ttl.
+//
+// Results are snapshotted field by field, so that fields hidden from JSON
+// and driver types of map results survive the cache.
+//
+//	qc := db.NewQueryCache(redisCache, db.QueryCacheOptions{Observer: cacheMetrics})
+//	err := gdb.Use(qc)
+//	err = gdb.WithContext(utils.WithQueryCache(ctx, 5*time.Second)).Find(&plans).Error
+type QueryCache struct {
+	cache cache.Cache
+	opts  QueryCacheOptions
+
+	hits, misses, sets, errs atomic.Uint64
+}
+
+// NewQueryCache creates a QueryCache storing results in c.
+func NewQueryCache(c cache.Cache, opts QueryCacheOptions) *QueryCache {
+	if opts.Prefix == "" {
+		opts.Prefix = DefaultQueryCachePrefix
+	}
+	if opts.MaxRows <= 0 {
+		opts.MaxRows = DefaultQueryCacheMaxRows
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultQueryCacheMaxBytes
+	}
+	if opts.Name == "" {
+		opts.Name = "query"
+	}
+	return &QueryCache{cache: c, opts: opts}
+}
+
+// Name implements gorm.Plugin.
+func (q *QueryCache) Name() string {
+	return "go-kit:query_cache"
+}
+
+// Initialize implements gorm.Plugin.
+func (q *QueryCache) Initialize(gdb *gorm.DB) error {
+	cb := gdb.Callback()
+	if err := cb.Query().Replace("gorm:query", q.query); err != nil {
+		return err
+	}
+	for _, p := range []struct {
+		name  string
+		after callbackRegistrar
+	}{
+		{"create", cb.Create().After("gorm:create")},
+		{"update", cb.Update().After("gorm:update")},
+		{"delete", cb.Delete().After("gorm:delete")},
+	} {
+		if err := p.after.Register("go-kit:query_cache_"+p.name, q.afterWrite); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats returns the hinted queries answered from the cache (Hits) or the
+// database (Misses), the results cached (Sets) and the cache failures
+// since creation.
+func (q *QueryCache) Stats() cache.Stats {
+	return cache.Stats{
+		Hits:   q.hits.Load(),
+		Misses: q.misses.Load(),
+		Sets:   q.sets.Load(),
+		Errors: q.errs.Load(),
+	}
+}
+
+// Invalidate drops the cached results of the queries of tables.
+func (q *QueryCache) Invalidate(ctx context.Context, tables ...string) error {
+	gen := time.Now().UnixNano()
+	for _, table := range tables {
+		if err := q.cache.Set(ctx, q.genKey(table), gen, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// query replaces gorm:query.
+func (q *QueryCache) query(db *gorm.DB) {
+	stmt := db.Statement
+	ttl := utils.QueryCacheTTL(stmt.Context)
+	if ttl <= 0 || db.Error != nil || db.DryRun || stmt.Table == "" || inTransaction(stmt) {
+		callbacks.Query(db)
+		return
+	}
+	callbacks.BuildQuerySQL(db)
+	if db.Error != nil {
+		return
+	}
+
+	ctx := stmt.Context
+	var gen int64
+	if err := q.cache.Get(ctx, q.genKey(stmt.Table), &gen); err != nil && !errors.Is(err, redis.Nil) {
+		q.fail(ctx, "Query cache generation read failed", err)
+		callbacks.Query(db)
+		return
+	}
+	key := q.resultKey(stmt, gen)
+
+	start := time.Now()
+	var data json.RawMessage
+	err := q.cache.Get(ctx, key, &data)
+	if err == nil {
+		var snap querySnapshot
+		if err = json.Unmarshal(data, &snap); err == nil {
+			err = snap.restore(db)
+		}
+		if err == nil {
+			q.hits.Add(1)
+			q.observe("get", "hit", start)
+			return
+		}
+	}
+	if err != nil && !errors.Is(err, redis.Nil) {
+		q.fail(ctx, "Query cache read failed", err)
+	}
+	q.misses.Add(1)
+	q.observe("get", "miss", start)
+
+	callbacks.Query(db)
+	if db.Error != nil || db.RowsAffected > int64(q.opts.MaxRows) {
+		return
+	}
+	q.store(db, key, ttl)
+}
+
+// store caches the result of db under key.
+func (q *QueryCache) store(db *gorm.DB, key string, ttl time.Duration) {
+	snap, ok := snapshotOf(db)
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(snap)
+	if err != nil || len(data) > q.opts.MaxBytes {
+		return
+	}
+	start := time.Now()
+	if err := q.cache.Set(db.Statement.Context, key, json.RawMessage(data), ttl); err != nil {
+		q.fail(db.Statement.Context, "Query cache write failed", err)
+		q.observe("set", "error", start)
+		return
+	}
+	q.sets.Add(1)
+	q.observe("set", "ok", start)
+}
+
+// afterWrite bumps the generation of the table of a successful write.
+func (q *QueryCache) afterWrite(db *gorm.DB) {
+	if db.Error != nil || db.DryRun || db.Statement.Table == "" {
+		return
+	}
+	ctx := utils.DetachContext(db.Statement.Context)
+	if err := q.Invalidate(ctx, db.Statement.Table); err != nil {
+		q.fail(ctx, "Query cache invalidation failed", err)
+	}
+}
+
+func (q *QueryCache) fail(ctx context.Context, msg string, err error) {
+	q.errs.Add(1)
+	slog.WarnContext(ctx, msg, slog.String("component", "database"), kitlog.Err(err))
+}
+
+func (q *QueryCache) observe(op, result string, start time.Time) {
+	if q.opts.Observer != nil {
+		q.opts.Observer.ObserveCache(q.opts.Name, op, result, time.Since(start))
+	}
+}
+
+func (q *QueryCache) genKey(table string) string {
+	return q.opts.Prefix + ":gen:" + table
+}
+
+// resultKey hashes the SQL, variables and destination type of stmt.
+func (q *QueryCache) resultKey(stmt *gorm.Statement, gen int64) string {
+	h := xxhash.New()
+	_, _ = h.WriteString(stmt.SQL.String())
+	for _, v := range stmt.Vars {
+		_, _ = fmt.Fprintf(h, "\x00%T:%v", v, v)
+	}
+	_, _ = fmt.Fprintf(h, "\x00%T", stmt.Dest)
+	return q.opts.Prefix + ":" + stmt.Table + ":" + strconv.FormatInt(gen, 36) + ":" + strconv.FormatUint(h.Sum64(), 16)
+}
+
+// inTransaction reports whether stmt runs in a transaction, whose reads
+// may see uncommitted writes.
+func inTransaction(stmt *gorm.Statement) bool {
+	_, ok := stmt.ConnPool.(gorm.TxCommitter)
+	return ok
+}
+
+// Kinds of querySnapshot.
+const (
+	snapStruct  = "struct"  // a model
+	snapStructs = "structs" // a slice of models
+	snapMap     = "map"     // map[string]any
+	snapMaps    = "maps"    // []map[string]any
+	snapValue   = "value"   // scalars and slices of them, as JSON
+)
+
+// querySnapshot is the cached form of a query result.
+type querySnapshot struct {
+	Kind string `json:"kind"`
+	// RowsAffected is the number of rows of the result.
+	RowsAffected int64 `json:"rows_affected"`
+	// Rows are the fields of models, by field name.
+	Rows []map[string]json.RawMessage `json:"rows,omitempty"`
+	// Maps are map rows, with the type of each value.
+	Maps []map[string]typedValue `json:"maps,omitempty"`
+	// Value is the destination of other results.
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// snapshotOf captures the result scanned into the destination of db. It
+// returns false for destinations it cannot restore.
+func snapshotOf(db *gorm.DB) (querySnapshot, bool) {
+	stmt := db.Statement
+	snap := querySnapshot{RowsAffected: db.RowsAffected}
+	switch dest := stmt.Dest.(type) {
+	case map[string]any:
+		snap.Kind = snapMap
+		return snap, snap.addMap(dest) == nil
+	case *map[string]any:
+		snap.Kind = snapMap
+		return snap, *dest == nil || snap.addMap(*dest) == nil
+	case *[]map[string]any:
+		snap.Kind = snapMaps
+		for _, m := range *dest {
+			if snap.addMap(m) != nil {
+				return snap, false
+			}
+		}
+		return snap, true
+	}
+
+	rv := reflect.Indirect(stmt.ReflectValue)
+	switch {
+	case rv.Kind() == reflect.Struct && isModel(rv.Type()):
+		sch, err := schemaOf(db, rv.Type())
+		if err != nil {
+			return snap, false
+		}
+		snap.Kind = snapStruct
+		if db.RowsAffected > 0 {
+			return snap, snap.addRow(stmt.Context, sch, rv) == nil
+		}
+		return snap, true
+	case rv.Kind() == reflect.Slice && isModel(derefType(rv.Type().Elem())):
+		sch, err := schemaOf(db, derefType(rv.Type().Elem()))
+		if err != nil {
+			return snap, false
+		}
+		snap.Kind = snapStructs
+		for i := 0; i < rv.Len(); i++ {
+			elem := reflect.Indirect(rv.Index(i))
+			if !elem.IsValid() || snap.addRow(stmt.Context, sch, elem) != nil {
+				return snap, false
+			}
+		}
+		return snap, true
+	case rv.Kind() == reflect.Array || rv.Kind() == reflect.Map || !rv.IsValid():
+		return snap, false
+	}
+	data, err := json.Marshal(rv.Interface())
+	if err != nil {
+		return snap, false
+	}
+	snap.Kind, snap.Value = snapValue, data
+	return snap, true
+}
+
+// restore scans the snapshot into the destination of db as the query
+// would have.
+func (s *querySnapshot) restore(db *gorm.DB) error {
+	stmt := db.Statement
+	db.RowsAffected = s.RowsAffected
+	switch s.Kind {
+	case snapMap:
+		m, ok := stmt.Dest.(map[string]any)
+		if p, isPtr := stmt.Dest.(*map[string]any); isPtr {
+			if *p == nil && len(s.Maps) > 0 {
+				*p = map[string]any{}
+			}
+			m, ok = *p, true
+		}
+		if !ok {
+			return errors.New("query cache: map result for a non-map destination")
+		}
+		if len(s.Maps) > 0 {
+			if err := restoreMap(m, s.Maps[0]); err != nil {
+				return err
+			}
+		}
+	case snapMaps:
+		dest, ok := stmt.Dest.(*[]map[string]any)
+		if !ok {
+			return errors.New("query cache: map rows for a non-map destination")
+		}
+		for _, row := range s.Maps {
+			m := map[string]any{}
+			if err := restoreMap(m, row); err != nil {
+				return err
+			}
+			*dest = append(*dest, m)
+		}
+	case snapStruct:
+		rv := reflect.Indirect(stmt.ReflectValue)
+		if rv.Kind() != reflect.Struct || !rv.CanAddr() {
+			return errors.New("query cache: model result for a non-model destination")
+		}
+		if len(s.Rows) > 0 {
+			sch, err := schemaOf(db, rv.Type())
+			if err != nil {
+				return err
+			}
+			if err := restoreRow(stmt.Context, sch, rv, s.Rows[0]); err != nil {
+				return err
+			}
+		}
+	case snapStructs:
+		rv := reflect.Indirect(stmt.ReflectValue)
+		if rv.Kind() != reflect.Slice || !rv.CanSet() {
+			return errors.New("query cache: model rows for a non-slice destination")
+		}
+		elemType := rv.Type().Elem()
+		sch, err := schemaOf(db, derefType(elemType))
+		if err != nil {
+			return err
+		}
+		out := reflect.MakeSlice(rv.Type(), 0, len(s.Rows))
+		for _, row := range s.Rows {
+			elem := reflect.New(sch.ModelType)
+			if err := restoreRow(stmt.Context, sch, elem.Elem(), row); err != nil {
+				return err
+			}
+			if elemType.Kind() == reflect.Pointer {
+				out = reflect.Append(out, elem)
+			} else {
+				out = reflect.Append(out, elem.Elem())
+			}
+		}
+		rv.Set(out)
+	case snapValue:
+		if err := json.Unmarshal(s.Value, stmt.Dest); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("query cache: unknown snapshot kind %q", s.Kind)
+	}
+	if s.RowsAffected == 0 && stmt.RaiseErrorOnNotFound {
+		_ = db.AddError(gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// addRow snapshots the fields of the model rv.
+func (s *querySnapshot) addRow(ctx context.Context, sch *schema.Schema, rv reflect.Value) error {
+	row := make(map[string]json.RawMessage, len(sch.Fields))
+	for _, f := range sch.Fields {
+		if f.DBName == "" || !f.Readable {
+			continue
+		}
+		v, _ := f.ValueOf(ctx, rv)
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		row[f.Name] = data
+	}
+	s.Rows = append(s.Rows, row)
+	return nil
+}
+
+// addMap snapshots a map row.
+func (s *querySnapshot) addMap(m map[string]any) error {
+	row := make(map[string]typedValue, len(m))
+	for k, v := range m {
+		tv, err := newTypedValue(v)
+		if err != nil {
+			return err
+		}
+		row[k] = tv
+	}
+	s.Maps = append(s.Maps, row)
+	return nil
+}
+
+// restoreRow sets the fields of the model rv from row.
+func restoreRow(ctx context.Context, sch *schema.Schema, rv reflect.Value, row map[string]json.RawMessage) error {
+	for name, data := range row {
+		f := sch.LookUpField(name)
+		if f == nil {
+			continue
+		}
+		v := reflect.New(f.FieldType)
+		if err := json.Unmarshal(data, v.Interface()); err != nil {
+			return fmt.Errorf("query cache: field %s: %w", name, err)
+		}
+		if err := f.Set(ctx, rv, v.Elem().Interface()); err != nil {
+			return fmt.Errorf("query cache: field %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func restoreMap(m map[string]any, row map[string]typedValue) error {
+	for k, tv := range row {
+		v, err := tv.value()
+		if err != nil {
+			return fmt.Errorf("query cache: column %s: %w", k, err)
+		}
+		m[k] = v
+	}
+	return nil
+}
+
+// typedValue is a value of a map row with its driver type, which JSON
+// alone would lose (int64, []byte, *time.Time...). Type is empty for nil
+// and "json" for types decoded as plain JSON.
+type typedValue struct {
+	Type  string          `json:"t,omitempty"`
+	Value json.RawMessage `json:"v,omitempty"`
+}
+
+// typedValueTypes are the types of map values kept across the cache, by
+// name; a pointer to one is named with a * prefix.
+var typedValueTypes = map[string]reflect.Type{
+	"int":     reflect.TypeFor[int](),
+	"int8":    reflect.TypeFor[int8](),
+	"int16":   reflect.TypeFor[int16](),
+	"int32":   reflect.TypeFor[int32](),
+	"int64":   reflect.TypeFor[int64](),
+	"uint":    reflect.TypeFor[uint](),
+	"uint8":   reflect.TypeFor[uint8](),
+	"uint16":  reflect.TypeFor[uint16](),
+	"uint32":  reflect.TypeFor[uint32](),
+	"uint64":  reflect.TypeFor[uint64](),
+	"float64": reflect.TypeFor[float64](),
+	"float32": reflect.TypeFor[float32](),
+	"bool":    reflect.TypeFor[bool](),
+	"string":  reflect.TypeFor[string](),
+	"bytes":   reflect.TypeFor[[]byte](),
+	"time":    timeType,
+}
+
+func newTypedValue(v any) (typedValue, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || (rv.Kind() == reflect.Pointer && rv.IsNil()) {
+		return typedValue{}, nil
+	}
+	prefix := ""
+	if rv.Kind() == reflect.Pointer {
+		prefix, rv = "*", rv.Elem()
+	}
+	name := "json"
+	for n, t := range typedValueTypes {
+		if rv.Type() == t {
+			name = prefix + n
+			break
+		}
+	}
+	data, err := json.Marshal(rv.Interface())
+	return typedValue{Type: name, Value: data}, err
+}
+
+func (tv typedValue) value() (any, error) {
+	if tv.Type == "" {
+		return nil, nil
+	}
+	name, ptr := strings.CutPrefix(tv.Type, "*")
+	t, ok := typedValueTypes[name]
+	if !ok {
+		var v any
+		err := json.Unmarshal(tv.Value, &v)
+		return v, err
+	}
+	dest := reflect.New(t)
+	if err := json.Unmarshal(tv.Value, dest.Interface()); err != nil {
+		return nil, err
+	}
+	if ptr {
+		return dest.Interface(), nil
+	}
+	return dest.Elem().Interface(), nil
+}
+
+// schemaOf parses the model type t with the schema cache of db.
+func schemaOf(db *gorm.DB, t reflect.Type) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(reflect.New(t).Interface()); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// isModel reports whether t is scanned field by field: a struct other
+// than time.Time and sql.Scanner types.
+func isModel(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+	_, scanner := reflect.New(t).Interface().(interface{ Scan(any) error })
+	return !scanner
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"unicode"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/utils"
+	"gorm.io/gorm"
+	gormutils "gorm.io/gorm/utils"
+)
+
+// EnableReadOnlyGuard registers callbacks failing writes with
+// code.ErrServiceUnavailable, before anything is sent to the database,
+// while isReadOnly returns true. It complements a maintenance switch at
+// the HTTP layer for background jobs and routes it misses:
+//
+//	var maintenance atomic.Bool // flipped from the config store
+//	err := db.EnableReadOnlyGuard(gdb, maintenance.Load)
+//
+// Create, Update and Delete are writes. Raw statements (Exec, and Raw
+// with Scan, Row or Find) are classified by their SQL: only statements
+// made of SELECT, SHOW, DESCRIBE, EXPLAIN (without ANALYZE), VALUES or
+// transaction control, and naming no writing keyword outside literals and
+// comments, are reads. A SELECT ... FOR UPDATE is therefore a write.
+//
+// Statements whose context carries utils.WithWriteOverride pass, for the
+// migration job itself. Inside a transaction the blocked statement returns
+// its error, so returning it from the transaction function rolls back.
+func EnableReadOnlyGuard(gdb *gorm.DB, isReadOnly func() bool) error {
+	guard := func(always bool) func(*gorm.DB) {
+		return func(db *gorm.DB) {
+			if db.Error != nil || !isReadOnly() {
+				return
+			}
+			ctx := db.Statement.Context
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			if utils.WriteOverride(ctx) {
+				return
+			}
+			sql := db.Statement.SQL.String()
+			if !always && (sql == "" || IsReadOnlySQL(sql)) {
+				return
+			}
+			slog.WarnContext(ctx, "Write blocked in read-only mode",
+				slog.String("component", "database"),
+				slog.String("table", db.Statement.Table),
+				slog.String("caller", gormutils.FileWithLineNum()),
+			)
+			_ = db.AddError(code.NewError(code.ErrServiceUnavailable, "database is read-only"))
+		}
+	}
+
+	cb := gdb.Callback()
+	for _, p := range []struct {
+		name   string
+		before callbackRegistrar
+		always bool
+	}{
+		{"create", cb.Create().Before("*"), true},
+		{"update", cb.Update().Before("*"), true},
+		{"delete", cb.Delete().Before("*"), true},
+		// Raw statements and queries with prepared SQL are classified.
+		{"raw", cb.Raw().Before("*"), false},
+		{"row", cb.Row().Before("*"), false},
+		{"query", cb.Query().Before("*"), false},
+	} {
+		if err := p.before.Register("go-kit:read_only_"+p.name, guard(p.always)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readVerbs are the leading keywords of statements that may be reads.
+var readVerbs = map[string]bool{
+	"SELECT": true, "SHOW": true, "DESCRIBE": true, "DESC": true, "EXPLAIN": true,
+	"VALUES": true, "WITH": true, "TABLE": true,
+	"BEGIN": true, "START": true, "COMMIT": true, "ROLLBACK": true, "SAVEPOINT": true, "RELEASE": true,
+}
+
+// writeKeywords are keywords making a statement a write wherever they
+// appear.
+var writeKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "UPSERT": true,
+	"INTO": true, "CREATE": true, "ALTER": true, "DROP": true, "TRUNCATE": true, "RENAME": true,
+	"GRANT": true, "REVOKE": true, "LOCK": true, "CALL": true, "DO": true, "COPY": true,
+	"LOAD": true, "ANALYZE": true, "VACUUM": true, "REINDEX": true, "SET": true,
+}
+
+// IsReadOnlySQL reports whether sql only reads, as EnableReadOnlyGuard
+// classifies raw statements. It errs on the side of writes: statements it
+// does not recognize are writes.
+func IsReadOnlySQL(sql string) bool {
+	words, ok := sqlKeywords(sql)
+	if !ok || len(words) == 0 {
+		return false
+	}
+	start := true
+	for _, w := range words {
+		if w == ";" {
+			start = true
+			continue
+		}
+		if start && !readVerbs[w] {
+			return false
+		}
+		start = false
+		if writeKeywords[w] {
+			return false
+		}
+	}
+	return true
+}
+
+// sqlKeywords returns the upper-cased words of sql, and ";" between
+// statements, skipping string literals, quoted identifiers and comments.
+// It reports false when a literal or comment is not terminated, which
+// happens when a dialect escapes quotes with backslashes.
+func sqlKeywords(sql string) ([]string, bool) {
+	var words []string
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				return words, true
+			}
+			i += end + 1
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return nil, false
+			}
+			i += end + 4
+		case c == '\'' || c == '"' || c == '`':
+			// Doubled quotes escape a quote; skipping both halves as two
+			// literals gives the same result.
+			end := strings.IndexByte(sql[i+1:], c)
+			if end < 0 {
+				return nil, false
+			}
+			i += end + 2
+		case c == ';':
+			words = append(words, ";")
+			i++
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(sql) && (sql[j] == '_' || sql[j] == '$' || unicode.IsLetter(rune(sql[j])) || unicode.IsDigit(rune(sql[j]))) {
+				j++
+			}
+			words = append(words, strings.ToUpper(sql[i:j]))
+			i = j
+		default:
+			i++
+		}
+	}
+	return words, true
+}
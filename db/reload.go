@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/config"
+	kitlog "github.com/NSObjects/go-kit/log"
+)
+
+// ErrRestartRequired is returned (wrapped) by ApplyConfig when changed
+// fields cannot be applied to a live connection pool.
+var ErrRestartRequired = errors.New("restart required")
+
+// ApplyConfig applies the pool settings of cfg (max_open_conns,
+// max_idle_conns, max_lifetime, conn_max_idle_time) to the live database
+// without reconnecting. Changes to any other field are not applied and
+// are reported in an error wrapping ErrRestartRequired; pool changes are
+// applied regardless.
+func (m *Manager) ApplyConfig(cfg config.DatabaseConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	sqlDB, err := m.DB.DB()
+	if err != nil {
+		return err
+	}
+
+	cur := m.Config.Database
+	var changes []slog.Attr
+	if cfg.MaxOpenConns != cur.MaxOpenConns {
+		sqlDB.SetMaxOpenConns(maxOpenConns(cfg)) // 0 is unlimited, as at startup
+		changes = append(changes, poolChange("max_open_conns", cur.MaxOpenConns, cfg.MaxOpenConns))
+	}
+	if cfg.MaxIdleConns != cur.MaxIdleConns {
+		sqlDB.SetMaxIdleConns(maxIdleConns(cfg.MaxIdleConns))
+		changes = append(changes, poolChange("max_idle_conns", cur.MaxIdleConns, cfg.MaxIdleConns))
+	}
+	if cfg.MaxLifetime != cur.MaxLifetime {
+		sqlDB.SetConnMaxLifetime(connMaxLifetime(cfg.MaxLifetime))
+		changes = append(changes, poolChange("max_lifetime", cur.MaxLifetime, cfg.MaxLifetime))
+	}
+	if cfg.ConnMaxIdleTime != cur.ConnMaxIdleTime {
+		sqlDB.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime) * time.Second)
+		changes = append(changes, poolChange("conn_max_idle_time", cur.ConnMaxIdleTime, cfg.ConnMaxIdleTime))
+	}
+
+	immutable := immutableDatabaseChanges(cur, cfg)
+
+	// Record only what was applied so later diffs stay accurate.
+	cur.MaxOpenConns = cfg.MaxOpenConns
+	cur.MaxIdleConns = cfg.MaxIdleConns
+	cur.MaxLifetime = cfg.MaxLifetime
+	cur.ConnMaxIdleTime = cfg.ConnMaxIdleTime
+	m.Config.Database = cur
+
+	if len(changes) > 0 {
+		args := make([]any, len(changes))
+		for i, c := range changes {
+			args[i] = c
+		}
+		slog.Info("Database pool config applied", args...)
+	}
+	if len(immutable) > 0 {
+		return fmt.Errorf("database config fields %s changed: %w", strings.Join(immutable, ", "), ErrRestartRequired)
+	}
+	return nil
+}
+
+// WatchDatabaseConfig applies database config changes from store to m until
+// ctx is done. Changes that need a restart are logged.
+//
+//	go db.WatchDatabaseConfig(ctx, manager, store, func(c config.Config) config.DatabaseConfig {
+//	    return c.Database
+//	})
+func WatchDatabaseConfig[T any](ctx context.Context, m *Manager, store *config.Store[T], get func(T) config.DatabaseConfig) {
+	events := store.SubscribeEvents()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			if err := m.ApplyConfig(get(ev.New)); err != nil {
+				slog.Warn("Database config reload incomplete", kitlog.Err(err))
+			}
+		}
+	}
+}
+
+// immutableDatabaseChanges lists the mapstructure names of changed fields
+// that require reconnecting.
+func immutableDatabaseChanges(old, cfg config.DatabaseConfig) []string {
+	var fields []string
+	check := func(name string, changed bool) {
+		if changed {
+			fields = append(fields, name)
+		}
+	}
+	check("driver", old.Driver != cfg.Driver)
+	check("host", old.Host != cfg.Host)
+	check("port", old.Port != cfg.Port)
+	check("user", old.User != cfg.User)
+	check("password", old.Password != cfg.Password)
+	check("database", old.Database != cfg.Database)
+	check("charset", old.Charset != cfg.Charset)
+	check("ssl_mode", old.SSLMode != cfg.SSLMode)
+	check("schema", old.Schema != cfg.Schema)
+	check("timezone", old.TimeZone != cfg.TimeZone)
+	check("default_query_timeout", old.DefaultQueryTimeout != cfg.DefaultQueryTimeout)
+	check("slow_query", old.SlowQuery != cfg.SlowQuery)
+	check("sqlite", resolveSqlite(old.Sqlite) != resolveSqlite(cfg.Sqlite))
+	check("translate_errors", old.TranslateErrors != cfg.TranslateErrors)
+	return fields
+}
+
+func poolChange(name string, from, to int) slog.Attr {
+	return slog.String(name, fmt.Sprintf("%d -> %d", from, to))
+}
+
+// maxOpenConns limits sqlite databases outside WAL mode to one connection
+// (see config.SqliteConfig).
+func maxOpenConns(cfg config.DatabaseConfig) int {
+	if sqliteSingleConnection(cfg) {
+		return 1
+	}
+	return cfg.MaxOpenConns
+}
+
+// maxIdleConns maps an unset value to the database/sql default.
+func maxIdleConns(n int) int {
+	if n <= 0 {
+		return 2
+	}
+	return n
+}
+
+// connMaxLifetime maps an unset value to the startup default.
+func connMaxLifetime(seconds int) time.Duration {
+	if seconds <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(seconds) * time.Second
+}
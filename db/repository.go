@@ -0,0 +1,197 @@
+package db
+
+import (
+	"context"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Scope is a reusable query condition, composable via gorm's Scopes.
+type Scope = func(*gorm.DB) *gorm.DB
+
+// Pagination selects a page. Page is 1-based; Size defaults to 20 and is
+// capped at MaxPageSize.
+type Pagination struct {
+	Page int `json:"page" query:"page"`
+	Size int `json:"size" query:"size"`
+}
+
+// MaxPageSize caps Pagination.Size.
+const MaxPageSize = 1000
+
+// Normalize returns p with defaults and bounds applied.
+func (p Pagination) Normalize() Pagination {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.Size < 1 {
+		p.Size = 20
+	}
+	if p.Size > MaxPageSize {
+		p.Size = MaxPageSize
+	}
+	return p
+}
+
+// Offset returns a scope applying offset/limit for p.
+func (p Pagination) Offset() Scope {
+	p = p.Normalize()
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Offset((p.Page - 1) * p.Size).Limit(p.Size)
+	}
+}
+
+// TenantScope restricts queries to the tenant in ctx (column tenant_id).
+// Without a tenant in ctx it matches nothing, so a missing tenant can never
+// leak other tenants' rows.
+func TenantScope(ctx context.Context) Scope {
+	tenantID := utils.GetTenantID(ctx)
+	return func(db *gorm.DB) *gorm.DB {
+		if tenantID == "" {
+			return db.Where("1 = 0")
+		}
+		return db.Where("tenant_id = ?", tenantID)
+	}
+}
+
+// CursorAfter returns a keyset pagination scope: rows whose column is
+// greater than cursor (all rows when cursor is nil), ordered by column,
+// limited to limit rows.
+func CursorAfter(column string, cursor any, limit int) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		if cursor != nil {
+			db = db.Where(clause.Gt{Column: clause.Column{Name: column}, Value: cursor})
+		}
+		return db.Order(clause.OrderByColumn{Column: clause.Column{Name: column}}).Limit(limit)
+	}
+}
+
+// Repository provides generic CRUD for model T. All methods are
+// context-first and return coded errors (see TranslateError).
+type Repository[T any] struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a Repository using the Manager's database.
+func NewRepository[T any](m *Manager) *Repository[T] {
+	return NewRepositoryWithDB[T](m.DB)
+}
+
+// NewRepositoryWithDB creates a Repository using db, e.g. a transaction.
+func NewRepositoryWithDB[T any](db *gorm.DB) *Repository[T] {
+	return &Repository[T]{db: db}
+}
+
+// DB returns the underlying connection bound to ctx for custom queries.
+func (r *Repository[T]) DB(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx)
+}
+
+// Create inserts entity.
+func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
+	return TranslateError(r.db.WithContext(ctx).Create(entity).Error, "create")
+}
+
+// BatchCreate inserts entities in batches of batchSize (default 100).
+func (r *Repository[T]) BatchCreate(ctx context.Context, entities []T, batchSize int) error {
+	if len(entities) == 0 {
+		return nil
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	return TranslateError(r.db.WithContext(ctx).CreateInBatches(entities, batchSize).Error, "batch create")
+}
+
+// GetByID returns the entity with primary key id.
+func (r *Repository[T]) GetByID(ctx context.Context, id any, scopes ...Scope) (*T, error) {
+	var entity T
+	err := r.db.WithContext(ctx).Scopes(scopes...).
+		Where(clause.Eq{Column: clause.PrimaryColumn, Value: id}).
+		First(&entity).Error
+	if err != nil {
+		return nil, TranslateError(err, "get")
+	}
+	return &entity, nil
+}
+
+// Update writes all fields of entity to the row with its primary key.
+// Returns code.ErrNotFound when no row matched.
+func (r *Repository[T]) Update(ctx context.Context, entity *T) error {
+	res := r.db.WithContext(ctx).Model(entity).Select("*").Omit(clause.Associations).Updates(entity)
+	if res.Error != nil {
+		return TranslateError(res.Error, "update")
+	}
+	if res.RowsAffected == 0 {
+		return code.NewNotFoundError("record")
+	}
+	return nil
+}
+
+// UpdateFields updates the given columns of the entity with primary key id.
+// Returns code.ErrNotFound when no row matched.
+func (r *Repository[T]) UpdateFields(ctx context.Context, id any, fields map[string]any, scopes ...Scope) error {
+	res := r.db.WithContext(ctx).Model(new(T)).Scopes(scopes...).
+		Where(clause.Eq{Column: clause.PrimaryColumn, Value: id}).
+		Updates(fields)
+	if res.Error != nil {
+		return TranslateError(res.Error, "update")
+	}
+	if res.RowsAffected == 0 {
+		return code.NewNotFoundError("record")
+	}
+	return nil
+}
+
+// Delete deletes the entity with primary key id. Models with a
+// gorm.DeletedAt field are soft-deleted; others are removed.
+// Returns code.ErrNotFound when no row matched.
+func (r *Repository[T]) Delete(ctx context.Context, id any, scopes ...Scope) error {
+	res := r.db.WithContext(ctx).Scopes(scopes...).
+		Where(clause.Eq{Column: clause.PrimaryColumn, Value: id}).
+		Delete(new(T))
+	if res.Error != nil {
+		return TranslateError(res.Error, "delete")
+	}
+	if res.RowsAffected == 0 {
+		return code.NewNotFoundError("record")
+	}
+	return nil
+}
+
+// List returns one page of entities matching scopes and the total count.
+// Soft-deleted rows are excluded.
+func (r *Repository[T]) List(ctx context.Context, page Pagination, scopes ...Scope) ([]T, int64, error) {
+	query := func() *gorm.DB {
+		return r.db.WithContext(ctx).Model(new(T)).Scopes(scopes...)
+	}
+
+	var total int64
+	if err := query().Count(&total).Error; err != nil {
+		return nil, 0, TranslateError(err, "count")
+	}
+
+	items := []T{}
+	if total == 0 {
+		return items, 0, nil
+	}
+	if err := query().Scopes(page.Offset()).Find(&items).Error; err != nil {
+		return nil, 0, TranslateError(err, "list")
+	}
+	return items, total, nil
+}
+
+// Exists reports whether any entity matches scopes. Soft-deleted rows are
+// excluded.
+func (r *Repository[T]) Exists(ctx context.Context, scopes ...Scope) (bool, error) {
+	var found []int
+	err := r.db.WithContext(ctx).Model(new(T)).Scopes(scopes...).
+		Select("1").Limit(1).Find(&found).Error
+	if err != nil {
+		return false, TranslateError(err, "exists")
+	}
+	return len(found) > 0, nil
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"time"
+
+	"github.com/NSObjects/go-kit/utils"
+	"gorm.io/gorm"
+)
+
+// requestStatsStartKey stores the start time of a recorded statement.
+const requestStatsStartKey = "go-kit:request_stats_start"
+
+// RequestStatsPlugin is a GORM plugin recording each statement into the
+// utils.DBStats of its context (see middleware.DBStats), by fingerprint
+// (see Fingerprint), for per-request query counts and the N+1 detector.
+// Statements whose context carries no DBStats cost a context lookup.
+// NewDatabase registers it.
+//
+//	err := gdb.Use(db.RequestStatsPlugin{})
+type RequestStatsPlugin struct{}
+
+// Name implements gorm.Plugin.
+func (RequestStatsPlugin) Name() string {
+	return "go-kit:request_stats"
+}
+
+// Initialize implements gorm.Plugin.
+func (RequestStatsPlugin) Initialize(gdb *gorm.DB) error {
+	before := func(db *gorm.DB) {
+		if utils.DBStatsFromContext(db.Statement.Context) != nil {
+			db.InstanceSet(requestStatsStartKey, time.Now())
+		}
+	}
+	after := func(db *gorm.DB) {
+		v, ok := db.InstanceGet(requestStatsStartKey)
+		if !ok {
+			return
+		}
+		elapsed := time.Since(v.(time.Time))
+		sql := db.Statement.SQL.String()
+		if sql == "" {
+			return // dry run or failed before building the statement
+		}
+		utils.DBStatsFromContext(db.Statement.Context).Record(Fingerprint(sql), elapsed, db.RowsAffected)
+	}
+
+	cb := gdb.Callback()
+	for _, p := range []struct {
+		name          string
+		before, after callbackRegistrar
+	}{
+		{"create", cb.Create().Before("*"), cb.Create().After("*")},
+		{"query", cb.Query().Before("*"), cb.Query().After("*")},
+		{"update", cb.Update().Before("*"), cb.Update().After("*")},
+		{"delete", cb.Delete().Before("*"), cb.Delete().After("*")},
+		{"raw", cb.Raw().Before("*"), cb.Raw().After("*")},
+		{"row", cb.Row().Before("*"), cb.Row().After("*")},
+	} {
+		name := "go-kit:request_stats_" + p.name
+		if err := p.before.Register(name+":before", before); err != nil {
+			return err
+		}
+		if err := p.after.Register(name+":after", after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"log/slog"
+	"time"
+
+	"github.com/NSObjects/go-kit/config"
+	kitlog "github.com/NSObjects/go-kit/log"
+)
+
+// redisCredentialsTimeout bounds the resolution of a Redis password, which
+// the client asks for without a context.
+const redisCredentialsTimeout = 10 * time.Second
+
+// secretConnector opens connections with the DSN of cfg and the password
+// its reference resolves to at that time.
+type secretConnector struct {
+	cfg     config.DatabaseConfig
+	secrets *config.SecretResolver
+	driver  connectorDriver
+	dsn     func(config.DatabaseConfig) string
+}
+
+// connectorDriver is a driver opening connectors from a DSN.
+type connectorDriver interface {
+	driver.Driver
+	driver.DriverContext
+}
+
+// Connect implements driver.Connector. When the connection fails with a
+// cached password, the password is resolved again and, if it was rotated,
+// the connection retried with the new one.
+func (c *secretConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	password, err := c.secrets.Resolve(ctx, c.cfg.Password)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := c.connect(ctx, password)
+	if err == nil {
+		return conn, nil
+	}
+	c.secrets.Invalidate(c.cfg.Password)
+	rotated, rerr := c.secrets.Resolve(ctx, c.cfg.Password)
+	if rerr != nil || rotated == password {
+		return nil, err
+	}
+	return c.connect(ctx, rotated)
+}
+
+func (c *secretConnector) connect(ctx context.Context, password string) (driver.Conn, error) {
+	cfg := c.cfg
+	cfg.Password = password
+	connector, err := c.driver.OpenConnector(c.dsn(cfg))
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+// Driver implements driver.Connector.
+func (c *secretConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// redisCredentials returns a credentials provider resolving ref with
+// secrets. Failures are logged, with the reference only, and leave the
+// connection unauthenticated, so that its commands fail.
+func redisCredentials(ref string, secrets *config.SecretResolver) func() (string, string) {
+	return func() (string, string) {
+		ctx, cancel := context.WithTimeout(context.Background(), redisCredentialsTimeout)
+		defer cancel()
+		password, err := secrets.Resolve(ctx, ref)
+		if err != nil {
+			slog.Error("Redis password not resolved", slog.String("component", "redis"), slog.String("ref", ref), kitlog.Err(err))
+			return "", ""
+		}
+		return "", password
+	}
+}
@@ -0,0 +1,292 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/NSObjects/go-kit/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.yaml.in/yaml/v3"
+	"gorm.io/gorm"
+)
+
+// SeederOptions configures a Seeder.
+type SeederOptions struct {
+	// FS holds the fixture files (e.g. an embed.FS or os.DirFS).
+	FS fs.FS
+	// Dir is the directory within FS; default ".".
+	Dir string
+	// DB receives table fixtures.
+	DB *gorm.DB
+	// Mongo receives collection fixtures (files with "mongo: true").
+	Mongo *mongo.Database
+	// Hasher backs the {{bcrypt "..."}} template function; default
+	// NewPasswordHasher(DefaultEncryptConfig()).
+	Hasher *utils.PasswordHasher
+	// SkipExisting skips rows whose primary key already exists, making
+	// repeated runs idempotent. Rows keyed by {{ulid}} get a new key on
+	// every run and are therefore always inserted.
+	SkipExisting bool
+}
+
+// Seeder loads fixtures into the database.
+//
+// Each YAML or JSON file seeds one table (or Mongo collection) named after
+// the file without its numeric prefix and extension; files run in order of
+// that prefix ("01_users.yaml" before "02_orders.yaml"). A file is either a
+// list of rows or a document with options:
+//
+//	truncate: true      # delete existing rows first
+//	primary_key: id     # key used by SkipExisting (default "id", "_id" for Mongo)
+//	mongo: false        # seed the Mongo collection instead of the SQL table
+//	rows:
+//	  - id: "{{ulid}}"
+//	    email: admin@example.com
+//	    password: '{{bcrypt "secret"}}'
+//	    created_at: "{{now}}"
+type Seeder struct {
+	opts SeederOptions
+}
+
+// fixtureFile is the document form of a fixture file.
+type fixtureFile struct {
+	Truncate   bool             `yaml:"truncate"`
+	PrimaryKey string           `yaml:"primary_key"`
+	Mongo      bool             `yaml:"mongo"`
+	Rows       []map[string]any `yaml:"rows"`
+}
+
+// NewSeeder creates a Seeder.
+func NewSeeder(opts SeederOptions) *Seeder {
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	if opts.Hasher == nil {
+		opts.Hasher = utils.NewPasswordHasher(utils.DefaultEncryptConfig())
+	}
+	return &Seeder{opts: opts}
+}
+
+// Run loads all fixture files in order.
+func (s *Seeder) Run(ctx context.Context) error {
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		if err := s.load(ctx, name); err != nil {
+			return fmt.Errorf("seed %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// files lists fixture files ordered by numeric prefix, then name.
+func (s *Seeder) files() ([]string, error) {
+	entries, err := fs.ReadDir(s.opts.FS, s.opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("read fixtures dir: %w", err)
+	}
+
+	var files []string
+	for _, e := range entries {
+		switch path.Ext(e.Name()) {
+		case ".yaml", ".yml", ".json":
+			if !e.IsDir() {
+				files = append(files, e.Name())
+			}
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		pi, _ := splitFixtureName(files[i])
+		pj, _ := splitFixtureName(files[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return files[i] < files[j]
+	})
+	return files, nil
+}
+
+// splitFixtureName splits "02_orders.yaml" into (2, "orders"). Files without
+// a numeric prefix sort last.
+func splitFixtureName(name string) (int, string) {
+	base := strings.TrimSuffix(name, path.Ext(name))
+	digits := 0
+	for digits < len(base) && base[digits] >= '0' && base[digits] <= '9' {
+		digits++
+	}
+	if digits == 0 {
+		return int(^uint(0) >> 1), base
+	}
+	n, _ := strconv.Atoi(base[:digits])
+	return n, strings.TrimLeft(base[digits:], "_-.")
+}
+
+func (s *Seeder) load(ctx context.Context, name string) error {
+	raw, err := fs.ReadFile(s.opts.FS, path.Join(s.opts.Dir, name))
+	if err != nil {
+		return err
+	}
+	data, err := s.render(name, raw)
+	if err != nil {
+		return err
+	}
+
+	// JSON is valid YAML, so one decoder handles both formats.
+	var f fixtureFile
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return fmt.Errorf("parse: %w", err)
+	}
+	if len(node.Content) > 0 && node.Content[0].Kind == yaml.SequenceNode {
+		err = node.Decode(&f.Rows)
+	} else {
+		err = node.Decode(&f)
+	}
+	if err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	_, table := splitFixtureName(name)
+	if f.Mongo {
+		return s.loadMongo(ctx, table, f)
+	}
+	return s.loadTable(ctx, table, f)
+}
+
+// render executes the file as a template with the seed functions.
+func (s *Seeder) render(name string, raw []byte) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(template.FuncMap{
+		"ulid": utils.NewULID,
+		"now": func() string {
+			return time.Now().UTC().Format(time.RFC3339)
+		},
+		"bcrypt": s.opts.Hasher.Hash,
+	}).Parse(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return nil, fmt.Errorf("execute template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Seeder) loadTable(ctx context.Context, table string, f fixtureFile) error {
+	if s.opts.DB == nil {
+		return fmt.Errorf("no database configured for table %s", table)
+	}
+	pk := f.PrimaryKey
+	if pk == "" {
+		pk = "id"
+	}
+
+	inserted, skipped := 0, 0
+	err := WithTransaction(ctx, s.opts.DB, func(tx *gorm.DB) error {
+		if f.Truncate {
+			if err := tx.Exec("DELETE FROM " + tx.Statement.Quote(table)).Error; err != nil {
+				return TranslateError(err, "truncate")
+			}
+		}
+		for _, row := range f.Rows {
+			if s.opts.SkipExisting && row[pk] != nil {
+				var n int64
+				if err := tx.Table(table).Where(tx.Statement.Quote(pk)+" = ?", row[pk]).Count(&n).Error; err != nil {
+					return TranslateError(err, "check existing")
+				}
+				if n > 0 {
+					skipped++
+					continue
+				}
+			}
+			if err := tx.Table(table).Create(row).Error; err != nil {
+				return TranslateError(err, "insert")
+			}
+			inserted++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Fixtures loaded", slog.String("table", table), slog.Int("inserted", inserted), slog.Int("skipped", skipped))
+	return nil
+}
+
+func (s *Seeder) loadMongo(ctx context.Context, collection string, f fixtureFile) error {
+	if s.opts.Mongo == nil {
+		return fmt.Errorf("no mongodb configured for collection %s", collection)
+	}
+	coll := s.opts.Mongo.Collection(collection)
+	pk := f.PrimaryKey
+	if pk == "" {
+		pk = "_id"
+	}
+
+	if f.Truncate {
+		if _, err := coll.DeleteMany(ctx, bson.D{}); err != nil {
+			return fmt.Errorf("truncate: %w", err)
+		}
+	}
+
+	inserted, skipped := 0, 0
+	for _, row := range f.Rows {
+		if s.opts.SkipExisting && row[pk] != nil {
+			n, err := coll.CountDocuments(ctx, bson.M{pk: row[pk]})
+			if err != nil {
+				return fmt.Errorf("check existing: %w", err)
+			}
+			if n > 0 {
+				skipped++
+				continue
+			}
+		}
+		if _, err := coll.InsertOne(ctx, row); err != nil {
+			return fmt.Errorf("insert: %w", err)
+		}
+		inserted++
+	}
+
+	slog.Info("Fixtures loaded", slog.String("collection", collection), slog.Int("inserted", inserted), slog.Int("skipped", skipped))
+	return nil
+}
+
+// RegisterSeedFlag registers the -seed flag on fs (flag.CommandLine if nil).
+func RegisterSeedFlag(fs *flag.FlagSet) *bool {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	return fs.Bool("seed", false, "load database fixtures and continue (not allowed in prod)")
+}
+
+// SeedIfRequested runs s when requested is true. It refuses to seed when env
+// is "prod".
+//
+//	seed := db.RegisterSeedFlag(nil)
+//	flag.Parse()
+//	if err := db.SeedIfRequested(ctx, *seed, cfg.System.Env, seeder); err != nil {
+//	    log.Fatal(err)
+//	}
+func SeedIfRequested(ctx context.Context, requested bool, env string, s *Seeder) error {
+	if !requested {
+		return nil
+	}
+	if env == "prod" {
+		return fmt.Errorf("seeding is disabled in prod environment")
+	}
+	return s.Run(ctx)
+}
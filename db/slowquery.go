@@ -0,0 +1,339 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NSObjects/go-kit/config"
+	kitlog "github.com/NSObjects/go-kit/log"
+	"gorm.io/gorm"
+	gormutils "gorm.io/gorm/utils"
+)
+
+// Defaults of config.SlowQueryConfig.
+const (
+	defaultExplainTimeout  = 2 * time.Second
+	defaultExplainInterval = 10 * time.Minute
+)
+
+// slowStartKey stores the start time of a statement.
+const slowStartKey = "go-kit:slow_query_start"
+
+// explainPrefixes are the EXPLAIN forms by dialector name. None of them
+// executes the statement.
+var explainPrefixes = map[string]string{
+	"mysql":    "EXPLAIN FORMAT=JSON ",
+	"postgres": "EXPLAIN (ANALYZE false) ",
+	"sqlite":   "EXPLAIN QUERY PLAN ",
+}
+
+// EnableSlowQueryLog registers callbacks that log statements slower than
+// cfg.Threshold with their fingerprint (see Fingerprint). With cfg.Explain
+// and env "dev", the plan of a slow statement is also logged: it is
+// explained on a separate connection, bounded by cfg.ExplainTimeout, at
+// most once per fingerprint per cfg.ExplainInterval. EXPLAIN never runs in
+// other environments, whatever cfg says. A zero threshold registers
+// nothing.
+func EnableSlowQueryLog(gdb *gorm.DB, cfg config.SlowQueryConfig, env string) error {
+	if cfg.Threshold <= 0 {
+		return nil
+	}
+	var ex *explainer
+	if prefix, ok := explainPrefixes[gdb.Dialector.Name()]; ok && cfg.Explain && env == "dev" {
+		ex = &explainer{
+			db:      gdb,
+			prefix:  prefix,
+			timeout: cfg.ExplainTimeout,
+			limiter: newFingerprintLimiter(cfg.ExplainInterval),
+		}
+		if ex.timeout <= 0 {
+			ex.timeout = defaultExplainTimeout
+		}
+	}
+
+	before := func(db *gorm.DB) {
+		db.InstanceSet(slowStartKey, time.Now())
+	}
+	after := func(db *gorm.DB) {
+		v, ok := db.InstanceGet(slowStartKey)
+		if !ok {
+			return
+		}
+		elapsed := time.Since(v.(time.Time))
+		sql := db.Statement.SQL.String()
+		if elapsed < cfg.Threshold || sql == "" {
+			return
+		}
+		fingerprint := Fingerprint(sql)
+		slog.Warn("Slow query",
+			slog.String("component", "database"),
+			slog.String("caller", gormutils.FileWithLineNum()),
+			slog.Duration("duration", elapsed),
+			slog.Duration("threshold", cfg.Threshold),
+			slog.Int64("rows", db.RowsAffected),
+			slog.String("fingerprint", fingerprint),
+			slog.String("sql", sql),
+		)
+		if ex != nil && ex.limiter.allow(fingerprint, time.Now()) {
+			go ex.explain(sql, slices.Clone(db.Statement.Vars), fingerprint)
+		}
+	}
+
+	cb := gdb.Callback()
+	for _, p := range []struct {
+		name          string
+		before, after callbackRegistrar
+	}{
+		{"create", cb.Create().Before("*"), cb.Create().After("*")},
+		{"query", cb.Query().Before("*"), cb.Query().After("*")},
+		{"update", cb.Update().Before("*"), cb.Update().After("*")},
+		{"delete", cb.Delete().Before("*"), cb.Delete().After("*")},
+		{"raw", cb.Raw().Before("*"), cb.Raw().After("*")},
+	} {
+		name := "go-kit:slow_query_" + p.name
+		if err := p.before.Register(name+":before", before); err != nil {
+			return err
+		}
+		if err := p.after.Register(name+":after", after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// explainer logs the plans of slow statements.
+type explainer struct {
+	db      *gorm.DB
+	prefix  string
+	timeout time.Duration
+	limiter *fingerprintLimiter
+}
+
+func (e *explainer) explain(sql string, vars []any, fingerprint string) {
+	plan, err := e.plan(sql, vars)
+	if err != nil {
+		slog.Debug("Slow query EXPLAIN failed",
+			slog.String("component", "database"),
+			slog.String("fingerprint", fingerprint),
+			kitlog.Err(err),
+		)
+		return
+	}
+	slog.Info("Slow query plan",
+		slog.String("component", "database"),
+		slog.String("fingerprint", fingerprint),
+		slog.String("sql", sql),
+		slog.Any("plan", plan),
+	)
+}
+
+// plan runs the EXPLAIN of sql on a connection of its own, detached from
+// the statement's context and transaction, and returns its rows.
+func (e *explainer) plan(sql string, vars []any) ([]map[string]any, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	sqlDB, err := e.db.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, e.prefix+sql, vars...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var plan []map[string]any
+	for rows.Next() {
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(columns))
+		for i, col := range columns {
+			row[col] = planValue(values[i])
+		}
+		plan = append(plan, row)
+	}
+	return plan, rows.Err()
+}
+
+// planValue returns v as text, or as raw JSON for the JSON plans of MySQL.
+func planValue(v any) any {
+	var s string
+	switch v := v.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return v
+	}
+	if t := strings.TrimSpace(s); (strings.HasPrefix(t, "{") || strings.HasPrefix(t, "[")) && json.Valid([]byte(t)) {
+		return json.RawMessage(t)
+	}
+	return s
+}
+
+// fingerprintLimiter allows an action at most once per fingerprint per
+// interval.
+type fingerprintLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	last     map[string]time.Time
+}
+
+// maxLimiterEntries bounds the fingerprints a limiter remembers.
+const maxLimiterEntries = 1024
+
+func newFingerprintLimiter(interval time.Duration) *fingerprintLimiter {
+	if interval <= 0 {
+		interval = defaultExplainInterval
+	}
+	return &fingerprintLimiter{interval: interval, last: make(map[string]time.Time)}
+}
+
+func (l *fingerprintLimiter) allow(fingerprint string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t, ok := l.last[fingerprint]; ok && now.Sub(t) < l.interval {
+		return false
+	}
+	if len(l.last) >= maxLimiterEntries {
+		for fp, t := range l.last {
+			if now.Sub(t) >= l.interval {
+				delete(l.last, fp)
+			}
+		}
+		if len(l.last) >= maxLimiterEntries {
+			return false
+		}
+	}
+	l.last[fingerprint] = now
+	return true
+}
+
+var (
+	fingerprintList   = regexp.MustCompile(`\(\?(?:\s*,\s*\?)+\)`)
+	fingerprintValues = regexp.MustCompile(`\(\?\+\)(?:\s*,\s*\(\?\+\))+`)
+)
+
+// Fingerprint normalizes a SQL statement so that executions differing only
+// in literals share it, e.g. for rate limits and metric labels: string and
+// numeric literals and numbered placeholders become ?, lists of them
+// (?+), comments are removed, whitespace is collapsed and keywords and
+// unquoted identifiers are lowercased.
+//
+//	SELECT * FROM users WHERE id IN (1, 2, 3) AND name = 'bob'
+//	-> select * from users where id in (?+) and name = ?
+func Fingerprint(sql string) string {
+	var b strings.Builder
+	b.Grow(len(sql))
+	space := false
+	emit := func(s string) {
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteString(s)
+	}
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			i++
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+			space = true
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 4
+			}
+			space = true
+		case c == '\'':
+			i = skipQuoted(sql, i, '\'')
+			emit("?")
+		case c == '"' || c == '`':
+			j := skipQuoted(sql, i, c)
+			emit(sql[i:j])
+			i = j
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			i++
+			for i < len(sql) && isDigit(sql[i]) {
+				i++
+			}
+			emit("?")
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			for i < len(sql) && (isDigit(sql[i]) || sql[i] == '.' || sql[i] == 'e' || sql[i] == 'E' || sql[i] == 'x' || isHexDigit(sql[i])) {
+				i++
+			}
+			emit("?")
+		case isIdentChar(c):
+			j := i
+			for j < len(sql) && (isIdentChar(sql[j]) || isDigit(sql[j])) {
+				j++
+			}
+			emit(strings.ToLower(sql[i:j]))
+			i = j
+		default:
+			emit(string(c))
+			i++
+		}
+	}
+
+	fp := fingerprintList.ReplaceAllString(b.String(), "(?+)")
+	return fingerprintValues.ReplaceAllString(fp, "(?+)")
+}
+
+// skipQuoted returns the index after the quoted token starting at i. A
+// doubled or backslash-escaped quote does not end it.
+func skipQuoted(sql string, i int, quote byte) int {
+	for j := i + 1; j < len(sql); j++ {
+		switch sql[j] {
+		case '\\':
+			j++
+		case quote:
+			if j+1 < len(sql) && sql[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(sql)
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isHexDigit(c byte) bool { return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') }
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c == '@' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
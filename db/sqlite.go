@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/NSObjects/go-kit/config"
+)
+
+// Defaults of config.SqliteConfig.
+const (
+	DefaultSqliteJournalMode = "wal"
+	DefaultSqliteBusyTimeout = 5000 // milliseconds
+	DefaultSqliteSynchronous = "normal"
+)
+
+// sqlitePragmas are the resolved pragmas of config.SqliteConfig.
+type sqlitePragmas struct {
+	journalMode string
+	busyTimeout int
+	foreignKeys bool
+	cacheSize   int
+	synchronous string
+}
+
+// resolveSqlite applies the defaults to c.
+func resolveSqlite(c config.SqliteConfig) sqlitePragmas {
+	p := sqlitePragmas{
+		journalMode: strings.ToLower(c.JournalMode),
+		busyTimeout: c.BusyTimeoutMS,
+		foreignKeys: c.ForeignKeys == nil || *c.ForeignKeys,
+		cacheSize:   c.CacheSize,
+		synchronous: strings.ToLower(c.Synchronous),
+	}
+	if p.journalMode == "" {
+		p.journalMode = DefaultSqliteJournalMode
+	}
+	if p.busyTimeout <= 0 {
+		p.busyTimeout = DefaultSqliteBusyTimeout
+	}
+	if p.synchronous == "" {
+		p.synchronous = DefaultSqliteSynchronous
+	}
+	return p
+}
+
+// sqliteDSN returns the data source name of the sqlite database of cfg,
+// with its pragmas as go-sqlite3 parameters, so that every connection of
+// the pool gets them. Parameters already in cfg.Database win. Memory
+// databases have no journal mode to set.
+func sqliteDSN(cfg config.DatabaseConfig) string {
+	p := resolveSqlite(cfg.Sqlite)
+	path, query, _ := strings.Cut(cfg.Database, "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return cfg.Database // let the driver report it
+	}
+	set := func(name, value string) {
+		if !params.Has(name) {
+			params.Set(name, value)
+		}
+	}
+	if !sqliteInMemory(cfg.Database) {
+		set("_journal_mode", strings.ToUpper(p.journalMode))
+	}
+	set("_busy_timeout", strconv.Itoa(p.busyTimeout))
+	set("_foreign_keys", strconv.FormatBool(p.foreignKeys))
+	if p.cacheSize != 0 {
+		set("_cache_size", strconv.Itoa(p.cacheSize))
+	}
+	set("_synchronous", strings.ToUpper(p.synchronous))
+	return path + "?" + params.Encode()
+}
+
+// sqliteInMemory reports whether dsn is a memory database.
+func sqliteInMemory(dsn string) bool {
+	return dsn == ":memory:" || strings.HasPrefix(dsn, "file::memory:") || strings.Contains(dsn, "mode=memory")
+}
+
+// sqliteFile returns the path of the database file of dsn, empty for
+// memory databases.
+func sqliteFile(dsn string) string {
+	if sqliteInMemory(dsn) {
+		return ""
+	}
+	path, _, _ := strings.Cut(dsn, "?")
+	return strings.TrimPrefix(path, "file:")
+}
+
+// prepareSqliteFile creates the parent directory of the database file of
+// cfg.
+func prepareSqliteFile(cfg config.DatabaseConfig) error {
+	file := sqliteFile(cfg.Database)
+	if file == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return fmt.Errorf("create sqlite directory: %w", err)
+	}
+	return nil
+}
+
+// sqliteSingleConnection reports whether cfg is a sqlite file database
+// outside WAL mode, whose pool is limited to one connection: a rollback
+// journal locks the whole file, so concurrent connections fail with
+// "database is locked".
+func sqliteSingleConnection(cfg config.DatabaseConfig) bool {
+	return cfg.Driver == "sqlite" && !sqliteInMemory(cfg.Database) &&
+		resolveSqlite(cfg.Sqlite).journalMode != "wal"
+}
+
+// SqliteBackup writes a consistent copy of the sqlite database of m to
+// destPath with VACUUM INTO, while the database stays in use. The copy is
+// written next to destPath and renamed over it, so an existing backup is
+// replaced only by a complete one. The parent directory is created.
+//
+//	err := m.SqliteBackup(ctx, filepath.Join(backupDir, "app-"+time.Now().Format("20060102")+".db"))
+func (m *Manager) SqliteBackup(ctx context.Context, destPath string) error {
+	if m.DB == nil {
+		return errors.New("database not initialized")
+	}
+	if name := m.DB.Dialector.Name(); name != "sqlite" {
+		return fmt.Errorf("sqlite backup: unsupported %s database", name)
+	}
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		return fmt.Errorf("sqlite backup: %w", err)
+	}
+	tmp := destPath + ".tmp"
+	if err := os.Remove(tmp); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("sqlite backup: %w", err)
+	}
+	if err := m.DB.WithContext(ctx).Exec("VACUUM INTO ?", tmp).Error; err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("sqlite backup: %w", err)
+	}
+	if err := os.Rename(tmp, destPath); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("sqlite backup: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,388 @@
+package db
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/lifecycle"
+	kitlog "github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/metrics"
+	"github.com/NSObjects/go-kit/utils"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+// ErrNoTenantDatabase is returned by a TenantResolver for tenants without
+// a database of their own; they use the default database.
+var ErrNoTenantDatabase = errors.New("tenant has no dedicated database")
+
+// TenantResolver returns the database of a tenant, or ErrNoTenantDatabase.
+type TenantResolver func(tenantID string) (config.DatabaseConfig, error)
+
+// Defaults of TenantRouterOptions.
+const (
+	DefaultTenantMaxOpen     = 100
+	DefaultTenantIdleTimeout = 10 * time.Minute
+	DefaultTenantCloseGrace  = 30 * time.Second
+)
+
+// TenantRouterOptions configure a TenantRouter.
+type TenantRouterOptions struct {
+	// MaxOpen is the number of tenants kept, with their pool or their use
+	// of the default database; one more evicts the least recently used.
+	// Default DefaultTenantMaxOpen.
+	MaxOpen int
+	// IdleTimeout closes the pools of tenants not routed to for that long;
+	// default DefaultTenantIdleTimeout.
+	IdleTimeout time.Duration
+	// CloseGrace is how long an evicted pool stays usable by the requests
+	// that got it before it is closed; default DefaultTenantCloseGrace.
+	CloseGrace time.Duration
+	// Setup prepares each new pool, e.g. registers plugins (optional).
+	Setup func(tenantID string, gdb *gorm.DB) error
+	// Metrics records open, opened and evicted pools (optional).
+	Metrics *metrics.TenantDBMetrics
+	// Secrets resolves password references of tenant databases, e.g.
+	// Manager.Secrets (optional).
+	Secrets *config.SecretResolver
+}
+
+// TenantRouter holds a connection pool per tenant with a database of its
+// own. Pools are opened on first use, at most one per tenant however many
+// requests race for it, and closed when the tenant goes cold.
+//
+//	router := db.NewTenantRouter(func(tenantID string) (config.DatabaseConfig, error) {
+//	    t, err := tenants.Get(tenantID)
+//	    if err != nil {
+//	        return config.DatabaseConfig{}, err
+//	    }
+//	    if t.Database == nil {
+//	        return config.DatabaseConfig{}, db.ErrNoTenantDatabase
+//	    }
+//	    return *t.Database, nil
+//	})
+//	manager.Tenants = router
+//
+//	gdb, err := manager.DBForTenant(ctx)
+type TenantRouter struct {
+	resolve TenantResolver
+	opts    TenantRouterOptions
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // of *tenantConn
+	lru     *list.List               // most recently used first
+	closing map[*gorm.DB]*time.Timer // evicted pools in their grace period
+	closed  bool
+
+	group  singleflight.Group
+	cancel context.CancelFunc
+	done   <-chan struct{}
+}
+
+// tenantConn is the pool of a tenant; gdb is nil for tenants using the
+// default database.
+type tenantConn struct {
+	id       string
+	gdb      *gorm.DB
+	lastUsed time.Time
+}
+
+// NewTenantRouter creates a TenantRouter resolving tenant databases with
+// resolve. Close it to close the pools.
+func NewTenantRouter(resolve TenantResolver, opts ...TenantRouterOptions) *TenantRouter {
+	var o TenantRouterOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.MaxOpen <= 0 {
+		o.MaxOpen = DefaultTenantMaxOpen
+	}
+	if o.IdleTimeout <= 0 {
+		o.IdleTimeout = DefaultTenantIdleTimeout
+	}
+	if o.CloseGrace <= 0 {
+		o.CloseGrace = DefaultTenantCloseGrace
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &TenantRouter{
+		resolve: resolve,
+		opts:    o,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		closing: make(map[*gorm.DB]*time.Timer),
+		cancel:  cancel,
+	}
+	r.done = lifecycle.Go(ctx, "db.tenant_evict", r.evictIdle).Done()
+	return r
+}
+
+// DB returns the pool of tenantID, opening it on first use. It returns
+// ErrNoTenantDatabase for tenants using the default database. A caller
+// whose ctx is done stops waiting for the pool, which is still opened for
+// the others.
+func (r *TenantRouter) DB(ctx context.Context, tenantID string) (*gorm.DB, error) {
+	if c, ok := r.lookup(tenantID); ok {
+		return tenantDB(c)
+	}
+	ch := r.group.DoChan(tenantID, func() (any, error) {
+		if c, ok := r.lookup(tenantID); ok {
+			return c, nil
+		}
+		return r.open(tenantID)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return tenantDB(res.Val.(*tenantConn))
+	}
+}
+
+func tenantDB(c *tenantConn) (*gorm.DB, error) {
+	if c.gdb == nil {
+		return nil, ErrNoTenantDatabase
+	}
+	return c.gdb, nil
+}
+
+// lookup returns the entry of tenantID, marking it used.
+func (r *TenantRouter) lookup(tenantID string) (*tenantConn, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[tenantID]
+	if !ok {
+		return nil, false
+	}
+	c := e.Value.(*tenantConn)
+	c.lastUsed = time.Now()
+	r.lru.MoveToFront(e)
+	return c, true
+}
+
+// open resolves and opens the pool of tenantID and adds it, evicting the
+// least recently used pools beyond MaxOpen.
+func (r *TenantRouter) open(tenantID string) (*tenantConn, error) {
+	c := &tenantConn{id: tenantID}
+	cfg, err := r.resolve(tenantID)
+	switch {
+	case errors.Is(err, ErrNoTenantDatabase):
+	case err != nil:
+		return nil, err
+	default:
+		gdb, err := NewDatabaseWithSecrets(cfg, io.Discard, r.opts.Secrets)
+		if err != nil {
+			return nil, code.WrapErrorf(err, code.ErrDatabase, "open database of tenant %s", tenantID)
+		}
+		if r.opts.Setup != nil {
+			if err := r.opts.Setup(tenantID, gdb); err != nil {
+				closeGorm(gdb)
+				return nil, code.WrapErrorf(err, code.ErrDatabase, "set up database of tenant %s", tenantID)
+			}
+		}
+		c.gdb = gdb
+	}
+	c.lastUsed = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		closeGorm(c.gdb)
+		return nil, fmt.Errorf("tenant router closed")
+	}
+	r.entries[tenantID] = r.lru.PushFront(c)
+	if c.gdb != nil {
+		slog.Info("Tenant database opened", slog.String("component", "database"), slog.String("tenant_id", tenantID))
+		if m := r.opts.Metrics; m != nil {
+			m.Opened.Inc()
+			m.Open.Inc()
+		}
+	}
+	for r.lru.Len() > r.opts.MaxOpen {
+		r.evict(r.lru.Back(), "lru")
+	}
+	return c, nil
+}
+
+// evict removes an entry and closes its pool after the grace period;
+// r.mu must be held.
+func (r *TenantRouter) evict(e *list.Element, reason string) {
+	c := r.lru.Remove(e).(*tenantConn)
+	delete(r.entries, c.id)
+	if c.gdb == nil {
+		return
+	}
+	slog.Info("Tenant database evicted", slog.String("component", "database"),
+		slog.String("tenant_id", c.id), slog.String("reason", reason))
+	if m := r.opts.Metrics; m != nil {
+		m.Open.Dec()
+		m.Evicted.WithLabelValues(reason).Inc()
+	}
+	gdb := c.gdb
+	r.closing[gdb] = time.AfterFunc(r.opts.CloseGrace, func() {
+		r.mu.Lock()
+		_, ok := r.closing[gdb]
+		delete(r.closing, gdb)
+		r.mu.Unlock()
+		if ok {
+			closeGorm(gdb)
+		}
+	})
+}
+
+// evictIdle evicts the entries unused for IdleTimeout until ctx is done.
+func (r *TenantRouter) evictIdle(ctx context.Context) error {
+	ticker := time.NewTicker(max(r.opts.IdleTimeout/2, time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			r.evictIdleAt(now)
+		}
+	}
+}
+
+// evictIdleAt evicts the entries unused for IdleTimeout at now.
+func (r *TenantRouter) evictIdleAt(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for e := r.lru.Back(); e != nil; {
+		prev := e.Prev()
+		if now.Sub(e.Value.(*tenantConn).lastUsed) < r.opts.IdleTimeout {
+			break
+		}
+		r.evict(e, "idle")
+		e = prev
+	}
+}
+
+// Open returns the tenants whose pool is open.
+func (r *TenantRouter) Open() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ids []string
+	for e := r.lru.Front(); e != nil; e = e.Next() {
+		if c := e.Value.(*tenantConn); c.gdb != nil {
+			ids = append(ids, c.id)
+		}
+	}
+	return ids
+}
+
+// Evict closes the pool of tenantID, e.g. after its database moved; the
+// next DB call resolves it again.
+func (r *TenantRouter) Evict(tenantID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.entries[tenantID]; ok {
+		r.evict(e, "manual")
+	}
+}
+
+// Health pings the open tenant pools, by tenant; pools are not opened for
+// the check.
+func (r *TenantRouter) Health(ctx context.Context) map[string]error {
+	r.mu.Lock()
+	conns := make([]*tenantConn, 0, r.lru.Len())
+	for e := r.lru.Front(); e != nil; e = e.Next() {
+		if c := e.Value.(*tenantConn); c.gdb != nil {
+			conns = append(conns, c)
+		}
+	}
+	r.mu.Unlock()
+
+	health := make(map[string]error, len(conns))
+	for _, c := range conns {
+		sqlDB, err := c.gdb.DB()
+		if err == nil {
+			err = sqlDB.PingContext(ctx)
+		}
+		health[c.id] = err
+	}
+	return health
+}
+
+// Close stops the idle eviction and closes every pool, including the
+// evicted ones still in their grace period.
+func (r *TenantRouter) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	var pools []*gorm.DB
+	for e := r.lru.Front(); e != nil; e = e.Next() {
+		if c := e.Value.(*tenantConn); c.gdb != nil {
+			pools = append(pools, c.gdb)
+		}
+	}
+	if m := r.opts.Metrics; m != nil {
+		m.Open.Sub(float64(len(pools)))
+	}
+	for gdb, t := range r.closing {
+		t.Stop()
+		pools = append(pools, gdb)
+	}
+	r.entries = make(map[string]*list.Element)
+	r.lru.Init()
+	r.closing = make(map[*gorm.DB]*time.Timer)
+	r.mu.Unlock()
+
+	r.cancel()
+	<-r.done
+	var errs []error
+	for _, gdb := range pools {
+		if sqlDB, err := gdb.DB(); err == nil {
+			if err := sqlDB.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// closeGorm closes the pool of gdb, logging failures.
+func closeGorm(gdb *gorm.DB) {
+	if gdb == nil {
+		return
+	}
+	sqlDB, err := gdb.DB()
+	if err == nil {
+		err = sqlDB.Close()
+	}
+	if err != nil {
+		slog.Warn("Tenant database close failed", slog.String("component", "database"), kitlog.Err(err))
+	}
+}
+
+// DBForTenant returns the database of the tenant of ctx (see
+// utils.GetTenantID), bound to ctx: the pool of Tenants for tenants with a
+// database of their own, else the default database.
+func (m *Manager) DBForTenant(ctx context.Context) (*gorm.DB, error) {
+	if tenantID := utils.GetTenantID(ctx); tenantID != "" && m.Tenants != nil {
+		gdb, err := m.Tenants.DB(ctx, tenantID)
+		if err == nil {
+			return gdb.WithContext(ctx), nil
+		}
+		if !errors.Is(err, ErrNoTenantDatabase) {
+			return nil, err
+		}
+	}
+	if m.DB == nil {
+		return nil, code.NewError(code.ErrDatabase, "database not configured")
+	}
+	return m.DB.WithContext(ctx), nil
+}
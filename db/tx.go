@@ -0,0 +1,21 @@
+package db
+
+import (
+	"context"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"gorm.io/gorm"
+)
+
+// WithTransaction runs fn inside a database transaction bound to ctx.
+// The transaction is committed if fn returns nil and rolled back otherwise
+// (including when fn panics). Errors without a code are wrapped as
+// database errors.
+func WithTransaction(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	err := db.WithContext(ctx).Transaction(fn)
+	if err != nil && errors.GetCode(err) == 0 {
+		return code.WrapDatabaseError(err, "transaction")
+	}
+	return err
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// DefaultUpsertBatchSize is the batch size of Upsert when none is given.
+const DefaultUpsertBatchSize = 100
+
+// UpsertOptions configure Upsert.
+type UpsertOptions struct {
+	// Ignore leaves conflicting rows untouched instead of updating them
+	// (ON CONFLICT DO NOTHING); the update columns are not used.
+	Ignore bool
+	// Except are columns never updated. With no update columns, every
+	// column but these is updated.
+	Except []string
+}
+
+// Upsert inserts rows in batches of batchSize (default
+// DefaultUpsertBatchSize) and, for rows conflicting on conflictCols
+// (default the primary key), updates updateCols instead. With no update
+// columns every column is updated except the primary key, the conflict
+// columns, autoCreateTime columns such as CreatedAt, and opts.Except.
+// autoUpdateTime columns such as UpdatedAt are set to the current time.
+// It returns the rows affected by each batch, as counted by the driver:
+// MySQL counts 2 for each updated row and 0 for an unchanged one.
+//
+//	counts, err := db.Upsert(ctx, gdb, prices, []string{"sku"}, []string{"amount"}, 500)
+//
+// The conflict clause follows the dialect: ON CONFLICT (...) DO UPDATE on
+// PostgreSQL and SQLite, ON DUPLICATE KEY UPDATE on MySQL. MySQL applies
+// it to a conflict on any unique key, whatever conflictCols are.
+//
+// All batches run in one transaction, a savepoint when gdb is already in
+// one. Unique violations outside the conflict target fail with
+// code.ErrAlreadyExists; other constraint violations (foreign keys, NOT
+// NULL, CHECK) and rows repeating a conflict key within a batch fail with
+// code.ErrBadRequest; other errors are translated by TranslateError.
+func Upsert[T any](ctx context.Context, gdb *gorm.DB, rows []T, conflictCols, updateCols []string, batchSize int, opts ...UpsertOptions) ([]int64, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	var o UpsertOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultUpsertBatchSize
+	}
+
+	onConflict, err := upsertClause[T](gdb, conflictCols, updateCols, o)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]int64, 0, (len(rows)+batchSize-1)/batchSize)
+	err = gdb.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for batch := range slices.Chunk(rows, batchSize) {
+			res := tx.Clauses(onConflict).Create(&batch)
+			if res.Error != nil {
+				return res.Error
+			}
+			counts = append(counts, res.RowsAffected)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, translateUpsertError(err)
+	}
+	return counts, nil
+}
+
+// upsertClause builds the conflict clause of Upsert for the model T.
+func upsertClause[T any](gdb *gorm.DB, conflictCols, updateCols []string, o UpsertOptions) (clause.OnConflict, error) {
+	stmt := &gorm.Statement{DB: gdb}
+	if err := stmt.Parse(new(T)); err != nil {
+		return clause.OnConflict{}, fmt.Errorf("upsert: parse model: %w", err)
+	}
+	sch := stmt.Schema
+
+	if len(conflictCols) == 0 {
+		for _, f := range sch.PrimaryFields {
+			conflictCols = append(conflictCols, f.DBName)
+		}
+	}
+	onConflict := clause.OnConflict{DoNothing: o.Ignore}
+	for _, col := range conflictCols {
+		if sch.LookUpField(col) == nil {
+			return clause.OnConflict{}, fmt.Errorf("upsert: unknown conflict column %q of %s", col, sch.Name)
+		}
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: col})
+	}
+	if o.Ignore {
+		return onConflict, nil
+	}
+
+	if len(updateCols) == 0 {
+		for _, f := range sch.Fields {
+			if f.DBName == "" || f.PrimaryKey || !f.Creatable || f.AutoCreateTime != 0 || slices.Contains(conflictCols, f.DBName) {
+				continue
+			}
+			updateCols = append(updateCols, f.DBName)
+		}
+	}
+	var columns []string
+	for _, col := range updateCols {
+		f := sch.LookUpField(col)
+		if f == nil {
+			return clause.OnConflict{}, fmt.Errorf("upsert: unknown update column %q of %s", col, sch.Name)
+		}
+		if f.AutoUpdateTime == 0 && !slices.Contains(o.Except, f.DBName) {
+			columns = append(columns, f.DBName)
+		}
+	}
+	if len(columns) == 0 {
+		onConflict.DoNothing = true
+		return onConflict, nil
+	}
+	onConflict.DoUpdates = clause.AssignmentColumns(columns)
+
+	// Updated rows get a new update time, not the one of the row.
+	now := gdb.NowFunc()
+	for _, f := range sch.Fields {
+		if f.DBName == "" || f.AutoUpdateTime == 0 || slices.Contains(o.Except, f.DBName) {
+			continue
+		}
+		var v any = now
+		switch f.AutoUpdateTime {
+		case schema.UnixNanosecond:
+			v = now.UnixNano()
+		case schema.UnixMillisecond:
+			v = now.UnixMilli()
+		case schema.UnixSecond:
+			v = now.Unix()
+		}
+		onConflict.DoUpdates = append(onConflict.DoUpdates, clause.Assignment{Column: clause.Column{Name: f.DBName}, Value: v})
+	}
+	return onConflict, nil
+}
+
+// translateUpsertError converts the constraint violations of an upsert
+// into client errors, and other errors with TranslateError.
+func translateUpsertError(err error) error {
+	switch {
+	case errors.GetCode(err) != 0:
+		return err
+	case isDuplicateKey(err):
+		return code.WrapError(err, code.ErrAlreadyExists, "record conflicts with an existing record")
+	case isConstraintViolation(err):
+		return code.WrapError(err, code.ErrBadRequest, "record violates a constraint")
+	case isRepeatedConflict(err):
+		return code.WrapError(err, code.ErrBadRequest, "records repeat a conflict key")
+	default:
+		return TranslateError(err, "upsert")
+	}
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/NSObjects/go-kit/config"
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newConnectorDialector returns a dialector opening the connections of cfg
+// through a driver.Connector, resolving a password reference with secrets
+// as they are opened (see secretConnector) and validating idle ones before
+// reuse (see validatingConnector).
+func newConnectorDialector(cfg config.DatabaseConfig, secrets *config.SecretResolver) (gorm.Dialector, error) {
+	var (
+		drv connectorDriver
+		dsn func(config.DatabaseConfig) string
+	)
+	switch cfg.Driver {
+	case "mysql", "":
+		drv, dsn = gomysql.MySQLDriver{}, mysqlDSN
+	case "postgres":
+		var ok bool
+		if drv, ok = stdlib.GetDefaultDriver().(connectorDriver); !ok {
+			return nil, fmt.Errorf("postgres driver does not open connectors")
+		}
+		dsn = postgresDSN
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", cfg.Driver)
+	}
+
+	var connector driver.Connector
+	if secrets.IsRef(cfg.Password) {
+		connector = &secretConnector{cfg: cfg, secrets: secrets, driver: drv, dsn: dsn}
+	} else {
+		var err error
+		if connector, err = drv.OpenConnector(dsn(cfg)); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ValidateIdle > 0 {
+		connector = &validatingConnector{Connector: connector, idle: cfg.ValidateIdle}
+	}
+
+	if cfg.Driver == "postgres" {
+		return postgres.New(postgres.Config{Conn: sql.OpenDB(connector)}), nil
+	}
+	return mysql.New(mysql.Config{Conn: sql.OpenDB(connector)}), nil
+}
+
+// validatingConnector opens connections that ping themselves before being
+// reused after more than idle in the pool. A connection failing the ping
+// reports driver.ErrBadConn, so database/sql discards it and runs the
+// statement on another connection instead of failing it: flows a load
+// balancer silently dropped while idle cost a ping, not an error.
+type validatingConnector struct {
+	driver.Connector
+	idle time.Duration
+}
+
+// Connect implements driver.Connector.
+func (c *validatingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &validatingConn{Conn: conn, idle: c.idle}, nil
+}
+
+// validatingConn is a driver.Conn tracking when it was returned to the
+// pool. It forwards the optional interfaces of the driver's connection,
+// reporting driver.ErrSkip where database/sql has a fallback.
+type validatingConn struct {
+	driver.Conn
+	idle time.Duration
+	// returned is when the connection was last put back in the pool, in
+	// Unix nanoseconds.
+	returned atomic.Int64
+}
+
+// IsValid implements driver.Validator. database/sql calls it when putting
+// the connection back in the pool.
+func (c *validatingConn) IsValid() bool {
+	c.returned.Store(time.Now().UnixNano())
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// ResetSession implements driver.SessionResetter. database/sql calls it
+// before reusing the connection.
+func (c *validatingConn) ResetSession(ctx context.Context) error {
+	if returned := c.returned.Load(); returned > 0 && time.Since(time.Unix(0, returned)) > c.idle {
+		if err := c.Ping(ctx); err != nil {
+			return driver.ErrBadConn
+		}
+	}
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+// Ping implements driver.Pinger.
+func (c *validatingConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// PrepareContext implements driver.ConnPrepareContext.
+func (c *validatingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// BeginTx implements driver.ConnBeginTx.
+func (c *validatingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) || opts.ReadOnly {
+		return nil, fmt.Errorf("driver does not support transaction options")
+	}
+	return c.Conn.Begin() //nolint:staticcheck // fallback for drivers without BeginTx
+}
+
+// ExecContext implements driver.ExecerContext.
+func (c *validatingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := c.Conn.(driver.ExecerContext); ok {
+		return e.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+// QueryContext implements driver.QueryerContext.
+func (c *validatingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := c.Conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+// CheckNamedValue implements driver.NamedValueChecker.
+func (c *validatingConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if ch, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
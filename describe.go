@@ -0,0 +1,142 @@
+package kit
+
+import (
+	"runtime/debug"
+
+	"github.com/NSObjects/go-kit/config"
+)
+
+// Well-known paths of the self-description endpoints (see
+// middleware.DescribeHandler and middleware.ErrorCodesHandler).
+const (
+	DescribePath   = "/.well-known/kit"
+	ErrorCodesPath = "/.well-known/kit/error-codes"
+)
+
+// Middleware describes a middleware installed by middleware.Setup.
+type Middleware struct {
+	// Name identifies the middleware, e.g. "recovery" or "jwt".
+	Name string `json:"name"`
+	// Scope is "global" or the route group it applies to.
+	Scope string `json:"scope"`
+	// Options are the effective settings worth reporting.
+	Options map[string]any `json:"options,omitempty"`
+}
+
+// SetupRecord is what middleware.Setup installed, in installation order
+// (see RouteGroups.Installed).
+type SetupRecord struct {
+	Middleware  []Middleware
+	HealthPaths []string
+	MetricsPath string
+	DebugPaths  []string
+}
+
+// Chain returns the names of the middleware running for the routes of
+// the group scope ("public", "authenticated" or "admin"), outermost
+// first: the global middleware, then those of the group.
+func (r SetupRecord) Chain(scope string) []string {
+	var global, group []string
+	for _, m := range r.Middleware {
+		switch m.Scope {
+		case "", "global":
+			global = append(global, m.Name)
+		case scope:
+			group = append(group, m.Name)
+		}
+	}
+	return append(global, group...)
+}
+
+// Extras are the parts of a Description not derived from the config.
+type Extras struct {
+	// Setup is the record of middleware.Setup.
+	Setup SetupRecord
+	// OpenAPIURL is where the OpenAPI document is served, if anywhere.
+	OpenAPIURL string
+	// ErrorCodesURL is where the error codes are served; default
+	// ErrorCodesPath.
+	ErrorCodesURL string
+}
+
+// Build is the build information of the binary.
+type Build struct {
+	GoVersion string `json:"go_version"`
+	Module    string `json:"module,omitempty"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// Description is the self-description of a service, for platform tooling.
+type Description struct {
+	Service       string       `json:"service"`
+	Version       string       `json:"version"`
+	Env           string       `json:"env"`
+	Build         Build        `json:"build"`
+	Middleware    []Middleware `json:"middleware"`
+	ErrorCodesURL string       `json:"error_codes_url"`
+	HealthPaths   []string     `json:"health_paths"`
+	MetricsPath   string       `json:"metrics_path,omitempty"`
+	DebugPaths    []string     `json:"debug_paths,omitempty"`
+	OpenAPIURL    string       `json:"openapi_url,omitempty"`
+}
+
+// Describe assembles the description of the service configured by cfg.
+// The middleware list comes from extras.Setup, recorded by
+// middleware.Setup as it installed them.
+//
+//	groups := middleware.Setup(e, deps)
+//	doc := kit.Describe(cfg, kit.Extras{Setup: groups.Installed, OpenAPIURL: "/openapi.json"})
+//	internal.GET(kit.DescribePath, middleware.DescribeHandler(doc))
+//	internal.GET(kit.ErrorCodesPath, middleware.ErrorCodesHandler())
+func Describe(cfg config.Config, extras Extras) Description {
+	d := Description{
+		Service:       cfg.System.Name,
+		Version:       cfg.System.Version,
+		Env:           cfg.System.Env,
+		Build:         readBuild(),
+		Middleware:    extras.Setup.Middleware,
+		ErrorCodesURL: extras.ErrorCodesURL,
+		HealthPaths:   extras.Setup.HealthPaths,
+		MetricsPath:   extras.Setup.MetricsPath,
+		DebugPaths:    extras.Setup.DebugPaths,
+		OpenAPIURL:    extras.OpenAPIURL,
+	}
+	if d.Version == "" {
+		d.Version = d.Build.Module
+	}
+	if d.ErrorCodesURL == "" {
+		d.ErrorCodesURL = ErrorCodesPath
+	}
+	if d.Middleware == nil {
+		d.Middleware = []Middleware{}
+	}
+	if d.HealthPaths == nil {
+		d.HealthPaths = []string{}
+	}
+	return d
+}
+
+// readBuild reads the build information embedded by the Go toolchain.
+func readBuild() Build {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return Build{}
+	}
+	b := Build{GoVersion: info.GoVersion}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		b.Module = v
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			b.Revision = s.Value
+		case "vcs.time":
+			b.Time = s.Value
+		case "vcs.modified":
+			b.Modified = s.Value == "true"
+		}
+	}
+	return b
+}
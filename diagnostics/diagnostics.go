@@ -0,0 +1,237 @@
+// Package diagnostics runs the health checks of a service once, from the
+// command line and without its HTTP port, for local checks such as
+// `kubectl exec app -- /app healthcheck` and for init containers waiting
+// on dependencies. The same binary serves both modes:
+//
+//	func main() {
+//	    cfg, err := config.Bootstrap[config.Config]("configs/config.toml")
+//	    ...
+//	    if diagnostics.Requested(os.Args[1:]) {
+//	        os.Exit(diagnostics.Run(context.Background(), cfg))
+//	    }
+//	    // start the server
+//	}
+//
+// Run opens the components of the config in check-only mode (see
+// db.WithCheckOnly), checks the config and pings them, prints the report
+// and returns the exit code. Flags, after the command:
+//
+//	-json             print the report as JSON
+//	-timeout 10s      bound one run of the checks
+//	-wait 2m          run the checks until healthy or 2m passed
+//	-interval 2s      pause between runs with -wait
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/db"
+	"github.com/NSObjects/go-kit/health"
+)
+
+// Command is the first argument selecting diagnostics mode.
+const Command = "healthcheck"
+
+// EnvVar selects diagnostics mode when set to a true value (see
+// strconv.ParseBool), e.g. for images whose entrypoint takes no
+// arguments.
+const EnvVar = "KIT_DIAGNOSTICS"
+
+// Defaults of the flags.
+const (
+	DefaultTimeout  = 10 * time.Second
+	DefaultInterval = 2 * time.Second
+)
+
+// Exit codes of Run.
+const (
+	ExitHealthy   = 0 // healthy or degraded
+	ExitUnhealthy = 1
+	ExitUsage     = 2 // invalid flags
+)
+
+// Options configure Run.
+type Options struct {
+	// Args are the flags; default the arguments after Command in os.Args.
+	Args []string
+	// Stdout receives the report; default os.Stdout.
+	Stdout io.Writer
+	// Stderr receives usage errors and the progress of -wait; default
+	// os.Stderr.
+	Stderr io.Writer
+	// Checkers are checks of the application run along those of the kit.
+	Checkers []health.Checker
+	// DBOptions are passed to db.NewManager after db.WithCheckOnly.
+	DBOptions []db.Option
+}
+
+// Requested reports whether args (os.Args[1:]) start with Command or
+// EnvVar is set.
+func Requested(args []string) bool {
+	if len(args) > 0 && args[0] == Command {
+		return true
+	}
+	on, _ := strconv.ParseBool(os.Getenv(EnvVar))
+	return on
+}
+
+// Run checks cfg and its components, writes the report and returns the
+// process exit code: ExitHealthy when the overall status is healthy or
+// degraded, ExitUnhealthy otherwise.
+func Run(ctx context.Context, cfg config.Config, opts ...Options) int {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Stdout == nil {
+		o.Stdout = os.Stdout
+	}
+	if o.Stderr == nil {
+		o.Stderr = os.Stderr
+	}
+	if o.Args == nil {
+		o.Args = defaultArgs()
+	}
+
+	fs := flag.NewFlagSet(Command, flag.ContinueOnError)
+	fs.SetOutput(o.Stderr)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	timeout := fs.Duration("timeout", DefaultTimeout, "bound one run of the checks")
+	wait := fs.Duration("wait", 0, "run the checks until healthy or this much time passed")
+	interval := fs.Duration("interval", DefaultInterval, "pause between runs with -wait")
+	if err := fs.Parse(o.Args); err != nil {
+		return ExitUsage
+	}
+
+	d := &diagnoser{cfg: cfg, opts: o, timeout: *timeout}
+	defer d.close()
+
+	var snap health.Snapshot
+	if *wait > 0 {
+		snap = d.wait(ctx, *wait, *interval)
+	} else {
+		snap = d.run(ctx)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(o.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(snap)
+	} else {
+		_ = snap.WriteText(o.Stdout)
+	}
+	if snap.Status == health.StatusUnhealthy {
+		return ExitUnhealthy
+	}
+	return ExitHealthy
+}
+
+// defaultArgs returns the arguments of the process after Command.
+func defaultArgs() []string {
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == Command {
+		return args[1:]
+	}
+	return args
+}
+
+// diagnoser runs the checks, opening the components on first use.
+type diagnoser struct {
+	cfg     config.Config
+	opts    Options
+	timeout time.Duration
+	manager *db.Manager
+}
+
+// run runs the checks once.
+func (d *diagnoser) run(ctx context.Context) health.Snapshot {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	start := time.Now()
+
+	checks := []health.Check{configCheck(d.cfg)}
+	registry := health.NewRegistry()
+	if d.manager == nil {
+		opts := append([]db.Option{db.WithCheckOnly()}, d.opts.DBOptions...)
+		m, err := db.NewManager(ctx, d.cfg, opts...)
+		if err != nil {
+			checks = append(checks, health.Check{Name: "db", Status: health.StatusUnhealthy, Message: err.Error()})
+		} else {
+			d.manager = m
+		}
+	}
+	if d.manager != nil {
+		for _, c := range d.manager.Checkers() {
+			registry.Register(c)
+		}
+	}
+	for _, c := range d.opts.Checkers {
+		registry.Register(c)
+	}
+	checks = append(checks, registry.CheckAll(ctx)...)
+	return health.NewSnapshot(checks, start, time.Since(start))
+}
+
+// wait runs the checks every interval until they are not unhealthy, ctx
+// is canceled or limit passed, and returns the last snapshot.
+func (d *diagnoser) wait(ctx context.Context, limit, interval time.Duration) health.Snapshot {
+	ctx, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+	for {
+		snap := d.run(ctx)
+		if snap.Status != health.StatusUnhealthy {
+			return snap
+		}
+		fmt.Fprintf(d.opts.Stderr, "waiting: %s\n", unhealthyNames(snap))
+		select {
+		case <-ctx.Done():
+			return snap
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (d *diagnoser) close() {
+	if d.manager != nil {
+		_ = d.manager.Stop(context.Background())
+	}
+}
+
+// configCheck reports the problems of config.Check: errors make it
+// unhealthy, warnings degraded.
+func configCheck(cfg config.Config) health.Check {
+	check := health.Check{Name: "config", Status: health.StatusHealthy}
+	problems := config.Check(cfg)
+	if len(problems) == 0 {
+		return check
+	}
+	check.Status = health.StatusDegraded
+	if problems.HasErrors() {
+		check.Status = health.StatusUnhealthy
+	}
+	msgs := make([]string, len(problems))
+	for i, p := range problems {
+		msgs[i] = p.String()
+	}
+	check.Message = strings.Join(msgs, "; ")
+	return check
+}
+
+func unhealthyNames(snap health.Snapshot) string {
+	var names []string
+	for _, c := range snap.Checks {
+		if c.Status == health.StatusUnhealthy {
+			names = append(names, c.Name)
+		}
+	}
+	return strings.Join(names, ", ") + " unhealthy"
+}
@@ -37,6 +37,36 @@ logging, middleware, and database connectivity.
 	    }
 	}
 
+# Profiles
+
+Profile (Dev, Test, Prod, or one added with RegisterProfile) holds the
+per-environment defaults for logging, middleware and metrics, selected by
+SystemConfig.Env. Explicit config values always win; see Resolve.
+
+# Self-description
+
+Describe assembles a JSON document of the service (name, version, build,
+installed middleware, health and metrics paths) for platform tooling,
+served at DescribePath by middleware.DescribeHandler.
+
+LogStartupSummary logs one record of what a service runs with (service,
+listen address, middleware, connected components with masked endpoints,
+logging and tracing), as server.Runner does once started when given a
+StartupSummary; in dev it also prints a banner.
+
+# Identifiers
+
+ID is an int64 identifier that JSON renders as a string, so JavaScript
+clients keep its precision; see resp.JSONOptions to render every int64
+that way.
+
+# Route audit
+
+middleware.Setup records every route of the echo instance with the
+middleware running for it (see AuditRoutes). VerifyRoutes checks them
+against RouteRules, e.g. that admin routes run JWT before Casbin, and
+CheckRoutes refuses startup on violations outside the dev profile.
+
 # Version
 
 v1.0.0 - Stable release
@@ -0,0 +1,173 @@
+package errors
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// RetryableCode reports whether errors with a code are transient (see
+// code.IsRetryable), for Audit. The code package sets it.
+var RetryableCode = func(code int) bool { return false }
+
+// AuditKind is the kind of an AuditFinding.
+type AuditKind string
+
+// Kinds of AuditFinding.
+const (
+	// AuditUnregistered is a code constant never registered.
+	AuditUnregistered AuditKind = "unregistered"
+	// AuditStatusRange is a code whose HTTP status is outside the status
+	// range reserved for it (see Registrar.ReserveStatus), or is not a 4xx
+	// or 5xx status outside reserved ranges.
+	AuditStatusRange AuditKind = "status_range"
+	// AuditDuplicateMessage is a code whose message is the message of
+	// another code, so clients cannot tell them apart.
+	AuditDuplicateMessage AuditKind = "duplicate_message"
+	// AuditRetryableClientError is a retryable code with a 4xx status other
+	// than 408 and 429: retrying a client error fails again.
+	AuditRetryableClientError AuditKind = "retryable_4xx"
+)
+
+// AuditFinding is a problem of the code registry found by Audit.
+type AuditFinding struct {
+	Kind AuditKind `json:"kind"`
+	Code int       `json:"code"`
+	// Name and Pos locate the constant of the code, when known from
+	// AuditOptions.Constants.
+	Name    string `json:"name,omitempty"`
+	Pos     string `json:"pos,omitempty"`
+	Message string `json:"message"`
+}
+
+// String renders the finding for test failures.
+func (f AuditFinding) String() string {
+	var b strings.Builder
+	if f.Pos != "" {
+		b.WriteString(f.Pos + ": ")
+	}
+	fmt.Fprintf(&b, "[%s] code %d", f.Kind, f.Code)
+	if f.Name != "" {
+		b.WriteString(" (" + f.Name + ")")
+	}
+	b.WriteString(": " + f.Message)
+	return b.String()
+}
+
+// AuditConstant is a code constant declared in source, as found by
+// kitlint.ScanCodes.
+type AuditConstant struct {
+	Name string `json:"name"`
+	Code int    `json:"code"`
+	// Pos is the "file:line" of the declaration.
+	Pos string `json:"pos"`
+}
+
+// AuditOptions configure Audit.
+type AuditOptions struct {
+	// Constants are the declared code constants, checked for
+	// registration; without them unregistered constants go unnoticed.
+	Constants []AuditConstant
+	// Ignore drops the findings it returns true for, e.g. intended aliases
+	// with the same message.
+	Ignore func(AuditFinding) bool
+}
+
+// Audit checks the registered codes, sorted by code, for statuses outside
+// their reserved range, 2xx and 3xx error codes, duplicate messages and
+// retryable client errors, and the declared Constants for registration.
+//
+//	findings := errors.Audit(errors.AuditOptions{Constants: consts})
+func Audit(opts ...AuditOptions) []AuditFinding {
+	var o AuditOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	coders := Registered()
+	byCode := make(map[int]AuditConstant, len(o.Constants))
+	for _, c := range o.Constants {
+		byCode[c.Code] = c
+	}
+
+	var findings []AuditFinding
+	add := func(kind AuditKind, code int, format string, args ...any) {
+		c := byCode[code]
+		f := AuditFinding{Kind: kind, Code: code, Name: c.Name, Pos: c.Pos, Message: fmt.Sprintf(format, args...)}
+		if o.Ignore == nil || !o.Ignore(f) {
+			findings = append(findings, f)
+		}
+	}
+
+	registryMu.RLock()
+	ranges := make([]*StatusRange, len(coders))
+	for i, c := range coders {
+		if r, ok := statusRangeOf(c.Code()); ok {
+			ranges[i] = &r
+		}
+	}
+	registryMu.RUnlock()
+
+	for i, c := range coders {
+		status := c.HTTPStatus()
+		if r := ranges[i]; r != nil {
+			if status < r.MinStatus || status > r.MaxStatus {
+				add(AuditStatusRange, c.Code(), "status %d outside %d-%d reserved for codes %d-%d",
+					status, r.MinStatus, r.MaxStatus, r.From, r.To)
+			}
+		} else if status < 400 || status > 599 {
+			add(AuditStatusRange, c.Code(), "status %d of an error code is not a 4xx or 5xx status", status)
+		}
+	}
+
+	messages := make(map[string]int, len(coders))
+	for _, c := range coders {
+		msg := strings.ToLower(strings.TrimSpace(c.Message()))
+		if first, ok := messages[msg]; ok {
+			add(AuditDuplicateMessage, c.Code(), "message %q already used by code %d", c.Message(), first)
+		} else {
+			messages[msg] = c.Code()
+		}
+
+		status := c.HTTPStatus()
+		if RetryableCode(c.Code()) && status >= 400 && status < 500 &&
+			status != http.StatusRequestTimeout && status != http.StatusTooManyRequests {
+			add(AuditRetryableClientError, c.Code(), "retryable code with client error status %d", status)
+		}
+	}
+
+	for _, c := range o.Constants {
+		if _, ok := Lookup(c.Code); !ok {
+			add(AuditUnregistered, c.Code, "constant %s is not registered", c.Name)
+		}
+	}
+
+	slices.SortStableFunc(findings, func(a, b AuditFinding) int {
+		return cmp.Or(cmp.Compare(a.Code, b.Code), cmp.Compare(a.Kind, b.Kind))
+	})
+	return findings
+}
+
+// AuditTB is the subset of testing.TB used by RunAuditTest.
+type AuditTB interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// RunAuditTest fails t with every finding of Audit, for a test of the
+// package registering the codes, which must be imported:
+//
+//	func TestErrorCodes(t *testing.T) {
+//	    consts, err := kitlint.ScanCodes(".")
+//	    if err != nil {
+//	        t.Fatal(err)
+//	    }
+//	    errors.RunAuditTest(t, errors.AuditOptions{Constants: consts})
+//	}
+func RunAuditTest(t AuditTB, opts ...AuditOptions) {
+	t.Helper()
+	for _, f := range Audit(opts...) {
+		t.Errorf("%s", f)
+	}
+}
@@ -3,6 +3,7 @@ package errors
 import (
 	"fmt"
 	"net/http"
+	"slices"
 	"sync"
 )
 
@@ -14,6 +15,9 @@ type Coder interface {
 	HTTPStatus() int
 	// Message returns the user-facing message.
 	Message() string
+	// Reference returns the developer documentation URL or reference of
+	// the code, empty when none was registered.
+	Reference() string
 }
 
 // coder is the default implementation of Coder.
@@ -21,11 +25,14 @@ type coder struct {
 	code       int
 	httpStatus int
 	message    string
+	reference  string
+	namespace  string
 }
 
-func (c coder) Code() int       { return c.code }
-func (c coder) HTTPStatus() int { return c.httpStatus }
-func (c coder) Message() string { return c.message }
+func (c coder) Code() int         { return c.code }
+func (c coder) HTTPStatus() int   { return c.httpStatus }
+func (c coder) Message() string   { return c.message }
+func (c coder) Reference() string { return c.reference }
 
 var (
 	registry   = make(map[int]coder)
@@ -39,40 +46,44 @@ var (
 )
 
 // Register registers an error code with its HTTP status and message.
-// Panics if the code is 0 or already registered.
+// Panics if the code is 0 or already registered. Prefer registering
+// through a Namespace, which names the owners of colliding codes.
 func Register(code int, httpStatus int, message string) {
-	if code == 0 {
-		panic("error code 0 is reserved")
-	}
-
-	registryMu.Lock()
-	defer registryMu.Unlock()
-
-	if _, exists := registry[code]; exists {
-		panic(fmt.Sprintf("error code %d already registered", code))
-	}
+	register(coder{code: code, httpStatus: httpStatus, message: message}, false)
+}
 
-	registry[code] = coder{
-		code:       code,
-		httpStatus: httpStatus,
-		message:    message,
-	}
+// RegisterFull is Register with a developer reference, such as a
+// documentation URL, returned to clients next to userMessage. The
+// user message is what clients see; logs keep the full error text.
+func RegisterFull(code int, httpStatus int, userMessage, ref string) {
+	register(coder{code: code, httpStatus: httpStatus, message: userMessage, reference: ref}, false)
 }
 
 // MustRegister is like Register but allows overwriting existing codes.
 func MustRegister(code int, httpStatus int, message string) {
-	if code == 0 {
+	register(coder{code: code, httpStatus: httpStatus, message: message}, true)
+}
+
+func register(c coder, overwrite bool) {
+	if c.code == 0 {
 		panic("error code 0 is reserved")
 	}
 
 	registryMu.Lock()
 	defer registryMu.Unlock()
 
-	registry[code] = coder{
-		code:       code,
-		httpStatus: httpStatus,
-		message:    message,
+	if prev, exists := registry[c.code]; exists && !overwrite {
+		panic(fmt.Sprintf("error code %d (%s) already registered by %s", c.code, owner(c.namespace), owner(prev.namespace)))
 	}
+	registry[c.code] = c
+}
+
+// owner describes a namespace in collision messages.
+func owner(namespace string) string {
+	if namespace == "" {
+		return "no namespace"
+	}
+	return "namespace " + namespace
 }
 
 // Lookup retrieves a Coder by code.
@@ -84,6 +95,19 @@ func Lookup(code int) (Coder, bool) {
 	return c, ok
 }
 
+// Registered returns all registered codes, sorted by code.
+func Registered() []Coder {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	coders := make([]Coder, 0, len(registry))
+	for _, c := range registry {
+		coders = append(coders, c)
+	}
+	slices.SortFunc(coders, func(a, b Coder) int { return a.Code() - b.Code() })
+	return coders
+}
+
 // HTTPStatus returns the HTTP status for an error code.
 // Returns 500 if code is not registered.
 func HTTPStatus(code int) int {
@@ -172,8 +196,8 @@ type CodedError interface {
 	Code() int
 }
 
-// GetCode extracts the error code from an error.
-// Returns 0 if no code is found.
+// GetCode returns the outermost code in err's tree: the first CodedError
+// found by As. Returns 0 if no code is found.
 func GetCode(err error) int {
 	var coded CodedError
 	if As(err, &coded) {
@@ -182,17 +206,68 @@ func GetCode(err error) int {
 	return 0
 }
 
-// IsCode reports whether any error in err's chain has the given code.
+// RootCode returns the innermost code in err's tree: the last code found
+// in depth-first order, usually the code of the original failure. Returns
+// 0 if no code is found.
+func RootCode(err error) int {
+	root := 0
+	walkCodes(err, func(c int) bool {
+		root = c
+		return true
+	})
+	return root
+}
+
+// IsCode reports whether any error in err's tree has the given code. Like
+// Is, it follows both Unwrap() error and Unwrap() []error.
 func IsCode(err error, code int) bool {
-	for err != nil {
-		if coded, ok := err.(CodedError); ok {
-			if coded.Code() == code {
-				return true
+	return IsAnyCode(err, code)
+}
+
+// IsAnyCode reports whether any error in err's tree has one of codes.
+//
+//	if errors.IsAnyCode(err, code.ErrNotFound, code.ErrForbidden) { ... }
+func IsAnyCode(err error, codes ...int) bool {
+	found := false
+	walkCodes(err, func(c int) bool {
+		found = slices.Contains(codes, c)
+		return !found
+	})
+	return found
+}
+
+// CodesIn returns every code in err's tree in depth-first order, outermost
+// first. Duplicates are kept.
+func CodesIn(err error) []int {
+	var codes []int
+	walkCodes(err, func(c int) bool {
+		codes = append(codes, c)
+		return true
+	})
+	return codes
+}
+
+// walkCodes calls fn with the code of each CodedError in err's tree, in
+// the pre-order depth-first order used by Is and As, until fn returns
+// false. It reports whether the walk completed.
+func walkCodes(err error, fn func(code int) bool) bool {
+	if err == nil {
+		return true
+	}
+	if coded, ok := err.(CodedError); ok && !fn(coded.Code()) {
+		return false
+	}
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		return walkCodes(u.Unwrap(), fn)
+	case interface{ Unwrap() []error }:
+		for _, e := range u.Unwrap() {
+			if !walkCodes(e, fn) {
+				return false
 			}
 		}
-		err = Unwrap(err)
 	}
-	return false
+	return true
 }
 
 // ParseCoder extracts Coder from an error.
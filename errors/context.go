@@ -0,0 +1,31 @@
+package errors
+
+import "context"
+
+// Codes used by FromContextError. The code package sets them to
+// code.ErrTimeout and code.ErrClientClosedRequest.
+var (
+	TimeoutCode  = 100504
+	CanceledCode = 100499
+)
+
+// FromContextError converts context.DeadlineExceeded into a TimeoutCode
+// error and context.Canceled into a CanceledCode error, so timeouts and
+// client disconnects are not reported as internal errors. Other errors,
+// already coded errors and nil are returned unchanged.
+//
+//	if err := db.WithContext(ctx).First(&u).Error; err != nil {
+//	    return errors.FromContextError(err)
+//	}
+func FromContextError(err error) error {
+	if err == nil || GetCode(err) != 0 {
+		return err
+	}
+	switch {
+	case Is(err, context.DeadlineExceeded):
+		return WrapCode(err, TimeoutCode, "deadline exceeded")
+	case Is(err, context.Canceled):
+		return WrapCode(err, CanceledCode, "request canceled")
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package errors
+
+import "fmt"
+
+// withDetails attaches client-facing details to an error.
+type withDetails struct {
+	err     error
+	details any
+}
+
+// WithDetails returns err with details, a JSON-encodable value returned
+// to clients as the data of the error response, such as the URL to
+// upgrade from. Like WithUserMessage, only client errors (4xx) show it.
+//
+//	return errors.WithDetails(
+//	    code.NewError(code.ErrUpgradeRequired, "client 1.2.0 below 2.0.0"),
+//	    map[string]string{"upgrade_url": "https://example.com/app"})
+func WithDetails(err error, details any) error {
+	if err == nil {
+		return nil
+	}
+	return &withDetails{err: err, details: details}
+}
+
+// Details returns the outermost details attached with WithDetails.
+func Details(err error) (any, bool) {
+	var d *withDetails
+	if As(err, &d) {
+		return d.details, true
+	}
+	return nil, false
+}
+
+func (e *withDetails) Error() string { return e.err.Error() }
+func (e *withDetails) Unwrap() error { return e.err }
+
+func (e *withDetails) Format(s fmt.State, verb rune) {
+	if f, ok := e.err.(fmt.Formatter); ok {
+		f.Format(s, verb)
+		return
+	}
+	fmt.Fprint(s, e.Error())
+}
@@ -0,0 +1,155 @@
+// Package errtest provides assertions on errors for tests. Failures print
+// the whole error chain with the codes found and the %+v stack of the
+// innermost error, so CI output points at the failing call.
+//
+//	err := svc.GetUser(ctx, "u1")
+//	errtest.AssertCode(t, err, code.ErrNotFound)
+//	errtest.RequireRetryable(t, err, false)
+package errtest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+)
+
+// TB is the subset of testing.TB used by the assertions.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+	Fatalf(format string, args ...any)
+}
+
+// AssertCode reports a failure unless some error in err's tree has code
+// want (see errors.IsCode).
+func AssertCode(t TB, err error, want int) bool {
+	t.Helper()
+	if errors.IsCode(err, want) {
+		return true
+	}
+	t.Errorf("error does not carry code %d\n%s", want, Describe(err))
+	return false
+}
+
+// RequireCode is AssertCode stopping the test on failure.
+func RequireCode(t TB, err error, want int) {
+	t.Helper()
+	if !errors.IsCode(err, want) {
+		t.Fatalf("error does not carry code %d\n%s", want, Describe(err))
+	}
+}
+
+// AssertIs reports a failure unless errors.Is(err, target).
+func AssertIs(t TB, err, target error) bool {
+	t.Helper()
+	if errors.Is(err, target) {
+		return true
+	}
+	t.Errorf("error is not %v\n%s", target, Describe(err))
+	return false
+}
+
+// RequireRetryable stops the test unless code.IsRetryable(err) is want.
+func RequireRetryable(t TB, err error, want bool) {
+	t.Helper()
+	if code.IsRetryable(err) != want {
+		t.Fatalf("retryable = %t, want %t\n%s", !want, want, Describe(err))
+	}
+}
+
+// Matcher matches errors. It implements gomock.Matcher, and works with
+// any assertion library through Matches and String:
+//
+//	m := errtest.MatchError(code.ErrNotFound, "u1")
+//	assert.True(t, m.Matches(err), m.Explain(err))
+type Matcher struct {
+	code     int
+	contains []string
+}
+
+// MatchError matches errors carrying code (0 matches any non-nil error)
+// whose message contains every fragment.
+func MatchError(code int, contains ...string) Matcher {
+	return Matcher{code: code, contains: contains}
+}
+
+// Matches reports whether x is an error matching m.
+func (m Matcher) Matches(x any) bool {
+	err, ok := x.(error)
+	if !ok || err == nil {
+		return false
+	}
+	if m.code != 0 && !errors.IsCode(err, m.code) {
+		return false
+	}
+	msg := err.Error()
+	for _, s := range m.contains {
+		if !strings.Contains(msg, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// String describes m.
+func (m Matcher) String() string {
+	var b strings.Builder
+	b.WriteString("error")
+	if m.code != 0 {
+		fmt.Fprintf(&b, " with code %d", m.code)
+	}
+	if len(m.contains) > 0 {
+		fmt.Fprintf(&b, " containing %q", m.contains)
+	}
+	return b.String()
+}
+
+// Explain describes why err does or does not match m.
+func (m Matcher) Explain(err error) string {
+	return "want " + m.String() + "\n" + Describe(err)
+}
+
+// Describe renders err for failure messages: every error of the tree with
+// its type and code, the codes found, and the %+v form of the innermost
+// error.
+func Describe(err error) string {
+	if err == nil {
+		return "got: <nil>"
+	}
+	var b strings.Builder
+	b.WriteString("got chain:\n")
+	innermost := describe(&b, err, 1)
+	fmt.Fprintf(&b, "codes found: %v\n", errors.CodesIn(err))
+	fmt.Fprintf(&b, "innermost error:\n%+v", innermost)
+	return b.String()
+}
+
+// describe writes err and its tree indented by depth and returns the
+// innermost error of the first branch.
+func describe(b *strings.Builder, err error, depth int) error {
+	fmt.Fprintf(b, "%s%T: %s", strings.Repeat("  ", depth), err, err.Error())
+	if coded, ok := err.(errors.CodedError); ok {
+		fmt.Fprintf(b, " [code %d]", coded.Code())
+	}
+	b.WriteByte('\n')
+
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		if next := u.Unwrap(); next != nil {
+			return describe(b, next, depth+1)
+		}
+	case interface{ Unwrap() []error }:
+		var innermost error
+		for _, e := range u.Unwrap() {
+			if in := describe(b, e, depth+1); innermost == nil {
+				innermost = in
+			}
+		}
+		if innermost != nil {
+			return innermost
+		}
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+package errors
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// MessageTranslator returns the message of code for the locale of ctx,
+// or message, the registered one, when it has no translation.
+type MessageTranslator func(ctx context.Context, code int, message string) string
+
+var translator atomic.Pointer[MessageTranslator]
+
+// SetMessageTranslator sets the translator of MessageFor; nil removes it.
+// i18n.SetDefault sets one looking up "error.<code>" in its bundle.
+func SetMessageTranslator(t MessageTranslator) {
+	if t == nil {
+		translator.Store(nil)
+		return
+	}
+	translator.Store(&t)
+}
+
+// MessageFor returns the user-facing message of code for the locale of
+// ctx: the registered message, translated when a MessageTranslator is
+// set. It is empty when code is not registered.
+func MessageFor(ctx context.Context, code int) string {
+	c, ok := Lookup(code)
+	if !ok {
+		return ""
+	}
+	if t := translator.Load(); t != nil {
+		return (*t)(ctx, code, c.Message())
+	}
+	return c.Message()
+}
@@ -0,0 +1,190 @@
+package errors
+
+import (
+	"fmt"
+	"slices"
+)
+
+// NamespaceSize is the number of codes reserved by a namespace: offsets
+// 1 to NamespaceSize-1 of its base.
+const NamespaceSize = 1000
+
+// Registrar registers the codes of a namespace (see Namespace).
+type Registrar struct {
+	name string
+	base int
+}
+
+// NamespaceInfo describes a namespace: it owns the codes in [Base, End).
+type NamespaceInfo struct {
+	Name     string        `json:"name"`
+	Base     int           `json:"base"`
+	End      int           `json:"end"`
+	Statuses []StatusRange `json:"statuses,omitempty"`
+}
+
+// StatusRange reserves the codes From to To, inclusive, for the HTTP
+// statuses MinStatus to MaxStatus (see Registrar.ReserveStatus).
+type StatusRange struct {
+	From      int `json:"from"`
+	To        int `json:"to"`
+	MinStatus int `json:"min_status"`
+	MaxStatus int `json:"max_status"`
+}
+
+// statusRangeOf returns the status range of the namespaces containing
+// code; registryMu must be held.
+func statusRangeOf(code int) (StatusRange, bool) {
+	for _, ns := range namespaces {
+		for _, r := range ns.Statuses {
+			if code >= r.From && code <= r.To {
+				return r, true
+			}
+		}
+	}
+	return StatusRange{}, false
+}
+
+var namespaces = make(map[string]NamespaceInfo)
+
+// Namespace reserves the codes base to base+NamespaceSize-1 for name and
+// returns its registrar. Libraries and applications each take a namespace,
+// so that two of them defining the same code fail with both names instead
+// of an anonymous duplicate:
+//
+//	var billing = errors.Namespace("billing", 200000)
+//
+//	var ErrInvoicePaid = billing.Register(1, 409, "Invoice already paid") // 200001
+//
+// Calling Namespace again with the same name and base returns a registrar
+// of the same namespace. It panics when name is empty, when name was
+// reserved with another base, or when the range overlaps the range of
+// another namespace.
+func Namespace(name string, base int) *Registrar {
+	if name == "" {
+		panic("error namespace name is empty")
+	}
+	if base < 0 {
+		panic(fmt.Sprintf("error namespace %s: negative base %d", name, base))
+	}
+	info := NamespaceInfo{Name: name, Base: base, End: base + NamespaceSize}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if prev, ok := namespaces[name]; ok {
+		if prev.Base != base {
+			panic(fmt.Sprintf("error namespace %s reserved with base %d, requested again with base %d", name, prev.Base, base))
+		}
+		return &Registrar{name: name, base: base}
+	}
+	for _, other := range namespaces {
+		if info.Base < other.End && other.Base < info.End {
+			panic(fmt.Sprintf("error namespace %s [%d, %d) overlaps namespace %s [%d, %d)",
+				name, info.Base, info.End, other.Name, other.Base, other.End))
+		}
+	}
+	namespaces[name] = info
+	return &Registrar{name: name, base: base}
+}
+
+// Name returns the name of the namespace.
+func (r *Registrar) Name() string { return r.name }
+
+// Base returns the base of the namespace.
+func (r *Registrar) Base() int { return r.base }
+
+// ReserveStatus reserves the offsets from to to, inclusive, of the
+// namespace for the HTTP statuses minStatus to maxStatus, so that Audit
+// reports codes registered there with other statuses:
+//
+//	billing.ReserveStatus(100, 199, 500, 599) // 200100-200199: server errors
+//
+// Codes outside every reserved range must have a 4xx or 5xx status. It
+// panics when the offsets are outside 1 to NamespaceSize-1 or overlap
+// another range of the namespace.
+func (r *Registrar) ReserveStatus(from, to, minStatus, maxStatus int) {
+	if from < 1 || to >= NamespaceSize || from > to {
+		panic(fmt.Sprintf("error namespace %s: status range offsets [%d, %d] outside [1, %d)", r.name, from, to, NamespaceSize))
+	}
+	sr := StatusRange{From: r.base + from, To: r.base + to, MinStatus: minStatus, MaxStatus: maxStatus}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	info := namespaces[r.name]
+	for _, other := range info.Statuses {
+		if sr.From <= other.To && other.From <= sr.To {
+			panic(fmt.Sprintf("error namespace %s: status range [%d, %d] overlaps [%d, %d]", r.name, sr.From, sr.To, other.From, other.To))
+		}
+	}
+	info.Statuses = append(info.Statuses, sr)
+	namespaces[r.name] = info
+}
+
+// Register registers the code base+offset with its HTTP status and message
+// and returns it. It panics when offset is outside 1 to NamespaceSize-1 or
+// the code is already registered, naming the namespaces involved.
+func (r *Registrar) Register(offset, httpStatus int, message string) int {
+	return r.RegisterFull(offset, httpStatus, message, "")
+}
+
+// RegisterFull is Register with a developer reference (see the package
+// function RegisterFull).
+func (r *Registrar) RegisterFull(offset, httpStatus int, userMessage, ref string) int {
+	if offset < 1 || offset >= NamespaceSize {
+		panic(fmt.Sprintf("error code offset %d of namespace %s outside [1, %d)", offset, r.name, NamespaceSize))
+	}
+	code := r.base + offset
+	register(coder{code: code, httpStatus: httpStatus, message: userMessage, reference: ref, namespace: r.name}, false)
+	return code
+}
+
+// RegisterCode registers an absolute code of the namespace, for constants
+// defined before namespaces existed:
+//
+//	const ErrInvoicePaid = 200001
+//	billing.RegisterCode(ErrInvoicePaid, 409, "Invoice already paid")
+//
+// It panics when code is outside the namespace or already registered.
+func (r *Registrar) RegisterCode(code, httpStatus int, message string) {
+	if code <= r.base || code >= r.base+NamespaceSize {
+		panic(fmt.Sprintf("error code %d outside namespace %s [%d, %d)", code, r.name, r.base+1, r.base+NamespaceSize))
+	}
+	r.Register(code-r.base, httpStatus, message)
+}
+
+// NamespaceOf returns the namespace owning code: the one it was
+// registered through, or whose range contains it. It is empty for codes
+// outside every namespace.
+func NamespaceOf(code int) string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return namespaceOf(code)
+}
+
+// namespaceOf is NamespaceOf; registryMu must be held.
+func namespaceOf(code int) string {
+	if c, ok := registry[code]; ok && c.namespace != "" {
+		return c.namespace
+	}
+	for _, ns := range namespaces {
+		if code >= ns.Base && code < ns.End {
+			return ns.Name
+		}
+	}
+	return ""
+}
+
+// Namespaces returns the reserved namespaces, sorted by base.
+func Namespaces() []NamespaceInfo {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	out := make([]NamespaceInfo, 0, len(namespaces))
+	for _, ns := range namespaces {
+		out = append(out, ns)
+	}
+	slices.SortFunc(out, func(a, b NamespaceInfo) int { return a.Base - b.Base })
+	return out
+}
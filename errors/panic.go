@@ -0,0 +1,76 @@
+package errors
+
+import "fmt"
+
+// PanicCode is the code of errors created by FromPanic. The code package
+// sets it to code.ErrInternalServer.
+var PanicCode = 100500
+
+// panicError is an error converted from a recovered panic.
+type panicError struct {
+	value any
+	stack []uintptr
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *panicError) Unwrap() error {
+	if err, ok := e.value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+func (e *panicError) Code() int {
+	return PanicCode
+}
+
+func (e *panicError) StackTrace() []uintptr {
+	return e.stack
+}
+
+func (e *panicError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "[%d] %s", PanicCode, e.Error())
+			formatStack(s, e.stack)
+			return
+		}
+		fallthrough
+	case 's':
+		fmt.Fprint(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+// FromPanic converts a value returned by recover() into a coded error
+// (PanicCode). The stack trace is captured at the call site, which inside a
+// deferred function still includes the frames that panicked. An error panic
+// value stays reachable through errors.Is/As. Returns nil if recovered is nil.
+//
+//	defer func() {
+//	    if r := recover(); r != nil {
+//	        err = errors.FromPanic(r)
+//	    }
+//	}()
+func FromPanic(recovered any) error {
+	if recovered == nil {
+		return nil
+	}
+	return &panicError{
+		value: recovered,
+		stack: callers(),
+	}
+}
+
+// IsPanic reports whether err (or any error in its chain) was created by
+// FromPanic.
+func IsPanic(err error) bool {
+	var pe *panicError
+	return As(err, &pe)
+}
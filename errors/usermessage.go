@@ -0,0 +1,44 @@
+package errors
+
+import "fmt"
+
+// userMessage attaches a client-facing message to an error.
+type userMessage struct {
+	err error
+	msg string
+}
+
+// WithUserMessage returns err with msg as the message shown to clients in
+// place of the registered message of its code. Only client errors (4xx)
+// show it; server errors always respond with the registered message.
+// Error() and logs keep err's text.
+//
+//	return errors.WithUserMessage(
+//	    code.NewError(code.ErrConflict, "slot %d taken by booking %d", slot, other),
+//	    "This time slot was just booked, please pick another one")
+func WithUserMessage(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return &userMessage{err: err, msg: msg}
+}
+
+// UserMessage returns the outermost message attached with WithUserMessage.
+func UserMessage(err error) (string, bool) {
+	var um *userMessage
+	if As(err, &um) {
+		return um.msg, true
+	}
+	return "", false
+}
+
+func (e *userMessage) Error() string { return e.err.Error() }
+func (e *userMessage) Unwrap() error { return e.err }
+
+func (e *userMessage) Format(s fmt.State, verb rune) {
+	if f, ok := e.err.(fmt.Formatter); ok {
+		f.Format(s, verb)
+		return
+	}
+	fmt.Fprint(s, e.Error())
+}
@@ -0,0 +1,39 @@
+// Command consumer is a sample queue consumer built on the worker runtime.
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/NSObjects/go-kit/cache"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/db"
+	"github.com/NSObjects/go-kit/worker"
+)
+
+type OrderPaid struct {
+	OrderID string `json:"order_id"`
+}
+
+func main() {
+	cfg, _ := config.Bootstrap[config.Config]("configs/config.toml")
+	host, _ := os.Hostname()
+
+	err := worker.Run(context.Background(), cfg,
+		worker.Handler{
+			Name:        "order-paid",
+			Source:      worker.Stream(db.NewRedis(cfg.Redis), "orders.paid", "billing", host, cache.StreamConsumerOptions{}),
+			Concurrency: 4,
+			MaxLag:      1000,
+			Handle: worker.JSON(func(ctx context.Context, ev OrderPaid) error {
+				slog.InfoContext(ctx, "Order paid", slog.String("order_id", ev.OrderID))
+				return nil
+			}),
+		},
+	)
+	if err != nil {
+		slog.Error("Consumer stopped", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,43 @@
+// Command upload is a sample endpoint storing image uploads on local disk.
+package main
+
+import (
+	"net/http"
+
+	"github.com/NSObjects/go-kit/middleware"
+	"github.com/NSObjects/go-kit/resp"
+	"github.com/NSObjects/go-kit/upload"
+	"github.com/labstack/echo/v4"
+)
+
+func main() {
+	store := upload.NewLocalStorage("uploads")
+
+	e := echo.New()
+	middleware.RegisterErrorHandling(e)
+	e.Use(middleware.Recovery())
+
+	e.POST("/images", func(c echo.Context) error {
+		files, err := upload.Parse(c, store, upload.Config{
+			MaxSize:     5 << 20,
+			AllowedMIME: []string{"image/png", "image/jpeg", "image/gif"},
+			MaxFiles:    4,
+			KeyPrefix:   "images/",
+		})
+		if err != nil {
+			return err
+		}
+		return resp.OneDataResponse(c, echo.Map{"files": files, "album": c.FormValue("album")})
+	})
+
+	e.GET("/images/:name", func(c echo.Context) error {
+		f, err := store.Open("images/" + c.Param("name"))
+		if err != nil {
+			return echo.ErrNotFound
+		}
+		defer f.Close()
+		return c.Stream(http.StatusOK, "application/octet-stream", f)
+	})
+
+	e.Logger.Fatal(e.Start(":8080"))
+}
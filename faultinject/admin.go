@@ -0,0 +1,71 @@
+package faultinject
+
+import (
+	"context"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/resp"
+	"github.com/labstack/echo/v4"
+)
+
+// EnabledRequest is the body of PUT /enabled.
+type EnabledRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// RulesRequest is the body of PUT /rules. Kinds left out keep their rules.
+type RulesRequest struct {
+	HTTP     *[]config.FaultRuleConfig `json:"http"`
+	Cache    *[]config.FaultRuleConfig `json:"cache"`
+	Database *[]config.FaultRuleConfig `json:"database"`
+}
+
+// Register mounts the runtime control of i on g, as
+// adminserver.WithFaultInjection does:
+//
+//	GET /          status, rules and injected counts
+//	PUT /enabled   {"enabled": true} switches injection
+//	PUT /rules     {"http": [...], "cache": [...], "database": [...]}
+//	               replaces the rules of the kinds given
+func (i *Injector) Register(g *echo.Group) {
+	g.GET("", func(c echo.Context) error {
+		return resp.SuccessJSON(c, i.Status())
+	})
+	g.PUT("/enabled", resp.Handler(func(ctx context.Context, req EnabledRequest) (Status, error) {
+		if err := i.SetEnabled(req.Enabled); err != nil {
+			return Status{}, err
+		}
+		return i.Status(), nil
+	}))
+	g.PUT("/rules", resp.Handler(func(ctx context.Context, req RulesRequest) (Status, error) {
+		kinds := []struct {
+			kind  string
+			rules *[]config.FaultRuleConfig
+		}{{KindHTTP, req.HTTP}, {KindCache, req.Cache}, {KindDatabase, req.Database}}
+		// Validate every kind first, so a bad rule changes nothing.
+		for _, k := range kinds {
+			for n, r := range deref(k.rules) {
+				if err := r.Validate(k.kind); err != nil {
+					return Status{}, code.NewErrorf(code.ErrBadRequest, "%s[%d]: %v", k.kind, n, err)
+				}
+			}
+		}
+		for _, k := range kinds {
+			if k.rules == nil {
+				continue
+			}
+			if err := i.SetRules(k.kind, *k.rules); err != nil {
+				return Status{}, err
+			}
+		}
+		return i.Status(), nil
+	}))
+}
+
+func deref(rules *[]config.FaultRuleConfig) []config.FaultRuleConfig {
+	if rules == nil {
+		return nil
+	}
+	return *rules
+}
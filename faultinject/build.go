@@ -0,0 +1,6 @@
+//go:build !nofaultinject
+
+package faultinject
+
+// compiledIn is false when built with the nofaultinject tag.
+const compiledIn = true
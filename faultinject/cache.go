@@ -0,0 +1,52 @@
+package faultinject
+
+import (
+	"context"
+	"time"
+
+	"github.com/NSObjects/go-kit/cache"
+)
+
+// faultCache is a cache.Cache injecting the faults of the cache rules.
+type faultCache struct {
+	cache    cache.Cache
+	injector *Injector
+}
+
+// WrapCache wraps c to inject the faults of the cache rules, matched by
+// operation: latency, and errors wrapping ErrInjected. Only the
+// cache.Cache methods are wrapped.
+func (i *Injector) WrapCache(c cache.Cache) cache.Cache {
+	if !i.usable() {
+		return c
+	}
+	return &faultCache{cache: c, injector: i}
+}
+
+func (c *faultCache) Get(ctx context.Context, key string, dest any) error {
+	if err := c.injector.inject(ctx, KindCache, "get"); err != nil {
+		return err
+	}
+	return c.cache.Get(ctx, key, dest)
+}
+
+func (c *faultCache) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	if err := c.injector.inject(ctx, KindCache, "set"); err != nil {
+		return err
+	}
+	return c.cache.Set(ctx, key, value, expiration)
+}
+
+func (c *faultCache) Delete(ctx context.Context, key string) error {
+	if err := c.injector.inject(ctx, KindCache, "delete"); err != nil {
+		return err
+	}
+	return c.cache.Delete(ctx, key)
+}
+
+func (c *faultCache) Exists(ctx context.Context, key string) (bool, error) {
+	if err := c.injector.inject(ctx, KindCache, "exists"); err != nil {
+		return false, err
+	}
+	return c.cache.Exists(ctx, key)
+}
@@ -0,0 +1,278 @@
+// Package faultinject injects faults into a service for resilience testing:
+// latency, 500/503 responses and connection resets on HTTP routes, and
+// latency and errors on cache and database operations, each for a
+// configured fraction of the matched requests or operations:
+//
+//	faults := faultinject.New(cfg.FaultInjection, cfg.System.Env)
+//	e.Use(faults.Middleware())
+//	c := faults.WrapCache(redisCache)
+//	err := gdb.Use(faults.Plugin())
+//	admin, err := adminserver.NewAdmin(cfg.Admin, adminserver.WithFaultInjection(faults))
+//
+// Every injected fault is logged and set on the current span with
+// fault_injected=true. Injection is switched and its rules replaced at
+// runtime through the admin server (see Injector.Register).
+//
+// An Injector never activates when system.env is "prod", whatever its
+// config: there, and for a nil Injector, Middleware, WrapCache and Plugin
+// return pass-throughs. Building with the nofaultinject tag compiles
+// injection out of every Injector.
+package faultinject
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Kinds of injection points, as in the keys of Status.Injected.
+const (
+	KindHTTP     = "http"
+	KindCache    = "cache"
+	KindDatabase = "database"
+)
+
+// Fault types, as logged with fault.type.
+const (
+	FaultLatency = "latency"
+	FaultError   = "error"
+	FaultReset   = "reset"
+)
+
+// ErrInjected is the error of injected cache and database failures.
+var ErrInjected = errors.New("fault injected")
+
+// settings are the hot-swappable settings of an Injector.
+type settings struct {
+	enabled bool
+	rules   map[string][]config.FaultRuleConfig
+}
+
+// Injector injects the faults of a config.FaultInjectionConfig.
+type Injector struct {
+	env      string
+	refused  bool // the environment forbids injection
+	settings atomic.Pointer[settings]
+	injected [3]atomic.Uint64 // by kind, in kinds order
+}
+
+var kinds = []string{KindHTTP, KindCache, KindDatabase}
+
+// New creates an Injector for cfg in the environment env (system.env).
+// Invalid rules are logged and dropped. In "prod" the Injector refuses to
+// activate and logs so when cfg is enabled.
+func New(cfg config.FaultInjectionConfig, env string) *Injector {
+	i := &Injector{env: env, refused: env == "prod"}
+	i.settings.Store(&settings{})
+	if i.refused {
+		if cfg.Enabled {
+			slog.Warn("Fault injection refused in prod", slog.String("env", env))
+		}
+		return i
+	}
+	s := &settings{enabled: cfg.Enabled, rules: map[string][]config.FaultRuleConfig{}}
+	for kind, rules := range rulesByKind(cfg) {
+		for n, r := range rules {
+			if err := r.Validate(kind); err != nil {
+				slog.Error("Invalid fault injection rule, ignored",
+					slog.String("rule", fmt.Sprintf("%s[%d]", kind, n)), log.Err(err))
+				continue
+			}
+			s.rules[kind] = append(s.rules[kind], r)
+		}
+	}
+	i.settings.Store(s)
+	if cfg.Enabled {
+		slog.Warn("Fault injection enabled", slog.String("env", env))
+	}
+	return i
+}
+
+func rulesByKind(cfg config.FaultInjectionConfig) map[string][]config.FaultRuleConfig {
+	return map[string][]config.FaultRuleConfig{KindHTTP: cfg.HTTP, KindCache: cfg.Cache, KindDatabase: cfg.Database}
+}
+
+// usable reports whether i can ever inject, so the pass-throughs are
+// returned otherwise.
+func (i *Injector) usable() bool {
+	return compiledIn && i != nil && !i.refused
+}
+
+// Active reports whether faults are being injected.
+func (i *Injector) Active() bool {
+	return i.usable() && i.settings.Load().enabled
+}
+
+// SetEnabled switches injection on or off. Switching it on fails with
+// code.ErrForbidden in prod or when built with the nofaultinject tag.
+func (i *Injector) SetEnabled(on bool) error {
+	if on && !i.usable() {
+		return code.NewErrorf(code.ErrForbidden, "fault injection is not available in env %q", i.env)
+	}
+	if !i.usable() {
+		return nil
+	}
+	for {
+		old := i.settings.Load()
+		s := *old
+		s.enabled = on
+		if i.settings.CompareAndSwap(old, &s) {
+			slog.Warn("Fault injection switched", slog.Bool("enabled", on))
+			return nil
+		}
+	}
+}
+
+// SetRules replaces the rules of kind (KindHTTP, KindCache or
+// KindDatabase). Invalid rules fail with code.ErrBadRequest and leave the
+// rules unchanged.
+func (i *Injector) SetRules(kind string, rules []config.FaultRuleConfig) error {
+	if kind != KindHTTP && kind != KindCache && kind != KindDatabase {
+		return code.NewErrorf(code.ErrBadRequest, "unknown fault injection kind %q", kind)
+	}
+	for n, r := range rules {
+		if err := r.Validate(kind); err != nil {
+			return code.NewErrorf(code.ErrBadRequest, "%s[%d]: %v", kind, n, err)
+		}
+	}
+	if !i.usable() {
+		return code.NewErrorf(code.ErrForbidden, "fault injection is not available in env %q", i.env)
+	}
+	for {
+		old := i.settings.Load()
+		s := &settings{enabled: old.enabled, rules: make(map[string][]config.FaultRuleConfig, len(kinds))}
+		for k, v := range old.rules {
+			s.rules[k] = v
+		}
+		s.rules[kind] = append([]config.FaultRuleConfig(nil), rules...)
+		if i.settings.CompareAndSwap(old, s) {
+			return nil
+		}
+	}
+}
+
+// Status is the state of an Injector.
+type Status struct {
+	Enabled bool `json:"enabled"`
+	// Available is false in prod, where injection is refused.
+	Available bool                     `json:"available"`
+	Env       string                   `json:"env"`
+	HTTP      []config.FaultRuleConfig `json:"http"`
+	Cache     []config.FaultRuleConfig `json:"cache"`
+	Database  []config.FaultRuleConfig `json:"database"`
+	// Injected counts the injected faults by kind.
+	Injected map[string]uint64 `json:"injected"`
+}
+
+// Status returns the state of i.
+func (i *Injector) Status() Status {
+	st := Status{Injected: map[string]uint64{}}
+	if i == nil {
+		return st
+	}
+	s := i.settings.Load()
+	st.Enabled, st.Available, st.Env = s.enabled, i.usable(), i.env
+	st.HTTP, st.Cache, st.Database = s.rules[KindHTTP], s.rules[KindCache], s.rules[KindDatabase]
+	for n, kind := range kinds {
+		st.Injected[kind] = i.injected[n].Load()
+	}
+	return st
+}
+
+// fault is the outcome of a draw.
+type fault struct {
+	latency time.Duration
+	failure string // FaultError, FaultReset or empty
+	status  int
+}
+
+// draw picks the faults of the first rule of kind matching target.
+func (i *Injector) draw(kind, target string) (f fault, ok bool) {
+	if !i.Active() {
+		return f, false
+	}
+	for _, r := range i.settings.Load().rules[kind] {
+		if r.Target != "" && r.Target != target {
+			continue
+		}
+		if r.LatencyRate > 0 && rand.Float64() < r.LatencyRate {
+			f.latency = r.Latency
+		}
+		switch p := rand.Float64(); {
+		case p < r.ResetRate:
+			f.failure = FaultReset
+		case p < r.ResetRate+r.ErrorRate:
+			f.failure = FaultError
+		}
+		f.status = r.Status
+		return f, f.latency > 0 || f.failure != ""
+	}
+	return f, false
+}
+
+// apply logs and traces f, then waits out its latency. It returns the
+// cancellation of ctx if ctx is done first.
+func (i *Injector) apply(ctx context.Context, kind, target string, f fault) error {
+	for n, k := range kinds {
+		if k == kind {
+			i.injected[n].Add(1)
+		}
+	}
+	types := make([]string, 0, 2)
+	if f.latency > 0 {
+		types = append(types, FaultLatency)
+	}
+	if f.failure != "" {
+		types = append(types, f.failure)
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Bool("fault_injected", true),
+		attribute.String("fault.kind", kind),
+		attribute.StringSlice("fault.type", types),
+	)
+	log.WarnCtx(ctx, "Fault injected",
+		slog.Bool("fault_injected", true),
+		slog.String("fault.kind", kind),
+		slog.String("fault.target", target),
+		slog.Any("fault.type", types),
+		slog.Duration("fault.latency", f.latency),
+	)
+
+	if f.latency <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(f.latency)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// inject draws and applies the faults of an operation of kind and returns
+// the error it fails with, if any.
+func (i *Injector) inject(ctx context.Context, kind, op string) error {
+	f, ok := i.draw(kind, op)
+	if !ok {
+		return nil
+	}
+	if err := i.apply(ctx, kind, op, f); err != nil {
+		return err
+	}
+	if f.failure != "" {
+		return fmt.Errorf("%s %s: %w", kind, op, ErrInjected)
+	}
+	return nil
+}
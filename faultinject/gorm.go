@@ -0,0 +1,70 @@
+package faultinject
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// plugin is the GORM plugin of an Injector.
+type plugin struct {
+	injector *Injector
+}
+
+// Plugin returns a GORM plugin injecting the faults of the database rules,
+// matched by operation, before each statement: latency, and errors
+// wrapping ErrInjected that fail the statement.
+//
+//	err := gdb.Use(faults.Plugin())
+func (i *Injector) Plugin() gorm.Plugin {
+	if !i.usable() {
+		return noopPlugin{}
+	}
+	return plugin{injector: i}
+}
+
+// Name implements gorm.Plugin.
+func (plugin) Name() string {
+	return "go-kit:fault_inject"
+}
+
+// Initialize implements gorm.Plugin.
+func (p plugin) Initialize(gdb *gorm.DB) error {
+	cb := gdb.Callback()
+	for _, op := range []struct {
+		name string
+		reg  interface {
+			Register(name string, fn func(*gorm.DB)) error
+		}
+	}{
+		{"create", cb.Create().Before("*")},
+		{"query", cb.Query().Before("*")},
+		{"update", cb.Update().Before("*")},
+		{"delete", cb.Delete().Before("*")},
+		{"raw", cb.Raw().Before("*")},
+		{"row", cb.Row().Before("*")},
+	} {
+		if err := op.reg.Register("go-kit:fault_inject_"+op.name, p.callback(op.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p plugin) callback(op string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if err := p.injector.inject(ctx, KindDatabase, op); err != nil {
+			_ = db.AddError(err)
+		}
+	}
+}
+
+// noopPlugin is the Plugin of an Injector that never injects.
+type noopPlugin struct{}
+
+func (noopPlugin) Name() string              { return "go-kit:fault_inject" }
+func (noopPlugin) Initialize(*gorm.DB) error { return nil }
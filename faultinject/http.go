@@ -0,0 +1,60 @@
+package faultinject
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware returns a middleware injecting the faults of the HTTP rules,
+// matched by route template (c.Path()), before the handler runs: latency,
+// errors rendered as code.ErrInternalServer or code.ErrServiceUnavailable,
+// and connection resets, which close the connection without a response.
+// Requests that matched no route are left alone.
+func (i *Injector) Middleware() echo.MiddlewareFunc {
+	if !i.usable() {
+		return func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			route := c.Path()
+			if route == "" {
+				return next(c)
+			}
+			f, ok := i.draw(KindHTTP, route)
+			if !ok {
+				return next(c)
+			}
+			ctx := c.Request().Context()
+			if err := i.apply(ctx, KindHTTP, route, f); err != nil {
+				return err
+			}
+			switch f.failure {
+			case FaultReset:
+				return reset(c)
+			case FaultError:
+				if f.status == http.StatusInternalServerError {
+					return code.NewErrorf(code.ErrInternalServer, "fault injected")
+				}
+				return code.NewErrorf(code.ErrServiceUnavailable, "fault injected")
+			}
+			return next(c)
+		}
+	}
+}
+
+// reset closes the connection of c without a response, as a RST for TCP
+// connections. Connections that cannot be hijacked, such as HTTP/2
+// streams, get a code.ErrServiceUnavailable instead.
+func reset(c echo.Context) error {
+	conn, _, err := http.NewResponseController(c.Response().Writer).Hijack()
+	if err != nil {
+		return code.NewErrorf(code.ErrServiceUnavailable, "fault injected")
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.SetLinger(0)
+	}
+	return conn.Close()
+}
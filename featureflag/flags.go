@@ -0,0 +1,185 @@
+// Package featureflag evaluates feature flags declared in configuration,
+// with optional instance-wide overrides stored in Redis.
+//
+//	flags := featureflag.New(cfg.Features, featureflag.Options{
+//	    Overrides: featureflag.NewRedisOverrides(rdb, ""),
+//	})
+//	go featureflag.Watch(ctx, flags, store, func(c config.Config) map[string]config.FeatureFlag {
+//	    return c.Features
+//	})
+//
+//	if flags.Enabled(ctx, "new_checkout") { ... }
+package featureflag
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/utils"
+)
+
+// OverrideStore stores flag overrides shared by all instances.
+type OverrideStore interface {
+	// Get returns the override for name, or nil if there is none.
+	Get(ctx context.Context, name string) (*bool, error)
+	// All returns every override.
+	All(ctx context.Context) (map[string]bool, error)
+	// Set forces name on or off.
+	Set(ctx context.Context, name string, enabled bool) error
+	// Clear removes the override for name.
+	Clear(ctx context.Context, name string) error
+}
+
+// Options configures Flags.
+type Options struct {
+	// Overrides is checked before the configured flags (optional).
+	Overrides OverrideStore
+	// OverrideTTL is how long override lookups are cached locally; default 5s.
+	OverrideTTL time.Duration
+	// Clock is the time source; default utils.RealClock.
+	Clock utils.Clock
+}
+
+type cachedOverride struct {
+	value   *bool
+	expires time.Time
+}
+
+// Flags evaluates feature flags. It is safe for concurrent use.
+type Flags struct {
+	flags atomic.Value // map[string]config.FeatureFlag
+	opts  Options
+
+	mu    sync.Mutex
+	cache map[string]cachedOverride
+}
+
+// New creates Flags from the configured flags.
+func New(flags map[string]config.FeatureFlag, opts Options) *Flags {
+	if opts.OverrideTTL <= 0 {
+		opts.OverrideTTL = 5 * time.Second
+	}
+	if opts.Clock == nil {
+		opts.Clock = utils.RealClock{}
+	}
+	f := &Flags{opts: opts, cache: make(map[string]cachedOverride)}
+	f.Set(flags)
+	return f
+}
+
+// Set replaces the configured flags.
+func (f *Flags) Set(flags map[string]config.FeatureFlag) {
+	if flags == nil {
+		flags = map[string]config.FeatureFlag{}
+	}
+	f.flags.Store(flags)
+}
+
+// Config returns the configured flags.
+func (f *Flags) Config() map[string]config.FeatureFlag {
+	return f.flags.Load().(map[string]config.FeatureFlag)
+}
+
+// Watch keeps f in sync with store until ctx is done. get selects the flags
+// from the configuration type.
+func Watch[T any](ctx context.Context, f *Flags, store *config.Store[T], get func(T) map[string]config.FeatureFlag) {
+	events := store.SubscribeEvents()
+	f.Set(get(store.Current()))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			f.Set(get(ev.New))
+		}
+	}
+}
+
+// Enabled reports whether the feature is enabled for the user and tenant in
+// ctx. An override wins over the configuration; unknown flags are disabled.
+func (f *Flags) Enabled(ctx context.Context, name string) bool {
+	if v, ok := f.override(ctx, name); ok {
+		return v
+	}
+	flag, ok := f.Config()[name]
+	if !ok {
+		return false
+	}
+	return Evaluate(name, flag, utils.GetUserID(ctx), utils.GetTenantID(ctx))
+}
+
+// Evaluate applies flag to a user and tenant: allowlists first, then the
+// percentage rollout (bucketed by user, falling back to tenant), then Enabled.
+func Evaluate(name string, flag config.FeatureFlag, userID, tenantID string) bool {
+	if userID != "" && slices.Contains(flag.Users, userID) {
+		return true
+	}
+	if tenantID != "" && slices.Contains(flag.Tenants, tenantID) {
+		return true
+	}
+	if flag.Percentage > 0 {
+		subject := userID
+		if subject == "" {
+			subject = tenantID
+		}
+		if subject == "" {
+			return flag.Percentage >= 100
+		}
+		return Bucket(name, subject) < flag.Percentage
+	}
+	return flag.Enabled
+}
+
+// Bucket maps a subject to a stable bucket in [0, 100) for the flag name.
+// Hashing the name with the subject keeps rollouts of different flags
+// independent.
+func Bucket(name, subject string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{':'})
+	h.Write([]byte(subject))
+	return int(h.Sum32() % 100)
+}
+
+// override returns the cached override for name. Lookup errors fall back to
+// the configuration.
+func (f *Flags) override(ctx context.Context, name string) (value bool, ok bool) {
+	if f.opts.Overrides == nil {
+		return false, false
+	}
+	now := f.opts.Clock.Now()
+
+	f.mu.Lock()
+	c, hit := f.cache[name]
+	f.mu.Unlock()
+
+	if !hit || now.After(c.expires) {
+		v, err := f.opts.Overrides.Get(ctx, name)
+		if err != nil {
+			slog.Warn("Feature flag override lookup failed", slog.String("flag", name), slog.String("error", err.Error()))
+			return false, false
+		}
+		c = cachedOverride{value: v, expires: now.Add(f.opts.OverrideTTL)}
+		f.mu.Lock()
+		f.cache[name] = c
+		f.mu.Unlock()
+	}
+
+	if c.value == nil {
+		return false, false
+	}
+	return *c.value, true
+}
+
+// invalidate drops the local override cache for name.
+func (f *Flags) invalidate(name string) {
+	f.mu.Lock()
+	delete(f.cache, name)
+	f.mu.Unlock()
+}
@@ -0,0 +1,103 @@
+package featureflag
+
+import (
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/resp"
+	"github.com/labstack/echo/v4"
+)
+
+// FlagState describes a flag for the admin API.
+type FlagState struct {
+	Name     string              `json:"name"`
+	Config   *config.FeatureFlag `json:"config,omitempty"`
+	Override *bool               `json:"override,omitempty"`
+}
+
+// overrideRequest is the body of PUT /features/:name.
+type overrideRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// RequireFeature returns a middleware that responds 404 when the feature is
+// disabled for the request, hiding unreleased routes.
+func RequireFeature(f *Flags, name string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !f.Enabled(c.Request().Context(), name) {
+				return code.NewErrorf(code.ErrNotFound, "feature %s is not available", name)
+			}
+			return next(c)
+		}
+	}
+}
+
+// RegisterAdmin registers the override admin API on g. It must only be
+// mounted on an authenticated admin group:
+//
+//	GET    /features        list flags with their overrides
+//	PUT    /features/:name  {"enabled": true} forces a flag on or off
+//	DELETE /features/:name  removes the override
+func RegisterAdmin(g *echo.Group, f *Flags) {
+	g.GET("/features", f.listHandler)
+	g.PUT("/features/:name", f.setHandler)
+	g.DELETE("/features/:name", f.clearHandler)
+}
+
+func (f *Flags) listHandler(c echo.Context) error {
+	overrides := map[string]bool{}
+	if f.opts.Overrides != nil {
+		var err error
+		if overrides, err = f.opts.Overrides.All(c.Request().Context()); err != nil {
+			return err
+		}
+	}
+
+	cfg := f.Config()
+	states := make([]FlagState, 0, len(cfg)+len(overrides))
+	for name, flag := range cfg {
+		st := FlagState{Name: name, Config: &flag}
+		if v, ok := overrides[name]; ok {
+			st.Override = &v
+		}
+		states = append(states, st)
+	}
+	for name, v := range overrides {
+		if _, ok := cfg[name]; !ok {
+			states = append(states, FlagState{Name: name, Override: &v})
+		}
+	}
+	return resp.ListDataResponse(c, states, int64(len(states)))
+}
+
+func (f *Flags) setHandler(c echo.Context) error {
+	if f.opts.Overrides == nil {
+		return code.NewBadRequestError("feature overrides are not configured")
+	}
+	var req overrideRequest
+	if err := c.Bind(&req); err != nil {
+		return code.WrapError(err, code.ErrBind, "invalid request body")
+	}
+	if req.Enabled == nil {
+		return code.NewValidationError("enabled", "is required")
+	}
+
+	name := c.Param("name")
+	if err := f.opts.Overrides.Set(c.Request().Context(), name, *req.Enabled); err != nil {
+		return err
+	}
+	f.invalidate(name)
+	return resp.OperateSuccess(c)
+}
+
+func (f *Flags) clearHandler(c echo.Context) error {
+	if f.opts.Overrides == nil {
+		return code.NewBadRequestError("feature overrides are not configured")
+	}
+	name := c.Param("name")
+	if err := f.opts.Overrides.Clear(c.Request().Context(), name); err != nil {
+		return err
+	}
+	f.invalidate(name)
+	return resp.OperateSuccess(c)
+}
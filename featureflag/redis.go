@@ -0,0 +1,65 @@
+package featureflag
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisOverrides stores overrides in a Redis hash.
+type RedisOverrides struct {
+	client *redis.Client
+	key    string
+}
+
+// NewRedisOverrides creates a Redis override store using the hash key
+// (default "features:overrides").
+func NewRedisOverrides(client *redis.Client, key string) *RedisOverrides {
+	if key == "" {
+		key = "features:overrides"
+	}
+	return &RedisOverrides{client: client, key: key}
+}
+
+// Get implements OverrideStore.
+func (r *RedisOverrides) Get(ctx context.Context, name string) (*bool, error) {
+	s, err := r.client.HGet(ctx, r.key, name).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, code.WrapRedisError(err, "hget feature override")
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return nil, nil
+	}
+	return &v, nil
+}
+
+// All implements OverrideStore.
+func (r *RedisOverrides) All(ctx context.Context) (map[string]bool, error) {
+	m, err := r.client.HGetAll(ctx, r.key).Result()
+	if err != nil {
+		return nil, code.WrapRedisError(err, "hgetall feature overrides")
+	}
+	out := make(map[string]bool, len(m))
+	for name, s := range m {
+		if v, err := strconv.ParseBool(s); err == nil {
+			out[name] = v
+		}
+	}
+	return out, nil
+}
+
+// Set implements OverrideStore.
+func (r *RedisOverrides) Set(ctx context.Context, name string, enabled bool) error {
+	return code.WrapRedisError(r.client.HSet(ctx, r.key, name, strconv.FormatBool(enabled)).Err(), "hset feature override")
+}
+
+// Clear implements OverrideStore.
+func (r *RedisOverrides) Clear(ctx context.Context, name string) error {
+	return code.WrapRedisError(r.client.HDel(ctx, r.key, name).Err(), "hdel feature override")
+}
@@ -0,0 +1,88 @@
+package grpckit
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc/metadata"
+)
+
+// JWTConfig holds JWT interceptor configuration.
+type JWTConfig struct {
+	// SigningKey is the secret key for JWT validation (HS256, as
+	// middleware.JWT).
+	SigningKey []byte
+	// SkipMethods are full method patterns that skip JWT validation, as
+	// matched by path.Match: "/grpc.health.v1.Health/*" skips a service.
+	SkipMethods []string
+	// ClaimsFunc creates a new claims instance; default jwt.MapClaims.
+	ClaimsFunc func() jwt.Claims
+	// Keyfunc overrides SigningKey, e.g. the Keyfunc of a
+	// middleware.JWTKeyFile for a secret reloaded from a file.
+	Keyfunc jwt.Keyfunc
+}
+
+// tokenKey is the context key of the validated token.
+type tokenKey struct{}
+
+// JWT returns an interceptor validating the bearer token of the
+// "authorization" metadata of calls. Calls without a valid token fail
+// with code.ErrSignatureInvalid (Unauthenticated), as over HTTP. The token
+// is available to handlers with TokenFromContext, and its subject with
+// utils.GetUserID.
+func JWT(cfg JWTConfig) Interceptor {
+	return func(ctx context.Context, method string, next func(context.Context) error) error {
+		for _, pattern := range cfg.SkipMethods {
+			if ok, _ := path.Match(pattern, method); ok {
+				return next(ctx)
+			}
+		}
+		token, err := parseToken(ctx, cfg)
+		if err != nil {
+			return errors.WrapCode(err, code.ErrSignatureInvalid, "JWT signature invalid")
+		}
+		ctx = context.WithValue(ctx, tokenKey{}, token)
+		if sub, err := token.Claims.GetSubject(); err == nil && sub != "" {
+			ctx = context.WithValue(ctx, utils.KeyUserID, sub)
+		}
+		return next(ctx)
+	}
+}
+
+// parseToken returns the validated bearer token of the call of ctx.
+func parseToken(ctx context.Context, cfg JWTConfig) (*jwt.Token, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	auth := md.Get("authorization")
+	if len(auth) == 0 {
+		return nil, errors.New("missing authorization metadata")
+	}
+	raw, ok := strings.CutPrefix(auth[0], "Bearer ")
+	if !ok {
+		return nil, errors.New("authorization metadata is not a bearer token")
+	}
+	var claims jwt.Claims = jwt.MapClaims{}
+	if cfg.ClaimsFunc != nil {
+		claims = cfg.ClaimsFunc()
+	}
+	keyfunc := cfg.Keyfunc
+	if keyfunc == nil {
+		keyfunc = func(*jwt.Token) (any, error) {
+			if len(cfg.SigningKey) == 0 {
+				return nil, errors.New("no jwt signing key configured")
+			}
+			return cfg.SigningKey, nil
+		}
+	}
+	return jwt.ParseWithClaims(raw, claims, keyfunc, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+}
+
+// TokenFromContext returns the token validated by JWT.
+func TokenFromContext(ctx context.Context) (*jwt.Token, bool) {
+	token, ok := ctx.Value(tokenKey{}).(*jwt.Token)
+	return token, ok
+}
@@ -0,0 +1,73 @@
+// Package grpckit brings the cross-cutting behavior of the Echo middleware
+// to gRPC servers: panic recovery, coded errors as gRPC statuses, access
+// logs, metrics, request ID and trace propagation, and JWT validation.
+//
+//	srv := grpc.NewServer(grpckit.Setup(grpckit.SetupDeps{
+//	    Config:  cfg,
+//	    Logger:  logger,
+//	    Metrics: metrics.NewGRPCMetrics("app", nil),
+//	})...)
+//
+// Each interceptor serves unary and streaming RPCs alike:
+//
+//	grpc.NewServer(
+//	    grpc.ChainUnaryInterceptor(grpckit.RequestID().Unary(), grpckit.Errors().Unary()),
+//	    grpc.ChainStreamInterceptor(grpckit.RequestID().Stream(), grpckit.Errors().Stream()),
+//	)
+package grpckit
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// Interceptor is the logic of an interceptor shared by unary and
+// streaming RPCs: it calls next, with a derived context if needed, and
+// returns its error, possibly replaced.
+type Interceptor func(ctx context.Context, method string, next func(ctx context.Context) error) error
+
+// Unary returns f as a unary server interceptor.
+func (f Interceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var resp any
+		err := f(ctx, info.FullMethod, func(ctx context.Context) error {
+			var err error
+			resp, err = handler(ctx, req)
+			return err
+		})
+		return resp, err
+	}
+}
+
+// Stream returns f as a stream server interceptor.
+func (f Interceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return f(ss.Context(), info.FullMethod, func(ctx context.Context) error {
+			return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		})
+	}
+}
+
+// ServerOptions returns the options installing interceptors, in order,
+// for unary and streaming RPCs.
+func ServerOptions(interceptors ...Interceptor) []grpc.ServerOption {
+	unary := make([]grpc.UnaryServerInterceptor, len(interceptors))
+	stream := make([]grpc.StreamServerInterceptor, len(interceptors))
+	for i, f := range interceptors {
+		unary[i] = f.Unary()
+		stream[i] = f.Stream()
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+}
+
+// serverStream is a ServerStream with the context of an interceptor.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
@@ -0,0 +1,126 @@
+package grpckit
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/metrics"
+	"github.com/NSObjects/go-kit/utils"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDMetadata is the metadata key of the request ID, the gRPC
+// counterpart of the X-Request-ID header.
+const RequestIDMetadata = "x-request-id"
+
+// Recovery returns an interceptor converting handler panics into coded
+// errors with errors.FromPanic, logged with their stack. Errors turns
+// them into Internal statuses without the panic value.
+func Recovery() Interceptor {
+	return func(ctx context.Context, method string, next func(context.Context) error) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = errors.FromPanic(r)
+				slog.Error("Panic recovered",
+					slog.String("method", method),
+					log.Err(err),
+				)
+			}
+		}()
+		return next(ctx)
+	}
+}
+
+// RequestID returns an interceptor reading the request ID and trace
+// context of calls from their metadata into the context under the utils
+// keys, so utils.GetRequestID and the OpenTelemetry span context work as
+// in HTTP handlers. Calls without a request ID get a ULID. The request ID
+// is sent back in the response header metadata and attached to the logger
+// of the context (see log.FromContext).
+func RequestID() Interceptor {
+	return func(ctx context.Context, method string, next func(context.Context) error) error {
+		md, _ := metadata.FromIncomingContext(ctx)
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+		rid := ""
+		if v := md.Get(RequestIDMetadata); len(v) > 0 {
+			rid = v[0]
+		}
+		if rid == "" {
+			rid = utils.NewULID()
+		}
+		ctx = context.WithValue(ctx, utils.KeyRequestID, rid)
+		ctx = log.NewContext(ctx, log.FromContext(ctx).With(slog.String("request_id", rid)))
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadata, rid))
+		return next(ctx)
+	}
+}
+
+// AccessLog returns an interceptor logging every call to logger, or the
+// global logger when nil, like middleware.AccessLogSampled: only a
+// fraction rate (0..1) of the successful calls is logged, failed calls
+// always are.
+func AccessLog(logger log.Logger, rate float64) Interceptor {
+	return func(ctx context.Context, method string, next func(context.Context) error) error {
+		start := time.Now()
+
+		err := next(ctx)
+
+		code := status.Code(err)
+		if code == codes.OK && rate < 1 && rand.Float64() >= rate {
+			return err
+		}
+		l := logger
+		if l == nil {
+			l = log.FromContext(context.Background())
+		}
+		l.Info("RPC",
+			slog.String("request_id", utils.GetRequestID(ctx)),
+			slog.String("method", method),
+			slog.String("code", code.String()),
+			slog.Duration("latency", time.Since(start)),
+		)
+		return err
+	}
+}
+
+// Metrics returns an interceptor recording every call in m by method and
+// gRPC status code.
+func Metrics(m *metrics.GRPCMetrics) Interceptor {
+	return func(ctx context.Context, method string, next func(context.Context) error) error {
+		start := time.Now()
+		err := next(ctx)
+		m.Observe(method, status.Code(err).String(), time.Since(start))
+		return err
+	}
+}
+
+// metadataCarrier adapts incoming metadata to the OpenTelemetry
+// propagators; metadata keys are lower case.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,53 @@
+package grpckit
+
+import (
+	kit "github.com/NSObjects/go-kit"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/metrics"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+)
+
+// SetupDeps are the dependencies used by Setup. Nil dependencies disable
+// the interceptors that need them.
+type SetupDeps struct {
+	Config  config.Config
+	Logger  log.Logger
+	Metrics *metrics.GRPCMetrics
+	// AuthSkipMethods are the methods callable without a token when JWT is
+	// enabled (see JWTConfig.SkipMethods).
+	AuthSkipMethods []string
+	// JWTKeyfunc overrides cfg.JWT.Secret (see JWTConfig.Keyfunc); set it
+	// to the Keyfunc of the middleware.JWTKeyFile of cfg.JWT.SecretFile.
+	JWTKeyfunc jwt.Keyfunc
+}
+
+// Setup returns the server options installing the canonical interceptor
+// chain, driven by the config sections like middleware.Setup:
+//
+//	RequestID → AccessLog → Metrics → Errors → Recovery → JWT
+//
+// Recovery sits inside Errors, so panics are logged, counted and answered
+// as Internal statuses like any server error. Access-log sampling follows
+// the environment profile (see kit.Resolve); JWT is installed when
+// cfg.JWT is enabled.
+//
+//	srv := grpc.NewServer(grpckit.Setup(deps)...)
+func Setup(deps SetupDeps) []grpc.ServerOption {
+	cfg := deps.Config
+	profile := kit.Resolve(cfg)
+
+	chain := []Interceptor{
+		RequestID(),
+		AccessLog(deps.Logger, profile.AccessLogSampleRate),
+	}
+	if deps.Metrics != nil {
+		chain = append(chain, Metrics(deps.Metrics))
+	}
+	chain = append(chain, Errors(), Recovery())
+	if cfg.JWT.Enabled {
+		chain = append(chain, JWT(JWTConfig{SigningKey: []byte(cfg.JWT.Secret), SkipMethods: deps.AuthSkipMethods, Keyfunc: deps.JWTKeyfunc}))
+	}
+	return ServerOptions(chain...)
+}
@@ -0,0 +1,142 @@
+package grpckit
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/utils"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDomain is the domain of the ErrorInfo detail of statuses built by
+// Status, whose reason is the error code.
+const ErrorDomain = "go-kit"
+
+// Status returns the gRPC status of err, as the error handler renders
+// coded errors over HTTP: the gRPC code follows the HTTP status of the
+// error code (see GRPCCode), the message is the registered message of the
+// code in the locale of ctx, or the user message of client errors, and an
+// ErrorInfo detail carries the error code and request ID. Context errors
+// are DeadlineExceeded and Canceled; panic values never reach the client;
+// statuses are returned as is.
+func Status(ctx context.Context, err error) *status.Status {
+	if err == nil {
+		return nil
+	}
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+	err = errors.FromContextError(err)
+	errorCode := errors.GetCode(err)
+	if errorCode == 0 {
+		errorCode = code.ErrInternalServer
+	}
+	httpStatus := errors.HTTPStatus(errorCode)
+
+	message := internalMessage(ctx)
+	if _, ok := errors.Lookup(errorCode); ok {
+		message = errors.MessageFor(ctx, errorCode)
+	}
+	if msg, ok := errors.UserMessage(err); ok && httpStatus < http.StatusInternalServerError {
+		message = msg
+	}
+	// Never leak panic values to clients.
+	if errors.IsPanic(err) {
+		message = internalMessage(ctx)
+	}
+
+	st := status.New(GRPCCode(httpStatus), message)
+	info := &errdetails.ErrorInfo{Reason: strconv.Itoa(errorCode), Domain: ErrorDomain}
+	if rid := utils.GetRequestID(ctx); rid != "" {
+		info.Metadata = map[string]string{"request_id": rid}
+	}
+	if withDetails, err := st.WithDetails(info); err == nil {
+		st = withDetails
+	}
+	return st
+}
+
+// internalMessage is the message of server errors without a registered
+// message of their own.
+func internalMessage(ctx context.Context) string {
+	if msg := errors.MessageFor(ctx, code.ErrInternalServer); msg != "" {
+		return msg
+	}
+	return "Internal server error"
+}
+
+// GRPCCode returns the gRPC code of an HTTP status.
+func GRPCCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusOK:
+		return codes.OK
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound, http.StatusGone:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusPreconditionFailed, http.StatusPreconditionRequired:
+		return codes.FailedPrecondition
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case 499:
+		return codes.Canceled
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	if httpStatus >= http.StatusInternalServerError {
+		return codes.Internal
+	}
+	return codes.FailedPrecondition
+}
+
+// Errors returns an interceptor converting the errors of handlers into
+// gRPC statuses with Status. Server errors are logged with their stack,
+// client errors as warnings, like the HTTP error handler does.
+func Errors() Interceptor {
+	return func(ctx context.Context, method string, next func(context.Context) error) error {
+		err := next(ctx)
+		if err == nil {
+			return nil
+		}
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		st := Status(ctx, err)
+		errorCode := errors.GetCode(errors.FromContextError(err))
+		if errorCode == 0 {
+			errorCode = code.ErrInternalServer
+		}
+		logFields := []any{
+			slog.String("request_id", utils.GetRequestID(ctx)),
+			slog.String("method", method),
+			slog.Int("code", errorCode),
+			slog.String("message", st.Message()),
+		}
+		switch {
+		case errorCode == code.ErrClientClosedRequest:
+			slog.Debug("Client closed request", append(logFields, log.Err(err))...)
+		case code.IsServerError(errorCode):
+			slog.Error("Server error", append(logFields, log.Err(err))...)
+		default:
+			slog.Warn("Client error", append(logFields, slog.String("error", err.Error()))...)
+		}
+		return st.Err()
+	}
+}
@@ -4,6 +4,7 @@ package health
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,12 +17,15 @@ const (
 	StatusDegraded  Status = "degraded"
 )
 
-// Check represents a single health check result.
+// Check represents a single health check result. LastChecked and
+// ConsecutiveFailures are filled in by the Registry.
 type Check struct {
-	Name    string        `json:"name"`
-	Status  Status        `json:"status"`
-	Message string        `json:"message,omitempty"`
-	Latency time.Duration `json:"latency"`
+	Name                string        `json:"name"`
+	Status              Status        `json:"status"`
+	Message             string        `json:"message,omitempty"`
+	Latency             time.Duration `json:"latency"`
+	LastChecked         time.Time     `json:"last_checked,omitzero"`
+	ConsecutiveFailures int           `json:"consecutive_failures"`
 }
 
 // Checker is the interface for health checks.
@@ -30,15 +34,22 @@ type Checker interface {
 	Check(ctx context.Context) Check
 }
 
-// Registry holds all registered health checkers.
+// Registry holds all registered health checkers and the readiness state.
 type Registry struct {
 	mu       sync.RWMutex
 	checkers []Checker
+
+	resultsMu sync.RWMutex
+	results   map[string]Check // latest result per checker name
+
+	ready    atomic.Bool
+	started  atomic.Bool
+	draining atomic.Bool
 }
 
 // NewRegistry creates a new health check registry.
 func NewRegistry() *Registry {
-	return &Registry{}
+	return &Registry{results: make(map[string]Check)}
 }
 
 // Register adds a health checker to the registry.
@@ -55,21 +66,134 @@ func (r *Registry) CheckAll(ctx context.Context) []Check {
 
 	results := make([]Check, 0, len(r.checkers))
 	for _, checker := range r.checkers {
-		results = append(results, checker.Check(ctx))
+		results = append(results, r.record(checker.Check(ctx)))
 	}
 	return results
 }
 
-// OverallStatus returns the overall health status.
-func (r *Registry) OverallStatus(ctx context.Context) Status {
-	checks := r.CheckAll(ctx)
-	for _, check := range checks {
-		if check.Status == StatusUnhealthy {
-			return StatusUnhealthy
+// Cached returns the latest result of every check, as stored by CheckAll
+// or Run. Checks that never ran are run now, so the first call before Run
+// has refreshed anything is a live check.
+func (r *Registry) Cached(ctx context.Context) []Check {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	results := make([]Check, 0, len(r.checkers))
+	for _, checker := range r.checkers {
+		r.resultsMu.RLock()
+		check, ok := r.results[checker.Name()]
+		r.resultsMu.RUnlock()
+		if !ok {
+			check = r.record(checker.Check(ctx))
 		}
-		if check.Status == StatusDegraded {
-			return StatusDegraded
+		results = append(results, check)
+	}
+	return results
+}
+
+// Run refreshes the cached results every interval until ctx is canceled,
+// so probes served from the cache (see HandlerOptions) do not hit the
+// dependencies themselves:
+//
+//	go registry.Run(ctx, 10*time.Second)
+func (r *Registry) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.CheckAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 		}
 	}
-	return StatusHealthy
+}
+
+// record stamps check with the time and the number of consecutive
+// unhealthy results of its checker, and stores it as the latest result.
+func (r *Registry) record(check Check) Check {
+	r.resultsMu.Lock()
+	defer r.resultsMu.Unlock()
+
+	check.LastChecked = time.Now()
+	if check.Status == StatusUnhealthy {
+		check.ConsecutiveFailures = r.results[check.Name].ConsecutiveFailures + 1
+	}
+	r.results[check.Name] = check
+	return check
+}
+
+// OverallStatus returns the overall health status.
+func (r *Registry) OverallStatus(ctx context.Context) Status {
+	return overall(r.CheckAll(ctx))
+}
+
+// Readiness messages reported by the startup checker.
+const (
+	MessageStarting = "starting"
+	MessageDraining = "draining"
+)
+
+// SetReady marks the application as ready (or not) to receive traffic.
+// Call SetReady(true) once startup work such as migrations and cache warmup
+// has finished.
+func (r *Registry) SetReady(ready bool) {
+	r.ready.Store(ready)
+	if ready {
+		r.started.Store(true)
+	}
+}
+
+// BeginShutdown marks the application as draining: readiness turns
+// unhealthy so load balancers stop routing traffic, while liveness stays
+// healthy. It is irreversible.
+func (r *Registry) BeginShutdown() {
+	r.draining.Store(true)
+}
+
+// StartupChecker returns the built-in readiness checker. It reports
+// unhealthy ("starting") until SetReady(true) and unhealthy ("draining")
+// after BeginShutdown.
+func (r *Registry) StartupChecker() Checker {
+	return startupChecker{r: r}
+}
+
+type startupChecker struct {
+	r *Registry
+}
+
+func (c startupChecker) Name() string { return "startup" }
+
+func (c startupChecker) Check(ctx context.Context) Check {
+	check := Check{Name: c.Name(), Status: StatusHealthy}
+	switch {
+	case c.r.draining.Load():
+		check.Status = StatusUnhealthy
+		check.Message = MessageDraining
+	case !c.r.ready.Load():
+		check.Status = StatusUnhealthy
+		check.Message = MessageStarting
+	}
+	return check
+}
+
+// Started reports whether SetReady(true) was ever called. Unlike readiness
+// it stays true while draining, which is what a startup probe expects.
+func (r *Registry) Started() bool {
+	return r.started.Load()
+}
+
+// CheckReadiness runs the startup checker followed by all registered checks.
+func (r *Registry) CheckReadiness(ctx context.Context) []Check {
+	return append([]Check{r.startupCheck(ctx)}, r.CheckAll(ctx)...)
+}
+
+// CachedReadiness is CheckReadiness with the registered checks served from
+// the cache (see Cached). The startup checker is always evaluated live.
+func (r *Registry) CachedReadiness(ctx context.Context) []Check {
+	return append([]Check{r.startupCheck(ctx)}, r.Cached(ctx)...)
+}
+
+func (r *Registry) startupCheck(ctx context.Context) Check {
+	return r.record(r.StartupChecker().Check(ctx))
 }
@@ -0,0 +1,171 @@
+package health
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/resp"
+	"github.com/labstack/echo/v4"
+)
+
+// Report is the body returned by Handler.
+type Report struct {
+	Status Status  `json:"status"`
+	Checks []Check `json:"checks"`
+}
+
+// Default names of the parameter forcing live checks.
+const (
+	DefaultDeepParam  = "deep"
+	DefaultDeepHeader = "X-Health-Deep"
+)
+
+// HandlerOptions configures Handler and ReadinessHandler.
+type HandlerOptions struct {
+	// Cached serves the results refreshed by Registry.Run unless the caller
+	// asks for live checks with ?deep=true or the DeepHeader header. Use it
+	// when endpoints are polled by callers, such as external uptime
+	// checkers, that should not trigger dependency checks.
+	Cached bool
+	// DeepParam is the query parameter forcing live checks; default
+	// DefaultDeepParam.
+	DeepParam string
+	// DeepHeader is the header forcing live checks; default
+	// DefaultDeepHeader.
+	DeepHeader string
+}
+
+func (o HandlerOptions) withDefaults() HandlerOptions {
+	if o.DeepParam == "" {
+		o.DeepParam = DefaultDeepParam
+	}
+	if o.DeepHeader == "" {
+		o.DeepHeader = DefaultDeepHeader
+	}
+	return o
+}
+
+// deep reports whether c asks for live checks.
+func (o HandlerOptions) deep(c echo.Context) bool {
+	if !o.Cached {
+		return true
+	}
+	v := c.QueryParam(o.DeepParam)
+	if v == "" {
+		v = c.Request().Header.Get(o.DeepHeader)
+	}
+	deep, _ := strconv.ParseBool(v)
+	return deep
+}
+
+// Handler returns an endpoint reporting all checks. It responds 200 when the
+// overall status is healthy or degraded and 503 when unhealthy.
+func Handler(r *Registry, opts ...HandlerOptions) echo.HandlerFunc {
+	o := handlerOptions(opts)
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		if o.deep(c) {
+			return writeReport(c, r.CheckAll(ctx), "")
+		}
+		return writeReport(c, r.Cached(ctx), "")
+	}
+}
+
+// LivenessHandler returns an endpoint (/livez) reporting that the process is
+// up. It stays healthy while draining so orchestrators do not restart the
+// instance during graceful shutdown.
+func LivenessHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		noStore(c)
+		return resp.SuccessJSON(c, Report{Status: StatusHealthy, Checks: []Check{}})
+	}
+}
+
+// StartupHandler returns an endpoint (/startupz) for Kubernetes startup
+// probes, reflecting only the startup checker: 503 "starting" until
+// SetReady(true), then 200, including while draining, since a startup
+// probe is not expected to fail again.
+func StartupHandler(r *Registry) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		check := Check{Name: "startup", Status: StatusHealthy, LastChecked: time.Now()}
+		if !r.Started() {
+			check.Status = StatusUnhealthy
+			check.Message = MessageStarting
+		}
+		return writeReport(c, []Check{check}, check.Message)
+	}
+}
+
+// ReadinessHandler returns an endpoint (/readyz) reporting whether the
+// instance should receive traffic: the startup checker plus all registered
+// checks. While starting or draining it responds 503 with the message
+// "starting" or "draining".
+//
+// Kubernetes and an external uptime checker can share the endpoint: with
+// HandlerOptions.Cached the checker gets the results of Registry.Run, and
+// ?deep=true forces live checks.
+func ReadinessHandler(r *Registry, opts ...HandlerOptions) echo.HandlerFunc {
+	o := handlerOptions(opts)
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		var checks []Check
+		if o.deep(c) {
+			checks = r.CheckReadiness(ctx)
+		} else {
+			checks = r.CachedReadiness(ctx)
+		}
+		msg := ""
+		if startup := checks[0]; startup.Status == StatusUnhealthy {
+			msg = startup.Message
+		}
+		return writeReport(c, checks, msg)
+	}
+}
+
+func handlerOptions(opts []HandlerOptions) HandlerOptions {
+	var o HandlerOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	return o.withDefaults()
+}
+
+// noStore keeps intermediaries from caching a probe result.
+func noStore(c echo.Context) {
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store, no-cache, max-age=0")
+}
+
+// writeReport responds 200 when the overall status is healthy or degraded
+// and 503 when unhealthy. msg overrides the envelope message of a 503.
+func writeReport(c echo.Context, checks []Check, msg string) error {
+	report := Report{Status: overall(checks), Checks: checks}
+	noStore(c)
+
+	if report.Status == StatusUnhealthy {
+		if msg == "" {
+			msg = string(report.Status)
+		}
+		return resp.JSON(c, http.StatusServiceUnavailable, resp.Response{
+			Code: code.ErrInternalServer,
+			Msg:  msg,
+			Data: report,
+		})
+	}
+	return resp.SuccessJSON(c, report)
+}
+
+// overall folds check results into a single status.
+func overall(checks []Check) Status {
+	status := StatusHealthy
+	for _, check := range checks {
+		if check.Status == StatusUnhealthy {
+			return StatusUnhealthy
+		}
+		if check.Status == StatusDegraded {
+			status = StatusDegraded
+		}
+	}
+	return status
+}
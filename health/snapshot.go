@@ -0,0 +1,67 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// Snapshot is the materialized result of one run of the checks of a
+// Registry, for consumers without HTTP such as CLIs and init containers.
+type Snapshot struct {
+	Report
+	// Counts is the number of checks per status.
+	Counts map[Status]int `json:"counts"`
+	// TakenAt is when the checks started.
+	TakenAt time.Time `json:"taken_at"`
+	// Duration is how long the checks took.
+	Duration time.Duration `json:"duration"`
+}
+
+// Snapshot runs all registered checks once, live, and returns their
+// results; bound it with a ctx deadline:
+//
+//	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+//	defer cancel()
+//	snap := registry.Snapshot(ctx)
+//	_ = snap.WriteText(os.Stdout)
+//	if snap.Status == health.StatusUnhealthy {
+//	    os.Exit(1)
+//	}
+func (r *Registry) Snapshot(ctx context.Context) Snapshot {
+	start := time.Now()
+	checks := r.CheckAll(ctx)
+	return NewSnapshot(checks, start, time.Since(start))
+}
+
+// NewSnapshot returns the Snapshot of checks taken at start.
+func NewSnapshot(checks []Check, start time.Time, took time.Duration) Snapshot {
+	counts := map[Status]int{StatusHealthy: 0, StatusDegraded: 0, StatusUnhealthy: 0}
+	for _, check := range checks {
+		counts[check.Status]++
+	}
+	return Snapshot{
+		Report:   Report{Status: overall(checks), Checks: checks},
+		Counts:   counts,
+		TakenAt:  start,
+		Duration: took,
+	}
+}
+
+// WriteText writes s for humans: the overall status, then a line per
+// check with its status, latency and message.
+func (s Snapshot) WriteText(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "status: %s (%d healthy, %d degraded, %d unhealthy in %s)\n",
+		s.Status, s.Counts[StatusHealthy], s.Counts[StatusDegraded], s.Counts[StatusUnhealthy],
+		s.Duration.Round(time.Millisecond)); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, check := range s.Checks {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", check.Status, check.Name,
+			check.Latency.Round(time.Microsecond), check.Message)
+	}
+	return tw.Flush()
+}
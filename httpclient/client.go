@@ -0,0 +1,393 @@
+// Package httpclient provides an HTTP client for outbound calls with retries,
+// trace propagation, coded errors and metrics.
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/metrics"
+	"github.com/NSObjects/go-kit/resilience"
+	"github.com/NSObjects/go-kit/resp"
+	"github.com/NSObjects/go-kit/serviceauth"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// maxErrorBody limits how much of an error response body is read.
+const maxErrorBody = 64 << 10
+
+// Client is an HTTP client for calling external services.
+type Client struct {
+	http     *http.Client
+	baseURL  string
+	service  string
+	headers  http.Header
+	signer   *serviceauth.Signer
+	audience string
+	retry    *utils.RetryPolicy
+	metrics  *metrics.HTTPClientMetrics
+
+	breakerCfg *resilience.BreakerConfig
+	breakersMu sync.Mutex
+	breakers   map[string]*resilience.Breaker
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL sets the base URL prepended to relative request paths.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithServiceName sets the service name used in error messages.
+func WithServiceName(name string) Option {
+	return func(c *Client) {
+		c.service = name
+	}
+}
+
+// WithTimeout sets the per-attempt timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.http.Timeout = timeout
+	}
+}
+
+// WithHTTPClient replaces the underlying http.Client.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// WithHeader sets a header sent with every request.
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.headers.Set(key, value)
+	}
+}
+
+// WithServiceToken sends a service token of signer for audience, the name
+// of the called service, with every request (see package serviceauth).
+func WithServiceToken(signer *serviceauth.Signer, audience string) Option {
+	return func(c *Client) {
+		c.signer, c.audience = signer, audience
+	}
+}
+
+// WithTLS sets the TLS configuration of the connections, e.g. a client
+// certificate for mutual TLS (see serviceauth.ClientTLSConfig). It clones
+// the transport of the http.Client, which must be an *http.Transport or
+// nil, so apply it after WithHTTPClient.
+func WithTLS(tc *tls.Config) Option {
+	return func(c *Client) {
+		transport, ok := c.http.Transport.(*http.Transport)
+		if !ok || transport == nil {
+			transport = http.DefaultTransport.(*http.Transport)
+		}
+		transport = transport.Clone()
+		transport.TLSClientConfig = tc
+		hc := *c.http
+		hc.Transport = transport
+		c.http = &hc
+	}
+}
+
+// WithRetry enables retries for idempotent methods on 5xx responses and
+// connection errors.
+func WithRetry(policy utils.RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = &policy
+	}
+}
+
+// WithTracing is kept for compatibility. The request ID and trace context of
+// ctx are always propagated (see utils.PropagationHeaders); without tracing
+// set up the global propagator writes no trace headers.
+//
+// Deprecated: no longer needed.
+func WithTracing(cfg config.OtelConfig) Option {
+	log.DeprecationWarn("httpclient.WithTracing", "httpclient.WithTracing is deprecated and has no effect")
+	return func(c *Client) {}
+}
+
+// WithMetrics records per-host request metrics.
+func WithMetrics(m *metrics.HTTPClientMetrics) Option {
+	return func(c *Client) {
+		c.metrics = m
+	}
+}
+
+// WithBreaker enables a circuit breaker per target host, each created from
+// cfg with the host as its name. 5xx responses and transport errors count as
+// failures; calls rejected by an open circuit fail with code.ErrCircuitOpen
+// and are not retried.
+func WithBreaker(cfg resilience.BreakerConfig) Option {
+	return func(c *Client) {
+		c.breakerCfg = &cfg
+	}
+}
+
+// New creates a new Client.
+func New(opts ...Option) *Client {
+	c := &Client{
+		http:    &http.Client{Timeout: 10 * time.Second},
+		service: "http",
+		headers: make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// statusError reports a retryable HTTP status.
+type statusError struct {
+	status int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.status)
+}
+
+// Do sends req, applying default headers, request ID and trace propagation,
+// metrics and the retry policy. The request body must be replayable
+// (req.GetBody set) for retries to take effect; NewRequest takes care of that.
+func (c *Client) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	req = req.WithContext(ctx)
+	for k, vs := range c.headers {
+		if req.Header.Get(k) == "" {
+			req.Header[k] = vs
+		}
+	}
+	for k, vs := range utils.PropagationHeaders(ctx) {
+		if req.Header.Get(k) == "" {
+			req.Header[k] = vs
+		}
+	}
+	if c.signer != nil && req.Header.Get(serviceauth.Header) == "" {
+		token, err := c.signer.Token(c.audience)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(serviceauth.Header, token)
+	}
+
+	if c.retry == nil || !isIdempotent(req.Method) {
+		return c.send(req)
+	}
+
+	policy := *c.retry
+	retryIf := policy.RetryIf
+	policy.RetryIf = func(err error) bool {
+		return !errors.Is(err, context.Canceled) && (retryIf == nil || retryIf(err))
+	}
+
+	var res *http.Response
+	err := utils.Retry(ctx, policy, func(ctx context.Context) error {
+		attempt := req
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return utils.Permanent(err)
+			}
+			attempt = req.Clone(ctx)
+			attempt.Body = body
+		}
+
+		r, err := c.send(attempt)
+		if err != nil {
+			if errors.IsCode(err, code.ErrCircuitOpen) {
+				return utils.Permanent(err)
+			}
+			return err
+		}
+		// Keep the last response so callers can inspect it if retries run out.
+		if res != nil {
+			res.Body.Close()
+		}
+		res = r
+		if r.StatusCode >= 500 {
+			return &statusError{status: r.StatusCode}
+		}
+		return nil
+	})
+	if err != nil {
+		var se *statusError
+		if errors.As(err, &se) && res != nil {
+			return res, nil
+		}
+		if res != nil {
+			res.Body.Close()
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+// send performs a single attempt through the host's breaker and records
+// metrics.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	var done func(error)
+	if b := c.breaker(req.URL.Host); b != nil {
+		var err error
+		if done, err = b.Allow(); err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	res, err := c.http.Do(req)
+	if c.metrics != nil {
+		status := 0
+		if res != nil {
+			status = res.StatusCode
+		}
+		c.metrics.Observe(req.URL.Host, req.Method, status, time.Since(start))
+	}
+
+	if done != nil {
+		switch {
+		case err != nil:
+			done(err)
+		case res.StatusCode >= 500:
+			done(&statusError{status: res.StatusCode})
+		default:
+			done(nil)
+		}
+	}
+	return res, err
+}
+
+// breaker returns the breaker for host, or nil if breakers are disabled.
+func (c *Client) breaker(host string) *resilience.Breaker {
+	if c.breakerCfg == nil {
+		return nil
+	}
+	c.breakersMu.Lock()
+	defer c.breakersMu.Unlock()
+
+	if b, ok := c.breakers[host]; ok {
+		return b
+	}
+	if c.breakers == nil {
+		c.breakers = make(map[string]*resilience.Breaker)
+	}
+	cfg := *c.breakerCfg
+	cfg.Name = host
+	b := resilience.NewBreaker(cfg)
+	c.breakers[host] = b
+	return b
+}
+
+// NewRequest builds a request for path (relative to the base URL) with an
+// optional JSON body.
+func (c *Client) NewRequest(ctx context.Context, method, path string, body any) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, code.WrapError(err, code.ErrEncodingJSON, "encode request body")
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.url(path), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+	return req, nil
+}
+
+func (c *Client) url(path string) string {
+	if c.baseURL == "" || strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return c.baseURL + "/" + strings.TrimLeft(path, "/")
+}
+
+// DoJSON sends a request with an optional JSON body and decodes a JSON
+// response into T. Non-2xx responses are converted to coded errors.
+func DoJSON[T any](ctx context.Context, c *Client, method, path string, body any) (T, error) {
+	var zero T
+
+	req, err := c.NewRequest(ctx, method, path, body)
+	if err != nil {
+		return zero, err
+	}
+
+	res, err := c.Do(ctx, req)
+	if err != nil {
+		if errors.IsCode(err, code.ErrCircuitOpen) {
+			return zero, err
+		}
+		return zero, code.WrapExternalError(err, c.service, method+" "+path)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return zero, c.responseError(res, method, path)
+	}
+
+	var out T
+	if res.StatusCode == http.StatusNoContent {
+		return out, nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil && err != io.EOF {
+		return zero, code.WrapError(err, code.ErrDecodingJSON, "decode response body")
+	}
+	return out, nil
+}
+
+// GetJSON sends a GET request and decodes the JSON response into T.
+func GetJSON[T any](ctx context.Context, c *Client, path string) (T, error) {
+	return DoJSON[T](ctx, c, http.MethodGet, path, nil)
+}
+
+// PostJSON sends body as JSON in a POST request and decodes the JSON response into T.
+func PostJSON[T any](ctx context.Context, c *Client, path string, body any) (T, error) {
+	return DoJSON[T](ctx, c, http.MethodPost, path, body)
+}
+
+// responseError converts a non-2xx response into an ErrExternalService error.
+// If the body uses the kit response envelope, the remote coded error is kept
+// in the chain so errors.IsCode can match the remote code.
+func (c *Client) responseError(res *http.Response, method, path string) error {
+	data, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+
+	cause := resp.DecodeError(data)
+	if cause == nil {
+		cause = errors.Errorf("status %d", res.StatusCode)
+	}
+	return code.WrapExternalError(cause, c.service, fmt.Sprintf("%s %s (status %d)", method, path, res.StatusCode))
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
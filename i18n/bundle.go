@@ -0,0 +1,478 @@
+// Package i18n loads translated messages from per-locale TOML or YAML files
+// and renders them for the locale of a request.
+//
+// A bundle is a directory of files named after their locale ("en.yaml",
+// "zh-CN.toml", or "validation.pt-BR.yaml" to split a locale over several
+// files). Nested tables are flattened into dotted message IDs, messages
+// are text/template templates, and a table of plural categories is a
+// plural message selected by the Count of the data:
+//
+//	validation:
+//	  required: "{{.Field}} is required"
+//	  min: "{{.Field}} must be at least {{.Param}}"
+//	error:
+//	  "100404": "Resource not found"
+//	cart:
+//	  items:
+//	    zero: "Your cart is empty"
+//	    one: "{{.Count}} item in your cart"
+//	    other: "{{.Count}} items in your cart"
+//
+// IDs of the form "validation.<tag>" translate validator.TranslateError
+// and "error.<code>" the messages of errors.MessageFor once the bundle is
+// the default one (see SetDefault).
+package i18n
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/pelletier/go-toml/v2"
+	"go.yaml.in/yaml/v3"
+)
+
+// DefaultLocale is the default of BundleOptions.DefaultLocale.
+const DefaultLocale = "en"
+
+// MissingMode selects what a missing translation renders.
+type MissingMode int
+
+const (
+	// MissingFallback renders the fallback text, or the message ID
+	// without one.
+	MissingFallback MissingMode = iota
+	// MissingAnnotate renders the fallback text, or the message ID,
+	// prefixed with "⟦missing:<id>⟧", so that untranslated messages stand
+	// out in development.
+	MissingAnnotate
+)
+
+// BundleOptions configure a Bundle.
+type BundleOptions struct {
+	// DefaultLocale is the last locale tried, and the locale of contexts
+	// without one; default DefaultLocale.
+	DefaultLocale string
+	// Missing selects what missing translations render; default
+	// MissingFallback.
+	Missing MissingMode
+}
+
+// Bundle holds the messages of every locale. It is safe for concurrent
+// use, also while it is reloaded.
+type Bundle struct {
+	opts BundleOptions
+	cat  atomic.Pointer[catalog]
+
+	mu   sync.Mutex // guards fsys
+	fsys fs.FS
+}
+
+// catalog is the content of a bundle at one load.
+type catalog struct {
+	// messages are the messages by normalized locale, then ID.
+	messages map[string]map[string]*message
+	// locales are the locales as written in file names, by normalized
+	// locale.
+	locales map[string]string
+}
+
+// message is a translation: a single text, under "other", or a text per
+// plural category.
+type message struct {
+	plural bool
+	forms  map[string]*text
+}
+
+type text struct {
+	raw  string
+	tmpl *template.Template // nil without actions
+}
+
+// LoadBundle loads the message files of fsys, walking its directories.
+// Files ending in .toml, .yaml, .yml or .json are read; their locale is
+// the last dot-separated part of their name before the extension. An ID
+// defined twice for a locale is an error.
+func LoadBundle(fsys fs.FS, opts ...BundleOptions) (*Bundle, error) {
+	var o BundleOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.DefaultLocale == "" {
+		o.DefaultLocale = DefaultLocale
+	}
+	b := &Bundle{opts: o, fsys: fsys}
+	if err := b.Reload(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Reload reads the files of the bundle again and swaps them in at once;
+// on error the previous messages stay.
+func (b *Bundle) Reload() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.reload()
+}
+
+// reload is Reload; b.mu must be held.
+func (b *Bundle) reload() error {
+	c, err := load(b.fsys)
+	if err != nil {
+		return err
+	}
+	b.cat.Store(c)
+	return nil
+}
+
+// DefaultLocale returns the default locale of the bundle.
+func (b *Bundle) DefaultLocale() string {
+	return b.opts.DefaultLocale
+}
+
+// Locales returns the locales of the bundle, sorted.
+func (b *Bundle) Locales() []string {
+	c := b.cat.Load()
+	out := make([]string, 0, len(c.locales))
+	for _, l := range c.locales {
+		out = append(out, l)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// T renders message id for the locale of ctx (see utils.WithLocale) with
+// data, a map or struct whose fields the template uses. When no locale of
+// the chain has id, it renders as configured by BundleOptions.Missing.
+func (b *Bundle) T(ctx context.Context, id string, data any) string {
+	return b.Message(ctx, id, data, "")
+}
+
+// Message is T rendering fallback, rather than id, when the message is
+// missing.
+func (b *Bundle) Message(ctx context.Context, id string, data any, fallback string) string {
+	if s, ok := b.Lookup(ctx, id, data); ok {
+		return s
+	}
+	return b.missing(id, fallback)
+}
+
+// Lookup renders message id for the locale of ctx, trying the locale,
+// its parents ("pt-BR", then "pt") and the default locale in turn. It
+// reports false when none has id.
+func (b *Bundle) Lookup(ctx context.Context, id string, data any) (string, bool) {
+	c := b.cat.Load()
+	for _, locale := range b.chain(utils.GetLocale(ctx)) {
+		m, ok := c.messages[locale][id]
+		if !ok {
+			continue
+		}
+		s, err := m.render(locale, data)
+		if err != nil {
+			slog.WarnContext(ctx, "Translation failed",
+				slog.String("locale", c.locales[locale]), slog.String("id", id), slog.String("error", err.Error()))
+			continue
+		}
+		return s, true
+	}
+	return "", false
+}
+
+// Match returns the locale of the bundle best matching the preferred
+// locales, in order of preference: the same locale, or one of the same
+// language ("en-US" matches "en", "en" matches "en-GB"). It reports false
+// when none matches.
+func (b *Bundle) Match(preferred ...string) (string, bool) {
+	c := b.cat.Load()
+	for _, p := range preferred {
+		p = normalize(p)
+		if l, ok := c.locales[p]; ok {
+			return l, true
+		}
+		lang, _, _ := strings.Cut(p, "-")
+		if l, ok := c.locales[lang]; ok {
+			return l, true
+		}
+		var found []string
+		for n, l := range c.locales {
+			if strings.HasPrefix(n, lang+"-") {
+				found = append(found, l)
+			}
+		}
+		if len(found) > 0 {
+			slices.Sort(found)
+			return found[0], true
+		}
+	}
+	return "", false
+}
+
+// missing renders a missing message.
+func (b *Bundle) missing(id, fallback string) string {
+	if fallback == "" {
+		fallback = id
+	}
+	if b.opts.Missing == MissingAnnotate {
+		return "⟦missing:" + id + "⟧ " + fallback
+	}
+	return fallback
+}
+
+// chain returns the normalized locales tried for locale, most specific
+// first.
+func (b *Bundle) chain(locale string) []string {
+	var out []string
+	add := func(l string) {
+		for l = normalize(l); l != ""; {
+			if !slices.Contains(out, l) {
+				out = append(out, l)
+			}
+			i := strings.LastIndexByte(l, '-')
+			if i < 0 {
+				break
+			}
+			l = l[:i]
+		}
+	}
+	add(locale)
+	add(b.opts.DefaultLocale)
+	return out
+}
+
+// normalize lower-cases a locale and separates its parts with '-'.
+func normalize(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// render renders the form of m selected by the Count of data.
+func (m *message) render(locale string, data any) (string, error) {
+	t := m.forms["other"]
+	if m.plural {
+		n, ok := countOf(data)
+		if !ok {
+			return "", fmt.Errorf("plural message without Count")
+		}
+		if f, ok := m.forms[pluralCategory(locale, n)]; ok {
+			t = f
+		}
+		// An explicit zero form wins in every language.
+		if f, ok := m.forms["zero"]; ok && n == 0 {
+			t = f
+		}
+	}
+	if t.tmpl == nil {
+		return t.raw, nil
+	}
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// load reads the message files of fsys.
+func load(fsys fs.FS) (*catalog, error) {
+	c := &catalog{messages: make(map[string]map[string]*message), locales: make(map[string]string)}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		locale, ok := fileLocale(name)
+		if !ok {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		raw, err := decode(name, data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		key := normalize(locale)
+		if _, ok := c.locales[key]; !ok {
+			c.locales[key] = locale
+			c.messages[key] = make(map[string]*message)
+		}
+		if err := flatten(c.messages[key], "", raw); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// fileLocale returns the locale of a message file: "zh-CN" for "zh-CN.toml"
+// and "messages.zh-CN.toml". It reports false for other files.
+func fileLocale(name string) (string, bool) {
+	ext := path.Ext(name)
+	switch ext {
+	case ".toml", ".yaml", ".yml", ".json":
+	default:
+		return "", false
+	}
+	base := strings.TrimSuffix(path.Base(name), ext)
+	if i := strings.LastIndexByte(base, '.'); i >= 0 {
+		base = base[i+1:]
+	}
+	return base, base != ""
+}
+
+// decode parses a message file; JSON is read as YAML.
+func decode(name string, data []byte) (map[string]any, error) {
+	var raw map[string]any
+	var err error
+	if path.Ext(name) == ".toml" {
+		err = toml.Unmarshal(data, &raw)
+	} else {
+		err = yaml.Unmarshal(data, &raw)
+	}
+	return raw, err
+}
+
+// pluralCategories are the CLDR plural categories.
+var pluralCategories = []string{"zero", "one", "two", "few", "many", "other"}
+
+// flatten adds the messages of a decoded table to out, under prefix.
+func flatten(out map[string]*message, prefix string, table map[string]any) error {
+	for k, v := range table {
+		id := k
+		if prefix != "" {
+			id = prefix + "." + k
+		}
+		switch v := v.(type) {
+		case string:
+			t, err := parseText(id, v)
+			if err != nil {
+				return err
+			}
+			if err := add(out, id, &message{forms: map[string]*text{"other": t}}); err != nil {
+				return err
+			}
+		case map[string]any:
+			if !isPlural(v) {
+				if err := flatten(out, id, v); err != nil {
+					return err
+				}
+				continue
+			}
+			m := &message{plural: true, forms: make(map[string]*text, len(v))}
+			for cat, s := range v {
+				t, err := parseText(id+"."+cat, s.(string))
+				if err != nil {
+					return err
+				}
+				m.forms[cat] = t
+			}
+			if err := add(out, id, m); err != nil {
+				return err
+			}
+		case map[any]any:
+			sub := make(map[string]any, len(v))
+			for sk, sv := range v {
+				sub[fmt.Sprint(sk)] = sv
+			}
+			if err := flatten(out, prefix, map[string]any{k: sub}); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("message %s: %T is not a string or table", id, v)
+		}
+	}
+	return nil
+}
+
+// isPlural reports whether a table is a plural message: an "other" text
+// and texts of other plural categories only.
+func isPlural(table map[string]any) bool {
+	if _, ok := table["other"].(string); !ok {
+		return false
+	}
+	for k, v := range table {
+		if _, ok := v.(string); !ok || !slices.Contains(pluralCategories, k) {
+			return false
+		}
+	}
+	return true
+}
+
+func add(out map[string]*message, id string, m *message) error {
+	if _, ok := out[id]; ok {
+		return fmt.Errorf("message %s defined twice", id)
+	}
+	out[id] = m
+	return nil
+}
+
+func parseText(id, raw string) (*text, error) {
+	if !strings.Contains(raw, "{{") {
+		return &text{raw: raw}, nil
+	}
+	tmpl, err := template.New(id).Option("missingkey=zero").Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("message %s: %w", id, err)
+	}
+	return &text{raw: raw, tmpl: tmpl}, nil
+}
+
+var defaultBundle atomic.Pointer[Bundle]
+
+// SetDefault makes b the bundle of the package functions and translates
+// the messages of errors.MessageFor with its "error.<code>" messages; nil
+// removes it.
+func SetDefault(b *Bundle) {
+	defaultBundle.Store(b)
+	if b == nil {
+		errors.SetMessageTranslator(nil)
+		return
+	}
+	errors.SetMessageTranslator(func(ctx context.Context, code int, message string) string {
+		return b.Message(ctx, "error."+strconv.Itoa(code), nil, message)
+	})
+}
+
+// Default returns the bundle set by SetDefault, or nil.
+func Default() *Bundle {
+	return defaultBundle.Load()
+}
+
+// T renders message id with the default bundle (see Bundle.T). Without
+// one, it returns id.
+func T(ctx context.Context, id string, data any) string {
+	if b := Default(); b != nil {
+		return b.T(ctx, id, data)
+	}
+	return id
+}
+
+// Lookup renders message id with the default bundle (see Bundle.Lookup).
+func Lookup(ctx context.Context, id string, data any) (string, bool) {
+	if b := Default(); b != nil {
+		return b.Lookup(ctx, id, data)
+	}
+	return "", false
+}
+
+// Message renders message id with the default bundle, or fallback when
+// it is missing (see Bundle.Message).
+func Message(ctx context.Context, id string, data any, fallback string) string {
+	if b := Default(); b != nil {
+		return b.Message(ctx, id, data, fallback)
+	}
+	return fallback
+}
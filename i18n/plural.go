@@ -0,0 +1,107 @@
+package i18n
+
+import (
+	"math"
+	"reflect"
+	"strings"
+)
+
+// pluralCategory returns the CLDR plural category of n in the language of
+// locale, for the cardinal rules of common languages. Languages without a
+// rule use the English one; fractions are "other".
+func pluralCategory(locale string, n float64) string {
+	if n != math.Trunc(n) {
+		return "other"
+	}
+	i := int64(math.Abs(n))
+	lang, _, _ := strings.Cut(locale, "-")
+	switch lang {
+	case "ja", "zh", "ko", "vi", "th", "id", "ms", "lo", "my":
+		return "other"
+	case "fr", "pt", "hy", "kab":
+		if i == 0 || i == 1 {
+			return "one"
+		}
+		return "other"
+	case "ru", "uk", "be":
+		switch {
+		case i%10 == 1 && i%100 != 11:
+			return "one"
+		case i%10 >= 2 && i%10 <= 4 && (i%100 < 12 || i%100 > 14):
+			return "few"
+		}
+		return "many"
+	case "pl":
+		switch {
+		case i == 1:
+			return "one"
+		case i%10 >= 2 && i%10 <= 4 && (i%100 < 12 || i%100 > 14):
+			return "few"
+		}
+		return "many"
+	case "cs", "sk":
+		switch {
+		case i == 1:
+			return "one"
+		case i >= 2 && i <= 4:
+			return "few"
+		}
+		return "other"
+	case "ar":
+		switch {
+		case i == 0:
+			return "zero"
+		case i == 1:
+			return "one"
+		case i == 2:
+			return "two"
+		case i%100 >= 3 && i%100 <= 10:
+			return "few"
+		case i%100 >= 11:
+			return "many"
+		}
+		return "other"
+	}
+	if i == 1 {
+		return "one"
+	}
+	return "other"
+}
+
+// countOf returns the Count of data: a "Count" key of a map or field of a
+// struct, holding a number.
+func countOf(data any) (float64, bool) {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return 0, false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return 0, false
+		}
+		v = v.MapIndex(reflect.ValueOf("Count").Convert(v.Type().Key()))
+	case reflect.Struct:
+		v = v.FieldByName("Count")
+	default:
+		return 0, false
+	}
+	if v.IsValid() && v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return 0, false
+	}
+	switch {
+	case v.CanInt():
+		return float64(v.Int()), true
+	case v.CanUint():
+		return float64(v.Uint()), true
+	case v.CanFloat():
+		return v.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,106 @@
+package i18n
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/NSObjects/go-kit/lifecycle"
+)
+
+// watchDebounce is the quiet time after a change before Watch reloads.
+const watchDebounce = 300 * time.Millisecond
+
+// Watch reloads the bundle from dir, and again whenever a message file in
+// dir or its subdirectories changes, until ctx is done. It is meant for
+// development, with dir the source directory of an embedded bundle:
+//
+//	//go:embed locales
+//	var locales embed.FS
+//
+//	sub, _ := fs.Sub(locales, "locales")
+//	bundle, err := i18n.LoadBundle(sub)
+//	if cfg.System.Env == "dev" {
+//	    err = bundle.Watch(ctx, "locales")
+//	}
+//
+// Reloads failing to parse are logged and keep the previous messages.
+func (b *Bundle) Watch(ctx context.Context, dir string) error {
+	b.mu.Lock()
+	prev := b.fsys
+	b.fsys = os.DirFS(dir)
+	if err := b.reload(); err != nil {
+		b.fsys = prev
+		b.mu.Unlock()
+		return err
+	}
+	b.mu.Unlock()
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// fsnotify does not recurse: every directory is watched, including
+	// the ones created later.
+	watchDirs := func() {
+		_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && d.IsDir() {
+				if err := w.Add(p); err != nil {
+					slog.Warn("i18n watch failed", slog.String("path", p), slog.String("error", err.Error()))
+				}
+			}
+			return nil
+		})
+	}
+	watchDirs()
+
+	context.AfterFunc(ctx, func() { _ = w.Close() })
+	lifecycle.Go(ctx, "i18n.watch", func(ctx context.Context) error {
+		var timer *time.Timer
+		var fire <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return nil
+			case event, ok := <-w.Events:
+				if !ok {
+					return nil
+				}
+				if !event.Has(fsnotify.Write | fsnotify.Create | fsnotify.Rename | fsnotify.Remove) {
+					continue
+				}
+				if _, ok := fileLocale(filepath.ToSlash(event.Name)); !ok && !event.Has(fsnotify.Create) {
+					continue
+				}
+				if timer == nil {
+					timer = time.NewTimer(watchDebounce)
+				} else {
+					timer.Reset(watchDebounce)
+				}
+				fire = timer.C
+			case <-fire:
+				fire = nil
+				watchDirs()
+				if err := b.Reload(); err != nil {
+					slog.Error("i18n reload failed", slog.String("path", dir), slog.String("error", err.Error()))
+					continue
+				}
+				slog.Info("i18n bundle reloaded", slog.String("path", dir))
+			case err, ok := <-w.Errors:
+				if !ok {
+					return nil
+				}
+				slog.Warn("i18n watch error", slog.String("path", dir), slog.String("error", err.Error()))
+			}
+		}
+	})
+	return nil
+}
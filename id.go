@@ -0,0 +1,104 @@
+package kit
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+
+	"github.com/NSObjects/go-kit/code"
+)
+
+// ID is an int64 identifier rendered in JSON as a string, so JavaScript
+// clients keep its precision beyond 2^53. It reads strings and numbers,
+// and is a BIGINT in the database.
+//
+//	type Order struct {
+//	    ID     kit.ID `json:"id" gorm:"primaryKey"`
+//	    UserID kit.ID `json:"user_id"`
+//	}
+type ID int64
+
+// ParseID parses s as an ID. Failures are code.ErrBadRequest.
+func ParseID(s string) (ID, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, code.NewErrorf(code.ErrBadRequest, "invalid id %q", s)
+	}
+	return ID(n), nil
+}
+
+// String returns the decimal form of id.
+func (id ID) String() string {
+	return strconv.FormatInt(int64(id), 10)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (id ID) MarshalJSON() ([]byte, error) {
+	b := append(make([]byte, 0, 22), '"')
+	b = strconv.AppendInt(b, int64(id), 10)
+	return append(b, '"'), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler. null and "" are the zero ID.
+func (id *ID) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		*id = 0
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		if unquoted == "" {
+			*id = 0
+			return nil
+		}
+		s = unquoted
+	}
+	parsed, err := ParseID(s)
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// UnmarshalParam implements echo.BindUnmarshaler, for IDs in paths and
+// queries.
+func (id *ID) UnmarshalParam(param string) error {
+	parsed, err := ParseID(param)
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (id ID) Value() (driver.Value, error) {
+	return int64(id), nil
+}
+
+// Scan implements sql.Scanner.
+func (id *ID) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*id = 0
+	case int64:
+		*id = ID(v)
+	case []byte:
+		return id.scanText(string(v))
+	case string:
+		return id.scanText(v)
+	default:
+		return fmt.Errorf("kit.ID: cannot scan %T", src)
+	}
+	return nil
+}
+
+func (id *ID) scanText(s string) error {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("kit.ID: cannot parse %q", s)
+	}
+	*id = ID(n)
+	return nil
+}
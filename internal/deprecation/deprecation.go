@@ -0,0 +1,54 @@
+// Package deprecation records uses of deprecated kit APIs for
+// log.DeprecationWarn, so that packages below log (such as config) can
+// report them too.
+package deprecation
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	uses     sync.Map // key → *atomic.Uint64
+	silenced atomic.Bool
+)
+
+// Warn counts a use of the deprecated path key and, on its first use in
+// the process, logs msg at warn level with the caller of the deprecated
+// function. skip is the number of frames between Warn and the deprecated
+// function.
+func Warn(key, msg string, skip int) {
+	if n, ok := uses.Load(key); ok {
+		n.(*atomic.Uint64).Add(1)
+		return
+	}
+	n, loaded := uses.LoadOrStore(key, new(atomic.Uint64))
+	n.(*atomic.Uint64).Add(1)
+	if loaded || silenced.Load() {
+		return
+	}
+	attrs := []any{slog.String("deprecation", key)}
+	if _, file, line, ok := runtime.Caller(2 + skip); ok {
+		attrs = append(attrs, slog.String("caller", fmt.Sprintf("%s:%d", file, line)))
+	}
+	slog.Warn(msg, attrs...)
+}
+
+// SetSilenced stops (or resumes) the logging of Warn; uses are still
+// counted.
+func SetSilenced(on bool) {
+	silenced.Store(on)
+}
+
+// Counts returns the number of uses of each deprecated path used so far.
+func Counts() map[string]uint64 {
+	out := make(map[string]uint64)
+	uses.Range(func(k, v any) bool {
+		out[k.(string)] = v.(*atomic.Uint64).Load()
+		return true
+	})
+	return out
+}
@@ -0,0 +1,82 @@
+// Package filewatch calls back when files change on disk, for the
+// reloaders of certificates and keys mounted from secrets.
+package filewatch
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/NSObjects/go-kit/lifecycle"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Debounce is the quiet time after a change before Watch calls back.
+const Debounce = 300 * time.Millisecond
+
+// Watch calls onChange after any change in the directories of files, once
+// they have been quiet for Debounce, until ctx is done. Directories rather
+// than files are watched, so files replaced by editors and the symlink
+// swaps of Kubernetes secret volumes are seen; onChange must tell whether
+// the files actually changed.
+func Watch(ctx context.Context, files []string, onChange func()) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	dirs := make(map[string]bool)
+	for _, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			_ = w.Close()
+			return err
+		}
+		dir := filepath.Dir(abs)
+		if dirs[dir] {
+			continue
+		}
+		if err := w.Add(dir); err != nil {
+			_ = w.Close()
+			return err
+		}
+		dirs[dir] = true
+	}
+
+	context.AfterFunc(ctx, func() { _ = w.Close() })
+	lifecycle.Go(ctx, "filewatch", func(ctx context.Context) error {
+		var timer *time.Timer
+		var fire <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return nil
+			case event, ok := <-w.Events:
+				if !ok {
+					return nil
+				}
+				if !event.Has(fsnotify.Write | fsnotify.Create | fsnotify.Rename | fsnotify.Remove) {
+					continue
+				}
+				if timer == nil {
+					timer = time.NewTimer(Debounce)
+				} else {
+					timer.Reset(Debounce)
+				}
+				fire = timer.C
+			case <-fire:
+				fire = nil
+				onChange()
+			case err, ok := <-w.Errors:
+				if !ok {
+					return nil
+				}
+				slog.Warn("File watch error", slog.Any("files", files), slog.String("error", err.Error()))
+			}
+		}
+	})
+	return nil
+}
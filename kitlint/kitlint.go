@@ -0,0 +1,193 @@
+// Package kitlint checks go-kit conventions in source code. ScanCodes
+// finds the error code constants of a package for errors.Audit, which
+// reports those never registered:
+//
+//	consts, err := kitlint.ScanCodes("./code")
+//	findings := errors.Audit(errors.AuditOptions{Constants: consts})
+package kitlint
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/NSObjects/go-kit/errors"
+)
+
+// DefaultCodePrefix is the name prefix of error code constants.
+const DefaultCodePrefix = "Err"
+
+// Options configure ScanCodes.
+type Options struct {
+	// Prefix selects the constants by name; default DefaultCodePrefix.
+	Prefix string
+}
+
+// ScanCodes parses the non-test Go files of the package in dir and returns
+// its positive integer constants named with the prefix, in declaration
+// order. It evaluates the iota const blocks of the code package:
+//
+//	const (
+//	    ErrDatabase int = iota + 100101
+//	    ErrRedis        // 100102
+//	)
+//
+// as well as explicit values, references to other constants of the
+// package and constant arithmetic. Constants it cannot evaluate, such as
+// those referring to other packages, are skipped.
+func ScanCodes(dir string, opts ...Options) ([]errors.AuditConstant, error) {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Prefix == "" {
+		o.Prefix = DefaultCodePrefix
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	s := &scanner{specs: make(map[string]constSpec), values: make(map[string]constant.Value)}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		s.collect(fset, f)
+	}
+
+	var consts []errors.AuditConstant
+	for _, name := range s.order {
+		if !strings.HasPrefix(name, o.Prefix) {
+			continue
+		}
+		v := s.value(name)
+		if v == nil || v.Kind() != constant.Int {
+			continue
+		}
+		code, exact := constant.Int64Val(v)
+		if !exact || code <= 0 {
+			continue
+		}
+		consts = append(consts, errors.AuditConstant{Name: name, Code: int(code), Pos: s.specs[name].pos})
+	}
+	return consts, nil
+}
+
+// constSpec is the expression of a constant with its iota.
+type constSpec struct {
+	expr ast.Expr
+	iota int64
+	pos  string
+}
+
+type scanner struct {
+	order  []string
+	specs  map[string]constSpec
+	values map[string]constant.Value // nil for constants being or not evaluated
+}
+
+// collect records the package-level constants of f, repeating the
+// previous expression of a block for the specs without one.
+func (s *scanner) collect(fset *token.FileSet, f *ast.File) {
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		var last []ast.Expr
+		for i, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			if len(vs.Values) > 0 {
+				last = vs.Values
+			}
+			for j, name := range vs.Names {
+				if name.Name == "_" || j >= len(last) {
+					continue
+				}
+				p := fset.Position(name.Pos())
+				s.order = append(s.order, name.Name)
+				s.specs[name.Name] = constSpec{expr: last[j], iota: int64(i), pos: fmt.Sprintf("%s:%d", p.Filename, p.Line)}
+			}
+		}
+	}
+}
+
+// value evaluates the constant name, or returns nil.
+func (s *scanner) value(name string) constant.Value {
+	if v, ok := s.values[name]; ok {
+		return v
+	}
+	spec, ok := s.specs[name]
+	if !ok {
+		return nil
+	}
+	s.values[name] = nil // breaks reference cycles
+	v := s.eval(spec.expr, spec.iota)
+	s.values[name] = v
+	return v
+}
+
+func (s *scanner) eval(expr ast.Expr, iota int64) constant.Value {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind != token.INT {
+			return nil
+		}
+		return constant.MakeFromLiteral(e.Value, e.Kind, 0)
+	case *ast.Ident:
+		if e.Name == "iota" {
+			return constant.MakeInt64(iota)
+		}
+		return s.value(e.Name)
+	case *ast.ParenExpr:
+		return s.eval(e.X, iota)
+	case *ast.UnaryExpr:
+		x := s.eval(e.X, iota)
+		if x == nil || !slices.Contains([]token.Token{token.ADD, token.SUB, token.XOR}, e.Op) {
+			return nil
+		}
+		return constant.UnaryOp(e.Op, x, 0)
+	case *ast.BinaryExpr:
+		x, y := s.eval(e.X, iota), s.eval(e.Y, iota)
+		if x == nil || y == nil {
+			return nil
+		}
+		switch e.Op {
+		case token.ADD, token.SUB, token.MUL, token.AND, token.OR, token.XOR:
+			return constant.BinaryOp(x, e.Op, y)
+		case token.QUO, token.REM:
+			if constant.Sign(y) == 0 {
+				return nil
+			}
+			if e.Op == token.QUO {
+				return constant.BinaryOp(x, token.QUO_ASSIGN, y) // integer division
+			}
+			return constant.BinaryOp(x, e.Op, y)
+		case token.SHL, token.SHR:
+			n, ok := constant.Uint64Val(y)
+			if !ok || n > 63 {
+				return nil
+			}
+			return constant.Shift(x, e.Op, uint(n))
+		}
+	case *ast.CallExpr:
+		// Conversions such as int(100001).
+		if id, ok := e.Fun.(*ast.Ident); ok && len(e.Args) == 1 && strings.HasPrefix(id.Name, "int") {
+			return s.eval(e.Args[0], iota)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package kittest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/NSObjects/go-kit/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// Defaults of NewEchoContext.
+const (
+	RequestID = "kittest-request"
+	UserID    = "kittest-user"
+)
+
+// ContextOption configures NewEchoContext.
+type ContextOption func(*contextOptions)
+
+type contextOptions struct {
+	echo      *echo.Echo
+	requestID string
+	userID    string
+	tenantID  string
+	roles     []string
+	headers   http.Header
+	names     []string
+	values    []string
+}
+
+// WithEcho creates the context on e instead of a new echo.Echo with the
+// kit's validator and binder.
+func WithEcho(e *echo.Echo) ContextOption {
+	return func(o *contextOptions) { o.echo = e }
+}
+
+// WithRequestID sets the request ID; default RequestID.
+func WithRequestID(id string) ContextOption {
+	return func(o *contextOptions) { o.requestID = id }
+}
+
+// WithUserID sets the authenticated user; default UserID, "" for an
+// anonymous request.
+func WithUserID(id string) ContextOption {
+	return func(o *contextOptions) { o.userID = id }
+}
+
+// WithTenant sets the tenant ID.
+func WithTenant(id string) ContextOption {
+	return func(o *contextOptions) { o.tenantID = id }
+}
+
+// WithRoles sets the user's roles.
+func WithRoles(roles ...string) ContextOption {
+	return func(o *contextOptions) { o.roles = roles }
+}
+
+// WithHeader adds a request header.
+func WithHeader(key, value string) ContextOption {
+	return func(o *contextOptions) { o.headers.Add(key, value) }
+}
+
+// WithPathParams sets the route parameters, as names and values in pairs:
+// WithPathParams("id", "42").
+func WithPathParams(pairs ...string) ContextOption {
+	return func(o *contextOptions) {
+		for i := 0; i+1 < len(pairs); i += 2 {
+			o.names = append(o.names, pairs[i])
+			o.values = append(o.values, pairs[i+1])
+		}
+	}
+}
+
+// NewEchoContext returns a context for a request to path, with the request
+// ID and user the kit's middleware would set, and its recorder. body is
+// sent as is when it is a string, []byte or io.Reader and as JSON
+// otherwise; nil sends no body.
+func NewEchoContext(t TB, method, path string, body any, opts ...ContextOption) (echo.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+	o := contextOptions{requestID: RequestID, userID: UserID, headers: http.Header{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	e := o.echo
+	if e == nil {
+		e = echo.New()
+		e.Validator = validator.New()
+		e.Binder = validator.NewBinder()
+	}
+
+	req := httptest.NewRequest(method, path, requestBody(t, body))
+	if body != nil {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	for key, values := range o.headers {
+		req.Header[key] = values
+	}
+	req.Header.Set(echo.HeaderXRequestID, o.requestID)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Response().Header().Set(echo.HeaderXRequestID, o.requestID)
+	if o.userID != "" {
+		c.Set(string(utils.KeyUserID), o.userID)
+	}
+	if o.tenantID != "" {
+		c.Set(string(utils.KeyTenantID), o.tenantID)
+	}
+	if o.roles != nil {
+		c.Set(string(utils.KeyRoles), o.roles)
+	}
+	if len(o.names) > 0 {
+		c.SetParamNames(o.names...)
+		c.SetParamValues(o.values...)
+	}
+	return c, rec
+}
+
+func requestBody(t TB, body any) io.Reader {
+	t.Helper()
+	switch b := body.(type) {
+	case nil:
+		return nil
+	case string:
+		return bytes.NewBufferString(b)
+	case []byte:
+		return bytes.NewReader(b)
+	case io.Reader:
+		return b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatalf("kittest: encode request body: %v", err)
+		}
+		return bytes.NewReader(data)
+	}
+}
+
+// Envelope is a resp.Response with typed data.
+type Envelope[T any] struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data T      `json:"data"`
+}
+
+// DecodeEnvelope decodes the envelope in rec and returns its data, failing
+// the test unless the response is a success.
+func DecodeEnvelope[T any](t TB, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	env := decode[T](t, rec)
+	if rec.Code >= http.StatusBadRequest || env.Code != 0 {
+		t.Fatalf("kittest: want success, got HTTP %d code %d: %s", rec.Code, env.Code, env.Msg)
+	}
+	return env.Data
+}
+
+// DecodeList decodes a list envelope (see resp.ListDataResponse) and returns
+// its items and total.
+func DecodeList[T any](t TB, rec *httptest.ResponseRecorder) ([]T, int64) {
+	t.Helper()
+	list := DecodeEnvelope[struct {
+		List  []T   `json:"list"`
+		Total int64 `json:"total"`
+	}](t, rec)
+	return list.List, list.Total
+}
+
+// AssertErrorResponse reports a failure unless rec is an error envelope
+// with code wantCode and the HTTP status registered for it. It returns the
+// envelope message. Handlers called directly return their error; render it
+// first with middleware.ErrorHandler(err, c).
+func AssertErrorResponse(t TB, rec *httptest.ResponseRecorder, wantCode int) string {
+	t.Helper()
+	env := decode[json.RawMessage](t, rec)
+	if env.Code != wantCode {
+		t.Errorf("kittest: want code %d, got %d (HTTP %d): %s", wantCode, env.Code, rec.Code, env.Msg)
+	}
+	if coder, ok := errors.Lookup(wantCode); ok && rec.Code != coder.HTTPStatus() {
+		t.Errorf("kittest: want HTTP %d for code %d, got %d", coder.HTTPStatus(), wantCode, rec.Code)
+	}
+	return env.Msg
+}
+
+func decode[T any](t TB, rec *httptest.ResponseRecorder) Envelope[T] {
+	t.Helper()
+	var env Envelope[T]
+	if err := json.Unmarshal(rec.Body.Bytes(), &env); err != nil {
+		t.Fatalf("kittest: decode envelope: %v\nbody: %s", err, rec.Body.String())
+	}
+	return env
+}
@@ -0,0 +1,43 @@
+package kittest
+
+import (
+	"hash/fnv"
+	"time"
+
+	"github.com/NSObjects/go-kit/utils"
+)
+
+// WithFrozenTime returns a utils.FakeClock at now for the components of
+// the test, which take it through their options, so parallel tests each
+// keep their own time; nothing global is swapped:
+//
+//	clock := kittest.WithFrozenTime(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+//	s := scheduler.New(scheduler.Options{Clock: clock})
+//	clock.Advance(time.Minute)
+//
+// A zero now is the start of 2024 in UTC.
+func WithFrozenTime(t TB, now time.Time) *utils.FakeClock {
+	t.Helper()
+	if now.IsZero() {
+		now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return utils.NewFakeClock(now)
+}
+
+// NamedTB is a TB with the name of the test, as testing.TB.
+type NamedTB interface {
+	TB
+	Name() string
+}
+
+// NewTestRand returns a utils.Rand seeded from the name of the test, so
+// that its random values, jitters and sampled logs repeat from run to
+// run, and logs the seed.
+func NewTestRand(t NamedTB) utils.Rand {
+	t.Helper()
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(t.Name()))
+	seed := h.Sum64()
+	t.Logf("kittest: random seed %d", seed)
+	return utils.NewSeededRand(seed)
+}
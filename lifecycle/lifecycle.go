@@ -0,0 +1,236 @@
+// Package lifecycle orders the start and shutdown of the long-lived parts
+// of a service: log sinks, database connections, the tracer provider,
+// schedulers and background jobs.
+//
+// Hooks start in the order they are appended and stop in reverse order, so
+// whatever is set up first, such as the logger, is torn down last:
+//
+//	lc := lifecycle.New()
+//	ctx = lifecycle.NewContext(ctx, lc)
+//	logger := log.NewFromLogConfig(log.LogConfig{Lifecycle: lc}, env) // closed last
+//	shutdown, err := observability.Setup(ctx, cfg.Otel, cfg.System)
+//	dm, err := db.NewManager(ctx, cfg)
+//	sched := scheduler.New(scheduler.Options{Lifecycle: lc})      // stopped first
+//	runner := server.NewRunner(srv, cfg.System, lc)
+//
+// The kit helpers register themselves when given a Lifecycle: db.New and
+// db.NewManager and observability.Setup take it from their context (see
+// NewContext); scheduler.New, async.NewJobStore and log.NewFromLogConfig
+// from their options. Parts registered this way must not also be passed
+// to server.NewRunner, or they would be stopped twice.
+//
+// Background goroutines of the kit (log replay, config and file watchers,
+// schedulers, relays, async jobs) are started with Go, which labels them,
+// restarts them after panics and lists them in Workers, exposed by
+// adminserver.WithWorkers and metrics.RegisterWorkerCollector. With
+// Options.WaitWorkers, as in server.Runner, Stop reports the workers that
+// did not exit with their stacks.
+//
+// A Lifecycle is a server.Component. Its Start and Stop also have the
+// signature of fx hooks, so an fx application gets the same order with:
+//
+//	fxLifecycle.Append(fx.Hook{OnStart: lc.Start, OnStop: lc.Stop})
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/NSObjects/go-kit/errors"
+)
+
+// DefaultTimeout bounds each hook unless the hook or Options set one.
+const DefaultTimeout = 30 * time.Second
+
+// Hook is a part of a service with a start and a stop action, either of
+// which may be nil.
+type Hook struct {
+	// Name identifies the hook in logs and errors.
+	Name string
+	// OnStart starts the part. Background work must not inherit the
+	// cancellation of its context, which ends with the start timeout (use
+	// context.WithoutCancel).
+	OnStart func(ctx context.Context) error
+	// OnStop stops the part. It runs only if OnStart succeeded.
+	OnStop func(ctx context.Context) error
+	// StartTimeout and StopTimeout bound OnStart and OnStop; default
+	// Options.Timeout.
+	StartTimeout time.Duration
+	StopTimeout  time.Duration
+}
+
+// HookError is the error of a hook that failed, timed out or panicked.
+type HookError struct {
+	Name string
+	Op   string // "start" or "stop"
+	Err  error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s hook %q: %v", e.Op, e.Name, e.Err)
+}
+
+func (e *HookError) Unwrap() error { return e.Err }
+
+// Options configure a Lifecycle.
+type Options struct {
+	// Timeout bounds each hook without its own timeout; default
+	// DefaultTimeout.
+	Timeout time.Duration
+	// WaitWorkers makes Stop wait, once the hooks stopped, for the
+	// workers started with Go to exit, bounded by Timeout, and report
+	// the stragglers (see WaitWorkers). Set it on the outermost Lifecycle
+	// only, such as the one of server.Runner: a nested one would wait for
+	// the workers of parts stopped after it.
+	WaitWorkers bool
+}
+
+// Lifecycle runs hooks in order on start and in reverse order on stop.
+type Lifecycle struct {
+	timeout     time.Duration
+	waitWorkers bool
+
+	mu      sync.Mutex
+	hooks   []Hook
+	started int // number of hooks reached by Start, in order
+	stopped bool
+}
+
+// New creates a Lifecycle.
+func New(opts ...Options) *Lifecycle {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+	return &Lifecycle{timeout: o.Timeout, waitWorkers: o.WaitWorkers}
+}
+
+// Append adds h after the hooks already appended. Hooks appended after
+// Start never run.
+func (l *Lifecycle) Append(h Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, h)
+}
+
+// Start runs the OnStart of each hook in order, each bounded by its
+// timeout. It stops at the first failure and returns it as a *HookError;
+// Stop then stops the hooks that started.
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.mu.Lock()
+	hooks := l.hooks[l.started:]
+	l.mu.Unlock()
+
+	for _, h := range hooks {
+		if h.OnStart != nil {
+			if err := l.run(ctx, "start", h.Name, h.StartTimeout, h.OnStart); err != nil {
+				return err
+			}
+		}
+		l.mu.Lock()
+		l.started++
+		l.mu.Unlock()
+	}
+	return nil
+}
+
+// Stop runs the OnStop of the started hooks in reverse order, each bounded
+// by its timeout. A hook failing, timing out or panicking does not keep
+// the others from stopping; their errors are joined (errors.Join) as
+// *HookErrors, along with the stragglers of Options.WaitWorkers as the
+// *HookError of "workers". Stop runs the hooks once; later calls return
+// nil.
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.mu.Lock()
+	if l.stopped {
+		l.mu.Unlock()
+		return nil
+	}
+	l.stopped = true
+	hooks := l.hooks[:l.started]
+	l.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if h.OnStop == nil {
+			continue
+		}
+		if err := l.run(ctx, "stop", h.Name, h.StopTimeout, h.OnStop); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if l.waitWorkers {
+		wctx, cancel := context.WithTimeout(ctx, l.timeout)
+		if err := WaitWorkers(wctx); err != nil {
+			errs = append(errs, &HookError{Name: "workers", Op: "stop", Err: err})
+		}
+		cancel()
+	}
+	return errors.Join(errs...)
+}
+
+// run runs fn with timeout (default the Lifecycle's), isolating panics,
+// and logs the outcome.
+func (l *Lifecycle) run(ctx context.Context, op, name string, timeout time.Duration, fn func(context.Context) error) error {
+	if timeout <= 0 {
+		timeout = l.timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := runWithContext(ctx, fn)
+	attrs := []any{
+		slog.String("hook", name),
+		slog.String("op", op),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if err != nil {
+		slog.Error("Lifecycle hook failed", append(attrs, slog.String("error", err.Error()))...)
+		return &HookError{Name: name, Op: op, Err: err}
+	}
+	slog.Info("Lifecycle hook completed", attrs...)
+	return nil
+}
+
+// runWithContext returns when fn returns or ctx is done, so hooks that
+// ignore their context still honor the timeout. A panic of fn is returned
+// as an error (errors.FromPanic).
+func runWithContext(ctx context.Context, fn func(ctx context.Context) error) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- errors.FromPanic(r)
+			}
+		}()
+		done <- fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out: %w", ctx.Err())
+	}
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying l, for the helpers that
+// register with the Lifecycle of their context.
+func NewContext(ctx context.Context, l *Lifecycle) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the Lifecycle of ctx, or nil.
+func FromContext(ctx context.Context) *Lifecycle {
+	l, _ := ctx.Value(contextKey{}).(*Lifecycle)
+	return l
+}
@@ -0,0 +1,286 @@
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/pprof"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NSObjects/go-kit/errors"
+)
+
+// WorkerLabel is the pprof label naming the worker of a goroutine started
+// with Go; it shows in goroutine profiles (/debug/pprof/goroutine?debug=1).
+const WorkerLabel = "kit_worker"
+
+// Worker states reported by Workers.
+const (
+	WorkerRunning    = "running"
+	WorkerRestarting = "restarting" // waiting to restart after a panic
+)
+
+// Defaults applied when the corresponding WorkerOptions field is zero.
+const (
+	DefaultRestartBackoff    = time.Second
+	DefaultMaxRestartBackoff = time.Minute
+)
+
+// WorkerOptions configure a worker started with Go.
+type WorkerOptions struct {
+	// RestartBackoff is the delay before restarting after a panic,
+	// doubled after each consecutive panic; default
+	// DefaultRestartBackoff.
+	RestartBackoff time.Duration
+	// MaxRestartBackoff caps the delay; default DefaultMaxRestartBackoff.
+	// A run lasting longer resets the delay to RestartBackoff.
+	MaxRestartBackoff time.Duration
+	// Once runs fn once, for one-shot tasks: a panic is logged and kept
+	// as the last error but not restarted.
+	Once bool
+}
+
+// WorkerInfo describes a running worker.
+type WorkerInfo struct {
+	ID        uint64    `json:"id"`
+	Name      string    `json:"name"`
+	State     string    `json:"state"`
+	StartedAt time.Time `json:"started_at"`
+	// Restarts is the number of restarts after panics.
+	Restarts    int       `json:"restarts"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitzero"`
+}
+
+// Worker is a background goroutine started with Go.
+type Worker struct {
+	done chan struct{}
+
+	mu   sync.Mutex
+	info WorkerInfo
+}
+
+// Done is closed when the worker has exited.
+func (w *Worker) Done() <-chan struct{} {
+	return w.done
+}
+
+// Info returns the state of the worker.
+func (w *Worker) Info() WorkerInfo {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.info
+}
+
+// workers is the kit-wide inventory of running workers.
+var workers = struct {
+	sync.Mutex
+	next uint64
+	m    map[uint64]*Worker
+}{m: map[uint64]*Worker{}}
+
+// Go starts fn as a background worker named name, listed by Workers until
+// it returns. fn runs with a ctx labeled with WorkerLabel, so goroutines
+// it starts carry the label too, and must return once ctx is canceled.
+// A panic is logged and fn is called again after a backoff (see
+// WorkerOptions), so fn must be restartable; a returned error other than
+// the cancellation of ctx is logged and kept as the last error. The kit
+// starts its background goroutines (log replay, watchers, schedulers,
+// relays) this way:
+//
+//	w := lifecycle.Go(ctx, "outbox.relay", relay.Run)
+//	<-w.Done()
+//
+// WaitWorkers, run at shutdown by server.Runner, reports workers still
+// running.
+func Go(ctx context.Context, name string, fn func(ctx context.Context) error, opts ...WorkerOptions) *Worker {
+	var o WorkerOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.RestartBackoff <= 0 {
+		o.RestartBackoff = DefaultRestartBackoff
+	}
+	if o.MaxRestartBackoff <= 0 {
+		o.MaxRestartBackoff = DefaultMaxRestartBackoff
+	}
+
+	w := &Worker{done: make(chan struct{})}
+	workers.Lock()
+	workers.next++
+	w.info = WorkerInfo{ID: workers.next, Name: name, State: WorkerRunning, StartedAt: time.Now()}
+	workers.m[w.info.ID] = w
+	workers.Unlock()
+
+	go func() {
+		defer w.exit()
+		pprof.Do(ctx, pprof.Labels(WorkerLabel, name), func(ctx context.Context) {
+			w.loop(ctx, fn, o)
+		})
+	}()
+	return w
+}
+
+// loop calls fn until it returns without panicking or ctx is canceled.
+func (w *Worker) loop(ctx context.Context, fn func(context.Context) error, o WorkerOptions) {
+	backoff := o.RestartBackoff
+	for {
+		start := time.Now()
+		err, panicked := call(ctx, fn)
+		if err != nil && !(ctx.Err() != nil && errors.Is(err, ctx.Err())) {
+			info := w.record(err)
+			if !panicked {
+				slog.Error("Background worker failed", slog.String("worker", info.Name), slog.String("error", err.Error()))
+			}
+		}
+		if !panicked || ctx.Err() != nil {
+			return
+		}
+		if o.Once {
+			slog.Error("Background worker panicked", slog.String("worker", w.Info().Name), slog.String("error", err.Error()))
+			return
+		}
+
+		if time.Since(start) > o.MaxRestartBackoff {
+			backoff = o.RestartBackoff
+		}
+		info := w.setState(WorkerRestarting)
+		slog.Error("Background worker panicked; restarting",
+			slog.String("worker", info.Name),
+			slog.String("error", err.Error()),
+			slog.Int("restarts", info.Restarts),
+			slog.Duration("backoff", backoff))
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, o.MaxRestartBackoff)
+
+		w.mu.Lock()
+		w.info.Restarts++
+		w.info.State = WorkerRunning
+		w.mu.Unlock()
+	}
+}
+
+// call runs fn, returning a panic as an error (errors.FromPanic).
+func call(ctx context.Context, fn func(context.Context) error) (err error, panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			err, panicked = errors.FromPanic(r), true
+		}
+	}()
+	return fn(ctx), false
+}
+
+// record keeps err as the last error of w.
+func (w *Worker) record(err error) WorkerInfo {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.info.LastError, w.info.LastErrorAt = err.Error(), time.Now()
+	return w.info
+}
+
+func (w *Worker) setState(state string) WorkerInfo {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.info.State = state
+	return w.info
+}
+
+func (w *Worker) exit() {
+	workers.Lock()
+	delete(workers.m, w.info.ID)
+	workers.Unlock()
+	close(w.done)
+}
+
+// Workers returns the running workers by name and start time.
+func Workers() []WorkerInfo {
+	running := runningWorkers()
+	infos := make([]WorkerInfo, len(running))
+	for i, w := range running {
+		infos[i] = w.Info()
+	}
+	slices.SortFunc(infos, func(a, b WorkerInfo) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+	return infos
+}
+
+func runningWorkers() []*Worker {
+	workers.Lock()
+	defer workers.Unlock()
+	running := make([]*Worker, 0, len(workers.m))
+	for _, w := range workers.m {
+		running = append(running, w)
+	}
+	return running
+}
+
+// WaitWorkers waits until every worker started with Go has exited or ctx
+// is done. Workers still running then are logged with the stacks of
+// their goroutines and returned as an error naming them.
+func WaitWorkers(ctx context.Context) error {
+	for {
+		running := runningWorkers()
+		if len(running) == 0 {
+			return nil
+		}
+		for _, w := range running {
+			select {
+			case <-w.done:
+			case <-ctx.Done():
+				return stragglers()
+			}
+		}
+	}
+}
+
+// stragglers logs the running workers and returns the error naming them.
+func stragglers() error {
+	infos := Workers()
+	if len(infos) == 0 {
+		return nil
+	}
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name
+		slog.Error("Background worker did not exit",
+			slog.String("worker", info.Name),
+			slog.String("state", info.State),
+			slog.Time("started_at", info.StartedAt),
+			slog.String("stack", WorkerStacks(info.Name)))
+	}
+	return fmt.Errorf("%d background workers did not exit: %s", len(infos), strings.Join(names, ", "))
+}
+
+// WorkerStacks returns the goroutine stacks of the workers named name and
+// of the goroutines they started, from the goroutine profile.
+func WorkerStacks(name string) string {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return ""
+	}
+	label := strconv.Quote(WorkerLabel) + ":" + strconv.Quote(name)
+	var stacks []string
+	// Records are separated by blank lines; the label line follows the
+	// count line.
+	for _, record := range strings.Split(buf.String(), "\n\n") {
+		if strings.Contains(record, "# labels: ") && strings.Contains(record, label) {
+			stacks = append(stacks, strings.TrimSpace(record))
+		}
+	}
+	return strings.Join(stacks, "\n\n")
+}
@@ -0,0 +1,209 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Defaults of BatchOptions.
+const (
+	DefaultBatchSize          = 512
+	DefaultBatchFlushInterval = time.Second
+)
+
+// Record is a log record written as part of a batch (see BatchSink).
+type Record struct {
+	Time  time.Time
+	Level slog.Level
+	Msg   string
+	Attrs []slog.Attr
+}
+
+// BatchSink is a Sink writing several records at once cheaper than one by
+// one: FileSink encodes them into one buffer written at once, LokiSink and
+// ElasticsearchSink ship them in one request.
+type BatchSink interface {
+	Sink
+	// WriteBatch writes records in order.
+	WriteBatch(ctx context.Context, records []Record) error
+}
+
+// WriteBatch writes records to sink in order: in one call when sink is a
+// BatchSink, with one Write per record otherwise, joining their errors.
+func WriteBatch(ctx context.Context, sink Sink, records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if b, ok := sink.(BatchSink); ok {
+		return b.WriteBatch(ctx, records)
+	}
+	var errs []error
+	for _, r := range records {
+		if err := sink.Write(ctx, r.Level, r.Msg, r.Attrs); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// BatchOptions configure a BatchWriter.
+type BatchOptions struct {
+	// Size is the number of records buffered before they are flushed;
+	// default DefaultBatchSize.
+	Size int
+	// FlushInterval is the longest a record stays buffered; default
+	// DefaultBatchFlushInterval, negative to flush on size only.
+	FlushInterval time.Duration
+}
+
+// BatchWriter buffers records and writes them to the sink of its logger
+// in batches (see WriteBatch), for jobs logging a record per row where the
+// per-call path of a Logger dominates:
+//
+//	batch := logger.Batch()
+//	defer batch.Close()
+//	for _, row := range rows {
+//	    batch.Add(slog.LevelInfo, "Row imported", slog.Int64("id", row.ID))
+//	}
+//
+// Records are flushed when Size of them are buffered, when the oldest has
+// waited FlushInterval, and on Flush and Close. One flush runs at a time:
+// a caller of Add finding the buffer full while a flush is in flight
+// waits for it, so a slow sink slows the producers down instead of the
+// buffer growing.
+//
+// A BatchWriter is safe for concurrent use. Records keep the order of
+// their Add calls.
+type BatchWriter struct {
+	handler *SinkHandler
+	opts    BatchOptions
+
+	flushMu sync.Mutex // held for a whole flush, so batches stay in order
+
+	mu      sync.Mutex
+	pending []Record
+	spare   []Record // the buffer of the last flush, reused
+	timer   *time.Timer
+	closed  bool
+	err     error // of the last failed background flush
+}
+
+// Batch returns a BatchWriter writing to the sink of l, with the level,
+// attributes and groups of l.
+func (l *DefaultLogger) Batch(opts ...BatchOptions) *BatchWriter {
+	h, ok := l.slog.Handler().(*SinkHandler)
+	if !ok {
+		h = &SinkHandler{sink: l.sink, level: l.level}
+	}
+	var o BatchOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Size <= 0 {
+		o.Size = DefaultBatchSize
+	}
+	if o.FlushInterval == 0 {
+		o.FlushInterval = DefaultBatchFlushInterval
+	}
+	return &BatchWriter{handler: h, opts: o, pending: make([]Record, 0, o.Size)}
+}
+
+// Add buffers a record at level, dropping it below the level of the
+// logger. It flushes when the buffer is full; the error of that flush is
+// returned by the next Flush or Close. Records added after Close are
+// written directly.
+func (b *BatchWriter) Add(level slog.Level, msg string, attrs ...slog.Attr) {
+	h := b.handler
+	if level < h.level.Level() {
+		return
+	}
+	r := Record{Time: time.Now(), Level: level, Msg: msg, Attrs: make([]slog.Attr, 0, len(h.attrs)+len(attrs))}
+	for _, a := range h.attrs {
+		r.Attrs = append(r.Attrs, resolveAttr(a, level))
+	}
+	for _, a := range h.grouped(attrs) {
+		r.Attrs = append(r.Attrs, resolveAttr(a, level))
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		_ = h.sink.Write(context.Background(), r.Level, r.Msg, r.Attrs)
+		return
+	}
+	b.pending = append(b.pending, r)
+	full := len(b.pending) >= b.opts.Size
+	if !full && b.timer == nil && b.opts.FlushInterval > 0 {
+		b.timer = time.AfterFunc(b.opts.FlushInterval, b.flushTimed)
+	}
+	b.mu.Unlock()
+
+	if full {
+		b.keep(b.flush())
+	}
+}
+
+// Flush writes the buffered records and returns the first error since the
+// last Flush.
+func (b *BatchWriter) Flush() error {
+	err := b.flush()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		err = b.err
+	}
+	b.err = nil
+	return err
+}
+
+// Close flushes the buffered records and stops the timed flush. It does
+// not close the sink.
+func (b *BatchWriter) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	return b.Flush()
+}
+
+// flush writes the buffered records; one flush runs at a time.
+func (b *BatchWriter) flush() error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	batch := b.pending
+	b.pending = b.spare[:0]
+	b.spare = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.mu.Unlock()
+
+	err := WriteBatch(context.Background(), b.handler.sink, batch)
+
+	clear(batch)
+	b.mu.Lock()
+	b.spare = batch[:0]
+	b.mu.Unlock()
+	return err
+}
+
+func (b *BatchWriter) flushTimed() {
+	b.keep(b.flush())
+}
+
+// keep records the error of a background flush for the next Flush.
+func (b *BatchWriter) keep(err error) {
+	if err == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err == nil {
+		b.err = err
+	}
+}
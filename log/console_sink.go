@@ -2,6 +2,7 @@ package log
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
@@ -54,17 +55,18 @@ func (c *ConsoleSink) Write(ctx context.Context, level slog.Level, msg string, a
 }
 
 func (c *ConsoleSink) writeJSON(level slog.Level, msg string, attrs []slog.Attr) error {
-	json := fmt.Sprintf(`{"time":"%s","level":"%s","msg":"%s"`,
-		time.Now().Format(time.RFC3339),
-		level.String(),
-		msg)
-
-	for _, attr := range attrs {
-		json += fmt.Sprintf(`,"%s":"%v"`, attr.Key, attr.Value.Any())
+	entry := map[string]any{
+		"time":  time.Now().Format(time.RFC3339),
+		"level": level.String(),
+		"msg":   msg,
 	}
-	json += "}\n"
+	attrsMap(entry, attrs)
 
-	_, err := c.writer.Write([]byte(json))
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = c.writer.Write(append(data, '\n'))
 	return err
 }
 
@@ -74,12 +76,12 @@ func (c *ConsoleSink) writeText(level slog.Level, msg string, attrs []slog.Attr)
 		strings.ToUpper(level.String()),
 		msg)
 
-	for _, attr := range attrs {
-		text += fmt.Sprintf(" %s=%v", attr.Key, attr.Value.Any())
-	}
-	text += "\n"
+	var b strings.Builder
+	b.WriteString(text)
+	appendTextAttrs(&b, "", attrs, plain)
+	b.WriteByte('\n')
 
-	_, err := c.writer.Write([]byte(text))
+	_, err := c.writer.Write([]byte(b.String()))
 	return err
 }
 
@@ -107,12 +109,12 @@ func (c *ConsoleSink) writeColor(level slog.Level, msg string, attrs []slog.Attr
 		reset,
 		msg)
 
-	for _, attr := range attrs {
-		text += fmt.Sprintf(" \033[2m%s=%v\033[0m", attr.Key, attr.Value.Any())
-	}
-	text += "\n"
+	var b strings.Builder
+	b.WriteString(text)
+	appendTextAttrs(&b, "", attrs, func(s string) string { return "\033[2m" + s + "\033[0m" })
+	b.WriteByte('\n')
 
-	_, err := c.writer.Write([]byte(text))
+	_, err := c.writer.Write([]byte(b.String()))
 	return err
 }
 
@@ -0,0 +1,60 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+)
+
+// contextKey is the context key of the attached Logger.
+type contextKey struct{}
+
+// NewContext returns a context carrying logger, so code that only receives
+// ctx logs with the caller's fields. Goroutines keep them when given the
+// context, including one detached with utils.DetachContext:
+//
+//	ctx = log.NewContext(ctx, logger.With(slog.String("order_id", id)))
+//	go process(utils.DetachContext(ctx))
+//
+//	func process(ctx context.Context) {
+//	    log.InfoCtx(ctx, "Processing order") // includes order_id
+//	}
+func NewContext(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the Logger attached with NewContext, else the global
+// logger, else a Logger writing to slog.Default(). It never returns nil.
+func FromContext(ctx context.Context) Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(contextKey{}).(Logger); ok && logger != nil {
+			return logger
+		}
+	}
+	if logger := GetGlobalLogger(); logger != nil {
+		return logger
+	}
+	return &DefaultLogger{slog: slog.Default()}
+}
+
+// Context-aware logging functions, writing to FromContext(ctx).
+
+func DebugCtx(ctx context.Context, msg string, attrs ...slog.Attr) {
+	FromContext(ctx).Debug(msg, attrs...)
+}
+
+func InfoCtx(ctx context.Context, msg string, attrs ...slog.Attr) {
+	FromContext(ctx).Info(msg, attrs...)
+}
+
+func WarnCtx(ctx context.Context, msg string, attrs ...slog.Attr) {
+	FromContext(ctx).Warn(msg, attrs...)
+}
+
+func ErrorCtx(ctx context.Context, msg string, attrs ...slog.Attr) {
+	FromContext(ctx).Error(msg, attrs...)
+}
+
+// WithCtx returns FromContext(ctx) with attrs attached.
+func WithCtx(ctx context.Context, attrs ...slog.Attr) Logger {
+	return FromContext(ctx).With(attrs...)
+}
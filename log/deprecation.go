@@ -0,0 +1,29 @@
+package log
+
+import "github.com/NSObjects/go-kit/internal/deprecation"
+
+// DeprecationWarn reports a use of a deprecated path: the first use of key
+// in the process is logged at warn level with the caller of the function
+// calling DeprecationWarn, later ones are only counted (see
+// DeprecationCounts and metrics.RegisterDeprecationCollector).
+//
+//	// Deprecated: use Fetch.
+//	func Get(ctx context.Context, id string) (*Item, error) {
+//	    log.DeprecationWarn("store.Get", "store.Get is deprecated, use store.Fetch")
+//	    return Fetch(ctx, id)
+//	}
+func DeprecationWarn(key, msg string) {
+	deprecation.Warn(key, msg, 1)
+}
+
+// SilenceDeprecations stops logging DeprecationWarn, e.g. for noisy CI
+// runs (see config.LogConfig.SilenceDeprecations); uses are still counted.
+func SilenceDeprecations(on bool) {
+	deprecation.SetSilenced(on)
+}
+
+// DeprecationCounts returns the number of uses of each deprecated path
+// reported so far, by key.
+func DeprecationCounts() map[string]uint64 {
+	return deprecation.Counts()
+}
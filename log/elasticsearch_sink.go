@@ -46,25 +46,34 @@ func NewElasticsearchSink(cfg ElasticsearchSinkConfig) *ElasticsearchSink {
 }
 
 func (e *ElasticsearchSink) Write(ctx context.Context, level slog.Level, msg string, attrs []slog.Attr) error {
-	entry := map[string]any{
-		"@timestamp": time.Now().Format(time.RFC3339),
-		"level":      level.String(),
-		"message":    msg,
-	}
-
-	for _, attr := range attrs {
-		entry[attr.Key] = attr.Value.Any()
-	}
-
-	data, err := json.Marshal(entry)
-	if err != nil {
-		return err
-	}
+	return e.WriteBatch(ctx, []Record{{Time: time.Now(), Level: level, Msg: msg, Attrs: attrs}})
+}
 
+// WriteBatch implements BatchSink with a single bulk request of all
+// records, bounded by the earlier of the sink timeout and the deadline of ctx
+// (see deliveryContext).
+func (e *ElasticsearchSink) WriteBatch(ctx context.Context, records []Record) error {
 	// Build ES bulk API request
-	bulkData := fmt.Sprintf("{\"index\":{\"_index\":\"%s\"}}\n%s\n", e.index, string(data))
+	var bulkData bytes.Buffer
+	for _, r := range records {
+		entry := map[string]any{
+			"@timestamp": r.Time.Format(time.RFC3339),
+			"level":      r.Level.String(),
+			"message":    r.Msg,
+		}
+
+		attrsMap(entry, r.Attrs)
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&bulkData, "{\"index\":{\"_index\":\"%s\"}}\n%s\n", e.index, data)
+	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", e.url+"/_bulk", bytes.NewBufferString(bulkData))
+	ctx, cancel := deliveryContext(ctx, e.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", e.url+"/_bulk", &bulkData)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,206 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// attrValue converts v to a JSON-encodable value; groups become objects and
+// other values are expanded by Normalize.
+func attrValue(v slog.Value) any {
+	switch v.Kind() {
+	case slog.KindGroup:
+		m := make(map[string]any, len(v.Group()))
+		attrsMap(m, v.Group())
+		return m
+	case slog.KindString:
+		return truncate(v.String())
+	case slog.KindAny:
+		return Normalize(v.Any())
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().Format("2006-01-02T15:04:05.000Z07:00")
+	case slog.KindLogValuer:
+		return attrValue(v.Resolve())
+	}
+	return v.Any()
+}
+
+// attrsMap adds attrs to m as JSON-encodable values, masking sensitive
+// keys.
+func attrsMap(m map[string]any, attrs []slog.Attr) {
+	for _, a := range attrs {
+		if isRedactedKey(a.Key) && a.Value.Kind() != slog.KindGroup {
+			m[a.Key] = redacted
+			continue
+		}
+		m[a.Key] = attrValue(a.Value)
+	}
+}
+
+// appendJSONRecord appends r as a JSON line in the format of the JSON
+// sinks, without the intermediate map: attributes keep their order and a
+// repeated key is written twice instead of the last one winning.
+func appendJSONRecord(b []byte, r Record) []byte {
+	b = append(b, `{"time":"`...)
+	b = r.Time.AppendFormat(b, time.RFC3339)
+	b = append(b, `","level":"`...)
+	b = append(b, r.Level.String()...)
+	b = append(b, `","msg":`...)
+	b = appendJSONString(b, r.Msg)
+	for _, a := range r.Attrs {
+		b = append(b, ',')
+		b = appendJSONAttr(b, a)
+	}
+	return append(b, '}', '\n')
+}
+
+// appendJSONAttr appends a as "key":value, encoded as attrsMap does.
+func appendJSONAttr(b []byte, a slog.Attr) []byte {
+	b = appendJSONString(b, a.Key)
+	b = append(b, ':')
+	v := a.Value.Resolve()
+	if isRedactedKey(a.Key) && v.Kind() != slog.KindGroup {
+		return appendJSONString(b, redacted)
+	}
+	switch v.Kind() {
+	case slog.KindGroup:
+		b = append(b, '{')
+		for i, g := range v.Group() {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendJSONAttr(b, g)
+		}
+		return append(b, '}')
+	case slog.KindString:
+		return appendJSONString(b, truncate(v.String()))
+	case slog.KindInt64:
+		return strconv.AppendInt(b, v.Int64(), 10)
+	case slog.KindUint64:
+		return strconv.AppendUint(b, v.Uint64(), 10)
+	case slog.KindFloat64:
+		if f := v.Float64(); !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return strconv.AppendFloat(b, f, 'g', -1, 64)
+		}
+		return appendJSONString(b, strconv.FormatFloat(v.Float64(), 'g', -1, 64))
+	case slog.KindBool:
+		return strconv.AppendBool(b, v.Bool())
+	}
+	data, err := json.Marshal(attrValue(v))
+	if err != nil {
+		return appendJSONString(b, fmt.Sprintf("!ERROR:%v", err))
+	}
+	return append(b, data...)
+}
+
+// appendJSONString appends s as a JSON string, replacing invalid UTF-8.
+func appendJSONString(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= 0x20 && c != '"' && c != '\\' && c < utf8.RuneSelf {
+			i++
+			continue
+		}
+		if c < utf8.RuneSelf {
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, `\ufffd`...)
+			i++
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
+
+// appendTextAttrs appends attrs as " key=value" pairs, flattening groups,
+// structs, maps and slices into dotted keys (user.id=42 user.name=alice).
+func appendTextAttrs(b *strings.Builder, prefix string, attrs []slog.Attr, style func(string) string) {
+	for _, a := range attrs {
+		key := a.Key
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch {
+		case a.Value.Kind() == slog.KindGroup:
+			appendTextAttrs(b, key, a.Value.Group(), style)
+		case isRedactedKey(a.Key):
+			appendTextPair(b, key, redacted, style)
+		case a.Value.Kind() == slog.KindAny:
+			appendTextValue(b, key, Normalize(a.Value.Any()), style)
+		case a.Value.Kind() == slog.KindString:
+			appendTextPair(b, key, truncate(a.Value.String()), style)
+		default:
+			appendTextPair(b, key, a.Value.Any(), style)
+		}
+	}
+}
+
+// appendTextValue appends a normalized value, expanding maps and slices
+// into dotted keys in a stable order.
+func appendTextValue(b *strings.Builder, key string, v any, style func(string) string) {
+	switch t := v.(type) {
+	case map[string]any:
+		if len(t) == 0 {
+			appendTextPair(b, key, "{}", style)
+			return
+		}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			appendTextValue(b, key+"."+k, t[k], style)
+		}
+	case []any:
+		if len(t) == 0 {
+			appendTextPair(b, key, "[]", style)
+			return
+		}
+		for i, e := range t {
+			appendTextValue(b, key+"."+strconv.Itoa(i), e, style)
+		}
+	default:
+		appendTextPair(b, key, v, style)
+	}
+}
+
+func appendTextPair(b *strings.Builder, key string, v any, style func(string) string) {
+	b.WriteByte(' ')
+	b.WriteString(style(fmt.Sprintf("%s=%v", key, v)))
+}
+
+func plain(s string) string { return s }
@@ -0,0 +1,92 @@
+package log
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+)
+
+// ErrorKey is the attribute key used by Err.
+const ErrorKey = "error"
+
+// MaxStackFrames caps the number of stack frames logged by Err.
+var MaxStackFrames = 10
+
+// Err returns the standard attribute for logging an error. The value is
+// an object with msg, code, category and stack:
+//
+//	{"error": {"msg": "...", "code": 100301, "category": "database", "stack": ["pkg.Func file.go:42", ...]}}
+//
+// The kit's sinks only include the stack for records at Error level and
+// above; other slog handlers always receive it.
+func Err(err error) slog.Attr {
+	return slog.Any(ErrorKey, errorValue{err: err, stack: true})
+}
+
+// errorValue is the slog.LogValuer behind Err.
+type errorValue struct {
+	err   error
+	stack bool
+}
+
+func (v errorValue) LogValue() slog.Value {
+	if v.err == nil {
+		return slog.GroupValue(slog.String("msg", "<nil>"))
+	}
+	attrs := []slog.Attr{slog.String("msg", v.err.Error())}
+	if c := errors.GetCode(v.err); c != 0 {
+		attrs = append(attrs,
+			slog.Int("code", c),
+			slog.String("category", string(code.NewErrorInfo(v.err).Category)),
+		)
+	}
+	if v.stack {
+		if frames := stackFrames(errors.GetStackTrace(v.err), MaxStackFrames); len(frames) > 0 {
+			attrs = append(attrs, slog.Any("stack", frames))
+		}
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// stackFrames formats up to limit non-runtime frames as "func file:line".
+func stackFrames(pcs []uintptr, limit int) []string {
+	if len(pcs) == 0 || limit <= 0 {
+		return nil
+	}
+	var out []string
+	frames := runtime.CallersFrames(pcs)
+	for len(out) < limit {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			out = append(out, fmt.Sprintf("%s %s:%d", frame.Function, frame.File, frame.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	return out
+}
+
+// resolveAttr resolves LogValuers in a, dropping error stacks below Error
+// level.
+func resolveAttr(a slog.Attr, level slog.Level) slog.Attr {
+	if ev, ok := a.Value.Any().(errorValue); ok && a.Value.Kind() == slog.KindLogValuer {
+		ev.stack = level >= slog.LevelError
+		a.Value = ev.LogValue()
+		return a
+	}
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		resolved := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			resolved[i] = resolveAttr(ga, level)
+		}
+		a.Value = slog.GroupValue(resolved...)
+	}
+	return a
+}
@@ -1,10 +1,15 @@
 package log
 
 import (
+	"fmt"
 	"log/slog"
+	"path/filepath"
 	"strings"
+	"time"
 
+	kit "github.com/NSObjects/go-kit"
 	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/lifecycle"
 )
 
 // LogConfig extended configuration for logging.
@@ -16,11 +21,52 @@ type LogConfig struct {
 	File          FileSinkConfig          `json:"file" yaml:"file" toml:"file"`
 	Elasticsearch ElasticsearchSinkConfig `json:"elasticsearch" yaml:"elasticsearch" toml:"elasticsearch"`
 	Loki          LokiSinkConfig          `json:"loki" yaml:"loki" toml:"loki"`
+
+	// Fallback spills the records the Elasticsearch and Loki sinks fail to
+	// ship (see FallbackSink).
+	Fallback FallbackConfig `json:"fallback" yaml:"fallback" toml:"fallback"`
+
+	// Ring, when set, also receives every record, e.g. for the recent logs
+	// endpoint of the admin server.
+	Ring *RingSink `json:"-" yaml:"-" toml:"-"`
+
+	// SilenceDeprecations stops the warnings of DeprecationWarn.
+	SilenceDeprecations bool `json:"silence_deprecations" yaml:"silence_deprecations" toml:"silence_deprecations"`
+
+	// Stats, when set, records the activity of each sink under its name
+	// (console, file, elasticsearch, loki); see metrics.RegisterLogCollectors.
+	Stats *Stats `json:"-" yaml:"-" toml:"-"`
+
+	// Lifecycle, when set, gets a hook closing the sinks of the logger on
+	// stop (see CloseGlobal). Create the logger first so it closes last.
+	Lifecycle *lifecycle.Lifecycle `json:"-" yaml:"-" toml:"-"`
+}
+
+// FallbackConfig configures the spill files of the HTTP sinks. Each sink
+// spills to <dir>/<sink>.spill; an empty Dir disables spilling.
+type FallbackConfig struct {
+	Dir           string        `json:"dir" yaml:"dir" toml:"dir"`
+	MaxSize       int           `json:"max_size" yaml:"max_size" toml:"max_size"` // MB per sink
+	Replay        bool          `json:"replay" yaml:"replay" toml:"replay"`
+	ProbeInterval time.Duration `json:"probe_interval" yaml:"probe_interval" toml:"probe_interval"`
+}
+
+// wrap returns sink spilling to the file of name, or sink itself when
+// spilling is disabled.
+func (c FallbackConfig) wrap(name string, sink Sink) Sink {
+	if c.Dir == "" {
+		return sink
+	}
+	spill := NewFileSink(FileSinkConfig{Filename: filepath.Join(c.Dir, name+".spill"), MaxSize: c.MaxSize})
+	return NewFallbackSink(sink, spill, c.Replay, FallbackOptions{ProbeInterval: c.ProbeInterval})
 }
 
 // New creates a logger from the base configuration.
 func New(cfg config.LogConfig) Logger {
 	level := parseLevel(cfg.Level)
+	if cfg.SilenceDeprecations {
+		SilenceDeprecations(true)
+	}
 
 	// Default: console sink with text format
 	sink := NewConsoleSink(ConsoleSinkConfig{
@@ -32,39 +78,50 @@ func New(cfg config.LogConfig) Logger {
 }
 
 // NewFromLogConfig creates a logger from extended config with multiple sinks.
+// The level, console format and file sink default to the profile of env
+// (see kit.Profile); configured values win.
 func NewFromLogConfig(cfg LogConfig, env string) Logger {
+	profile := kit.ProfileFor(env)
+	if cfg.Level == "" {
+		cfg.Level = profile.LogLevel
+	}
+	if cfg.Format == "" {
+		cfg.Format = profile.LogFormat
+	}
 	level := parseLevel(cfg.Level)
+	if cfg.SilenceDeprecations {
+		SilenceDeprecations(true)
+	}
 
 	var sinks []Sink
 
 	// Console sink (always enabled by default)
-	if cfg.Console.Format != "" || cfg.Console.Output != "" {
-		sinks = append(sinks, NewConsoleSink(cfg.Console))
-	} else {
-		// Default based on environment
-		format := "color"
-		if env == "prod" {
-			format = "json"
-		}
-		sinks = append(sinks, NewConsoleSink(ConsoleSinkConfig{
-			Format: format,
-			Output: "stdout",
-		}))
+	console := cfg.Console
+	if console.Format == "" {
+		console.Format = cfg.Format
 	}
+	if console.Output == "" {
+		console.Output = "stdout"
+	}
+	sinks = append(sinks, InstrumentSink("console", NewConsoleSink(console), cfg.Stats))
 
-	// File sink (production/test)
-	if cfg.File.Filename != "" && (env == "prod" || env == "test") {
-		sinks = append(sinks, NewFileSink(cfg.File))
+	// File sink (profiles with file logging)
+	if cfg.File.Filename != "" && profile.FileLogging {
+		sinks = append(sinks, InstrumentSink("file", NewFileSink(cfg.File), cfg.Stats))
 	}
 
 	// Elasticsearch sink
 	if cfg.Elasticsearch.URL != "" {
-		sinks = append(sinks, NewElasticsearchSink(cfg.Elasticsearch))
+		sinks = append(sinks, InstrumentSink("elasticsearch", cfg.Fallback.wrap("elasticsearch", NewElasticsearchSink(cfg.Elasticsearch)), cfg.Stats))
 	}
 
 	// Loki sink
 	if cfg.Loki.URL != "" {
-		sinks = append(sinks, NewLokiSink(cfg.Loki))
+		sinks = append(sinks, InstrumentSink("loki", cfg.Fallback.wrap("loki", NewLokiSink(cfg.Loki)), cfg.Stats))
+	}
+
+	if cfg.Ring != nil {
+		sinks = append(sinks, InstrumentSink("ring", cfg.Ring, cfg.Stats))
 	}
 
 	// Create sink
@@ -78,10 +135,29 @@ func NewFromLogConfig(cfg LogConfig, env string) Logger {
 	// Create logger and set as global
 	logger := NewDefaultLogger(sink, level)
 	SetGlobalLogger(logger)
+	if cfg.Lifecycle != nil {
+		cfg.Lifecycle.Append(lifecycle.Hook{Name: "log", OnStop: CloseGlobal})
+	}
 
 	return logger
 }
 
+// ParseLevel parses a level name: debug, info, warn (or warning) or error,
+// in any case.
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q (expected debug, info, warn or error)", level)
+}
+
 // parseLevel parses a string level to slog.Level.
 func parseLevel(level string) slog.Level {
 	switch strings.ToLower(level) {
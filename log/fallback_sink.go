@@ -0,0 +1,319 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NSObjects/go-kit/lifecycle"
+)
+
+// Defaults of FallbackOptions.
+const (
+	DefaultProbeInterval = 5 * time.Second
+	DefaultReplayBatch   = 100
+)
+
+// FallbackOptions configure a FallbackSink.
+type FallbackOptions struct {
+	// ProbeInterval is how often a down primary is tried again; default
+	// DefaultProbeInterval.
+	ProbeInterval time.Duration
+	// ReplayBatch is the number of spilled records read at once when
+	// replaying; default DefaultReplayBatch.
+	ReplayBatch int
+}
+
+// FallbackSink writes to a primary sink, typically Loki or Elasticsearch,
+// and spills the records it fails to write to a file, with a spilled=true
+// attribute, so that an outage does not lose them:
+//
+//	sink := log.NewFallbackSink(log.NewLokiSink(lokiCfg),
+//	    log.NewFileSink(log.FileSinkConfig{Filename: "/var/spool/app/loki.spill", MaxSize: 50}), true)
+//
+// Once the primary fails, records go to the file until the primary
+// answers again; it is tried every ProbeInterval. With replay, the probe
+// is the re-shipping of the oldest spilled record: once it succeeds, the
+// spilled records are shipped to the primary in order and removed from the
+// file as they drain, and new records go to the primary again when the
+// file is empty.
+//
+// The file is bounded by the MaxSize of the FileSink: instead of rotating,
+// the oldest records are evicted (see Evicted). It is written as JSON
+// lines whatever its format, so that it can be replayed.
+type FallbackSink struct {
+	primary  Sink
+	fallback *FileSink
+	replay   bool
+	opts     FallbackOptions
+
+	down    atomic.Bool
+	probeAt atomic.Int64 // unix nanoseconds
+	evicted atomic.Uint64
+
+	cancel context.CancelFunc
+	done   <-chan struct{}
+	once   sync.Once
+}
+
+// NewFallbackSink creates a FallbackSink spilling the records primary
+// fails to write to fallback, and shipping them back to primary once it
+// recovers if replay is set.
+func NewFallbackSink(primary Sink, fallback *FileSink, replay bool, opts ...FallbackOptions) *FallbackSink {
+	var o FallbackOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.ProbeInterval <= 0 {
+		o.ProbeInterval = DefaultProbeInterval
+	}
+	if o.ReplayBatch <= 0 {
+		o.ReplayBatch = DefaultReplayBatch
+	}
+
+	fallback.mu.Lock()
+	fallback.format = "json"
+	pending := fallback.curSize > 0
+	fallback.mu.Unlock()
+
+	s := &FallbackSink{primary: primary, fallback: fallback, replay: replay, opts: o}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	if replay {
+		// Records spilled by a previous run are replayed first.
+		s.down.Store(pending)
+		s.done = lifecycle.Go(ctx, "log.fallback_replay", s.replayLoop).Done()
+	} else {
+		done := make(chan struct{})
+		close(done)
+		s.done = done
+	}
+	return s
+}
+
+// Write implements Sink. A record spilled to the file is not an error.
+func (s *FallbackSink) Write(ctx context.Context, level slog.Level, msg string, attrs []slog.Attr) error {
+	if s.tryPrimary(time.Now()) {
+		err := s.primary.Write(ctx, level, msg, attrs)
+		if err == nil {
+			if !s.replay {
+				s.down.Store(false)
+			}
+			return nil
+		}
+		if s.down.CompareAndSwap(false, true) {
+			s.probeAt.Store(time.Now().Add(s.opts.ProbeInterval).UnixNano())
+		}
+	}
+	return s.spill(time.Now(), level, msg, attrs)
+}
+
+// WriteBatch implements BatchSink: the batch goes to the primary in one
+// call when it is a BatchSink, and is spilled whole when that fails.
+// Otherwise records are written one by one as by Write.
+func (s *FallbackSink) WriteBatch(ctx context.Context, records []Record) error {
+	if _, ok := s.primary.(BatchSink); ok && s.tryPrimary(time.Now()) {
+		err := WriteBatch(ctx, s.primary, records)
+		if err == nil {
+			if !s.replay {
+				s.down.Store(false)
+			}
+			return nil
+		}
+		if s.down.CompareAndSwap(false, true) {
+			s.probeAt.Store(time.Now().Add(s.opts.ProbeInterval).UnixNano())
+		}
+		for _, r := range records {
+			if err := s.spill(r.Time, r.Level, r.Msg, r.Attrs); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var errs []error
+	for _, r := range records {
+		if err := s.Write(ctx, r.Level, r.Msg, r.Attrs); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close stops the replay and closes both sinks. Records still spilled stay
+// in the file and are replayed by the next FallbackSink on it.
+func (s *FallbackSink) Close() error {
+	s.once.Do(s.cancel)
+	<-s.done
+	perr := s.primary.Close()
+	if err := s.fallback.Close(); err != nil {
+		return err
+	}
+	return perr
+}
+
+// Down reports whether records are currently spilled instead of written to
+// the primary.
+func (s *FallbackSink) Down() bool {
+	return s.down.Load()
+}
+
+// Evicted returns the number of spilled records evicted to keep the file
+// within its size; those records are lost.
+func (s *FallbackSink) Evicted() uint64 {
+	return s.evicted.Load()
+}
+
+// tryPrimary reports whether a record should be written to the primary:
+// it is up, or, without replay, this record is the probe of the interval.
+func (s *FallbackSink) tryPrimary(now time.Time) bool {
+	if !s.down.Load() {
+		return true
+	}
+	if s.replay {
+		return false
+	}
+	at := s.probeAt.Load()
+	return now.UnixNano() >= at && s.probeAt.CompareAndSwap(at, now.Add(s.opts.ProbeInterval).UnixNano())
+}
+
+// spill appends the record to the file, evicting the oldest records when
+// it would exceed its size. A tenth of the size more is evicted so that a
+// full file is not rewritten on every record.
+func (s *FallbackSink) spill(t time.Time, level slog.Level, msg string, attrs []slog.Attr) error {
+	f := s.fallback
+	data, err := f.formatJSON(t, level, msg, append(attrs[:len(attrs):len(attrs)], slog.Bool("spilled", true)))
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if int64(len(data)) > f.maxSize {
+		s.evicted.Add(1)
+		return nil
+	}
+	if excess := f.curSize + int64(len(data)) - f.maxSize; excess > 0 {
+		lines, _, err := f.trimHead(excess + f.maxSize/10)
+		if err != nil {
+			return err
+		}
+		s.evicted.Add(uint64(lines))
+	}
+	n, err := f.file.Write(data)
+	f.curSize += int64(n)
+	return err
+}
+
+// replayLoop drains the file every ProbeInterval until ctx is done.
+func (s *FallbackSink) replayLoop(ctx context.Context) error {
+	ticker := time.NewTicker(s.opts.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			s.drain(ctx)
+		}
+	}
+}
+
+// drain ships the spilled records to the primary in order, removing them
+// from the file, until the file is empty or the primary fails.
+func (s *FallbackSink) drain(ctx context.Context) {
+	f := s.fallback
+	for ctx.Err() == nil {
+		lines, mark, err := f.readHead(s.opts.ReplayBatch)
+		if err != nil {
+			return
+		}
+		if len(lines) == 0 {
+			s.down.Store(false)
+			return
+		}
+
+		var shipped int64
+		failed := false
+		for _, line := range lines {
+			if level, msg, attrs, ok := parseSpilled(line); ok {
+				if err := s.primary.Write(ctx, level, msg, attrs); err != nil {
+					failed = true
+					break
+				}
+			}
+			shipped += int64(len(line))
+		}
+
+		f.mu.Lock()
+		// Records evicted meanwhile are gone from the head already.
+		if n := shipped - (f.trimmed - mark); n > 0 {
+			_, _, err = f.trimHead(n)
+		}
+		f.mu.Unlock()
+		if failed || err != nil {
+			s.down.Store(true)
+			return
+		}
+	}
+}
+
+// parseSpilled decodes a line of the file back into a record. The time of
+// the record is kept as its time attribute; lines that are not JSON
+// records are skipped.
+func parseSpilled(line []byte) (slog.Level, string, []slog.Attr, bool) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var entry map[string]any
+	if err := dec.Decode(&entry); err != nil {
+		return 0, "", nil, false
+	}
+	msg, ok := entry["msg"].(string)
+	if !ok {
+		return 0, "", nil, false
+	}
+	var level slog.Level
+	if name, ok := entry["level"].(string); ok {
+		_ = level.UnmarshalText([]byte(name))
+	}
+	delete(entry, "msg")
+	delete(entry, "level")
+
+	keys := make([]string, 0, len(entry))
+	for k := range entry {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, slog.Any(k, spilledValue(entry[k])))
+	}
+	return level, msg, attrs, true
+}
+
+// spilledValue turns the numbers of a decoded value back into integers
+// where they are, so that they are not shipped as strings or rounded.
+func spilledValue(v any) any {
+	switch t := v.(type) {
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n
+		}
+		f, _ := t.Float64()
+		return f
+	case map[string]any:
+		for k, e := range t {
+			t[k] = spilledValue(e)
+		}
+	case []any:
+		for i, e := range t {
+			t[i] = spilledValue(e)
+		}
+	}
+	return v
+}
@@ -1,6 +1,7 @@
 package log
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
@@ -20,6 +22,7 @@ type FileSink struct {
 	maxSize  int64 // bytes
 	curSize  int64
 	format   string
+	trimmed  int64 // bytes removed from the head by trimHead
 }
 
 // FileSinkConfig configuration for file output.
@@ -79,9 +82,9 @@ func (f *FileSink) Write(ctx context.Context, level slog.Level, msg string, attr
 
 	switch f.format {
 	case "json":
-		data, err = f.formatJSON(level, msg, attrs)
+		data, err = f.formatJSON(time.Now(), level, msg, attrs)
 	default:
-		data, err = f.formatText(level, msg, attrs)
+		data, err = f.formatText(time.Now(), level, msg, attrs)
 	}
 
 	if err != nil {
@@ -100,16 +103,14 @@ func (f *FileSink) Write(ctx context.Context, level slog.Level, msg string, attr
 	return err
 }
 
-func (f *FileSink) formatJSON(level slog.Level, msg string, attrs []slog.Attr) ([]byte, error) {
+func (f *FileSink) formatJSON(t time.Time, level slog.Level, msg string, attrs []slog.Attr) ([]byte, error) {
 	entry := map[string]any{
-		"time":  time.Now().Format(time.RFC3339),
+		"time":  t.Format(time.RFC3339),
 		"level": level.String(),
 		"msg":   msg,
 	}
 
-	for _, attr := range attrs {
-		entry[attr.Key] = attr.Value.Any()
-	}
+	attrsMap(entry, attrs)
 
 	data, err := json.Marshal(entry)
 	if err != nil {
@@ -118,18 +119,71 @@ func (f *FileSink) formatJSON(level slog.Level, msg string, attrs []slog.Attr) (
 	return append(data, '\n'), nil
 }
 
-func (f *FileSink) formatText(level slog.Level, msg string, attrs []slog.Attr) ([]byte, error) {
+func (f *FileSink) formatText(t time.Time, level slog.Level, msg string, attrs []slog.Attr) ([]byte, error) {
 	text := fmt.Sprintf("%s %s %s",
-		time.Now().Format("2006-01-02 15:04:05"),
+		t.Format("2006-01-02 15:04:05"),
 		level.String(),
 		msg)
 
-	for _, attr := range attrs {
-		text += fmt.Sprintf(" %s=%v", attr.Key, attr.Value.Any())
+	var b strings.Builder
+	b.WriteString(text)
+	appendTextAttrs(&b, "", attrs, plain)
+	b.WriteByte('\n')
+
+	return []byte(b.String()), nil
+}
+
+// maxPooledBuffer bounds the buffers returned to batchBuffers, so that a
+// burst of large batches does not pin their memory.
+const maxPooledBuffer = 4 << 20
+
+// batchBuffers are the encoding buffers of WriteBatch.
+var batchBuffers = sync.Pool{New: func() any { b := make([]byte, 0, 64<<10); return &b }}
+
+// WriteBatch implements BatchSink: records are encoded into one pooled
+// buffer written at once, split only where the file rotates.
+func (f *FileSink) WriteBatch(ctx context.Context, records []Record) error {
+	bp := batchBuffers.Get().(*[]byte)
+	buf := (*bp)[:0]
+	defer func() {
+		if cap(buf) <= maxPooledBuffer {
+			*bp = buf[:0]
+			batchBuffers.Put(bp)
+		}
+	}()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, r := range records {
+		start := len(buf)
+		if f.format == "json" {
+			buf = appendJSONRecord(buf, r)
+		} else {
+			data, err := f.formatText(r.Time, r.Level, r.Msg, r.Attrs)
+			if err != nil {
+				return err
+			}
+			buf = append(buf, data...)
+		}
+		if start > 0 && f.curSize+int64(len(buf)) > f.maxSize {
+			n, err := f.file.Write(buf[:start])
+			f.curSize += int64(n)
+			if err != nil {
+				return err
+			}
+			buf = buf[:copy(buf, buf[start:])]
+		}
+		if f.curSize+int64(len(buf)) > f.maxSize {
+			if err := f.rotate(); err != nil {
+				return err
+			}
+		}
 	}
-	text += "\n"
 
-	return []byte(text), nil
+	n, err := f.file.Write(buf)
+	f.curSize += int64(n)
+	return err
 }
 
 func (f *FileSink) rotate() error {
@@ -155,6 +209,92 @@ func (f *FileSink) rotate() error {
 	return nil
 }
 
+// trimHead removes whole lines from the head of the file, at least n
+// bytes of them or all of them, and returns how many lines and bytes were
+// removed. f.mu must be held.
+func (f *FileSink) trimHead(n int64) (int, int64, error) {
+	src, err := os.Open(f.filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer src.Close()
+
+	r := bufio.NewReader(src)
+	var lines int
+	var removed int64
+	for removed < n {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			lines++
+			removed += int64(len(line))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	tmp := f.filename + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := io.Copy(dst, r); err != nil {
+		dst.Close()
+		return 0, 0, err
+	}
+	if err := dst.Close(); err != nil {
+		return 0, 0, err
+	}
+	if err := f.file.Close(); err != nil {
+		return 0, 0, err
+	}
+	if err := os.Rename(tmp, f.filename); err != nil {
+		return 0, 0, err
+	}
+	file, err := os.OpenFile(f.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return 0, 0, err
+	}
+	f.file = file
+	f.curSize -= removed
+	f.trimmed += removed
+	return lines, removed, nil
+}
+
+// readHead returns up to max whole lines from the head of the file, with
+// the number of bytes trimmed from the head so far.
+func (f *FileSink) readHead(max int) ([][]byte, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.curSize == 0 {
+		return nil, f.trimmed, nil
+	}
+	src, err := os.Open(f.filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer src.Close()
+
+	r := bufio.NewReader(src)
+	var lines [][]byte
+	for len(lines) < max {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			lines = append(lines, line)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return lines, f.trimmed, nil
+}
+
 func (f *FileSink) Close() error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -1,6 +1,7 @@
 package log
 
 import (
+	"context"
 	"log/slog"
 	"sync"
 )
@@ -24,6 +25,24 @@ func GetGlobalLogger() Logger {
 	return globalLogger
 }
 
+// CloseGlobal closes the sinks of the global logger, flushing buffered
+// records, or returns when ctx is done. Loggers without a Close method are
+// left alone.
+func CloseGlobal(ctx context.Context) error {
+	c, ok := GetGlobalLogger().(interface{ Close() error })
+	if !ok {
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() { done <- c.Close() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Global logging functions
 
 func Debug(msg string, attrs ...slog.Attr) {
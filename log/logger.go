@@ -3,9 +3,11 @@ package log
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
+	"time"
 )
 
 // Logger is the unified logging interface.
@@ -24,6 +26,8 @@ type Logger interface {
 
 	With(attrs ...slog.Attr) Logger
 	WithGroup(name string) Logger
+	// WithError returns a Logger with Err(err) attached.
+	WithError(err error) Logger
 }
 
 // Sink is the log output target abstraction.
@@ -32,6 +36,28 @@ type Sink interface {
 	Close() error
 }
 
+type detachedDeliveryKey struct{}
+
+// DetachedDelivery marks ctx as the context of a write made off the
+// request path, e.g. by an asynchronous sink forwarding buffered records:
+// remote sinks then bound the write by their own timeout only, instead of
+// the earlier of it and the deadline of ctx, so records of a request that
+// timed out are still shipped.
+func DetachedDelivery(ctx context.Context) context.Context {
+	return context.WithValue(ctx, detachedDeliveryKey{}, true)
+}
+
+// deliveryContext returns the context of a write of a remote sink, bounded
+// by the sink timeout. A synchronous write on the request path keeps the
+// deadline and cancellation of ctx, so logging never outlives the
+// request; a write marked by DetachedDelivery drops them.
+func deliveryContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if detached, _ := ctx.Value(detachedDeliveryKey{}).(bool); detached {
+		ctx = context.WithoutCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
 // MultiSink writes to multiple sinks.
 type MultiSink struct {
 	sinks []Sink
@@ -51,29 +77,66 @@ func (m *MultiSink) Write(ctx context.Context, level slog.Level, msg string, att
 	return nil
 }
 
-func (m *MultiSink) Close() error {
+// WriteBatch implements BatchSink, passing records to each sink in one
+// batch (see WriteBatch).
+func (m *MultiSink) WriteBatch(ctx context.Context, records []Record) error {
 	for _, sink := range m.sinks {
-		sink.Close()
+		if err := WriteBatch(ctx, sink, records); err != nil {
+			continue // Log error but don't stop other sinks
+		}
 	}
 	return nil
 }
 
+// Close closes every sink and returns their errors joined.
+func (m *MultiSink) Close() error {
+	var errs []error
+	for _, sink := range m.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LevelController reads and changes the minimum level of a logger at
+// runtime, e.g. from an admin endpoint. DefaultLogger implements it.
+type LevelController interface {
+	Level() slog.Level
+	SetLevel(level slog.Level)
+}
+
 // DefaultLogger is the default logger implementation.
 type DefaultLogger struct {
-	slog *slog.Logger
-	sink Sink
-	mu   sync.RWMutex
+	slog  *slog.Logger
+	sink  Sink
+	level *slog.LevelVar
+	mu    sync.RWMutex
 }
 
 // NewDefaultLogger creates a logger with the given sink and level.
 func NewDefaultLogger(sink Sink, level slog.Level) *DefaultLogger {
-	handler := &SinkHandler{sink: sink, level: level}
+	lv := new(slog.LevelVar)
+	lv.Set(level)
+	handler := &SinkHandler{sink: sink, level: lv}
 	return &DefaultLogger{
-		slog: slog.New(handler),
-		sink: sink,
+		slog:  slog.New(handler),
+		sink:  sink,
+		level: lv,
 	}
 }
 
+// Level returns the minimum level of l.
+func (l *DefaultLogger) Level() slog.Level {
+	return l.level.Level()
+}
+
+// SetLevel changes the minimum level of l and of the loggers derived from
+// it with With and WithGroup.
+func (l *DefaultLogger) SetLevel(level slog.Level) {
+	l.level.Set(level)
+}
+
 func (l *DefaultLogger) Debug(msg string, attrs ...slog.Attr) {
 	l.slog.LogAttrs(context.Background(), slog.LevelDebug, msg, attrs...)
 }
@@ -115,46 +178,95 @@ func (l *DefaultLogger) Fatalf(format string, args ...any) {
 }
 
 func (l *DefaultLogger) With(attrs ...slog.Attr) Logger {
-	args := make([]any, 0, len(attrs)*2)
+	args := make([]any, 0, len(attrs))
 	for _, attr := range attrs {
-		args = append(args, attr.Key, attr.Value.Any())
+		args = append(args, attr)
 	}
 	return &DefaultLogger{
-		slog: l.slog.With(args...),
-		sink: l.sink,
+		slog:  l.slog.With(args...),
+		sink:  l.sink,
+		level: l.level,
 	}
 }
 
 func (l *DefaultLogger) WithGroup(name string) Logger {
 	return &DefaultLogger{
-		slog: l.slog.WithGroup(name),
-		sink: l.sink,
+		slog:  l.slog.WithGroup(name),
+		sink:  l.sink,
+		level: l.level,
 	}
 }
 
+func (l *DefaultLogger) WithError(err error) Logger {
+	return l.With(Err(err))
+}
+
+// Slog returns the underlying slog.Logger, e.g. for slog.SetDefault.
+func (l *DefaultLogger) Slog() *slog.Logger {
+	return l.slog
+}
+
+// Close closes the sink of the logger, flushing buffered records.
+func (l *DefaultLogger) Close() error {
+	return l.sink.Close()
+}
+
 // SinkHandler implements slog.Handler.
 type SinkHandler struct {
-	sink  Sink
-	level slog.Level
+	sink   Sink
+	level  slog.Leveler
+	attrs  []slog.Attr
+	groups []string
 }
 
 func (h *SinkHandler) Enabled(_ context.Context, level slog.Level) bool {
-	return level >= h.level
+	return level >= h.level.Level()
 }
 
 func (h *SinkHandler) Handle(ctx context.Context, r slog.Record) error {
-	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	own := make([]slog.Attr, 0, r.NumAttrs())
 	r.Attrs(func(a slog.Attr) bool {
-		attrs = append(attrs, a)
+		own = append(own, a)
 		return true
 	})
+	attrs := make([]slog.Attr, 0, len(h.attrs)+len(own))
+	for _, a := range h.attrs {
+		attrs = append(attrs, resolveAttr(a, r.Level))
+	}
+	for _, a := range h.grouped(own) {
+		attrs = append(attrs, resolveAttr(a, r.Level))
+	}
 	return h.sink.Write(ctx, r.Level, r.Message, attrs)
 }
 
 func (h *SinkHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return &SinkHandler{sink: h.sink, level: h.level}
+	return &SinkHandler{
+		sink:   h.sink,
+		level:  h.level,
+		attrs:  append(append([]slog.Attr(nil), h.attrs...), h.grouped(attrs)...),
+		groups: h.groups,
+	}
 }
 
 func (h *SinkHandler) WithGroup(name string) slog.Handler {
-	return &SinkHandler{sink: h.sink, level: h.level}
+	if name == "" {
+		return h
+	}
+	return &SinkHandler{
+		sink:   h.sink,
+		level:  h.level,
+		attrs:  h.attrs,
+		groups: append(append([]string(nil), h.groups...), name),
+	}
+}
+
+// grouped nests attrs inside the handler's open groups.
+func (h *SinkHandler) grouped(attrs []slog.Attr) []slog.Attr {
+	if len(attrs) == 0 {
+		return nil
+	}
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		attrs = []slog.Attr{{Key: h.groups[i], Value: slog.GroupValue(attrs...)}}
+	}
+	return attrs
 }
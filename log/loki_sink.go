@@ -48,24 +48,31 @@ func NewLokiSink(cfg LokiSinkConfig) *LokiSink {
 }
 
 func (l *LokiSink) Write(ctx context.Context, level slog.Level, msg string, attrs []slog.Attr) error {
-	// Build log entry
-	entry := map[string]any{
-		"level":   level.String(),
-		"message": msg,
-	}
+	return l.WriteBatch(ctx, []Record{{Time: time.Now(), Level: level, Msg: msg, Attrs: attrs}})
+}
 
-	for _, attr := range attrs {
-		entry[attr.Key] = attr.Value.Any()
-	}
+// WriteBatch implements BatchSink with a single push of all records,
+// bounded by the earlier of the sink timeout and the deadline of ctx
+// (see deliveryContext).
+func (l *LokiSink) WriteBatch(ctx context.Context, records []Record) error {
+	values := make([][]string, 0, len(records))
+	for _, r := range records {
+		// Build log entry
+		entry := map[string]any{
+			"level":   r.Level.String(),
+			"message": r.Msg,
+		}
+
+		attrsMap(entry, r.Attrs)
 
-	entryJSON, _ := json.Marshal(entry)
+		entryJSON, _ := json.Marshal(entry)
+		values = append(values, []string{fmt.Sprintf("%d", r.Time.UnixNano()), string(entryJSON)})
+	}
 
 	// Build Loki push API request
 	lokiEntry := map[string]any{
 		"stream": l.labels,
-		"values": [][]string{
-			{fmt.Sprintf("%d", time.Now().UnixNano()), string(entryJSON)},
-		},
+		"values": values,
 	}
 
 	payload := map[string]any{
@@ -77,6 +84,8 @@ func (l *LokiSink) Write(ctx context.Context, level slog.Level, msg string, attr
 		return err
 	}
 
+	ctx, cancel := deliveryContext(ctx, l.timeout)
+	defer cancel()
 	req, err := http.NewRequestWithContext(ctx, "POST", l.url+"/loki/api/v1/push", bytes.NewBuffer(data))
 	if err != nil {
 		return err
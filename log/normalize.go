@@ -0,0 +1,191 @@
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Bounds applied by Normalize so a single attribute cannot blow up a record.
+var (
+	// MaxValueDepth caps how deep structs, maps and slices are expanded;
+	// deeper values are logged in their %v form.
+	MaxValueDepth = 4
+	// MaxValueElements caps the entries kept per map, slice or struct.
+	MaxValueElements = 32
+	// MaxStringLength caps string values, in bytes.
+	MaxStringLength = 1024
+)
+
+// RedactedKeys are key fragments whose values are masked wherever they
+// appear: attribute keys, map keys and struct field names (matched
+// case-insensitively as substrings). Struct fields tagged
+// `sensitive:"true"` are masked too.
+var RedactedKeys = []string{"password", "secret", "token", "authorization", "cookie", "apikey", "api_key", "private_key"}
+
+// redacted replaces masked values.
+const redacted = "******"
+
+// truncatedKey holds the number of entries dropped from a map or struct.
+const truncatedKey = "_truncated"
+
+// Normalize converts v into a bounded, JSON-encodable value: structs (keyed
+// by their json tag names), maps and slices become map[string]any and
+// []any, errors and fmt.Stringers their string forms, and sensitive keys
+// are masked. Limits come from MaxValueDepth, MaxValueElements and
+// MaxStringLength.
+func Normalize(v any) any {
+	return normalize(reflect.ValueOf(v), 0)
+}
+
+func normalize(rv reflect.Value, depth int) any {
+	if !rv.IsValid() {
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		if rv.IsNil() {
+			return nil
+		}
+	}
+	if rv.CanInterface() {
+		switch t := rv.Interface().(type) {
+		case string:
+			return truncate(t)
+		case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			return t
+		case error:
+			return truncate(t.Error())
+		case fmt.Stringer:
+			return truncate(t.String())
+		case []byte:
+			return truncate(string(t))
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return normalize(rv.Elem(), depth)
+	case reflect.String:
+		return truncate(rv.String())
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint()
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		if depth >= MaxValueDepth {
+			return truncate(fmt.Sprintf("%v", rv))
+		}
+	default:
+		return truncate(fmt.Sprintf("%v", rv))
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		m := make(map[string]any)
+		normalizeStruct(m, rv, depth)
+		return m
+	case reflect.Map:
+		m := make(map[string]any, min(rv.Len(), MaxValueElements))
+		iter := rv.MapRange()
+		for iter.Next() {
+			if len(m) >= MaxValueElements {
+				m[truncatedKey] = rv.Len() - MaxValueElements
+				break
+			}
+			key := fmt.Sprint(iter.Key().Interface())
+			if isRedactedKey(key) {
+				m[key] = redacted
+				continue
+			}
+			m[key] = normalize(iter.Value(), depth+1)
+		}
+		return m
+	default: // slice, array
+		n := min(rv.Len(), MaxValueElements)
+		s := make([]any, n, n+1)
+		for i := range n {
+			s[i] = normalize(rv.Index(i), depth+1)
+		}
+		if rv.Len() > n {
+			s = append(s, fmt.Sprintf("...(%d more)", rv.Len()-n))
+		}
+		return s
+	}
+}
+
+// normalizeStruct adds the exported fields of rv to m, inlining embedded
+// structs without a json name like encoding/json does.
+func normalizeStruct(m map[string]any, rv reflect.Value, depth int) {
+	rt := rv.Type()
+	for i := range rt.NumField() {
+		field := rt.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		fv := rv.Field(i)
+		if field.Anonymous && name == "" {
+			if fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				normalizeStruct(m, fv, depth)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(opts, "omitempty") && fv.IsZero() {
+			continue
+		}
+		if len(m) >= MaxValueElements {
+			m[truncatedKey] = rt.NumField() - i
+			return
+		}
+		if field.Tag.Get("sensitive") == "true" || isRedactedKey(name) {
+			m[name] = redacted
+			continue
+		}
+		m[name] = normalize(fv, depth+1)
+	}
+}
+
+// truncate cuts s to MaxStringLength bytes on a rune boundary.
+func truncate(s string) string {
+	if MaxStringLength <= 0 || len(s) <= MaxStringLength {
+		return s
+	}
+	cut := MaxStringLength
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "...(" + strconv.Itoa(len(s)-cut) + " bytes truncated)"
+}
+
+// isRedactedKey reports whether key matches RedactedKeys.
+func isRedactedKey(key string) bool {
+	k := strings.ToLower(key)
+	for _, hint := range RedactedKeys {
+		if strings.Contains(k, hint) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultRingSize is the capacity of a RingSink when none is given.
+const DefaultRingSize = 1000
+
+// RingRecord is a record kept by RingSink.
+type RingRecord struct {
+	Time  time.Time      `json:"time"`
+	Level string         `json:"level"`
+	Msg   string         `json:"msg"`
+	Attrs map[string]any `json:"attrs,omitempty"`
+
+	level slog.Level
+}
+
+// RingSink keeps the most recent records in memory, e.g. for the recent
+// logs endpoint of the admin server. Sensitive attributes are masked as in
+// the other sinks.
+type RingSink struct {
+	mu      sync.Mutex
+	records []RingRecord
+	next    int
+	full    bool
+}
+
+// NewRingSink creates a RingSink keeping the last size records (default
+// DefaultRingSize).
+func NewRingSink(size int) *RingSink {
+	if size <= 0 {
+		size = DefaultRingSize
+	}
+	return &RingSink{records: make([]RingRecord, size)}
+}
+
+// Write implements Sink.
+func (r *RingSink) Write(_ context.Context, level slog.Level, msg string, attrs []slog.Attr) error {
+	rec := RingRecord{Time: time.Now(), Level: level.String(), Msg: msg, level: level}
+	if len(attrs) > 0 {
+		rec.Attrs = make(map[string]any, len(attrs))
+		attrsMap(rec.Attrs, attrs)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records[r.next] = rec
+	r.next = (r.next + 1) % len(r.records)
+	if r.next == 0 {
+		r.full = true
+	}
+	return nil
+}
+
+// Close implements Sink.
+func (r *RingSink) Close() error { return nil }
+
+// Records returns up to limit records at or above minLevel, newest first.
+// A limit <= 0 returns all of them.
+func (r *RingSink) Records(minLevel slog.Level, limit int) []RingRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.next
+	if r.full {
+		n = len(r.records)
+	}
+	out := make([]RingRecord, 0, min(n, max(limit, 0)))
+	for i := 0; i < n && (limit <= 0 || len(out) < limit); i++ {
+		rec := r.records[(r.next-1-i+len(r.records))%len(r.records)]
+		if rec.level >= minLevel {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
@@ -0,0 +1,388 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Reasons a record is dropped (see Stats.Drop).
+const (
+	// DropSinkError is a record lost because its sink failed to write it.
+	DropSinkError = "sink_error"
+	// DropQueueFull is a record discarded because a sink queue was full.
+	DropQueueFull = "queue_full"
+	// DropSampled is a record discarded by sampling.
+	DropSampled = "sampled"
+)
+
+// FlushBuckets are the upper bounds, in seconds, of the flush duration
+// histogram of SinkStats.
+var FlushBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+// DeliveryBuckets are the upper bounds, in seconds, of the delivery
+// latency histogram of SinkStats. Batched records wait for their batch,
+// hence the longer tail.
+var DeliveryBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// DefaultSlowSinkWindow is the default StatsOptions.SlowWindow.
+const DefaultSlowSinkWindow = time.Minute
+
+// slowSinkSamples bounds the latencies kept per window; past it, a
+// uniform sample of the window is kept.
+const slowSinkSamples = 4096
+
+// StatsOptions configure Stats.
+type StatsOptions struct {
+	// SlowThreshold reports a SlowSinkError to ErrorHandler for a sink
+	// whose p99 delivery latency over a SlowWindow exceeds it; 0 disables
+	// the check.
+	SlowThreshold time.Duration
+	// SlowWindow is the window of the p99; default DefaultSlowSinkWindow.
+	SlowWindow time.Duration
+	// ErrorHandler, when set, receives the failed writes of the sinks and
+	// the SlowSinkErrors. It must not log through the pipeline it watches;
+	// it is called outside the locks of Stats.
+	ErrorHandler func(sink string, err error)
+}
+
+// SlowSinkError reports a sink whose p99 delivery latency over a window
+// exceeded StatsOptions.SlowThreshold.
+type SlowSinkError struct {
+	Sink      string
+	P99       time.Duration
+	Threshold time.Duration
+	Window    time.Duration
+	Samples   int
+}
+
+func (e *SlowSinkError) Error() string {
+	return fmt.Sprintf("log sink %s slow: p99 delivery latency %s over %s (%d records) exceeds %s",
+		e.Sink, e.P99, e.Window, e.Samples, e.Threshold)
+}
+
+// StatsProvider returns a snapshot of the log pipeline, one entry per
+// sink, for exporters such as metrics.RegisterLogCollectors.
+type StatsProvider interface {
+	LogStats() []SinkStats
+}
+
+// SinkStats is the snapshot of a sink. Counters are totals since the
+// Stats was created.
+type SinkStats struct {
+	Sink string
+	// Records counts the records written, by level ("DEBUG", "INFO", ...).
+	Records map[string]uint64
+	// Dropped counts the records lost, by reason (DropSinkError, ...).
+	Dropped map[string]uint64
+	// Errors counts failed writes and flushes.
+	Errors uint64
+	// QueueDepth is the number of records waiting to be written.
+	QueueDepth int
+	Flush      FlushStats
+	// Delivery is the histogram of the latencies of the records written,
+	// from their creation to the end of their write, on DeliveryBuckets.
+	Delivery FlushStats
+	// DeliveryP99 is the p99 delivery latency of the last complete window
+	// of StatsOptions.SlowWindow; 0 without SlowThreshold.
+	DeliveryP99 time.Duration
+}
+
+// FlushStats is a histogram of durations: of a sink's flushes on
+// FlushBuckets, or of its deliveries on DeliveryBuckets.
+type FlushStats struct {
+	Count uint64
+	// Sum is the total duration in seconds.
+	Sum float64
+	// Buckets maps each bound to the cumulative count of durations at most
+	// that long.
+	Buckets map[float64]uint64
+}
+
+// Stats records the activity of the sinks of a log pipeline. Sinks report
+// through it; it is safe for concurrent use and implements StatsProvider.
+type Stats struct {
+	opts  StatsOptions
+	mu    sync.Mutex
+	sinks map[string]*sinkCounters
+}
+
+type sinkCounters struct {
+	records    map[string]uint64
+	dropped    map[string]uint64
+	errors     uint64
+	queueDepth int
+	flush      histogram
+	delivery   histogram
+
+	windowStart time.Time
+	windowSeen  int
+	window      []time.Duration // sample of the latencies of the window
+	p99         time.Duration
+}
+
+// histogram is a cumulative histogram on bounds.
+type histogram struct {
+	bounds []float64
+	count  uint64
+	sum    float64
+	cum    []uint64
+}
+
+func newHistogram(bounds []float64) histogram {
+	return histogram{bounds: bounds, cum: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	h.count++
+	h.sum += d.Seconds()
+	for i, bound := range h.bounds {
+		if d.Seconds() <= bound {
+			h.cum[i]++
+		}
+	}
+}
+
+func (h *histogram) snapshot() FlushStats {
+	st := FlushStats{Count: h.count, Sum: h.sum, Buckets: make(map[float64]uint64, len(h.bounds))}
+	for i, bound := range h.bounds {
+		st.Buckets[bound] = h.cum[i]
+	}
+	return st
+}
+
+// NewStats creates empty stats.
+//
+//	stats := log.NewStats(log.StatsOptions{
+//	    SlowThreshold: 2 * time.Second,
+//	    ErrorHandler: func(sink string, err error) {
+//	        fmt.Fprintln(os.Stderr, "log pipeline:", err)
+//	    },
+//	})
+func NewStats(opts ...StatsOptions) *Stats {
+	var o StatsOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.SlowWindow <= 0 {
+		o.SlowWindow = DefaultSlowSinkWindow
+	}
+	return &Stats{opts: o, sinks: make(map[string]*sinkCounters)}
+}
+
+// sink returns the counters of name; s.mu must be held.
+func (s *Stats) sink(name string) *sinkCounters {
+	c, ok := s.sinks[name]
+	if !ok {
+		c = &sinkCounters{
+			records:  make(map[string]uint64),
+			dropped:  make(map[string]uint64),
+			flush:    newHistogram(FlushBuckets),
+			delivery: newHistogram(DeliveryBuckets),
+		}
+		s.sinks[name] = c
+	}
+	return c
+}
+
+// Record counts a record written by sink at level.
+func (s *Stats) Record(sink string, level slog.Level) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sink(sink).records[level.String()]++
+}
+
+// Drop counts n records lost by sink for reason.
+func (s *Stats) Drop(sink, reason string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sink(sink).dropped[reason] += uint64(n)
+}
+
+// Error counts a failed write or flush of sink.
+func (s *Stats) Error(sink string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sink(sink).errors++
+}
+
+// SetQueueDepth records the number of records waiting in sink's queue.
+func (s *Stats) SetQueueDepth(sink string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sink(sink).queueDepth = n
+}
+
+// ObserveFlush records a flush of sink that took d.
+func (s *Stats) ObserveFlush(sink string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sink(sink).flush.observe(d)
+}
+
+// ObserveDelivery records records of sink delivered with latencies, from
+// their creation to the end of their write. Once a window of
+// StatsOptions.SlowWindow is complete, its p99 is checked against
+// SlowThreshold.
+func (s *Stats) ObserveDelivery(sink string, latencies ...time.Duration) {
+	s.mu.Lock()
+	c := s.sink(sink)
+	for _, d := range latencies {
+		c.delivery.observe(d)
+	}
+	var slow *SlowSinkError
+	if s.opts.SlowThreshold > 0 {
+		slow = s.window(sink, c, latencies)
+	}
+	s.mu.Unlock()
+
+	if slow != nil {
+		s.handle(sink, slow)
+	}
+}
+
+// window adds latencies to the window of c, and returns the error of the
+// window it completes if it was slow; s.mu must be held.
+func (s *Stats) window(sink string, c *sinkCounters, latencies []time.Duration) *SlowSinkError {
+	var slow *SlowSinkError
+	now := time.Now()
+	if c.windowStart.IsZero() {
+		c.windowStart = now
+	} else if now.Sub(c.windowStart) >= s.opts.SlowWindow {
+		if len(c.window) > 0 {
+			slices.Sort(c.window)
+			c.p99 = c.window[(len(c.window)*99-1)/100]
+			if c.p99 > s.opts.SlowThreshold {
+				slow = &SlowSinkError{Sink: sink, P99: c.p99, Threshold: s.opts.SlowThreshold,
+					Window: now.Sub(c.windowStart), Samples: c.windowSeen}
+			}
+		}
+		c.windowStart, c.windowSeen, c.window = now, 0, c.window[:0]
+	}
+	for _, d := range latencies {
+		c.windowSeen++
+		if len(c.window) < slowSinkSamples {
+			c.window = append(c.window, d)
+		} else if i := rand.IntN(c.windowSeen); i < slowSinkSamples {
+			c.window[i] = d
+		}
+	}
+	return slow
+}
+
+// handle passes err of sink to the ErrorHandler.
+func (s *Stats) handle(sink string, err error) {
+	if s.opts.ErrorHandler != nil {
+		s.opts.ErrorHandler(sink, err)
+	}
+}
+
+// LogStats implements StatsProvider, sorted by sink name.
+func (s *Stats) LogStats() []SinkStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]SinkStats, 0, len(s.sinks))
+	for name, c := range s.sinks {
+		st := SinkStats{
+			Sink:        name,
+			Records:     make(map[string]uint64, len(c.records)),
+			Dropped:     make(map[string]uint64, len(c.dropped)),
+			Errors:      c.errors,
+			QueueDepth:  c.queueDepth,
+			Flush:       c.flush.snapshot(),
+			Delivery:    c.delivery.snapshot(),
+			DeliveryP99: c.p99,
+		}
+		for k, v := range c.records {
+			st.Records[k] = v
+		}
+		for k, v := range c.dropped {
+			st.Dropped[k] = v
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Sink < out[j].Sink })
+	return out
+}
+
+// InstrumentSink returns sink reporting to stats under name. Each write of
+// the synchronous sinks is a flush: it is timed, and a failed write counts
+// as an error and a record dropped with DropSinkError, passed to the
+// ErrorHandler of stats. The records written are observed with their
+// delivery latency.
+func InstrumentSink(name string, sink Sink, stats *Stats) Sink {
+	if stats == nil {
+		return sink
+	}
+	return &instrumentedSink{name: name, sink: sink, stats: stats}
+}
+
+type instrumentedSink struct {
+	name  string
+	sink  Sink
+	stats *Stats
+}
+
+func (s *instrumentedSink) Write(ctx context.Context, level slog.Level, msg string, attrs []slog.Attr) error {
+	start := time.Now()
+	err := s.sink.Write(ctx, level, msg, attrs)
+	d := time.Since(start)
+	s.stats.ObserveFlush(s.name, d)
+	if err != nil {
+		s.failed(err, 1)
+		return err
+	}
+	s.stats.Record(s.name, level)
+	s.stats.ObserveDelivery(s.name, d)
+	return nil
+}
+
+// failed counts a write of n records failing with err.
+func (s *instrumentedSink) failed(err error, n int) {
+	s.stats.Error(s.name)
+	s.stats.Drop(s.name, DropSinkError, n)
+	s.stats.handle(s.name, err)
+}
+
+// WriteBatch implements BatchSink: a batch written at once is one flush,
+// and all its records are dropped when it fails. Batches for other sinks
+// are written and counted record by record.
+func (s *instrumentedSink) WriteBatch(ctx context.Context, records []Record) error {
+	if _, ok := s.sink.(BatchSink); !ok {
+		var errs []error
+		for _, r := range records {
+			if err := s.Write(ctx, r.Level, r.Msg, r.Attrs); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+	start := time.Now()
+	err := WriteBatch(ctx, s.sink, records)
+	end := time.Now()
+	s.stats.ObserveFlush(s.name, end.Sub(start))
+	if err != nil {
+		s.failed(err, len(records))
+		return err
+	}
+	latencies := make([]time.Duration, len(records))
+	for i, r := range records {
+		s.stats.Record(s.name, r.Level)
+		latencies[i] = end.Sub(start)
+		if !r.Time.IsZero() {
+			latencies[i] = end.Sub(r.Time)
+		}
+	}
+	s.stats.ObserveDelivery(s.name, latencies...)
+	return nil
+}
+
+func (s *instrumentedSink) Close() error {
+	return s.sink.Close()
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// BreakerMetrics holds metrics for circuit breakers.
+type BreakerMetrics struct {
+	State       *prometheus.GaugeVec
+	Transitions *prometheus.CounterVec
+	Rejected    *prometheus.CounterVec
+}
+
+// NewBreakerMetrics creates and registers circuit breaker metrics.
+func NewBreakerMetrics(namespace string) *BreakerMetrics {
+	m := &BreakerMetrics{
+		State: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "circuit_breaker_state",
+				Help:      "Circuit breaker state (0=closed, 1=half-open, 2=open)",
+			},
+			[]string{"name"},
+		),
+		Transitions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "circuit_breaker_transitions_total",
+				Help:      "Total number of circuit breaker state transitions",
+			},
+			[]string{"name", "from", "to"},
+		),
+		Rejected: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "circuit_breaker_rejected_total",
+				Help:      "Total number of calls rejected by an open circuit breaker",
+			},
+			[]string{"name"},
+		),
+	}
+
+	prometheus.MustRegister(m.State)
+	prometheus.MustRegister(m.Transitions)
+	prometheus.MustRegister(m.Rejected)
+
+	return m
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CacheMetrics holds metrics for cache operations (see cache.Instrument).
+// The hit ratio of a cache is hits / (hits + misses) of get Operations.
+type CacheMetrics struct {
+	Operations *prometheus.CounterVec
+	Duration   *prometheus.HistogramVec
+}
+
+// NewCacheMetrics creates and registers cache metrics.
+func NewCacheMetrics(namespace string) *CacheMetrics {
+	m := &CacheMetrics{
+		Operations: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "cache_operations_total",
+				Help:      "Total number of cache operations by result (hit, miss, ok, error)",
+			},
+			[]string{"cache", "op", "result"},
+		),
+		Duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "cache_operation_duration_seconds",
+				Help:      "Cache operation latency in seconds",
+				Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25},
+			},
+			[]string{"cache", "op"},
+		),
+	}
+
+	prometheus.MustRegister(m.Operations)
+	prometheus.MustRegister(m.Duration)
+
+	return m
+}
+
+// ObserveCache implements cache.Observer.
+func (m *CacheMetrics) ObserveCache(name, op, result string, duration time.Duration) {
+	m.Operations.WithLabelValues(name, op, result).Inc()
+	m.Duration.WithLabelValues(name, op).Observe(duration.Seconds())
+}
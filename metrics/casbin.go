@@ -0,0 +1,39 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// CasbinDecisionMetrics holds metrics for the Casbin decision cache (see
+// middleware.DecisionCache).
+type CasbinDecisionMetrics struct {
+	Lookups       *prometheus.CounterVec
+	Invalidations prometheus.Counter
+}
+
+// NewCasbinDecisionMetrics creates and registers Casbin decision cache
+// metrics. The hit ratio is
+// rate(casbin_decision_cache_lookups_total{result="hit"}[5m]) over the
+// rate of all lookups.
+func NewCasbinDecisionMetrics(namespace string) *CasbinDecisionMetrics {
+	m := &CasbinDecisionMetrics{
+		Lookups: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "casbin_decision_cache_lookups_total",
+				Help:      "Total number of Casbin decision cache lookups by result (hit, miss)",
+			},
+			[]string{"result"},
+		),
+		Invalidations: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "casbin_decision_cache_invalidations_total",
+				Help:      "Total number of Casbin decision cache invalidations on policy changes",
+			},
+		),
+	}
+
+	prometheus.MustRegister(m.Lookups)
+	prometheus.MustRegister(m.Invalidations)
+
+	return m
+}
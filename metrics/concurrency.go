@@ -0,0 +1,46 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// ConcurrencyMetrics holds metrics for the concurrency limit middleware.
+type ConcurrencyMetrics struct {
+	InFlight *prometheus.GaugeVec
+	Queued   *prometheus.GaugeVec
+	Rejected *prometheus.CounterVec
+}
+
+// NewConcurrencyMetrics creates and registers concurrency limit metrics.
+func NewConcurrencyMetrics(namespace string) *ConcurrencyMetrics {
+	m := &ConcurrencyMetrics{
+		InFlight: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "concurrency_in_flight",
+				Help:      "Requests currently holding a concurrency slot",
+			},
+			[]string{"key"},
+		),
+		Queued: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "concurrency_queued",
+				Help:      "Requests waiting for a concurrency slot",
+			},
+			[]string{"key"},
+		),
+		Rejected: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "concurrency_rejected_total",
+				Help:      "Total number of requests rejected by a concurrency limit",
+			},
+			[]string{"key", "reason"},
+		),
+	}
+
+	prometheus.MustRegister(m.InFlight)
+	prometheus.MustRegister(m.Queued)
+	prometheus.MustRegister(m.Rejected)
+
+	return m
+}
@@ -0,0 +1,245 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Labels added automatically by CountCtx.
+const (
+	LabelTenant = "tenant"
+	LabelEnv    = "env"
+)
+
+// RegistryOptions configures a Registry.
+type RegistryOptions struct {
+	// Registerer receives the collectors; default prometheus.DefaultRegisterer.
+	Registerer prometheus.Registerer
+	// Namespace prefixes every metric, typically SystemConfig.Name.
+	// Characters invalid in metric names are replaced by '_'.
+	Namespace string
+	// Env is the value of the env label added by CountCtx (SystemConfig.Env).
+	Env string
+	// AllowedLabels are the label names CountCtx accepts from callers.
+	AllowedLabels []string
+}
+
+// Registry lazily creates and registers ad-hoc business metrics by name.
+// Asking for the same name again returns the existing collector.
+//
+//	orders, err := reg.Counter("orders_created_total", "Orders created", "channel")
+//	orders.WithLabelValues("web").Inc()
+type Registry struct {
+	opts RegistryOptions
+
+	mu         sync.RWMutex
+	collectors map[string]*customCollector
+}
+
+type customCollector struct {
+	kind      string
+	labels    []string
+	collector prometheus.Collector
+}
+
+// NewRegistry creates a Registry.
+func NewRegistry(opts RegistryOptions) *Registry {
+	if opts.Registerer == nil {
+		opts.Registerer = prometheus.DefaultRegisterer
+	}
+	opts.Namespace = sanitizeName(opts.Namespace)
+	return &Registry{opts: opts, collectors: make(map[string]*customCollector)}
+}
+
+// NewRegistryFromConfig creates a Registry on the default registerer,
+// namespaced by cfg.Name and labeled with cfg.Env.
+//
+//	metrics.SetDefault(metrics.NewRegistryFromConfig(cfg.System, "channel"))
+func NewRegistryFromConfig(cfg config.SystemConfig, allowedLabels ...string) *Registry {
+	return NewRegistry(RegistryOptions{
+		Namespace:     cfg.Name,
+		Env:           cfg.Env,
+		AllowedLabels: allowedLabels,
+	})
+}
+
+var defaultRegistry atomic.Pointer[Registry]
+
+// SetDefault sets the Registry used by the package-level helpers.
+func SetDefault(r *Registry) {
+	defaultRegistry.Store(r)
+}
+
+// Default returns the Registry used by the package-level helpers, creating
+// one on the default registerer if none was set.
+func Default() *Registry {
+	if r := defaultRegistry.Load(); r != nil {
+		return r
+	}
+	defaultRegistry.CompareAndSwap(nil, NewRegistry(RegistryOptions{}))
+	return defaultRegistry.Load()
+}
+
+// Counter returns the counter vector name from the default Registry.
+func Counter(name, help string, labels ...string) (*prometheus.CounterVec, error) {
+	return Default().Counter(name, help, labels...)
+}
+
+// Gauge returns the gauge vector name from the default Registry.
+func Gauge(name, help string, labels ...string) (*prometheus.GaugeVec, error) {
+	return Default().Gauge(name, help, labels...)
+}
+
+// Histogram returns the histogram vector name from the default Registry.
+func Histogram(name, help string, buckets []float64, labels ...string) (*prometheus.HistogramVec, error) {
+	return Default().Histogram(name, help, buckets, labels...)
+}
+
+// CountCtx increments counter name on the default Registry; see Registry.CountCtx.
+func CountCtx(ctx context.Context, name string, labels map[string]string) error {
+	return Default().CountCtx(ctx, name, labels)
+}
+
+// Counter returns the counter vector name, registering it on first use.
+func (r *Registry) Counter(name, help string, labels ...string) (*prometheus.CounterVec, error) {
+	c, err := r.getOrCreate("counter", name, labels, func() prometheus.Collector {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: r.opts.Namespace,
+			Name:      name,
+			Help:      help,
+		}, labels)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.(*prometheus.CounterVec), nil
+}
+
+// Gauge returns the gauge vector name, registering it on first use.
+func (r *Registry) Gauge(name, help string, labels ...string) (*prometheus.GaugeVec, error) {
+	c, err := r.getOrCreate("gauge", name, labels, func() prometheus.Collector {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: r.opts.Namespace,
+			Name:      name,
+			Help:      help,
+		}, labels)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.(*prometheus.GaugeVec), nil
+}
+
+// Histogram returns the histogram vector name, registering it on first use.
+// Nil buckets use prometheus.DefBuckets.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) (*prometheus.HistogramVec, error) {
+	if buckets == nil {
+		buckets = prometheus.DefBuckets
+	}
+	c, err := r.getOrCreate("histogram", name, labels, func() prometheus.Collector {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: r.opts.Namespace,
+			Name:      name,
+			Help:      help,
+			Buckets:   buckets,
+		}, labels)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.(*prometheus.HistogramVec), nil
+}
+
+// CountCtx increments counter name by one. The tenant (from ctx) and env
+// labels are added automatically; every key of labels must be listed in
+// RegistryOptions.AllowedLabels. A given counter must always be called with
+// the same label keys.
+func (r *Registry) CountCtx(ctx context.Context, name string, labels map[string]string) error {
+	names := make([]string, 0, len(labels)+2)
+	for k := range labels {
+		if k == LabelTenant || k == LabelEnv || !slices.Contains(r.opts.AllowedLabels, k) {
+			return fmt.Errorf("metrics: label %q is not allowed for %s", k, name)
+		}
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	names = append(names, LabelEnv, LabelTenant)
+
+	vec, err := r.Counter(name, name, names...)
+	if err != nil {
+		return err
+	}
+	values := make([]string, len(names))
+	for i, k := range names[:len(names)-2] {
+		values[i] = labels[k]
+	}
+	values[len(names)-2] = r.opts.Env
+	values[len(names)-1] = utils.GetTenantID(ctx)
+
+	vec.WithLabelValues(values...).Inc()
+	return nil
+}
+
+// getOrCreate returns the collector registered under name, creating and
+// registering it with newFn when missing.
+func (r *Registry) getOrCreate(kind, name string, labels []string, newFn func() prometheus.Collector) (prometheus.Collector, error) {
+	r.mu.RLock()
+	c, ok := r.collectors[name]
+	r.mu.RUnlock()
+	if ok {
+		return c.check(kind, name, labels)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.collectors[name]; ok {
+		return c.check(kind, name, labels)
+	}
+
+	collector := newFn()
+	if err := r.opts.Registerer.Register(collector); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if !errors.As(err, &are) {
+			return nil, fmt.Errorf("metrics: register %s: %w", name, err)
+		}
+		// Registered outside this Registry with an identical descriptor.
+		if fmt.Sprintf("%T", are.ExistingCollector) != fmt.Sprintf("%T", collector) {
+			return nil, fmt.Errorf("metrics: %s is already registered as %T", name, are.ExistingCollector)
+		}
+		collector = are.ExistingCollector
+	}
+	c = &customCollector{kind: kind, labels: slices.Clone(labels), collector: collector}
+	r.collectors[name] = c
+	return c.check(kind, name, labels)
+}
+
+// check verifies the collector matches the requested kind and labels.
+func (c *customCollector) check(kind, name string, labels []string) (prometheus.Collector, error) {
+	if c.kind != kind {
+		return nil, fmt.Errorf("metrics: %s is registered as a %s, not a %s", name, c.kind, kind)
+	}
+	if !slices.Equal(c.labels, labels) {
+		return nil, fmt.Errorf("metrics: %s is registered with labels %v, got %v", name, c.labels, labels)
+	}
+	return c.collector, nil
+}
+
+// sanitizeName replaces characters not allowed in metric names with '_'.
+func sanitizeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, s)
+}
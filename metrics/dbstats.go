@@ -0,0 +1,39 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// DBStatsMetrics holds the per-route database metrics of requests (see
+// middleware.DBStats).
+type DBStatsMetrics struct {
+	Queries  *prometheus.HistogramVec
+	Duration *prometheus.HistogramVec
+}
+
+// NewDBStatsMetrics creates and registers per-request database metrics.
+func NewDBStatsMetrics(namespace string) *DBStatsMetrics {
+	m := &DBStatsMetrics{
+		Queries: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "http_request_db_queries",
+				Help:      "Number of database statements run by an HTTP request",
+				Buckets:   []float64{0, 1, 2, 5, 10, 20, 50, 100, 200},
+			},
+			[]string{"method", "path"},
+		),
+		Duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "http_request_db_duration_seconds",
+				Help:      "Total database time of an HTTP request in seconds",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"method", "path"},
+		),
+	}
+
+	prometheus.MustRegister(m.Queries)
+	prometheus.MustRegister(m.Duration)
+
+	return m
+}
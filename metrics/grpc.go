@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// GRPCMetrics holds metrics for gRPC servers (see grpckit.Metrics).
+type GRPCMetrics struct {
+	Handled  *prometheus.CounterVec
+	Duration *prometheus.HistogramVec
+}
+
+// NewGRPCMetrics creates gRPC server metrics and registers them on reg;
+// nil registers them on prometheus.DefaultRegisterer.
+func NewGRPCMetrics(namespace string, reg prometheus.Registerer) *GRPCMetrics {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	m := &GRPCMetrics{
+		Handled: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "grpc_server_handled_total",
+				Help:      "Total number of RPCs completed by method and gRPC status code",
+			},
+			[]string{"method", "code"},
+		),
+		Duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "grpc_server_handling_seconds",
+				Help:      "RPC handling duration in seconds",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"method"},
+		),
+	}
+
+	reg.MustRegister(m.Handled)
+	reg.MustRegister(m.Duration)
+
+	return m
+}
+
+// Observe records a completed RPC.
+func (m *GRPCMetrics) Observe(method, code string, duration time.Duration) {
+	m.Handled.WithLabelValues(method, code).Inc()
+	m.Duration.WithLabelValues(method).Observe(duration.Seconds())
+}
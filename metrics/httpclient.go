@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HTTPClientMetrics holds metrics for outbound HTTP calls.
+type HTTPClientMetrics struct {
+	RequestsTotal   *prometheus.CounterVec
+	RequestDuration *prometheus.HistogramVec
+}
+
+// NewHTTPClientMetrics creates and registers outbound HTTP client metrics.
+func NewHTTPClientMetrics(namespace string) *HTTPClientMetrics {
+	m := &HTTPClientMetrics{
+		RequestsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "http_client_requests_total",
+				Help:      "Total number of outbound HTTP requests",
+			},
+			[]string{"host", "method", "status_class"},
+		),
+		RequestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "http_client_request_duration_seconds",
+				Help:      "Outbound HTTP request duration in seconds",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"host", "method"},
+		),
+	}
+
+	prometheus.MustRegister(m.RequestsTotal)
+	prometheus.MustRegister(m.RequestDuration)
+
+	return m
+}
+
+// Observe records one outbound request. status 0 means the request failed
+// before a response was received.
+func (m *HTTPClientMetrics) Observe(host, method string, status int, duration time.Duration) {
+	m.RequestsTotal.WithLabelValues(host, method, StatusClass(status)).Inc()
+	m.RequestDuration.WithLabelValues(host, method).Observe(duration.Seconds())
+}
+
+// StatusClass returns the status class label ("2xx", "4xx", ...) for an HTTP
+// status, or "error" when no response was received.
+func StatusClass(status int) string {
+	if status <= 0 {
+		return "error"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
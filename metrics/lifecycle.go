@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"github.com/NSObjects/go-kit/lifecycle"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var backgroundWorkersDesc = prometheus.NewDesc("kit_background_workers",
+	"Number of running background workers of the kit by name and state", []string{"name", "state"}, nil)
+
+// RegisterWorkerCollector registers on reg the kit_background_workers
+// gauge of the workers started with lifecycle.Go, read at scrape time. A
+// nil reg registers on the default registry.
+func RegisterWorkerCollector(reg prometheus.Registerer) error {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	return reg.Register(workerCollector{})
+}
+
+type workerCollector struct{}
+
+func (workerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- backgroundWorkersDesc
+}
+
+func (workerCollector) Collect(ch chan<- prometheus.Metric) {
+	type key struct{ name, state string }
+	counts := map[key]int{}
+	for _, w := range lifecycle.Workers() {
+		counts[key{w.Name, w.State}]++
+	}
+	for k, n := range counts {
+		ch <- prometheus.MustNewConstMetric(backgroundWorkersDesc, prometheus.GaugeValue, float64(n), k.name, k.state)
+	}
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"github.com/NSObjects/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	logRecordsDesc = prometheus.NewDesc("kit_log_records_total",
+		"Total number of log records written by sink and level", []string{"sink", "level"}, nil)
+	logDroppedDesc = prometheus.NewDesc("kit_log_dropped_total",
+		"Total number of log records dropped by sink and reason", []string{"sink", "reason"}, nil)
+	logSinkErrorsDesc = prometheus.NewDesc("kit_log_sink_errors_total",
+		"Total number of failed log sink writes and flushes", []string{"sink"}, nil)
+	logQueueDepthDesc = prometheus.NewDesc("kit_log_queue_depth",
+		"Number of log records waiting to be written by sink", []string{"sink"}, nil)
+	logFlushDurationDesc = prometheus.NewDesc("kit_log_flush_duration_seconds",
+		"Log sink flush duration in seconds", []string{"sink"}, nil)
+	logDeliveryLatencyDesc = prometheus.NewDesc("kit_log_delivery_latency_seconds",
+		"Latency of log records from their creation to their delivery by sink, in seconds", []string{"sink"}, nil)
+)
+
+// RegisterLogCollectors registers on reg the metrics of the log pipeline
+// read from stats at scrape time (see log.Stats). A nil reg registers on
+// the default registry.
+//
+//	stats := log.NewStats()
+//	logger := log.NewFromLogConfig(log.LogConfig{Loki: lokiCfg, Stats: stats}, env)
+//	_ = metrics.RegisterLogCollectors(nil, stats)
+func RegisterLogCollectors(reg prometheus.Registerer, stats log.StatsProvider) error {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	return reg.Register(logCollector{stats: stats})
+}
+
+type logCollector struct {
+	stats log.StatsProvider
+}
+
+func (c logCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- logRecordsDesc
+	ch <- logDroppedDesc
+	ch <- logSinkErrorsDesc
+	ch <- logQueueDepthDesc
+	ch <- logFlushDurationDesc
+	ch <- logDeliveryLatencyDesc
+}
+
+func (c logCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range c.stats.LogStats() {
+		for level, n := range s.Records {
+			ch <- prometheus.MustNewConstMetric(logRecordsDesc, prometheus.CounterValue, float64(n), s.Sink, level)
+		}
+		for reason, n := range s.Dropped {
+			ch <- prometheus.MustNewConstMetric(logDroppedDesc, prometheus.CounterValue, float64(n), s.Sink, reason)
+		}
+		ch <- prometheus.MustNewConstMetric(logSinkErrorsDesc, prometheus.CounterValue, float64(s.Errors), s.Sink)
+		ch <- prometheus.MustNewConstMetric(logQueueDepthDesc, prometheus.GaugeValue, float64(s.QueueDepth), s.Sink)
+		ch <- prometheus.MustNewConstHistogram(logFlushDurationDesc, s.Flush.Count, s.Flush.Sum, s.Flush.Buckets, s.Sink)
+		ch <- prometheus.MustNewConstHistogram(logDeliveryLatencyDesc, s.Delivery.Count, s.Delivery.Sum, s.Delivery.Buckets, s.Sink)
+	}
+}
+
+var deprecatedUsageDesc = prometheus.NewDesc("kit_deprecated_usage_total",
+	"Total number of uses of deprecated kit APIs by key", []string{"key"}, nil)
+
+// RegisterDeprecationCollector registers on reg the
+// kit_deprecated_usage_total{key} counter of the deprecated paths reported
+// with log.DeprecationWarn. A nil reg registers on the default registry.
+func RegisterDeprecationCollector(reg prometheus.Registerer) error {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	return reg.Register(deprecationCollector{})
+}
+
+type deprecationCollector struct{}
+
+func (deprecationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- deprecatedUsageDesc
+}
+
+func (deprecationCollector) Collect(ch chan<- prometheus.Metric) {
+	for key, n := range log.DeprecationCounts() {
+		ch <- prometheus.MustNewConstMetric(deprecatedUsageDesc, prometheus.CounterValue, float64(n), key)
+	}
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NotifyMetrics holds metrics for outgoing notifications (see
+// notify.Notifier).
+type NotifyMetrics struct {
+	Sends       *prometheus.CounterVec
+	Duration    *prometheus.HistogramVec
+	RateLimited *prometheus.CounterVec
+}
+
+// NewNotifyMetrics creates and registers notification metrics.
+func NewNotifyMetrics(namespace string) *NotifyMetrics {
+	m := &NotifyMetrics{
+		Sends: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "notify_sends_total",
+				Help:      "Total number of notification send attempts by provider, channel and status (ok, error)",
+			},
+			[]string{"provider", "channel", "status"},
+		),
+		Duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "notify_send_duration_seconds",
+				Help:      "Notification send attempt duration in seconds",
+				Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+			},
+			[]string{"provider"},
+		),
+		RateLimited: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "notify_rate_limited_total",
+				Help:      "Total number of notifications rejected by the per-recipient rate limit",
+			},
+			[]string{"channel"},
+		),
+	}
+
+	prometheus.MustRegister(m.Sends)
+	prometheus.MustRegister(m.Duration)
+	prometheus.MustRegister(m.RateLimited)
+
+	return m
+}
+
+// Observe records a send attempt.
+func (m *NotifyMetrics) Observe(provider, channel, status string, duration time.Duration) {
+	m.Sends.WithLabelValues(provider, channel, status).Inc()
+	m.Duration.WithLabelValues(provider).Observe(duration.Seconds())
+}
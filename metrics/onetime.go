@@ -0,0 +1,31 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// OneTimeMetrics counts the one-time token operations (see cache.OneTime).
+type OneTimeMetrics struct {
+	Operations *prometheus.CounterVec
+}
+
+// NewOneTimeMetrics creates and registers one-time token metrics.
+func NewOneTimeMetrics(namespace string) *OneTimeMetrics {
+	m := &OneTimeMetrics{
+		Operations: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "onetime_tokens_total",
+				Help:      "Total number of one-time token operations by purpose and result (issued, consumed, verified, expired, invalid, error)",
+			},
+			[]string{"purpose", "result"},
+		),
+	}
+
+	prometheus.MustRegister(m.Operations)
+
+	return m
+}
+
+// ObserveOneTime implements cache.OneTimeObserver.
+func (m *OneTimeMetrics) ObserveOneTime(purpose, result string) {
+	m.Operations.WithLabelValues(purpose, result).Inc()
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// OutboxMetrics holds metrics for the transactional outbox relay.
+type OutboxMetrics struct {
+	Published *prometheus.CounterVec
+	Failures  *prometheus.CounterVec
+	Lag       prometheus.Gauge
+}
+
+// NewOutboxMetrics creates and registers outbox relay metrics.
+func NewOutboxMetrics(namespace string) *OutboxMetrics {
+	m := &OutboxMetrics{
+		Published: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "outbox_published_total",
+				Help:      "Total number of outbox entries published",
+			},
+			[]string{"topic"},
+		),
+		Failures: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "outbox_publish_failures_total",
+				Help:      "Total number of failed outbox publish attempts",
+			},
+			[]string{"topic"},
+		),
+		Lag: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "outbox_lag_seconds",
+				Help:      "Age of the oldest unpublished outbox entry in seconds",
+			},
+		),
+	}
+
+	prometheus.MustRegister(m.Published)
+	prometheus.MustRegister(m.Failures)
+	prometheus.MustRegister(m.Lag)
+
+	return m
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisPoolHitsDesc = prometheus.NewDesc("kit_redis_pool_hits_total",
+		"Total number of times a free connection was found in the Redis pool", []string{"name"}, nil)
+	redisPoolMissesDesc = prometheus.NewDesc("kit_redis_pool_misses_total",
+		"Total number of times a free connection was not found in the Redis pool", []string{"name"}, nil)
+	redisPoolTimeoutsDesc = prometheus.NewDesc("kit_redis_pool_timeouts_total",
+		"Total number of times waiting for a Redis pool connection timed out", []string{"name"}, nil)
+	redisPoolConnsDesc = prometheus.NewDesc("kit_redis_pool_connections",
+		"Number of connections in the Redis pool by state (total, idle)", []string{"name", "state"}, nil)
+	redisPoolStaleDesc = prometheus.NewDesc("kit_redis_pool_stale_connections_total",
+		"Total number of stale connections removed from the Redis pool", []string{"name"}, nil)
+)
+
+// RegisterSQLPoolCollector registers on reg the pool statistics of db,
+// labeled db_name=name, read at scrape time: open, in-use and idle
+// connections, waits for a connection (go_sql_wait_count_total,
+// go_sql_wait_duration_seconds_total) and closes by reason. A nil reg
+// registers on the default registry.
+//
+//	sqlDB, _ := manager.DB.DB()
+//	_ = metrics.RegisterSQLPoolCollector(nil, "main", sqlDB)
+func RegisterSQLPoolCollector(reg prometheus.Registerer, name string, db *sql.DB) error {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	return reg.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterRedisPoolCollector registers on reg the pool statistics of
+// client, labeled name=name, read at scrape time. Timeouts count the
+// commands that waited for a connection longer than the pool timeout. A
+// nil reg registers on the default registry.
+func RegisterRedisPoolCollector(reg prometheus.Registerer, name string, client *redis.Client) error {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	return reg.Register(redisPoolCollector{name: name, client: client})
+}
+
+type redisPoolCollector struct {
+	name   string
+	client *redis.Client
+}
+
+func (c redisPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- redisPoolHitsDesc
+	ch <- redisPoolMissesDesc
+	ch <- redisPoolTimeoutsDesc
+	ch <- redisPoolConnsDesc
+	ch <- redisPoolStaleDesc
+}
+
+func (c redisPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(redisPoolHitsDesc, prometheus.CounterValue, float64(s.Hits), c.name)
+	ch <- prometheus.MustNewConstMetric(redisPoolMissesDesc, prometheus.CounterValue, float64(s.Misses), c.name)
+	ch <- prometheus.MustNewConstMetric(redisPoolTimeoutsDesc, prometheus.CounterValue, float64(s.Timeouts), c.name)
+	ch <- prometheus.MustNewConstMetric(redisPoolConnsDesc, prometheus.GaugeValue, float64(s.TotalConns), c.name, "total")
+	ch <- prometheus.MustNewConstMetric(redisPoolConnsDesc, prometheus.GaugeValue, float64(s.IdleConns), c.name, "idle")
+	ch <- prometheus.MustNewConstMetric(redisPoolStaleDesc, prometheus.CounterValue, float64(s.StaleConns), c.name)
+}
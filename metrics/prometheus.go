@@ -2,9 +2,16 @@
 package metrics
 
 import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	kiterrors "github.com/NSObjects/go-kit/errors"
 	"github.com/labstack/echo/v4"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Metrics holds application metrics.
@@ -13,6 +20,11 @@ type Metrics struct {
 	RequestDuration *prometheus.HistogramVec
 	RequestSize     *prometheus.SummaryVec
 	ResponseSize    *prometheus.SummaryVec
+	ErrorsTotal     *prometheus.CounterVec
+
+	// Exemplars attaches the sampled trace_id as an exemplar to
+	// RequestDuration and ErrorsTotal. Setup enables it with OtelConfig.Enabled.
+	Exemplars bool
 }
 
 // New creates and registers default metrics.
@@ -32,6 +44,10 @@ func New(namespace string) *Metrics {
 				Name:      "http_request_duration_seconds",
 				Help:      "HTTP request duration in seconds",
 				Buckets:   prometheus.DefBuckets,
+				// Also expose a native histogram to scrapers that negotiate it.
+				NativeHistogramBucketFactor:     1.1,
+				NativeHistogramMaxBucketNumber:  160,
+				NativeHistogramMinResetDuration: time.Hour,
 			},
 			[]string{"method", "path"},
 		),
@@ -51,21 +67,104 @@ func New(namespace string) *Metrics {
 			},
 			[]string{"method", "path"},
 		),
+		ErrorsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "http_errors_total",
+				Help:      "Total number of HTTP requests that returned an error, by error code",
+			},
+			[]string{"method", "path", "code"},
+		),
 	}
 
 	prometheus.MustRegister(m.RequestsTotal)
 	prometheus.MustRegister(m.RequestDuration)
 	prometheus.MustRegister(m.RequestSize)
 	prometheus.MustRegister(m.ResponseSize)
+	prometheus.MustRegister(m.ErrorsTotal)
 
 	return m
 }
 
-// Handler returns the Prometheus metrics handler.
+// Handler returns the Prometheus metrics handler. OpenMetrics is offered
+// during content negotiation so exemplars are exposed.
 func Handler() echo.HandlerFunc {
-	h := promhttp.Handler()
+	h := promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
 	return func(c echo.Context) error {
 		h.ServeHTTP(c.Response(), c.Request())
 		return nil
 	}
 }
+
+// Middleware records request count, duration and sizes for every request.
+// The route template (c.Path()) is used as the path label to keep
+// cardinality bounded.
+func (m *Metrics) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+
+			req := c.Request()
+			path := c.Path()
+			if path == "" {
+				path = "unmatched"
+			}
+			status := c.Response().Status
+			if err != nil {
+				var he *echo.HTTPError
+				if errors.As(err, &he) {
+					status = he.Code
+				}
+			}
+
+			var exemplar prometheus.Labels
+			if m.Exemplars {
+				exemplar = traceExemplar(req.Context())
+			}
+
+			m.RequestsTotal.WithLabelValues(req.Method, path, strconv.Itoa(status)).Inc()
+			observe(m.RequestDuration.WithLabelValues(req.Method, path), time.Since(start).Seconds(), exemplar)
+			if err != nil {
+				errCode := kiterrors.GetCode(err)
+				if errCode == 0 {
+					errCode = status
+				}
+				add(m.ErrorsTotal.WithLabelValues(req.Method, path, strconv.Itoa(errCode)), exemplar)
+			}
+			if req.ContentLength > 0 {
+				m.RequestSize.WithLabelValues(req.Method, path).Observe(float64(req.ContentLength))
+			}
+			m.ResponseSize.WithLabelValues(req.Method, path).Observe(float64(c.Response().Size))
+
+			return err
+		}
+	}
+}
+
+// traceExemplar returns the trace_id exemplar of a sampled span in ctx,
+// or nil.
+func traceExemplar(ctx context.Context) prometheus.Labels {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() || !sc.IsSampled() {
+		return nil
+	}
+	return prometheus.Labels{"trace_id": sc.TraceID().String()}
+}
+
+func observe(o prometheus.Observer, v float64, exemplar prometheus.Labels) {
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && exemplar != nil {
+		eo.ObserveWithExemplar(v, exemplar)
+		return
+	}
+	o.Observe(v)
+}
+
+func add(c prometheus.Counter, exemplar prometheus.Labels) {
+	if ea, ok := c.(prometheus.ExemplarAdder); ok && exemplar != nil {
+		ea.AddWithExemplar(1, exemplar)
+		return
+	}
+	c.Inc()
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// ResponseCacheMetrics holds metrics for the response cache middleware.
+// The hit ratio is hits / (hits + misses) of Requests.
+type ResponseCacheMetrics struct {
+	Requests *prometheus.CounterVec
+	Purges   *prometheus.CounterVec
+}
+
+// NewResponseCacheMetrics creates and registers response cache metrics.
+func NewResponseCacheMetrics(namespace string) *ResponseCacheMetrics {
+	m := &ResponseCacheMetrics{
+		Requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "response_cache_requests_total",
+				Help:      "Total number of cacheable requests by result (hit, miss, bypass)",
+			},
+			[]string{"route", "result"},
+		),
+		Purges: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "response_cache_purges_total",
+				Help:      "Total number of response cache purges by kind (route, tag)",
+			},
+			[]string{"kind"},
+		),
+	}
+
+	prometheus.MustRegister(m.Requests)
+	prometheus.MustRegister(m.Purges)
+
+	return m
+}
@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// SetRuntimeMetrics adds or removes the Go runtime and process collectors
+// of the default registry, which has them registered initially.
+func SetRuntimeMetrics(enabled bool) {
+	for _, c := range []prometheus.Collector{
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	} {
+		if !enabled {
+			prometheus.Unregister(c)
+			continue
+		}
+		var are prometheus.AlreadyRegisteredError
+		if err := prometheus.Register(c); err != nil && !errors.As(err, &are) {
+			panic(err)
+		}
+	}
+}
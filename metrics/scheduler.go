@@ -0,0 +1,37 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// SchedulerMetrics holds metrics for scheduled jobs.
+type SchedulerMetrics struct {
+	JobRuns     *prometheus.CounterVec
+	JobDuration *prometheus.HistogramVec
+}
+
+// NewSchedulerMetrics creates and registers scheduler metrics.
+func NewSchedulerMetrics(namespace string) *SchedulerMetrics {
+	m := &SchedulerMetrics{
+		JobRuns: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "scheduler_job_runs_total",
+				Help:      "Total number of scheduled job runs",
+			},
+			[]string{"job", "status"},
+		),
+		JobDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "scheduler_job_duration_seconds",
+				Help:      "Scheduled job duration in seconds",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"job"},
+		),
+	}
+
+	prometheus.MustRegister(m.JobRuns)
+	prometheus.MustRegister(m.JobDuration)
+
+	return m
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"github.com/NSObjects/go-kit/security"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SecurityMetrics counts security events. Register Handle with
+// security.OnEvent.
+type SecurityMetrics struct {
+	Events  *prometheus.CounterVec
+	Dropped prometheus.CounterFunc
+}
+
+// NewSecurityMetrics creates and registers security event metrics.
+func NewSecurityMetrics(namespace string) *SecurityMetrics {
+	m := &SecurityMetrics{
+		Events: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "security_events_total",
+				Help:      "Total number of security events",
+			},
+			[]string{"kind"},
+		),
+		Dropped: prometheus.NewCounterFunc(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "security_events_dropped_total",
+				Help:      "Total number of security events dropped because the queue was full",
+			},
+			func() float64 { return float64(security.Dropped()) },
+		),
+	}
+
+	prometheus.MustRegister(m.Events)
+	prometheus.MustRegister(m.Dropped)
+
+	return m
+}
+
+// Handle counts e by kind.
+func (m *SecurityMetrics) Handle(e security.Event) {
+	m.Events.WithLabelValues(string(e.Kind)).Inc()
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// ShedMetrics holds metrics for the adaptive load shedding middleware.
+type ShedMetrics struct {
+	Fraction *prometheus.GaugeVec
+	Shed     *prometheus.CounterVec
+}
+
+// NewShedMetrics creates and registers load shedding metrics.
+func NewShedMetrics(namespace string) *ShedMetrics {
+	m := &ShedMetrics{
+		Fraction: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "shed_fraction",
+				Help:      "Fraction of requests currently shed per route (0 to 1)",
+			},
+			[]string{"route"},
+		),
+		Shed: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "shed_requests_total",
+				Help:      "Total number of requests shed per route",
+			},
+			[]string{"route"},
+		),
+	}
+
+	prometheus.MustRegister(m.Fraction)
+	prometheus.MustRegister(m.Shed)
+
+	return m
+}
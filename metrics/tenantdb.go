@@ -0,0 +1,45 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// TenantDBMetrics holds metrics for per-tenant database connections (see
+// db.TenantRouter).
+type TenantDBMetrics struct {
+	Open    prometheus.Gauge
+	Opened  prometheus.Counter
+	Evicted *prometheus.CounterVec
+}
+
+// NewTenantDBMetrics creates and registers tenant database metrics.
+func NewTenantDBMetrics(namespace string) *TenantDBMetrics {
+	m := &TenantDBMetrics{
+		Open: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "tenant_db_open_connections",
+				Help:      "Number of tenant database connection pools currently open",
+			},
+		),
+		Opened: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "tenant_db_opened_total",
+				Help:      "Total number of tenant database connection pools opened",
+			},
+		),
+		Evicted: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "tenant_db_evicted_total",
+				Help:      "Total number of tenant database connection pools closed by reason (lru, idle, manual)",
+			},
+			[]string{"reason"},
+		),
+	}
+
+	prometheus.MustRegister(m.Open)
+	prometheus.MustRegister(m.Opened)
+	prometheus.MustRegister(m.Evicted)
+
+	return m
+}
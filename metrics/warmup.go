@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WarmupMetrics holds metrics for cache warmers (see cache.Warmer).
+type WarmupMetrics struct {
+	Runs     *prometheus.CounterVec
+	Duration *prometheus.HistogramVec
+	Keys     *prometheus.CounterVec
+}
+
+// NewWarmupMetrics creates and registers cache warmup metrics.
+func NewWarmupMetrics(namespace string) *WarmupMetrics {
+	m := &WarmupMetrics{
+		Runs: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "cache_warmer_runs_total",
+				Help:      "Total number of cache warmer runs by status (ok, error, timeout)",
+			},
+			[]string{"warmer", "status"},
+		),
+		Duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "cache_warmer_duration_seconds",
+				Help:      "Cache warmer duration in seconds",
+				Buckets:   []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+			},
+			[]string{"warmer"},
+		),
+		Keys: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "cache_warmer_keys_total",
+				Help:      "Total number of keys loaded by cache warmers",
+			},
+			[]string{"warmer"},
+		),
+	}
+
+	prometheus.MustRegister(m.Runs)
+	prometheus.MustRegister(m.Duration)
+	prometheus.MustRegister(m.Keys)
+
+	return m
+}
+
+// ObserveWarmup implements cache.WarmupObserver.
+func (m *WarmupMetrics) ObserveWarmup(name, status string, duration time.Duration, keys int64) {
+	m.Runs.WithLabelValues(name, status).Inc()
+	m.Duration.WithLabelValues(name).Observe(duration.Seconds())
+	m.Keys.WithLabelValues(name).Add(float64(keys))
+}
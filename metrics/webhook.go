@@ -0,0 +1,47 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// WebhookMetrics holds metrics for webhook delivery.
+type WebhookMetrics struct {
+	Attempts   *prometheus.CounterVec
+	Deliveries *prometheus.CounterVec
+	Latency    *prometheus.HistogramVec
+}
+
+// NewWebhookMetrics creates and registers webhook delivery metrics.
+func NewWebhookMetrics(namespace string) *WebhookMetrics {
+	m := &WebhookMetrics{
+		Attempts: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "webhook_attempts_total",
+				Help:      "Total number of webhook delivery attempts",
+			},
+			[]string{"host", "result"}, // success, failure
+		),
+		Deliveries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "webhook_deliveries_total",
+				Help:      "Total number of finished webhook deliveries",
+			},
+			[]string{"host", "result"}, // delivered, dead_letter
+		),
+		Latency: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "webhook_attempt_duration_seconds",
+				Help:      "Webhook delivery attempt duration in seconds",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"host"},
+		),
+	}
+
+	prometheus.MustRegister(m.Attempts)
+	prometheus.MustRegister(m.Deliveries)
+	prometheus.MustRegister(m.Latency)
+
+	return m
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// WorkerMetrics holds metrics for background message handlers.
+type WorkerMetrics struct {
+	Processed *prometheus.CounterVec
+	Failed    *prometheus.CounterVec
+	Duration  *prometheus.HistogramVec
+	Lag       *prometheus.GaugeVec
+}
+
+// NewWorkerMetrics creates and registers worker metrics.
+func NewWorkerMetrics(namespace string) *WorkerMetrics {
+	m := &WorkerMetrics{
+		Processed: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "worker_messages_processed_total",
+				Help:      "Total number of messages handled, including failures",
+			},
+			[]string{"handler"},
+		),
+		Failed: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "worker_messages_failed_total",
+				Help:      "Total number of messages that failed after all retries",
+			},
+			[]string{"handler"},
+		),
+		Duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "worker_message_duration_seconds",
+				Help:      "Message handling duration in seconds, including retries",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"handler"},
+		),
+		Lag: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "worker_lag_messages",
+				Help:      "Messages waiting to be handled, for sources that report lag",
+			},
+			[]string{"handler"},
+		),
+	}
+
+	prometheus.MustRegister(m.Processed)
+	prometheus.MustRegister(m.Failed)
+	prometheus.MustRegister(m.Duration)
+	prometheus.MustRegister(m.Lag)
+
+	return m
+}
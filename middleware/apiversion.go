@@ -0,0 +1,225 @@
+package middleware
+
+import (
+	"cmp"
+	"fmt"
+	"mime"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/NSObjects/go-kit/apidoc"
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/resp"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// Sources of the API version (see APIVersionConfig.Sources).
+const (
+	// APIVersionPath reads a path segment such as "/api/v2/users".
+	APIVersionPath = "path"
+	// APIVersionHeader reads the APIVersionConfig.Header request header.
+	APIVersionHeader = "header"
+	// APIVersionAccept reads a parameter of the Accept media type, as in
+	// "application/json; version=2".
+	APIVersionAccept = "accept"
+)
+
+// Defaults of APIVersionConfig.
+const (
+	DefaultAPIVersionHeader      = "X-API-Version"
+	DefaultAPIVersionAcceptParam = "version"
+)
+
+// DefaultAPIVersionSources is the default of APIVersionConfig.Sources.
+var DefaultAPIVersionSources = []string{APIVersionPath, APIVersionHeader, APIVersionAccept}
+
+// versionSegmentRe matches the path segments naming a version.
+var versionSegmentRe = regexp.MustCompile(`^[vV][0-9]+$`)
+
+// APIVersionConfig holds API version resolution configuration.
+type APIVersionConfig struct {
+	// Versions are the supported versions, such as "v1" and "v2".
+	Versions []string
+	// Default is the version of requests naming none; default the first
+	// of Versions, so that clients predating versioning keep the oldest.
+	Default string
+	// Sources are where the version is read, the first naming one wins;
+	// default DefaultAPIVersionSources.
+	Sources []string
+	// Header is the request header of APIVersionHeader; default
+	// DefaultAPIVersionHeader. Responses carry the resolved version in it.
+	Header string
+	// AcceptParam is the media type parameter of APIVersionAccept;
+	// default DefaultAPIVersionAcceptParam.
+	AcceptParam string
+	// Codecs are the response envelopes by version (see
+	// resp.EnvelopeCodec); versions without one use the process-wide
+	// codec.
+	Codecs map[string]resp.EnvelopeCodec
+	// Skipper skips the middleware for matching requests.
+	Skipper func(c echo.Context) bool
+}
+
+// APIVersion returns a middleware resolving the API version of requests
+// and storing it in the request context (see utils.GetAPIVersion).
+// Versions are compared case-insensitively, with or without the "v": "2",
+// "V2" and "v2" are all "v2". Requests naming an unsupported version are
+// rejected with a coded ErrAPIVersionUnsupported (404) listing the
+// supported versions. It panics on an invalid configuration.
+//
+//	e.Use(middleware.APIVersion(middleware.APIVersionConfig{
+//	    Versions: []string{"v1", "v2"},
+//	    Codecs:   map[string]resp.EnvelopeCodec{"v1": resp.DefaultCodec, "v2": resp.VerboseCodec},
+//	}))
+//	api := e.Group("/api/:version")
+//	middleware.VersionedRoute(api, docs, apidoc.Route{Method: http.MethodGet, Path: "/users", Versions: []string{"v1", "v2"}}, h.ListUsers)
+func APIVersion(cfg APIVersionConfig) echo.MiddlewareFunc {
+	if len(cfg.Versions) == 0 {
+		panic("api version: no versions")
+	}
+	versions := make([]string, len(cfg.Versions))
+	for i, v := range cfg.Versions {
+		versions[i] = normalizeAPIVersion(v)
+	}
+	def := normalizeAPIVersion(cmp.Or(cfg.Default, versions[0]))
+	if !slices.Contains(versions, def) {
+		panic(fmt.Sprintf("api version: default %s not in versions", def))
+	}
+	sources := cfg.Sources
+	if len(sources) == 0 {
+		sources = DefaultAPIVersionSources
+	}
+	for _, s := range sources {
+		if s != APIVersionPath && s != APIVersionHeader && s != APIVersionAccept {
+			panic(fmt.Sprintf("api version: unknown source %s", s))
+		}
+	}
+	codecs := make(map[string]resp.EnvelopeCodec, len(cfg.Codecs))
+	for v, codec := range cfg.Codecs {
+		codecs[normalizeAPIVersion(v)] = codec
+	}
+	header := cmp.Or(cfg.Header, DefaultAPIVersionHeader)
+	acceptParam := cmp.Or(cfg.AcceptParam, DefaultAPIVersionAcceptParam)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cfg.Skipper != nil && cfg.Skipper(c) {
+				return next(c)
+			}
+			req := c.Request()
+			version := ""
+			for _, s := range sources {
+				switch s {
+				case APIVersionPath:
+					version = pathAPIVersion(req.URL.Path)
+				case APIVersionHeader:
+					version = req.Header.Get(header)
+				case APIVersionAccept:
+					version = acceptAPIVersion(req.Header.Values(echo.HeaderAccept), acceptParam)
+				}
+				if version != "" {
+					break
+				}
+			}
+			version = normalizeAPIVersion(cmp.Or(version, def))
+			if !slices.Contains(versions, version) {
+				return unsupportedAPIVersion(version, versions)
+			}
+
+			c.SetRequest(req.WithContext(utils.WithAPIVersion(req.Context(), version)))
+			c.Response().Header().Set(header, version)
+			if codec := codecs[version]; codec != nil {
+				resp.SetRequestCodec(c, codec)
+			}
+			return next(c)
+		}
+	}
+}
+
+// ServeVersions returns a middleware rejecting requests whose API version
+// (see APIVersion) is not one of versions with a coded
+// ErrAPIVersionUnsupported (404) listing them. Requests without a version,
+// where APIVersion is not installed, pass.
+func ServeVersions(versions ...string) echo.MiddlewareFunc {
+	normalized := make([]string, len(versions))
+	for i, v := range versions {
+		normalized[i] = normalizeAPIVersion(v)
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			version := utils.GetAPIVersion(c.Request().Context())
+			if version != "" && !slices.Contains(normalized, version) {
+				return unsupportedAPIVersion(version, normalized)
+			}
+			return next(c)
+		}
+	}
+}
+
+// VersionedRoute registers h on g like g.Add for the versions of route
+// (every version when empty, see ServeVersions) and documents it in docs,
+// which may be nil, under its full path. A handler shared by versions can
+// branch on utils.GetAPIVersion.
+func VersionedRoute(g *echo.Group, docs *apidoc.Registry, route apidoc.Route, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	if len(route.Versions) > 0 {
+		versions := make([]string, len(route.Versions))
+		for i, v := range route.Versions {
+			versions[i] = normalizeAPIVersion(v)
+		}
+		route.Versions = versions
+		m = append(slices.Clone(m), ServeVersions(versions...))
+	}
+	r := g.Add(route.Method, route.Path, h, m...)
+	if docs != nil {
+		route.Path = r.Path
+		docs.Add(route)
+	}
+	return r
+}
+
+// unsupportedAPIVersion returns the error of a request for version,
+// listing the supported versions as details.
+func unsupportedAPIVersion(version string, supported []string) error {
+	return errors.WithDetails(
+		code.NewErrorf(code.ErrAPIVersionUnsupported, "api version %s not supported", version),
+		map[string]any{"version": version, "supported": supported})
+}
+
+// pathAPIVersion returns the first path segment naming a version.
+func pathAPIVersion(path string) string {
+	for _, seg := range strings.Split(path, "/") {
+		if versionSegmentRe.MatchString(seg) {
+			return seg
+		}
+	}
+	return ""
+}
+
+// acceptAPIVersion returns the param parameter of the first Accept media
+// range carrying it.
+func acceptAPIVersion(accept []string, param string) string {
+	for _, value := range accept {
+		for _, mediaRange := range strings.Split(value, ",") {
+			_, params, err := mime.ParseMediaType(mediaRange)
+			if err != nil {
+				continue
+			}
+			if v := params[param]; v != "" {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// normalizeAPIVersion lower-cases a version and prefixes it with "v".
+func normalizeAPIVersion(v string) string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if v != "" && !strings.HasPrefix(v, "v") {
+		v = "v" + v
+	}
+	return v
+}
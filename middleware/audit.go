@@ -0,0 +1,305 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/pubsub"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+// auditBodyKey is the echo.Context key for a handler-provided audit body.
+const auditBodyKey = "audit_body"
+
+// AuditRecord is one audited request.
+type AuditRecord struct {
+	ID         uint64            `json:"-" gorm:"primaryKey;autoIncrement"`
+	Time       time.Time         `json:"time" gorm:"not null;index"`
+	RequestID  string            `json:"request_id" gorm:"size:64;index"`
+	Actor      string            `json:"actor" gorm:"size:128;index"`
+	Action     string            `json:"action" gorm:"size:255;not null"`
+	Resource   map[string]string `json:"resource,omitempty" gorm:"serializer:json"`
+	Body       string            `json:"body,omitempty" gorm:"type:text"`
+	Status     int               `json:"status"`
+	Code       int               `json:"code"`
+	ClientIP   string            `json:"client_ip" gorm:"size:64"`
+	DurationMS int64             `json:"duration_ms"`
+}
+
+// TableName implements gorm's Tabler.
+func (AuditRecord) TableName() string {
+	return "audit_records"
+}
+
+// AuditSink persists audit records.
+type AuditSink interface {
+	WriteAudit(ctx context.Context, rec AuditRecord) error
+}
+
+// AuditConfig configures the Audit middleware.
+type AuditConfig struct {
+	// Methods to audit; default POST, PUT, PATCH, DELETE.
+	Methods []string
+	// MaxBodySize caps the recorded body in bytes; default 4096.
+	MaxBodySize int
+	// SensitiveFields are JSON keys redacted from raw bodies; default
+	// password, secret, token, access_token, refresh_token.
+	SensitiveFields []string
+	// Failures counts audit write failures (optional).
+	Failures prometheus.Counter
+	// Skipper skips auditing for matching requests.
+	Skipper func(c echo.Context) bool
+}
+
+// SetAuditBody records dto as the audited request body. Fields tagged with
+// `mask:"..."` are masked via utils.MaskStruct. Without it, the raw JSON body
+// is recorded with AuditConfig.SensitiveFields redacted.
+func SetAuditBody(c echo.Context, dto any) {
+	c.Set(auditBodyKey, dto)
+}
+
+// Audit returns a middleware that records state-changing requests to sink:
+// actor, action (method and route template), path params, masked body,
+// response status and business code, client IP and timing.
+// Sink failures never fail the request; they are logged and counted.
+func Audit(sink AuditSink, cfg AuditConfig) echo.MiddlewareFunc {
+	methods := cfg.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	}
+	maxBody := cfg.MaxBodySize
+	if maxBody <= 0 {
+		maxBody = 4096
+	}
+	sensitive := cfg.SensitiveFields
+	if len(sensitive) == 0 {
+		sensitive = []string{"password", "secret", "token", "access_token", "refresh_token"}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !containsFold(methods, c.Request().Method) || (cfg.Skipper != nil && cfg.Skipper(c)) {
+				return next(c)
+			}
+
+			start := time.Now()
+			raw, berr := CachedBody(c)
+			if berr != nil {
+				raw = peekBody(c.Request(), maxBody)
+			}
+
+			err := next(c)
+
+			rec := AuditRecord{
+				Time:       start,
+				RequestID:  c.Response().Header().Get(echo.HeaderXRequestID),
+				Actor:      requestUserID(c),
+				Action:     c.Request().Method + " " + c.Path(),
+				Resource:   pathParams(c),
+				Body:       auditBody(c, raw, sensitive, maxBody),
+				Status:     responseStatus(c, err),
+				Code:       errors.GetCode(err),
+				ClientIP:   c.RealIP(),
+				DurationMS: time.Since(start).Milliseconds(),
+			}
+
+			ctx := utils.DetachContext(c.Request().Context())
+			if werr := sink.WriteAudit(ctx, rec); werr != nil {
+				if cfg.Failures != nil {
+					cfg.Failures.Inc()
+				}
+				slog.Error("Audit write failed",
+					slog.String("request_id", rec.RequestID),
+					slog.String("action", rec.Action),
+					log.Err(werr),
+				)
+			}
+
+			return err
+		}
+	}
+}
+
+// peekBody reads up to limit+1 bytes of the body and restores it for the
+// handler, for requests that BodyCache does not buffer.
+func peekBody(req *http.Request, limit int) []byte {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	buf, _ := io.ReadAll(io.LimitReader(req.Body, int64(limit)+1))
+	req.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(buf), req.Body), Closer: req.Body}
+	return buf
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// requestUserID returns the authenticated user ID of the request, if any.
+func requestUserID(c echo.Context) string {
+	if uid, ok := c.Get("user_id").(string); ok {
+		return uid
+	}
+	return utils.GetUserID(c.Request().Context())
+}
+
+func pathParams(c echo.Context) map[string]string {
+	names := c.ParamNames()
+	if len(names) == 0 {
+		return nil
+	}
+	params := make(map[string]string, len(names))
+	for _, name := range names {
+		params[name] = c.Param(name)
+	}
+	return params
+}
+
+func auditBody(c echo.Context, raw []byte, sensitive []string, limit int) string {
+	if dto := c.Get(auditBodyKey); dto != nil {
+		data, err := json.Marshal(utils.MaskStruct(dto))
+		if err == nil {
+			return truncate(string(data), limit)
+		}
+	}
+	if len(raw) == 0 {
+		return ""
+	}
+	if len(raw) > limit {
+		// Truncated JSON cannot be parsed reliably; never record it raw.
+		return "<body exceeds audit size cap>"
+	}
+
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "<non-JSON body omitted>"
+	}
+	data, _ := json.Marshal(redactKeys(v, sensitive))
+	return truncate(string(data), limit)
+}
+
+// redactKeys masks values whose key matches one of the sensitive names.
+func redactKeys(v any, sensitive []string) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if containsFold(sensitive, k) {
+				t[k] = utils.MaskSecret("x")
+				continue
+			}
+			t[k] = redactKeys(val, sensitive)
+		}
+		return t
+	case []any:
+		for i := range t {
+			t[i] = redactKeys(t[i], sensitive)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return s[:limit]
+}
+
+// responseStatus determines the final HTTP status, including errors that the
+// error handler has not rendered yet.
+func responseStatus(c echo.Context, err error) int {
+	if err == nil {
+		return c.Response().Status
+	}
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		return he.Code
+	}
+	if code := errors.GetCode(err); code != 0 {
+		return errors.HTTPStatus(code)
+	}
+	return http.StatusInternalServerError
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ========== Sinks ==========
+
+// LogAuditSink writes audit records to a logger under the "audit" group.
+type LogAuditSink struct {
+	logger log.Logger
+}
+
+// NewLogAuditSink creates a log-based audit sink.
+func NewLogAuditSink(logger log.Logger) *LogAuditSink {
+	return &LogAuditSink{logger: logger.WithGroup("audit")}
+}
+
+// WriteAudit implements AuditSink.
+func (s *LogAuditSink) WriteAudit(ctx context.Context, rec AuditRecord) error {
+	s.logger.Info("Audit",
+		slog.Time("time", rec.Time),
+		slog.String("request_id", rec.RequestID),
+		slog.String("actor", rec.Actor),
+		slog.String("action", rec.Action),
+		slog.Any("resource", rec.Resource),
+		slog.String("body", rec.Body),
+		slog.Int("status", rec.Status),
+		slog.Int("code", rec.Code),
+		slog.String("client_ip", rec.ClientIP),
+		slog.Int64("duration_ms", rec.DurationMS),
+	)
+	return nil
+}
+
+// GormAuditSink writes audit records to the audit_records table.
+type GormAuditSink struct {
+	db *gorm.DB
+}
+
+// NewGormAuditSink creates a database audit sink. Migrate AuditRecord first.
+func NewGormAuditSink(db *gorm.DB) *GormAuditSink {
+	return &GormAuditSink{db: db}
+}
+
+// WriteAudit implements AuditSink.
+func (s *GormAuditSink) WriteAudit(ctx context.Context, rec AuditRecord) error {
+	return s.db.WithContext(ctx).Create(&rec).Error
+}
+
+// PublisherAuditSink publishes audit records as events (e.g. to Kafka).
+type PublisherAuditSink struct {
+	pub   pubsub.Publisher
+	topic string
+}
+
+// NewPublisherAuditSink creates an audit sink publishing to topic.
+func NewPublisherAuditSink(pub pubsub.Publisher, topic string) *PublisherAuditSink {
+	return &PublisherAuditSink{pub: pub, topic: topic}
+}
+
+// WriteAudit implements AuditSink.
+func (s *PublisherAuditSink) WriteAudit(ctx context.Context, rec AuditRecord) error {
+	return pubsub.Publish(ctx, s.pub, s.topic, rec)
+}
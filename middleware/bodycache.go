@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultBodyCacheSize is the cap of BodyCache when none is given.
+const DefaultBodyCacheSize = 1 << 20
+
+// ErrBodyTooLarge is returned by CachedBody when the request body exceeds
+// the BodyCache cap. The body is then streamed to the handler untouched;
+// dependent middleware decide whether to skip or reject the request.
+var ErrBodyTooLarge = errors.New("request body exceeds cache limit")
+
+// errBodyCacheMissing is returned by CachedBody without BodyCache.
+var errBodyCacheMissing = errors.New("middleware: BodyCache not installed")
+
+const bodyCacheKey = "middleware.body_cache"
+
+type bodyCache struct {
+	max  int64
+	read bool
+	data []byte
+	err  error
+}
+
+// BodyCache returns a middleware that lets later middleware read the request
+// body through CachedBody without starving the handler. Nothing is read
+// until CachedBody is called; maxBytes caps the buffered body (default
+// DefaultBodyCacheSize). Install it early, before the consumers.
+func BodyCache(maxBytes int64) echo.MiddlewareFunc {
+	if maxBytes <= 0 {
+		maxBytes = DefaultBodyCacheSize
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(bodyCacheKey, &bodyCache{max: maxBytes})
+			return next(c)
+		}
+	}
+}
+
+// CachedBody returns the request body, buffering it on the first call and
+// replacing the request body with a replay of it, so every consumer and
+// the handler see the full body. Bodies over the cap return
+// ErrBodyTooLarge, as do all later calls.
+//
+//	body, err := middleware.CachedBody(c)
+//	if errors.Is(err, middleware.ErrBodyTooLarge) {
+//	    return next(c) // skip verification of large uploads
+//	}
+func CachedBody(c echo.Context) ([]byte, error) {
+	bc, ok := c.Get(bodyCacheKey).(*bodyCache)
+	if !ok {
+		return nil, errBodyCacheMissing
+	}
+	if bc.read {
+		return bc.data, bc.err
+	}
+	bc.read = true
+
+	req := c.Request()
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.ContentLength > bc.max {
+		bc.err = ErrBodyTooLarge
+		return nil, bc.err
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(req.Body, bc.max+1))
+	switch {
+	case err != nil:
+		bc.err = err
+	case int64(len(buf)) > bc.max:
+		bc.err = ErrBodyTooLarge
+	default:
+		bc.data = buf
+		req.Body = readCloser{Reader: bytes.NewReader(buf), Closer: req.Body}
+		return bc.data, nil
+	}
+	// Hand the bytes read so far back to the handler ahead of the rest.
+	req.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(buf), req.Body), Closer: req.Body}
+	return nil, bc.err
+}
@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"net/http"
+
 	"github.com/NSObjects/go-kit/code"
 	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/security"
 	"github.com/casbin/casbin/v2"
 	casbin_mw "github.com/labstack/echo-contrib/casbin"
 	"github.com/labstack/echo/v4"
@@ -12,14 +15,29 @@ import (
 type CasbinConfig struct {
 	// Enabled controls whether Casbin is enabled.
 	Enabled bool
-	// SkipPaths are paths that skip authorization.
+	// SkipPaths are route paths that skip authorization, matched exactly
+	// or, ending in "*", by prefix, unless SkipPatterns is set.
 	SkipPaths []string
+	// SkipPatterns matches SkipPaths as PathMatcher patterns, where a
+	// ":param" segment also matches literal routes such as "/users/me".
+	SkipPatterns bool
+	// SkipMatcher overrides SkipPaths with a precompiled matcher.
+	SkipMatcher *PathMatcher
 	// AdminUsers are users that bypass authorization.
 	AdminUsers []string
 	// UserGetter extracts user from context.
 	UserGetter func(c echo.Context) (string, error)
 	// EnforceHandler performs custom authorization logic.
 	EnforceHandler func(c echo.Context, user string) (bool, error)
+	// Permissions declares the object and action of routes; requests to
+	// declared routes are enforced against them instead of the path.
+	Permissions *PermissionRegistry
+	// Unannotated is the enforcement of routes without a declared
+	// permission: UnannotatedPath (default), UnannotatedDeny or
+	// UnannotatedAllow.
+	Unannotated string
+	// Decisions caches the results of the default enforcement (optional).
+	Decisions *DecisionCache
 }
 
 // DefaultCasbinConfig returns default Casbin configuration.
@@ -45,16 +63,11 @@ func Casbin(enforcer *casbin.Enforcer, config *CasbinConfig) echo.MiddlewareFunc
 
 	cfg := casbin_mw.Config{
 		Enforcer: enforcer,
-		Skipper: func(c echo.Context) bool {
-			path := c.Path()
-			for _, skipPath := range config.SkipPaths {
-				if path == skipPath {
-					return true
-				}
-			}
-			return false
-		},
+		Skipper:  skipPaths(config.SkipMatcher, config.SkipPatterns, config.SkipPaths),
 		ErrorHandler: func(c echo.Context, internal error, proposedStatus int) error {
+			if proposedStatus == http.StatusForbidden {
+				EmitSecurityEvent(c, security.KindPermissionDenied, code.ErrPermissionDenied, nil)
+			}
 			return errors.WrapCode(internal, code.ErrPermissionDenied, "permission denied")
 		},
 	}
@@ -65,6 +78,29 @@ func Casbin(enforcer *casbin.Enforcer, config *CasbinConfig) echo.MiddlewareFunc
 
 	if config.EnforceHandler != nil {
 		cfg.EnforceHandler = config.EnforceHandler
+	} else {
+		// Hold the read lock so CasbinAdmin changes never race enforcement.
+		mu := enforcerLock(enforcer)
+		perms, unannotated, decisions := config.Permissions, config.Unannotated, config.Decisions
+		cfg.EnforceHandler = func(c echo.Context, user string) (bool, error) {
+			obj, act := c.Request().URL.Path, c.Request().Method
+			if p, ok := perms.lookup(act, c.Path()); ok {
+				obj, act = p.Object, p.Action
+			} else {
+				switch unannotated {
+				case UnannotatedDeny:
+					return false, nil
+				case UnannotatedAllow:
+					return true, nil
+				}
+			}
+			if decisions != nil {
+				return decisions.Enforce(user, obj, act)
+			}
+			mu.RLock()
+			defer mu.RUnlock()
+			return enforcer.Enforce(user, obj, act)
+		}
 	}
 
 	return casbin_mw.MiddlewareWithConfig(cfg)
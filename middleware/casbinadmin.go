@@ -0,0 +1,325 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/pubsub"
+	"github.com/NSObjects/go-kit/resp"
+	"github.com/NSObjects/go-kit/security"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultPolicyTopic is the topic of policy invalidations.
+const DefaultPolicyTopic = "casbin.policy.changed"
+
+// enforcerLocks guards policy changes against concurrent enforcement, since
+// casbin.Enforcer is not safe for it. Casbin takes the read lock.
+var enforcerLocks sync.Map // *casbin.Enforcer -> *sync.RWMutex
+
+func enforcerLock(e *casbin.Enforcer) *sync.RWMutex {
+	mu, _ := enforcerLocks.LoadOrStore(e, &sync.RWMutex{})
+	return mu.(*sync.RWMutex)
+}
+
+// NewEnforcer creates an enforcer with the model of cfg (Model text or
+// ModelFile) and the policies of adapter. A nil adapter keeps policies in
+// memory only. Persisting them in the database requires an adapter such
+// as github.com/casbin/gorm-adapter/v3, which projects add directly:
+//
+//	adapter, err := gormadapter.NewAdapterByDB(m.DB)
+//	enforcer, err := middleware.NewEnforcer(cfg.Casbin, adapter)
+func NewEnforcer(cfg config.CasbinConfig, adapter persist.Adapter) (*casbin.Enforcer, error) {
+	var (
+		m   model.Model
+		err error
+	)
+	if cfg.Model != "" {
+		m, err = model.NewModelFromString(cfg.Model)
+	} else {
+		m, err = model.NewModelFromFile(cfg.ModelFile)
+	}
+	if err != nil {
+		return nil, code.WrapError(err, code.ErrStartup, "load casbin model")
+	}
+	if adapter == nil {
+		e, err := casbin.NewEnforcer(m)
+		if err != nil {
+			return nil, code.WrapError(err, code.ErrStartup, "create casbin enforcer")
+		}
+		return e, nil
+	}
+	e, err := casbin.NewEnforcer(m, adapter)
+	if err != nil {
+		return nil, code.WrapError(err, code.ErrStartup, "create casbin enforcer")
+	}
+	return e, nil
+}
+
+// Policy is a permission rule: Subject may perform Action on Object.
+type Policy struct {
+	Subject string `json:"subject" query:"subject"`
+	Object  string `json:"object" query:"object"`
+	Action  string `json:"action" query:"action"`
+}
+
+// roleRequest is the body of POST /roles.
+type roleRequest struct {
+	User   string `json:"user"`
+	Role   string `json:"role"`
+	Revoke bool   `json:"revoke"`
+}
+
+// CasbinAdminOptions configures CasbinAdminHandlers.
+type CasbinAdminOptions struct {
+	// Bus publishes policy invalidations so other instances reload (see
+	// CasbinAdmin.Watch); optional.
+	Bus pubsub.Publisher
+	// Topic of the invalidations; default DefaultPolicyTopic.
+	Topic string
+}
+
+// CasbinAdmin serves the policy admin API of an enforcer.
+type CasbinAdmin struct {
+	enforcer *casbin.Enforcer
+	mu       *sync.RWMutex
+	opts     CasbinAdminOptions
+	instance string
+}
+
+// CasbinAdminHandlers creates the policy admin API of enforcer. Changes
+// take effect immediately, clear the DecisionCaches of the enforcer, are
+// saved with SavePolicy when the enforcer has an adapter, emit
+// security.KindPolicyChanged events and are published to the other
+// instances, which reload and clear theirs.
+//
+// Mount it on the admin group so the API is itself protected by JWT and
+// Casbin. Bootstrap the first administrator with a policy granting the
+// admin role access to the API, e.g. with the keyMatch2 RESTful model:
+//
+//	p, admin, /api/admin/casbin/*, (GET)|(POST)|(DELETE)
+//	g, alice, admin
+//
+//	admin := middleware.CasbinAdminHandlers(enforcer, middleware.CasbinAdminOptions{Bus: bus})
+//	admin.Register(groups.Admin.Group("/casbin"))
+//	go admin.Watch(ctx, bus)
+func CasbinAdminHandlers(enforcer *casbin.Enforcer, opts ...CasbinAdminOptions) *CasbinAdmin {
+	var o CasbinAdminOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Topic == "" {
+		o.Topic = DefaultPolicyTopic
+	}
+	return &CasbinAdmin{
+		enforcer: enforcer,
+		mu:       enforcerLock(enforcer),
+		opts:     o,
+		instance: utils.NewULID(),
+	}
+}
+
+// Register registers the admin API on g:
+//
+//	GET    /policies  list rules, filtered by ?subject= and ?object=
+//	POST   /policies  {"subject","object","action"} adds a rule
+//	DELETE /policies  ?subject=&object=&action= removes a rule
+//	POST   /roles     {"user","role"} assigns a role; "revoke": true revokes it
+//	POST   /reload    reloads the policies from the adapter
+func (a *CasbinAdmin) Register(g *echo.Group) {
+	g.GET("/policies", a.listHandler)
+	g.POST("/policies", a.addHandler)
+	g.DELETE("/policies", a.removeHandler)
+	g.POST("/roles", a.roleHandler)
+	g.POST("/reload", a.reloadHandler)
+}
+
+// Watch reloads the policies when another instance publishes a change,
+// until ctx is canceled.
+func (a *CasbinAdmin) Watch(ctx context.Context, sub pubsub.Subscriber) error {
+	return sub.Subscribe(ctx, a.opts.Topic, func(ctx context.Context, msg pubsub.Message) error {
+		if string(msg.Payload) == a.instance {
+			return nil
+		}
+		if err := a.load(); err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "Casbin policies reloaded")
+		return nil
+	})
+}
+
+func (a *CasbinAdmin) listHandler(c echo.Context) error {
+	a.mu.RLock()
+	rules, err := a.enforcer.GetFilteredPolicy(0, c.QueryParam("subject"), c.QueryParam("object"))
+	a.mu.RUnlock()
+	if err != nil {
+		return code.WrapError(err, code.ErrInternalServer, "list policies")
+	}
+
+	policies := make([]Policy, 0, len(rules))
+	for _, r := range rules {
+		if len(r) < 3 {
+			continue
+		}
+		policies = append(policies, Policy{Subject: r[0], Object: r[1], Action: r[2]})
+	}
+	return resp.ListDataResponse(c, policies, int64(len(policies)))
+}
+
+func (a *CasbinAdmin) addHandler(c echo.Context) error {
+	p, err := bindPolicy(c)
+	if err != nil {
+		return err
+	}
+	return a.change(c, "add_policy", map[string]any{"policy": p}, func() error {
+		added, err := a.enforcer.AddPolicy(p.Subject, p.Object, p.Action)
+		if err != nil {
+			return code.WrapError(err, code.ErrInternalServer, "add policy")
+		}
+		if !added {
+			return code.NewError(code.ErrAlreadyExists, "policy already exists")
+		}
+		return nil
+	})
+}
+
+func (a *CasbinAdmin) removeHandler(c echo.Context) error {
+	p, err := bindPolicy(c)
+	if err != nil {
+		return err
+	}
+	return a.change(c, "remove_policy", map[string]any{"policy": p}, func() error {
+		removed, err := a.enforcer.RemovePolicy(p.Subject, p.Object, p.Action)
+		if err != nil {
+			return code.WrapError(err, code.ErrInternalServer, "remove policy")
+		}
+		if !removed {
+			return code.NewNotFoundError("policy")
+		}
+		return nil
+	})
+}
+
+func (a *CasbinAdmin) roleHandler(c echo.Context) error {
+	var req roleRequest
+	if err := c.Bind(&req); err != nil {
+		return code.WrapError(err, code.ErrBind, "invalid request body")
+	}
+	if req.User == "" {
+		return code.NewValidationError("user", "is required")
+	}
+	if req.Role == "" {
+		return code.NewValidationError("role", "is required")
+	}
+
+	action := "assign_role"
+	if req.Revoke {
+		action = "revoke_role"
+	}
+	return a.change(c, action, map[string]any{"user": req.User, "role": req.Role}, func() error {
+		var (
+			ok  bool
+			err error
+		)
+		if req.Revoke {
+			ok, err = a.enforcer.RemoveGroupingPolicy(req.User, req.Role)
+		} else {
+			ok, err = a.enforcer.AddGroupingPolicy(req.User, req.Role)
+		}
+		if err != nil {
+			return code.WrapError(err, code.ErrInternalServer, action)
+		}
+		if !ok && req.Revoke {
+			return code.NewNotFoundError("role binding")
+		}
+		if !ok {
+			return code.NewError(code.ErrAlreadyExists, "role already assigned")
+		}
+		return nil
+	})
+}
+
+func (a *CasbinAdmin) reloadHandler(c echo.Context) error {
+	if err := a.load(); err != nil {
+		return err
+	}
+	EmitSecurityEvent(c, security.KindPolicyChanged, 0, map[string]any{"action": "reload"})
+	a.publish(c.Request().Context())
+	return resp.OperateSuccess(c)
+}
+
+// change applies fn under the write lock, clears the cached decisions,
+// saves the policies, emits the audit event and publishes the
+// invalidation.
+func (a *CasbinAdmin) change(c echo.Context, action string, details map[string]any, fn func() error) error {
+	a.mu.Lock()
+	err := fn()
+	InvalidateDecisions(a.enforcer)
+	if err == nil && a.enforcer.GetAdapter() != nil {
+		if err = a.enforcer.SavePolicy(); err != nil {
+			err = code.WrapError(err, code.ErrInternalServer, "save policies")
+		}
+	}
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	details["action"] = action
+	EmitSecurityEvent(c, security.KindPolicyChanged, 0, details)
+	a.publish(c.Request().Context())
+	return resp.OperateSuccess(c)
+}
+
+// load reloads the policies from the adapter and clears the cached
+// decisions.
+func (a *CasbinAdmin) load() error {
+	if a.enforcer.GetAdapter() == nil {
+		return code.NewBadRequestError("casbin enforcer has no adapter to reload from")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	err := a.enforcer.LoadPolicy()
+	InvalidateDecisions(a.enforcer)
+	if err != nil {
+		return code.WrapError(err, code.ErrInternalServer, "reload policies")
+	}
+	return nil
+}
+
+// publish notifies the other instances; failures are logged since the
+// change is already applied and saved.
+func (a *CasbinAdmin) publish(ctx context.Context) {
+	if a.opts.Bus == nil {
+		return
+	}
+	msg := pubsub.Message{Topic: a.opts.Topic, Payload: []byte(a.instance)}
+	if err := a.opts.Bus.Publish(ctx, msg); err != nil {
+		slog.ErrorContext(ctx, "Casbin policy invalidation failed", log.Err(err))
+	}
+}
+
+func bindPolicy(c echo.Context) (Policy, error) {
+	var p Policy
+	if err := c.Bind(&p); err != nil {
+		return p, code.WrapError(err, code.ErrBind, "invalid request body")
+	}
+	switch {
+	case p.Subject == "":
+		return p, code.NewValidationError("subject", "is required")
+	case p.Object == "":
+		return p, code.NewValidationError("object", "is required")
+	case p.Action == "":
+		return p, code.NewValidationError("action", "is required")
+	}
+	return p, nil
+}
@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/NSObjects/go-kit/metrics"
+	"github.com/casbin/casbin/v2"
+	"golang.org/x/sync/singleflight"
+)
+
+// Defaults of DecisionCacheOptions.
+const (
+	DefaultDecisionCacheSize = 10000
+	DefaultDecisionCacheTTL  = 5 * time.Second
+)
+
+// decisionCaches are the caches of each enforcer, cleared by
+// InvalidateDecisions.
+var decisionCaches sync.Map // *casbin.Enforcer -> *decisionCacheSet
+
+type decisionCacheSet struct {
+	mu     sync.Mutex
+	caches []*DecisionCache
+}
+
+// DecisionCacheOptions configures a DecisionCache.
+type DecisionCacheOptions struct {
+	// Size is the number of decisions kept, least recently used evicted
+	// first; default DefaultDecisionCacheSize.
+	Size int
+	// TTL bounds the age of a decision; default DefaultDecisionCacheTTL.
+	TTL time.Duration
+	// Metrics records lookups and invalidations (optional).
+	Metrics *metrics.CasbinDecisionMetrics
+}
+
+// DecisionCache caches the results of an enforcer by subject, object and
+// action, in memory. CasbinAdmin clears it on every policy change and
+// reload; call InvalidateDecisions after changing policies otherwise, or
+// rely on the TTL. Concurrent misses of a decision share one enforcement,
+// so a cleared cache does not stampede the enforcer, and failed
+// enforcements are never cached.
+//
+//	decisions := middleware.NewDecisionCache(enforcer, middleware.DecisionCacheOptions{
+//	    Metrics: metrics.NewCasbinDecisionMetrics("app"),
+//	})
+//	e.Use(middleware.Casbin(enforcer, &middleware.CasbinConfig{Enabled: true, Decisions: decisions}))
+type DecisionCache struct {
+	enforcer *casbin.Enforcer
+	lock     *sync.RWMutex
+	opts     DecisionCacheOptions
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // of *decision
+	lru     *list.List               // most recently used first
+	// gen counts invalidations; decisions computed under an older
+	// generation are not stored.
+	gen uint64
+
+	group singleflight.Group
+}
+
+type decision struct {
+	key     string
+	allowed bool
+	expires time.Time
+}
+
+// NewDecisionCache creates a DecisionCache of enforcer.
+func NewDecisionCache(enforcer *casbin.Enforcer, opts ...DecisionCacheOptions) *DecisionCache {
+	var o DecisionCacheOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Size <= 0 {
+		o.Size = DefaultDecisionCacheSize
+	}
+	if o.TTL <= 0 {
+		o.TTL = DefaultDecisionCacheTTL
+	}
+	d := &DecisionCache{
+		enforcer: enforcer,
+		lock:     enforcerLock(enforcer),
+		opts:     o,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+	v, _ := decisionCaches.LoadOrStore(enforcer, &decisionCacheSet{})
+	set := v.(*decisionCacheSet)
+	set.mu.Lock()
+	set.caches = append(set.caches, d)
+	set.mu.Unlock()
+	return d
+}
+
+// Enforce returns the decision of the enforcer for sub, obj and act,
+// cached.
+func (d *DecisionCache) Enforce(sub, obj, act string) (bool, error) {
+	key := sub + "\x00" + obj + "\x00" + act
+	now := time.Now()
+
+	d.mu.Lock()
+	if e, ok := d.entries[key]; ok {
+		dec := e.Value.(*decision)
+		if now.Before(dec.expires) {
+			d.lru.MoveToFront(e)
+			d.mu.Unlock()
+			d.observe("hit")
+			return dec.allowed, nil
+		}
+		d.remove(e)
+	}
+	gen := d.gen
+	d.mu.Unlock()
+	d.observe("miss")
+
+	// The generation keys the flight, so that callers arriving after an
+	// invalidation never share an enforcement started before it.
+	v, err, _ := d.group.Do(strconv.FormatUint(gen, 10)+"\x00"+key, func() (any, error) {
+		d.lock.RLock()
+		allowed, err := d.enforcer.Enforce(sub, obj, act)
+		d.lock.RUnlock()
+		if err != nil {
+			return false, err
+		}
+		d.store(gen, key, allowed)
+		return allowed, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return v.(bool), nil
+}
+
+// store caches a decision computed under generation gen.
+func (d *DecisionCache) store(gen uint64, key string, allowed bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if gen != d.gen {
+		return
+	}
+	if e, ok := d.entries[key]; ok {
+		d.remove(e)
+	}
+	d.entries[key] = d.lru.PushFront(&decision{key: key, allowed: allowed, expires: time.Now().Add(d.opts.TTL)})
+	for d.lru.Len() > d.opts.Size {
+		d.remove(d.lru.Back())
+	}
+}
+
+func (d *DecisionCache) remove(e *list.Element) {
+	delete(d.entries, d.lru.Remove(e).(*decision).key)
+}
+
+// Invalidate drops every decision, including those being computed.
+func (d *DecisionCache) Invalidate() {
+	d.mu.Lock()
+	d.gen++
+	clear(d.entries)
+	d.lru.Init()
+	d.mu.Unlock()
+	if d.opts.Metrics != nil {
+		d.opts.Metrics.Invalidations.Inc()
+	}
+}
+
+// Len returns the number of cached decisions.
+func (d *DecisionCache) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lru.Len()
+}
+
+func (d *DecisionCache) observe(result string) {
+	if d.opts.Metrics != nil {
+		d.opts.Metrics.Lookups.WithLabelValues(result).Inc()
+	}
+}
+
+// InvalidateDecisions clears the DecisionCaches of enforcer, for policy
+// changes made outside CasbinAdmin, such as in the update callback of a
+// Casbin watcher:
+//
+//	watcher.SetUpdateCallback(func(string) {
+//	    _ = enforcer.LoadPolicy()
+//	    middleware.InvalidateDecisions(enforcer)
+//	})
+func InvalidateDecisions(enforcer *casbin.Enforcer) {
+	v, ok := decisionCaches.Load(enforcer)
+	if !ok {
+		return
+	}
+	set := v.(*decisionCacheSet)
+	set.mu.Lock()
+	caches := set.caches
+	set.mu.Unlock()
+	for _, d := range caches {
+		d.Invalidate()
+	}
+}
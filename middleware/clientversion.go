@@ -0,0 +1,302 @@
+package middleware
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/NSObjects/go-kit/apidoc"
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// Headers read by ClientVersion by default.
+const (
+	DefaultClientVersionHeader  = "X-Client-Version"
+	DefaultClientPlatformHeader = "X-Client-Platform"
+)
+
+// Policies for requests without a valid client version (see
+// ClientVersionConfig.Missing).
+const (
+	// MissingVersionAllow lets them through (default), e.g. for browsers.
+	MissingVersionAllow = "allow"
+	// MissingVersionDeny rejects them where a minimum applies.
+	MissingVersionDeny = "deny"
+)
+
+// ClientVersionConfig holds client version gating configuration.
+type ClientVersionConfig struct {
+	// VersionHeader carries the app version, a semantic version; default
+	// DefaultClientVersionHeader.
+	VersionHeader string
+	// PlatformHeader carries the platform, e.g. "ios"; default
+	// DefaultClientPlatformHeader.
+	PlatformHeader string
+
+	// Minimum is the minimum version of routes not in Routes; empty
+	// admits every version.
+	Minimum string
+	// Routes maps route patterns (see PathMatcher) to their minimum, over
+	// Minimum; the longest matching pattern wins. An empty minimum exempts
+	// the routes.
+	Routes map[string]string
+	// Missing is the policy for requests without a valid version:
+	// MissingVersionAllow (default) or MissingVersionDeny.
+	Missing string
+	// UpgradeURL is returned to rejected clients.
+	UpgradeURL string
+	// UpgradeURLs are the upgrade URLs by platform, over UpgradeURL.
+	UpgradeURLs map[string]string
+
+	// Registry flags deprecated routes (see apidoc.Route.Deprecated),
+	// whose responses get Deprecation, Sunset and Link headers (optional).
+	Registry *apidoc.Registry
+	// Skipper skips the middleware for matching requests.
+	Skipper func(c echo.Context) bool
+}
+
+// clientVersionSettings are the hot-swappable minimums of a
+// ClientVersionGate.
+type clientVersionSettings struct {
+	minimum     *utils.Version
+	routes      []routeMinimum // longest pattern first
+	denyMissing bool
+	upgradeURL  string
+	upgradeURLs map[string]string
+}
+
+type routeMinimum struct {
+	pattern string
+	matcher *PathMatcher
+	minimum *utils.Version
+}
+
+// minimumFor returns the minimum version of the route, nil for none.
+func (s *clientVersionSettings) minimumFor(method, path string) *utils.Version {
+	for _, r := range s.routes {
+		if r.matcher.Match(method, path) {
+			return r.minimum
+		}
+	}
+	return s.minimum
+}
+
+// ClientVersionGate rejects clients below a minimum app version with a
+// coded ErrUpgradeRequired (426) carrying the upgrade URL, and stores the
+// version and platform of clients in the request context (see
+// utils.GetClientVersion and utils.GetClientPlatform).
+type ClientVersionGate struct {
+	settings       atomic.Pointer[clientVersionSettings]
+	versionHeader  string
+	platformHeader string
+	registry       *apidoc.Registry
+	skipper        func(c echo.Context) bool
+}
+
+// ClientVersion returns a middleware gating client versions. It panics on
+// an invalid version or route pattern.
+//
+//	e.Use(middleware.ClientVersion(middleware.ClientVersionConfig{
+//	    Minimum:    "2.0.0",
+//	    Routes:     map[string]string{"POST /api/orders": "2.3.0"},
+//	    UpgradeURL: "https://example.com/download",
+//	    Registry:   docs,
+//	}))
+func ClientVersion(cfg ClientVersionConfig) echo.MiddlewareFunc {
+	g, err := NewClientVersionGate(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return g.Middleware()
+}
+
+// NewClientVersionGate creates a ClientVersionGate whose minimums can be
+// replaced at runtime with Update.
+func NewClientVersionGate(cfg ClientVersionConfig) (*ClientVersionGate, error) {
+	g := &ClientVersionGate{
+		versionHeader:  cmp.Or(cfg.VersionHeader, DefaultClientVersionHeader),
+		platformHeader: cmp.Or(cfg.PlatformHeader, DefaultClientPlatformHeader),
+		registry:       cfg.Registry,
+		skipper:        cfg.Skipper,
+	}
+	err := g.Update(config.ClientVersionConfig{
+		Minimum:     cfg.Minimum,
+		Routes:      cfg.Routes,
+		Missing:     cfg.Missing,
+		UpgradeURL:  cfg.UpgradeURL,
+		UpgradeURLs: cfg.UpgradeURLs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Update replaces the minimums. Invalid settings are rejected as a whole
+// and the current ones kept.
+func (g *ClientVersionGate) Update(cfg config.ClientVersionConfig) error {
+	s := &clientVersionSettings{
+		upgradeURL:  cfg.UpgradeURL,
+		upgradeURLs: make(map[string]string, len(cfg.UpgradeURLs)),
+	}
+	switch cfg.Missing {
+	case "", MissingVersionAllow:
+	case MissingVersionDeny:
+		s.denyMissing = true
+	default:
+		return fmt.Errorf("client version: unknown missing policy %q", cfg.Missing)
+	}
+	for platform, url := range cfg.UpgradeURLs {
+		s.upgradeURLs[strings.ToLower(platform)] = url
+	}
+
+	var err error
+	if s.minimum, err = parseMinimum(cfg.Minimum); err != nil {
+		return err
+	}
+	for pattern, minimum := range cfg.Routes {
+		matcher, err := NewPathMatcher(pattern)
+		if err != nil {
+			return fmt.Errorf("client version: %w", err)
+		}
+		v, err := parseMinimum(minimum)
+		if err != nil {
+			return fmt.Errorf("client version of %s: %w", pattern, err)
+		}
+		s.routes = append(s.routes, routeMinimum{pattern: pattern, matcher: matcher, minimum: v})
+	}
+	slices.SortFunc(s.routes, func(a, b routeMinimum) int {
+		if c := cmp.Compare(len(b.pattern), len(a.pattern)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.pattern, b.pattern)
+	})
+
+	g.settings.Store(s)
+	return nil
+}
+
+// parseMinimum parses a minimum version; empty is none.
+func parseMinimum(s string) (*utils.Version, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	v, err := utils.ParseVersion(s)
+	if err != nil {
+		return nil, fmt.Errorf("client version: %w", err)
+	}
+	return &v, nil
+}
+
+// WatchClientVersion keeps g in sync with store until ctx is done.
+// Invalid settings are logged and ignored.
+//
+//	go middleware.WatchClientVersion(ctx, gate, store, func(c config.Config) config.ClientVersionConfig {
+//	    return c.ClientVersion
+//	})
+func WatchClientVersion[T any](ctx context.Context, g *ClientVersionGate, store *config.Store[T], get func(T) config.ClientVersionConfig) {
+	events := store.SubscribeEvents()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			if err := g.Update(get(ev.New)); err != nil {
+				slog.Warn("Client version config not applied", log.Err(err))
+			}
+		}
+	}
+}
+
+// Middleware returns the gating middleware.
+func (g *ClientVersionGate) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if g.skipper != nil && g.skipper(c) {
+				return next(c)
+			}
+			req := c.Request()
+			ctx := req.Context()
+			platform := strings.ToLower(strings.TrimSpace(req.Header.Get(g.platformHeader)))
+			if platform != "" {
+				ctx = utils.WithClientPlatform(ctx, platform)
+			}
+			// Invalid versions are treated as missing.
+			version, err := utils.ParseVersion(req.Header.Get(g.versionHeader))
+			hasVersion := err == nil && req.Header.Get(g.versionHeader) != ""
+			if hasVersion {
+				ctx = utils.WithClientVersion(ctx, version)
+			}
+			c.SetRequest(req.WithContext(ctx))
+
+			method, path := req.Method, c.Path()
+			g.deprecationHeaders(c, method, path)
+
+			s := g.settings.Load()
+			minimum := s.minimumFor(method, path)
+			switch {
+			case minimum == nil:
+			case !hasVersion:
+				if s.denyMissing {
+					return g.reject(s, platform, nil, minimum)
+				}
+			case version.Less(*minimum):
+				return g.reject(s, platform, &version, minimum)
+			}
+			return next(c)
+		}
+	}
+}
+
+// deprecationHeaders flags responses of deprecated routes: Deprecation
+// (draft-ietf-httpapi-deprecation-header), Sunset (RFC 8594) and a Link to
+// the replacement.
+func (g *ClientVersionGate) deprecationHeaders(c echo.Context, method, path string) {
+	if g.registry == nil {
+		return
+	}
+	route, ok := g.registry.Lookup(method, path)
+	if !ok || !route.Deprecated {
+		return
+	}
+	h := c.Response().Header()
+	h.Set("Deprecation", "true")
+	if !route.Sunset.IsZero() {
+		h.Set("Sunset", route.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if route.Link != "" {
+		h.Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", route.Link))
+	}
+}
+
+// ClientUpgrade is the data of ErrUpgradeRequired responses.
+type ClientUpgrade struct {
+	MinimumVersion string `json:"minimum_version"`
+	CurrentVersion string `json:"current_version,omitempty"`
+	UpgradeURL     string `json:"upgrade_url,omitempty"`
+}
+
+func (g *ClientVersionGate) reject(s *clientVersionSettings, platform string, version, minimum *utils.Version) error {
+	details := ClientUpgrade{
+		MinimumVersion: minimum.String(),
+		UpgradeURL:     cmp.Or(s.upgradeURLs[platform], s.upgradeURL),
+	}
+	var err error
+	if version == nil {
+		err = code.NewErrorf(code.ErrUpgradeRequired, "client version missing, minimum %s", minimum)
+	} else {
+		details.CurrentVersion = version.String()
+		err = code.NewErrorf(code.ErrUpgradeRequired, "client version %s below minimum %s", version, minimum)
+	}
+	return errors.WithDetails(err, details)
+}
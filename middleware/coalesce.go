@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/sync/singleflight"
+)
+
+// Response headers set on coalesced responses for debugging.
+const (
+	HeaderCoalescedFrom  = "X-Coalesced-From"
+	HeaderCoalescedTrace = "X-Coalesced-Trace-Id"
+)
+
+// CoalesceConfig holds request coalescing configuration.
+type CoalesceConfig struct {
+	// Vary lists request headers that are part of the key (e.g. Accept-Language).
+	Vary []string
+	// MaxBodySize caps the buffered response; larger responses are not
+	// shared. Default 1 MB.
+	MaxBodySize int
+	// PerUser adds the authenticated user_id to the key. Without it,
+	// requests carrying a user are never coalesced. Requests with an
+	// Authorization or Cookie header but no verified user_id, e.g. with
+	// the middleware mounted before authentication, are never coalesced
+	// either.
+	PerUser bool
+	// Skipper skips coalescing for matching requests.
+	Skipper func(c echo.Context) bool
+}
+
+// coalescedResponse is the captured response of the leading request.
+type coalescedResponse struct {
+	status    int
+	header    http.Header
+	body      []byte
+	requestID string
+	traceID   string
+	shareable bool
+}
+
+// Coalesce returns a middleware that collapses identical concurrent GET
+// requests into a single handler execution. Followers receive a copy of the
+// leader's response when it is a complete 2xx; otherwise they run the
+// handler themselves.
+func Coalesce(cfg CoalesceConfig) echo.MiddlewareFunc {
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 1 << 20
+	}
+	var group singleflight.Group
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.Method != http.MethodGet || (cfg.Skipper != nil && cfg.Skipper(c)) {
+				return next(c)
+			}
+			key, ok := coalesceKey(c, cfg)
+			if !ok {
+				return next(c)
+			}
+
+			leader := false
+			var leaderErr error
+			v, _, _ := group.Do(key, func() (any, error) {
+				leader = true
+				res, err := captureResponse(c, next, cfg.MaxBodySize)
+				leaderErr = err
+				return res, nil
+			})
+			if leader {
+				return leaderErr
+			}
+
+			res := v.(*coalescedResponse)
+			if !res.shareable {
+				return next(c)
+			}
+			h := c.Response().Header()
+			for k, vs := range res.header {
+				if k == echo.HeaderXRequestID || k == "Set-Cookie" {
+					continue
+				}
+				h[k] = append([]string(nil), vs...)
+			}
+			h.Set(HeaderCoalescedFrom, res.requestID)
+			if res.traceID != "" {
+				h.Set(HeaderCoalescedTrace, res.traceID)
+			}
+			c.Response().WriteHeader(res.status)
+			_, err := c.Response().Write(res.body)
+			return err
+		}
+	}
+}
+
+// coalesceKey builds the request key. It reports false when the request is
+// user-specific and PerUser is off, or carries credentials not verified
+// yet.
+func coalesceKey(c echo.Context, cfg CoalesceConfig) (string, bool) {
+	req := c.Request()
+	var b strings.Builder
+	b.WriteString(req.URL.Path)
+	b.WriteByte('?')
+	b.WriteString(req.URL.RawQuery)
+	for _, name := range cfg.Vary {
+		b.WriteByte('\n')
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(req.Header.Get(name))
+	}
+
+	userID := requestUserID(c)
+	switch {
+	case userID != "":
+		if !cfg.PerUser {
+			return "", false
+		}
+		b.WriteString("\nuser:")
+		b.WriteString(userID)
+	case req.Header.Get(echo.HeaderAuthorization) != "" || req.Header.Get("Cookie") != "":
+		// Credentials no middleware has verified: the response may
+		// depend on them, and only a verified user_id can key it.
+		return "", false
+	}
+	return b.String(), true
+}
+
+// captureResponse runs next while teeing the response into a buffer.
+func captureResponse(c echo.Context, next echo.HandlerFunc, limit int) (*coalescedResponse, error) {
+	rec := &teeWriter{ResponseWriter: c.Response().Writer, limit: limit}
+	c.Response().Writer = rec
+	err := next(c)
+	c.Response().Writer = rec.ResponseWriter
+
+	status := c.Response().Status
+	return &coalescedResponse{
+		status:    status,
+		header:    c.Response().Header().Clone(),
+		body:      rec.buf.Bytes(),
+		requestID: c.Response().Header().Get(echo.HeaderXRequestID),
+		traceID:   utils.GetTraceID(c.Request().Context()),
+		shareable: err == nil && c.Response().Committed && !rec.overflow && status >= 200 && status < 300,
+	}, err
+}
+
+// teeWriter copies written bytes into a size-capped buffer.
+type teeWriter struct {
+	http.ResponseWriter
+	buf      bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (w *teeWriter) Write(p []byte) (int, error) {
+	if !w.overflow {
+		if w.buf.Len()+len(p) > w.limit {
+			w.overflow = true
+			w.buf.Reset()
+		} else {
+			w.buf.Write(p)
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher; streamed responses are not shared.
+func (w *teeWriter) Flush() {
+	w.overflow = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,312 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/metrics"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultQueueTimeout is the wait limit when QueueTimeout is unset.
+const defaultQueueTimeout = time.Second
+
+var (
+	errQueueFull    = errors.New("queue full")
+	errQueueTimeout = errors.New("queue timeout")
+)
+
+// ConcurrencyConfig holds concurrency limit (bulkhead) configuration.
+// Limits are keyed by route template (c.Path()) or by the group name given
+// to ConcurrencyLimiter.Group; a limit of 0 means unlimited.
+type ConcurrencyConfig struct {
+	// Default is the limit for keys not in Limits.
+	Default int
+	// Limits maps route templates or group names to their limit.
+	Limits map[string]int
+	// MaxQueue bounds the waiters per key; 0 rejects as soon as the limit
+	// is reached.
+	MaxQueue int
+	// QueueTimeout bounds the wait for a slot; default 1s.
+	QueueTimeout time.Duration
+	// Metrics records in-flight, queued and rejected counts per key.
+	Metrics *metrics.ConcurrencyMetrics
+	// Skipper skips limiting for matching requests.
+	Skipper func(c echo.Context) bool
+}
+
+// concurrencySettings are the hot-swappable limits of a ConcurrencyLimiter.
+type concurrencySettings struct {
+	def      int
+	limits   map[string]int
+	maxQueue int
+	timeout  time.Duration
+}
+
+func (s *concurrencySettings) limit(key string) int {
+	if n, ok := s.limits[key]; ok {
+		return n
+	}
+	return s.def
+}
+
+// ConcurrencyLimiter enforces a maximum number of in-flight requests per
+// key. Requests over the limit wait in a bounded FIFO queue and are
+// rejected with a coded ErrServiceUnavailable and Retry-After when the
+// queue is full or the wait times out.
+type ConcurrencyLimiter struct {
+	settings atomic.Pointer[concurrencySettings]
+	metrics  *metrics.ConcurrencyMetrics
+	skipper  func(c echo.Context) bool
+
+	mu        sync.Mutex
+	bulkheads map[string]*bulkhead
+}
+
+// ConcurrencyLimit returns a middleware limiting in-flight requests per
+// route template.
+//
+//	e.Use(middleware.ConcurrencyLimit(middleware.ConcurrencyConfig{
+//	    Default:  100,
+//	    Limits:   map[string]int{"/api/reports/:id": 4},
+//	    MaxQueue: 20,
+//	}))
+func ConcurrencyLimit(cfg ConcurrencyConfig) echo.MiddlewareFunc {
+	return NewConcurrencyLimiter(cfg).Middleware()
+}
+
+// NewConcurrencyLimiter creates a ConcurrencyLimiter whose limits can be
+// replaced at runtime with Update.
+func NewConcurrencyLimiter(cfg ConcurrencyConfig) *ConcurrencyLimiter {
+	l := &ConcurrencyLimiter{
+		metrics:   cfg.Metrics,
+		skipper:   cfg.Skipper,
+		bulkheads: make(map[string]*bulkhead),
+	}
+	l.Update(config.ConcurrencyConfig{
+		Default:      cfg.Default,
+		Limits:       cfg.Limits,
+		MaxQueue:     cfg.MaxQueue,
+		QueueTimeout: cfg.QueueTimeout,
+	})
+	return l
+}
+
+// Update replaces the limits. Lowered limits take effect as in-flight
+// requests finish; raised limits admit queued requests immediately.
+func (l *ConcurrencyLimiter) Update(cfg config.ConcurrencyConfig) {
+	s := &concurrencySettings{
+		def:      cfg.Default,
+		limits:   make(map[string]int, len(cfg.Limits)),
+		maxQueue: max(cfg.MaxQueue, 0),
+		timeout:  cfg.QueueTimeout,
+	}
+	for k, v := range cfg.Limits {
+		s.limits[k] = v
+	}
+	if s.timeout <= 0 {
+		s.timeout = defaultQueueTimeout
+	}
+	l.settings.Store(s)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, b := range l.bulkheads {
+		b.setLimit(s.limit(key))
+	}
+}
+
+// WatchConcurrencyLimits keeps l in sync with store until ctx is done.
+//
+//	go middleware.WatchConcurrencyLimits(ctx, limiter, store, func(c config.Config) config.ConcurrencyConfig {
+//	    return c.Concurrency
+//	})
+func WatchConcurrencyLimits[T any](ctx context.Context, l *ConcurrencyLimiter, store *config.Store[T], get func(T) config.ConcurrencyConfig) {
+	events := store.SubscribeEvents()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			l.Update(get(ev.New))
+		}
+	}
+}
+
+// Middleware returns a middleware limiting requests per route template.
+// Requests that matched no route are not limited.
+func (l *ConcurrencyLimiter) Middleware() echo.MiddlewareFunc {
+	return l.middleware(func(c echo.Context) string { return c.Path() })
+}
+
+// Group returns a middleware that shares one limit, configured under name,
+// across every route it is applied to.
+//
+//	reports := e.Group("/api/reports", limiter.Group("reports"))
+func (l *ConcurrencyLimiter) Group(name string) echo.MiddlewareFunc {
+	return l.middleware(func(echo.Context) string { return name })
+}
+
+func (l *ConcurrencyLimiter) middleware(keyOf func(c echo.Context) string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if l.skipper != nil && l.skipper(c) {
+				return next(c)
+			}
+			key := keyOf(c)
+			if key == "" {
+				return next(c)
+			}
+
+			s := l.settings.Load()
+			b := l.bulkhead(key)
+			if err := b.acquire(c.Request().Context(), s.maxQueue, s.timeout); err != nil {
+				return l.reject(c, key, s, err)
+			}
+			defer b.release()
+			return next(c)
+		}
+	}
+}
+
+// bulkhead returns the bulkhead of key, creating it with the current limit.
+func (l *ConcurrencyLimiter) bulkhead(key string) *bulkhead {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.bulkheads[key]
+	if !ok {
+		b = &bulkhead{limit: l.settings.Load().limit(key)}
+		if l.metrics != nil {
+			b.inFlightGauge = l.metrics.InFlight.WithLabelValues(key)
+			b.queuedGauge = l.metrics.Queued.WithLabelValues(key)
+		}
+		l.bulkheads[key] = b
+	}
+	return b
+}
+
+func (l *ConcurrencyLimiter) reject(c echo.Context, key string, s *concurrencySettings, err error) error {
+	reason := "timeout"
+	switch {
+	case errors.Is(err, errQueueFull):
+		reason = "queue_full"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		reason = "canceled"
+	}
+	if l.metrics != nil {
+		l.metrics.Rejected.WithLabelValues(key, reason).Inc()
+	}
+	slog.Warn("Concurrency limit exceeded",
+		slog.String("key", key),
+		slog.String("reason", reason),
+		slog.String("uri", c.Request().RequestURI),
+		log.Err(err),
+	)
+
+	retryAfter := int(math.Ceil(s.timeout.Seconds()))
+	c.Response().Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	return code.NewError(code.ErrServiceUnavailable, "too many concurrent requests")
+}
+
+// bulkhead is a counting semaphore with an adjustable limit and a FIFO
+// wait queue.
+type bulkhead struct {
+	mu       sync.Mutex
+	limit    int // <= 0 is unlimited
+	inFlight int
+	waiters  []chan struct{}
+
+	inFlightGauge prometheus.Gauge
+	queuedGauge   prometheus.Gauge
+}
+
+// acquire takes a slot, waiting up to timeout when maxQueue allows.
+func (b *bulkhead) acquire(ctx context.Context, maxQueue int, timeout time.Duration) error {
+	b.mu.Lock()
+	if len(b.waiters) == 0 && b.available() {
+		b.inFlight++
+		b.observe()
+		b.mu.Unlock()
+		return nil
+	}
+	if len(b.waiters) >= maxQueue {
+		b.mu.Unlock()
+		return errQueueFull
+	}
+	ready := make(chan struct{})
+	b.waiters = append(b.waiters, ready)
+	b.observe()
+	b.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-ready:
+		return nil
+	case <-timer.C:
+		err = errQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, w := range b.waiters {
+		if w == ready {
+			b.waiters = append(b.waiters[:i], b.waiters[i+1:]...)
+			b.observe()
+			return err
+		}
+	}
+	// The slot was granted while the wait ended; keep it.
+	return nil
+}
+
+func (b *bulkhead) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.inFlight--
+	b.grant()
+}
+
+func (b *bulkhead) setLimit(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limit = n
+	b.grant()
+}
+
+// grant hands free slots to waiters in arrival order. Callers hold b.mu.
+func (b *bulkhead) grant() {
+	for len(b.waiters) > 0 && b.available() {
+		close(b.waiters[0])
+		b.waiters[0] = nil
+		b.waiters = b.waiters[1:]
+		b.inFlight++
+	}
+	b.observe()
+}
+
+func (b *bulkhead) available() bool {
+	return b.limit <= 0 || b.inFlight < b.limit
+}
+
+// observe publishes the counts. Callers hold b.mu.
+func (b *bulkhead) observe() {
+	if b.inFlightGauge != nil {
+		b.inFlightGauge.Set(float64(b.inFlight))
+		b.queuedGauge.Set(float64(len(b.waiters)))
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/resp"
+	"github.com/labstack/echo/v4"
+)
+
+// ConfigDump is the response of ConfigHandler.
+type ConfigDump struct {
+	Meta   config.Meta `json:"meta"`
+	Config any         `json:"config"`
+}
+
+// ConfigHandler returns an endpoint that dumps the currently loaded
+// configuration with sensitive fields masked (see config.Redacted).
+//
+// The endpoint exposes deployment details and must only be mounted on an
+// internal router, e.g.:
+//
+//	debug := e.Group("/debug", internalOnly)
+//	debug.GET("/config", middleware.ConfigHandler(store))
+func ConfigHandler[T any](store *config.Store[T]) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return resp.SuccessJSON(c, ConfigDump{
+			Meta:   store.Meta(),
+			Config: config.Redacted(store.Current()),
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package middleware
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/labstack/echo/v4"
+	echomw "github.com/labstack/echo/v4/middleware"
+	"golang.org/x/sync/singleflight"
+)
+
+// Defaults applied when the corresponding CORSOptions field is zero.
+const (
+	DefaultCORSResolverTTL      = 5 * time.Minute
+	DefaultCORSResolverErrorTTL = 5 * time.Second
+	DefaultCORSResolverTimeout  = 2 * time.Second
+	DefaultCORSCacheSize        = 10000
+)
+
+// AllowOriginResolver reports whether origin, e.g. "https://shop.example.com",
+// is allowed. It is consulted for the origins not in the static list and
+// may look them up in a database or cache; its results are cached per
+// origin (see CORSOptions.ResolverTTL).
+type AllowOriginResolver func(ctx context.Context, origin string) (bool, error)
+
+// CORSOptions configure the dynamic parts of a CORSPolicy.
+type CORSOptions struct {
+	// AllowOriginResolver resolves the origins not in AllowOrigins
+	// (optional). An error denies the origin and is logged with it.
+	AllowOriginResolver AllowOriginResolver
+	// GroupResolvers replace AllowOriginResolver for the paths below
+	// their key, e.g. "/api/admin"; a nil resolver disables resolution
+	// for the group. A key without a group in config.CORSConfig.Groups
+	// gets the top-level settings.
+	GroupResolvers map[string]AllowOriginResolver
+	// ResolverTTL is how long an answer of the resolver is cached;
+	// default DefaultCORSResolverTTL.
+	ResolverTTL time.Duration
+	// ResolverErrorTTL is how long an origin stays denied after a
+	// resolver error; default DefaultCORSResolverErrorTTL.
+	ResolverErrorTTL time.Duration
+	// ResolverTimeout bounds each resolver call; default
+	// DefaultCORSResolverTimeout.
+	ResolverTimeout time.Duration
+	// CacheSize bounds the cached answers; default DefaultCORSCacheSize.
+	CacheSize int
+}
+
+// corsRule is the policy of the paths below prefix ("" for the top level).
+type corsRule struct {
+	prefix      string
+	any         bool
+	exact       map[string]bool
+	patterns    [][2]string // prefix and suffix around the wildcard
+	methods     string
+	headers     string // empty reflects Access-Control-Request-Headers
+	credentials bool
+	resolver    AllowOriginResolver
+}
+
+// corsSettings are the hot-swappable rules of a CORSPolicy, the longest
+// prefix first and the top level last.
+type corsSettings struct {
+	rules []*corsRule
+}
+
+func (s *corsSettings) match(path string) *corsRule {
+	for _, r := range s.rules {
+		if r.prefix == "" || path == r.prefix || strings.HasPrefix(path, r.prefix+"/") {
+			return r
+		}
+	}
+	return nil
+}
+
+type corsEntry struct {
+	allowed bool
+	expires time.Time
+}
+
+// CORSPolicy answers CORS requests from the static origins of a
+// config.CORSConfig, replaceable at runtime with Update, and from an
+// optional AllowOriginResolver whose answers are cached per origin. Paths
+// below a group prefix get the policy of the longest matching group.
+type CORSPolicy struct {
+	settings atomic.Pointer[corsSettings]
+	opts     CORSOptions
+
+	group singleflight.Group
+	mu    sync.Mutex
+	cache map[string]corsEntry
+}
+
+// CORS returns a CORS middleware configured from cfg.
+func CORS(cfg config.CORSConfig) echo.MiddlewareFunc {
+	return NewCORSPolicy(cfg).Middleware()
+}
+
+// NewCORSPolicy creates a CORSPolicy for cfg and opts.
+//
+//	cors := middleware.NewCORSPolicy(cfg.CORS, middleware.CORSOptions{
+//	    AllowOriginResolver: func(ctx context.Context, origin string) (bool, error) {
+//	        return domains.IsAllowed(ctx, origin)
+//	    },
+//	})
+//	groups := middleware.Setup(e, middleware.SetupDeps{Config: cfg, CORS: cors})
+func NewCORSPolicy(cfg config.CORSConfig, opts ...CORSOptions) *CORSPolicy {
+	var o CORSOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.ResolverTTL <= 0 {
+		o.ResolverTTL = DefaultCORSResolverTTL
+	}
+	if o.ResolverErrorTTL <= 0 {
+		o.ResolverErrorTTL = DefaultCORSResolverErrorTTL
+	}
+	if o.ResolverTimeout <= 0 {
+		o.ResolverTimeout = DefaultCORSResolverTimeout
+	}
+	if o.CacheSize <= 0 {
+		o.CacheSize = DefaultCORSCacheSize
+	}
+	p := &CORSPolicy{opts: o, cache: make(map[string]corsEntry)}
+	p.Update(cfg)
+	return p
+}
+
+// Update replaces the static settings and drops the cached resolver
+// answers. Groups with empty AllowMethods or AllowHeaders inherit the
+// top-level ones.
+func (p *CORSPolicy) Update(cfg config.CORSConfig) {
+	top := newCORSRule("", cfg, p.opts.AllowOriginResolver)
+	s := &corsSettings{}
+	for prefix, g := range cfg.Groups {
+		if len(g.AllowMethods) == 0 {
+			g.AllowMethods = cfg.AllowMethods
+		}
+		if len(g.AllowHeaders) == 0 {
+			g.AllowHeaders = cfg.AllowHeaders
+		}
+		s.rules = append(s.rules, newCORSRule(prefix, g, p.groupResolver(prefix)))
+	}
+	for prefix := range p.opts.GroupResolvers {
+		if _, ok := cfg.Groups[prefix]; !ok {
+			s.rules = append(s.rules, newCORSRule(prefix, cfg, p.groupResolver(prefix)))
+		}
+	}
+	slices.SortFunc(s.rules, func(a, b *corsRule) int {
+		return cmp.Or(cmp.Compare(len(b.prefix), len(a.prefix)), strings.Compare(a.prefix, b.prefix))
+	})
+	s.rules = append(s.rules, top)
+	p.settings.Store(s)
+	p.Invalidate()
+}
+
+func (p *CORSPolicy) groupResolver(prefix string) AllowOriginResolver {
+	if r, ok := p.opts.GroupResolvers[prefix]; ok {
+		return r
+	}
+	return p.opts.AllowOriginResolver
+}
+
+func newCORSRule(prefix string, cfg config.CORSConfig, resolver AllowOriginResolver) *corsRule {
+	methods := cfg.AllowMethods
+	if len(methods) == 0 {
+		methods = echomw.DefaultCORSConfig.AllowMethods
+	}
+	r := &corsRule{
+		prefix:      strings.TrimSuffix(prefix, "/"),
+		exact:       make(map[string]bool),
+		methods:     strings.Join(methods, ","),
+		headers:     strings.Join(cfg.AllowHeaders, ","),
+		credentials: cfg.AllowCredentials,
+		resolver:    resolver,
+	}
+	for _, origin := range cfg.AllowOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		switch before, after, ok := strings.Cut(origin, "*"); {
+		case origin == "*":
+			r.any = true
+		case ok:
+			r.patterns = append(r.patterns, [2]string{before, after})
+		default:
+			r.exact[origin] = true
+		}
+	}
+	return r
+}
+
+// static reports whether origin is in the static list of r. A wildcard
+// stands for one or more characters other than "/".
+func (r *corsRule) static(origin string) bool {
+	if r.any {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if r.exact[origin] {
+		return true
+	}
+	for _, p := range r.patterns {
+		if len(origin) > len(p[0])+len(p[1]) && strings.HasPrefix(origin, p[0]) && strings.HasSuffix(origin, p[1]) &&
+			!strings.Contains(origin[len(p[0]):len(origin)-len(p[1])], "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Invalidate drops the cached resolver answers for origins, or all of
+// them when none is given, e.g. after a domain was added or removed.
+func (p *CORSPolicy) Invalidate(origins ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(origins) == 0 {
+		clear(p.cache)
+		return
+	}
+	for key := range p.cache {
+		_, origin, _ := strings.Cut(key, "\x00")
+		if slices.Contains(origins, origin) {
+			delete(p.cache, key)
+		}
+	}
+}
+
+// allowed reports whether r allows origin, from the static list or the
+// resolver. Resolver errors deny the origin.
+func (p *CORSPolicy) allowed(ctx context.Context, r *corsRule, origin string) bool {
+	if r.static(origin) {
+		return true
+	}
+	if r.resolver == nil {
+		return false
+	}
+	key := r.prefix + "\x00" + origin
+	if allowed, ok := p.cached(key); ok {
+		return allowed
+	}
+	v, _, _ := p.group.Do(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), p.opts.ResolverTimeout)
+		defer cancel()
+		allowed, err := r.resolver(ctx, origin)
+		ttl := p.opts.ResolverTTL
+		if err != nil {
+			slog.Warn("CORS origin resolver failed, origin denied",
+				slog.String("origin", origin), slog.String("group", r.prefix), log.Err(err))
+			allowed, ttl = false, p.opts.ResolverErrorTTL
+		}
+		p.store(key, corsEntry{allowed: allowed, expires: time.Now().Add(ttl)})
+		return allowed, nil
+	})
+	return v.(bool)
+}
+
+func (p *CORSPolicy) cached(key string) (allowed, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.cache[key]
+	if !ok || time.Now().After(e.expires) {
+		return false, false
+	}
+	return e.allowed, true
+}
+
+// store caches e under key. A full cache drops its expired answers, or
+// all of them, since origins are chosen by clients.
+func (p *CORSPolicy) store(key string, e corsEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.cache) >= p.opts.CacheSize {
+		now := time.Now()
+		for k, old := range p.cache {
+			if now.After(old.expires) {
+				delete(p.cache, k)
+			}
+		}
+		if len(p.cache) >= p.opts.CacheSize {
+			clear(p.cache)
+		}
+	}
+	p.cache[key] = e
+}
+
+// WatchCORS keeps the static settings of p in sync with store until ctx is
+// done.
+//
+//	go middleware.WatchCORS(ctx, cors, store, func(c config.Config) config.CORSConfig {
+//	    return c.CORS
+//	})
+func WatchCORS[T any](ctx context.Context, p *CORSPolicy, store *config.Store[T], get func(T) config.CORSConfig) {
+	events := store.SubscribeEvents()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			p.Update(get(ev.New))
+		}
+	}
+}
+
+// Middleware returns the CORS middleware of p. Preflight requests (OPTIONS
+// with Access-Control-Request-Method) are answered with 204 without
+// reaching the handler or the group middleware, such as JWT and Casbin;
+// the CORS headers are set only for allowed origins. Every response varies
+// on Origin, and preflight responses also on the requested method and
+// headers.
+func (p *CORSPolicy) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			h := c.Response().Header()
+			h.Add(echo.HeaderVary, echo.HeaderOrigin)
+			origin := req.Header.Get(echo.HeaderOrigin)
+			preflight := req.Method == http.MethodOptions && req.Header.Get(echo.HeaderAccessControlRequestMethod) != ""
+			if origin == "" {
+				return next(c)
+			}
+
+			r := p.settings.Load().match(req.URL.Path)
+			allowed := p.allowed(req.Context(), r, origin)
+			if allowed {
+				if r.any && !r.credentials {
+					h.Set(echo.HeaderAccessControlAllowOrigin, "*")
+				} else {
+					h.Set(echo.HeaderAccessControlAllowOrigin, origin)
+				}
+				if r.credentials {
+					h.Set(echo.HeaderAccessControlAllowCredentials, "true")
+				}
+			}
+			if !preflight {
+				return next(c)
+			}
+
+			h.Add(echo.HeaderVary, echo.HeaderAccessControlRequestMethod)
+			h.Add(echo.HeaderVary, echo.HeaderAccessControlRequestHeaders)
+			if allowed {
+				h.Set(echo.HeaderAccessControlAllowMethods, r.methods)
+				headers := r.headers
+				if headers == "" {
+					headers = req.Header.Get(echo.HeaderAccessControlRequestHeaders)
+				}
+				if headers != "" {
+					h.Set(echo.HeaderAccessControlAllowHeaders, headers)
+				}
+			}
+			return c.NoContent(http.StatusNoContent)
+		}
+	}
+}
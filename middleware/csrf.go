@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/resp"
+	"github.com/NSObjects/go-kit/security"
+	"github.com/labstack/echo/v4"
+)
+
+// Context keys set by the CSRF middleware.
+const (
+	csrfTokenKey  = "csrf"
+	csrfConfigKey = "csrf_config"
+)
+
+// CSRFConfig holds CSRF middleware configuration.
+type CSRFConfig struct {
+	// CookieName is the token cookie; default "_csrf".
+	CookieName string
+	// HeaderName is the request header carrying the token; default "X-CSRF-Token".
+	HeaderName string
+	// FormField is the form field carrying the token; default "_csrf".
+	FormField string
+	// CookiePath is the cookie path; default "/".
+	CookiePath string
+	// CookieDomain is the cookie domain (optional).
+	CookieDomain string
+	// CookieSecure sets the Secure attribute.
+	CookieSecure bool
+	// CookieSameSite is the SameSite attribute; default http.SameSiteLaxMode.
+	CookieSameSite http.SameSite
+	// TokenTTL is the token lifetime; default 12h. It bounds the cookie
+	// and, with Key, the token itself.
+	TokenTTL time.Duration
+	// Key signs the expiry of tokens with HMAC-SHA256, so that clients
+	// cannot extend it; it must be shared by every replica. Without it,
+	// tokens carry no expiry and only the cookie expires.
+	Key []byte
+	// SkipPaths are route paths exempt from validation, matched exactly or,
+	// ending in "*", by prefix, unless SkipPatterns is set.
+	SkipPaths []string
+	// SkipPatterns matches SkipPaths as PathMatcher patterns, where a
+	// ":param" segment also matches literal routes such as "/users/me".
+	SkipPatterns bool
+	// SkipMatcher overrides SkipPaths with a precompiled matcher.
+	SkipMatcher *PathMatcher
+}
+
+func (cfg CSRFConfig) withDefaults() CSRFConfig {
+	if cfg.CookieName == "" {
+		cfg.CookieName = "_csrf"
+	}
+	if cfg.HeaderName == "" {
+		cfg.HeaderName = echo.HeaderXCSRFToken
+	}
+	if cfg.FormField == "" {
+		cfg.FormField = "_csrf"
+	}
+	if cfg.CookiePath == "" {
+		cfg.CookiePath = "/"
+	}
+	if cfg.CookieSameSite == 0 {
+		cfg.CookieSameSite = http.SameSiteLaxMode
+	}
+	if cfg.TokenTTL <= 0 {
+		cfg.TokenTTL = 12 * time.Hour
+	}
+	return cfg
+}
+
+// CSRF returns a double-submit cookie CSRF middleware. Safe methods (GET,
+// HEAD, OPTIONS, TRACE) issue a token cookie when missing or expired; other
+// methods must echo the cookie's token in the header or form field.
+// Failures are coded ErrForbidden errors rendered by the error handler.
+func CSRF(cfg CSRFConfig) echo.MiddlewareFunc {
+	cfg = cfg.withDefaults()
+	skip := skipPaths(cfg.SkipMatcher, cfg.SkipPatterns, cfg.SkipPaths)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(csrfConfigKey, cfg)
+			if skip(c) {
+				return next(c)
+			}
+
+			token := ""
+			if cookie, err := c.Cookie(cfg.CookieName); err == nil && csrfTokenValid(cfg, cookie.Value, time.Now()) {
+				token = cookie.Value
+			}
+
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				if token == "" {
+					token = issueCSRFToken(c, cfg)
+				}
+				c.Set(csrfTokenKey, token)
+				return next(c)
+			}
+
+			if token == "" {
+				EmitSecurityEvent(c, security.KindCSRFRejected, code.ErrForbidden, map[string]any{"reason": "missing cookie"})
+				return code.NewError(code.ErrForbidden, "CSRF token invalid")
+			}
+			sent := c.Request().Header.Get(cfg.HeaderName)
+			if sent == "" {
+				sent = c.FormValue(cfg.FormField)
+			}
+			if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				EmitSecurityEvent(c, security.KindCSRFRejected, code.ErrForbidden, map[string]any{"reason": "token mismatch"})
+				return code.NewError(code.ErrForbidden, "CSRF token invalid")
+			}
+
+			c.Set(csrfTokenKey, token)
+			return next(c)
+		}
+	}
+}
+
+// RotateCSRFToken issues a new CSRF token, e.g. after login to prevent
+// session fixation. It must run behind the CSRF middleware.
+func RotateCSRFToken(c echo.Context) string {
+	cfg, ok := c.Get(csrfConfigKey).(CSRFConfig)
+	if !ok {
+		return ""
+	}
+	token := issueCSRFToken(c, cfg)
+	c.Set(csrfTokenKey, token)
+	return token
+}
+
+// CSRFToken returns the current request's CSRF token.
+func CSRFToken(c echo.Context) string {
+	token, _ := c.Get(csrfTokenKey).(string)
+	return token
+}
+
+// CSRFTokenHandler returns an endpoint for SPAs to fetch the current token:
+//
+//	e.GET("/api/csrf", middleware.CSRFTokenHandler())
+func CSRFTokenHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return resp.SuccessJSON(c, map[string]string{"token": CSRFToken(c)})
+	}
+}
+
+// csrfRandomLen is the length of the encoded random part of tokens.
+var csrfRandomLen = base64.RawURLEncoding.EncodedLen(32)
+
+// issueCSRFToken generates a token and sets it as a cookie.
+func issueCSRFToken(c echo.Context, cfg CSRFConfig) string {
+	expires := time.Now().Add(cfg.TokenTTL)
+	token := newCSRFToken(cfg, expires)
+
+	c.SetCookie(&http.Cookie{
+		Name:     cfg.CookieName,
+		Value:    token,
+		Path:     cfg.CookiePath,
+		Domain:   cfg.CookieDomain,
+		Expires:  expires,
+		Secure:   cfg.CookieSecure,
+		SameSite: cfg.CookieSameSite,
+		// Readable by scripts so SPAs can echo it in the header.
+		HttpOnly: false,
+	})
+	return token
+}
+
+// newCSRFToken returns a random token, of the form
+// "<random>.<expiry unix>.<signature>" with cfg.Key.
+func newCSRFToken(cfg CSRFConfig, expires time.Time) string {
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	if len(cfg.Key) == 0 {
+		return token
+	}
+	token += "." + strconv.FormatInt(expires.Unix(), 10)
+	return token + "." + csrfSignature(cfg.Key, token)
+}
+
+// csrfSignature returns the encoded HMAC-SHA256 of payload.
+func csrfSignature(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// csrfTokenValid reports whether token is a well-formed token of cfg and,
+// with cfg.Key, is signed and not expired at now.
+func csrfTokenValid(cfg CSRFConfig, token string, now time.Time) bool {
+	if len(cfg.Key) == 0 {
+		return len(token) == csrfRandomLen
+	}
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return false
+	}
+	payload, sig := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(csrfSignature(cfg.Key, payload))) {
+		return false
+	}
+	_, exp, ok := strings.Cut(payload, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return false
+	}
+	return now.Before(time.Unix(unix, 0))
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/labstack/echo/v4"
+)
+
+var csrfTestKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestCSRFTokenExpiry(t *testing.T) {
+	cfg := CSRFConfig{Key: csrfTestKey}
+	now := time.Now()
+	token := newCSRFToken(cfg, now.Add(time.Hour))
+
+	if !csrfTokenValid(cfg, token, now) {
+		t.Fatal("fresh token: invalid")
+	}
+	if csrfTokenValid(cfg, token, now.Add(2*time.Hour)) {
+		t.Fatal("expired token: valid")
+	}
+	if csrfTokenValid(CSRFConfig{Key: []byte("another key")}, token, now) {
+		t.Fatal("token of another key: valid")
+	}
+
+	// A client pushing the expiry of its cookie back.
+	parts := strings.Split(token, ".")
+	parts[1] = strconv.FormatInt(now.Add(100*24*time.Hour).Unix(), 10)
+	if csrfTokenValid(cfg, strings.Join(parts, "."), now.Add(2*time.Hour)) {
+		t.Fatal("token with an edited expiry: valid")
+	}
+}
+
+func TestCSRFTokenWithoutKey(t *testing.T) {
+	cfg := CSRFConfig{}
+	token := newCSRFToken(cfg, time.Now().Add(time.Hour))
+	if strings.Contains(token, ".") {
+		t.Fatalf("token %q carries an unsigned claim", token)
+	}
+	if !csrfTokenValid(cfg, token, time.Now()) {
+		t.Fatal("token: invalid")
+	}
+	if csrfTokenValid(cfg, token+"."+strconv.FormatInt(time.Now().Unix(), 10), time.Now()) {
+		t.Fatal("token with a claim: valid")
+	}
+}
+
+func TestCSRFMiddleware(t *testing.T) {
+	e := echo.New()
+	mw := CSRF(CSRFConfig{Key: csrfTestKey, SkipPaths: []string{"/hooks/*"}})
+	h := mw(func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+	serve := func(method, route, cookie, header string) error {
+		req := httptest.NewRequest(method, route, nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "_csrf", Value: cookie})
+		}
+		if header != "" {
+			req.Header.Set(echo.HeaderXCSRFToken, header)
+		}
+		c := e.NewContext(req, httptest.NewRecorder())
+		c.SetPath(route)
+		return h(c)
+	}
+	token := newCSRFToken(CSRFConfig{Key: csrfTestKey}, time.Now().Add(time.Hour))
+	expired := newCSRFToken(CSRFConfig{Key: csrfTestKey}, time.Now().Add(-time.Minute))
+
+	tests := []struct {
+		name           string
+		method, route  string
+		cookie, header string
+		forbidden      bool
+	}{
+		{"safe method", http.MethodGet, "/form", "", "", false},
+		{"missing cookie", http.MethodPost, "/form", "", token, true},
+		{"missing header", http.MethodPost, "/form", token, "", true},
+		{"mismatch", http.MethodPost, "/form", token, expired, true},
+		{"expired", http.MethodPost, "/form", expired, expired, true},
+		{"valid", http.MethodPost, "/form", token, token, false},
+		{"skipped", http.MethodPost, "/hooks/github", "", "", false},
+	}
+	for _, tt := range tests {
+		err := serve(tt.method, tt.route, tt.cookie, tt.header)
+		if forbidden := errors.GetCode(err) == code.ErrForbidden; forbidden != tt.forbidden || (err != nil && !forbidden) {
+			t.Errorf("%s: err = %v, want forbidden %v", tt.name, err, tt.forbidden)
+		}
+	}
+}
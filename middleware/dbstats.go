@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/metrics"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultDBStatsTopStatements is the number of statements listed by the
+// repeated-statements warning of DBStats.
+const DefaultDBStatsTopStatements = 5
+
+// DBStatsConfig configures DBStats.
+type DBStatsConfig struct {
+	// RepeatThreshold logs a "Repeated database statements" warning for a
+	// request running one statement at least this many times, the mark of
+	// an N+1 query pattern; 0 disables it.
+	RepeatThreshold int
+	// TopStatements is the number of statements listed by the warning;
+	// default DefaultDBStatsTopStatements.
+	TopStatements int
+	// Metrics, when set, observes the statement count and database time
+	// of each request by route.
+	Metrics *metrics.DBStatsMetrics
+	// Skipper skips matching requests.
+	Skipper func(c echo.Context) bool
+}
+
+// DBStats returns a middleware that counts the database statements of
+// each request, their total time and rows, in a utils.DBStats put in the
+// request context (see utils.DBStatsFromContext). Statements are recorded
+// by the db.RequestStatsPlugin gorm plugin, registered by db.NewDatabase,
+// when run with the request context (see RequestDeps.DB).
+//
+// Installed before AccessLog, it adds db_query_count, db_time_ms and
+// db_rows to the request log line. Setup installs it when
+// database.request_stats.enabled is set.
+//
+//	e.Use(middleware.DBStats(middleware.DBStatsConfig{RepeatThreshold: 20}))
+func DBStats(cfg DBStatsConfig) echo.MiddlewareFunc {
+	if cfg.TopStatements <= 0 {
+		cfg.TopStatements = DefaultDBStatsTopStatements
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cfg.Skipper != nil && cfg.Skipper(c) {
+				return next(c)
+			}
+			stats := utils.NewDBStats()
+			c.SetRequest(c.Request().WithContext(utils.WithDBStats(c.Request().Context(), stats)))
+
+			err := next(c)
+
+			snap := stats.Snapshot()
+			if cfg.Metrics != nil {
+				path := c.Path()
+				if path == "" {
+					path = "unmatched"
+				}
+				method := c.Request().Method
+				cfg.Metrics.Queries.WithLabelValues(method, path).Observe(float64(snap.Queries))
+				cfg.Metrics.Duration.WithLabelValues(method, path).Observe(snap.Duration.Seconds())
+			}
+			if cfg.RepeatThreshold > 0 && snap.Queries >= cfg.RepeatThreshold {
+				top := stats.TopStatements(cfg.TopStatements)
+				if len(top) > 0 && top[0].Count >= cfg.RepeatThreshold {
+					log.WarnCtx(c.Request().Context(), "Repeated database statements",
+						slog.String("method", c.Request().Method),
+						slog.String("route", c.Path()),
+						slog.Int("threshold", cfg.RepeatThreshold),
+						slog.Int("db_query_count", snap.Queries),
+						slog.Float64("db_time_ms", durationMillis(snap.Duration)),
+						slog.Any("statements", top),
+					)
+				}
+			}
+			return err
+		}
+	}
+}
+
+// dbStatsAttrs returns the database statistics of the request for its log
+// line, or nil when DBStats is not installed.
+func dbStatsAttrs(c echo.Context) []slog.Attr {
+	stats := utils.DBStatsFromContext(c.Request().Context())
+	if stats == nil {
+		return nil
+	}
+	snap := stats.Snapshot()
+	return []slog.Attr{
+		slog.Int("db_query_count", snap.Queries),
+		slog.Float64("db_time_ms", durationMillis(snap.Duration)),
+		slog.Int64("db_rows", snap.Rows),
+	}
+}
+
+// durationMillis returns d in milliseconds, to the microsecond.
+func durationMillis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
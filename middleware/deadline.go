@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderRequestTimeout carries the time a client is willing to wait for a
+// response, in milliseconds or as a Go duration ("1500", "1.5s").
+const HeaderRequestTimeout = "X-Request-Timeout"
+
+// ClientDeadlineConfig holds the bounds of ClientDeadline.
+type ClientDeadlineConfig struct {
+	// Header is the request header of the timeout; default
+	// HeaderRequestTimeout.
+	Header string
+	// Min is the shortest timeout applied; shorter hints are raised to it.
+	Min time.Duration
+	// Max is the longest timeout applied; longer hints are lowered to it.
+	// Zero does not bound hints.
+	Max time.Duration
+	// Default is the timeout of requests without a valid hint. Zero leaves
+	// them without deadline.
+	Default time.Duration
+	// Skipper skips matching requests.
+	Skipper func(c echo.Context) bool
+}
+
+// ClientDeadline returns a middleware that bounds each request by the
+// timeout its client sends in cfg.Header, clamped to [cfg.Min, cfg.Max],
+// or cfg.Default without one. An earlier deadline of the request context
+// is kept. The effective timeout is sent back in cfg.Header, in
+// milliseconds, and set as the http.request.timeout_ms span attribute.
+//
+// Database and Redis calls made with the request context inherit the
+// deadline (see db.Manager.DBWithContext), so the server stops working on a
+// response nobody will read; handlers returning the context error answer
+// 504 (see errors.FromContextError).
+//
+//	e.Use(middleware.ClientDeadline(middleware.ClientDeadlineConfig{
+//	    Min:     100 * time.Millisecond,
+//	    Max:     30 * time.Second,
+//	    Default: 10 * time.Second,
+//	}))
+func ClientDeadline(cfg ClientDeadlineConfig) echo.MiddlewareFunc {
+	if cfg.Header == "" {
+		cfg.Header = HeaderRequestTimeout
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cfg.Skipper != nil && cfg.Skipper(c) {
+				return next(c)
+			}
+			timeout := cfg.Default
+			if v := c.Request().Header.Get(cfg.Header); v != "" {
+				if d, ok := parseRequestTimeout(v); ok {
+					timeout = clampTimeout(d, cfg.Min, cfg.Max)
+				} else {
+					slog.DebugContext(c.Request().Context(), "Invalid request timeout hint",
+						slog.String("header", cfg.Header), slog.String("value", v))
+				}
+			}
+			if timeout <= 0 {
+				return next(c)
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+			defer cancel()
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			deadline, _ := ctx.Deadline()
+			ms := time.Until(deadline).Milliseconds()
+			c.Response().Header().Set(cfg.Header, strconv.FormatInt(ms, 10))
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("http.request.timeout_ms", ms))
+			return next(c)
+		}
+	}
+}
+
+// parseRequestTimeout parses milliseconds or a Go duration. Zero and
+// negative timeouts are invalid.
+func parseRequestTimeout(v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Duration(ms) * time.Millisecond, ms > 0
+	}
+	d, err := time.ParseDuration(v)
+	return d, err == nil && d > 0
+}
+
+func clampTimeout(d, lo, hi time.Duration) time.Duration {
+	if d < lo {
+		d = lo
+	}
+	if hi > 0 && d > hi {
+		d = hi
+	}
+	return d
+}
@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/NSObjects/go-kit/cache"
+	"github.com/NSObjects/go-kit/db"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// depsKey is the echo.Context key of the request's RequestDeps.
+const depsKey = "request_deps"
+
+// RequestScopeConfig holds the shared dependencies behind RequestDeps.
+// Nil fields make the corresponding accessors return nil (DB, Cache) or
+// the global logger (Logger).
+type RequestScopeConfig struct {
+	Manager *db.Manager
+	Cache   cache.Cache
+	Logger  log.Logger
+}
+
+// RequestScope returns a middleware that attaches a RequestDeps container to
+// every request (see Deps), and the request logger, with request_id, and a
+// utils.RequestCache (see utils.Memo) to the request context (see
+// log.FromContext). Setup installs it.
+func RequestScope(cfg RequestScopeConfig) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := utils.WithRequestCache(req.Context(), utils.NewRequestCache())
+			logger := cfg.Logger
+			if logger == nil {
+				logger = log.GetGlobalLogger()
+			}
+			if logger != nil {
+				logger = logger.With(slog.String("request_id", utils.GetRequestIDFromEcho(c)))
+				ctx = log.NewContext(ctx, logger)
+			}
+			c.SetRequest(req.WithContext(ctx))
+			c.Set(depsKey, &RequestDeps{c: c, cfg: cfg})
+			return next(c)
+		}
+	}
+}
+
+// RequestDeps holds request-scoped dependencies, each built on first use
+// and at most once per request. Values are derived from the request at the
+// time of first use, so call the accessors in handlers, after the
+// authentication middleware ran.
+//
+// A RequestDeps is safe for concurrent use, but its values are bound to
+// the request context: goroutines that outlive the handler must use
+// Detach. RequestDeps must not be copied (go vet reports copies).
+type RequestDeps struct {
+	c   echo.Context
+	cfg RequestScopeConfig
+	// ctx replaces the request context when set (see Detach).
+	ctx context.Context
+
+	ctxOnce sync.Once
+	ctxVal  context.Context
+
+	dbOnce sync.Once
+	db     *gorm.DB
+
+	cacheOnce sync.Once
+	cache     cache.Cache
+
+	loggerOnce sync.Once
+	logger     log.Logger
+}
+
+// Deps returns the request's dependency container. Without the
+// RequestScope middleware it returns a container without a database or
+// cache.
+func Deps(c echo.Context) *RequestDeps {
+	if d, ok := c.Get(depsKey).(*RequestDeps); ok {
+		return d
+	}
+	d := &RequestDeps{c: c}
+	c.Set(depsKey, d)
+	return d
+}
+
+// Context returns the request context with request values attached (see
+// utils.BuildContext). It carries the request's deadline and cancellation.
+func (d *RequestDeps) Context() context.Context {
+	d.ctxOnce.Do(func() {
+		if d.ctx != nil {
+			d.ctxVal = d.ctx
+			return
+		}
+		d.ctxVal = utils.BuildContext(d.c)
+	})
+	return d.ctxVal
+}
+
+// UserID returns the authenticated user's ID, or "".
+func (d *RequestDeps) UserID() string {
+	return utils.GetUserID(d.Context())
+}
+
+// TenantID returns the request's tenant ID, or "".
+func (d *RequestDeps) TenantID() string {
+	return utils.GetTenantID(d.Context())
+}
+
+// DB returns the database bound to the request context and restricted to
+// the request's tenant (see db.TenantScope): without a tenant it matches
+// no rows. Use UnscopedDB for tables without a tenant_id column. The
+// returned session is safe to reuse for several queries.
+func (d *RequestDeps) DB() *gorm.DB {
+	d.dbOnce.Do(func() {
+		if base := d.UnscopedDB(); base != nil {
+			d.db = db.TenantScope(d.Context())(base).Session(&gorm.Session{})
+		}
+	})
+	return d.db
+}
+
+// UnscopedDB returns the database bound to the request context without
+// tenant restriction, or nil when no database is configured.
+func (d *RequestDeps) UnscopedDB() *gorm.DB {
+	if d.cfg.Manager == nil {
+		return nil
+	}
+	return d.cfg.Manager.DBWithContext(d.Context())
+}
+
+// Cache returns the cache with keys prefixed by "tenant:<id>" when the
+// request has a tenant, or nil when no cache is configured.
+func (d *RequestDeps) Cache() cache.Cache {
+	d.cacheOnce.Do(func() {
+		if d.cfg.Cache == nil {
+			return
+		}
+		prefix := ""
+		if tenantID := d.TenantID(); tenantID != "" {
+			prefix = "tenant:" + tenantID
+		}
+		d.cache = cache.WithPrefix(d.cfg.Cache, prefix)
+	})
+	return d.cache
+}
+
+// Logger returns the request logger (see log.FromContext) with user_id
+// attached when authenticated.
+func (d *RequestDeps) Logger() log.Logger {
+	d.loggerOnce.Do(func() {
+		ctx := d.Context()
+		d.logger = log.FromContext(ctx)
+		if userID := utils.GetUserID(ctx); userID != "" {
+			d.logger = d.logger.With(slog.String("user_id", userID))
+		}
+	})
+	return d.logger
+}
+
+// RequestCache returns the request's memoization cache (see utils.Memo),
+// or nil without the RequestScope middleware, in which case Memo calls
+// its loader every time.
+//
+//	tenant, err := utils.Memo(deps.Context(), "tenant", loadTenant)
+func (d *RequestDeps) RequestCache() *utils.RequestCache {
+	return utils.GetRequestCache(d.Context())
+}
+
+// Detach returns a new container for goroutines that outlive the request:
+// it keeps the request values, including the RequestCache, but is never
+// canceled, and builds its own DB, Cache and Logger.
+//
+//	deps := middleware.Deps(c).Detach()
+//	go func() { _ = deps.DB().Create(&event).Error }()
+func (d *RequestDeps) Detach() *RequestDeps {
+	return &RequestDeps{c: d.c, cfg: d.cfg, ctx: utils.DetachContext(d.Context())}
+}
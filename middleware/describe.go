@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+
+	kit "github.com/NSObjects/go-kit"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/labstack/echo/v4"
+)
+
+// ErrorCode is an entry of the ErrorCodesHandler response.
+type ErrorCode struct {
+	Code       int    `json:"code"`
+	HTTPStatus int    `json:"http_status"`
+	Message    string `json:"message"`
+	Reference  string `json:"reference,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+}
+
+// ErrorCodeGroup is an entry of the ErrorCodesHandler response grouped by
+// namespace. Codes outside every namespace are grouped under an empty
+// name, without range.
+type ErrorCodeGroup struct {
+	Namespace string      `json:"namespace"`
+	Base      int         `json:"base,omitempty"`
+	End       int         `json:"end,omitempty"`
+	Codes     []ErrorCode `json:"codes"`
+}
+
+// DescribeOptions configure DescribeHandler.
+type DescribeOptions struct {
+	// Maintenance adds its current status to the description, under
+	// "maintenance".
+	Maintenance *Maintenance
+}
+
+// DescribeHandler serves doc (see kit.Describe) as plain JSON. Like
+// ConfigHandler, mount it on an internal router at kit.DescribePath.
+func DescribeHandler(doc kit.Description, opts ...DescribeOptions) echo.HandlerFunc {
+	var o DescribeOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	return func(c echo.Context) error {
+		if o.Maintenance == nil {
+			return c.JSON(http.StatusOK, doc)
+		}
+		return c.JSON(http.StatusOK, struct {
+			kit.Description
+			Maintenance MaintenanceStatus `json:"maintenance"`
+		}{doc, o.Maintenance.Status()})
+	}
+}
+
+// ErrorCodesHandler serves the registered error codes as plain JSON,
+// sorted by code, or grouped by namespace (see errors.Namespace) with
+// ?group=namespace. Mount it at kit.ErrorCodesPath.
+func ErrorCodesHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		coders := errors.Registered()
+		codes := make([]ErrorCode, len(coders))
+		for i, coder := range coders {
+			codes[i] = ErrorCode{
+				Code:       coder.Code(),
+				HTTPStatus: coder.HTTPStatus(),
+				Message:    coder.Message(),
+				Reference:  coder.Reference(),
+				Namespace:  errors.NamespaceOf(coder.Code()),
+			}
+		}
+		if c.QueryParam("group") == "namespace" {
+			return c.JSON(http.StatusOK, groupByNamespace(codes))
+		}
+		return c.JSON(http.StatusOK, codes)
+	}
+}
+
+// groupByNamespace groups codes by namespace, in the order of the
+// namespaces, codes outside them last. Namespaces without codes are
+// listed too.
+func groupByNamespace(codes []ErrorCode) []ErrorCodeGroup {
+	var groups []ErrorCodeGroup
+	index := map[string]int{}
+	for _, ns := range errors.Namespaces() {
+		index[ns.Name] = len(groups)
+		groups = append(groups, ErrorCodeGroup{Namespace: ns.Name, Base: ns.Base, End: ns.End, Codes: []ErrorCode{}})
+	}
+	for _, code := range codes {
+		i, ok := index[code.Namespace]
+		if !ok {
+			i = len(groups)
+			index[code.Namespace] = i
+			groups = append(groups, ErrorCodeGroup{Namespace: code.Namespace})
+		}
+		groups[i].Codes = append(groups[i].Codes, code)
+	}
+	return groups
+}
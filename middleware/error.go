@@ -2,27 +2,138 @@
 package middleware
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/NSObjects/go-kit/code"
 	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/log"
 	"github.com/NSObjects/go-kit/resp"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/NSObjects/go-kit/validator"
 	"github.com/labstack/echo/v4"
 )
 
+// StatusClientClosedRequest is the non-standard status (nginx 499) recorded
+// for requests whose client went away before a response was written.
+const StatusClientClosedRequest = 499
+
+// ErrorHandlerConfig configures NewErrorHandler.
+type ErrorHandlerConfig struct {
+	// Debug adds the error text and stack to error responses (see
+	// resp.Debug). Only for development: it leaks implementation details.
+	Debug bool
+}
+
+// NewErrorHandler returns ErrorHandler configured by cfg.
+func NewErrorHandler(cfg ErrorHandlerConfig) echo.HTTPErrorHandler {
+	if !cfg.Debug {
+		return ErrorHandler
+	}
+	return func(err error, c echo.Context) {
+		resp.SetDebug(c, true)
+		ErrorHandler(err, c)
+	}
+}
+
+// RegisterErrorHandling sets ErrorHandler, configured by cfg, as the
+// HTTPErrorHandler of e. ErrorHandler makes unmatched routes and methods
+// fail with the coded ErrNotFound and ErrMethodNotAllowed, the latter
+// keeping the Allow header. Setup and the admin server call it. Only e
+// changes: echo's package-level handlers, shared by every instance, are
+// left alone.
+func RegisterErrorHandling(e *echo.Echo, cfg ...ErrorHandlerConfig) {
+	var c ErrorHandlerConfig
+	if len(cfg) > 0 {
+		c = cfg[0]
+	}
+	e.HTTPErrorHandler = NewErrorHandler(c)
+}
+
+// NotFoundHandler is the handler of unmatched routes, as ErrorHandler
+// answers them; it also serves as an echo RouteNotFound handler. It
+// fails with echo.ErrNotFound carrying the coded ErrNotFound.
+func NotFoundHandler(c echo.Context) error {
+	return echo.NewHTTPError(http.StatusNotFound).SetInternal(
+		code.NewErrorf(code.ErrNotFound, "route %s not found", c.Request().URL.Path))
+}
+
+// MethodNotAllowedHandler is the handler of routes matched for another
+// method, as ErrorHandler answers them. It sets the Allow header found by
+// the router and fails with echo.ErrMethodNotAllowed carrying the coded
+// ErrMethodNotAllowed.
+func MethodNotAllowedHandler(c echo.Context) error {
+	allow, _ := c.Get(echo.ContextKeyHeaderAllow).(string)
+	if allow != "" {
+		c.Response().Header().Set(echo.HeaderAllow, allow)
+	}
+	return echo.NewHTTPError(http.StatusMethodNotAllowed).SetInternal(
+		code.NewErrorf(code.ErrMethodNotAllowed, "method %s not allowed on %s", c.Request().Method, c.Request().URL.Path))
+}
+
 // ErrorHandler is the centralized error handler for Echo.
+//
+// When the response is already committed (e.g. a streaming handler failed
+// midway) the error is logged instead of written; event streams get a
+// final "error" event. Errors caused by the client cancelling the request
+// are logged at debug level and no response is attempted; a cancellation
+// started inside the server, with the request context still live, is a
+// 5xx like any other error. The router's not found and method not allowed
+// errors become the coded ErrNotFound and ErrMethodNotAllowed (see
+// NotFoundHandler and MethodNotAllowedHandler). Responses to HEAD
+// requests keep their status and headers but have no body. A context
+// without a request or response, as for errors raised while the
+// connection is set up, is only logged.
 func ErrorHandler(err error, c echo.Context) {
 	start := time.Now()
 
+	if c == nil || c.Request() == nil || c.Response() == nil || c.Response().Writer == nil {
+		slog.Error("Error without request", log.Err(err))
+		return
+	}
+	if c.Response().Committed {
+		handleCommittedError(err, c)
+		return
+	}
+	if clientClosed(err, c) {
+		slog.Debug("Client closed request", append(requestAttrs(c), log.Err(err))...)
+		c.Response().Status = StatusClientClosedRequest
+		return
+	}
+	if errors.GetCode(err) == code.ErrClientClosedRequest || errors.Is(err, context.Canceled) {
+		// Canceled inside the server while the client still waits: an
+		// internal error, logged as one by resp.APIError.
+		err = code.WrapInternalServerError(err, "request canceled")
+	}
+	switch {
+	case err == echo.ErrNotFound && c.Path() == "":
+		// Raised by the router for an unmatched route, rather than by a
+		// handler.
+		err = NotFoundHandler(c)
+	case err == echo.ErrMethodNotAllowed:
+		err = MethodNotAllowedHandler(c)
+	}
+	if c.Request().Method == http.MethodHead {
+		res, w := c.Response(), c.Response().Writer
+		res.Writer = headWriter{w}
+		defer func() { res.Writer = w }()
+	}
+
 	// Handle different error types
-	switch e := err.(type) {
-	case *echo.HTTPError:
-		handleHTTPError(e, c)
-	case *ValidationError:
-		handleValidationError(e, c)
+	var httpErr *echo.HTTPError
+	var validationErr *ValidationError
+	switch {
+	case errors.GetCode(err) != 0:
+		handleGenericError(err, c)
+	case errors.As(err, &httpErr):
+		handleHTTPError(httpErr, c)
+	case errors.As(err, &validationErr):
+		handleValidationError(validationErr, c)
 	default:
 		handleGenericError(err, c)
 	}
@@ -35,6 +146,12 @@ func ErrorHandler(err error, c echo.Context) {
 	)
 }
 
+// clientClosed reports whether err is the cancellation of the request by
+// its client: a context.Canceled while the request context is done too.
+func clientClosed(err error, c echo.Context) bool {
+	return errors.Is(err, context.Canceled) && c.Request().Context().Err() != nil
+}
+
 // ValidationError represents a validation error.
 type ValidationError struct {
 	Field   string `json:"field"`
@@ -46,27 +163,67 @@ func (e *ValidationError) Error() string {
 	return e.Message
 }
 
-// handleHTTPError converts Echo HTTP errors to business errors.
+// httpErrorCodes maps the statuses of Echo HTTP errors to codes.
+var httpErrorCodes = map[int]int{
+	http.StatusBadRequest:            code.ErrBadRequest,
+	http.StatusUnauthorized:          code.ErrUnauthorized,
+	http.StatusForbidden:             code.ErrForbidden,
+	http.StatusNotFound:              code.ErrNotFound,
+	http.StatusMethodNotAllowed:      code.ErrMethodNotAllowed,
+	http.StatusConflict:              code.ErrAlreadyExists,
+	http.StatusRequestEntityTooLarge: code.ErrPayloadTooLarge,
+	http.StatusUnsupportedMediaType:  code.ErrUnsupportedMediaType,
+	http.StatusTooManyRequests:       code.ErrTooManyRequests,
+	http.StatusServiceUnavailable:    code.ErrServiceUnavailable,
+	http.StatusGatewayTimeout:        code.ErrTimeout,
+}
+
+// handleHTTPError converts Echo HTTP errors to business errors. An error
+// with a coded Internal error, possibly nested in further HTTP errors,
+// gets the code of the internal error. Other statuses without a code map
+// to ErrBadRequest below 500 and to ErrInternalServer from 500.
 func handleHTTPError(err *echo.HTTPError, c echo.Context) {
-	message := extractErrorMessage(err.Message)
+	for inner := err; inner.Internal != nil; {
+		if errors.GetCode(inner.Internal) != 0 {
+			_ = resp.APIError(c, inner.Internal)
+			return
+		}
+		next, ok := inner.Internal.(*echo.HTTPError)
+		if !ok {
+			break
+		}
+		inner = next
+	}
 
-	var bizErr error
-	switch err.Code {
-	case http.StatusBadRequest:
-		bizErr = code.NewErrorf(code.ErrBadRequest, "%s", message)
-	case http.StatusUnauthorized:
-		bizErr = code.NewErrorf(code.ErrUnauthorized, "%s", message)
-	case http.StatusForbidden:
-		bizErr = code.NewErrorf(code.ErrForbidden, "%s", message)
-	case http.StatusNotFound:
-		bizErr = code.NewErrorf(code.ErrNotFound, "%s", message)
+	message := extractErrorMessage(err.Message)
+	errorCode, ok := httpErrorCodes[err.Code]
+	switch {
+	case ok:
+	case err.Code >= http.StatusBadRequest && err.Code < http.StatusInternalServerError:
+		errorCode = code.ErrBadRequest
 	default:
-		bizErr = code.NewErrorf(code.ErrInternalServer, "%s", message)
+		errorCode = code.ErrInternalServer
+	}
+	bizErr := code.NewErrorf(errorCode, "%s", message)
+	if err.Internal != nil {
+		bizErr = code.WrapError(err.Internal, errorCode, message)
 	}
-
 	_ = resp.APIError(c, bizErr)
 }
 
+// headWriter discards the body of responses to HEAD requests.
+type headWriter struct {
+	http.ResponseWriter
+}
+
+func (w headWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w headWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // handleValidationError handles validation errors.
 func handleValidationError(err *ValidationError, c echo.Context) {
 	slog.Warn("Validation Error",
@@ -87,17 +244,74 @@ func handleGenericError(err error, c echo.Context) {
 		return
 	}
 
+	// Validation errors of the echo validator list the invalid fields.
+	if fields, ok := validator.TranslateError(c.Request().Context(), err).(code.ValidationErrors); ok {
+		_ = resp.APIError(c, fields)
+		return
+	}
+
+	// Deadlines and cancellations are timeouts or client disconnects, not
+	// internal errors.
+	if ctxErr := errors.FromContextError(err); ctxErr != err {
+		_ = resp.APIError(c, ctxErr)
+		return
+	}
+
 	// Log unknown errors
-	slog.Error("Generic Error",
-		slog.String("error", err.Error()),
-		slog.String("method", c.Request().Method),
-		slog.String("uri", c.Request().RequestURI),
-	)
+	slog.Error("Generic Error", append(requestAttrs(c), log.Err(err))...)
 
 	wrapped := code.WrapInternalServerError(err, "internal server error")
 	_ = resp.APIError(c, wrapped)
 }
 
+// handleCommittedError logs an error returned after the response was
+// committed. A second write would corrupt the response, so only event
+// streams get a trailing error event.
+func handleCommittedError(err error, c echo.Context) {
+	res := c.Response()
+	contentType := res.Header().Get(echo.HeaderContentType)
+	attrs := append(requestAttrs(c),
+		slog.Int("status", res.Status),
+		slog.Int64("bytes_written", res.Size),
+		slog.String("content_type", contentType),
+		log.Err(err),
+	)
+	if clientClosed(err, c) {
+		slog.Debug("Client closed streaming request", attrs...)
+		return
+	}
+	slog.Error("Error after response committed", attrs...)
+
+	if !strings.HasPrefix(contentType, "text/event-stream") {
+		return
+	}
+	errorCode := errors.GetCode(err)
+	if errorCode == 0 || errors.IsPanic(err) {
+		errorCode = code.ErrInternalServer
+	}
+	message := http.StatusText(errors.HTTPStatus(errorCode))
+	if msg := errors.MessageFor(c.Request().Context(), errorCode); msg != "" {
+		message = msg
+	}
+	data, _ := json.Marshal(resp.Render(c, errors.HTTPStatus(errorCode), resp.Response{Code: errorCode, Msg: message}))
+	if _, werr := fmt.Fprintf(res, "event: error\ndata: %s\n\n", data); werr == nil {
+		_ = http.NewResponseController(res.Writer).Flush()
+	}
+}
+
+// requestAttrs are the log attributes identifying the request.
+func requestAttrs(c echo.Context) []any {
+	attrs := []any{
+		slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+		slog.String("method", c.Request().Method),
+		slog.String("uri", c.Request().RequestURI),
+	}
+	if traceID := utils.GetTraceID(c.Request().Context()); traceID != "" {
+		attrs = append(attrs, slog.String("trace_id", traceID))
+	}
+	return attrs
+}
+
 // extractErrorMessage converts various types to string.
 func extractErrorMessage(message any) string {
 	switch v := message.(type) {
@@ -112,17 +326,41 @@ func extractErrorMessage(message any) string {
 
 // Recovery returns a panic recovery middleware.
 func Recovery() echo.MiddlewareFunc {
+	return RecoveryWithConfig(RecoveryConfig{})
+}
+
+// RecoveryConfig configures RecoveryWithConfig.
+type RecoveryConfig struct {
+	// Verbose includes the panic message and stack in the response body.
+	// Only for development: it leaks implementation details.
+	Verbose bool
+}
+
+// RecoveryWithConfig returns a panic recovery middleware configured by cfg.
+func RecoveryWithConfig(cfg RecoveryConfig) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			defer func() {
 				if r := recover(); r != nil {
+					err := errors.FromPanic(r)
+					if c.Response().Committed {
+						handleCommittedError(err, c)
+						return
+					}
 					slog.Error("Panic recovered",
-						slog.Any("panic", r),
 						slog.String("method", c.Request().Method),
 						slog.String("uri", c.Request().RequestURI),
+						log.Err(err),
 					)
 
-					err := code.NewError(code.ErrInternalServer, "internal server error")
+					if cfg.Verbose {
+						_ = resp.JSON(c, http.StatusInternalServerError, resp.Response{
+							Code: code.ErrInternalServer,
+							Msg:  err.Error(),
+							Data: map[string]any{"stack": strings.Split(fmt.Sprintf("%+v", err), "\n")[1:]},
+						})
+						return
+					}
 					_ = resp.APIError(c, err)
 				}
 			}()
@@ -140,12 +378,16 @@ func RequestLogger() echo.MiddlewareFunc {
 
 			err := next(c)
 
-			slog.Info("Request",
+			attrs := []any{
 				slog.String("method", c.Request().Method),
 				slog.String("uri", c.Request().RequestURI),
 				slog.Int("status", c.Response().Status),
 				slog.Duration("latency", time.Since(start)),
-			)
+			}
+			for _, a := range dbStatsAttrs(c) {
+				attrs = append(attrs, a)
+			}
+			slog.Info("Request", attrs...)
 
 			return err
 		}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+
+	"github.com/labstack/echo/v4"
+)
+
+// InsertionPoint is a place of the Setup chain where SetupOptions insert
+// middleware.
+type InsertionPoint string
+
+// Insertion points, outermost first:
+//
+//	BeforeAll → Recovery → Tracing → RequestID → AfterRequestID → BodyCache → ... → Metrics
+//	  → group: JWT → Casbin → AfterAuth → BeforeHandler → handler
+const (
+	// BeforeAll runs first for every route, before Recovery: panics in
+	// these middleware are not recovered.
+	BeforeAll InsertionPoint = "before_all"
+	// AfterRequestID runs for every route right after RequestID, so the
+	// request ID is set.
+	AfterRequestID InsertionPoint = "after_request_id"
+	// AfterAuth runs on the Authenticated and Admin groups after JWT, and
+	// after Casbin on Admin.
+	AfterAuth InsertionPoint = "after_auth"
+	// BeforeHandler runs on every group (Public, Authenticated and Admin)
+	// last, right before the handler.
+	BeforeHandler InsertionPoint = "before_handler"
+)
+
+var insertionPoints = []InsertionPoint{BeforeAll, AfterRequestID, AfterAuth, BeforeHandler}
+
+// UserMiddleware is a middleware inserted into the Setup chain.
+type UserMiddleware struct {
+	// Name identifies the middleware in RouteGroups.Installed and in the
+	// route audit; default the name of its function.
+	Name       string
+	Middleware echo.MiddlewareFunc
+	// Requires are the names of the middleware that must run before this
+	// one, e.g. "jwt" for middleware reading the authenticated user.
+	// kit.VerifyRoutes reports the routes where they do not.
+	Requires []string
+}
+
+// SetupOptions customize the chain installed by Setup: middleware
+// inserted at InsertionPoints and built-in middleware replaced in place,
+// so the order of the rest of the chain is kept.
+//
+//	var opts middleware.SetupOptions
+//	if err := opts.UseNamed(middleware.AfterAuth, middleware.UserMiddleware{
+//	    Name: "tenant", Middleware: tenantMW, Requires: []string{"jwt"},
+//	}); err != nil {
+//	    return err
+//	}
+//	opts.AccessLog = myAccessLog
+//	groups := middleware.Setup(e, middleware.SetupDeps{Config: cfg, Options: &opts})
+type SetupOptions struct {
+	// Recovery replaces the recovery middleware.
+	Recovery echo.MiddlewareFunc
+	// RequestID replaces the request ID middleware.
+	RequestID echo.MiddlewareFunc
+	// AccessLog replaces the access log middleware.
+	AccessLog echo.MiddlewareFunc
+
+	inserted map[InsertionPoint][]UserMiddleware
+}
+
+// Use inserts mw at point, in order, after the middleware inserted there
+// before. It fails for unknown points.
+func (o *SetupOptions) Use(point InsertionPoint, mw ...echo.MiddlewareFunc) error {
+	for _, m := range mw {
+		if err := o.UseNamed(point, UserMiddleware{Middleware: m}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseNamed inserts m at point like Use, with a name and dependencies.
+func (o *SetupOptions) UseNamed(point InsertionPoint, m UserMiddleware) error {
+	if !isInsertionPoint(point) {
+		return fmt.Errorf("middleware: unknown insertion point %q (expected one of %v)", point, insertionPoints)
+	}
+	if m.Middleware == nil {
+		return fmt.Errorf("middleware: nil middleware inserted at %s", point)
+	}
+	if m.Name == "" {
+		m.Name = middlewareName(m.Middleware)
+	}
+	if o.inserted == nil {
+		o.inserted = make(map[InsertionPoint][]UserMiddleware)
+	}
+	o.inserted[point] = append(o.inserted[point], m)
+	return nil
+}
+
+// At returns the middleware inserted at point.
+func (o *SetupOptions) At(point InsertionPoint) []UserMiddleware {
+	if o == nil {
+		return nil
+	}
+	return o.inserted[point]
+}
+
+func isInsertionPoint(point InsertionPoint) bool {
+	for _, p := range insertionPoints {
+		if p == point {
+			return true
+		}
+	}
+	return false
+}
+
+// middlewareName returns the function name of mw.
+func middlewareName(mw echo.MiddlewareFunc) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(mw).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return "unknown"
+}
@@ -0,0 +1,258 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/cache"
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/httpclient"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/resilience"
+	"github.com/NSObjects/go-kit/security"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// Defaults of OAuth2IntrospectionConfig.
+const (
+	DefaultIntrospectionCacheTTL    = time.Minute
+	DefaultIntrospectionCachePrefix = "oauth2:introspect:"
+)
+
+// OAuth2IntrospectionConfig holds OAuth2 introspection middleware
+// configuration.
+type OAuth2IntrospectionConfig struct {
+	// Endpoint is the RFC 7662 introspection endpoint; required.
+	Endpoint string
+	// ClientID and ClientSecret authenticate the introspection requests
+	// with HTTP Basic authentication.
+	ClientID     string
+	ClientSecret string
+	// Client sends the introspection requests; default an
+	// httpclient.Client with a circuit breaker per host.
+	Client *httpclient.Client
+	// Cache caches active results under a hash of the token (optional).
+	Cache cache.Cache
+	// CacheTTL bounds how long an active result is cached, never past the
+	// expiry of the token; default DefaultIntrospectionCacheTTL.
+	CacheTTL time.Duration
+	// RequiredScopes are the scopes every token must grant.
+	RequiredScopes []string
+	// FailOpenReadOnly lets GET, HEAD and OPTIONS requests through without
+	// an identity while the endpoint is unavailable, instead of failing
+	// them with ErrServiceUnavailable.
+	FailOpenReadOnly bool
+	// SkipPaths are route patterns that skip introspection (see
+	// PathMatcher).
+	SkipPaths []string
+	// SkipMatcher overrides SkipPaths with a precompiled matcher.
+	SkipMatcher *PathMatcher
+}
+
+// Introspection is the RFC 7662 introspection response of a token. Roles
+// is not part of the RFC; identity providers commonly add it.
+type Introspection struct {
+	Active   bool     `json:"active"`
+	Scope    string   `json:"scope,omitempty"`
+	ClientID string   `json:"client_id,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Subject  string   `json:"sub,omitempty"`
+	Expiry   int64    `json:"exp,omitempty"`
+	Roles    []string `json:"roles,omitempty"`
+}
+
+// Scopes returns the scopes granted by the token.
+func (i *Introspection) Scopes() []string {
+	return strings.Fields(i.Scope)
+}
+
+// UserID returns the subject of the token, or its username.
+func (i *Introspection) UserID() string {
+	if i.Subject != "" {
+		return i.Subject
+	}
+	return i.Username
+}
+
+// introspectionKey is the echo.Context key of the introspection result.
+const introspectionKey = "oauth2_introspection"
+
+// IntrospectionFromContext returns the introspection result of the token
+// of the request, set by OAuth2Introspection.
+func IntrospectionFromContext(c echo.Context) (*Introspection, bool) {
+	i, ok := c.Get(introspectionKey).(*Introspection)
+	return i, ok
+}
+
+// OAuth2Introspection returns a middleware validating opaque bearer tokens
+// with the RFC 7662 introspection endpoint of an identity provider. Like
+// JWT, it sets user_id and roles for Casbin, logging and
+// utils.BuildContext:
+//
+//	api := e.Group("/api", middleware.OAuth2Introspection(middleware.OAuth2IntrospectionConfig{
+//	    Endpoint:       "https://idp.example.com/oauth2/introspect",
+//	    ClientID:       cfg.IdP.ClientID,
+//	    ClientSecret:   cfg.IdP.ClientSecret,
+//	    Cache:          c,
+//	    RequiredScopes: []string{"api"},
+//	}))
+//
+// Requests without a bearer token fail with ErrUnauthorized, inactive
+// tokens with ErrTokenInvalid, and tokens lacking required scopes with
+// ErrPermissionDenied listing them in the "missing_scopes" detail. While
+// the endpoint is unavailable, requests fail with ErrServiceUnavailable
+// (see FailOpenReadOnly).
+func OAuth2Introspection(cfg OAuth2IntrospectionConfig) echo.MiddlewareFunc {
+	if cfg.Client == nil {
+		cfg.Client = httpclient.New(
+			httpclient.WithServiceName("oauth2-introspection"),
+			httpclient.WithBreaker(resilience.BreakerConfig{}),
+		)
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DefaultIntrospectionCacheTTL
+	}
+	skip := skipMatcher(cfg.SkipMatcher, cfg.SkipPaths)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if skip.Match(c.Request().Method, c.Path()) {
+				return next(c)
+			}
+
+			token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if token = strings.TrimSpace(token); !ok || token == "" {
+				EmitSecurityEvent(c, security.KindTokenMissing, code.ErrUnauthorized, nil)
+				return code.NewError(code.ErrUnauthorized, "missing bearer token")
+			}
+
+			ctx := c.Request().Context()
+			result, err := introspect(ctx, cfg, token)
+			if err != nil {
+				if cfg.FailOpenReadOnly && isReadOnly(c.Request().Method) {
+					slog.Warn("Token introspection unavailable, serving read-only request without identity",
+						slog.String("method", c.Request().Method), slog.String("path", c.Path()), log.Err(err))
+					return next(c)
+				}
+				return code.WrapError(err, code.ErrServiceUnavailable, "token introspection unavailable")
+			}
+			if !result.Active {
+				EmitSecurityEvent(c, security.KindTokenInvalid, code.ErrTokenInvalid, nil)
+				return code.NewError(code.ErrTokenInvalid, "token is not active")
+			}
+			if missing := missingScopes(result.Scopes(), cfg.RequiredScopes); len(missing) > 0 {
+				EmitSecurityEvent(c, security.KindPermissionDenied, code.ErrPermissionDenied,
+					map[string]any{"missing_scopes": missing})
+				err := code.NewErrorf(code.ErrPermissionDenied, "token lacks scopes %s", strings.Join(missing, ", "))
+				return errors.WithDetails(err, map[string]any{"missing_scopes": missing})
+			}
+
+			userID := result.UserID()
+			c.Set("user_id", userID)
+			c.Set(string(utils.KeyRoles), result.Roles)
+			c.Set(introspectionKey, result)
+			ctx = context.WithValue(ctx, utils.KeyUserID, userID)
+			ctx = context.WithValue(ctx, utils.KeyRoles, result.Roles)
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// introspect returns the introspection result of token, from the cache
+// when possible. Expired cached results are inactive.
+func introspect(ctx context.Context, cfg OAuth2IntrospectionConfig, token string) (*Introspection, error) {
+	var key string
+	if cfg.Cache != nil {
+		sum := sha256.Sum256([]byte(token))
+		key = DefaultIntrospectionCachePrefix + hex.EncodeToString(sum[:])
+		var cached Introspection
+		if err := cfg.Cache.Get(ctx, key, &cached); err == nil {
+			if cached.Expiry != 0 && time.Now().Unix() >= cached.Expiry {
+				cached.Active = false
+			}
+			return &cached, nil
+		}
+	}
+
+	result, err := postIntrospection(ctx, cfg, token)
+	if err != nil {
+		return nil, err
+	}
+	if result.Active && result.Expiry != 0 && time.Now().Unix() >= result.Expiry {
+		result.Active = false
+	}
+	if cfg.Cache != nil && result.Active {
+		ttl := cfg.CacheTTL
+		if result.Expiry != 0 {
+			ttl = min(ttl, time.Until(time.Unix(result.Expiry, 0)))
+		}
+		if ttl > 0 {
+			if err := cfg.Cache.Set(ctx, key, result, ttl); err != nil {
+				slog.Warn("Cache token introspection failed", log.Err(err))
+			}
+		}
+	}
+	return result, nil
+}
+
+// postIntrospection sends token to the introspection endpoint.
+func postIntrospection(ctx context.Context, cfg OAuth2IntrospectionConfig, token string) (*Introspection, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+	if cfg.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	}
+
+	res, err := cfg.Client.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+		return nil, fmt.Errorf("introspection endpoint returned status %d", res.StatusCode)
+	}
+	var result Introspection
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode introspection response: %w", err)
+	}
+	return &result, nil
+}
+
+// missingScopes returns the required scopes not in granted.
+func missingScopes(granted, required []string) []string {
+	var missing []string
+	for _, s := range required {
+		if !slices.Contains(granted, s) {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
+
+// isReadOnly reports whether method is safe (RFC 9110).
+func isReadOnly(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
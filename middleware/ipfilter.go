@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// IPFilterConfig holds IP filter middleware configuration. Entries are IPs
+// or CIDRs (IPv4 or IPv6).
+type IPFilterConfig struct {
+	// Allow lists permitted clients; empty allows everyone not denied.
+	Allow []string
+	// Deny lists rejected clients; evaluated before Allow.
+	Deny []string
+	// TrustedProxies are peers whose X-Forwarded-For/X-Real-IP are honored
+	// (IPs, CIDRs or utils.NewTrustedProxies presets). When empty, the
+	// resolver installed on Echo by Setup is used.
+	TrustedProxies []string
+	// OnDenied handles a denied request; default returns a coded ErrForbidden.
+	OnDenied func(c echo.Context, ip string) error
+}
+
+// ipRules are the parsed lists of an IPFilterConfig.
+type ipRules struct {
+	allow, deny []netip.Prefix
+	trusted     *utils.TrustedProxies // nil defers to the Echo IPExtractor
+}
+
+// IPRules is a hot-swappable IP filter.
+type IPRules struct {
+	rules    atomic.Pointer[ipRules]
+	onDenied func(c echo.Context, ip string) error
+}
+
+// IPFilter returns an IP filter middleware. It fails on invalid entries.
+//
+//	filter, err := middleware.IPFilter(middleware.IPFilterConfig{Allow: []string{"10.0.0.0/8"}})
+//	admin := e.Group("/admin", filter)
+func IPFilter(cfg IPFilterConfig) (echo.MiddlewareFunc, error) {
+	r, err := NewIPRules(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return r.Middleware(), nil
+}
+
+// NewIPRules parses cfg into IPRules whose lists can be replaced at runtime.
+func NewIPRules(cfg IPFilterConfig) (*IPRules, error) {
+	r := &IPRules{onDenied: cfg.OnDenied}
+	if r.onDenied == nil {
+		r.onDenied = func(c echo.Context, ip string) error {
+			return code.NewError(code.ErrForbidden, "access denied")
+		}
+	}
+	if err := r.Update(config.IPFilterConfig{Allow: cfg.Allow, Deny: cfg.Deny, TrustedProxies: cfg.TrustedProxies}); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Update atomically replaces the lists. On error the current lists are kept.
+func (r *IPRules) Update(cfg config.IPFilterConfig) error {
+	var rules ipRules
+	var err error
+	if rules.allow, err = parsePrefixes(cfg.Allow); err != nil {
+		return fmt.Errorf("ip filter allow: %w", err)
+	}
+	if rules.deny, err = parsePrefixes(cfg.Deny); err != nil {
+		return fmt.Errorf("ip filter deny: %w", err)
+	}
+	if len(cfg.TrustedProxies) > 0 {
+		if rules.trusted, err = utils.NewTrustedProxies(cfg.TrustedProxies); err != nil {
+			return fmt.Errorf("ip filter trusted proxies: %w", err)
+		}
+	}
+	r.rules.Store(&rules)
+	return nil
+}
+
+// WatchIPRules keeps r in sync with store until ctx is done. Invalid
+// reloads are logged and ignored.
+func WatchIPRules[T any](ctx context.Context, r *IPRules, store *config.Store[T], get func(T) config.IPFilterConfig) {
+	events := store.SubscribeEvents()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			if err := r.Update(get(ev.New)); err != nil {
+				slog.Error("IP filter reload failed", log.Err(err))
+			}
+		}
+	}
+}
+
+// Middleware returns the filtering middleware.
+func (r *IPRules) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			rules := r.rules.Load()
+			ip, ok := rules.clientIP(c)
+			if !ok || !rules.allowed(ip) {
+				slog.Warn("IP denied",
+					slog.String("ip", ip.String()),
+					slog.String("remote_addr", c.Request().RemoteAddr),
+					slog.String("uri", c.Request().RequestURI),
+				)
+				return r.onDenied(c, ip.String())
+			}
+			return next(c)
+		}
+	}
+}
+
+// allowed evaluates deny before allow.
+func (rules *ipRules) allowed(ip netip.Addr) bool {
+	if containsAddr(rules.deny, ip) {
+		return false
+	}
+	return len(rules.allow) == 0 || containsAddr(rules.allow, ip)
+}
+
+// clientIP resolves the client IP with the filter's own trusted proxies,
+// or via c.RealIP() when Echo has an IPExtractor (installed by Setup).
+// Without either, only the peer address is used.
+func (rules *ipRules) clientIP(c echo.Context) (netip.Addr, bool) {
+	req := c.Request()
+	if rules.trusted == nil && c.Echo().IPExtractor != nil {
+		ip, err := netip.ParseAddr(c.RealIP())
+		return ip.Unmap(), err == nil
+	}
+	return rules.trusted.Resolve(req.RemoteAddr, req.Header)
+}
+
+func parsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, e := range entries {
+		p, err := utils.ParsePrefix(strings.TrimSpace(e))
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, p)
+	}
+	return prefixes, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,10 +1,9 @@
 package middleware
 
 import (
-	"strings"
-
 	"github.com/NSObjects/go-kit/code"
 	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/security"
 	"github.com/golang-jwt/jwt/v5"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
@@ -14,12 +13,22 @@ import (
 type JWTConfig struct {
 	// SigningKey is the secret key for JWT validation.
 	SigningKey []byte
-	// SkipPaths are paths that skip JWT validation.
+	// SkipPaths are route paths that skip JWT validation, matched exactly
+	// or, ending in "*", by prefix, unless SkipPatterns is set.
 	SkipPaths []string
+	// SkipPatterns matches SkipPaths as PathMatcher patterns, where a
+	// ":param" segment also matches literal routes such as "/users/me".
+	SkipPatterns bool
+	// SkipMatcher overrides SkipPaths with a precompiled matcher.
+	SkipMatcher *PathMatcher
 	// Enabled controls whether JWT is enabled.
 	Enabled bool
 	// ClaimsFunc creates a new claims instance.
 	ClaimsFunc func(c echo.Context) jwt.Claims
+	// KeyFile overrides SigningKey with a secret reloaded from a file:
+	// tokens signed with its previous secret keep validating for the
+	// overlap window after a rotation.
+	KeyFile *JWTKeyFile
 }
 
 // DefaultJWTConfig returns default JWT configuration.
@@ -45,21 +54,9 @@ func JWT(config *JWTConfig) echo.MiddlewareFunc {
 
 	cfg := echojwt.Config{
 		SigningKey: config.SigningKey,
-		Skipper: func(c echo.Context) bool {
-			path := c.Path()
-			for _, skipPath := range config.SkipPaths {
-				// Support exact match and prefix match (with *)
-				if path == skipPath {
-					return true
-				}
-				if len(skipPath) > 0 && skipPath[len(skipPath)-1] == '*' &&
-					strings.HasPrefix(path, skipPath[:len(skipPath)-1]) {
-					return true
-				}
-			}
-			return false
-		},
+		Skipper:    skipPaths(config.SkipMatcher, config.SkipPatterns, config.SkipPaths),
 		ErrorHandler: func(c echo.Context, err error) error {
+			EmitSecurityEvent(c, jwtFailureKind(err), code.ErrSignatureInvalid, map[string]any{"reason": err.Error()})
 			return errors.WrapCode(err, code.ErrSignatureInvalid, "JWT signature invalid")
 		},
 	}
@@ -67,10 +64,27 @@ func JWT(config *JWTConfig) echo.MiddlewareFunc {
 	if config.ClaimsFunc != nil {
 		cfg.NewClaimsFunc = config.ClaimsFunc
 	}
+	if config.KeyFile != nil {
+		cfg.KeyFunc = config.KeyFile.Keyfunc()
+	}
 
 	return echojwt.WithConfig(cfg)
 }
 
+// jwtFailureKind classifies a JWT validation failure.
+func jwtFailureKind(err error) security.Kind {
+	switch {
+	case errors.Is(err, echojwt.ErrJWTMissing):
+		return security.KindTokenMissing
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return security.KindTokenExpired
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return security.KindSignatureInvalid
+	default:
+		return security.KindTokenInvalid
+	}
+}
+
 // CreateJWTConfig creates JWT config from parameters.
 func CreateJWTConfig(secret string, skipPaths []string, enabled bool) *JWTConfig {
 	return &JWTConfig{
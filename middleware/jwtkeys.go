@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/NSObjects/go-kit/internal/filewatch"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/security"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultJWTKeyOverlap is how long the previous key of a JWTKeyFile keeps
+// validating tokens after a rotation.
+const DefaultJWTKeyOverlap = 5 * time.Minute
+
+// JWTKeyFileOptions configure a JWTKeyFile. Zero values use the defaults.
+type JWTKeyFileOptions struct {
+	// Overlap is how long the previous key keeps validating after a
+	// rotation, so tokens signed just before it stay valid; default
+	// DefaultJWTKeyOverlap.
+	Overlap time.Duration
+	// Clock defaults to utils.RealClock.
+	Clock utils.Clock
+}
+
+// JWTKeyFile is an HS256 JWT secret read from a file, e.g. mounted from a
+// secret rotated by the identity provider, and reloaded when it changes.
+// Surrounding whitespace is ignored. After a rotation tokens signed with
+// the previous secret keep validating for the overlap window:
+//
+//	keys, err := middleware.NewJWTKeyFile(cfg.JWT.SecretFile, middleware.JWTKeyFileOptions{Overlap: cfg.JWT.KeyOverlap})
+//	err = keys.Watch(ctx)
+//	e.Use(middleware.JWT(&middleware.JWTConfig{KeyFile: keys, Enabled: true}))
+//
+// Every rotation emits a security.KindKeyRotated event, and every failed
+// reload a security.KindRotationFailed event (counted by
+// metrics.SecurityMetrics).
+type JWTKeyFile struct {
+	path    string
+	overlap time.Duration
+	clock   utils.Clock
+
+	mu            sync.RWMutex
+	current       []byte
+	previous      []byte
+	previousUntil time.Time
+}
+
+// NewJWTKeyFile reads the secret of path.
+func NewJWTKeyFile(path string, opts ...JWTKeyFileOptions) (*JWTKeyFile, error) {
+	k := newJWTKeyFile(path, opts...)
+	key, err := readJWTKey(path)
+	if err != nil {
+		return nil, err
+	}
+	k.current = key
+	return k, nil
+}
+
+// newJWTKeyFile returns a JWTKeyFile of path without a secret, rejecting
+// every token until a Reload succeeds.
+func newJWTKeyFile(path string, opts ...JWTKeyFileOptions) *JWTKeyFile {
+	var o JWTKeyFileOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Overlap <= 0 {
+		o.Overlap = DefaultJWTKeyOverlap
+	}
+	if o.Clock == nil {
+		o.Clock = utils.RealClock{}
+	}
+	return &JWTKeyFile{path: path, overlap: o.Overlap, clock: o.Clock}
+}
+
+// readJWTKey reads the secret of path.
+func readJWTKey(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read jwt secret: %w", err)
+	}
+	key := bytes.TrimSpace(b)
+	if len(key) == 0 {
+		return nil, fmt.Errorf("read jwt secret: %s is empty", path)
+	}
+	return key, nil
+}
+
+// SigningKey returns the current secret, to sign new tokens with.
+func (k *JWTKeyFile) SigningKey() []byte {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return slices.Clone(k.current)
+}
+
+// VerificationKeys returns the secrets validating tokens: the current one,
+// then the previous one within the overlap window.
+func (k *JWTKeyFile) VerificationKeys() [][]byte {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	keys := [][]byte{k.current}
+	if k.previous != nil && k.clock.Now().Before(k.previousUntil) {
+		keys = append(keys, k.previous)
+	}
+	return keys
+}
+
+// Keyfunc returns a jwt.Keyfunc accepting HS256 tokens signed with any of
+// the VerificationKeys.
+func (k *JWTKeyFile) Keyfunc() jwt.Keyfunc {
+	return func(t *jwt.Token) (any, error) {
+		if t.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, fmt.Errorf("unexpected jwt signing method %s", t.Method.Alg())
+		}
+		keys := k.VerificationKeys()
+		if len(keys[0]) == 0 {
+			return nil, fmt.Errorf("jwt secret of %s not loaded", k.path)
+		}
+		set := jwt.VerificationKeySet{Keys: make([]jwt.VerificationKey, len(keys))}
+		for i, key := range keys {
+			set.Keys[i] = key
+		}
+		return set, nil
+	}
+}
+
+// Reload rereads the secret. A secret failing to read is logged and
+// returned, and the current secret is kept.
+func (k *JWTKeyFile) Reload() error {
+	key, err := readJWTKey(k.path)
+	if err != nil {
+		slog.Error("JWT secret reload failed, keeping the current secret", slog.String("path", k.path), log.Err(err))
+		security.Emit(security.Event{Kind: security.KindRotationFailed, Details: map[string]any{
+			"secret_file": k.path,
+			"error":       err.Error(),
+		}})
+		return err
+	}
+
+	k.mu.Lock()
+	if bytes.Equal(key, k.current) {
+		k.mu.Unlock()
+		return nil
+	}
+	first := k.current == nil
+	if !first {
+		k.previous = k.current
+		k.previousUntil = k.clock.Now().Add(k.overlap)
+	}
+	k.current = key
+	k.mu.Unlock()
+
+	if first {
+		slog.Info("JWT secret loaded", slog.String("path", k.path))
+		return nil
+	}
+	slog.Info("JWT secret rotated", slog.String("path", k.path), slog.Duration("overlap", k.overlap))
+	security.Emit(security.Event{Kind: security.KindKeyRotated, Details: map[string]any{
+		"secret_file": k.path,
+		"overlap":     k.overlap.String(),
+	}})
+	return nil
+}
+
+// Watch reloads the secret whenever its file changes, until ctx is done.
+func (k *JWTKeyFile) Watch(ctx context.Context) error {
+	return filewatch.Watch(ctx, []string{k.path}, func() {
+		_ = k.Reload()
+	})
+}
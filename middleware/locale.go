@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/NSObjects/go-kit/i18n"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// LocaleConfig holds locale middleware configuration.
+type LocaleConfig struct {
+	// Bundle lists the supported locales; required.
+	Bundle *i18n.Bundle
+	// QueryParam is the query parameter overriding Accept-Language;
+	// default "lang". "-" disables it.
+	QueryParam string
+}
+
+// Locale returns a middleware that sets the request locale (see
+// utils.GetLocale) to the locale of cfg.Bundle best matching the query
+// parameter or the Accept-Language header, or the default locale of the
+// bundle, and sends it back in Content-Language. Error messages and
+// validation errors are then rendered in it (see i18n.SetDefault).
+//
+//	e.Use(middleware.Locale(middleware.LocaleConfig{Bundle: bundle}))
+func Locale(cfg LocaleConfig) echo.MiddlewareFunc {
+	if cfg.QueryParam == "" {
+		cfg.QueryParam = "lang"
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var preferred []string
+			if cfg.QueryParam != "-" {
+				if lang := c.QueryParam(cfg.QueryParam); lang != "" {
+					preferred = append(preferred, lang)
+				}
+			}
+			preferred = append(preferred, parseAcceptLanguage(c.Request().Header.Get("Accept-Language"))...)
+
+			locale, ok := cfg.Bundle.Match(preferred...)
+			if !ok {
+				locale = cfg.Bundle.DefaultLocale()
+			}
+			c.SetRequest(c.Request().WithContext(utils.WithLocale(c.Request().Context(), locale)))
+			c.Response().Header().Set("Content-Language", locale)
+			return next(c)
+		}
+	}
+}
+
+// parseAcceptLanguage returns the languages of an Accept-Language header
+// by decreasing quality, without the wildcard and refused ones.
+func parseAcceptLanguage(header string) []string {
+	type lang struct {
+		tag string
+		q   float64
+	}
+	var langs []lang
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+		if q > 0 {
+			langs = append(langs, lang{tag, q})
+		}
+	}
+	slices.SortStableFunc(langs, func(a, b lang) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+	out := make([]string, len(langs))
+	for i, l := range langs {
+		out[i] = l.tag
+	}
+	return out
+}
@@ -0,0 +1,503 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	kit "github.com/NSObjects/go-kit"
+	"github.com/NSObjects/go-kit/cache"
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/health"
+	"github.com/NSObjects/go-kit/lifecycle"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/resp"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// Defaults of Maintenance.
+const (
+	DefaultMaintenanceMessage = "Service under maintenance"
+	DefaultMaintenanceKey     = "maintenance:windows"
+	DefaultMaintenanceRefresh = 30 * time.Second
+)
+
+// DefaultMaintenanceSkipPaths are the routes served during maintenance when
+// SkipPaths is unset: probes, metrics, profiling, the self-description and
+// the admin group of Setup.
+var DefaultMaintenanceSkipPaths = []string{
+	"/health", "/livez", "/readyz", "/startupz", "/metrics", "/debug/pprof/*", "/api/admin/*",
+	kit.DescribePath, kit.DescribePath + "/*",
+}
+
+// MaintenanceWindow is a weekly maintenance window.
+type MaintenanceWindow struct {
+	// Days are the weekdays it starts on; empty means every day.
+	Days []time.Weekday
+	// Hour and Minute are the local start time. On a day a DST change
+	// skips it, the window starts when the clocks resume, e.g. 03:00 for a
+	// 02:30 start.
+	Hour, Minute int
+	// Duration is the length of the window, in elapsed time: across a DST
+	// change a 02:00 window of 30m still lasts 30 minutes.
+	Duration time.Duration
+	// Location is the time zone of Days and the start time.
+	Location *time.Location
+}
+
+// ParseMaintenanceWindow parses cfg.
+func ParseMaintenanceWindow(cfg config.MaintenanceWindowConfig) (MaintenanceWindow, error) {
+	w := MaintenanceWindow{Duration: cfg.Duration}
+	for _, name := range cfg.Days {
+		d, err := config.ParseWeekday(name)
+		if err != nil {
+			return w, err
+		}
+		w.Days = append(w.Days, d)
+	}
+	start, err := time.Parse("15:04", cfg.Start)
+	if err != nil {
+		return w, fmt.Errorf("maintenance window start %q: expected HH:MM", cfg.Start)
+	}
+	w.Hour, w.Minute = start.Hour(), start.Minute()
+	if w.Duration <= 0 || w.Duration > 7*24*time.Hour {
+		return w, fmt.Errorf("maintenance window duration %s: must be between 0 and a week", cfg.Duration)
+	}
+	if w.Location, err = time.LoadLocation(cfg.TimeZone); err != nil {
+		return w, fmt.Errorf("maintenance window time zone: %w", err)
+	}
+	return w, nil
+}
+
+// occurrence returns the occurrence of w in progress at t or, when none
+// is, the next one.
+func (w MaintenanceWindow) occurrence(t time.Time) (start, end time.Time) {
+	y, m, d := t.In(w.Location).Date()
+	// A window lasts at most a week, so the occurrences ending after t
+	// start within a week of it.
+	for i := -7; i <= 7; i++ {
+		if len(w.Days) > 0 && !slices.Contains(w.Days, time.Date(y, m, d+i, 0, 0, 0, 0, w.Location).Weekday()) {
+			continue
+		}
+		start = w.start(y, m, d+i)
+		if end = start.Add(w.Duration); end.After(t) {
+			return start, end
+		}
+	}
+	return time.Time{}, time.Time{}
+}
+
+// start returns the start of the occurrence of w on the given day.
+func (w MaintenanceWindow) start(y int, m time.Month, d int) time.Time {
+	start := time.Date(y, m, d, w.Hour, w.Minute, 0, 0, w.Location)
+	// time.Date may resolve a start skipped by a DST change to before the
+	// change; the window then starts at the change.
+	if start.Hour()*60+start.Minute() < w.Hour*60+w.Minute {
+		_, start = start.ZoneBounds()
+	}
+	return start
+}
+
+// MaintenancePeriod is an occurrence of a weekly window or a one-time
+// window.
+type MaintenancePeriod struct {
+	// ID identifies one-time windows.
+	ID        string    `json:"id,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Reason    string    `json:"reason,omitempty"`
+	Recurring bool      `json:"recurring"`
+}
+
+// MaintenanceStatus is the maintenance state at a point in time.
+type MaintenanceStatus struct {
+	// Active reports whether requests are refused: the manual switch is on
+	// or a window is in progress.
+	Active bool `json:"active"`
+	Manual bool `json:"manual"`
+	// Current is the window in progress, the one ending last if several
+	// overlap.
+	Current *MaintenancePeriod `json:"current,omitempty"`
+	// Next is the first window starting later.
+	Next *MaintenancePeriod `json:"next,omitempty"`
+}
+
+// MaintenanceOptions configure a Maintenance.
+type MaintenanceOptions struct {
+	// Store persists the one-time windows, so restarts and the other
+	// instances see them; nil keeps them in memory.
+	Store cache.Cache
+	// Key is the Store key of the one-time windows; default
+	// DefaultMaintenanceKey.
+	Key string
+	// Refresh is how often Start rereads the one-time windows from Store;
+	// default DefaultMaintenanceRefresh.
+	Refresh time.Duration
+	// SkipPaths are route patterns served during maintenance (see
+	// PathMatcher); default DefaultMaintenanceSkipPaths.
+	SkipPaths []string
+	// SkipMatcher overrides SkipPaths with a precompiled matcher.
+	SkipMatcher *PathMatcher
+	// Clock is the time source, and drives the refresh of Start when a
+	// utils.TimerClock; default utils.RealClock.
+	Clock utils.Clock
+}
+
+// Maintenance is maintenance mode: a manual switch, the weekly windows of
+// config.MaintenanceConfig and one-time windows added at runtime. Its
+// Middleware refuses requests while it is active:
+//
+//	m, err := middleware.NewMaintenance(cfg.Maintenance, middleware.MaintenanceOptions{Store: c})
+//	e.Use(m.Middleware())
+//	reg.Register(m)                                  // next window in /readyz
+//	e.GET(kit.DescribePath, middleware.DescribeHandler(doc, middleware.DescribeOptions{Maintenance: m}))
+//	m.Register(admin.Group("/maintenance"))          // one-time windows
+//	runner := server.NewRunner(srv, cfg.System, m)   // loads and refreshes them
+//
+// Active also fits db.EnableReadOnlyGuard, to refuse writes from jobs.
+type Maintenance struct {
+	message string
+	windows []MaintenanceWindow
+	opts    MaintenanceOptions
+	skip    *PathMatcher
+	manual  atomic.Bool
+
+	mu      sync.RWMutex
+	oneTime []MaintenancePeriod
+	stop    context.CancelFunc
+	done    <-chan struct{}
+}
+
+// NewMaintenance creates the Maintenance of cfg. It fails on invalid
+// windows.
+func NewMaintenance(cfg config.MaintenanceConfig, opts ...MaintenanceOptions) (*Maintenance, error) {
+	var o MaintenanceOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Key == "" {
+		o.Key = DefaultMaintenanceKey
+	}
+	if o.Refresh <= 0 {
+		o.Refresh = DefaultMaintenanceRefresh
+	}
+	if o.SkipPaths == nil {
+		o.SkipPaths = DefaultMaintenanceSkipPaths
+	}
+	if o.Clock == nil {
+		o.Clock = utils.RealClock{}
+	}
+	m := &Maintenance{message: cfg.Message, opts: o, skip: skipMatcher(o.SkipMatcher, o.SkipPaths)}
+	if m.message == "" {
+		m.message = DefaultMaintenanceMessage
+	}
+	for i, wc := range cfg.Windows {
+		w, err := ParseMaintenanceWindow(wc)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window %d: %w", i, err)
+		}
+		m.windows = append(m.windows, w)
+	}
+	m.manual.Store(cfg.Enabled)
+	return m, nil
+}
+
+// SetManual turns the manual switch on or off.
+func (m *Maintenance) SetManual(on bool) {
+	if m.manual.Swap(on) != on {
+		slog.Warn("Maintenance mode switched", slog.Bool("on", on))
+	}
+}
+
+// Active reports whether maintenance is active now.
+func (m *Maintenance) Active() bool {
+	return m.Status().Active
+}
+
+// Status returns the maintenance state now.
+func (m *Maintenance) Status() MaintenanceStatus {
+	now := m.opts.Clock.Now()
+	st := MaintenanceStatus{Manual: m.manual.Load()}
+
+	consider := func(p MaintenancePeriod) {
+		switch {
+		case !p.End.After(now):
+		case !p.Start.After(now):
+			if st.Current == nil || p.End.After(st.Current.End) {
+				st.Current = &p
+			}
+		case st.Next == nil || p.Start.Before(st.Next.Start):
+			st.Next = &p
+		}
+	}
+	for _, w := range m.windows {
+		start, end := w.occurrence(now)
+		consider(MaintenancePeriod{Start: start, End: end, Recurring: true})
+		if !start.After(now) {
+			start, end = w.occurrence(end)
+			consider(MaintenancePeriod{Start: start, End: end, Recurring: true})
+		}
+	}
+	m.mu.RLock()
+	for _, p := range m.oneTime {
+		consider(p)
+	}
+	m.mu.RUnlock()
+
+	st.Active = st.Manual || st.Current != nil
+	return st
+}
+
+// Middleware refuses requests with ErrServiceUnavailable while maintenance
+// is active, except on the skip paths. Retry-After is the end of the
+// window in progress; the "maintenance" detail is the status.
+func (m *Maintenance) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if m.skip.Match(c.Request().Method, c.Path()) {
+				return next(c)
+			}
+			st := m.Status()
+			if !st.Active {
+				return next(c)
+			}
+			if st.Current != nil && !st.Manual {
+				wait := st.Current.End.Sub(m.opts.Clock.Now())
+				c.Response().Header().Set("Retry-After", strconv.Itoa(max(int(math.Ceil(wait.Seconds())), 1)))
+			}
+			err := code.NewError(code.ErrServiceUnavailable, m.message)
+			return errors.WithDetails(err, map[string]any{"maintenance": st})
+		}
+	}
+}
+
+// Windows returns the one-time windows that have not ended, by start.
+func (m *Maintenance) Windows() []MaintenancePeriod {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.oneTime)
+}
+
+// AddWindow adds a one-time window from start to end and persists it.
+func (m *Maintenance) AddWindow(ctx context.Context, start, end time.Time, reason string) (MaintenancePeriod, error) {
+	if !end.After(start) {
+		return MaintenancePeriod{}, code.NewError(code.ErrBadRequest, "maintenance window must end after it starts")
+	}
+	if !end.After(m.opts.Clock.Now()) {
+		return MaintenancePeriod{}, code.NewError(code.ErrBadRequest, "maintenance window already ended")
+	}
+	p := MaintenancePeriod{ID: utils.NewULID(), Start: start, End: end, Reason: reason}
+	err := m.update(ctx, func(ps []MaintenancePeriod) ([]MaintenancePeriod, error) {
+		return append(ps, p), nil
+	})
+	if err != nil {
+		return MaintenancePeriod{}, err
+	}
+	slog.Warn("Maintenance window added", slog.String("id", p.ID), slog.Time("start", start), slog.Time("end", end))
+	return p, nil
+}
+
+// CancelWindow removes the one-time window id. Unknown ids are
+// code.ErrNotFound.
+func (m *Maintenance) CancelWindow(ctx context.Context, id string) error {
+	err := m.update(ctx, func(ps []MaintenancePeriod) ([]MaintenancePeriod, error) {
+		i := slices.IndexFunc(ps, func(p MaintenancePeriod) bool { return p.ID == id })
+		if i < 0 {
+			return nil, code.NewErrorf(code.ErrNotFound, "maintenance window %s not found", id)
+		}
+		return slices.Delete(ps, i, i+1), nil
+	})
+	if err != nil {
+		return err
+	}
+	slog.Warn("Maintenance window canceled", slog.String("id", id))
+	return nil
+}
+
+// update applies fn to the latest one-time windows, persists and keeps
+// the result without the ended windows.
+func (m *Maintenance) update(ctx context.Context, fn func([]MaintenancePeriod) ([]MaintenancePeriod, error)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ps := slices.Clone(m.oneTime)
+	if m.opts.Store != nil {
+		var err error
+		if ps, err = m.read(ctx); err != nil {
+			return err
+		}
+	}
+	ps, err := fn(ps)
+	if err != nil {
+		return err
+	}
+	ps = m.prune(ps)
+	if m.opts.Store != nil {
+		if err := m.opts.Store.Set(ctx, m.opts.Key, ps, 0); err != nil {
+			return code.WrapRedisError(err, "save maintenance windows")
+		}
+	}
+	m.oneTime = ps
+	return nil
+}
+
+// Load reads the one-time windows from Store.
+func (m *Maintenance) Load(ctx context.Context) error {
+	if m.opts.Store == nil {
+		return nil
+	}
+	ps, err := m.read(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.oneTime = m.prune(ps)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Maintenance) read(ctx context.Context) ([]MaintenancePeriod, error) {
+	var ps []MaintenancePeriod
+	if _, err := cache.GetResult(ctx, m.opts.Store, m.opts.Key, &ps); err != nil {
+		return nil, code.WrapRedisError(err, "load maintenance windows")
+	}
+	return ps, nil
+}
+
+// prune drops the ended windows of ps and sorts the others by start.
+func (m *Maintenance) prune(ps []MaintenancePeriod) []MaintenancePeriod {
+	now := m.opts.Clock.Now()
+	ps = slices.DeleteFunc(ps, func(p MaintenancePeriod) bool { return !p.End.After(now) })
+	slices.SortFunc(ps, func(a, b MaintenancePeriod) int { return a.Start.Compare(b.Start) })
+	return ps
+}
+
+// Start loads the one-time windows and rereads them every Refresh, to see
+// the changes of other instances, until Stop. It implements
+// server.Component.
+func (m *Maintenance) Start(ctx context.Context) error {
+	if err := m.Load(ctx); err != nil {
+		return err
+	}
+	if m.opts.Store == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != nil {
+		return nil
+	}
+	loopCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	m.stop = cancel
+	m.done = lifecycle.Go(loopCtx, "maintenance.refresh", func(ctx context.Context) error {
+		ticker := utils.NewTicker(m.opts.Clock, m.opts.Refresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C():
+				if err := m.Load(ctx); err != nil {
+					slog.Warn("Maintenance windows refresh failed", log.Err(err))
+				}
+			}
+		}
+	}).Done()
+	return nil
+}
+
+// Stop stops the refresh of Start.
+func (m *Maintenance) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	stop, done := m.stop, m.done
+	m.stop = nil
+	m.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+	stop()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Name implements health.Checker.
+func (m *Maintenance) Name() string { return "maintenance" }
+
+// Check implements health.Checker, so /readyz shows the maintenance in
+// progress or the next window. It is degraded during maintenance, never
+// unhealthy: instances stay in rotation to answer with the maintenance
+// error.
+func (m *Maintenance) Check(context.Context) health.Check {
+	st := m.Status()
+	check := health.Check{Name: m.Name(), Status: health.StatusHealthy}
+	switch {
+	case st.Manual:
+		check.Status = health.StatusDegraded
+		check.Message = "maintenance mode on"
+	case st.Current != nil:
+		check.Status = health.StatusDegraded
+		check.Message = "maintenance until " + st.Current.End.Format(time.RFC3339)
+	}
+	if st.Next != nil {
+		next := fmt.Sprintf("next maintenance %s to %s", st.Next.Start.Format(time.RFC3339), st.Next.End.Format(time.RFC3339))
+		if check.Message != "" {
+			next = check.Message + "; " + next
+		}
+		check.Message = next
+	}
+	return check
+}
+
+// MaintenanceWindowRequest is the body adding a one-time window.
+type MaintenanceWindowRequest struct {
+	Start  time.Time `json:"start" validate:"required"`
+	End    time.Time `json:"end" validate:"required"`
+	Reason string    `json:"reason"`
+}
+
+// MaintenanceManualRequest is the body of the manual switch.
+type MaintenanceManualRequest struct {
+	On bool `json:"on"`
+}
+
+// Register mounts the maintenance API on g, typically an admin group:
+//
+//	GET    /                status
+//	PUT    /manual          {"on": true} switches maintenance mode
+//	GET    /windows         one-time windows
+//	POST   /windows         {"start": ..., "end": ..., "reason": ...} adds one
+//	DELETE /windows/:id     cancels one
+func (m *Maintenance) Register(g *echo.Group) {
+	g.GET("", func(c echo.Context) error {
+		return resp.SuccessJSON(c, m.Status())
+	})
+	g.PUT("/manual", resp.Handler(func(ctx context.Context, req MaintenanceManualRequest) (MaintenanceStatus, error) {
+		m.SetManual(req.On)
+		return m.Status(), nil
+	}))
+	g.GET("/windows", func(c echo.Context) error {
+		ws := m.Windows()
+		return resp.ListDataResponse(c, ws, int64(len(ws)))
+	})
+	g.POST("/windows", resp.Handler(func(ctx context.Context, req MaintenanceWindowRequest) (MaintenancePeriod, error) {
+		return m.AddWindow(ctx, req.Start, req.End, req.Reason)
+	}))
+	g.DELETE("/windows/:id", func(c echo.Context) error {
+		if err := m.CancelWindow(c.Request().Context(), c.Param("id")); err != nil {
+			return err
+		}
+		return resp.OperateSuccess(c)
+	})
+}
@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// PathMatcher matches requests against a set of route patterns. Patterns
+// are compiled once and matched without allocating. Supported forms:
+//
+//	/api/login              exact path
+//	/api/public/*           prefix ("*" only at the end)
+//	/api/users/:id          ":param" matches one non-empty segment
+//	/api/users/:id/files/*  params followed by a prefix
+//	POST /api/webhooks/*    optional method prefix
+//
+// Paths are matched against c.Path() (the route template), so ":param"
+// segments also match the template's own ":name" segments.
+type PathMatcher struct {
+	patterns []pathPattern
+}
+
+type patternKind uint8
+
+const (
+	patternExact patternKind = iota
+	patternPrefix
+	patternSegments
+)
+
+type pathPattern struct {
+	method string // empty matches any method
+	kind   patternKind
+	path   string   // exact path or prefix
+	segs   []string // patternSegments: literal segments, ":" for params
+	tail   bool     // patternSegments: trailing "/<prefix>*"
+}
+
+var httpMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true,
+	"DELETE": true, "OPTIONS": true, "CONNECT": true, "TRACE": true,
+}
+
+// NewPathMatcher compiles patterns into a PathMatcher.
+func NewPathMatcher(patterns ...string) (*PathMatcher, error) {
+	m := &PathMatcher{patterns: make([]pathPattern, 0, len(patterns))}
+	for _, p := range patterns {
+		pp, err := compilePathPattern(p)
+		if err != nil {
+			return nil, err
+		}
+		m.patterns = append(m.patterns, pp)
+	}
+	return m, nil
+}
+
+// MustPathMatcher is NewPathMatcher that panics on invalid patterns.
+func MustPathMatcher(patterns ...string) *PathMatcher {
+	m, err := NewPathMatcher(patterns...)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Match reports whether method and routePath match any pattern. A nil
+// matcher matches nothing.
+func (m *PathMatcher) Match(method, routePath string) bool {
+	if m == nil {
+		return false
+	}
+	for i := range m.patterns {
+		if m.patterns[i].match(method, routePath) {
+			return true
+		}
+	}
+	return false
+}
+
+func compilePathPattern(s string) (pathPattern, error) {
+	var pp pathPattern
+	raw := s
+	s = strings.TrimSpace(s)
+	if method, rest, ok := strings.Cut(s, " "); ok {
+		method = strings.ToUpper(method)
+		if !httpMethods[method] {
+			return pp, fmt.Errorf("path pattern %q: unknown method %q", raw, method)
+		}
+		pp.method = method
+		s = strings.TrimSpace(rest)
+	}
+	if s == "" {
+		return pp, fmt.Errorf("path pattern %q: empty path", raw)
+	}
+	if !strings.HasPrefix(s, "/") && s != "*" {
+		return pp, fmt.Errorf("path pattern %q: path must start with '/'", raw)
+	}
+	if i := strings.IndexByte(s, '*'); i >= 0 && i != len(s)-1 {
+		return pp, fmt.Errorf("path pattern %q: '*' is only allowed at the end", raw)
+	}
+
+	body, tail := strings.CutSuffix(s, "*")
+	if !strings.Contains(body, ":") {
+		pp.path = body
+		if tail {
+			pp.kind = patternPrefix
+		}
+		return pp, nil
+	}
+
+	pp.kind = patternSegments
+	pp.tail = tail
+	parts := strings.Split(body[1:], "/")
+	if tail {
+		// The text after the last '/' is the literal prefix of the tail.
+		last := parts[len(parts)-1]
+		if strings.Contains(last, ":") {
+			return pp, fmt.Errorf("path pattern %q: parameter cannot be followed by '*'", raw)
+		}
+		pp.path = last
+		parts = parts[:len(parts)-1]
+	}
+	for _, seg := range parts {
+		switch {
+		case seg == ":":
+			return pp, fmt.Errorf("path pattern %q: empty parameter name", raw)
+		case strings.HasPrefix(seg, ":"):
+			seg = ":"
+		case strings.Contains(seg, ":"):
+			return pp, fmt.Errorf("path pattern %q: parameter must span a whole segment", raw)
+		}
+		pp.segs = append(pp.segs, seg)
+	}
+	return pp, nil
+}
+
+func (p *pathPattern) match(method, path string) bool {
+	if p.method != "" && p.method != method {
+		return false
+	}
+	switch p.kind {
+	case patternExact:
+		return path == p.path
+	case patternPrefix:
+		return strings.HasPrefix(path, p.path)
+	}
+
+	for _, seg := range p.segs {
+		if len(path) == 0 || path[0] != '/' {
+			return false
+		}
+		path = path[1:]
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		cur := path[:end]
+		path = path[end:]
+		if seg == ":" {
+			if cur == "" {
+				return false
+			}
+		} else if cur != seg {
+			return false
+		}
+	}
+	if !p.tail {
+		return path == ""
+	}
+	return len(path) > 0 && path[0] == '/' && strings.HasPrefix(path[1:], p.path)
+}
+
+// skipPaths returns the Skipper of the skip list of JWT, CSRF and Casbin: m, or
+// paths compiled as patterns when patterns is set, or else paths matched
+// against c.Path() as they always were, exactly or, ending in "*", by
+// prefix. Patterns are opt-in because ":param" segments also match
+// literal routes: "/api/users/:id" would skip "/api/users/me".
+func skipPaths(m *PathMatcher, patterns bool, paths []string) func(c echo.Context) bool {
+	if m != nil || patterns {
+		skip := skipMatcher(m, paths)
+		return func(c echo.Context) bool {
+			return skip.Match(c.Request().Method, c.Path())
+		}
+	}
+	exact := make(map[string]bool, len(paths))
+	var prefixes []string
+	for _, p := range paths {
+		exact[p] = true
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return func(c echo.Context) bool {
+		path := c.Path()
+		if exact[path] {
+			return true
+		}
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// skipMatcher returns m, or compiles the skip path list. Invalid patterns
+// are a configuration error and panic at construction time.
+func skipMatcher(m *PathMatcher, paths []string) *PathMatcher {
+	if m != nil {
+		return m
+	}
+	compiled, err := NewPathMatcher(paths...)
+	if err != nil {
+		panic(fmt.Sprintf("middleware: invalid skip paths: %v", err))
+	}
+	return compiled
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func routeContext(method, route string) echo.Context {
+	c := echo.New().NewContext(httptest.NewRequest(method, "/", nil), httptest.NewRecorder())
+	c.SetPath(route)
+	return c
+}
+
+// TestSkipPathsExactAndPrefix checks the default matching of the JWT, CSRF
+// and Casbin skip lists.
+func TestSkipPathsExactAndPrefix(t *testing.T) {
+	// Entries compiling to no pattern must not panic.
+	skip := skipPaths(nil, false, []string{"/api/login", "/api/public/*", "/api/users/:id", "", "/a/*/b"})
+	tests := map[string]bool{
+		"/api/login":        true,
+		"/api/login/2fa":    false,
+		"/api/public/":      true,
+		"/api/public/files": true,
+		"/api/users/:id":    true,
+		"/api/users/me":     false,
+		"/api/users/:id/x":  false,
+		"/a/*/b":            true,
+		"/a/x/b":            false,
+	}
+	for route, want := range tests {
+		if got := skip(routeContext(http.MethodGet, route)); got != want {
+			t.Errorf("skip(%q) = %v, want %v", route, got, want)
+		}
+	}
+}
+
+func TestSkipPathsPatterns(t *testing.T) {
+	skip := skipPaths(nil, true, []string{"/api/users/:id", "POST /api/hooks/*"})
+	tests := []struct {
+		method, route string
+		want          bool
+	}{
+		{http.MethodGet, "/api/users/me", true},
+		{http.MethodGet, "/api/users/:id", true},
+		{http.MethodGet, "/api/users", false},
+		{http.MethodPost, "/api/hooks/github", true},
+		{http.MethodGet, "/api/hooks/github", false},
+	}
+	for _, tt := range tests {
+		if got := skip(routeContext(tt.method, tt.route)); got != tt.want {
+			t.Errorf("skip(%s %s) = %v, want %v", tt.method, tt.route, got, tt.want)
+		}
+	}
+}
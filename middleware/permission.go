@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Enforcement of routes without a declared Permission (see
+// CasbinConfig.Unannotated).
+const (
+	// UnannotatedPath enforces the request path and method (default).
+	UnannotatedPath = "path"
+	// UnannotatedDeny rejects the request.
+	UnannotatedDeny = "deny"
+	// UnannotatedAllow lets the request through.
+	UnannotatedAllow = "allow"
+)
+
+// Permission is the Casbin object and action a route requires.
+type Permission struct {
+	Object string `json:"object"`
+	Action string `json:"action"`
+}
+
+// RoutePermission is an entry of the route → permission matrix.
+type RoutePermission struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Permission
+}
+
+// PermissionRegistry declares the Permission of routes by method and route
+// template, so policies name stable objects and actions instead of URLs:
+//
+//	perms := middleware.NewPermissionRegistry()
+//	groups := middleware.Setup(e, middleware.SetupDeps{Permissions: perms, ...})
+//	perms.Add(groups.Admin, http.MethodDelete, "/users/:id", h.DeleteUser,
+//	    middleware.Permission{Object: "user", Action: "delete"})
+//
+//	p, admin, user, delete
+//
+// The Casbin middleware enforces (user, object, action) for declared
+// routes and falls back to CasbinConfig.Unannotated for the others.
+type PermissionRegistry struct {
+	mu    sync.RWMutex
+	perms map[string]RoutePermission // method + " " + path -> permission
+}
+
+// NewPermissionRegistry creates an empty registry.
+func NewPermissionRegistry() *PermissionRegistry {
+	return &PermissionRegistry{perms: make(map[string]RoutePermission)}
+}
+
+// Add registers h on g like g.Add and declares its permission.
+func (r *PermissionRegistry) Add(g *echo.Group, method, path string, h echo.HandlerFunc, p Permission, m ...echo.MiddlewareFunc) *echo.Route {
+	route := g.Add(method, path, h, m...)
+	r.Set(route.Method, route.Path, p)
+	return route
+}
+
+// Set declares the permission of the route with method and template path,
+// e.g. for routes registered elsewhere: perms.Set(route.Method, route.Path, p).
+func (r *PermissionRegistry) Set(method, path string, p Permission) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.perms[method+" "+path] = RoutePermission{Method: method, Path: path, Permission: p}
+}
+
+// Lookup returns the permission of the route with method and template
+// path.
+func (r *PermissionRegistry) Lookup(method, path string) (Permission, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rp, ok := r.perms[method+" "+path]
+	return rp.Permission, ok
+}
+
+// lookup is Lookup on a possibly nil registry.
+func (r *PermissionRegistry) lookup(method, path string) (Permission, bool) {
+	if r == nil {
+		return Permission{}, false
+	}
+	return r.Lookup(method, path)
+}
+
+// Matrix returns the declared permissions sorted by path and method.
+func (r *PermissionRegistry) Matrix() []RoutePermission {
+	r.mu.RLock()
+	matrix := make([]RoutePermission, 0, len(r.perms))
+	for _, rp := range r.perms {
+		matrix = append(matrix, rp)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(matrix, func(i, j int) bool {
+		if matrix[i].Path != matrix[j].Path {
+			return matrix[i].Path < matrix[j].Path
+		}
+		return matrix[i].Method < matrix[j].Method
+	})
+	return matrix
+}
+
+// Handler serves Matrix as plain JSON, for policy authoring. Mount it on an
+// internal router or the admin group.
+func (r *PermissionRegistry) Handler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, r.Matrix())
+	}
+}
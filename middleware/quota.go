@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"log/slog"
+	"strconv"
+
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/quota"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// Quota response headers.
+const (
+	HeaderQuotaLimit     = "X-Quota-Limit"
+	HeaderQuotaRemaining = "X-Quota-Remaining"
+	HeaderQuotaReset     = "X-Quota-Reset"
+)
+
+// QuotaConfig holds quota tracking configuration.
+type QuotaConfig struct {
+	// Tracker records the usage (required).
+	Tracker *quota.Tracker
+	// KeyFunc returns the API key ID of the request; requests without one
+	// are not tracked. Default: the X-API-Key header.
+	KeyFunc func(c echo.Context) string
+	// Cost returns the units a request consumes; default 1.
+	Cost func(c echo.Context) int64
+	// Skipper skips tracking for matching requests.
+	Skipper func(c echo.Context) bool
+	// Headers selects the standard rate limit headers sent besides the
+	// X-Quota ones (see SetRateLimitHeaders).
+	Headers RateLimitHeaderOptions
+	// Clock is the time source of the reset delays; default
+	// utils.RealClock. Use the Clock of the Tracker.
+	Clock utils.Clock
+}
+
+// Quota returns a middleware that counts requests against the monthly quota
+// of their API key and sets the X-Quota-Limit, X-Quota-Remaining and
+// X-Quota-Reset (Unix seconds) headers, along with the standard rate limit
+// headers of the "quota" policy (see SetRateLimitHeaders). Keys without a
+// cap get none. Requests beyond the hard cap are rejected with
+// code.ErrTooManyRequests (429), Retry-After and retry_after_seconds in
+// the details. When usage cannot be recorded the request is let through
+// and the error logged.
+func Quota(cfg QuotaConfig) echo.MiddlewareFunc {
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = func(c echo.Context) string { return c.Request().Header.Get("X-API-Key") }
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = utils.RealClock{}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cfg.Skipper != nil && cfg.Skipper(c) {
+				return next(c)
+			}
+			keyID := cfg.KeyFunc(c)
+			if keyID == "" {
+				return next(c)
+			}
+			cost := int64(1)
+			if cfg.Cost != nil {
+				cost = cfg.Cost(c)
+			}
+
+			ctx := utils.BuildContext(c)
+			st, err := cfg.Tracker.Record(ctx, keyID, cost)
+			if err != nil {
+				slog.ErrorContext(ctx, "Quota record failed", slog.String("key_id", keyID), log.Err(err))
+				return next(c)
+			}
+			now := cfg.Clock.Now()
+			limit := RateLimitState{
+				Policy:    "quota",
+				Limit:     st.Limit,
+				Remaining: st.Remaining,
+				Window:    st.Reset.Sub(st.Reset.AddDate(0, -1, 0)),
+				Reset:     st.Reset,
+			}
+			if st.Limit > 0 {
+				h := c.Response().Header()
+				h.Set(HeaderQuotaLimit, strconv.FormatInt(st.Limit, 10))
+				h.Set(HeaderQuotaRemaining, strconv.FormatInt(st.Remaining, 10))
+				h.Set(HeaderQuotaReset, strconv.FormatInt(st.Reset.Unix(), 10))
+				SetRateLimitHeaders(h, limit, now, cfg.Headers)
+			}
+			if st.Exceeded {
+				return RateLimitExceeded(c, quota.Exceeded(st), limit, now)
+			}
+			return next(c)
+		}
+	}
+}
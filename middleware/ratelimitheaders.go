@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/labstack/echo/v4"
+)
+
+// Rate limit response headers: the de facto X-RateLimit trio and the
+// fields of the IETF draft "RateLimit header fields for HTTP"
+// (draft-ietf-httpapi-ratelimit-headers).
+const (
+	HeaderXRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderXRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderXRateLimitReset     = "X-RateLimit-Reset"
+	HeaderRateLimit           = "RateLimit"
+	HeaderRateLimitPolicy     = "RateLimit-Policy"
+)
+
+// RateLimitState is the window state of a limiter, as advertised to
+// clients.
+type RateLimitState struct {
+	// Policy names the limit in the draft fields, e.g. "quota".
+	Policy string
+	// Limit is the number of units of a window; 0 or less is unlimited.
+	Limit int64
+	// Remaining is the number of units left in the window.
+	Remaining int64
+	// Window is the length of a window.
+	Window time.Duration
+	// Reset is when the window ends.
+	Reset time.Time
+}
+
+// RateLimitHeaderOptions selects the headers of SetRateLimitHeaders.
+type RateLimitHeaderOptions struct {
+	// NoLegacy omits the X-RateLimit-Limit, -Remaining and -Reset headers.
+	NoLegacy bool
+	// NoDraft omits the RateLimit and RateLimit-Policy fields.
+	NoDraft bool
+	// ResetEpoch sends X-RateLimit-Reset as Unix seconds instead of
+	// seconds until the reset. The draft fields always use seconds until
+	// the reset.
+	ResetEpoch bool
+}
+
+// SetRateLimitHeaders sets the rate limit headers of st at time now on h:
+//
+//	X-RateLimit-Limit: 100
+//	X-RateLimit-Remaining: 42
+//	X-RateLimit-Reset: 30
+//	RateLimit-Policy: "api";q=100;w=60
+//	RateLimit: "api";r=42;t=30
+//
+// Nothing is set for unlimited states, so unlimited routes carry no rate
+// limit headers.
+func SetRateLimitHeaders(h http.Header, st RateLimitState, now time.Time, opts RateLimitHeaderOptions) {
+	if st.Limit <= 0 {
+		return
+	}
+	remaining := max(st.Remaining, 0)
+	resetIn := secondsUntil(st.Reset, now)
+	if !opts.NoLegacy {
+		h.Set(HeaderXRateLimitLimit, strconv.FormatInt(st.Limit, 10))
+		h.Set(HeaderXRateLimitRemaining, strconv.FormatInt(remaining, 10))
+		if opts.ResetEpoch {
+			h.Set(HeaderXRateLimitReset, strconv.FormatInt(st.Reset.Unix(), 10))
+		} else {
+			h.Set(HeaderXRateLimitReset, strconv.FormatInt(resetIn, 10))
+		}
+	}
+	if !opts.NoDraft {
+		policy := sfString(st.Policy)
+		h.Set(HeaderRateLimitPolicy, policy+";q="+strconv.FormatInt(st.Limit, 10)+";w="+strconv.FormatInt(int64(math.Ceil(st.Window.Seconds())), 10))
+		h.Set(HeaderRateLimit, policy+";r="+strconv.FormatInt(remaining, 10)+";t="+strconv.FormatInt(resetIn, 10))
+	}
+}
+
+// RateLimitExceeded returns err, the coded 429 error of a request rejected
+// by the limiter of st, with the seconds until the window resets as
+// "retry_after_seconds" in its details, and sets the Retry-After header.
+func RateLimitExceeded(c echo.Context, err error, st RateLimitState, now time.Time) error {
+	retryAfter := max(secondsUntil(st.Reset, now), 1)
+	c.Response().Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+	return errors.WithDetails(err, map[string]any{"retry_after_seconds": retryAfter})
+}
+
+// secondsUntil returns the whole seconds from now until t, rounded up and
+// never negative.
+func secondsUntil(t, now time.Time) int64 {
+	return max(int64(math.Ceil(t.Sub(now).Seconds())), 0)
+}
+
+// sfString encodes s as a structured field string (RFC 8941), dropping
+// the characters it cannot hold.
+func sfString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
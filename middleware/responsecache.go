@@ -0,0 +1,287 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/cache"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/metrics"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+)
+
+// HeaderXCache reports whether a response was served from the response
+// cache (HIT) or computed (MISS).
+const HeaderXCache = "X-Cache"
+
+// cacheTagsKey is the echo context key of tags added by AddCacheTags.
+const cacheTagsKey = "response_cache_tags"
+
+// ResponseCacheRoute configures caching of one route.
+type ResponseCacheRoute struct {
+	// TTL of cached responses; required.
+	TTL time.Duration
+	// Tags are attached to every cached response of the route and can be
+	// purged with PurgeTags.
+	Tags []string
+	// Vary lists extra request headers that are part of the key.
+	Vary []string
+	// AllowAuthenticated caches responses of authenticated requests. The
+	// response must not depend on the user: it is shared by everyone.
+	AllowAuthenticated bool
+}
+
+// ResponseCacheConfig holds response cache configuration.
+type ResponseCacheConfig struct {
+	// Routes maps route templates (c.Path()) to their settings. GET
+	// requests to other routes are not cached.
+	Routes map[string]ResponseCacheRoute
+	// Vary lists request headers that are part of every key
+	// (e.g. Accept-Language).
+	Vary []string
+	// Headers lists the response headers stored with the body; default
+	// Content-Type.
+	Headers []string
+	// MaxBodySize caps cached bodies; larger responses are not cached.
+	// Default 1 MB.
+	MaxBodySize int
+	// KeyPrefix prefixes cache keys; default "respcache".
+	KeyPrefix string
+	// Metrics records hits, misses, bypasses and purges.
+	Metrics *metrics.ResponseCacheMetrics
+}
+
+// cachedResponse is the stored form of a response.
+type cachedResponse struct {
+	Status int                 `json:"status"`
+	Header map[string][]string `json:"header,omitempty"`
+	Body   []byte              `json:"body"`
+	// Tags records the generation of each tag when the response was stored.
+	Tags map[string]int64 `json:"tags,omitempty"`
+}
+
+// ResponseCacher caches full GET responses in a cache.Cache. Purges bump a
+// per-route or per-tag generation instead of deleting keys, so they work
+// with any Cache and across instances.
+type ResponseCacher struct {
+	cache cache.Cache
+	cfg   ResponseCacheConfig
+}
+
+// ResponseCache returns a middleware caching the configured routes in c.
+// Use NewResponseCacher when handlers need to purge.
+func ResponseCache(c cache.Cache, cfg ResponseCacheConfig) echo.MiddlewareFunc {
+	return NewResponseCacher(c, cfg).Middleware()
+}
+
+// NewResponseCacher creates a ResponseCacher.
+//
+//	rc := middleware.NewResponseCacher(redisCache, middleware.ResponseCacheConfig{
+//	    Routes: map[string]middleware.ResponseCacheRoute{
+//	        "/api/catalog/:id": {TTL: time.Minute, Tags: []string{"catalog"}},
+//	    },
+//	})
+//	api.Use(rc.Middleware())
+//	// after a write:
+//	_ = rc.PurgeTags(ctx, "catalog")
+func NewResponseCacher(c cache.Cache, cfg ResponseCacheConfig) *ResponseCacher {
+	if len(cfg.Headers) == 0 {
+		cfg.Headers = []string{echo.HeaderContentType}
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 1 << 20
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = "respcache"
+	}
+	return &ResponseCacher{cache: c, cfg: cfg}
+}
+
+// AddCacheTags attaches tags to the response of the current request, in
+// addition to the route's configured tags.
+func AddCacheTags(c echo.Context, tags ...string) {
+	existing, _ := c.Get(cacheTagsKey).([]string)
+	c.Set(cacheTagsKey, append(existing, tags...))
+}
+
+// PurgeRoute invalidates every cached response of a route template.
+func (rc *ResponseCacher) PurgeRoute(ctx context.Context, route string) error {
+	rc.recordPurge("route")
+	return rc.cache.Set(ctx, rc.generationKey("route", route), time.Now().UnixNano(), 0)
+}
+
+// PurgeTags invalidates every cached response carrying any of tags.
+func (rc *ResponseCacher) PurgeTags(ctx context.Context, tags ...string) error {
+	gen := time.Now().UnixNano()
+	for _, tag := range tags {
+		rc.recordPurge("tag")
+		if err := rc.cache.Set(ctx, rc.generationKey("tag", tag), gen, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Middleware returns the caching middleware. A request bypasses the cache
+// when it sends Cache-Control or Pragma no-cache, or is authenticated and
+// the route does not allow it. Only complete 200 responses without
+// Set-Cookie are stored.
+func (rc *ResponseCacher) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			route := c.Path()
+			rt, ok := rc.cfg.Routes[route]
+			if req.Method != http.MethodGet || !ok || rt.TTL <= 0 {
+				return next(c)
+			}
+			if clientNoCache(req.Header) || (!rt.AllowAuthenticated && isAuthenticated(c)) {
+				rc.record(route, "bypass")
+				return next(c)
+			}
+
+			ctx := req.Context()
+			key := rc.entryKey(c, route, rt, rc.generation(ctx, "route", route))
+
+			var entry cachedResponse
+			if rc.lookup(ctx, key, &entry) && rc.fresh(ctx, entry.Tags) {
+				rc.record(route, "hit")
+				return writeCachedResponse(c, &entry)
+			}
+
+			rc.record(route, "miss")
+			tagGens := rc.generations(ctx, rt.Tags)
+			c.Response().Header().Set(HeaderXCache, "MISS")
+			res, err := captureResponse(c, next, rc.cfg.MaxBodySize)
+			if err != nil || !res.shareable || res.status != http.StatusOK || res.header.Get("Set-Cookie") != "" {
+				return err
+			}
+
+			dynamic, _ := c.Get(cacheTagsKey).([]string)
+			for tag, gen := range rc.generations(ctx, dynamic) {
+				tagGens[tag] = gen
+			}
+			entry = cachedResponse{Status: res.status, Body: res.body, Tags: tagGens}
+			for _, name := range rc.cfg.Headers {
+				if vs := res.header.Values(name); len(vs) > 0 {
+					if entry.Header == nil {
+						entry.Header = make(map[string][]string, len(rc.cfg.Headers))
+					}
+					entry.Header[http.CanonicalHeaderKey(name)] = vs
+				}
+			}
+			if err := rc.cache.Set(ctx, key, &entry, rt.TTL); err != nil {
+				slog.Warn("Response cache store failed", slog.String("route", route), log.Err(err))
+			}
+			return nil
+		}
+	}
+}
+
+// entryKey builds the key from the route, its generation, the request path,
+// the sorted query and the vary headers.
+func (rc *ResponseCacher) entryKey(c echo.Context, route string, rt ResponseCacheRoute, gen int64) string {
+	req := c.Request()
+	h := sha256.New()
+	h.Write([]byte(req.URL.Path))
+	h.Write([]byte{'?'})
+	h.Write([]byte(req.URL.Query().Encode()))
+	for _, vary := range [][]string{rc.cfg.Vary, rt.Vary} {
+		for _, name := range vary {
+			h.Write([]byte("\n" + strings.ToLower(name) + ":" + req.Header.Get(name)))
+		}
+	}
+	return rc.cfg.KeyPrefix + ":" + route + ":" + strconv.FormatInt(gen, 36) + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+func (rc *ResponseCacher) generationKey(kind, name string) string {
+	return rc.cfg.KeyPrefix + ":gen:" + kind + ":" + name
+}
+
+// generation returns the current generation of a route or tag; 0 when it
+// was never purged.
+func (rc *ResponseCacher) generation(ctx context.Context, kind, name string) int64 {
+	var gen int64
+	if !rc.lookup(ctx, rc.generationKey(kind, name), &gen) {
+		return 0
+	}
+	return gen
+}
+
+func (rc *ResponseCacher) generations(ctx context.Context, tags []string) map[string]int64 {
+	gens := make(map[string]int64, len(tags))
+	for _, tag := range tags {
+		gens[tag] = rc.generation(ctx, "tag", tag)
+	}
+	return gens
+}
+
+// fresh reports whether no tag was purged since the entry was stored.
+func (rc *ResponseCacher) fresh(ctx context.Context, tags map[string]int64) bool {
+	for tag, gen := range tags {
+		if rc.generation(ctx, "tag", tag) != gen {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup reads key into dest. Cache errors other than a miss are logged
+// and treated as a miss.
+func (rc *ResponseCacher) lookup(ctx context.Context, key string, dest any) bool {
+	err := rc.cache.Get(ctx, key, dest)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		slog.Warn("Response cache read failed", slog.String("key", key), log.Err(err))
+	}
+	return err == nil
+}
+
+func (rc *ResponseCacher) record(route, result string) {
+	if rc.cfg.Metrics != nil {
+		rc.cfg.Metrics.Requests.WithLabelValues(route, result).Inc()
+	}
+}
+
+func (rc *ResponseCacher) recordPurge(kind string) {
+	if rc.cfg.Metrics != nil {
+		rc.cfg.Metrics.Purges.WithLabelValues(kind).Inc()
+	}
+}
+
+func writeCachedResponse(c echo.Context, entry *cachedResponse) error {
+	h := c.Response().Header()
+	for k, vs := range entry.Header {
+		h[k] = append([]string(nil), vs...)
+	}
+	h.Set(HeaderXCache, "HIT")
+	c.Response().WriteHeader(entry.Status)
+	_, err := c.Response().Write(entry.Body)
+	return err
+}
+
+// clientNoCache reports whether the client asked to bypass caches.
+func clientNoCache(h http.Header) bool {
+	for _, v := range h.Values(echo.HeaderCacheControl) {
+		for _, directive := range strings.Split(v, ",") {
+			switch strings.ToLower(strings.TrimSpace(directive)) {
+			case "no-cache", "no-store":
+				return true
+			}
+		}
+	}
+	return strings.EqualFold(h.Get("Pragma"), "no-cache")
+}
+
+// isAuthenticated reports whether the request carries a user or
+// credentials.
+func isAuthenticated(c echo.Context) bool {
+	return requestUserID(c) != "" || c.Request().Header.Get(echo.HeaderAuthorization) != ""
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"github.com/NSObjects/go-kit/security"
+	"github.com/labstack/echo/v4"
+)
+
+// EmitSecurityEvent emits a security event for the current request with
+// its user, client IP, route and request ID. Use it for decisions made in
+// handlers, such as failed logins:
+//
+//	if !ok {
+//	    middleware.EmitSecurityEvent(c, security.KindLoginFailed, code.ErrPasswordIncorrect,
+//	        map[string]any{"username": req.Username})
+//	    return code.NewError(code.ErrPasswordIncorrect, "invalid credentials")
+//	}
+func EmitSecurityEvent(c echo.Context, kind security.Kind, errCode int, details map[string]any) {
+	security.Emit(security.Event{
+		Kind:      kind,
+		UserID:    requestUserID(c),
+		IP:        c.RealIP(),
+		Method:    c.Request().Method,
+		Route:     c.Path(),
+		RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+		Code:      errCode,
+		Details:   details,
+	})
+}
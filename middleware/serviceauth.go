@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/security"
+	"github.com/NSObjects/go-kit/serviceauth"
+	"github.com/labstack/echo/v4"
+)
+
+// Modes of ServiceAuthConfig.ClientCert.
+const (
+	ClientCertNone        = "none"
+	ClientCertAlternative = "alternative"
+	ClientCertRequired    = "required"
+)
+
+// ServiceSubjectPrefix prefixes calling services in the subjects of
+// CallerSubject, so Casbin policies tell them from users.
+const ServiceSubjectPrefix = "service:"
+
+// callerServiceKey is the echo.Context key of the calling service.
+const callerServiceKey = "caller_service"
+
+// ServiceAuthConfig holds service authentication middleware
+// configuration.
+type ServiceAuthConfig struct {
+	// Verifier validates service tokens; required unless ClientCert is
+	// ClientCertAlternative and every caller has a certificate.
+	Verifier *serviceauth.Verifier
+	// ClientCert is how a client certificate verified by the server (see
+	// config.TLSConfig.ClientCAFile) counts: ClientCertNone (default),
+	// ClientCertAlternative to a token, or ClientCertRequired in addition
+	// to it, naming the same service. Behind a proxy terminating TLS there
+	// is no client certificate.
+	ClientCert string
+	// CertServices maps client certificate SANs to service names; by
+	// default the service is the first SAN (see serviceauth.SANs).
+	CertServices map[string]string
+	// SkipPaths are route patterns that skip service authentication (see
+	// PathMatcher).
+	SkipPaths []string
+	// SkipMatcher overrides SkipPaths with a precompiled matcher.
+	SkipMatcher *PathMatcher
+}
+
+// NewServiceAuthConfig returns the ServiceAuthConfig of cfg with a
+// Verifier of cfg.
+func NewServiceAuthConfig(cfg config.ServiceAuthConfig) (ServiceAuthConfig, error) {
+	verifier, err := serviceauth.NewVerifier(cfg)
+	if err != nil {
+		return ServiceAuthConfig{}, err
+	}
+	return ServiceAuthConfig{Verifier: verifier, ClientCert: cfg.ClientCert, CertServices: cfg.CertServices}, nil
+}
+
+// ServiceAuth returns a middleware authenticating the calling service of
+// internal requests by the service token of serviceauth.Header and, per
+// cfg.ClientCert, the client certificate. It sets the calling service,
+// read with CallerService or serviceauth.CallerFromContext, apart from
+// the user of JWT, so both can be known:
+//
+//	internal := e.Group("/internal", middleware.ServiceAuth(sa))
+//	e.Use(middleware.Casbin(enforcer, &middleware.CasbinConfig{Enabled: true, UserGetter: middleware.CallerSubject}))
+//
+// Missing, expired or invalid tokens fail with ErrUnauthorized, callers
+// outside the allowed services with ErrPermissionDenied, both with a
+// "reason" detail of serviceauth (e.g. service_token_expired).
+func ServiceAuth(cfg ServiceAuthConfig) echo.MiddlewareFunc {
+	skip := skipMatcher(cfg.SkipMatcher, cfg.SkipPaths)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if skip.Match(c.Request().Method, c.Path()) {
+				return next(c)
+			}
+			service, err := authenticateService(c, cfg)
+			if err != nil {
+				reason, _ := reasonOf(err)
+				EmitSecurityEvent(c, serviceAuthFailureKind(reason), errors.GetCode(err),
+					map[string]any{"reason": reason})
+				return err
+			}
+			c.Set(callerServiceKey, service)
+			ctx := serviceauth.WithCaller(c.Request().Context(), service)
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// authenticateService returns the calling service of c.
+func authenticateService(c echo.Context, cfg ServiceAuthConfig) (string, error) {
+	certService, hasCert := "", false
+	if cfg.ClientCert == ClientCertAlternative || cfg.ClientCert == ClientCertRequired {
+		certService, hasCert = clientCertService(c, cfg.CertServices)
+		if !hasCert && cfg.ClientCert == ClientCertRequired {
+			return "", serviceauth.Error(nil, serviceauth.ReasonClientCert)
+		}
+	}
+
+	token := strings.TrimSpace(c.Request().Header.Get(serviceauth.Header))
+	if token == "" {
+		if hasCert && cfg.ClientCert == ClientCertAlternative {
+			if cfg.Verifier != nil && !cfg.Verifier.Allowed(certService) {
+				return "", serviceauth.Error(nil, serviceauth.ReasonNotAllowed)
+			}
+			return certService, nil
+		}
+		return "", serviceauth.Error(nil, serviceauth.ReasonMissing)
+	}
+	if cfg.Verifier == nil {
+		return "", serviceauth.Error(nil, serviceauth.ReasonInvalid)
+	}
+	claims, err := cfg.Verifier.Verify(token)
+	if err != nil {
+		return "", err
+	}
+	if hasCert && cfg.ClientCert == ClientCertRequired && certService != claims.Service() {
+		return "", serviceauth.Error(nil, serviceauth.ReasonClientCert)
+	}
+	return claims.Service(), nil
+}
+
+// clientCertService returns the service of the verified client
+// certificate of c.
+func clientCertService(c echo.Context, services map[string]string) (string, bool) {
+	state := c.Request().TLS
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+		return "", false
+	}
+	sans := serviceauth.SANs(state.PeerCertificates[0])
+	if len(services) == 0 {
+		if len(sans) == 0 {
+			return "", false
+		}
+		return sans[0], true
+	}
+	for _, san := range sans {
+		if service, ok := services[san]; ok {
+			return service, true
+		}
+	}
+	return "", false
+}
+
+// reasonOf returns the "reason" detail of a service authentication error.
+func reasonOf(err error) (string, bool) {
+	details, ok := errors.Details(err)
+	if !ok {
+		return "", false
+	}
+	m, _ := details.(map[string]any)
+	reason, ok := m["reason"].(string)
+	return reason, ok
+}
+
+// serviceAuthFailureKind classifies a service authentication failure.
+func serviceAuthFailureKind(reason string) security.Kind {
+	switch reason {
+	case serviceauth.ReasonMissing:
+		return security.KindTokenMissing
+	case serviceauth.ReasonExpired:
+		return security.KindTokenExpired
+	case serviceauth.ReasonNotAllowed:
+		return security.KindPermissionDenied
+	default:
+		return security.KindTokenInvalid
+	}
+}
+
+// CallerService returns the calling service of c, set by ServiceAuth.
+func CallerService(c echo.Context) (string, bool) {
+	s, ok := c.Get(callerServiceKey).(string)
+	return s, ok && s != ""
+}
+
+// CallerSubject is a CasbinConfig.UserGetter authorizing calling services
+// as ServiceSubjectPrefix plus their name, e.g. "service:billing", and
+// other requests as their user_id:
+//
+//	p, service:billing, /internal/orders/*, GET
+func CallerSubject(c echo.Context) (string, error) {
+	if service, ok := CallerService(c); ok {
+		return ServiceSubjectPrefix + service, nil
+	}
+	if user, ok := c.Get("user_id").(string); ok && user != "" {
+		return user, nil
+	}
+	return "", code.NewError(code.ErrUnauthorized, "no authenticated caller")
+}
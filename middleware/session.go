@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/security"
+	"github.com/NSObjects/go-kit/session"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultSessionCookie is the session cookie name when none is configured.
+const DefaultSessionCookie = "session_id"
+
+// SessionConfig holds session middleware configuration.
+type SessionConfig struct {
+	// Store loads and renews sessions; required.
+	Store *session.Store
+	// CookieName is the session cookie; default "session_id". A bearer
+	// Authorization header is used when the cookie is absent.
+	CookieName string
+	// RenewInterval is the minimum time between idle-timeout renewals of
+	// a session; default 1m.
+	RenewInterval time.Duration
+	// SkipPaths are route patterns that need no session (see PathMatcher).
+	SkipPaths []string
+	// SkipMatcher overrides SkipPaths with a precompiled matcher.
+	SkipMatcher *PathMatcher
+}
+
+// Session returns a middleware that loads the server-side session from
+// the session cookie or bearer header into the request context
+// (utils.GetSession) and sets user_id. Missing, expired and destroyed
+// sessions are rejected with ErrUnauthorized.
+//
+//	admin := e.Group("/admin", middleware.Session(middleware.SessionConfig{Store: store}))
+func Session(cfg SessionConfig) echo.MiddlewareFunc {
+	if cfg.CookieName == "" {
+		cfg.CookieName = DefaultSessionCookie
+	}
+	if cfg.RenewInterval <= 0 {
+		cfg.RenewInterval = time.Minute
+	}
+	skip := skipMatcher(cfg.SkipMatcher, cfg.SkipPaths)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if skip.Match(c.Request().Method, c.Path()) {
+				return next(c)
+			}
+
+			id := sessionID(c, cfg.CookieName)
+			if id == "" {
+				EmitSecurityEvent(c, security.KindTokenMissing, code.ErrUnauthorized, nil)
+				return code.NewError(code.ErrUnauthorized, "missing session")
+			}
+			ctx := c.Request().Context()
+			s, err := cfg.Store.Get(ctx, id)
+			if errors.Is(err, session.ErrNotFound) {
+				EmitSecurityEvent(c, security.KindSessionInvalid, code.ErrUnauthorized, nil)
+				return code.NewError(code.ErrUnauthorized, "session expired")
+			}
+			if err != nil {
+				return code.WrapInternalServerError(err, "load session")
+			}
+
+			// Sliding renewal, throttled to save a write per request.
+			if time.Since(s.LastSeen) >= cfg.RenewInterval {
+				if err := cfg.Store.Refresh(ctx, s); err != nil {
+					if errors.Is(err, session.ErrNotFound) {
+						return code.NewError(code.ErrUnauthorized, "session expired")
+					}
+					return code.WrapInternalServerError(err, "renew session")
+				}
+			}
+
+			c.Set("user_id", s.UserID)
+			c.Set(string(utils.KeySession), s)
+			c.SetRequest(c.Request().WithContext(utils.WithSession(ctx, s)))
+			return next(c)
+		}
+	}
+}
+
+// SetSessionCookie writes s as a secure, HTTP-only cookie expiring with
+// the session's absolute timeout.
+func SetSessionCookie(c echo.Context, name string, s *session.Session) {
+	if name == "" {
+		name = DefaultSessionCookie
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     name,
+		Value:    s.ID,
+		Path:     "/",
+		Expires:  s.ExpiresAt,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearSessionCookie removes the session cookie, e.g. on logout.
+func ClearSessionCookie(c echo.Context, name string) {
+	if name == "" {
+		name = DefaultSessionCookie
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// sessionID reads the session ID from the cookie, then the bearer header.
+func sessionID(c echo.Context, cookieName string) string {
+	if ck, err := c.Cookie(cookieName); err == nil && ck.Value != "" {
+		return ck.Value
+	}
+	auth := c.Request().Header.Get(echo.HeaderAuthorization)
+	if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
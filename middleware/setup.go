@@ -0,0 +1,433 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/pprof"
+	"slices"
+
+	kit "github.com/NSObjects/go-kit"
+	"github.com/NSObjects/go-kit/cache"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/db"
+	"github.com/NSObjects/go-kit/health"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/metrics"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/NSObjects/go-kit/validator"
+	"github.com/casbin/casbin/v2"
+	"github.com/labstack/echo/v4"
+	echomw "github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+)
+
+// SetupDeps are the dependencies used by Setup. Nil dependencies disable the
+// middleware or routes that need them.
+type SetupDeps struct {
+	Config         config.Config
+	Logger         log.Logger
+	Enforcer       *casbin.Enforcer
+	Metrics        *metrics.Metrics
+	HealthRegistry *health.Registry
+	// HealthOptions configure /health and /readyz, e.g. Cached to serve
+	// the results of HealthRegistry.Run unless ?deep=true.
+	HealthOptions health.HandlerOptions
+	// Permissions declares the Casbin object and action of admin routes
+	// (see PermissionRegistry).
+	Permissions *PermissionRegistry
+	// DecisionMetrics records the Casbin decision cache enabled by
+	// Config.Casbin.DecisionCache (optional).
+	DecisionMetrics *metrics.CasbinDecisionMetrics
+	// JWTKeys is the reloaded secret of JWT; default loaded and watched
+	// from Config.JWT.SecretFile, when set, for the life of the process.
+	JWTKeys *JWTKeyFile
+	// DBStatsMetrics records the per-route database statistics enabled by
+	// Config.Database.RequestStats (optional).
+	DBStatsMetrics *metrics.DBStatsMetrics
+	// Manager and Cache back the request-scoped DB and Cache of Deps.
+	Manager *db.Manager
+	Cache   cache.Cache
+	// TrustedProxies resolves client IPs; default built from
+	// Config.System.TrustedProxies.
+	TrustedProxies *utils.TrustedProxies
+	// CORS answers CORS requests, e.g. with an AllowOriginResolver and
+	// kept in sync with WatchCORS; default built from Config.CORS when it
+	// has origins or groups.
+	CORS *CORSPolicy
+	// Clock and Rand time and sample the access log and generate the
+	// request IDs, e.g. a utils.FakeClock and a seeded utils.Rand for
+	// reproducible tests; default the real ones.
+	Clock utils.Clock
+	Rand  utils.Rand
+	// Options insert middleware into the chain and replace built-in ones
+	// (optional).
+	Options *SetupOptions
+
+	// APIPrefix is the prefix of the route groups; default "/api".
+	APIPrefix string
+	// AdminPrefix is the admin group prefix below APIPrefix; default "/admin".
+	AdminPrefix string
+}
+
+// RouteGroups are the pre-made route groups returned by Setup.
+type RouteGroups struct {
+	// Public routes require no authentication.
+	Public *echo.Group
+	// Authenticated routes require a valid JWT (when JWT is enabled).
+	Authenticated *echo.Group
+	// Admin routes require a valid JWT and Casbin authorization (when enabled).
+	Admin *echo.Group
+	// Installed records what Setup installed, for kit.Describe.
+	Installed kit.SetupRecord
+	// Audit records the routes of e and their middleware, for
+	// kit.VerifyRoutes.
+	Audit *kit.RouteAudit
+}
+
+// Setup installs the canonical middleware stack on e, driven by the config
+// sections:
+//
+//	Recovery → Tracing → RequestID → BodyCache → DBStats → AccessLog → CORS → Metrics
+//
+// Recovery verbosity, debug details in error responses, access-log
+// sampling, runtime metrics and the /debug/pprof routes follow the
+// environment profile (see kit.Resolve).
+//
+// deps.Options insert middleware at named points of that chain and
+// replace the recovery, request ID and access log middleware in place
+// (see SetupOptions and InsertionPoint).
+//
+// JWT and Casbin are applied per group (see RouteGroups) so public routes
+// never pay for them. Every request gets a RequestDeps container (see Deps)
+// and a logger with its request_id in the context (see log.FromContext).
+// It also applies the time config of cfg.System (see utils.SetTimeConfig),
+// registers the error handling (see RegisterErrorHandling), sets
+// e.Validator, e.Binder (checking JSON bodies strictly per cfg.Bind) and
+// e.IPExtractor (so c.RealIP() honors the trusted proxies everywhere) and
+// registers GET /health, /livez, /readyz, /startupz and /metrics when the
+// corresponding dependencies are provided.
+// RouteGroups.Installed records what was installed.
+//
+// Setup installs the route audit of e (see kit.AuditRoutes) before
+// registering any route and names the middleware it installs, globally and
+// as "jwt" and "casbin" on the groups when enabled, so kit.VerifyRoutes can
+// check that every route runs the middleware it should.
+func Setup(e *echo.Echo, deps SetupDeps) RouteGroups {
+	cfg := deps.Config
+	profile := kit.Resolve(cfg)
+
+	RegisterErrorHandling(e, ErrorHandlerConfig{Debug: profile.DebugErrors})
+	e.Validator = validator.New()
+	e.Binder = validator.NewBinder(validator.BinderOptions{
+		StrictJSON: cfg.Bind.StrictJSON,
+		JSONLimits: validator.JSONLimits{
+			MaxDepth:        cfg.Bind.MaxDepth,
+			MaxStringLength: cfg.Bind.MaxStringLength,
+			MaxTokens:       cfg.Bind.MaxTokens,
+			Timeout:         cfg.Bind.DecodeTimeout,
+		},
+	})
+	utils.SetTracerName(cfg.System.Name)
+	if err := utils.SetTimeConfig(utils.TimeConfig{Location: cfg.System.TimeZone, Format: cfg.System.TimeFormat}); err != nil {
+		slog.Error("Invalid time config, using UTC and RFC 3339", log.Err(err))
+	}
+
+	proxies := deps.TrustedProxies
+	if proxies == nil {
+		var err error
+		if proxies, err = utils.NewTrustedProxies(cfg.System.TrustedProxies); err != nil {
+			slog.Error("Invalid trusted proxies, forwarding headers ignored", log.Err(err))
+		}
+	}
+	e.IPExtractor = proxies.IPExtractor()
+
+	audit := kit.AuditRoutes(e)
+	var rec kit.SetupRecord
+	use := func(name string, options map[string]any, mw echo.MiddlewareFunc) {
+		e.Use(mw)
+		audit.Global(name)
+		rec.Middleware = append(rec.Middleware, kit.Middleware{Name: name, Scope: "global", Options: options})
+	}
+	opts := deps.Options
+	if opts == nil {
+		opts = &SetupOptions{}
+	}
+	// builtin returns replacement, when set, instead of mw, and records it.
+	builtin := func(options map[string]any, mw, replacement echo.MiddlewareFunc) echo.MiddlewareFunc {
+		if replacement == nil {
+			return mw
+		}
+		if options == nil {
+			options = map[string]any{}
+		}
+		options["replaced"] = true
+		return replacement
+	}
+	insert := func(point InsertionPoint) {
+		for _, m := range opts.At(point) {
+			audit.Depends(m.Name, m.Requires...)
+			use(m.Name, insertedOptions(point, m), m.Middleware)
+		}
+	}
+
+	insert(BeforeAll)
+	recoveryOptions := map[string]any{"verbose": profile.VerboseRecovery}
+	use("recovery", recoveryOptions,
+		builtin(recoveryOptions, RecoveryWithConfig(RecoveryConfig{Verbose: profile.VerboseRecovery}), opts.Recovery))
+	if cfg.Otel.Enabled {
+		use("tracing", nil, Tracing(cfg))
+	}
+	requestIDOptions := map[string]any{}
+	use("request_id", requestIDOptions, builtin(requestIDOptions, requestID(deps), opts.RequestID))
+	insert(AfterRequestID)
+	use("body_cache", map[string]any{"max_bytes": DefaultBodyCacheSize}, BodyCache(DefaultBodyCacheSize))
+	use("request_scope", nil, RequestScope(RequestScopeConfig{Manager: deps.Manager, Cache: deps.Cache, Logger: deps.Logger}))
+	if rs := cfg.Database.RequestStats; rs.Enabled {
+		use("db_stats", map[string]any{"repeat_threshold": rs.RepeatThreshold, "metrics": deps.DBStatsMetrics != nil},
+			DBStats(DBStatsConfig{RepeatThreshold: rs.RepeatThreshold, Metrics: deps.DBStatsMetrics}))
+	}
+	accessLogOptions := map[string]any{"sample_rate": profile.AccessLogSampleRate}
+	use("access_log", accessLogOptions,
+		builtin(accessLogOptions, AccessLogSampled(deps.Logger, profile.AccessLogSampleRate, AccessLogOptions{Clock: deps.Clock, Rand: deps.Rand}), opts.AccessLog))
+	cors := deps.CORS
+	if cors == nil && (len(cfg.CORS.AllowOrigins) > 0 || len(cfg.CORS.Groups) > 0) {
+		cors = NewCORSPolicy(cfg.CORS)
+	}
+	if cors != nil {
+		use("cors", map[string]any{
+			"allow_origins": cfg.CORS.AllowOrigins,
+			"groups":        slices.Sorted(maps.Keys(cfg.CORS.Groups)),
+			"resolver":      cors.opts.AllowOriginResolver != nil || len(cors.opts.GroupResolvers) > 0,
+		}, cors.Middleware())
+	}
+	if deps.Metrics != nil {
+		deps.Metrics.Exemplars = cfg.Otel.Enabled
+		use("metrics", map[string]any{"runtime_metrics": profile.RuntimeMetrics}, deps.Metrics.Middleware())
+		e.GET("/metrics", metrics.Handler())
+		rec.MetricsPath = "/metrics"
+		metrics.SetRuntimeMetrics(profile.RuntimeMetrics)
+	}
+	if profile.DebugRoutes {
+		registerPprof(e)
+		rec.DebugPaths = append(rec.DebugPaths, "/debug/pprof/")
+	}
+	if deps.HealthRegistry != nil {
+		e.GET("/health", health.Handler(deps.HealthRegistry, deps.HealthOptions))
+		e.GET("/livez", health.LivenessHandler())
+		e.GET("/readyz", health.ReadinessHandler(deps.HealthRegistry, deps.HealthOptions))
+		e.GET("/startupz", health.StartupHandler(deps.HealthRegistry))
+		rec.HealthPaths = append(rec.HealthPaths, "/health", "/livez", "/readyz", "/startupz")
+	}
+
+	prefix := deps.APIPrefix
+	if prefix == "" {
+		prefix = "/api"
+	}
+	adminPrefix := deps.AdminPrefix
+	if adminPrefix == "" {
+		adminPrefix = "/admin"
+	}
+
+	jwtCfg := CreateJWTConfig(cfg.JWT.Secret, cfg.JWT.SkipPaths, cfg.JWT.Enabled)
+	jwtCfg.SkipPatterns = cfg.JWT.SkipPatterns
+	jwtCfg.KeyFile = deps.JWTKeys
+	if jwtCfg.KeyFile == nil && cfg.JWT.Enabled && cfg.JWT.SecretFile != "" {
+		jwtCfg.KeyFile = watchJWTKeyFile(cfg.JWT)
+	}
+	jwtMW := JWT(jwtCfg)
+	casbinCfg := CreateCasbinConfig(cfg.Casbin.Enabled, cfg.Casbin.SkipPaths, cfg.Casbin.AdminUsers)
+	casbinCfg.SkipPatterns = cfg.Casbin.SkipPatterns
+	casbinCfg.Permissions = deps.Permissions
+	casbinCfg.Unannotated = cfg.Casbin.Unannotated
+	if dc := cfg.Casbin.DecisionCache; dc.Enabled && cfg.Casbin.Enabled && deps.Enforcer != nil {
+		casbinCfg.Decisions = NewDecisionCache(deps.Enforcer, DecisionCacheOptions{Size: dc.Size, TTL: dc.TTL, Metrics: deps.DecisionMetrics})
+	}
+	casbinMW := Casbin(deps.Enforcer, casbinCfg)
+	if cfg.JWT.Enabled {
+		audit.Name("jwt", jwtMW)
+		rec.Middleware = append(rec.Middleware,
+			kit.Middleware{Name: "jwt", Scope: "authenticated", Options: map[string]any{"skip_paths": cfg.JWT.SkipPaths, "skip_patterns": cfg.JWT.SkipPatterns}},
+			kit.Middleware{Name: "jwt", Scope: "admin", Options: map[string]any{"skip_paths": cfg.JWT.SkipPaths, "skip_patterns": cfg.JWT.SkipPatterns}})
+	}
+	if cfg.Casbin.Enabled && deps.Enforcer != nil {
+		audit.Name("casbin", casbinMW)
+		rec.Middleware = append(rec.Middleware,
+			kit.Middleware{Name: "casbin", Scope: "admin", Options: map[string]any{
+				"skip_paths":    cfg.Casbin.SkipPaths,
+				"skip_patterns": cfg.Casbin.SkipPatterns,
+				"unannotated":   cfg.Casbin.Unannotated,
+				"annotated":     deps.Permissions != nil,
+				"decisions":     casbinCfg.Decisions != nil,
+			}})
+	}
+
+	// group returns the group middleware of scope: base, then the
+	// middleware inserted after auth when authenticated, then before the
+	// handler.
+	group := func(scope string, authenticated bool, base ...echo.MiddlewareFunc) []echo.MiddlewareFunc {
+		points := []InsertionPoint{BeforeHandler}
+		if authenticated {
+			points = []InsertionPoint{AfterAuth, BeforeHandler}
+		}
+		for _, point := range points {
+			for _, m := range opts.At(point) {
+				audit.Name(m.Name, m.Middleware)
+				audit.Depends(m.Name, m.Requires...)
+				rec.Middleware = append(rec.Middleware, kit.Middleware{Name: m.Name, Scope: scope, Options: insertedOptions(point, m)})
+				base = append(base, m.Middleware)
+			}
+		}
+		return base
+	}
+	public := group("public", false)
+	authenticated := group("authenticated", true, jwtMW)
+	admin := group("admin", true, jwtMW, casbinMW)
+
+	return RouteGroups{
+		Public:        e.Group(prefix, public...),
+		Authenticated: e.Group(prefix, authenticated...),
+		Admin:         e.Group(prefix+adminPrefix, admin...),
+		Installed:     rec,
+		Audit:         audit,
+	}
+}
+
+// insertedOptions are the recorded options of a middleware inserted at
+// point.
+func insertedOptions(point InsertionPoint, m UserMiddleware) map[string]any {
+	options := map[string]any{"point": string(point)}
+	if len(m.Requires) > 0 {
+		options["requires"] = m.Requires
+	}
+	return options
+}
+
+// watchJWTKeyFile loads and watches the secret file of cfg for the life
+// of the process. Until the file holds a secret, JWT rejects every token.
+func watchJWTKeyFile(cfg config.JWTConfig) *JWTKeyFile {
+	keys := newJWTKeyFile(cfg.SecretFile, JWTKeyFileOptions{Overlap: cfg.KeyOverlap})
+	if err := keys.Reload(); err != nil {
+		slog.Error("Invalid JWT secret file, rejecting all tokens", slog.String("path", cfg.SecretFile), log.Err(err))
+	}
+	if err := keys.Watch(context.Background()); err != nil {
+		slog.Error("JWT secret file watch failed, secret rotations need a restart", slog.String("path", cfg.SecretFile), log.Err(err))
+	}
+	return keys
+}
+
+// Tracing returns the OpenTelemetry echo middleware using the global tracer
+// provider (see observability.Setup). Health and metrics endpoints are not
+// traced.
+func Tracing(cfg config.Config) echo.MiddlewareFunc {
+	service := cfg.Otel.ServiceName
+	if service == "" {
+		service = cfg.System.Name
+	}
+	return otelecho.Middleware(service, otelecho.WithSkipper(func(c echo.Context) bool {
+		switch c.Path() {
+		case "/health", "/livez", "/readyz", "/startupz", "/metrics":
+			return true
+		}
+		return false
+	}))
+}
+
+// registerPprof mounts the net/http/pprof handlers under /debug/pprof.
+func registerPprof(e *echo.Echo) {
+	g := e.Group("/debug/pprof")
+	g.GET("/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+	g.GET("/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)))
+	g.GET("/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	g.GET("/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)))
+	g.GET("/*", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
+}
+
+// RequestID returns a middleware that assigns a ULID request ID (or keeps the
+// incoming X-Request-ID) and exposes it in the response header.
+func RequestID() echo.MiddlewareFunc {
+	return echomw.RequestIDWithConfig(echomw.RequestIDConfig{
+		Generator: utils.NewULID,
+	})
+}
+
+// RequestIDWith is RequestID generating the IDs with gen, e.g. one of
+// utils.NewIDGeneratorWithEntropy for reproducible IDs.
+func RequestIDWith(gen *utils.IDGenerator) echo.MiddlewareFunc {
+	return echomw.RequestIDWithConfig(echomw.RequestIDConfig{
+		Generator: gen.ULID,
+	})
+}
+
+// requestID is the request ID middleware of Setup, generating the IDs
+// with the Clock and Rand of deps when set.
+func requestID(deps SetupDeps) echo.MiddlewareFunc {
+	if deps.Clock == nil && deps.Rand == nil {
+		return RequestID()
+	}
+	var entropy io.Reader
+	if deps.Rand != nil {
+		entropy = deps.Rand
+	}
+	return RequestIDWith(utils.NewIDGeneratorWithEntropy(deps.Clock, entropy))
+}
+
+// AccessLogOptions configure AccessLogSampled.
+type AccessLogOptions struct {
+	// Clock measures the latency; default utils.RealClock.
+	Clock utils.Clock
+	// Rand samples the requests; default utils.RealRand.
+	Rand utils.Rand
+}
+
+// AccessLog returns a request logging middleware writing to logger.
+// A nil logger falls back to RequestLogger (slog default logger).
+func AccessLog(logger log.Logger) echo.MiddlewareFunc {
+	return AccessLogSampled(logger, 1)
+}
+
+// AccessLogSampled is AccessLog logging only a fraction rate (0..1) of the
+// successful requests; failed requests (errors and statuses >= 400) are
+// always logged. A nil logger falls back to RequestLogger, unsampled.
+// Behind DBStats, the line also carries the database statistics of the
+// request.
+func AccessLogSampled(logger log.Logger, rate float64, opts ...AccessLogOptions) echo.MiddlewareFunc {
+	if logger == nil {
+		return RequestLogger()
+	}
+	var o AccessLogOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Clock == nil {
+		o.Clock = utils.RealClock{}
+	}
+	if o.Rand == nil {
+		o.Rand = utils.RealRand{}
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := o.Clock.Now()
+
+			err := next(c)
+
+			if err == nil && c.Response().Status < http.StatusBadRequest && rate < 1 && o.Rand.Float64() >= rate {
+				return nil
+			}
+
+			attrs := []slog.Attr{
+				slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+				slog.String("method", c.Request().Method),
+				slog.String("uri", c.Request().RequestURI),
+				slog.String("client_ip", c.RealIP()),
+				slog.Int("status", c.Response().Status),
+				slog.Duration("latency", o.Clock.Now().Sub(start)),
+			}
+			logger.Info("Request", append(attrs, dbStatsAttrs(c)...)...)
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/metrics"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Defaults of AdaptiveShedConfig.
+const (
+	DefaultShedWindow       = 10 * time.Second
+	DefaultShedBuckets      = 10
+	DefaultShedThreshold    = 0.5
+	DefaultShedMinRequests  = 20
+	DefaultShedStep         = 0.1
+	DefaultShedRecoveryStep = 0.05
+	DefaultShedMaxFraction  = 0.9
+)
+
+// DefaultShedSkipPaths are the routes never shed when SkipPaths is unset:
+// probes, metrics, profiling and the admin group of Setup.
+var DefaultShedSkipPaths = []string{
+	"/health", "/livez", "/readyz", "/startupz", "/metrics", "/debug/pprof/*", "/api/admin/*",
+}
+
+// AdaptiveShedConfig holds adaptive load shedding configuration.
+type AdaptiveShedConfig struct {
+	// Window is the span over which the server-error rate of a route is
+	// measured; default DefaultShedWindow.
+	Window time.Duration
+	// Buckets is the number of slices of the window; default
+	// DefaultShedBuckets. The window slides by Window/Buckets.
+	Buckets int
+	// Threshold is the server-error rate (0 to 1) above which a route
+	// sheds; default DefaultShedThreshold.
+	Threshold float64
+	// MinRequests is the number of requests in the window below which the
+	// rate is not trusted; default DefaultShedMinRequests.
+	MinRequests int
+	// Step is added to the shed fraction every Window/Buckets while the
+	// rate exceeds Threshold; default DefaultShedStep.
+	Step float64
+	// RecoveryStep is removed from the shed fraction by every successful
+	// request let through once the rate is back under Threshold; default
+	// DefaultShedRecoveryStep.
+	RecoveryStep float64
+	// MaxFraction caps the shed fraction, so that probes keep reaching
+	// the handler; default DefaultShedMaxFraction.
+	MaxFraction float64
+	// RetryAfter is the Retry-After of shed requests; default
+	// Window/Buckets, at least one second.
+	RetryAfter time.Duration
+	// SkipPaths are route patterns never shed (see PathMatcher); default
+	// DefaultShedSkipPaths.
+	SkipPaths []string
+	// SkipMatcher overrides SkipPaths with a precompiled matcher.
+	SkipMatcher *PathMatcher
+	// Skipper skips shedding and tracking for matching requests.
+	Skipper func(c echo.Context) bool
+	// Metrics records the shed fraction and shed requests per route.
+	Metrics *metrics.ShedMetrics
+	// Clock is the time source; default utils.RealClock.
+	Clock utils.Clock
+}
+
+func (cfg AdaptiveShedConfig) withDefaults() AdaptiveShedConfig {
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultShedWindow
+	}
+	if cfg.Buckets <= 0 {
+		cfg.Buckets = DefaultShedBuckets
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = DefaultShedThreshold
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = DefaultShedMinRequests
+	}
+	if cfg.Step <= 0 {
+		cfg.Step = DefaultShedStep
+	}
+	if cfg.RecoveryStep <= 0 {
+		cfg.RecoveryStep = DefaultShedRecoveryStep
+	}
+	if cfg.MaxFraction <= 0 || cfg.MaxFraction > 1 {
+		cfg.MaxFraction = DefaultShedMaxFraction
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = max(cfg.Window/time.Duration(cfg.Buckets), time.Second)
+	}
+	if cfg.SkipMatcher == nil {
+		if cfg.SkipPaths == nil {
+			cfg.SkipPaths = DefaultShedSkipPaths
+		}
+		cfg.SkipMatcher = MustPathMatcher(cfg.SkipPaths...)
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = utils.RealClock{}
+	}
+	return cfg
+}
+
+// AdaptiveShed returns a middleware that fails requests fast while their
+// route keeps failing, instead of letting each of them wait for a broken
+// dependency. It tracks the rate of 5xx responses per route template over
+// a sliding window; once the rate exceeds the threshold, a fraction of the
+// requests, growing while the errors go on, is answered with a coded
+// ErrServiceUnavailable and Retry-After without reaching the handler. The
+// requests still let through probe the route, and each success lowers the
+// fraction once the rate is back under the threshold.
+//
+// State is kept per instance, in memory. Requests that matched no route
+// are not tracked.
+//
+//	e.Use(middleware.AdaptiveShed(middleware.AdaptiveShedConfig{
+//	    Threshold: 0.5,
+//	    Metrics:   metrics.NewShedMetrics("app"),
+//	}))
+func AdaptiveShed(cfg AdaptiveShedConfig) echo.MiddlewareFunc {
+	cfg = cfg.withDefaults()
+	width := cfg.Window / time.Duration(cfg.Buckets)
+	retryAfter := strconv.Itoa(int(math.Ceil(cfg.RetryAfter.Seconds())))
+
+	var mu sync.Mutex
+	routes := make(map[string]*shedRoute)
+	route := func(key string) *shedRoute {
+		mu.Lock()
+		defer mu.Unlock()
+		r, ok := routes[key]
+		if !ok {
+			r = &shedRoute{key: key, buckets: make([]shedBucket, cfg.Buckets)}
+			if cfg.Metrics != nil {
+				r.fractionGauge = cfg.Metrics.Fraction.WithLabelValues(key)
+				r.shedCounter = cfg.Metrics.Shed.WithLabelValues(key)
+			}
+			routes[key] = r
+		}
+		return r
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cfg.Skipper != nil && cfg.Skipper(c) {
+				return next(c)
+			}
+			key := c.Path()
+			if key == "" || cfg.SkipMatcher.Match(c.Request().Method, key) {
+				return next(c)
+			}
+
+			r := route(key)
+			if r.admit() {
+				err := next(c)
+				slot := cfg.Clock.Now().UnixNano() / int64(width)
+				r.record(&cfg, slot, responseStatus(c, err) >= http.StatusInternalServerError)
+				return err
+			}
+			if r.shedCounter != nil {
+				r.shedCounter.Inc()
+			}
+			c.Response().Header().Set("Retry-After", retryAfter)
+			return code.NewError(code.ErrServiceUnavailable, "service overloaded, retry later")
+		}
+	}
+}
+
+// shedRoute is the error window and shed fraction of a route.
+type shedRoute struct {
+	key string
+
+	mu       sync.Mutex
+	buckets  []shedBucket
+	fraction float64
+	credit   float64 // accumulated fraction; a request is shed per whole unit
+	raisedAt int64   // slot of the last raise
+
+	fractionGauge prometheus.Gauge
+	shedCounter   prometheus.Counter
+}
+
+// shedBucket counts the requests of one slot of the window.
+type shedBucket struct {
+	slot   int64
+	total  int
+	errors int
+}
+
+// admit reports whether a request is let through. Shedding is spread
+// evenly: with a fraction of 0.3, three requests in ten are shed.
+func (r *shedRoute) admit() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fraction == 0 {
+		return true
+	}
+	r.credit += r.fraction
+	if r.credit >= 1 {
+		r.credit--
+		return false
+	}
+	return true
+}
+
+// record counts the outcome of an admitted request in slot and adjusts
+// the shed fraction.
+func (r *shedRoute) record(cfg *AdaptiveShedConfig, slot int64, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := &r.buckets[slot%int64(len(r.buckets))]
+	if b.slot != slot {
+		*b = shedBucket{slot: slot}
+	}
+	b.total++
+	if failed {
+		b.errors++
+	}
+
+	var total, errs int
+	for i := range r.buckets {
+		if r.buckets[i].slot > slot-int64(len(r.buckets)) {
+			total += r.buckets[i].total
+			errs += r.buckets[i].errors
+		}
+	}
+	rate := float64(errs) / float64(total)
+	before := r.fraction
+	switch {
+	case total >= cfg.MinRequests && rate > cfg.Threshold:
+		if r.fraction == 0 || slot > r.raisedAt {
+			r.fraction = min(r.fraction+cfg.Step, cfg.MaxFraction)
+			r.raisedAt = slot
+		}
+	case r.fraction > 0 && !failed:
+		r.fraction = max(r.fraction-cfg.RecoveryStep, 0)
+		if r.fraction < 1e-9 {
+			r.fraction = 0
+		}
+	}
+	if r.fraction == before {
+		return
+	}
+	if r.fractionGauge != nil {
+		r.fractionGauge.Set(r.fraction)
+	}
+	switch {
+	case before == 0:
+		slog.Warn("Load shedding started",
+			slog.String("route", r.key),
+			slog.Float64("error_rate", rate),
+			slog.Int("requests", total),
+		)
+	case r.fraction == 0:
+		r.credit = 0
+		slog.Info("Load shedding stopped", slog.String("route", r.key))
+	}
+}
@@ -0,0 +1,286 @@
+// Package notify sends email and SMS notifications through a failover
+// chain of providers (SMTP servers, HTTP gateways), with per-locale
+// templates, retries, a rate limit per recipient and metrics.
+//
+//	templates, err := notify.LoadTemplates(os.DirFS("templates/notify"))
+//	n, err := notify.NewFromConfig(ctx, cfg.Notify, secrets, notify.Options{
+//	    Templates: templates,
+//	    Cache:     redisCache,
+//	    Metrics:   metrics.NewNotifyMetrics("app"),
+//	})
+//
+//	err = n.Send(ctx, notify.Message{
+//	    Channel:  notify.ChannelEmail,
+//	    To:       []string{user.Email},
+//	    Template: "welcome",
+//	    Data:     map[string]any{"Name": user.Name},
+//	})
+//
+// Templates are rendered before any provider is contacted, so a broken
+// template never sends a partial message.
+package notify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/cache"
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/metrics"
+	"github.com/NSObjects/go-kit/utils"
+)
+
+// Channels of Message.Channel.
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+// DefaultRateWindow is the default of Options.RateWindow.
+const DefaultRateWindow = time.Hour
+
+// Message is a notification.
+type Message struct {
+	// Channel selects the providers, e.g. ChannelEmail.
+	Channel string `json:"channel"`
+	// To are the recipients: email addresses or phone numbers.
+	To []string `json:"to"`
+	// From overrides the default sender of the provider.
+	From string `json:"from,omitempty"`
+
+	Subject string `json:"subject,omitempty"`
+	Text    string `json:"text,omitempty"`
+	HTML    string `json:"html,omitempty"`
+
+	// Template, when set, renders Subject, Text and HTML with Data (see
+	// Templates).
+	Template string `json:"-"`
+	Data     any    `json:"-"`
+	// Locale selects the template locale over the locale of the context
+	// (see utils.WithLocale), e.g. the recipient's for background sends.
+	Locale string `json:"-"`
+
+	// Metadata is passed to providers that support it (WebhookSender).
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Sender sends messages. Senders mark errors that retrying cannot fix
+// (rejected recipients, bad credentials) with utils.Permanent.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Provider is a Sender in a Notifier's failover chain.
+type Provider struct {
+	// Name labels logs and metrics.
+	Name   string
+	Sender Sender
+	// Channels are the channels the provider handles; empty handles all.
+	Channels []string
+	// Retry is the retry policy of the provider before failing over; zero
+	// values use utils.DefaultRetryPolicy.
+	Retry utils.RetryPolicy
+}
+
+// Options configures a Notifier.
+type Options struct {
+	// Templates renders messages with a Template (optional).
+	Templates *Templates
+	// Cache counts messages per recipient for RateLimit; it must
+	// implement cache.Counter (RedisCache does).
+	Cache cache.Cache
+	// RateLimit is the number of messages per recipient and channel per
+	// RateWindow; 0 is unlimited.
+	RateLimit int
+	// RateWindow is the rate limit window; default DefaultRateWindow.
+	RateWindow time.Duration
+	// Metrics records sends per provider (optional).
+	Metrics *metrics.NotifyMetrics
+	// Clock provides the current time; default utils.RealClock.
+	Clock utils.Clock
+}
+
+// Notifier sends messages through the first of its providers that accepts
+// them. It implements Sender.
+type Notifier struct {
+	providers []Provider
+	opts      Options
+}
+
+// New creates a Notifier trying providers in order.
+func New(providers []Provider, opts Options) *Notifier {
+	if opts.RateWindow <= 0 {
+		opts.RateWindow = DefaultRateWindow
+	}
+	if opts.Clock == nil {
+		opts.Clock = utils.RealClock{}
+	}
+	return &Notifier{providers: providers, opts: opts}
+}
+
+// NewFromConfig creates a Notifier with the providers of cfg, resolving
+// secret references with secrets (which may be nil). Options.RateLimit
+// and RateWindow default to those of cfg.
+func NewFromConfig(ctx context.Context, cfg config.NotifyConfig, secrets *config.SecretResolver, opts Options) (*Notifier, error) {
+	providers := make([]Provider, 0, len(cfg.Providers))
+	for _, p := range cfg.Providers {
+		var sender Sender
+		switch p.Type {
+		case "smtp":
+			smtpCfg := p.SMTP
+			password, err := secrets.Resolve(ctx, smtpCfg.Password)
+			if err != nil {
+				return nil, err
+			}
+			smtpCfg.Password = password
+			sender = NewSMTPSender(smtpCfg)
+		case "webhook":
+			sender = NewWebhookSender(p.Name, p.Webhook)
+		default:
+			return nil, fmt.Errorf("notify: unsupported provider type: %s", p.Type)
+		}
+		retry := utils.DefaultRetryPolicy()
+		if p.MaxAttempts > 0 {
+			retry.MaxAttempts = p.MaxAttempts
+		}
+		if p.InitialBackoff > 0 {
+			retry.InitialBackoff = p.InitialBackoff
+		}
+		providers = append(providers, Provider{Name: p.Name, Sender: sender, Channels: p.Channels, Retry: retry})
+	}
+	if opts.RateLimit == 0 {
+		opts.RateLimit = cfg.RateLimit
+	}
+	if opts.RateWindow == 0 {
+		opts.RateWindow = cfg.RateWindow
+	}
+	return New(providers, opts), nil
+}
+
+// Send renders msg and sends it through the first provider of its channel
+// that accepts it, retrying each as its policy allows. Failures are coded:
+// code.ErrBadRequest for messages without channel or recipients,
+// code.ErrInternalServer for template failures (naming the missing
+// variable) and channels without providers, code.ErrTooManyRequests over
+// the rate limit, and code.ErrExternalService when every provider failed.
+func (n *Notifier) Send(ctx context.Context, msg Message) error {
+	if msg.Channel == "" || len(msg.To) == 0 {
+		return code.NewError(code.ErrBadRequest, "notify: message needs a channel and recipients")
+	}
+	if msg.Template != "" {
+		if n.opts.Templates == nil {
+			return code.NewErrorf(code.ErrInternalServer, "notify: template %s without templates", msg.Template)
+		}
+		if err := n.opts.Templates.Render(ctx, &msg); err != nil {
+			return err
+		}
+	}
+
+	providers := slices.DeleteFunc(slices.Clone(n.providers), func(p Provider) bool {
+		return len(p.Channels) > 0 && !slices.Contains(p.Channels, msg.Channel)
+	})
+	if len(providers) == 0 {
+		return code.NewErrorf(code.ErrInternalServer, "notify: no provider for channel %s", msg.Channel)
+	}
+	if err := n.limit(ctx, msg); err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, p := range providers {
+		err := n.send(ctx, p, msg)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+		if ctx.Err() != nil {
+			break
+		}
+		if i < len(providers)-1 {
+			slog.WarnContext(ctx, "Notification provider failed, failing over",
+				slog.String("provider", p.Name),
+				slog.String("next", providers[i+1].Name),
+				slog.String("channel", msg.Channel),
+				log.Err(err),
+			)
+		}
+	}
+	return code.WrapErrorf(errors.Join(errs...), code.ErrExternalService,
+		"notify: %s not sent, %d of %d providers failed", msg.Channel, len(errs), len(providers))
+}
+
+// send sends msg through p with its retry policy.
+func (n *Notifier) send(ctx context.Context, p Provider, msg Message) error {
+	policy := p.Retry
+	policy.RetryIf = func(err error) bool {
+		return !errors.Is(err, context.Canceled)
+	}
+	return utils.Retry(ctx, policy, func(ctx context.Context) error {
+		start := time.Now()
+		err := p.Sender.Send(ctx, msg)
+		if n.opts.Metrics != nil {
+			status := "ok"
+			if err != nil {
+				status = "error"
+			}
+			n.opts.Metrics.Observe(p.Name, msg.Channel, status, time.Since(start))
+		}
+		return err
+	})
+}
+
+// limit counts msg against the rate limit of its recipients. Counter
+// failures are logged and let the message through.
+func (n *Notifier) limit(ctx context.Context, msg Message) error {
+	if n.opts.RateLimit <= 0 || n.opts.Cache == nil {
+		return nil
+	}
+	counter, ok := n.opts.Cache.(cache.Counter)
+	if !ok {
+		slog.WarnContext(ctx, "Notification rate limit not applied", log.Err(cache.ErrCounterUnsupported))
+		return nil
+	}
+	now := n.opts.Clock.Now()
+	window := now.Truncate(n.opts.RateWindow)
+	ttl := window.Add(n.opts.RateWindow).Sub(now)
+
+	var counted []string
+	for _, to := range msg.To {
+		key := rateKey(msg.Channel, to, window)
+		count, err := counter.IncrBy(ctx, key, 1, ttl)
+		if err != nil {
+			slog.WarnContext(ctx, "Notification rate limit not applied", log.Err(err))
+			continue
+		}
+		counted = append(counted, key)
+		if count > int64(n.opts.RateLimit) {
+			// Give the counts back, as the message is not sent.
+			for _, k := range counted {
+				_, _ = counter.IncrBy(ctx, k, -1, 0)
+			}
+			if n.opts.Metrics != nil {
+				n.opts.Metrics.RateLimited.WithLabelValues(msg.Channel).Inc()
+			}
+			return code.NewErrorf(code.ErrTooManyRequests,
+				"notify: more than %d %s messages per %s to a recipient", n.opts.RateLimit, msg.Channel, n.opts.RateWindow)
+		}
+	}
+	return nil
+}
+
+// rateKey returns the counter of a recipient in a window. Recipients are
+// hashed to keep addresses out of the cache.
+func rateKey(channel, to string, window time.Time) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(to))))
+	return "notify:rate:" + channel + ":" + hex.EncodeToString(sum[:12]) + ":" + strconv.FormatInt(window.Unix(), 10)
+}
@@ -0,0 +1,218 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/utils"
+)
+
+// Defaults of config.SMTPConfig.
+const (
+	DefaultSMTPPort    = 587
+	DefaultSMTPTimeout = 30 * time.Second
+)
+
+// SMTPSender sends email through an SMTP server. With the default
+// "starttls" mode the server must offer STARTTLS; "tls" connects over TLS
+// (port 465) and "none" sends in the clear, for local relays only.
+type SMTPSender struct {
+	cfg  config.SMTPConfig
+	addr string
+}
+
+// NewSMTPSender creates an SMTPSender. cfg.Password must be resolved.
+func NewSMTPSender(cfg config.SMTPConfig) *SMTPSender {
+	if cfg.Port == 0 {
+		cfg.Port = DefaultSMTPPort
+	}
+	if cfg.TLS == "" {
+		cfg.TLS = "starttls"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultSMTPTimeout
+	}
+	return &SMTPSender{cfg: cfg, addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))}
+}
+
+// Send implements Sender; msg.To are email addresses. Rejections by the
+// server (5xx replies) are not retried.
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	from := msg.From
+	if from == "" {
+		from = s.cfg.From
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return utils.Permanent(code.WrapErrorf(err, code.ErrBadRequest, "notify: invalid sender %q", from))
+	}
+	rcpts := make([]*mail.Address, 0, len(msg.To))
+	for _, to := range msg.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return utils.Permanent(code.WrapErrorf(err, code.ErrBadRequest, "notify: invalid recipient %q", to))
+		}
+		rcpts = append(rcpts, addr)
+	}
+	data, err := buildMessage(sender, rcpts, msg)
+	if err != nil {
+		return utils.Permanent(code.WrapError(err, code.ErrInternalServer, "notify: build email"))
+	}
+
+	if err := s.send(ctx, sender.Address, rcpts, data); err != nil {
+		wrapped := code.WrapExternalError(err, "smtp "+s.addr, "send")
+		var reply *textproto.Error
+		if errors.As(err, &reply) && reply.Code >= 500 {
+			return utils.Permanent(wrapped)
+		}
+		return wrapped
+	}
+	return nil
+}
+
+// send runs one SMTP transaction.
+func (s *SMTPSender) send(ctx context.Context, from string, rcpts []*mail.Address, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	var conn net.Conn
+	var err error
+	if s.cfg.TLS == "tls" {
+		d := &tls.Dialer{Config: &tls.Config{ServerName: s.cfg.Host}}
+		conn, err = d.DialContext(ctx, "tcp", s.addr)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", s.addr)
+	}
+	if err != nil {
+		return err
+	}
+	// net/smtp has no context support; the deadline bounds the transaction
+	// and closing the connection aborts it on cancellation.
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.cfg.TLS == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("server does not support STARTTLS")
+		}
+		if err := c.StartTLS(&tls.Config{ServerName: s.cfg.Host}); err != nil {
+			return err
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range rcpts {
+		if err := c.Rcpt(rcpt.Address); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildMessage formats msg as a MIME message: a text or HTML body, or a
+// multipart/alternative of both, quoted-printable encoded.
+func buildMessage(from *mail.Address, to []*mail.Address, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	tos := make([]string, len(to))
+	for i, addr := range to {
+		tos[i] = addr.String()
+	}
+	domain := "localhost"
+	if i := strings.LastIndexByte(from.Address, '@'); i >= 0 {
+		domain = from.Address[i+1:]
+	}
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+	header("From", from.String())
+	header("To", strings.Join(tos, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", "<"+utils.NewULID()+"@"+domain+">")
+	header("MIME-Version", "1.0")
+
+	if msg.Text != "" && msg.HTML != "" {
+		mw := multipart.NewWriter(&buf)
+		header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+		buf.WriteString("\r\n")
+		for _, part := range []struct{ contentType, body string }{
+			{"text/plain; charset=utf-8", msg.Text},
+			{"text/html; charset=utf-8", msg.HTML},
+		} {
+			pw, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {part.contentType},
+				"Content-Transfer-Encoding": {"quoted-printable"},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if err := writeQuotedPrintable(pw, part.body); err != nil {
+				return nil, err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	contentType, body := "text/plain; charset=utf-8", msg.Text
+	if msg.HTML != "" {
+		contentType, body = "text/html; charset=utf-8", msg.HTML
+	}
+	header("Content-Type", contentType)
+	header("Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+	if err := writeQuotedPrintable(&buf, body); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qw := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qw, body); err != nil {
+		return err
+	}
+	return qw.Close()
+}
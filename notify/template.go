@@ -0,0 +1,191 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/utils"
+)
+
+// DefaultTemplateLocale is the default of TemplateOptions.DefaultLocale.
+const DefaultTemplateLocale = "en"
+
+// Template parts, as file names below <name>/<locale>/.
+const (
+	partSubject = "subject.txt"
+	partText    = "body.txt"
+	partHTML    = "body.html"
+)
+
+// TemplateOptions configures LoadTemplates.
+type TemplateOptions struct {
+	// DefaultLocale is the last locale tried; default
+	// DefaultTemplateLocale.
+	DefaultLocale string
+	// Funcs are added to the templates.
+	Funcs map[string]any
+}
+
+// Templates renders messages from per-locale templates, laid out as
+// <name>/<locale>/<part>:
+//
+//	welcome/en/subject.txt   Welcome, {{.Name}}
+//	welcome/en/body.txt      text/template
+//	welcome/en/body.html     html/template, escaped
+//	welcome/pt-BR/...
+//
+// Every part is optional. The locale is the one of the message or the
+// context, its parents ("pt-BR", then "pt") and the default locale, in
+// turn; the first with the template renders all parts. Variables missing
+// from the data fail rendering rather than render empty.
+type Templates struct {
+	defaultLocale string
+	// sets are the templates by name, then normalized locale.
+	sets map[string]map[string]*templateSet
+}
+
+type templateSet struct {
+	locale  string
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// LoadTemplates parses the templates of fsys.
+func LoadTemplates(fsys fs.FS, opts ...TemplateOptions) (*Templates, error) {
+	var o TemplateOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.DefaultLocale == "" {
+		o.DefaultLocale = DefaultTemplateLocale
+	}
+	t := &Templates{defaultLocale: o.DefaultLocale, sets: make(map[string]map[string]*templateSet)}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		parts := strings.Split(name, "/")
+		if len(parts) < 3 {
+			return nil
+		}
+		part := parts[len(parts)-1]
+		if part != partSubject && part != partText && part != partHTML {
+			return nil
+		}
+		tmplName := path.Join(parts[:len(parts)-2]...)
+		locale := parts[len(parts)-2]
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		set := t.set(tmplName, locale)
+		switch part {
+		case partSubject:
+			set.subject, err = texttemplate.New(name).Funcs(o.Funcs).Option("missingkey=error").Parse(string(data))
+		case partText:
+			set.text, err = texttemplate.New(name).Funcs(o.Funcs).Option("missingkey=error").Parse(string(data))
+		case partHTML:
+			set.html, err = htmltemplate.New(name).Funcs(o.Funcs).Option("missingkey=error").Parse(string(data))
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *Templates) set(name, locale string) *templateSet {
+	if t.sets[name] == nil {
+		t.sets[name] = make(map[string]*templateSet)
+	}
+	key := normalizeLocale(locale)
+	if t.sets[name][key] == nil {
+		t.sets[name][key] = &templateSet{locale: locale}
+	}
+	return t.sets[name][key]
+}
+
+// Render fills the Subject, Text and HTML of msg from msg.Template and
+// msg.Data; parts without a template are kept. Failures are coded
+// code.ErrInternalServer errors naming the template, locale and the
+// variable that failed.
+func (t *Templates) Render(ctx context.Context, msg *Message) error {
+	locales := t.sets[msg.Template]
+	if locales == nil {
+		return code.NewErrorf(code.ErrInternalServer, "notify: template %s not found", msg.Template)
+	}
+	locale := msg.Locale
+	if locale == "" {
+		locale = utils.GetLocale(ctx)
+	}
+	var set *templateSet
+	for _, l := range localeChain(locale, t.defaultLocale) {
+		if set = locales[l]; set != nil {
+			break
+		}
+	}
+	if set == nil {
+		return code.NewErrorf(code.ErrInternalServer, "notify: template %s has no locale %s or %s", msg.Template, locale, t.defaultLocale)
+	}
+
+	// Render into a copy, so that msg is left as is on failure.
+	out := *msg
+	var buf bytes.Buffer
+	if set.subject != nil {
+		if err := set.subject.Execute(&buf, msg.Data); err != nil {
+			return renderError(err, msg.Template, set.locale)
+		}
+		out.Subject = strings.TrimSpace(buf.String())
+	}
+	if set.text != nil {
+		buf.Reset()
+		if err := set.text.Execute(&buf, msg.Data); err != nil {
+			return renderError(err, msg.Template, set.locale)
+		}
+		out.Text = buf.String()
+	}
+	if set.html != nil {
+		buf.Reset()
+		if err := set.html.Execute(&buf, msg.Data); err != nil {
+			return renderError(err, msg.Template, set.locale)
+		}
+		out.HTML = buf.String()
+	}
+	*msg = out
+	return nil
+}
+
+func renderError(err error, name, locale string) error {
+	return code.WrapErrorf(err, code.ErrInternalServer, "notify: render template %s (%s)", name, locale)
+}
+
+// localeChain returns the normalized locales tried for locale, most
+// specific first.
+func localeChain(locale, defaultLocale string) []string {
+	var out []string
+	for _, l := range []string{locale, defaultLocale} {
+		for l = normalizeLocale(l); l != ""; {
+			out = append(out, l)
+			i := strings.LastIndexByte(l, '-')
+			if i < 0 {
+				break
+			}
+			l = l[:i]
+		}
+	}
+	return out
+}
+
+// normalizeLocale lower-cases a locale and separates its parts with '-'.
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// NoopSender discards messages, e.g. in development.
+type NoopSender struct{}
+
+// Send implements Sender.
+func (NoopSender) Send(context.Context, Message) error { return nil }
+
+// TestSender records the messages it is sent, for tests of code sending
+// notifications.
+//
+//	sender := &notify.TestSender{}
+//	n := notify.New([]notify.Provider{{Name: "test", Sender: sender}}, notify.Options{Templates: templates})
+//	// ...
+//	msg, ok := sender.Last()
+type TestSender struct {
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+// Send implements Sender. Messages are recorded after rendering, and also
+// when FailWith set an error.
+func (s *TestSender) Send(_ context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, msg)
+	return s.err
+}
+
+// Messages returns the messages sent so far.
+func (s *TestSender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.messages)
+}
+
+// Last returns the latest message, and false when none was sent.
+func (s *TestSender) Last() (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.messages) == 0 {
+		return Message{}, false
+	}
+	return s.messages[len(s.messages)-1], true
+}
+
+// FailWith makes Send fail with err; nil makes it succeed again.
+func (s *TestSender) FailWith(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// Reset forgets the messages sent so far.
+func (s *TestSender) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/httpclient"
+	"github.com/NSObjects/go-kit/utils"
+)
+
+// WebhookSender posts messages as JSON to an HTTP endpoint, such as an SMS
+// gateway or a relay of a mail API. Any 2xx response accepts the message;
+// 4xx responses other than 429 are not retried.
+type WebhookSender struct {
+	client  *httpclient.Client
+	url     string
+	headers map[string]string
+}
+
+// NewWebhookSender creates a WebhookSender; name labels its errors.
+func NewWebhookSender(name string, cfg config.NotifyWebhookConfig) *WebhookSender {
+	opts := []httpclient.Option{httpclient.WithServiceName(name)}
+	if cfg.Timeout > 0 {
+		opts = append(opts, httpclient.WithTimeout(cfg.Timeout))
+	}
+	return &WebhookSender{client: httpclient.New(opts...), url: cfg.URL, headers: cfg.Headers}
+}
+
+// Send implements Sender.
+func (s *WebhookSender) Send(ctx context.Context, msg Message) error {
+	req, err := s.client.NewRequest(ctx, http.MethodPost, s.url, msg)
+	if err != nil {
+		return utils.Permanent(err)
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := s.client.Do(ctx, req)
+	if err != nil {
+		return code.WrapExternalError(err, "notify webhook", "send")
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	err = code.WrapExternalError(errors.Errorf("status %d", res.StatusCode), "notify webhook", fmt.Sprintf("send (status %d)", res.StatusCode))
+	if res.StatusCode < 500 && res.StatusCode != http.StatusTooManyRequests {
+		return utils.Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,141 @@
+// Package observability bootstraps OpenTelemetry tracing from OtelConfig.
+//
+//	shutdown, err := observability.Setup(ctx, cfg.Otel, cfg.System)
+//	if err != nil {
+//	    return err
+//	}
+//	runner := server.NewRunner(srv, cfg.System, shutdown, dbManager)
+package observability
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/lifecycle"
+	"github.com/NSObjects/go-kit/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// DefaultShutdownTimeout bounds the span flush when the shutdown context
+// has no deadline.
+const DefaultShutdownTimeout = 5 * time.Second
+
+// ShutdownFunc flushes and stops the tracer provider. It also implements
+// server.Component (Start is a no-op), so it can be passed to NewRunner as
+// the first component and is then stopped after the HTTP server.
+type ShutdownFunc func(ctx context.Context) error
+
+// Start implements server.Component.
+func (f ShutdownFunc) Start(context.Context) error { return nil }
+
+// Stop implements server.Component.
+func (f ShutdownFunc) Stop(ctx context.Context) error { return f(ctx) }
+
+// Setup initializes the global tracer provider and propagators from cfg:
+//
+//   - exporter per ExporterType: "otlp" (gRPC to OTLPEndpoint, default) or "stdout"
+//   - resource with service name, version and deployment environment
+//   - parent-based ratio sampler from SamplingRatio (0 samples everything)
+//   - W3C tracecontext and baggage propagators
+//
+// ServiceName and Environment default to sys.Name and sys.Env. When cfg is
+// disabled, Setup does nothing and returns a no-op ShutdownFunc. When ctx
+// carries a lifecycle.Lifecycle (see lifecycle.NewContext), the
+// ShutdownFunc is registered with it; do not also pass it to NewRunner.
+func Setup(ctx context.Context, cfg config.OtelConfig, sys config.SystemConfig) (ShutdownFunc, error) {
+	noop := ShutdownFunc(func(context.Context) error { return nil })
+	if !cfg.Enabled {
+		return noop, nil
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = sys.Name
+	}
+	env := cfg.Environment
+	if env == "" {
+		env = sys.Env
+	}
+
+	exporter, err := newExporter(ctx, cfg)
+	if err != nil {
+		return noop, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(sys.Version),
+		semconv.DeploymentEnvironmentName(env),
+	))
+	if err != nil {
+		return noop, fmt.Errorf("otel resource: %w", err)
+	}
+
+	ratio := cfg.SamplingRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	utils.SetTracerName(serviceName)
+
+	shutdown := ShutdownFunc(func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, DefaultShutdownTimeout)
+			defer cancel()
+		}
+		if err := tp.Shutdown(ctx); err != nil {
+			return fmt.Errorf("otel shutdown: %w", err)
+		}
+		return nil
+	})
+	if lc := lifecycle.FromContext(ctx); lc != nil {
+		lc.Append(lifecycle.Hook{Name: "otel", OnStop: shutdown})
+	}
+	return shutdown, nil
+}
+
+func newExporter(ctx context.Context, cfg config.OtelConfig) (sdktrace.SpanExporter, error) {
+	switch cfg.ExporterType {
+	case "", "otlp":
+		opts := []otlptracegrpc.Option{}
+		if cfg.OTLPEndpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.OTLPEndpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exp, err := otlptracegrpc.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("otlp exporter: %w", err)
+		}
+		return exp, nil
+	case "stdout":
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, fmt.Errorf("stdout exporter: %w", err)
+		}
+		return exp, nil
+	default:
+		return nil, fmt.Errorf("unknown otel exporter type %q", cfg.ExporterType)
+	}
+}
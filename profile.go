@@ -0,0 +1,125 @@
+package kit
+
+import (
+	"sync"
+
+	"github.com/NSObjects/go-kit/config"
+)
+
+// Profile holds the defaults of an environment (SystemConfig.Env) consumed
+// by log.NewFromLogConfig, middleware.Setup and the metrics setup. Explicit
+// config values always win over profile defaults (see Resolve).
+type Profile struct {
+	// Name is the environment the profile applies to.
+	Name string
+	// LogLevel is the log level when log.level is unset.
+	LogLevel string
+	// LogFormat is the console log format when none is configured: "color",
+	// "text" or "json".
+	LogFormat string
+	// FileLogging enables the file sink when log.file.filename is set.
+	FileLogging bool
+	// DebugRoutes registers /debug/pprof endpoints.
+	DebugRoutes bool
+	// AccessLogSampleRate is the fraction of successful requests logged by
+	// the access log; failed requests are always logged.
+	AccessLogSampleRate float64
+	// VerboseRecovery includes the panic message and stack in the 500
+	// response of recovered panics.
+	VerboseRecovery bool
+	// DebugErrors adds the error text and stack to error responses in a
+	// "debug" field (see middleware.ErrorHandlerConfig).
+	DebugErrors bool
+	// RuntimeMetrics exposes the Go runtime and process collectors.
+	RuntimeMetrics bool
+}
+
+// Built-in profiles.
+var (
+	Dev = Profile{
+		Name:                "dev",
+		LogLevel:            "debug",
+		LogFormat:           "color",
+		DebugRoutes:         true,
+		AccessLogSampleRate: 1,
+		VerboseRecovery:     true,
+		DebugErrors:         true,
+		RuntimeMetrics:      true,
+	}
+	Test = Profile{
+		Name:                "test",
+		LogLevel:            "info",
+		LogFormat:           "text",
+		FileLogging:         true,
+		AccessLogSampleRate: 1,
+	}
+	Prod = Profile{
+		Name:                "prod",
+		LogLevel:            "info",
+		LogFormat:           "json",
+		FileLogging:         true,
+		AccessLogSampleRate: 1,
+		RuntimeMetrics:      true,
+	}
+)
+
+var (
+	profilesMu sync.RWMutex
+	profiles   = map[string]Profile{"dev": Dev, "test": Test, "prod": Prod}
+)
+
+// RegisterProfile registers a profile for p.Name, e.g. a "staging" profile
+// derived from Prod, and makes the environment known to config.Check. Call
+// it during startup, before loading the configuration.
+//
+//	staging := kit.Prod
+//	staging.Name = "staging"
+//	staging.LogLevel = "debug"
+//	kit.RegisterProfile(staging)
+func RegisterProfile(p Profile) {
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	profiles[p.Name] = p
+	config.RegisterEnv(p.Name)
+}
+
+// ProfileFor returns the profile of env. Unknown and empty environments get
+// Dev.
+func ProfileFor(env string) Profile {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	if p, ok := profiles[env]; ok {
+		return p
+	}
+	return Dev
+}
+
+// Resolve returns the effective profile of cfg: the profile of
+// cfg.System.Env with the values set in cfg.Log and cfg.Profile applied on
+// top.
+func Resolve(cfg config.Config) Profile {
+	p := ProfileFor(cfg.System.Env)
+	if cfg.Log.Level != "" {
+		p.LogLevel = cfg.Log.Level
+	}
+	if cfg.Log.Format != "" {
+		p.LogFormat = cfg.Log.Format
+	}
+	o := cfg.Profile
+	if o.DebugRoutes != nil {
+		p.DebugRoutes = *o.DebugRoutes
+	}
+	if o.AccessLogSampleRate != nil {
+		p.AccessLogSampleRate = *o.AccessLogSampleRate
+	}
+	if o.VerboseRecovery != nil {
+		p.VerboseRecovery = *o.VerboseRecovery
+	}
+	if o.DebugErrors != nil {
+		p.DebugErrors = *o.DebugErrors
+	}
+	if o.RuntimeMetrics != nil {
+		p.RuntimeMetrics = *o.RuntimeMetrics
+	}
+	return p
+}
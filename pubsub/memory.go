@@ -0,0 +1,109 @@
+package pubsub
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// MemoryBus is an in-process Bus for tests and single-instance deployments.
+// Each subscriber has a buffered queue; Publish blocks when a queue is
+// full, until the subscriber takes the message or goes away, or ctx is
+// done.
+type MemoryBus struct {
+	mu     sync.RWMutex
+	subs   map[string][]*memorySub
+	buffer int
+	closed bool
+}
+
+// memorySub is a subscription of a MemoryBus. done is closed when it ends,
+// releasing the publishers blocked on its full queue; ch is never closed,
+// as publishers send to it outside the lock.
+type memorySub struct {
+	ch       chan Message
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+func (s *memorySub) stop() {
+	s.doneOnce.Do(func() { close(s.done) })
+}
+
+// NewMemoryBus creates an in-memory bus.
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{subs: make(map[string][]*memorySub), buffer: 64}
+}
+
+// Publish implements Publisher. Messages without metadata get the request
+// and trace information of ctx (see MetadataFromContext).
+func (b *MemoryBus) Publish(ctx context.Context, msg Message) error {
+	if msg.Metadata == nil {
+		msg.Metadata = MetadataFromContext(ctx)
+	}
+	// Send outside the lock: a subscriber leaving, Close, or a handler
+	// publishing to its own topic need it while the queue is full.
+	b.mu.RLock()
+	subs := slices.Clone(b.subs[msg.Topic])
+	b.mu.RUnlock()
+	for _, sub := range subs {
+		select {
+		case sub.ch <- msg:
+		case <-sub.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Subscribe implements Subscriber. Message metadata is restored into the
+// handler's context (see ContextWithMetadata).
+func (b *MemoryBus) Subscribe(ctx context.Context, topic string, handler Handler) error {
+	sub := &memorySub{ch: make(chan Message, b.buffer), done: make(chan struct{})}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.subs[topic] = append(b.subs[topic], sub)
+	b.mu.Unlock()
+
+	defer b.unsubscribe(topic, sub)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-sub.done:
+			return nil
+		case msg := <-sub.ch:
+			_ = handler(ContextWithMetadata(ctx, msg.Metadata), msg)
+		}
+	}
+}
+
+func (b *MemoryBus) unsubscribe(topic string, sub *memorySub) {
+	sub.stop()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[topic] = slices.DeleteFunc(b.subs[topic], func(s *memorySub) bool { return s == sub })
+}
+
+// Close stops all subscriptions.
+func (b *MemoryBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for _, subs := range b.subs {
+		for _, sub := range subs {
+			sub.stop()
+		}
+	}
+	b.subs = make(map[string][]*memorySub)
+	return nil
+}
@@ -0,0 +1,182 @@
+// Package pubsub provides a lightweight event bus abstraction with typed
+// handlers, metadata propagation, retries and dead-letter logging.
+//
+// Implementations are provided for Redis pub/sub (RedisBus), PostgreSQL
+// LISTEN/NOTIFY (db.PGNotifier) and in-memory delivery (MemoryBus, for
+// tests and single-process use). A Kafka-backed bus
+// can be added in the application by implementing Publisher and Subscriber
+// on top of its Kafka client (see db/kafka.go).
+//
+// Usage:
+//
+//	bus := pubsub.NewRedisBus(manager.Redis)
+//	_ = pubsub.Publish(ctx, bus, "user.updated", UserUpdated{ID: 1})
+//
+//	go pubsub.Subscribe(ctx, bus, "user.updated", func(ctx context.Context, ev UserUpdated) error {
+//	    return cache.Delete(ctx, userKey(ev.ID))
+//	})
+package pubsub
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/NSObjects/go-kit/cache"
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Metadata keys carried with every message.
+const (
+	MetaRequestID = "request_id"
+	MetaUserID    = "user_id"
+	MetaTenantID  = "tenant_id"
+)
+
+// Message is a published event.
+type Message struct {
+	Topic    string            `json:"topic"`
+	Key      string            `json:"key,omitempty"` // partition/ordering key, if supported
+	Payload  []byte            `json:"payload"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Handler processes a raw message.
+type Handler func(ctx context.Context, msg Message) error
+
+// Publisher publishes messages to a topic.
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+// Subscriber delivers messages of a topic to a handler.
+// Subscribe blocks until ctx is canceled or the subscription fails.
+type Subscriber interface {
+	Subscribe(ctx context.Context, topic string, handler Handler) error
+}
+
+// Bus is both a Publisher and a Subscriber.
+type Bus interface {
+	Publisher
+	Subscriber
+	Close() error
+}
+
+// SubscribeOptions configures typed subscriptions.
+type SubscribeOptions struct {
+	// Retry controls redelivery attempts of a failing handler.
+	Retry utils.RetryPolicy
+	// Codec decodes payloads; default cache.DefaultCodec.
+	Codec cache.Codec
+	// DeadLetter is called with messages that still fail after all retries.
+	// Default logs the message as a poison message.
+	DeadLetter func(ctx context.Context, msg Message, err error)
+}
+
+// PublishOptions configures typed publishing.
+type PublishOptions struct {
+	// Codec encodes payloads; default cache.DefaultCodec.
+	Codec cache.Codec
+}
+
+// Publish encodes payload and publishes it to topic. Request ID, user, tenant
+// and the OpenTelemetry trace context from ctx are attached as metadata.
+func Publish[T any](ctx context.Context, pub Publisher, topic string, payload T, opts ...PublishOptions) error {
+	codec := cache.DefaultCodec
+	if len(opts) > 0 && opts[0].Codec != nil {
+		codec = opts[0].Codec
+	}
+
+	data, err := codec.Marshal(payload)
+	if err != nil {
+		return code.WrapError(err, code.ErrEncodingFailed, "encode event payload")
+	}
+
+	return pub.Publish(ctx, Message{
+		Topic:    topic,
+		Payload:  data,
+		Metadata: MetadataFromContext(ctx),
+	})
+}
+
+// Subscribe decodes messages of topic into T and calls handler.
+// Failing handlers are retried according to the retry policy; messages that
+// cannot be decoded or keep failing are passed to the dead-letter function.
+// Subscribe blocks until ctx is canceled.
+func Subscribe[T any](ctx context.Context, sub Subscriber, topic string, handler func(ctx context.Context, payload T) error, opts ...SubscribeOptions) error {
+	var o SubscribeOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Codec == nil {
+		o.Codec = cache.DefaultCodec
+	}
+	if o.DeadLetter == nil {
+		o.DeadLetter = logDeadLetter
+	}
+
+	return sub.Subscribe(ctx, topic, func(ctx context.Context, msg Message) error {
+		ctx = ContextWithMetadata(ctx, msg.Metadata)
+
+		var payload T
+		if err := o.Codec.Unmarshal(msg.Payload, &payload); err != nil {
+			o.DeadLetter(ctx, msg, code.WrapError(err, code.ErrDecodingFailed, "decode event payload"))
+			return nil
+		}
+
+		err := utils.Retry(ctx, o.Retry, func(ctx context.Context) error {
+			return handler(ctx, payload)
+		})
+		if err != nil && ctx.Err() == nil {
+			o.DeadLetter(ctx, msg, err)
+		}
+		return nil
+	})
+}
+
+// logDeadLetter logs a poison message.
+func logDeadLetter(ctx context.Context, msg Message, err error) {
+	slog.ErrorContext(ctx, "Poison message dropped",
+		slog.String("topic", msg.Topic),
+		slog.String("request_id", msg.Metadata[MetaRequestID]),
+		slog.Int("payload_size", len(msg.Payload)),
+		slog.String("error", err.Error()),
+	)
+}
+
+// MetadataFromContext captures request and trace information from ctx.
+func MetadataFromContext(ctx context.Context) map[string]string {
+	md := make(map[string]string)
+	if v := utils.GetRequestID(ctx); v != "" {
+		md[MetaRequestID] = v
+	}
+	if v := utils.GetUserID(ctx); v != "" {
+		md[MetaUserID] = v
+	}
+	if v := utils.GetTenantID(ctx); v != "" {
+		md[MetaTenantID] = v
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(md))
+	return md
+}
+
+// ContextWithMetadata restores request and trace information captured by
+// MetadataFromContext into ctx, so utils.GetRequestID and friends work in
+// consumers. Values missing from md are left as they are in ctx.
+func ContextWithMetadata(ctx context.Context, md map[string]string) context.Context {
+	if len(md) == 0 {
+		return ctx
+	}
+	for key, ctxKey := range map[string]utils.ContextKey{
+		MetaRequestID: utils.KeyRequestID,
+		MetaUserID:    utils.KeyUserID,
+		MetaTenantID:  utils.KeyTenantID,
+	} {
+		if v := md[key]; v != "" {
+			ctx = context.WithValue(ctx, ctxKey, v)
+		}
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(md))
+}
@@ -0,0 +1,100 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBus implements Bus using Redis pub/sub.
+// Delivery is at-most-once: messages published while no subscriber is
+// connected are lost. Use Redis Streams for durable delivery.
+type RedisBus struct {
+	client *redis.Client
+	prefix string
+
+	mu     sync.Mutex
+	subs   []*redis.PubSub
+	closed bool
+}
+
+// NewRedisBus creates a Redis pub/sub bus.
+func NewRedisBus(client *redis.Client) *RedisBus {
+	return &RedisBus{client: client, prefix: "events:"}
+}
+
+// NewRedisBusWithPrefix creates a Redis pub/sub bus with a channel prefix.
+func NewRedisBusWithPrefix(client *redis.Client, prefix string) *RedisBus {
+	return &RedisBus{client: client, prefix: prefix}
+}
+
+// Publish implements Publisher. Messages without metadata get the request
+// and trace information of ctx (see MetadataFromContext).
+func (b *RedisBus) Publish(ctx context.Context, msg Message) error {
+	if msg.Metadata == nil {
+		msg.Metadata = MetadataFromContext(ctx)
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return code.WrapError(err, code.ErrEncodingJSON, "encode message")
+	}
+	return code.WrapRedisError(b.client.Publish(ctx, b.prefix+msg.Topic, data).Err(), "publish")
+}
+
+// Subscribe implements Subscriber. Messages are handled sequentially in
+// arrival order, with their metadata restored into the handler's context
+// (see ContextWithMetadata). It returns when ctx is canceled or the bus is closed.
+func (b *RedisBus) Subscribe(ctx context.Context, topic string, handler Handler) error {
+	ps := b.client.Subscribe(ctx, b.prefix+topic)
+	if _, err := ps.Receive(ctx); err != nil {
+		ps.Close()
+		return code.WrapRedisError(err, "subscribe")
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		ps.Close()
+		return nil
+	}
+	b.subs = append(b.subs, ps)
+	b.mu.Unlock()
+	defer ps.Close()
+
+	ch := ps.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case m, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			var msg Message
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+				slog.Error("Malformed message dropped",
+					slog.String("channel", m.Channel),
+					slog.String("error", err.Error()),
+				)
+				continue
+			}
+			_ = handler(ContextWithMetadata(ctx, msg.Metadata), msg)
+		}
+	}
+}
+
+// Close closes all active subscriptions.
+func (b *RedisBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for _, ps := range b.subs {
+		ps.Close()
+	}
+	b.subs = nil
+	return nil
+}
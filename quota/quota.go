@@ -0,0 +1,309 @@
+// Package quota tracks monthly usage quotas per API key for billing.
+//
+// Usage is counted in Redis (a monthly counter for enforcement and hourly
+// buckets for recent activity) and aggregated into daily rows of the
+// quota_usage table by Run. Only requests beyond the hard monthly cap are
+// rejected; crossing a soft threshold (80% and 95% by default) emits an
+// Event once per key and month.
+//
+//	// once: gdb.AutoMigrate(&quota.Usage{})
+//	t := quota.New(redisCache, m.DB, quota.Options{
+//	    Limit: 100_000,
+//	    OnThreshold: func(ctx context.Context, ev quota.Event) {
+//	        _ = bus.Publish(ctx, "quota.warnings", ev)
+//	    },
+//	})
+//	go t.Run(ctx) // flushes pending usage on shutdown
+//
+//	api.Use(middleware.Quota(middleware.QuotaConfig{Tracker: t}))
+//
+// Windows are calendar hours, days and months in UTC.
+package quota
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/NSObjects/go-kit/cache"
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Counter retention beyond the end of their window, so late readers still
+// see them.
+const (
+	monthRetention = 7 * 24 * time.Hour
+	hourRetention  = 48 * time.Hour
+)
+
+// Options configures a Tracker. Zero values use the defaults.
+type Options struct {
+	// Limit is the hard monthly cap per key; 0 means unlimited.
+	Limit int64
+	// LimitFunc returns the cap of a key, e.g. from its billing plan, and
+	// overrides Limit when set.
+	LimitFunc func(ctx context.Context, keyID string) (int64, error)
+	// SoftThresholds are the fractions of the cap that emit an Event when
+	// crossed; default 0.8 and 0.95.
+	SoftThresholds []float64
+	// OnThreshold receives soft threshold events on the request goroutine,
+	// so it should not block. Events are logged either way.
+	OnThreshold func(ctx context.Context, ev Event)
+	// FlushInterval between flushes of pending usage to the database;
+	// default 30s.
+	FlushInterval time.Duration
+	// Clock provides the current time and, when a utils.TimerClock such as
+	// utils.FakeClock, paces the flushes of Run; default utils.RealClock.
+	Clock utils.Clock
+}
+
+func (o Options) withDefaults() Options {
+	if o.SoftThresholds == nil {
+		o.SoftThresholds = []float64{0.8, 0.95}
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = 30 * time.Second
+	}
+	if o.Clock == nil {
+		o.Clock = utils.RealClock{}
+	}
+	return o
+}
+
+// Status is the quota state of a key for the current month.
+type Status struct {
+	KeyID string `json:"key_id"`
+	// Used is the usage of the current month.
+	Used int64 `json:"used"`
+	// Limit is the monthly cap; 0 means unlimited.
+	Limit int64 `json:"limit"`
+	// Remaining is Limit-Used, or -1 when unlimited.
+	Remaining int64 `json:"remaining"`
+	// Reset is when the next month starts.
+	Reset time.Time `json:"reset"`
+	// Exceeded reports whether the recorded units were rejected.
+	Exceeded bool `json:"exceeded"`
+}
+
+// Event is emitted when a key's usage crosses a soft threshold.
+type Event struct {
+	KeyID     string    `json:"key_id"`
+	Threshold float64   `json:"threshold"`
+	Used      int64     `json:"used"`
+	Limit     int64     `json:"limit"`
+	Month     Period    `json:"month"`
+	Time      time.Time `json:"time"`
+}
+
+// bucket identifies pending usage of a key in an hour.
+type bucket struct {
+	keyID string
+	hour  time.Time
+}
+
+// Tracker records and enforces usage quotas.
+type Tracker struct {
+	cache cache.Cache
+	db    *gorm.DB
+	opts  Options
+
+	mu      sync.Mutex
+	pending map[bucket]int64
+}
+
+// New creates a Tracker counting in c, which must implement cache.Counter
+// (RedisCache does), and storing daily usage in gdb.
+func New(c cache.Cache, gdb *gorm.DB, opts Options) *Tracker {
+	return &Tracker{cache: c, db: gdb, opts: opts.withDefaults(), pending: make(map[bucket]int64)}
+}
+
+// Record adds n units to the usage of keyID. When the new usage exceeds
+// the monthly cap the units are not counted and the returned Status has
+// Exceeded set; callers should reject the request (see Exceeded).
+func (t *Tracker) Record(ctx context.Context, keyID string, n int64) (Status, error) {
+	counter, ok := t.cache.(cache.Counter)
+	if !ok {
+		return Status{}, code.WrapError(cache.ErrCounterUnsupported, code.ErrRedis, "quota record")
+	}
+	now := t.opts.Clock.Now().UTC()
+	month := Month(now)
+	st := Status{KeyID: keyID, Reset: month.End}
+
+	limit, err := t.limit(ctx, keyID)
+	if err != nil {
+		return st, err
+	}
+	st.Limit = limit
+
+	used, err := counter.IncrBy(ctx, monthKey(keyID, month.Start), n, month.End.Sub(now)+monthRetention)
+	if err != nil {
+		return st, code.WrapError(err, code.ErrRedis, "quota record")
+	}
+	if limit > 0 && used > limit {
+		// Give the units back so rejected requests are not billed.
+		if used, err = counter.IncrBy(ctx, monthKey(keyID, month.Start), -n, 0); err != nil {
+			return st, code.WrapError(err, code.ErrRedis, "quota record")
+		}
+		st.Used, st.Remaining, st.Exceeded = used, max(limit-used, 0), true
+		return st, nil
+	}
+	st.Used, st.Remaining = used, remaining(limit, used)
+
+	hour := now.Truncate(time.Hour)
+	if _, err := counter.IncrBy(ctx, hourKey(keyID, hour), n, hour.Add(time.Hour).Sub(now)+hourRetention); err != nil {
+		slog.WarnContext(ctx, "Quota hourly bucket update failed", slog.String("key_id", keyID), log.Err(err))
+	}
+	t.mu.Lock()
+	t.pending[bucket{keyID: keyID, hour: hour}] += n
+	t.mu.Unlock()
+
+	t.checkThresholds(ctx, keyID, used-n, used, limit, month, now)
+	return st, nil
+}
+
+// Status returns the current month's quota state of keyID without
+// recording usage.
+func (t *Tracker) Status(ctx context.Context, keyID string) (Status, error) {
+	now := t.opts.Clock.Now().UTC()
+	month := Month(now)
+	st := Status{KeyID: keyID, Reset: month.End}
+
+	limit, err := t.limit(ctx, keyID)
+	if err != nil {
+		return st, err
+	}
+	if err := t.cache.Get(ctx, monthKey(keyID, month.Start), &st.Used); err != nil && !errors.Is(err, redis.Nil) {
+		return st, code.WrapError(err, code.ErrRedis, "quota status")
+	}
+	st.Limit, st.Remaining = limit, remaining(limit, st.Used)
+	return st, nil
+}
+
+// HourlyUsage returns the usage of keyID in the hour containing at. Hourly
+// buckets are kept for 48 hours; older hours return 0.
+func (t *Tracker) HourlyUsage(ctx context.Context, keyID string, at time.Time) (int64, error) {
+	var used int64
+	if err := t.cache.Get(ctx, hourKey(keyID, at.UTC().Truncate(time.Hour)), &used); err != nil && !errors.Is(err, redis.Nil) {
+		return 0, code.WrapError(err, code.ErrRedis, "quota hourly usage")
+	}
+	return used, nil
+}
+
+// Reset clears the current month's counter of keyID, e.g. after a plan
+// upgrade, so the key starts from zero against its cap and soft thresholds
+// fire again. Recorded usage in the database is kept for billing.
+func (t *Tracker) Reset(ctx context.Context, keyID string) error {
+	month := Month(t.opts.Clock.Now().UTC())
+	if err := t.cache.Delete(ctx, monthKey(keyID, month.Start)); err != nil {
+		return code.WrapError(err, code.ErrRedis, "quota reset")
+	}
+	slog.InfoContext(ctx, "Quota reset", slog.String("key_id", keyID))
+	return nil
+}
+
+// Run flushes pending usage every FlushInterval until ctx is canceled,
+// then flushes once more so no usage recorded by this process is lost.
+// A process killed without shutdown loses at most FlushInterval of
+// database usage; the Redis counters are unaffected.
+func (t *Tracker) Run(ctx context.Context) error {
+	ticker := utils.NewTicker(t.opts.Clock, t.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return t.Flush(context.WithoutCancel(ctx))
+		case <-ticker.C():
+			if err := t.Flush(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("Quota flush failed", log.Err(err))
+			}
+		}
+	}
+}
+
+// Flush adds the pending hourly usage to the daily rows of the database.
+// Usage that fails to flush stays pending for the next call.
+func (t *Tracker) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = make(map[bucket]int64)
+	t.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	daily := make(map[bucket]int64, len(pending))
+	for b, n := range pending {
+		daily[bucket{keyID: b.keyID, hour: Day(b.hour).Start}] += n
+	}
+	if err := t.addUsage(ctx, daily); err != nil {
+		t.mu.Lock()
+		for b, n := range pending {
+			t.pending[b] += n
+		}
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Exceeded returns the coded 429 error for a rejected Status.
+func Exceeded(st Status) error {
+	return code.NewErrorf(code.ErrTooManyRequests, "monthly quota of %d exceeded", st.Limit)
+}
+
+func (t *Tracker) limit(ctx context.Context, keyID string) (int64, error) {
+	if t.opts.LimitFunc == nil {
+		return t.opts.Limit, nil
+	}
+	limit, err := t.opts.LimitFunc(ctx, keyID)
+	if err != nil {
+		return 0, errors.Wrap(err, "quota limit")
+	}
+	return limit, nil
+}
+
+// checkThresholds emits an Event for every soft threshold between prev
+// (exclusive) and used (inclusive). The counter is atomic, so exactly one
+// request crosses each threshold, across all replicas.
+func (t *Tracker) checkThresholds(ctx context.Context, keyID string, prev, used, limit int64, month Period, now time.Time) {
+	if limit <= 0 {
+		return
+	}
+	for _, th := range t.opts.SoftThresholds {
+		mark := int64(th * float64(limit))
+		if prev >= mark || used < mark {
+			continue
+		}
+		ev := Event{KeyID: keyID, Threshold: th, Used: used, Limit: limit, Month: month, Time: now}
+		slog.WarnContext(ctx, "Quota threshold reached",
+			slog.String("key_id", keyID),
+			slog.Float64("threshold", th),
+			slog.Int64("used", used),
+			slog.Int64("limit", limit))
+		if t.opts.OnThreshold != nil {
+			t.opts.OnThreshold(ctx, ev)
+		}
+	}
+}
+
+func remaining(limit, used int64) int64 {
+	if limit <= 0 {
+		return -1
+	}
+	return max(limit-used, 0)
+}
+
+func monthKey(keyID string, month time.Time) string {
+	return fmt.Sprintf("quota:%s:m:%s", keyID, month.Format("2006-01"))
+}
+
+func hourKey(keyID string, hour time.Time) string {
+	return fmt.Sprintf("quota:%s:h:%s", keyID, hour.Format("2006-01-02T15"))
+}
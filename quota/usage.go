@@ -0,0 +1,93 @@
+package quota
+
+import (
+	"context"
+	"time"
+
+	"github.com/NSObjects/go-kit/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Usage is the usage of a key on a day (UTC).
+type Usage struct {
+	KeyID     string    `gorm:"size:128;primaryKey" json:"key_id"`
+	Day       time.Time `gorm:"primaryKey" json:"day"`
+	Units     int64     `gorm:"not null" json:"units"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+// TableName implements gorm's Tabler.
+func (Usage) TableName() string {
+	return "quota_usage"
+}
+
+// Period is the half-open time range [Start, End).
+type Period struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Month returns the calendar month (UTC) containing t.
+func Month(t time.Time) Period {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return Period{Start: start, End: start.AddDate(0, 1, 0)}
+}
+
+// Day returns the calendar day (UTC) containing t.
+func Day(t time.Time) Period {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return Period{Start: start, End: start.AddDate(0, 0, 1)}
+}
+
+// Report is the usage of a key over a period.
+type Report struct {
+	KeyID  string  `json:"key_id"`
+	Period Period  `json:"period"`
+	Total  int64   `json:"total"`
+	Days   []Usage `json:"days"`
+}
+
+// Usage returns the usage of keyID over the days starting in period, for
+// billing. Usage of this process is flushed first; usage pending in other
+// processes appears after their next flush.
+func (t *Tracker) Usage(ctx context.Context, keyID string, period Period) (Report, error) {
+	report := Report{KeyID: keyID, Period: period}
+	if err := t.Flush(ctx); err != nil {
+		return report, err
+	}
+	err := t.db.WithContext(ctx).
+		Where("key_id = ? AND day >= ? AND day < ?", keyID, period.Start.UTC(), period.End.UTC()).
+		Order("day").
+		Find(&report.Days).Error
+	if err != nil {
+		return report, db.TranslateError(err, "quota usage")
+	}
+	for _, d := range report.Days {
+		report.Total += d.Units
+	}
+	return report, nil
+}
+
+// addUsage adds the daily units to their rows in one transaction.
+func (t *Tracker) addUsage(ctx context.Context, daily map[bucket]int64) error {
+	now := t.opts.Clock.Now()
+	return db.WithTransaction(ctx, t.db, func(tx *gorm.DB) error {
+		for b, n := range daily {
+			row := Usage{KeyID: b.keyID, Day: b.hour, Units: n, UpdatedAt: now}
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "key_id"}, {Name: "day"}},
+				DoUpdates: clause.Assignments(map[string]any{
+					"units":      gorm.Expr("quota_usage.units + ?", n),
+					"updated_at": now,
+				}),
+			}).Create(&row).Error
+			if err != nil {
+				return db.TranslateError(err, "quota flush")
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,281 @@
+// Package resilience provides fault-tolerance primitives for calls to
+// outbound dependencies.
+package resilience
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/metrics"
+	"github.com/NSObjects/go-kit/utils"
+)
+
+// State is the state of a circuit breaker.
+type State int
+
+const (
+	// StateClosed lets all calls through and counts consecutive failures.
+	StateClosed State = iota
+	// StateHalfOpen lets a limited number of trial calls through.
+	StateHalfOpen
+	// StateOpen rejects all calls until OpenTimeout elapses.
+	StateOpen
+)
+
+// String returns the state name.
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerConfig configures a Breaker.
+type BreakerConfig struct {
+	// Name identifies the breaker in errors, callbacks and metrics.
+	Name string
+	// FailureThreshold is the number of consecutive failures that opens the
+	// circuit; default 5.
+	FailureThreshold int
+	// SuccessThreshold is the number of consecutive half-open successes that
+	// closes the circuit; default 1.
+	SuccessThreshold int
+	// OpenTimeout is how long the circuit stays open before allowing trial
+	// calls; default 30s.
+	OpenTimeout time.Duration
+	// HalfOpenMaxCalls limits concurrent trial calls while half-open; default 1.
+	HalfOpenMaxCalls int
+	// IsFailure reports whether an error counts as a failure; default
+	// DefaultIsFailure.
+	IsFailure func(err error) bool
+	// OnStateChange is called after each state transition.
+	OnStateChange func(name string, from, to State)
+	// Clock is the time source; default utils.RealClock.
+	Clock utils.Clock
+	// Metrics records state and rejections (optional).
+	Metrics *metrics.BreakerMetrics
+}
+
+// DefaultIsFailure counts uncoded errors, errors whose code maps to a 5xx
+// status and retryable errors (see code.IsRetryable) as failures. Client
+// errors (other 4xx codes) and caller cancellation do not count: they say
+// nothing about the dependency's health.
+func DefaultIsFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if c := errors.GetCode(err); c != 0 {
+		return errors.HTTPStatus(c) >= 500 || code.IsRetryable(err)
+	}
+	return true
+}
+
+// Breaker is a circuit breaker. It is safe for concurrent use.
+type Breaker struct {
+	cfg BreakerConfig
+
+	mu         sync.Mutex
+	state      State
+	generation uint64
+	failures   int
+	successes  int
+	inFlight   int
+	openedAt   time.Time
+}
+
+// NewBreaker creates a closed Breaker.
+func NewBreaker(cfg BreakerConfig) *Breaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.SuccessThreshold <= 0 {
+		cfg.SuccessThreshold = 1
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenMaxCalls <= 0 {
+		cfg.HalfOpenMaxCalls = 1
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = DefaultIsFailure
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = utils.RealClock{}
+	}
+
+	b := &Breaker{cfg: cfg}
+	if cfg.Metrics != nil {
+		cfg.Metrics.State.WithLabelValues(cfg.Name).Set(float64(StateClosed))
+	}
+	return b
+}
+
+// Name returns the breaker name.
+func (b *Breaker) Name() string {
+	return b.cfg.Name
+}
+
+// State returns the current state, moving from open to half-open if the open
+// timeout has elapsed.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	from, to := b.advance()
+	state := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	return state
+}
+
+// Do calls fn if the circuit allows it and records the outcome. Rejected calls
+// return an error coded code.ErrCircuitOpen (HTTP 503). A panic in fn is
+// recorded as a failure, then propagated.
+func (b *Breaker) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	defer donePanicking(done)
+	err = fn(ctx)
+	done(err)
+	return err
+}
+
+// DoValue is the value-returning variant of Breaker.Do.
+func DoValue[T any](ctx context.Context, b *Breaker, fn func(ctx context.Context) (T, error)) (T, error) {
+	done, err := b.Allow()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer donePanicking(done)
+	v, err := fn(ctx)
+	done(err)
+	return v, err
+}
+
+// donePanicking, deferred, records a panicking call as failed so that it
+// frees its half-open slot, and panics again.
+func donePanicking(done func(err error)) {
+	if r := recover(); r != nil {
+		done(errors.FromPanic(r))
+		panic(r)
+	}
+}
+
+// Allow reserves a call. If the circuit is open it returns an ErrCircuitOpen
+// error; otherwise the caller must invoke done exactly once with the call's
+// outcome. Use it when the outcome is not a plain error, e.g. to count a 5xx
+// response as a failure.
+func (b *Breaker) Allow() (done func(err error), err error) {
+	b.mu.Lock()
+	from, to := b.advance()
+
+	reject := b.state == StateOpen ||
+		(b.state == StateHalfOpen && b.inFlight >= b.cfg.HalfOpenMaxCalls)
+	if reject {
+		b.mu.Unlock()
+		b.notify(from, to)
+		if b.cfg.Metrics != nil {
+			b.cfg.Metrics.Rejected.WithLabelValues(b.cfg.Name).Inc()
+		}
+		return nil, errors.WithCode(code.ErrCircuitOpen, "circuit breaker %s is open", b.cfg.Name)
+	}
+
+	b.inFlight++
+	gen := b.generation
+	b.mu.Unlock()
+	b.notify(from, to)
+
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { b.record(gen, err) })
+	}, nil
+}
+
+// record applies the outcome of a call admitted in generation gen. Outcomes
+// from an earlier generation (before a state change) are ignored. Panics
+// are failures whatever IsFailure says.
+func (b *Breaker) record(gen uint64, err error) {
+	failed := errors.IsPanic(err) || b.cfg.IsFailure(err)
+
+	b.mu.Lock()
+	if gen != b.generation {
+		b.mu.Unlock()
+		return
+	}
+	b.inFlight--
+
+	from, to := b.state, b.state
+	switch b.state {
+	case StateClosed:
+		if !failed {
+			b.failures = 0
+			break
+		}
+		b.failures++
+		if b.failures >= b.cfg.FailureThreshold {
+			to = b.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		if failed {
+			to = b.setState(StateOpen)
+			break
+		}
+		b.successes++
+		if b.successes >= b.cfg.SuccessThreshold {
+			to = b.setState(StateClosed)
+		}
+	}
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+// advance moves an open circuit to half-open once OpenTimeout has elapsed.
+// Must be called with mu held; returns the transition for notify.
+func (b *Breaker) advance() (from, to State) {
+	from = b.state
+	if b.state == StateOpen && !b.cfg.Clock.Now().Before(b.openedAt.Add(b.cfg.OpenTimeout)) {
+		return from, b.setState(StateHalfOpen)
+	}
+	return from, from
+}
+
+// setState switches to s and resets the counters. Must be called with mu held.
+func (b *Breaker) setState(s State) State {
+	b.state = s
+	b.generation++
+	b.failures = 0
+	b.successes = 0
+	b.inFlight = 0
+	if s == StateOpen {
+		b.openedAt = b.cfg.Clock.Now()
+	}
+	return s
+}
+
+// notify reports a transition to metrics and OnStateChange. Must be called
+// without mu held.
+func (b *Breaker) notify(from, to State) {
+	if from == to {
+		return
+	}
+	if m := b.cfg.Metrics; m != nil {
+		m.State.WithLabelValues(b.cfg.Name).Set(float64(to))
+		m.Transitions.WithLabelValues(b.cfg.Name, from.String(), to.String()).Inc()
+	}
+	if b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(b.cfg.Name, from, to)
+	}
+}
@@ -0,0 +1,84 @@
+package resilience
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/utils"
+)
+
+var errDown = errors.New("down")
+
+func TestBreakerStateMachine(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	b := NewBreaker(BreakerConfig{Name: "pricing", FailureThreshold: 2, OpenTimeout: time.Minute, Clock: clock})
+	fail := func(context.Context) error { return errDown }
+	ok := func(context.Context) error { return nil }
+
+	_ = b.Do(context.Background(), fail)
+	if got := b.State(); got != StateClosed {
+		t.Fatalf("after 1 failure: %s, want closed", got)
+	}
+	_ = b.Do(context.Background(), fail)
+	if got := b.State(); got != StateOpen {
+		t.Fatalf("after 2 failures: %s, want open", got)
+	}
+	if err := b.Do(context.Background(), ok); errors.GetCode(err) != code.ErrCircuitOpen {
+		t.Fatalf("open: err = %v, want ErrCircuitOpen", err)
+	}
+	clock.Advance(time.Minute)
+	if got := b.State(); got != StateHalfOpen {
+		t.Fatalf("after OpenTimeout: %s, want half-open", got)
+	}
+	if err := b.Do(context.Background(), ok); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.State(); got != StateClosed {
+		t.Fatalf("after a half-open success: %s, want closed", got)
+	}
+}
+
+func TestBreakerPanicFreesHalfOpenSlot(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	b := NewBreaker(BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute, Clock: clock})
+	_ = b.Do(context.Background(), func(context.Context) error { return errDown })
+	clock.Advance(time.Minute)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("the panic was not propagated")
+			}
+		}()
+		_, _ = DoValue(context.Background(), b, func(context.Context) (int, error) { panic("boom") })
+	}()
+	if got := b.State(); got != StateOpen {
+		t.Fatalf("after a half-open panic: %s, want open", got)
+	}
+	clock.Advance(time.Minute)
+	if err := b.Do(context.Background(), func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("half-open trial after a panic: %v", err)
+	}
+}
+
+func TestDefaultIsFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{context.Canceled, false},
+		{errDown, true},
+		{errors.WithCode(code.ErrBadRequest, "bad"), false},
+		{errors.WithCode(code.ErrDatabase, "db"), true},
+		{errors.WithCode(code.ErrTimeout, "slow"), true},
+	}
+	for _, tt := range tests {
+		if got := DefaultIsFailure(tt.err); got != tt.want {
+			t.Errorf("DefaultIsFailure(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package resp
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/labstack/echo/v4"
+)
+
+// BatchResponse is the data of a bulk endpoint response: the outcome of
+// each input item, in input order.
+type BatchResponse struct {
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []BatchResult `json:"results"`
+}
+
+// BatchResult is the outcome of the input item at Index. Failed items
+// have a non-zero Code and the client message of Err; Data is the
+// item's result, or its field errors when validation failed.
+type BatchResult struct {
+	Index int    `json:"index"`
+	Code  int    `json:"code"`
+	Msg   string `json:"msg"`
+	Data  any    `json:"data,omitempty"`
+	// Err is the failure of the item, rendered by BatchJSON into Code,
+	// Msg and Data.
+	Err error `json:"-"`
+}
+
+// ItemError is the failure of the input item at Index, for bulk
+// operations returning one joined error (see BatchFromError).
+type ItemError struct {
+	Index int
+	Err   error
+}
+
+// NewItemError returns err as the failure of the item at index.
+func NewItemError(index int, err error) error {
+	return &ItemError{Index: index, Err: err}
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// BatchResults returns the results of a bulk operation from its outputs
+// and errors, both aligned with the input indices: item i failed when
+// errs[i] is non-nil, and succeeded with data[i] otherwise. data may be
+// nil when items have no output.
+//
+//	users := make([]User, len(req.Items))
+//	errs := make([]error, len(req.Items))
+//	for i, item := range req.Items {
+//	    users[i], errs[i] = svc.Create(ctx, item)
+//	}
+//	return resp.BatchJSON(c, resp.BatchResults(users, errs))
+func BatchResults[T any](data []T, errs []error) []BatchResult {
+	results := make([]BatchResult, max(len(data), len(errs)))
+	for i := range results {
+		results[i].Index = i
+		if i < len(errs) && errs[i] != nil {
+			results[i].Err = errs[i]
+		} else if i < len(data) {
+			results[i].Data = data[i]
+		}
+	}
+	return results
+}
+
+// BatchFromError returns the results of a bulk operation over n items that
+// returned err, a joined error (errors.Join) of ItemErrors. Items without
+// an ItemError succeeded; an error not attributed to an item fails every
+// item without one.
+func BatchFromError(n int, err error) []BatchResult {
+	results := make([]BatchResult, n)
+	for i := range results {
+		results[i].Index = i
+	}
+	var unattributed []error
+	var collect func(err error)
+	collect = func(err error) {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				collect(e)
+			}
+			return
+		}
+		var item *ItemError
+		switch {
+		case err == nil:
+		case errors.As(err, &item) && item.Index >= 0 && item.Index < n:
+			results[item.Index].Err = item.Err
+		default:
+			unattributed = append(unattributed, err)
+		}
+	}
+	collect(err)
+
+	if len(unattributed) > 0 {
+		rest := errors.Join(unattributed...)
+		for i := range results {
+			if results[i].Err == nil {
+				results[i].Err = rest
+			}
+		}
+	}
+	return results
+}
+
+// BatchJSON renders the results of a bulk endpoint as a BatchResponse. The
+// status is 200 when every item succeeded, 207 Multi-Status when some
+// failed, and that of the most frequent error code when all failed (the
+// envelope then carries that code and its message). Failed items get the
+// code and client message APIError would render for their error, so
+// causes and panic values never reach the client.
+func BatchJSON(c echo.Context, results []BatchResult) error {
+	batch := BatchResponse{Results: results}
+	counts := make(map[int]int)
+	dominant := 0
+	for i := range results {
+		r := &results[i]
+		if r.Err == nil {
+			r.Code, r.Msg = 0, "success"
+			batch.Succeeded++
+			continue
+		}
+		batch.Failed++
+
+		r.Code = errors.GetCode(r.Err)
+		if r.Code == 0 {
+			r.Code = code.ErrInternalServer
+		}
+		status := errors.HTTPStatus(r.Code)
+		r.Msg, _ = clientMessage(c.Request().Context(), r.Err, r.Code, status)
+		r.Data = errorData(r.Err)
+		if status >= http.StatusInternalServerError {
+			logError(c, r.Err, r.Code, r.Msg, c.Response().Header().Get(echo.HeaderXRequestID))
+		}
+
+		counts[r.Code]++
+		if counts[r.Code] > counts[dominant] {
+			dominant = r.Code
+		}
+	}
+
+	switch {
+	case batch.Failed == 0:
+		return JSON(c, http.StatusOK, Response{Code: 0, Msg: "success", Data: batch})
+	case batch.Succeeded > 0:
+		slog.Warn("Batch partially failed",
+			slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+			slog.String("uri", c.Request().RequestURI),
+			slog.Int("succeeded", batch.Succeeded),
+			slog.Int("failed", batch.Failed),
+		)
+		return JSON(c, http.StatusMultiStatus, Response{Code: 0, Msg: "partial success", Data: batch})
+	default:
+		status := errors.HTTPStatus(dominant)
+		message, docs := "Internal server error", ""
+		if coder, ok := errors.Lookup(dominant); ok {
+			message, docs = coder.Message(), coder.Reference()
+		}
+		slog.Warn("Batch failed",
+			slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+			slog.String("uri", c.Request().RequestURI),
+			slog.Int("code", dominant),
+			slog.Int("failed", batch.Failed),
+			log.Err(results[0].Err),
+		)
+		return JSON(c, status, Response{Code: dominant, Msg: message, Docs: docs, Data: batch})
+	}
+}
@@ -0,0 +1,154 @@
+package resp
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Meta is what a response carries besides its code, message and data.
+type Meta struct {
+	// Status is the HTTP status of the response.
+	Status int
+	// RequestID is the X-Request-ID of the response, if any.
+	RequestID string
+	// Docs is the developer reference of the error code (see Response.Docs).
+	Docs string
+	// Debug details the error in development (see SetDebug).
+	Debug *Debug
+}
+
+// EnvelopeCodec maps a response to the value encoded as its JSON body. All
+// resp helpers and the error handler write through the codec of the
+// request: the one set with UseEnvelopeCodec or SetRequestCodec, or
+// SetEnvelopeCodec.
+type EnvelopeCodec interface {
+	Encode(code int, msg string, data any, meta Meta) any
+}
+
+// EnvelopeCodecFunc adapts a function to EnvelopeCodec.
+type EnvelopeCodecFunc func(code int, msg string, data any, meta Meta) any
+
+// Encode implements EnvelopeCodec.
+func (f EnvelopeCodecFunc) Encode(code int, msg string, data any, meta Meta) any {
+	return f(code, msg, data, meta)
+}
+
+// Built-in codecs.
+var (
+	// DefaultCodec renders Response: {"code", "msg", "docs", "data",
+	// "debug"}, without empty docs, data and debug.
+	DefaultCodec EnvelopeCodec = EnvelopeCodecFunc(defaultEncode)
+	// VerboseCodec renders {"code", "message", "data", "meta",
+	// "request_id"}: data is always present, null when empty, and meta
+	// holds docs, debug and the total of lists, whose data is the list.
+	VerboseCodec EnvelopeCodec = EnvelopeCodecFunc(verboseEncode)
+	// BareCodec renders the data of 2xx responses without an envelope and
+	// errors as DefaultCodec.
+	BareCodec EnvelopeCodec = EnvelopeCodecFunc(bareEncode)
+)
+
+type codecHolder struct{ codec EnvelopeCodec }
+
+var envelopeCodec atomic.Pointer[codecHolder]
+
+// SetEnvelopeCodec sets the process-wide envelope codec; nil restores
+// DefaultCodec.
+func SetEnvelopeCodec(codec EnvelopeCodec) {
+	if codec == nil {
+		codec = DefaultCodec
+	}
+	envelopeCodec.Store(&codecHolder{codec: codec})
+}
+
+const envelopeCodecKey = "resp.envelope_codec"
+
+// UseEnvelopeCodec returns a middleware that makes the requests it serves
+// use codec instead of the process-wide one, e.g. on a group migrating to
+// another envelope:
+//
+//	v2 := e.Group("/api/v2", resp.UseEnvelopeCodec(resp.VerboseCodec))
+func UseEnvelopeCodec(codec EnvelopeCodec) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			SetRequestCodec(c, codec)
+			return next(c)
+		}
+	}
+}
+
+// SetRequestCodec makes the responses of c use codec instead of the
+// process-wide one, for middleware choosing it per request (see
+// middleware.APIVersionConfig.Codecs).
+func SetRequestCodec(c echo.Context, codec EnvelopeCodec) {
+	c.Set(envelopeCodecKey, codec)
+}
+
+// codecOf returns the envelope codec of c.
+func codecOf(c echo.Context) EnvelopeCodec {
+	if codec, ok := c.Get(envelopeCodecKey).(EnvelopeCodec); ok && codec != nil {
+		return codec
+	}
+	if h := envelopeCodec.Load(); h != nil {
+		return h.codec
+	}
+	return DefaultCodec
+}
+
+// Render returns the JSON body of r sent with status, as encoded by the
+// envelope codec of c, its data adjusted by the JSONOptions of the codec.
+func Render(c echo.Context, status int, r Response) any {
+	codec := codecOf(c)
+	return codec.Encode(r.Code, r.Msg, normalizeData(jsonOptionsOf(codec), r.Data), Meta{
+		Status:    status,
+		RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+		Docs:      r.Docs,
+		Debug:     r.Debug,
+	})
+}
+
+// JSON writes r with status through the envelope codec of c. Use it
+// instead of c.JSON for responses in the envelope format.
+func JSON(c echo.Context, status int, r Response) error {
+	return c.JSON(status, Render(c, status, r))
+}
+
+func defaultEncode(code int, msg string, data any, meta Meta) any {
+	return Response{Code: code, Msg: msg, Docs: meta.Docs, Data: data, Debug: meta.Debug}
+}
+
+// verboseEnvelope is the body of VerboseCodec.
+type verboseEnvelope struct {
+	Code      int          `json:"code"`
+	Message   string       `json:"message"`
+	Data      any          `json:"data"`
+	Meta      *verboseMeta `json:"meta,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+type verboseMeta struct {
+	Total *int64 `json:"total,omitempty"`
+	Docs  string `json:"docs,omitempty"`
+	Debug *Debug `json:"debug,omitempty"`
+}
+
+func verboseEncode(code int, msg string, data any, meta Meta) any {
+	env := verboseEnvelope{Code: code, Message: msg, Data: data, RequestID: meta.RequestID}
+	m := verboseMeta{Docs: meta.Docs, Debug: meta.Debug}
+	if list, ok := data.(ListResponse); ok {
+		env.Data = list.List
+		m.Total = &list.Total
+	}
+	if m != (verboseMeta{}) {
+		env.Meta = &m
+	}
+	return env
+}
+
+func bareEncode(code int, msg string, data any, meta Meta) any {
+	if meta.Status >= http.StatusOK && meta.Status < http.StatusMultipleChoices {
+		return data
+	}
+	return defaultEncode(code, msg, data, meta)
+}
@@ -0,0 +1,321 @@
+package resp
+
+import (
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultExportFlushRows is the default of ExportOptions.FlushRows.
+const DefaultExportFlushRows = 1000
+
+// ExportOptions configure StreamCSV and StreamXLSX.
+type ExportOptions struct {
+	// Filename is the attachment name of the download; none when empty.
+	Filename string
+	// BOM starts CSV output with a UTF-8 byte order mark, so that Excel
+	// reads it as UTF-8.
+	BOM bool
+	// FlushRows is the number of rows between flushes to the client;
+	// default DefaultExportFlushRows.
+	FlushRows int
+}
+
+// Rows produces the rows of an export by calling yield for each, in
+// order, and returns the first error of yield. yield fails once the
+// client is gone, which should stop the producer (see db.ExportRows).
+type Rows func(yield func(row []string) error) error
+
+// TableEncoder writes a table, row by row, to a writer.
+type TableEncoder interface {
+	// WriteRow writes a row; it may be buffered.
+	WriteRow(row []string) error
+	// Flush writes the buffered rows to the writer.
+	Flush() error
+	// Close finishes the document and flushes it.
+	Close() error
+}
+
+// TableFormat is a file format of StreamTable.
+type TableFormat struct {
+	// ContentType is the Content-Type of the response.
+	ContentType string
+	// Compressible allows gzip when the client accepts it.
+	Compressible bool
+	// New returns an encoder writing to w.
+	New func(w io.Writer, opts ExportOptions) TableEncoder
+}
+
+// CSVFormat writes RFC 4180 CSV.
+var CSVFormat = TableFormat{
+	ContentType:  "text/csv; charset=utf-8",
+	Compressible: true,
+	New: func(w io.Writer, opts ExportOptions) TableEncoder {
+		return &csvEncoder{raw: w, w: csv.NewWriter(w), bom: opts.BOM}
+	},
+}
+
+// XLSXFormat writes a single-sheet Excel workbook of text cells.
+var XLSXFormat = TableFormat{
+	ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	New: func(w io.Writer, _ ExportOptions) TableEncoder {
+		return newXLSXEncoder(w)
+	},
+}
+
+// StreamCSV streams a CSV export of header and rows, flushing every
+// opts.FlushRows rows, so that memory stays flat whatever the number of
+// rows. It is gzipped when the client accepts it, unless a compression
+// middleware is in place already.
+//
+//	return resp.StreamCSV(c, []string{"id", "email"},
+//	    db.ExportRows(ctx, gdb.Model(&User{}).Order("id"), 500, func(u User) []string {
+//	        return []string{strconv.Itoa(int(u.ID)), u.Email}
+//	    }), resp.ExportOptions{Filename: "users.csv", BOM: true})
+func StreamCSV(c echo.Context, header []string, rows Rows, opts ...ExportOptions) error {
+	return StreamTable(c, CSVFormat, header, rows, opts...)
+}
+
+// StreamXLSX is StreamCSV writing an Excel workbook.
+func StreamXLSX(c echo.Context, header []string, rows Rows, opts ...ExportOptions) error {
+	return StreamTable(c, XLSXFormat, header, rows, opts...)
+}
+
+// StreamTable streams header and rows in format. Errors of rows before
+// anything was sent are returned for the error handler to render; later
+// ones truncate the download and are returned to be logged.
+func StreamTable(c echo.Context, format TableFormat, header []string, rows Rows, opts ...ExportOptions) error {
+	var o ExportOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.FlushRows <= 0 {
+		o.FlushRows = DefaultExportFlushRows
+	}
+
+	res := c.Response()
+	h := res.Header()
+	h.Set(echo.HeaderContentType, format.ContentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	if o.Filename != "" {
+		h.Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": o.Filename}))
+	}
+
+	// Rows are buffered until the first flush, so that early errors can
+	// still be answered with an error response.
+	out := bufio.NewWriterSize(res, 32<<10)
+	var w io.Writer = out
+	var gz *gzip.Writer
+	if format.Compressible && acceptsGzip(c) {
+		h.Set(echo.HeaderContentEncoding, "gzip")
+		h.Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+		gz = gzip.NewWriter(out)
+		w = gz
+	}
+	enc := format.New(w, o)
+	rc := http.NewResponseController(res.Writer)
+	ctx := c.Request().Context()
+
+	flush := func() error {
+		if err := enc.Flush(); err != nil {
+			return err
+		}
+		if gz != nil {
+			if err := gz.Flush(); err != nil {
+				return err
+			}
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+		_ = rc.Flush()
+		return nil
+	}
+
+	n := 0
+	err := enc.WriteRow(header)
+	if err == nil {
+		err = rows(func(row []string) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := enc.WriteRow(row); err != nil {
+				return err
+			}
+			if n++; n%o.FlushRows == 0 {
+				return flush()
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		if !res.Committed {
+			h.Del(echo.HeaderContentType)
+			h.Del(echo.HeaderContentDisposition)
+			h.Del(echo.HeaderContentEncoding)
+		}
+		return err
+	}
+
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	if !res.Committed {
+		// An empty body still answers 200.
+		res.WriteHeader(http.StatusOK)
+	}
+	return nil
+}
+
+// acceptsGzip reports whether the response may be gzipped here: the
+// client accepts it and no compression middleware (which adds Vary:
+// Accept-Encoding first) is in place.
+func acceptsGzip(c echo.Context) bool {
+	for _, v := range c.Response().Header().Values(echo.HeaderVary) {
+		if strings.Contains(strings.ToLower(v), "accept-encoding") {
+			return false
+		}
+	}
+	for _, part := range strings.Split(c.Request().Header.Get(echo.HeaderAcceptEncoding), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				q, err := strconv.ParseFloat(v, 64)
+				return err == nil && q > 0
+			}
+			return true
+		}
+	}
+	return false
+}
+
+type csvEncoder struct {
+	raw io.Writer
+	w   *csv.Writer
+	bom bool
+}
+
+func (e *csvEncoder) WriteRow(row []string) error {
+	if e.bom {
+		e.bom = false
+		if _, err := io.WriteString(e.raw, "\uFEFF"); err != nil {
+			return err
+		}
+	}
+	return e.w.Write(row)
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) Close() error {
+	return e.Flush()
+}
+
+// xlsxEncoder streams a workbook: the fixed parts are written first, then
+// the rows of the sheet, which is the last entry of the archive.
+type xlsxEncoder struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	err   error
+	rows  int
+}
+
+// xlsxParts are the parts of a single-sheet workbook besides the sheet.
+var xlsxParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+}
+
+func newXLSXEncoder(w io.Writer) *xlsxEncoder {
+	e := &xlsxEncoder{zw: zip.NewWriter(w)}
+	for _, p := range xlsxParts {
+		f, err := e.zw.Create(p.name)
+		if err != nil {
+			e.err = err
+			return e
+		}
+		if _, err := io.WriteString(f, p.body); err != nil {
+			e.err = err
+			return e
+		}
+	}
+	f, err := e.zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		e.err = err
+		return e
+	}
+	e.sheet = bufio.NewWriter(f)
+	_, e.err = e.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return e
+}
+
+func (e *xlsxEncoder) WriteRow(row []string) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.rows++
+	b := e.sheet
+	fmt.Fprintf(b, `<row r="%d">`, e.rows)
+	for _, cell := range row {
+		b.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		if err := xml.EscapeText(b, []byte(cell)); err != nil {
+			e.err = err
+			return err
+		}
+		b.WriteString(`</t></is></c>`)
+	}
+	_, e.err = b.WriteString(`</row>`)
+	return e.err
+}
+
+func (e *xlsxEncoder) Flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.err = e.sheet.Flush(); e.err != nil {
+		return e.err
+	}
+	e.err = e.zw.Flush()
+	return e.err
+}
+
+func (e *xlsxEncoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if _, err := e.sheet.WriteString(`</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+	return e.zw.Close()
+}
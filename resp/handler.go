@@ -0,0 +1,128 @@
+package resp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// StatusCoder may be implemented by a handler result to override the HTTP
+// status of the success response (e.g. http.StatusCreated).
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// Locationer may be implemented by a handler result to set the Location
+// header of the success response.
+type Locationer interface {
+	Location() string
+}
+
+// Empty is the request type of handlers that take no input.
+type Empty struct{}
+
+// Handler adapts a typed function to an echo handler. The request is bound
+// (path, query and body) into Req and validated with the echo validator,
+// the context is built via utils.BuildContext, and the result is rendered
+// with the unified envelope. Errors are returned to the centralized error
+// handler: bind failures as ErrBind, validation failures as ErrValidation.
+//
+//	type CreateUserReq struct {
+//	    Name string `json:"name" validate:"required"`
+//	}
+//
+//	type Created struct{ ID string `json:"id"` }
+//
+//	func (Created) StatusCode() int    { return http.StatusCreated }
+//	func (r Created) Location() string { return "/api/users/" + r.ID }
+//
+//	g.POST("/users", resp.Handler(svc.CreateUser))
+func Handler[Req, Res any](fn func(ctx context.Context, req Req) (Res, error)) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req, err := bindRequest[Req](c)
+		if err != nil {
+			return err
+		}
+		res, err := fn(utils.BuildContext(c), req)
+		if err != nil {
+			return err
+		}
+		return writeResult(c, res)
+	}
+}
+
+// ListHandler is Handler for list endpoints returning items and a total,
+// rendered like ListDataResponse.
+func ListHandler[Req, Item any](fn func(ctx context.Context, req Req) ([]Item, int64, error)) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req, err := bindRequest[Req](c)
+		if err != nil {
+			return err
+		}
+		items, total, err := fn(utils.BuildContext(c), req)
+		if err != nil {
+			return err
+		}
+		if items == nil {
+			items = []Item{}
+		}
+		return ListDataResponse(c, items, total)
+	}
+}
+
+// NoContentHandler is Handler for operations without a result, rendered
+// like OperateSuccess.
+func NoContentHandler[Req any](fn func(ctx context.Context, req Req) error) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req, err := bindRequest[Req](c)
+		if err != nil {
+			return err
+		}
+		if err := fn(utils.BuildContext(c), req); err != nil {
+			return err
+		}
+		return OperateSuccess(c)
+	}
+}
+
+// bindRequest binds and validates the request into a new Req.
+func bindRequest[Req any](c echo.Context) (Req, error) {
+	var req Req
+	if _, ok := any(req).(Empty); ok {
+		return req, nil
+	}
+	if err := c.Bind(&req); err != nil {
+		// Keep the codes of binders rejecting a body outright, such as the
+		// ErrBadRequest of validator.CheckJSON.
+		if ec := errors.GetCode(err); ec != 0 && ec != code.ErrBind {
+			return req, err
+		}
+		return req, code.WrapError(err, code.ErrBind, "invalid request")
+	}
+	if c.Echo().Validator != nil {
+		if err := c.Validate(&req); err != nil {
+			return req, code.WrapError(err, code.ErrValidation, err.Error())
+		}
+	}
+	return req, nil
+}
+
+// writeResult renders res honoring StatusCoder and Locationer.
+func writeResult(c echo.Context, res any) error {
+	status := http.StatusOK
+	if sc, ok := res.(StatusCoder); ok && sc.StatusCode() != 0 {
+		status = sc.StatusCode()
+	}
+	if l, ok := res.(Locationer); ok && l.Location() != "" {
+		c.Response().Header().Set(echo.HeaderLocation, l.Location())
+	}
+	return JSON(c, status, Response{
+		Code: 0,
+		Msg:  "success",
+		Data: res,
+	})
+}
@@ -0,0 +1,779 @@
+package resp
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/NSObjects/go-kit/utils"
+)
+
+// JSONOptions adjust the JSON encoding of response data for clients that
+// do not cope with the defaults of encoding/json, e.g. JavaScript losing
+// the precision of int64 IDs or failing on null lists. The zero
+// JSONOptions encodes data as encoding/json does.
+//
+//	resp.SetJSONOptions(resp.JSONOptions{Int64AsString: true, EmptySlices: true})
+//
+// Types implementing json.Marshaler or encoding.TextMarshaler keep their
+// own encoding; kit.ID is a string whatever the options.
+type JSONOptions struct {
+	// Int64AsString renders int64 and uint64 values as JSON strings, as
+	// the ",string" tag option does.
+	Int64AsString bool
+	// EmptySlices renders nil slices as [] instead of null. Fields tagged
+	// omitempty are still omitted; []byte is still null.
+	EmptySlices bool
+	// UseTimeConfig renders time.Time values in the location and format of
+	// utils.SetTimeConfig, as utils.Time does: the zero time is null.
+	UseTimeConfig bool
+}
+
+var jsonOptions atomic.Pointer[JSONOptions]
+
+// SetJSONOptions sets the process-wide JSONOptions of response data. They
+// apply to every resp helper (SuccessJSON, ListDataResponse, BatchJSON,
+// APIError...) except under codecs with their own (see WithJSONOptions).
+func SetJSONOptions(opts JSONOptions) {
+	jsonOptions.Store(&opts)
+}
+
+// JSONOptionsCodec is an EnvelopeCodec with its own JSONOptions, used
+// instead of the process-wide ones for the responses it encodes.
+type JSONOptionsCodec interface {
+	EnvelopeCodec
+	JSONOptions() JSONOptions
+}
+
+// WithJSONOptions returns codec encoding data with opts, e.g. for a group
+// of routes serving browsers:
+//
+//	web := e.Group("/web", resp.UseEnvelopeCodec(resp.WithJSONOptions(resp.DefaultCodec,
+//	    resp.JSONOptions{Int64AsString: true})))
+func WithJSONOptions(codec EnvelopeCodec, opts JSONOptions) EnvelopeCodec {
+	return optionsCodec{EnvelopeCodec: codec, opts: opts}
+}
+
+type optionsCodec struct {
+	EnvelopeCodec
+	opts JSONOptions
+}
+
+func (c optionsCodec) JSONOptions() JSONOptions { return c.opts }
+
+// jsonOptionsOf returns the JSONOptions of codec.
+func jsonOptionsOf(codec EnvelopeCodec) JSONOptions {
+	if c, ok := codec.(JSONOptionsCodec); ok {
+		return c.JSONOptions()
+	}
+	if o := jsonOptions.Load(); o != nil {
+		return *o
+	}
+	return JSONOptions{}
+}
+
+// normalizeData applies opts to the data of a response. The list of a
+// ListResponse is normalized in place so codecs still see the
+// ListResponse; its total stays a number.
+func normalizeData(opts JSONOptions, data any) any {
+	if opts == (JSONOptions{}) || data == nil {
+		return data
+	}
+	if list, ok := data.(ListResponse); ok {
+		list.List = normalizeJSON(opts, list.List)
+		return list
+	}
+	return normalizeJSON(opts, data)
+}
+
+// normalizeJSON returns v encoded with opts as a json.RawMessage, or v
+// itself when opts change nothing in its type. Values the encoder cannot
+// handle are returned as they are, for json.Marshal to encode or report.
+func normalizeJSON(opts JSONOptions, v any) any {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	p := planFor(rv.Type(), opts)
+	if p.plain {
+		return v
+	}
+	e := jsonEncoder{opts: opts}
+	if err := e.encode(rv, p); err != nil {
+		return v
+	}
+	return json.RawMessage(e.buf)
+}
+
+// planKind is how a jsonPlan encodes its values.
+type planKind uint8
+
+const (
+	planMarshal   planKind = iota // by encoding/json
+	planInt64                     // as a quoted integer
+	planTime                      // as utils.Time
+	planPointer                   // nil as null, else the element
+	planInterface                 // by the plan of the dynamic type
+	planSlice                     // nil as [] or null, else as planArray
+	planArray                     // elements in order
+	planMap                       // entries sorted by key
+	planStruct                    // fields resolved as encoding/json does
+)
+
+// jsonPlan is the encoding of a type under given JSONOptions, computed
+// once per type (see planFor).
+type jsonPlan struct {
+	kind planKind
+	// plain reports whether the options change nothing in the type, so
+	// encoding/json encodes its values whole. It is false while the plan
+	// is being built, making recursive types conservatively not plain.
+	plain bool
+	// scalar marks plain integers, booleans and strings, written without
+	// encoding/json.
+	scalar bool
+	elem   *jsonPlan
+	fields []jsonField
+}
+
+// jsonField is a field of a planStruct.
+type jsonField struct {
+	name      string
+	key       []byte // JSON-encoded name and colon
+	index     []int
+	tagged    bool
+	omitEmpty bool
+	omitZero  bool
+	quoted    bool
+	plan      *jsonPlan
+}
+
+type planKey struct {
+	t    reflect.Type
+	opts JSONOptions
+}
+
+var (
+	plans   sync.Map // planKey -> *jsonPlan
+	plansMu sync.Mutex
+
+	timeType          = reflect.TypeFor[time.Time]()
+	marshalerType     = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// planFor returns the plan of t under opts.
+func planFor(t reflect.Type, opts JSONOptions) *jsonPlan {
+	if p, ok := plans.Load(planKey{t, opts}); ok {
+		return p.(*jsonPlan)
+	}
+	plansMu.Lock()
+	defer plansMu.Unlock()
+	building := map[reflect.Type]*jsonPlan{}
+	p := buildPlan(t, opts, building)
+	for bt, bp := range building {
+		plans.Store(planKey{bt, opts}, bp)
+	}
+	return p
+}
+
+func buildPlan(t reflect.Type, opts JSONOptions, building map[reflect.Type]*jsonPlan) *jsonPlan {
+	if p, ok := plans.Load(planKey{t, opts}); ok {
+		return p.(*jsonPlan)
+	}
+	if p, ok := building[t]; ok {
+		return p
+	}
+	p := &jsonPlan{}
+	building[t] = p
+
+	switch {
+	case t == timeType && opts.UseTimeConfig:
+		p.kind = planTime
+	case implementsMarshaler(t):
+		p.plain = true
+	default:
+		switch t.Kind() {
+		case reflect.Int64, reflect.Uint64:
+			p.kind, p.plain, p.scalar = planInt64, !opts.Int64AsString, !opts.Int64AsString
+		case reflect.Pointer:
+			p.kind, p.elem = planPointer, buildPlan(t.Elem(), opts, building)
+			p.plain = p.elem.plain
+		case reflect.Interface:
+			p.kind = planInterface
+		case reflect.Slice:
+			if t.Elem().Kind() == reflect.Uint8 && !implementsMarshaler(t.Elem()) {
+				p.plain = true // base64 string
+				break
+			}
+			p.kind, p.elem = planSlice, buildPlan(t.Elem(), opts, building)
+			p.plain = p.elem.plain && !opts.EmptySlices
+		case reflect.Array:
+			p.kind, p.elem = planArray, buildPlan(t.Elem(), opts, building)
+			p.plain = p.elem.plain
+		case reflect.Map:
+			p.kind, p.elem = planMap, buildPlan(t.Elem(), opts, building)
+			p.plain = p.elem.plain
+		case reflect.Struct:
+			p.kind, p.fields = planStruct, structFields(t)
+			plain := true
+			for i := range p.fields {
+				f := &p.fields[i]
+				f.plan = buildPlan(t.FieldByIndex(f.index).Type, opts, building)
+				plain = plain && f.plan.plain
+			}
+			p.plain = plain
+		case reflect.Bool, reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uintptr:
+			p.plain, p.scalar = true, true
+		default:
+			p.plain = true
+		}
+	}
+	if p.plain {
+		p.kind, p.elem, p.fields = planMarshal, nil, nil
+	}
+	return p
+}
+
+// implementsMarshaler reports whether encoding/json encodes t, or *t when
+// addressable, with its own method.
+func implementsMarshaler(t reflect.Type) bool {
+	for _, it := range []reflect.Type{marshalerType, textMarshalerType} {
+		if t.Implements(it) || (t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(it)) {
+			return true
+		}
+	}
+	return false
+}
+
+// structFields returns the JSON fields of t in encoding order, resolving
+// embedded structs and name conflicts as encoding/json does.
+func structFields(t reflect.Type) []jsonField {
+	type entry struct {
+		typ   reflect.Type
+		index []int
+	}
+	var fields []jsonField
+	next := []entry{{typ: t}}
+	var count, nextCount map[reflect.Type]int
+	visited := map[reflect.Type]bool{}
+	for len(next) > 0 {
+		current := next
+		next = nil
+		count, nextCount = nextCount, map[reflect.Type]int{}
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			visited[e.typ] = true
+			for i := range e.typ.NumField() {
+				sf := e.typ.Field(i)
+				if sf.Anonymous {
+					ft := sf.Type
+					if ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, options, _ := strings.Cut(tag, ",")
+				index := append(slices.Clone(e.index), i)
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					f := jsonField{
+						name:      name,
+						index:     index,
+						tagged:    name != "",
+						omitEmpty: hasTagOption(options, "omitempty"),
+						omitZero:  hasTagOption(options, "omitzero"),
+					}
+					if f.name == "" {
+						f.name = sf.Name
+					}
+					if hasTagOption(options, "string") {
+						switch ft.Kind() {
+						case reflect.Bool, reflect.String,
+							reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+							reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+							reflect.Float32, reflect.Float64:
+							f.quoted = true
+						}
+					}
+					f.key = append(appendString(nil, f.name), ':')
+					fields = append(fields, f)
+					// A type embedded twice at this depth: duplicate the
+					// field so the conflict removes it.
+					if count[e.typ] > 1 {
+						fields = append(fields, f)
+					}
+					continue
+				}
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, entry{typ: ft, index: index})
+				}
+			}
+		}
+	}
+
+	// Of the fields sharing a name, the shallowest wins, then the tagged
+	// one; any other tie hides them all.
+	slices.SortStableFunc(fields, func(a, b jsonField) int {
+		if c := strings.Compare(a.name, b.name); c != 0 {
+			return c
+		}
+		if c := len(a.index) - len(b.index); c != 0 {
+			return c
+		}
+		if a.tagged != b.tagged {
+			if a.tagged {
+				return -1
+			}
+			return 1
+		}
+		return slices.Compare(a.index, b.index)
+	})
+	out := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		group := fields[i:j]
+		if len(group) == 1 || len(group[0].index) != len(group[1].index) || group[0].tagged != group[1].tagged {
+			out = append(out, group[0])
+		}
+		i = j
+	}
+	slices.SortFunc(out, func(a, b jsonField) int { return slices.Compare(a.index, b.index) })
+	return out
+}
+
+func hasTagOption(options, name string) bool {
+	for options != "" {
+		var opt string
+		opt, options, _ = strings.Cut(options, ",")
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+// errInaccessible reports a value reflection cannot hand out, which
+// json.Marshal then encodes on its own.
+var errInaccessible = fmt.Errorf("resp: inaccessible value")
+
+// errCycle reports a value referring to itself, which json.Marshal then
+// reports as it does.
+var errCycle = fmt.Errorf("resp: cyclic value")
+
+// startDetectingCyclesAfter is the depth of pointers, maps and slices
+// after which the encoder tracks the ones it is in, as encoding/json does,
+// so that acyclic values pay nothing for it.
+const startDetectingCyclesAfter = 1000
+
+// jsonEncoder writes values along their plans.
+type jsonEncoder struct {
+	opts JSONOptions
+	buf  []byte
+	// depth is the number of pointers, maps and slices being encoded;
+	// seen holds those past startDetectingCyclesAfter.
+	depth int
+	seen  map[any]struct{}
+}
+
+// enter records that the encoder descends into the pointer, map or slice
+// v, failing with errCycle when it is already in it. leave undoes it.
+func (e *jsonEncoder) enter(v reflect.Value) error {
+	if e.depth++; e.depth <= startDetectingCyclesAfter {
+		return nil
+	}
+	key := refKey(v)
+	if _, ok := e.seen[key]; ok {
+		return errCycle
+	}
+	if e.seen == nil {
+		e.seen = map[any]struct{}{}
+	}
+	e.seen[key] = struct{}{}
+	return nil
+}
+
+func (e *jsonEncoder) leave(v reflect.Value) {
+	if e.depth--; e.depth >= startDetectingCyclesAfter {
+		delete(e.seen, refKey(v))
+	}
+}
+
+// refKey identifies the pointer, map or slice v; slices by their length
+// too, as a slice of themselves is not a cycle.
+func refKey(v reflect.Value) any {
+	if v.Kind() == reflect.Slice {
+		return [2]uintptr{v.Pointer(), uintptr(v.Len())}
+	}
+	return v.Pointer()
+}
+
+func (e *jsonEncoder) encode(v reflect.Value, p *jsonPlan) error {
+	if p.scalar {
+		e.appendScalar(v)
+		return nil
+	}
+	if p.plain {
+		return e.marshal(v)
+	}
+	switch p.kind {
+	case planInt64:
+		e.buf = append(e.buf, '"')
+		e.appendScalar(v)
+		e.buf = append(e.buf, '"')
+	case planTime:
+		v, err := accessible(v)
+		if err != nil {
+			return err
+		}
+		b, _ := utils.NewTime(v.Interface().(time.Time)).MarshalJSON()
+		e.buf = append(e.buf, b...)
+	case planPointer:
+		if v.IsNil() {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+		if err := e.enter(v); err != nil {
+			return err
+		}
+		defer e.leave(v)
+		return e.encode(v.Elem(), p.elem)
+	case planInterface:
+		if v.IsNil() {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+		v = v.Elem()
+		return e.encode(v, planFor(v.Type(), e.opts))
+	case planSlice:
+		if v.IsNil() {
+			if e.opts.EmptySlices {
+				e.buf = append(e.buf, "[]"...)
+			} else {
+				e.buf = append(e.buf, "null"...)
+			}
+			return nil
+		}
+		if p.elem.plain {
+			return e.marshal(v)
+		}
+		if err := e.enter(v); err != nil {
+			return err
+		}
+		defer e.leave(v)
+		return e.encodeArray(v, p)
+	case planArray:
+		return e.encodeArray(v, p)
+	case planMap:
+		return e.encodeMap(v, p)
+	case planStruct:
+		return e.encodeStruct(v, p)
+	}
+	return nil
+}
+
+// appendScalar appends the integer, boolean or string v.
+func (e *jsonEncoder) appendScalar(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		e.buf = appendString(e.buf, v.String())
+	case reflect.Bool:
+		e.buf = strconv.AppendBool(e.buf, v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.buf = strconv.AppendInt(e.buf, v.Int(), 10)
+	default:
+		e.buf = strconv.AppendUint(e.buf, v.Uint(), 10)
+	}
+}
+
+// marshal appends the encoding/json encoding of v.
+func (e *jsonEncoder) marshal(v reflect.Value) error {
+	v, err := accessible(v)
+	if err != nil {
+		return err
+	}
+	if v.CanAddr() && v.Kind() != reflect.Pointer {
+		v = v.Addr() // for methods with pointer receivers
+	}
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	e.buf = append(e.buf, b...)
+	return nil
+}
+
+func (e *jsonEncoder) encodeArray(v reflect.Value, p *jsonPlan) error {
+	e.buf = append(e.buf, '[')
+	for i := range v.Len() {
+		if i > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		if err := e.encode(v.Index(i), p.elem); err != nil {
+			return err
+		}
+	}
+	e.buf = append(e.buf, ']')
+	return nil
+}
+
+func (e *jsonEncoder) encodeMap(v reflect.Value, p *jsonPlan) error {
+	if v.IsNil() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	if err := e.enter(v); err != nil {
+		return err
+	}
+	defer e.leave(v)
+	type entry struct {
+		key string
+		v   reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	for iter := v.MapRange(); iter.Next(); {
+		key, err := mapKey(iter.Key())
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{key, iter.Value()})
+	}
+	slices.SortFunc(entries, func(a, b entry) int { return strings.Compare(a.key, b.key) })
+
+	e.buf = append(e.buf, '{')
+	for i, en := range entries {
+		if i > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = append(appendString(e.buf, en.key), ':')
+		if err := e.encode(en.v, p.elem); err != nil {
+			return err
+		}
+	}
+	e.buf = append(e.buf, '}')
+	return nil
+}
+
+// mapKey returns the JSON object key of k, as encoding/json does.
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if k.Type().Implements(textMarshalerType) {
+		k, err := accessible(k)
+		if err != nil {
+			return "", err
+		}
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", nil
+		}
+		b, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("resp: unsupported map key type %s", k.Type())
+}
+
+func (e *jsonEncoder) encodeStruct(v reflect.Value, p *jsonPlan) error {
+	e.buf = append(e.buf, '{')
+	first := true
+	for i := range p.fields {
+		f := &p.fields[i]
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || (f.omitEmpty && isEmptyValue(fv)) || (f.omitZero && isZeroValue(fv)) {
+			continue
+		}
+		if !first {
+			e.buf = append(e.buf, ',')
+		}
+		first = false
+		e.buf = append(e.buf, f.key...)
+		if err := e.encodeField(fv, f); err != nil {
+			return err
+		}
+	}
+	e.buf = append(e.buf, '}')
+	return nil
+}
+
+// encodeField encodes a field value, quoting it for the ",string" tag
+// option.
+func (e *jsonEncoder) encodeField(v reflect.Value, f *jsonField) error {
+	if !f.quoted {
+		return e.encode(v, f.plan)
+	}
+	p := f.plan
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+		v, p = v.Elem(), p.elem
+		if p == nil {
+			p = planFor(v.Type(), e.opts)
+		}
+	}
+	switch {
+	case v.Kind() == reflect.String:
+		e.buf = appendString(e.buf, string(appendString(nil, v.String())))
+		return nil
+	case p.kind == planInt64 && !p.plain:
+		return e.encode(v, p)
+	}
+	e.buf = append(e.buf, '"')
+	if err := e.encode(v, p); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, '"')
+	return nil
+}
+
+// appendString appends s as a JSON string escaped as encoding/json does:
+// HTML characters and U+2028/U+2029 as \u escapes, invalid UTF-8 replaced
+// by U+FFFD.
+func appendString(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\b':
+				b = append(b, '\\', 'b')
+			case '\f':
+				b = append(b, '\\', 'f')
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
+
+// fieldByIndex returns the field of v at index, through embedded
+// pointers; false when one of them is nil.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// accessible returns v if it is usable with Interface, else
+// errInaccessible.
+func accessible(v reflect.Value) (reflect.Value, error) {
+	if v.CanInterface() {
+		return v, nil
+	}
+	return v, errInaccessible
+}
+
+// isEmptyValue reports whether omitempty omits v.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+type isZeroer interface{ IsZero() bool }
+
+// isZeroValue reports whether omitzero omits v: by its IsZero method if
+// it has one.
+func isZeroValue(v reflect.Value) bool {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return true
+	}
+	if av, err := accessible(v); err == nil {
+		if z, ok := av.Interface().(isZeroer); ok {
+			return z.IsZero()
+		}
+		if av.CanAddr() {
+			if z, ok := av.Addr().Interface().(isZeroer); ok {
+				return z.IsZero()
+			}
+		}
+	}
+	return v.IsZero()
+}
@@ -0,0 +1,63 @@
+package resp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type jsonNode struct {
+	ID       int64
+	Parent   *jsonNode
+	Children []*jsonNode
+}
+
+func TestNormalizeJSONCycle(t *testing.T) {
+	opts := JSONOptions{Int64AsString: true, EmptySlices: true}
+
+	n := &jsonNode{ID: 1}
+	n.Parent = n
+	if _, ok := normalizeJSON(opts, n).(json.RawMessage); ok {
+		t.Fatal("cyclic pointer: encoded, want the value back for json.Marshal")
+	}
+	if _, err := json.Marshal(normalizeJSON(opts, n)); err == nil {
+		t.Fatal("cyclic pointer: json.Marshal succeeded, want an error")
+	}
+
+	m := &jsonNode{ID: 2}
+	m.Children = []*jsonNode{m}
+	if _, err := json.Marshal(normalizeJSON(opts, m)); err == nil {
+		t.Fatal("cyclic slice: json.Marshal succeeded, want an error")
+	}
+}
+
+func TestNormalizeJSONDeepAcyclic(t *testing.T) {
+	opts := JSONOptions{Int64AsString: true}
+	root := &jsonNode{ID: 0}
+	for n, i := root, int64(1); i <= 3*startDetectingCyclesAfter; i++ {
+		n.Parent = &jsonNode{ID: i}
+		n = n.Parent
+	}
+	if _, ok := normalizeJSON(opts, root).(json.RawMessage); !ok {
+		t.Fatal("deep acyclic value: not encoded")
+	}
+}
+
+type jsonHidden struct{ Secret string }
+
+type jsonOuter struct {
+	jsonHidden `json:"hidden"`
+	ID         int64
+}
+
+// TestNormalizeJSONUnexportedEmbedded checks that a value reflection does
+// not hand out falls back to json.Marshal whole.
+func TestNormalizeJSONUnexportedEmbedded(t *testing.T) {
+	opts := JSONOptions{Int64AsString: true}
+	b, err := json.Marshal(normalizeJSON(opts, jsonOuter{jsonHidden{"s"}, 7}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"hidden":{"Secret":"s"},"ID":7}`; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
@@ -2,11 +2,16 @@
 package resp
 
 import (
+	"context"
+	"encoding/json"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/NSObjects/go-kit/code"
 	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/log"
 	"github.com/labstack/echo/v4"
 )
 
@@ -14,7 +19,27 @@ import (
 type Response struct {
 	Code int    `json:"code"`
 	Msg  string `json:"msg"`
+	// Docs is the developer reference of the error code, if registered
+	// (see errors.RegisterFull).
+	Docs string `json:"docs,omitempty"`
 	Data any    `json:"data,omitempty"`
+	// Debug details the error in development (see SetDebug).
+	Debug *Debug `json:"debug,omitempty"`
+}
+
+// Debug is the developer detail of an error response: the full error
+// text and the stack where it was created.
+type Debug struct {
+	Error string   `json:"error"`
+	Stack []string `json:"stack,omitempty"`
+}
+
+const debugKey = "resp.debug"
+
+// SetDebug makes APIError add the Debug field to error responses of c.
+// Only for development: it leaks implementation details.
+func SetDebug(c echo.Context, on bool) {
+	c.Set(debugKey, on)
 }
 
 // ListResponse is the response structure for list endpoints.
@@ -25,7 +50,7 @@ type ListResponse struct {
 
 // SuccessJSON returns a success response with custom data.
 func SuccessJSON(c echo.Context, data any) error {
-	return c.JSON(http.StatusOK, Response{
+	return JSON(c, http.StatusOK, Response{
 		Code: 0,
 		Msg:  "success",
 		Data: data,
@@ -47,12 +72,51 @@ func OneDataResponse(c echo.Context, data any) error {
 
 // OperateSuccess returns a success response for operations.
 func OperateSuccess(c echo.Context) error {
-	return c.JSON(http.StatusOK, Response{
+	return JSON(c, http.StatusOK, Response{
 		Code: 0,
 		Msg:  "success",
 	})
 }
 
+// JobStatusRoute is the route name AcceptedJob links to. Name the status
+// route of the jobs with it:
+//
+//	e.GET("/api/jobs/:id", jobs.StatusHandler()).Name = resp.JobStatusRoute
+const JobStatusRoute = "job_status"
+
+// JobAccepted is the data of AcceptedJob responses.
+type JobAccepted struct {
+	JobID     string `json:"job_id"`
+	StatusURL string `json:"status_url"`
+}
+
+// AcceptedJob returns 202 Accepted for an operation running as job jobID
+// (see async.JobStore), with a Location header to its status route
+// (JobStatusRoute, or /jobs/:id if no route has that name).
+func AcceptedJob(c echo.Context, jobID string) error {
+	location := c.Echo().Reverse(JobStatusRoute, url.PathEscape(jobID))
+	if location == "" {
+		location = "/jobs/" + url.PathEscape(jobID)
+	}
+	c.Response().Header().Set(echo.HeaderLocation, location)
+	return JSON(c, http.StatusAccepted, Response{
+		Code: 0,
+		Msg:  "accepted",
+		Data: JobAccepted{JobID: jobID, StatusURL: location},
+	})
+}
+
+// ClientError returns the code and message APIError would send for err,
+// for errors reported outside the request that failed, e.g. by jobs.
+func ClientError(err error) (errorCode int, message string) {
+	errorCode = errors.GetCode(err)
+	if errorCode == 0 {
+		errorCode = code.ErrInternalServer
+	}
+	message, _ = clientMessage(context.Background(), err, errorCode, errors.HTTPStatus(errorCode))
+	return errorCode, message
+}
+
 // APIError returns an error response.
 func APIError(c echo.Context, err error) error {
 	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
@@ -64,20 +128,100 @@ func APIError(c echo.Context, err error) error {
 	}
 
 	httpStatus := errors.HTTPStatus(errorCode)
-	message := err.Error()
-
-	// Try to get registered message
-	if coder, ok := errors.Lookup(errorCode); ok {
-		message = coder.Message()
+	// The client is gone; record the status for logs and metrics but
+	// write no body.
+	if errorCode == code.ErrClientClosedRequest {
+		slog.Debug("Client closed request",
+			slog.String("request_id", requestID),
+			slog.String("method", c.Request().Method),
+			slog.String("uri", c.Request().RequestURI),
+			log.Err(err),
+		)
+		c.Response().Status = httpStatus
+		return nil
 	}
+	message, docs := clientMessage(c.Request().Context(), err, errorCode, httpStatus)
 
 	// Log the error
 	logError(c, err, errorCode, message, requestID)
 
-	return c.JSON(httpStatus, Response{
+	r := Response{
 		Code: errorCode,
 		Msg:  message,
-	})
+		Docs: docs,
+		Data: errorData(err),
+	}
+	if on, _ := c.Get(debugKey).(bool); on {
+		r.Debug = &Debug{Error: err.Error()}
+		for _, line := range strings.Split(errors.FormatStack(errors.GetStackTrace(err)), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				r.Debug.Stack = append(r.Debug.Stack, line)
+			}
+		}
+	}
+	return JSON(c, httpStatus, r)
+}
+
+// clientMessage returns the message and developer reference shown to
+// clients for err: the registered user message, translated for the locale
+// of ctx (see errors.MessageFor), or for client errors the one attached
+// with errors.WithUserMessage. err.Error() may carry causes and is only
+// logged. Unregistered codes are server errors.
+func clientMessage(ctx context.Context, err error, errorCode, httpStatus int) (message, docs string) {
+	message = internalMessage(ctx)
+	if coder, ok := errors.Lookup(errorCode); ok {
+		message, docs = errors.MessageFor(ctx, errorCode), coder.Reference()
+	}
+	if msg, ok := errors.UserMessage(err); ok && httpStatus < http.StatusInternalServerError {
+		message = msg
+	}
+	// Never leak panic values to clients.
+	if errors.IsPanic(err) {
+		message = internalMessage(ctx)
+	}
+	return message, docs
+}
+
+// internalMessage is the message of server errors without a registered
+// message of their own.
+func internalMessage(ctx context.Context) string {
+	if msg := errors.MessageFor(ctx, code.ErrInternalServer); msg != "" {
+		return msg
+	}
+	return "Internal server error"
+}
+
+// errorData returns the data of an error response: field errors, which
+// are safe to show and tell the client what to fix, the details of a
+// client error (see errors.WithDetails), or nil.
+func errorData(err error) any {
+	var fields code.ValidationErrors
+	if errors.As(err, &fields) {
+		return fields
+	}
+	if details, ok := errors.Details(err); ok && errors.HTTPStatus(errors.GetCode(err)) < http.StatusInternalServerError {
+		return details
+	}
+	return nil
+}
+
+// DecodeError decodes a response body in the Response envelope format (or
+// that of VerboseCodec) and returns it as a coded error carrying the remote
+// code and message. Returns nil if data is not an envelope or represents
+// success (code 0).
+func DecodeError(data []byte) error {
+	var r struct {
+		Code    *int   `json:"code"`
+		Msg     string `json:"msg"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &r); err != nil || r.Code == nil || *r.Code == 0 {
+		return nil
+	}
+	if r.Msg == "" {
+		r.Msg = r.Message
+	}
+	return errors.WithCode(*r.Code, "%s", r.Msg)
 }
 
 // logError logs an error with context.
@@ -91,8 +235,8 @@ func logError(c echo.Context, err error, errorCode int, message, requestID strin
 	}
 
 	if code.IsServerError(errorCode) {
-		slog.Error("Server error", append(logFields, slog.String("error", err.Error()))...)
+		slog.Error("Server error", append(logFields, log.Err(err))...)
 	} else {
-		slog.Warn("Client error", logFields...)
+		slog.Warn("Client error", append(logFields, slog.String("error", err.Error()))...)
 	}
 }
@@ -0,0 +1,344 @@
+package kit
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/NSObjects/go-kit/config"
+	"github.com/labstack/echo/v4"
+)
+
+// typedHandlerPrefix is the name prefix of the handlers built by the typed
+// adapters of package resp (resp.Handler, ListHandler, NoContentHandler).
+const typedHandlerPrefix = "github.com/NSObjects/go-kit/resp."
+
+// RouteMatcher selects routes by method and route path;
+// *middleware.PathMatcher implements it.
+type RouteMatcher interface {
+	Match(method, path string) bool
+}
+
+// RouteRule is an invariant of the routes selected by Routes, checked by
+// VerifyRoutes:
+//
+//	rules := []kit.RouteRule{
+//	    {Name: "admin routes are authorized", Routes: middleware.MustPathMatcher("/api/admin/*"), Require: []string{"jwt", "casbin"}},
+//	    {Name: "API routes are typed", Routes: middleware.MustPathMatcher("/api/*"), Typed: true, Unique: true},
+//	}
+type RouteRule struct {
+	// Name describes the invariant in violations.
+	Name string
+	// Routes selects the routes of the rule; nil selects every route.
+	Routes RouteMatcher
+	// Require are the names of the middleware every selected route must
+	// run, in this order, e.g. "jwt" before "casbin". Other middleware may
+	// run in between.
+	Require []string
+	// Typed requires handlers built by the typed adapters of package resp.
+	Typed bool
+	// Unique forbids registering a method and path twice; echo silently
+	// keeps the last registration.
+	Unique bool
+}
+
+// DependencyRule is the rule of the violations of middleware dependencies
+// reported by VerifyRoutes (see RouteAudit.Depends).
+const DependencyRule = "middleware dependencies"
+
+// RouteRecord is a route as registered.
+type RouteRecord struct {
+	Host    string `json:"host,omitempty"`
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+	// Middleware are the names of the middleware running for the route,
+	// outermost first: the global middleware, then the group and route
+	// middleware. Unnamed middleware appear as their function name.
+	Middleware []string `json:"middleware"`
+}
+
+// RouteAudit records the routes of an echo instance and the middleware
+// wrapping them as they are registered, for VerifyRoutes. middleware.Setup
+// installs it and names the middleware it installs.
+type RouteAudit struct {
+	mu       sync.Mutex
+	names    map[uintptr]string
+	global   []string
+	routes   []auditedRoute
+	requires map[string][]string
+}
+
+type auditedRoute struct {
+	host       string
+	route      echo.Route
+	middleware []uintptr
+}
+
+var (
+	auditsMu sync.Mutex
+	audits   = map[*echo.Echo]*RouteAudit{}
+)
+
+// AuditRoutes returns the route audit of e, installing it through
+// e.OnAddRouteHandler on first use. Routes registered before are not
+// recorded; VerifyRoutes reports them when a rule selects them.
+func AuditRoutes(e *echo.Echo) *RouteAudit {
+	auditsMu.Lock()
+	defer auditsMu.Unlock()
+	if a, ok := audits[e]; ok {
+		return a
+	}
+	a := &RouteAudit{names: map[uintptr]string{}}
+	prev := e.OnAddRouteHandler
+	e.OnAddRouteHandler = func(host string, route echo.Route, handler echo.HandlerFunc, middleware []echo.MiddlewareFunc) {
+		a.add(host, route, middleware)
+		if prev != nil {
+			prev(host, route, handler, middleware)
+		}
+	}
+	audits[e] = a
+	return a
+}
+
+func (a *RouteAudit) add(host string, route echo.Route, middleware []echo.MiddlewareFunc) {
+	ptrs := make([]uintptr, len(middleware))
+	for i, mw := range middleware {
+		ptrs[i] = reflect.ValueOf(mw).Pointer()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.routes = append(a.routes, auditedRoute{host: host, route: route, middleware: ptrs})
+}
+
+// Name names the group and route middleware built like mw, i.e. by the
+// same function literal, in the records.
+func (a *RouteAudit) Name(name string, mw echo.MiddlewareFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.names[reflect.ValueOf(mw).Pointer()] = name
+}
+
+// Global records a middleware installed with e.Use, which runs for every
+// route whenever it was registered.
+func (a *RouteAudit) Global(name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.global = append(a.global, name)
+}
+
+// Depends declares that the middleware named name must run after the
+// middleware named requires, e.g. a tenant resolver after "jwt".
+// VerifyRoutes reports every route where it does not.
+func (a *RouteAudit) Depends(name string, requires ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.requires == nil {
+		a.requires = map[string][]string{}
+	}
+	a.requires[name] = append(a.requires[name], requires...)
+}
+
+// dependencies returns a copy of the declared dependencies.
+func (a *RouteAudit) dependencies() map[string][]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	deps := make(map[string][]string, len(a.requires))
+	for name, requires := range a.requires {
+		deps[name] = slices.Clone(requires)
+	}
+	return deps
+}
+
+// Routes returns the recorded routes in registration order, including
+// repeated registrations. The catch-all routes echo adds for group
+// middleware are left out.
+func (a *RouteAudit) Routes() []RouteRecord {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	records := make([]RouteRecord, 0, len(a.routes))
+	for _, r := range a.routes {
+		if r.route.Method == echo.RouteNotFound {
+			continue
+		}
+		mw := slices.Clone(a.global)
+		for _, ptr := range r.middleware {
+			name, ok := a.names[ptr]
+			if !ok {
+				name = funcName(ptr)
+			}
+			mw = append(mw, name)
+		}
+		records = append(records, RouteRecord{
+			Host:       r.host,
+			Method:     r.route.Method,
+			Path:       r.route.Path,
+			Handler:    r.route.Name,
+			Middleware: mw,
+		})
+	}
+	return records
+}
+
+// funcName returns the name of the function at ptr.
+func funcName(ptr uintptr) string {
+	if fn := runtime.FuncForPC(ptr); fn != nil {
+		return fn.Name()
+	}
+	return "unknown"
+}
+
+// RouteViolation is a route breaking a RouteRule.
+type RouteViolation struct {
+	Rule    string `json:"rule"`
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler,omitempty"`
+	Problem string `json:"problem"`
+}
+
+func (v RouteViolation) String() string {
+	s := v.Rule + ": " + v.Method + " " + v.Path
+	if v.Handler != "" {
+		s += " (" + v.Handler + ")"
+	}
+	return s + ": " + v.Problem
+}
+
+// RouteViolations is the error of VerifyRoutes, listing every violation.
+type RouteViolations []RouteViolation
+
+func (v RouteViolations) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d route violation(s)", len(v))
+	for _, rv := range v {
+		b.WriteString("\n  ")
+		b.WriteString(rv.String())
+	}
+	return b.String()
+}
+
+// VerifyRoutes checks the routes of e recorded by its route audit (see
+// AuditRoutes) against rules and returns a RouteViolations listing every
+// violation, or nil. Run it once every route is registered, at startup and
+// in tests:
+//
+//	groups := middleware.Setup(e, deps)
+//	registerRoutes(groups)
+//	if err := kit.VerifyRoutes(e, rules); err != nil {
+//	    t.Fatal(err)
+//	}
+//
+// Routes of e.Routes() missing from the audit were registered before it
+// was installed; their middleware is unknown, so they violate every rule
+// selecting them. Whatever the rules, every route running a middleware
+// whose dependencies (see RouteAudit.Depends) do not run before it is a
+// violation of the rule DependencyRule.
+func VerifyRoutes(e *echo.Echo, rules []RouteRule) error {
+	auditsMu.Lock()
+	a, ok := audits[e]
+	auditsMu.Unlock()
+	if !ok {
+		return fmt.Errorf("routes are not audited: call kit.AuditRoutes or middleware.Setup before registering routes")
+	}
+	records := a.Routes()
+
+	audited := make(map[string]bool, len(records))
+	for _, r := range records {
+		audited[r.Method+" "+r.Path] = true
+	}
+	var unaudited []RouteRecord
+	for _, r := range e.Routes() {
+		if r.Method != echo.RouteNotFound && !audited[r.Method+" "+r.Path] {
+			unaudited = append(unaudited, RouteRecord{Method: r.Method, Path: r.Path, Handler: r.Name})
+		}
+	}
+
+	var violations RouteViolations
+	for _, rule := range rules {
+		violation := func(r RouteRecord, problem string) {
+			violations = append(violations, RouteViolation{Rule: rule.Name, Method: r.Method, Path: r.Path, Handler: r.Handler, Problem: problem})
+		}
+		for _, r := range unaudited {
+			if rule.selects(r) {
+				violation(r, "registered before the route audit was installed")
+			}
+		}
+		seen := map[string]bool{}
+		for _, r := range records {
+			if !rule.selects(r) {
+				continue
+			}
+			if missing := missingMiddleware(r.Middleware, rule.Require); len(missing) > 0 {
+				violation(r, "missing or misordered middleware "+strings.Join(missing, ", ")+" (has "+strings.Join(r.Middleware, ", ")+")")
+			}
+			if rule.Typed && !strings.HasPrefix(r.Handler, typedHandlerPrefix) {
+				violation(r, "handler not built by resp.Handler, ListHandler or NoContentHandler")
+			}
+			key := r.Host + " " + r.Method + " " + r.Path
+			if rule.Unique && seen[key] {
+				violation(r, "registered more than once; the last registration wins")
+			}
+			seen[key] = true
+		}
+	}
+	deps := a.dependencies()
+	for _, r := range records {
+		for i, name := range r.Middleware {
+			for _, req := range deps[name] {
+				if !slices.Contains(r.Middleware[:i], req) {
+					violations = append(violations, RouteViolation{Rule: DependencyRule, Method: r.Method, Path: r.Path, Handler: r.Handler,
+						Problem: name + " runs without " + req + " before it (has " + strings.Join(r.Middleware, ", ") + ")"})
+				}
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return violations
+	}
+	return nil
+}
+
+// selects reports whether the rule applies to r.
+func (rule RouteRule) selects(r RouteRecord) bool {
+	return rule.Routes == nil || rule.Routes.Match(r.Method, r.Path)
+}
+
+// missingMiddleware returns the names of required not found in have in
+// the required order.
+func missingMiddleware(have, required []string) []string {
+	var missing []string
+	i := 0
+	for _, name := range required {
+		j := slices.Index(have[i:], name)
+		if j < 0 {
+			missing = append(missing, name)
+			continue
+		}
+		i += j + 1
+	}
+	return missing
+}
+
+// CheckRoutes is VerifyRoutes for startup: in the dev profile (see
+// Resolve) violations are logged as warnings and nil is returned, in other
+// environments the violations are returned and startup should stop.
+func CheckRoutes(cfg config.Config, e *echo.Echo, rules []RouteRule) error {
+	err := VerifyRoutes(e, rules)
+	if err == nil || Resolve(cfg).Name != Dev.Name {
+		return err
+	}
+	if violations, ok := err.(RouteViolations); ok {
+		for _, v := range violations {
+			slog.Warn("Route violation", slog.String("rule", v.Rule), slog.String("method", v.Method),
+				slog.String("path", v.Path), slog.String("handler", v.Handler), slog.String("problem", v.Problem))
+		}
+		return nil
+	}
+	slog.Warn("Route audit failed", slog.String("error", err.Error()))
+	return nil
+}
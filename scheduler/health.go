@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/NSObjects/go-kit/health"
+	"github.com/NSObjects/go-kit/resp"
+	"github.com/labstack/echo/v4"
+)
+
+// Checker returns a health checker reporting degraded status when any job's
+// last run failed.
+func (s *Scheduler) Checker() health.Checker {
+	return schedulerChecker{s: s}
+}
+
+type schedulerChecker struct {
+	s *Scheduler
+}
+
+func (c schedulerChecker) Name() string { return "scheduler" }
+
+func (c schedulerChecker) Check(ctx context.Context) health.Check {
+	start := time.Now()
+	check := health.Check{Name: c.Name(), Status: health.StatusHealthy}
+
+	var failing []string
+	for _, st := range c.s.States() {
+		if st.LastError != "" {
+			failing = append(failing, st.Name)
+		}
+	}
+	if len(failing) > 0 {
+		check.Status = health.StatusDegraded
+		check.Message = fmt.Sprintf("failing jobs: %v", failing)
+	}
+
+	check.Latency = time.Since(start)
+	return check
+}
+
+// Handler returns an admin endpoint listing job states as JSON.
+//
+//	admin.GET("/jobs", s.Handler())
+func (s *Scheduler) Handler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		states := s.States()
+		return resp.ListDataResponse(c, states, int64(len(states)))
+	}
+}
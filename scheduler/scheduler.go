@@ -0,0 +1,381 @@
+// Package scheduler provides a cron/interval job runner with per-job
+// timeouts, panic recovery, overlap policies, optional distributed locking
+// and observability.
+//
+// Usage:
+//
+//	s := scheduler.New(scheduler.Options{Locker: cache.NewLocker(m.Redis, "jobs")})
+//	_ = s.Register("cleanup", "*/5 * * * *", cleanup, scheduler.JobOptions{
+//	    Timeout:     time.Minute,
+//	    Distributed: true,
+//	})
+//	_ = s.Register("refresh", "@every 30s", refresh, scheduler.JobOptions{})
+//	_ = s.Start(ctx)
+//	defer s.Stop(ctx)
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/NSObjects/go-kit/cache"
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/lifecycle"
+	"github.com/NSObjects/go-kit/metrics"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/robfig/cron/v3"
+)
+
+// Job is a scheduled function.
+type Job func(ctx context.Context) error
+
+// OverlapPolicy decides what happens when a job is due while still running.
+type OverlapPolicy int
+
+const (
+	// SkipIfRunning skips the tick if the previous run has not finished.
+	SkipIfRunning OverlapPolicy = iota
+	// Queue runs the job once more immediately after the current run finishes.
+	Queue
+)
+
+// JobOptions configures a registered job.
+type JobOptions struct {
+	// Timeout bounds a single run; 0 means no timeout.
+	Timeout time.Duration
+	// Policy controls overlapping runs; default SkipIfRunning.
+	Policy OverlapPolicy
+	// Distributed ensures only one replica runs the job per tick using the
+	// scheduler's Locker. The ticks of "@every" schedules are then aligned
+	// on the Unix epoch, so that replicas started at different times share
+	// them: "@every 5m" runs at :00, :05, :10...
+	Distributed bool
+	// LockTTL is how long the per-tick lock is held; default Timeout or 1m.
+	LockTTL time.Duration
+}
+
+// JobState describes the state of a job.
+type JobState struct {
+	Name         string        `json:"name"`
+	Spec         string        `json:"spec"`
+	Running      bool          `json:"running"`
+	LastRun      time.Time     `json:"last_run,omitzero"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastError    string        `json:"last_error,omitempty"`
+	NextRun      time.Time     `json:"next_run"`
+	Runs         int64         `json:"runs"`
+	Failures     int64         `json:"failures"`
+}
+
+// Options configures a Scheduler.
+type Options struct {
+	// Clock provides the current time and, when a utils.TimerClock such as
+	// utils.FakeClock, drives the ticker; default utils.RealClock.
+	Clock utils.Clock
+	// Locker is required for Distributed jobs.
+	Locker *cache.Locker
+	// Metrics records run counts and durations (optional).
+	Metrics *metrics.SchedulerMetrics
+	// Resolution is how often due jobs are checked; default 1s.
+	Resolution time.Duration
+	// Lifecycle, when set, gets a hook starting and stopping the
+	// Scheduler; do not also pass it to server.NewRunner.
+	Lifecycle *lifecycle.Lifecycle
+}
+
+type job struct {
+	name     string
+	spec     string
+	schedule cron.Schedule
+	fn       Job
+	opts     JobOptions
+
+	mu      sync.Mutex
+	state   JobState
+	pending bool
+	// pendingTick is the scheduled time of the queued run, which names
+	// its distributed lock like every replica does.
+	pendingTick time.Time
+}
+
+// Scheduler runs registered jobs.
+type Scheduler struct {
+	opts Options
+
+	mu     sync.RWMutex
+	jobs   map[string]*job
+	cancel context.CancelFunc
+	done   <-chan struct{}
+	// cancelJobs cancels the context of running jobs, once Stop is done
+	// waiting for them.
+	cancelJobs context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// New creates a Scheduler.
+func New(opts Options) *Scheduler {
+	if opts.Clock == nil {
+		opts.Clock = utils.RealClock{}
+	}
+	if opts.Resolution <= 0 {
+		opts.Resolution = time.Second
+	}
+	s := &Scheduler{opts: opts, jobs: make(map[string]*job)}
+	if opts.Lifecycle != nil {
+		opts.Lifecycle.Append(lifecycle.Hook{Name: "scheduler", OnStart: s.Start, OnStop: s.Stop})
+	}
+	return s
+}
+
+var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// epochSchedule is an "@every" schedule whose ticks are the multiples of
+// its delay since the Unix epoch, rather than counted from the start of
+// the process, so that every replica computes the same ones.
+type epochSchedule cron.ConstantDelaySchedule
+
+func (e epochSchedule) Next(t time.Time) time.Time {
+	d := e.Delay.Nanoseconds()
+	n := t.UnixNano()
+	return time.Unix(0, n-n%d+d).In(t.Location())
+}
+
+// Register adds a job. spec is a standard 5-field cron expression or a
+// descriptor such as "@hourly" or "@every 30s".
+func (s *Scheduler) Register(name, spec string, fn Job, opts JobOptions) error {
+	schedule, err := parser.Parse(spec)
+	if err != nil {
+		return code.WrapErrorf(err, code.ErrBadRequest, "invalid schedule %q for job %s", spec, name)
+	}
+	if opts.Distributed && s.opts.Locker == nil {
+		return code.NewErrorf(code.ErrBadRequest, "job %s is distributed but no locker is configured", name)
+	}
+	if every, ok := schedule.(cron.ConstantDelaySchedule); ok && opts.Distributed {
+		schedule = epochSchedule(every)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.jobs[name]; exists {
+		return code.NewErrorf(code.ErrBadRequest, "job %s already registered", name)
+	}
+	j := &job{name: name, spec: spec, schedule: schedule, fn: fn, opts: opts}
+	j.state = JobState{Name: name, Spec: spec, NextRun: schedule.Next(s.opts.Clock.Now())}
+	s.jobs[name] = j
+	return nil
+}
+
+// Start starts the scheduling loop in the background.
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return nil
+	}
+
+	loopCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	jobsCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	s.cancel, s.cancelJobs = cancel, cancelJobs
+	s.done = lifecycle.Go(loopCtx, "scheduler", func(ctx context.Context) error {
+		ticker := utils.NewTicker(s.opts.Clock, s.opts.Resolution)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C():
+				s.tick(ctx, jobsCtx)
+			}
+		}
+	}).Done()
+	return nil
+}
+
+// Stop stops scheduling and waits for running jobs until ctx is done.
+// Their context is canceled only then, so that they can finish within
+// that grace period.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, cancelJobs, done := s.cancel, s.cancelJobs, s.done
+	s.cancel, s.cancelJobs = nil, nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	defer cancelJobs()
+	cancel()
+	<-done
+
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Tick starts every job that is due at the current clock time. It is called
+// by the scheduling loop and can be called directly in tests with a fake clock.
+func (s *Scheduler) Tick(ctx context.Context) {
+	s.tick(ctx, ctx)
+}
+
+// tick is Tick running the jobs with jobsCtx; queued runs are dropped once
+// ctx is done.
+func (s *Scheduler) tick(ctx, jobsCtx context.Context) {
+	now := s.opts.Clock.Now()
+
+	s.mu.RLock()
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.mu.RUnlock()
+
+	for _, j := range jobs {
+		j.mu.Lock()
+		due := !now.Before(j.state.NextRun)
+		if !due {
+			j.mu.Unlock()
+			continue
+		}
+		tick := j.state.NextRun
+		j.state.NextRun = j.schedule.Next(now)
+
+		if j.state.Running {
+			if j.opts.Policy == Queue {
+				j.pending = true
+				j.pendingTick = tick
+			}
+			j.mu.Unlock()
+			continue
+		}
+		j.state.Running = true
+		j.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.runLoop(ctx, jobsCtx, j, tick)
+		}()
+	}
+}
+
+// runLoop runs j with jobsCtx, then any queued follow-up runs until ctx
+// is done.
+func (s *Scheduler) runLoop(ctx, jobsCtx context.Context, j *job, tick time.Time) {
+	for {
+		s.run(jobsCtx, j, tick)
+
+		j.mu.Lock()
+		if !j.pending || ctx.Err() != nil {
+			j.pending = false
+			j.state.Running = false
+			j.mu.Unlock()
+			return
+		}
+		j.pending = false
+		tick = j.pendingTick
+		j.mu.Unlock()
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, j *job, tick time.Time) {
+	if j.opts.Distributed {
+		ttl := j.opts.LockTTL
+		if ttl <= 0 {
+			ttl = j.opts.Timeout
+		}
+		if ttl <= 0 {
+			ttl = time.Minute
+		}
+		// The lock is per tick and never released early, so replicas whose
+		// clocks are slightly behind cannot run the same tick again.
+		key := j.name + ":" + strconv.FormatInt(tick.Unix(), 10)
+		if _, err := s.opts.Locker.Obtain(ctx, key, ttl); err != nil {
+			if err != cache.ErrLockNotObtained {
+				slog.Warn("Job lock failed", slog.String("job", j.name), slog.String("error", err.Error()))
+			}
+			return
+		}
+	}
+
+	// Every execution gets a fresh request ID, tagged with the one of the
+	// context passed to Start, if any.
+	runCtx := utils.WithNewRequestID(ctx)
+	if j.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(runCtx, j.opts.Timeout)
+		defer cancel()
+	}
+
+	start := s.opts.Clock.Now()
+	err := safeRun(runCtx, j)
+	duration := s.opts.Clock.Now().Sub(start)
+
+	j.mu.Lock()
+	j.state.LastRun = start
+	j.state.LastDuration = duration
+	j.state.Runs++
+	if err != nil {
+		j.state.Failures++
+		j.state.LastError = err.Error()
+	} else {
+		j.state.LastError = ""
+	}
+	j.mu.Unlock()
+
+	status := "success"
+	if err != nil {
+		status = "error"
+		slog.ErrorContext(runCtx, "Job failed",
+			slog.String("job", j.name),
+			slog.String("request_id", utils.GetRequestID(runCtx)),
+			slog.String("origin_request_id", utils.GetOriginRequestID(runCtx)),
+			slog.Int("code", errors.GetCode(err)),
+			slog.Duration("duration", duration),
+			slog.String("error", fmt.Sprintf("%+v", err)),
+		)
+	}
+	if s.opts.Metrics != nil {
+		s.opts.Metrics.JobRuns.WithLabelValues(j.name, status).Inc()
+		s.opts.Metrics.JobDuration.WithLabelValues(j.name).Observe(duration.Seconds())
+	}
+}
+
+// safeRun runs the job, converting a panic into a coded error.
+func safeRun(ctx context.Context, j *job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Wrapf(errors.FromPanic(r), "job %s", j.name)
+		}
+	}()
+	return j.fn(ctx)
+}
+
+// States returns the state of all jobs sorted by name.
+func (s *Scheduler) States() []JobState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	states := make([]JobState, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		j.mu.Lock()
+		states = append(states, j.state)
+		j.mu.Unlock()
+	}
+	sort.Slice(states, func(i, k int) bool { return states[i].Name < states[k].Name })
+	return states
+}
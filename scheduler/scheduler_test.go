@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NSObjects/go-kit/cache"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestDistributedEveryRunsOncePerTick(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	locker := cache.NewLocker(client, "jobs")
+
+	var runs atomic.Int32
+	job := func(context.Context) error { runs.Add(1); return nil }
+
+	// Two replicas started 17s apart.
+	start := time.Date(2024, 1, 1, 0, 0, 3, 0, time.UTC)
+	clocks := []*utils.FakeClock{utils.NewFakeClock(start), utils.NewFakeClock(start.Add(17 * time.Second))}
+	var replicas []*Scheduler
+	for _, clock := range clocks {
+		s := New(Options{Clock: clock, Locker: locker})
+		if err := s.Register("sync", "@every 1m", job, JobOptions{Distributed: true}); err != nil {
+			t.Fatal(err)
+		}
+		replicas = append(replicas, s)
+	}
+	for i, s := range replicas {
+		if got, want := s.States()[0].NextRun, time.Date(2024, 1, 1, 0, 1, 0, 0, time.UTC); !got.Equal(want) {
+			t.Fatalf("replica %d: NextRun = %v, want %v", i, got, want)
+		}
+	}
+
+	for i, s := range replicas {
+		clocks[i].Advance(time.Minute)
+		s.Tick(context.Background())
+		s.wg.Wait()
+	}
+	if got := runs.Load(); got != 1 {
+		t.Fatalf("runs = %d, want 1", got)
+	}
+}
+
+func TestStopLetsRunningJobsFinish(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := New(Options{Clock: clock})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var canceled atomic.Bool
+	err := s.Register("slow", "@every 1m", func(ctx context.Context) error {
+		close(started)
+		<-release
+		canceled.Store(ctx.Err() != nil)
+		return nil
+	}, JobOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	<-started
+
+	stopped := make(chan error)
+	go func() { stopped <- s.Stop(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+	if canceled.Load() {
+		t.Fatal("the job context was canceled before Stop finished waiting")
+	}
+}
@@ -0,0 +1,51 @@
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/pubsub"
+)
+
+// LogHandler logs events at warn level with their fields in a "security"
+// group, so log pipelines can route them separately.
+func LogHandler(logger *slog.Logger) Handler {
+	return func(e Event) {
+		attrs := []any{
+			slog.String("kind", string(e.Kind)),
+			slog.Time("time", e.Time),
+			slog.String("user_id", e.UserID),
+			slog.String("ip", e.IP),
+			slog.String("method", e.Method),
+			slog.String("route", e.Route),
+			slog.String("request_id", e.RequestID),
+			slog.Int("code", e.Code),
+		}
+		if len(e.Details) > 0 {
+			attrs = append(attrs, slog.Any("details", e.Details))
+		}
+		logger.Warn("Security event", slog.Group("security", attrs...))
+	}
+}
+
+// PublishHandler publishes events as JSON to topic, keyed by user ID, e.g.
+// on a Kafka-backed pubsub.Bus feeding a SIEM. Each publish is bounded by
+// 5s; failures are logged and the event is lost.
+func PublishHandler(p pubsub.Publisher, topic string) Handler {
+	return func(e Event) {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			slog.Error("Encode security event failed", log.Err(err))
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err = p.Publish(ctx, pubsub.Message{Topic: topic, Key: e.UserID, Payload: payload})
+		if err != nil {
+			slog.Error("Publish security event failed", slog.String("topic", topic), log.Err(err))
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Package security is an in-process bus for security events, such as
+// rejected tokens and denied permissions, to forward to logs, metrics and a
+// SIEM.
+//
+// The auth middleware (JWT, Casbin, CSRF, Session) emits events; handlers
+// emit their own with middleware.EmitSecurityEvent. Handlers run on a
+// single background goroutine, so Emit never blocks the request: when the
+// queue is full the event is dropped and counted (see Dropped).
+//
+//	security.OnEvent(security.LogHandler(slog.Default()))
+//	security.OnEvent(metrics.NewSecurityMetrics("app").Handle)
+//	security.OnEvent(security.PublishHandler(kafkaBus, "security.events"))
+package security
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+)
+
+// Kind classifies a security event.
+type Kind string
+
+// Event kinds.
+const (
+	KindLoginSucceeded   Kind = "login_succeeded"
+	KindLoginFailed      Kind = "login_failed"
+	KindTokenMissing     Kind = "token_missing"
+	KindTokenInvalid     Kind = "token_invalid"
+	KindTokenExpired     Kind = "token_expired"
+	KindSessionInvalid   Kind = "session_invalid"
+	KindPermissionDenied Kind = "permission_denied"
+	KindAccountLocked    Kind = "account_locked"
+	KindAccountDisabled  Kind = "account_disabled"
+	KindRateLimited      Kind = "rate_limited"
+	KindCSRFRejected     Kind = "csrf_rejected"
+	KindSignatureInvalid Kind = "signature_invalid"
+	KindPolicyChanged    Kind = "policy_changed"
+
+	// Rotations of the TLS certificate and JWT keys reloaded from files
+	// (see server.CertReloader and middleware.JWTKeyFile), and reloads
+	// failing and keeping the previous material.
+	KindCertificateRotated Kind = "certificate_rotated"
+	KindKeyRotated         Kind = "key_rotated"
+	KindRotationFailed     Kind = "rotation_failed"
+)
+
+// Event is a security-relevant decision.
+type Event struct {
+	Kind      Kind           `json:"kind"`
+	Time      time.Time      `json:"time"`
+	UserID    string         `json:"user_id,omitempty"`
+	IP        string         `json:"ip,omitempty"`
+	Method    string         `json:"method,omitempty"`
+	Route     string         `json:"route,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+	Code      int            `json:"code,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// Handler processes an event. Handlers run sequentially on the dispatch
+// goroutine and should not block for long.
+type Handler func(Event)
+
+// QueueSize is the capacity of the event queue.
+const QueueSize = 1024
+
+var (
+	mu       sync.RWMutex
+	handlers []Handler
+	start    sync.Once
+	queue    = make(chan Event, QueueSize)
+	pending  atomic.Int64
+	dropped  atomic.Uint64
+)
+
+// OnEvent registers h for all subsequent events.
+func OnEvent(h Handler) {
+	mu.Lock()
+	handlers = append(handlers, h)
+	mu.Unlock()
+	start.Do(func() { go dispatch() })
+}
+
+// Emit queues e for the registered handlers without blocking. Time is set
+// when zero. Without handlers Emit does nothing.
+func Emit(e Event) {
+	mu.RLock()
+	n := len(handlers)
+	mu.RUnlock()
+	if n == 0 {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	pending.Add(1)
+	select {
+	case queue <- e:
+	default:
+		pending.Add(-1)
+		dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of events dropped because the queue was full.
+func Dropped() uint64 {
+	return dropped.Load()
+}
+
+// Flush waits until queued events have been handled or ctx is done, e.g.
+// during shutdown.
+func Flush(ctx context.Context) error {
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for pending.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+func dispatch() {
+	for e := range queue {
+		mu.RLock()
+		hs := handlers
+		mu.RUnlock()
+		for _, h := range hs {
+			handle(h, e)
+		}
+		pending.Add(-1)
+	}
+}
+
+// handle runs h, containing panics so one handler cannot stop dispatch.
+func handle(h Handler, e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Security event handler panicked", slog.Any("panic", r), slog.String("kind", string(e.Kind)))
+		}
+	}()
+	h(e)
+}
+
+// KindForCode returns the event kind for an auth-related error code, and
+// false for codes that are not security relevant.
+func KindForCode(errCode int) (Kind, bool) {
+	switch errCode {
+	case code.ErrExpired:
+		return KindTokenExpired, true
+	case code.ErrTokenInvalid, code.ErrInvalidAuthHeader:
+		return KindTokenInvalid, true
+	case code.ErrMissingHeader:
+		return KindTokenMissing, true
+	case code.ErrSignatureInvalid:
+		return KindSignatureInvalid, true
+	case code.ErrPasswordIncorrect:
+		return KindLoginFailed, true
+	case code.ErrForbidden, code.ErrPermissionDenied:
+		return KindPermissionDenied, true
+	case code.ErrAccountLocked:
+		return KindAccountLocked, true
+	case code.ErrAccountDisabled:
+		return KindAccountDisabled, true
+	case code.ErrTooManyAttempts:
+		return KindRateLimited, true
+	default:
+		return "", false
+	}
+}
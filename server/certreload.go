@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/NSObjects/go-kit/internal/filewatch"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/security"
+)
+
+// CertReloader serves a TLS key pair from files, reloaded when they change,
+// e.g. when cert-manager renews the certificate of a secret volume. New
+// connections get the new certificate; established ones keep theirs.
+//
+//	r, err := server.NewCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+//	err = r.Watch(ctx)
+//	srv.TLSConfig = &tls.Config{GetCertificate: r.GetCertificate}
+//
+// Every rotation emits a security.KindCertificateRotated event, and every
+// failed reload a security.KindRotationFailed event (counted by
+// metrics.SecurityMetrics).
+type CertReloader struct {
+	certFile, keyFile string
+	cert              atomic.Pointer[tls.Certificate]
+	mu                sync.Mutex // serializes reloads
+}
+
+// NewCertReloader loads the key pair of certFile and keyFile.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load tls key pair: %w", err)
+	}
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	r.cert.Store(&cert)
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// Reload reloads the key pair. A pair failing to load is logged and
+// returned, and the last good pair keeps being served.
+func (r *CertReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		err = fmt.Errorf("load tls key pair: %w", err)
+		slog.Error("TLS certificate reload failed, keeping the previous certificate",
+			slog.String("cert_file", r.certFile), log.Err(err))
+		security.Emit(security.Event{Kind: security.KindRotationFailed, Details: map[string]any{
+			"cert_file": r.certFile,
+			"error":     err.Error(),
+		}})
+		return err
+	}
+	if bytes.Equal(cert.Certificate[0], r.cert.Load().Certificate[0]) {
+		return nil
+	}
+	r.cert.Store(&cert)
+
+	details := map[string]any{"cert_file": r.certFile}
+	if cert.Leaf != nil {
+		details["subject"] = cert.Leaf.Subject.String()
+		details["not_after"] = cert.Leaf.NotAfter
+	}
+	slog.Info("TLS certificate reloaded", slog.String("cert_file", r.certFile), slog.Any("not_after", details["not_after"]))
+	security.Emit(security.Event{Kind: security.KindCertificateRotated, Details: details})
+	return nil
+}
+
+// Watch reloads the key pair whenever its files change, until ctx is done.
+func (r *CertReloader) Watch(ctx context.Context) error {
+	return filewatch.Watch(ctx, []string{r.certFile, r.keyFile}, func() {
+		_ = r.Reload()
+	})
+}
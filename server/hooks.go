@@ -0,0 +1,126 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/lifecycle"
+)
+
+// Startup phases. Hooks run in ascending phase order (registration order
+// within a phase); shutdown hooks run in descending order.
+const (
+	// PhaseComponents starts the components passed to NewRunner
+	// (db.Manager, scheduler, ...).
+	PhaseComponents = 0
+	// PhaseMigrate runs schema migrations.
+	PhaseMigrate = 100
+	// PhaseSeed loads fixtures (db.Seeder).
+	PhaseSeed = 200
+	// PhaseWarmup primes caches and loads policies.
+	PhaseWarmup = 300
+	// PhaseReady flips readiness (see WithHealth).
+	PhaseReady = 1000
+)
+
+// DefaultHookTimeout bounds each hook unless HookTimeout is given.
+const DefaultHookTimeout = lifecycle.DefaultTimeout
+
+// HookOption configures a startup or shutdown hook.
+type HookOption func(*hook)
+
+// HookTimeout overrides the timeout of one hook.
+func HookTimeout(d time.Duration) HookOption {
+	return func(h *hook) {
+		h.StartTimeout = d
+		h.StopTimeout = d
+	}
+}
+
+// hook is a lifecycle hook of a phase.
+type hook struct {
+	lifecycle.Hook
+	phase int
+}
+
+// RegisterStartup adds a startup hook. Hooks run before the HTTP server
+// listens, in ascending phase order, each bounded by its timeout; the first
+// failure aborts startup with an ErrStartup error naming the hook.
+//
+//	runner := server.NewRunner(srv, cfg.System, dbManager).WithHealth(reg)
+//	runner.RegisterStartup("migrate", server.PhaseMigrate, migrate)
+//	runner.RegisterStartup("seed", server.PhaseSeed, seeder.Run)
+//	runner.RegisterStartup("casbin.load", server.PhaseWarmup, func(ctx context.Context) error {
+//	    return enforcer.LoadPolicy()
+//	})
+func (r *Runner) RegisterStartup(name string, phase int, fn func(ctx context.Context) error, opts ...HookOption) *Runner {
+	r.hooks = append(r.hooks, newHook(lifecycle.Hook{Name: name, OnStart: fn}, phase, opts))
+	return r
+}
+
+// RegisterShutdown adds a shutdown hook. Shutdown hooks run after the HTTP
+// server stops, in descending phase order (reverse registration order
+// within a phase, before the components of the phase stop). When startup
+// fails, only hooks of phases that completed run.
+func (r *Runner) RegisterShutdown(name string, phase int, fn func(ctx context.Context) error, opts ...HookOption) *Runner {
+	r.hooks = append(r.hooks, newHook(lifecycle.Hook{Name: name, OnStop: fn}, phase, opts))
+	return r
+}
+
+// RegisterComponent registers c.Start as a startup hook and c.Stop as a
+// shutdown hook of phase. Stop runs only if Start succeeded.
+func (r *Runner) RegisterComponent(name string, phase int, c Component, opts ...HookOption) *Runner {
+	r.hooks = append(r.hooks, newHook(lifecycle.Hook{Name: name, OnStart: c.Start, OnStop: c.Stop}, phase, opts))
+	return r
+}
+
+func newHook(lh lifecycle.Hook, phase int, opts []HookOption) hook {
+	h := hook{Hook: lh, phase: phase}
+	for _, opt := range opts {
+		opt(&h)
+	}
+	return h
+}
+
+// newLifecycle returns the Lifecycle of the hooks in ascending phase
+// order. Within a phase, shutdown-only hooks follow the others, so they
+// are reached, and run on shutdown, only when the whole phase started.
+func (r *Runner) newLifecycle() *lifecycle.Lifecycle {
+	hooks := slices.Clone(r.hooks)
+	slices.SortStableFunc(hooks, func(a, b hook) int {
+		return cmp.Or(cmp.Compare(a.phase, b.phase), cmp.Compare(stopOnly(a), stopOnly(b)))
+	})
+	lc := lifecycle.New(lifecycle.Options{Timeout: r.hookTimeout, WaitWorkers: true})
+	for _, h := range hooks {
+		lc.Append(h.Hook)
+	}
+	return lc
+}
+
+func stopOnly(h hook) int {
+	if h.OnStart == nil {
+		return 1
+	}
+	return 0
+}
+
+// startError returns the ErrStartup error of a failed startup, naming the
+// hook and its phase.
+func (r *Runner) startError(err error) error {
+	var he *lifecycle.HookError
+	if !errors.As(err, &he) {
+		return code.WrapError(err, code.ErrStartup, "startup")
+	}
+	phase := 0
+	for _, h := range r.hooks {
+		if h.Name == he.Name && h.OnStart != nil {
+			phase = h.phase
+			break
+		}
+	}
+	return code.WrapErrorf(he.Err, code.ErrStartup, "startup hook %q (phase %d)", he.Name, phase)
+}
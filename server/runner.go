@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	kit "github.com/NSObjects/go-kit"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/health"
+	"github.com/NSObjects/go-kit/lifecycle"
+)
+
+// Component is a long-lived dependency started before and stopped after the
+// HTTP server (db.Manager, scheduler.Scheduler, ...). The Start context is
+// bounded by the hook timeout, so background work must not inherit its
+// cancellation (use context.WithoutCancel).
+type Component interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// Runner runs an HTTP server with its components and shuts everything down
+// gracefully on SIGINT/SIGTERM or context cancellation.
+type Runner struct {
+	server      *http.Server
+	cfg         config.SystemConfig
+	health      *health.Registry
+	hookTimeout time.Duration
+	hooks       []hook
+
+	summary       *kit.StartupSummary
+	summaryLogger kit.InfoLogger
+}
+
+// NewRunner creates a Runner. Components are registered as PhaseComponents
+// hooks (see RegisterComponent): started in order and stopped in reverse
+// order. A lifecycle.Lifecycle is a Component, so the parts registered with
+// it start and stop in the same order as in any other bootstrap.
+func NewRunner(srv *http.Server, cfg config.SystemConfig, components ...Component) *Runner {
+	r := &Runner{
+		server:      srv,
+		cfg:         WithDefaults(cfg),
+		hookTimeout: DefaultHookTimeout,
+	}
+	for _, c := range components {
+		r.RegisterComponent(fmt.Sprintf("%T", c), PhaseComponents, c)
+	}
+	return r
+}
+
+// WithHealth sets the health registry. Readiness is set by a PhaseReady
+// startup hook, once earlier phases (migrations, warmup) completed, and is
+// flipped to "draining" (BeginShutdown) before the HTTP server stops. After
+// that the Runner waits cfg.DrainDelay so load balancers can observe it.
+func (r *Runner) WithHealth(reg *health.Registry) *Runner {
+	r.health = reg
+	return r.RegisterStartup("health.ready", PhaseReady, func(context.Context) error {
+		reg.SetReady(true)
+		return nil
+	})
+}
+
+// WithStartupSummary makes Run log summary to logger (default
+// slog.Default()) once the startup hooks succeeded (see
+// kit.StartupSummary.LogTo):
+//
+//	runner.WithStartupSummary(logger, kit.NewStartupSummary(cfg, manager, groups.Installed))
+func (r *Runner) WithStartupSummary(logger kit.InfoLogger, summary kit.StartupSummary) *Runner {
+	r.summary, r.summaryLogger = &summary, logger
+	return r
+}
+
+// WithHookTimeout sets the default timeout of each hook
+// (DefaultHookTimeout).
+func (r *Runner) WithHookTimeout(d time.Duration) *Runner {
+	r.hookTimeout = d
+	return r
+}
+
+// Run runs the startup hooks and the server, then blocks until ctx is
+// canceled, a termination signal arrives, or the server fails. It returns
+// the first fatal error, if any. When a startup hook fails, the shutdown
+// hooks of the completed phases run and the ErrStartup error is returned.
+// Hooks run on a lifecycle.Lifecycle: a failing, panicking or timed out
+// shutdown hook does not keep the others from running.
+func (r *Runner) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	lc := r.newLifecycle()
+	if err := lc.Start(ctx); err != nil {
+		_ = r.stopHooks(lc)
+		return r.startError(err)
+	}
+	if r.summary != nil {
+		r.summary.LogTo(r.summaryLogger)
+	}
+
+	serveErr := make(chan error, 1)
+	lifecycle.Go(context.WithoutCancel(ctx), "http.server", func(context.Context) error {
+		slog.Info("HTTP server starting", slog.String("addr", r.server.Addr), slog.Bool("tls", r.server.TLSConfig != nil))
+		var err error
+		if r.server.TLSConfig != nil {
+			err = r.server.ListenAndServeTLS("", "")
+		} else {
+			err = r.server.ListenAndServe()
+		}
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		serveErr <- err
+		return nil
+	}, lifecycle.WorkerOptions{Once: true})
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+	case runErr = <-serveErr:
+	}
+
+	if r.health != nil {
+		r.health.BeginShutdown()
+		if r.cfg.DrainDelay > 0 {
+			slog.Info("Draining before shutdown", slog.Duration("delay", r.cfg.DrainDelay))
+			time.Sleep(r.cfg.DrainDelay)
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), r.cfg.ShutdownTimeout)
+	defer cancel()
+
+	slog.Info("HTTP server shutting down")
+	if err := r.server.Shutdown(shutdownCtx); err != nil && runErr == nil {
+		runErr = fmt.Errorf("shutdown server: %w", err)
+	}
+	if err := r.stopHooks(lc); err != nil && runErr == nil {
+		runErr = err
+	}
+	return runErr
+}
+
+// stopHooks runs the shutdown hooks of the phases that started within the
+// shutdown timeout.
+func (r *Runner) stopHooks(lc *lifecycle.Lifecycle) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.cfg.ShutdownTimeout)
+	defer cancel()
+	return lc.Stop(ctx)
+}
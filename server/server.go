@@ -0,0 +1,184 @@
+// Package server builds production HTTP servers from configuration and runs
+// them with graceful shutdown.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/serviceauth"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Defaults applied when the corresponding SystemConfig field is zero.
+const (
+	DefaultPort              = "8080"
+	DefaultReadTimeout       = 30 * time.Second
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultWriteTimeout      = 30 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+	DefaultMaxHeaderBytes    = 1 << 20 // 1 MB
+	DefaultShutdownTimeout   = 15 * time.Second
+)
+
+// WithDefaults returns cfg with zero server settings replaced by defaults.
+func WithDefaults(cfg config.SystemConfig) config.SystemConfig {
+	if cfg.Port == "" {
+		cfg.Port = DefaultPort
+	}
+	if cfg.ReadTimeout <= 0 {
+		cfg.ReadTimeout = DefaultReadTimeout
+	}
+	if cfg.ReadHeaderTimeout <= 0 {
+		cfg.ReadHeaderTimeout = DefaultReadHeaderTimeout
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = DefaultWriteTimeout
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = DefaultIdleTimeout
+	}
+	if cfg.MaxHeaderBytes <= 0 {
+		cfg.MaxHeaderBytes = DefaultMaxHeaderBytes
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	return cfg
+}
+
+// Addr returns the listen address for cfg, e.g. "0.0.0.0:8080" or ":8080".
+func Addr(cfg config.SystemConfig) string {
+	port := strings.TrimPrefix(cfg.Port, ":")
+	if port == "" {
+		port = DefaultPort
+	}
+	return net.JoinHostPort(cfg.Host, port)
+}
+
+// NewHTTPServer creates an http.Server for handler configured from cfg.
+// Zero timeouts and limits are filled with safe defaults. When TLS is
+// configured, srv.TLSConfig is set and the server must be started with
+// ListenAndServeTLS("", "") (Runner does this automatically).
+func NewHTTPServer(cfg config.SystemConfig, handler http.Handler) (*http.Server, error) {
+	cfg = WithDefaults(cfg)
+
+	srv := &http.Server{
+		Addr:              Addr(cfg),
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+
+	tlsConfig, err := NewTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	srv.TLSConfig = tlsConfig
+
+	return srv, nil
+}
+
+// NewTLSConfig assembles the TLS configuration from cfg.TLS.
+// Returns nil if TLS is not configured.
+//
+// With Reload set, the key pair is reloaded whenever its files change, for
+// the life of the process (see CertReloader).
+//
+// Autocert answers ACME TLS-ALPN-01 challenges on the TLS listener; it is
+// refused when Env is "dev" unless AllowInDev is set, so development machines
+// never request real certificates by accident.
+//
+// With ClientCAFile set, clients authenticate with certificates of its CAs
+// (mutual TLS), required unless ClientAuth is "request", and limited to
+// those with a SAN of ClientSANs when set; with autocert, ClientAuth must
+// be "request" for ACME challenges to get through. middleware.ServiceAuth
+// can then take the certificate as the identity of the calling service.
+func NewTLSConfig(cfg config.SystemConfig) (*tls.Config, error) {
+	tc, err := newTLSConfig(cfg)
+	t := cfg.TLS
+	if err != nil || t.ClientCAFile == "" {
+		return tc, err
+	}
+	if tc == nil {
+		return nil, fmt.Errorf("tls client_ca_file requires cert_file or autocert")
+	}
+	if tc.ClientCAs, err = serviceauth.LoadCertPool(t.ClientCAFile); err != nil {
+		return nil, fmt.Errorf("tls client_ca_file: %w", err)
+	}
+	switch t.ClientAuth {
+	case "", "require":
+		tc.ClientAuth = tls.RequireAndVerifyClientCert
+	case "request":
+		tc.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf("tls client_auth: unknown mode %q", t.ClientAuth)
+	}
+	tc.VerifyConnection = serviceauth.VerifySANs(t.ClientSANs)
+	return tc, nil
+}
+
+func newTLSConfig(cfg config.SystemConfig) (*tls.Config, error) {
+	t := cfg.TLS
+
+	switch {
+	case t.Autocert.Enabled:
+		if cfg.Env == "dev" && !t.Autocert.AllowInDev {
+			return nil, fmt.Errorf("autocert is disabled in dev environment (set tls.autocert.allow_in_dev to override)")
+		}
+		if len(t.Autocert.Hosts) == 0 {
+			return nil, fmt.Errorf("autocert requires at least one host in tls.autocert.hosts")
+		}
+		cacheDir := t.Autocert.CacheDir
+		if cacheDir == "" {
+			cacheDir = "certs"
+		}
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(t.Autocert.Hosts...),
+			Cache:      autocert.DirCache(cacheDir),
+			Email:      t.Autocert.Email,
+		}
+		tc := m.TLSConfig()
+		tc.MinVersion = tls.VersionTLS12
+		return tc, nil
+
+	case t.CertFile != "" || t.KeyFile != "":
+		if t.CertFile == "" || t.KeyFile == "" {
+			return nil, fmt.Errorf("tls requires both cert_file and key_file")
+		}
+		if t.Reload {
+			r, err := NewCertReloader(t.CertFile, t.KeyFile)
+			if err != nil {
+				return nil, err
+			}
+			if err := r.Watch(context.Background()); err != nil {
+				return nil, fmt.Errorf("watch tls key pair: %w", err)
+			}
+			return &tls.Config{
+				GetCertificate: r.GetCertificate,
+				MinVersion:     tls.VersionTLS12,
+			}, nil
+		}
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load tls key pair: %w", err)
+		}
+		return &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}, nil
+
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,316 @@
+// Package serviceauth authenticates internal calls between services with
+// short-lived service tokens: JWTs signed by the calling service (HS256
+// with a shared secret, or Ed25519 with a key per service) naming it and
+// the service it calls.
+//
+// The caller mints tokens with a Signer, attached by
+// httpclient.WithServiceToken; the callee validates them with a Verifier
+// in middleware.ServiceAuth, which records the calling service apart from
+// the user:
+//
+//	// billing, calling orders
+//	signer, err := serviceauth.NewSigner(cfg.ServiceAuth)
+//	client := httpclient.New(httpclient.WithBaseURL(ordersURL), httpclient.WithServiceToken(signer, "orders"))
+//
+//	// orders
+//	verifier, err := serviceauth.NewVerifier(cfg.ServiceAuth)
+//	internal := e.Group("/internal", middleware.ServiceAuth(middleware.ServiceAuthConfig{Verifier: verifier}))
+//
+// Tokens travel in the Header header, so a user token can travel along in
+// Authorization.
+package serviceauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Header is the request header carrying service tokens.
+const Header = "X-Service-Token"
+
+// Defaults applied when the corresponding ServiceAuthConfig field is zero.
+const (
+	DefaultTokenTTL = 5 * time.Minute
+	DefaultLeeway   = 30 * time.Second
+)
+
+// Algorithms of ServiceAuthConfig.Algorithm.
+const (
+	AlgorithmHS256   = "hs256"
+	AlgorithmEd25519 = "ed25519"
+)
+
+// Reasons are the "reason" detail of the errors of Verify and
+// middleware.ServiceAuth, telling failed service authentication apart
+// from failed user authentication.
+const (
+	ReasonMissing          = "service_token_missing"
+	ReasonExpired          = "service_token_expired"
+	ReasonInvalid          = "service_token_invalid"
+	ReasonAudienceMismatch = "service_audience_mismatch"
+	ReasonNotAllowed       = "service_not_allowed"
+	ReasonClientCert       = "service_client_cert"
+)
+
+// Claims are the claims of a service token: the calling service is the
+// issuer and the called one the audience.
+type Claims struct {
+	jwt.RegisteredClaims
+}
+
+// Service returns the calling service.
+func (c *Claims) Service() string {
+	return c.Issuer
+}
+
+// Options configure a Signer or a Verifier.
+type Options struct {
+	// Clock defaults to utils.RealClock.
+	Clock utils.Clock
+}
+
+func clockOf(opts []Options) utils.Clock {
+	if len(opts) > 0 && opts[0].Clock != nil {
+		return opts[0].Clock
+	}
+	return utils.RealClock{}
+}
+
+// Signer mints the service tokens of a service. Tokens are cached per
+// audience and minted again when half their lifetime has passed.
+type Signer struct {
+	service string
+	method  jwt.SigningMethod
+	key     any
+	ttl     time.Duration
+	clock   utils.Clock
+
+	mu     sync.Mutex
+	tokens map[string]cachedToken
+}
+
+type cachedToken struct {
+	token   string
+	renewAt time.Time
+}
+
+// NewSigner creates the Signer of cfg.Service, with cfg.Secret (hs256) or
+// the key of cfg.PrivateKeyFile (ed25519).
+func NewSigner(cfg config.ServiceAuthConfig, opts ...Options) (*Signer, error) {
+	if cfg.Service == "" {
+		return nil, fmt.Errorf("service auth: service name required")
+	}
+	s := &Signer{service: cfg.Service, ttl: cfg.TokenTTL, clock: clockOf(opts), tokens: map[string]cachedToken{}}
+	if s.ttl <= 0 {
+		s.ttl = DefaultTokenTTL
+	}
+	switch cfg.Algorithm {
+	case "", AlgorithmHS256:
+		if cfg.Secret == "" {
+			return nil, fmt.Errorf("service auth: secret required with hs256")
+		}
+		s.method, s.key = jwt.SigningMethodHS256, []byte(cfg.Secret)
+	case AlgorithmEd25519:
+		key, err := readPEM(cfg.PrivateKeyFile, jwt.ParseEdPrivateKeyFromPEM)
+		if err != nil {
+			return nil, err
+		}
+		s.method, s.key = jwt.SigningMethodEdDSA, key
+	default:
+		return nil, fmt.Errorf("service auth: unknown algorithm %q", cfg.Algorithm)
+	}
+	return s, nil
+}
+
+// Service returns the name of the service the tokens are minted for.
+func (s *Signer) Service() string {
+	return s.service
+}
+
+// Token returns a token of the service for calls to audience.
+func (s *Signer) Token(audience string) (string, error) {
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.tokens[audience]; ok && now.Before(t.renewAt) {
+		return t.token, nil
+	}
+	claims := Claims{RegisteredClaims: jwt.RegisteredClaims{
+		Issuer:    s.service,
+		Subject:   s.service,
+		Audience:  jwt.ClaimStrings{audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(s.ttl)),
+		ID:        utils.NewULID(),
+	}}
+	token, err := jwt.NewWithClaims(s.method, claims).SignedString(s.key)
+	if err != nil {
+		return "", fmt.Errorf("service auth: sign token: %w", err)
+	}
+	s.tokens[audience] = cachedToken{token: token, renewAt: now.Add(s.ttl / 2)}
+	return token, nil
+}
+
+// Verifier validates the service tokens sent to a service.
+type Verifier struct {
+	audience string
+	method   jwt.SigningMethod
+	secret   []byte
+	keys     map[string]crypto.PublicKey
+	allowed  []string
+	parser   *jwt.Parser
+}
+
+// NewVerifier creates the Verifier of cfg.Service, accepting tokens for
+// it signed with cfg.Secret (hs256) or the key of their issuer in
+// cfg.PublicKeyFiles (ed25519), within cfg.Leeway of clock skew.
+func NewVerifier(cfg config.ServiceAuthConfig, opts ...Options) (*Verifier, error) {
+	if cfg.Service == "" {
+		return nil, fmt.Errorf("service auth: service name required")
+	}
+	v := &Verifier{audience: cfg.Service, allowed: cfg.AllowedServices}
+	switch cfg.Algorithm {
+	case "", AlgorithmHS256:
+		if cfg.Secret == "" {
+			return nil, fmt.Errorf("service auth: secret required with hs256")
+		}
+		v.method, v.secret = jwt.SigningMethodHS256, []byte(cfg.Secret)
+	case AlgorithmEd25519:
+		v.method, v.keys = jwt.SigningMethodEdDSA, map[string]crypto.PublicKey{}
+		for service, path := range cfg.PublicKeyFiles {
+			key, err := readPEM(path, jwt.ParseEdPublicKeyFromPEM)
+			if err != nil {
+				return nil, fmt.Errorf("%w (service %s)", err, service)
+			}
+			v.keys[service] = key
+		}
+	default:
+		return nil, fmt.Errorf("service auth: unknown algorithm %q", cfg.Algorithm)
+	}
+	leeway := cfg.Leeway
+	if leeway <= 0 {
+		leeway = DefaultLeeway
+	}
+	v.parser = jwt.NewParser(
+		jwt.WithValidMethods([]string{v.method.Alg()}),
+		jwt.WithAudience(cfg.Service),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(leeway),
+		jwt.WithTimeFunc(clockOf(opts).Now),
+	)
+	return v, nil
+}
+
+// Verify validates token and returns its claims. Invalid tokens fail with
+// code.ErrUnauthorized and callers outside cfg.AllowedServices with
+// code.ErrPermissionDenied, both with a "reason" detail (see Reasons).
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	var claims Claims
+	_, err := v.parser.ParseWithClaims(token, &claims, v.keyfunc)
+	switch {
+	case err == nil:
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return nil, Error(err, ReasonExpired)
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return nil, Error(err, ReasonAudienceMismatch)
+	default:
+		return nil, Error(err, ReasonInvalid)
+	}
+	if claims.Issuer == "" {
+		return nil, Error(nil, ReasonInvalid)
+	}
+	if !v.Allowed(claims.Issuer) {
+		return nil, Error(nil, ReasonNotAllowed)
+	}
+	return &claims, nil
+}
+
+// Allowed reports whether service may call: it is in
+// cfg.AllowedServices, or that list is empty.
+func (v *Verifier) Allowed(service string) bool {
+	return len(v.allowed) == 0 || slices.Contains(v.allowed, service)
+}
+
+func (v *Verifier) keyfunc(t *jwt.Token) (any, error) {
+	if v.secret != nil {
+		return v.secret, nil
+	}
+	claims, _ := t.Claims.(*Claims)
+	if claims == nil {
+		return nil, fmt.Errorf("no claims")
+	}
+	key, ok := v.keys[claims.Issuer]
+	if !ok {
+		return nil, fmt.Errorf("no key for service %q", claims.Issuer)
+	}
+	return key, nil
+}
+
+// Error returns the error of a failed service authentication: reason
+// ReasonNotAllowed is code.ErrPermissionDenied, the others
+// code.ErrUnauthorized. The reason is the "reason" detail.
+func Error(cause error, reason string) error {
+	errCode, msg := code.ErrUnauthorized, "service authentication failed"
+	if reason == ReasonNotAllowed {
+		errCode, msg = code.ErrPermissionDenied, "service not allowed"
+	}
+	var err error
+	if cause != nil {
+		err = code.WrapError(cause, errCode, msg)
+	} else {
+		err = code.NewError(errCode, msg)
+	}
+	return errors.WithDetails(err, map[string]any{"reason": reason})
+}
+
+// readPEM reads the key of path with parse.
+func readPEM[K any](path string, parse func([]byte) (K, error)) (K, error) {
+	var zero K
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return zero, fmt.Errorf("service auth: read key: %w", err)
+	}
+	key, err := parse(b)
+	if err != nil {
+		return zero, fmt.Errorf("service auth: parse key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// GenerateKey returns a new Ed25519 key pair as PKCS#8 and PKIX PEM, for
+// PrivateKeyFile and PublicKeyFiles.
+func GenerateKey() (privatePEM, publicPEM []byte, err error) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodeKeyPair(priv, pub)
+}
+
+type callerKey struct{}
+
+// WithCaller returns a copy of ctx carrying the calling service.
+func WithCaller(ctx context.Context, service string) context.Context {
+	return context.WithValue(ctx, callerKey{}, service)
+}
+
+// CallerFromContext returns the calling service of ctx, set by
+// middleware.ServiceAuth.
+func CallerFromContext(ctx context.Context) (string, bool) {
+	s, ok := ctx.Value(callerKey{}).(string)
+	return s, ok && s != ""
+}
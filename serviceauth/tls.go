@@ -0,0 +1,100 @@
+package serviceauth
+
+import (
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func encodeKeyPair(priv ed25519.PrivateKey, pub ed25519.PublicKey) (privatePEM, publicPEM []byte, err error) {
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), nil
+}
+
+// SANs returns the subject alternative names of cert: URIs, DNS names,
+// emails, then IPs.
+func SANs(cert *x509.Certificate) []string {
+	var sans []string
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	return sans
+}
+
+// MatchSAN returns the first SAN of cert matching one of patterns, exactly
+// or, for patterns ending with *, by prefix.
+func MatchSAN(cert *x509.Certificate, patterns []string) (string, bool) {
+	for _, san := range SANs(cert) {
+		for _, p := range patterns {
+			if prefix, ok := strings.CutSuffix(p, "*"); (ok && strings.HasPrefix(san, prefix)) || san == p {
+				return san, true
+			}
+		}
+	}
+	return "", false
+}
+
+// VerifySANs returns a tls.Config.VerifyConnection refusing client
+// certificates without a SAN matching patterns (see MatchSAN). Clients
+// without a certificate are left to ClientAuth.
+func VerifySANs(patterns []string) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 || len(patterns) == 0 {
+			return nil
+		}
+		if _, ok := MatchSAN(cs.PeerCertificates[0], patterns); !ok {
+			return fmt.Errorf("client certificate %q has no allowed SAN", cs.PeerCertificates[0].Subject)
+		}
+		return nil
+	}
+}
+
+// LoadCertPool reads the PEM certificates of path.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read ca file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("ca file %s: no PEM certificates", path)
+	}
+	return pool, nil
+}
+
+// ClientTLSConfig returns the TLS configuration of a client presenting the
+// key pair of certFile and keyFile to servers requiring mutual TLS, and
+// trusting the CAs of caFile (default the system roots):
+//
+//	tc, err := serviceauth.ClientTLSConfig("/etc/tls/tls.crt", "/etc/tls/tls.key", "/etc/tls/ca.crt")
+//	client := httpclient.New(httpclient.WithTLS(tc), httpclient.WithServiceToken(signer, "orders"))
+func ClientTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load client key pair: %w", err)
+	}
+	tc := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		if tc.RootCAs, err = LoadCertPool(caFile); err != nil {
+			return nil, err
+		}
+	}
+	return tc, nil
+}
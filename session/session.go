@@ -0,0 +1,265 @@
+// Package session provides revocable server-side sessions stored in a
+// cache.Cache, with idle and absolute timeouts and per-user limits.
+//
+//	store := session.NewStore(redisCache, session.Options{IdleTTL: 30 * time.Minute, MaxPerUser: 5})
+//	s, err := store.Create(ctx, userID, map[string]any{"role": "admin"})
+//	// hand s.ID to the client (middleware.SetSessionCookie)
+//	...
+//	_, err = store.DestroyUser(ctx, userID) // logout everywhere
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/NSObjects/go-kit/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNotFound is returned for unknown, expired or destroyed sessions.
+var ErrNotFound = errors.New("session not found")
+
+// Session is a server-side session. ID is opaque and safe to hand to
+// clients.
+type Session struct {
+	ID        string         `json:"id"`
+	UserID    string         `json:"user_id"`
+	Data      map[string]any `json:"data,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	LastSeen  time.Time      `json:"last_seen"`
+	ExpiresAt time.Time      `json:"expires_at"` // absolute expiry
+}
+
+// Options configures a Store. Zero values use the defaults.
+type Options struct {
+	// Prefix prefixes cache keys; default "session".
+	Prefix string
+	// IdleTTL expires sessions not refreshed for this long; default 30m.
+	IdleTTL time.Duration
+	// AbsoluteTTL caps the session lifetime regardless of activity;
+	// default 24h.
+	AbsoluteTTL time.Duration
+	// MaxPerUser limits concurrent sessions per user; creating one more
+	// destroys the oldest. 0 is unlimited.
+	MaxPerUser int
+}
+
+// Store manages sessions in a cache.Cache. The sessions of each user are
+// indexed in a set (cache.SetStore), updated atomically, so that logins on
+// several instances do not lose each other's entries; concurrent logins of
+// the same user may still briefly exceed MaxPerUser. DestroyUser and
+// MaxPerUser need a cache with sets, such as cache.RedisCache; Refresh is
+// atomic on caches implementing cache.Replacer.
+type Store struct {
+	cache cache.Cache
+	opts  Options
+}
+
+// NewStore creates a session store.
+func NewStore(c cache.Cache, opts Options) *Store {
+	if opts.Prefix == "" {
+		opts.Prefix = "session"
+	}
+	if opts.IdleTTL <= 0 {
+		opts.IdleTTL = 30 * time.Minute
+	}
+	if opts.AbsoluteTTL <= 0 {
+		opts.AbsoluteTTL = 24 * time.Hour
+	}
+	return &Store{cache: c, opts: opts}
+}
+
+// Options returns the effective options.
+func (s *Store) Options() Options {
+	return s.opts
+}
+
+// Create starts a session for userID, destroying the user's oldest
+// sessions beyond MaxPerUser.
+func (s *Store) Create(ctx context.Context, userID string, data map[string]any) (*Session, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	sess := &Session{
+		ID:        id,
+		UserID:    userID,
+		Data:      data,
+		CreatedAt: now,
+		LastSeen:  now,
+		ExpiresAt: now.Add(s.opts.AbsoluteTTL),
+	}
+	if err := s.cache.Set(ctx, s.sessionKey(id), sess, s.ttl(sess, now)); err != nil {
+		return nil, fmt.Errorf("session create: %w", err)
+	}
+
+	if err := cache.SetAdd(ctx, s.cache, s.indexKey(userID), s.opts.AbsoluteTTL, id); err != nil {
+		if errors.Is(err, cache.ErrSetUnsupported) && s.opts.MaxPerUser <= 0 {
+			return sess, nil
+		}
+		return nil, fmt.Errorf("session index: %w", err)
+	}
+	if limit := s.opts.MaxPerUser; limit > 0 {
+		live, err := s.liveSessions(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if len(live) > limit {
+			for _, old := range live[:len(live)-limit] {
+				if err := s.cache.Delete(ctx, s.sessionKey(old.ID)); err != nil {
+					return nil, fmt.Errorf("session evict: %w", err)
+				}
+				if err := cache.SetRemove(ctx, s.cache, s.indexKey(userID), old.ID); err != nil {
+					return nil, fmt.Errorf("session index: %w", err)
+				}
+			}
+		}
+	}
+	return sess, nil
+}
+
+// Get returns the session with id, or ErrNotFound.
+func (s *Store) Get(ctx context.Context, id string) (*Session, error) {
+	if id == "" {
+		return nil, ErrNotFound
+	}
+	var sess Session
+	if err := s.cache.Get(ctx, s.sessionKey(id), &sess); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("session get: %w", err)
+	}
+	if !time.Now().Before(sess.ExpiresAt) {
+		_ = s.cache.Delete(ctx, s.sessionKey(id))
+		return nil, ErrNotFound
+	}
+	return &sess, nil
+}
+
+// Refresh renews the idle timeout of sess and persists its Data. It
+// returns ErrNotFound once the absolute timeout has passed, or when the
+// session was destroyed meanwhile: the write only replaces an existing
+// session (cache.Replacer), so a request that loaded the session before a
+// Destroy or DestroyUser cannot bring it back. Caches without Replace
+// check for the session first, leaving a short race.
+func (s *Store) Refresh(ctx context.Context, sess *Session) error {
+	now := time.Now()
+	ttl := s.ttl(sess, now)
+	if ttl <= 0 {
+		_ = s.cache.Delete(ctx, s.sessionKey(sess.ID))
+		return ErrNotFound
+	}
+	sess.LastSeen = now
+	key := s.sessionKey(sess.ID)
+	ok, err := cache.Replace(ctx, s.cache, key, sess, ttl)
+	if errors.Is(err, cache.ErrReplaceUnsupported) {
+		if ok, err = s.cache.Exists(ctx, key); err == nil && ok {
+			err = s.cache.Set(ctx, key, sess, ttl)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("session refresh: %w", err)
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Destroy ends the session with id. Unknown sessions are ignored.
+func (s *Store) Destroy(ctx context.Context, id string) error {
+	sess, err := s.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.cache.Delete(ctx, s.sessionKey(id)); err != nil {
+		return fmt.Errorf("session destroy: %w", err)
+	}
+	err = cache.SetRemove(ctx, s.cache, s.indexKey(sess.UserID), id)
+	if err != nil && !errors.Is(err, cache.ErrSetUnsupported) {
+		return fmt.Errorf("session index: %w", err)
+	}
+	return nil
+}
+
+// DestroyUser ends every session of userID (logout everywhere) and
+// returns how many were destroyed. It returns cache.ErrSetUnsupported for
+// caches without sets.
+func (s *Store) DestroyUser(ctx context.Context, userID string) (int, error) {
+	ids, err := cache.SetMembers(ctx, s.cache, s.indexKey(userID))
+	if err != nil {
+		return 0, fmt.Errorf("session index: %w", err)
+	}
+	for _, id := range ids {
+		if err := s.cache.Delete(ctx, s.sessionKey(id)); err != nil {
+			return 0, fmt.Errorf("session destroy: %w", err)
+		}
+	}
+	if err := cache.SetRemove(ctx, s.cache, s.indexKey(userID), ids...); err != nil {
+		return 0, fmt.Errorf("session index: %w", err)
+	}
+	return len(ids), nil
+}
+
+// ttl is the cache TTL of sess: the idle timeout, capped by the absolute
+// expiry.
+func (s *Store) ttl(sess *Session, now time.Time) time.Duration {
+	return min(s.opts.IdleTTL, sess.ExpiresAt.Sub(now))
+}
+
+// liveSessions returns the sessions in the user's index, oldest first,
+// dropping the expired and destroyed ones from the index.
+func (s *Store) liveSessions(ctx context.Context, userID string) ([]*Session, error) {
+	ids, err := cache.SetMembers(ctx, s.cache, s.indexKey(userID))
+	if err != nil {
+		return nil, fmt.Errorf("session index: %w", err)
+	}
+	live := make([]*Session, 0, len(ids))
+	var gone []string
+	for _, id := range ids {
+		sess, err := s.Get(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			gone = append(gone, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		live = append(live, sess)
+	}
+	if err := cache.SetRemove(ctx, s.cache, s.indexKey(userID), gone...); err != nil {
+		return nil, fmt.Errorf("session index: %w", err)
+	}
+	sort.SliceStable(live, func(i, j int) bool { return live[i].CreatedAt.Before(live[j].CreatedAt) })
+	return live, nil
+}
+
+func (s *Store) sessionKey(id string) string {
+	return s.opts.Prefix + ":" + id
+}
+
+// indexKey is the set of the session IDs of userID. It differs from the
+// key of the former JSON index, so that an old index left in the cache
+// does not make the set commands fail with WRONGTYPE.
+func (s *Store) indexKey(userID string) string {
+	return s.opts.Prefix + ":user:" + userID + ":sessions"
+}
+
+// newID returns 256 random bits, base64url encoded.
+func newID() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("session id: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:]), nil
+}
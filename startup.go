@@ -0,0 +1,210 @@
+package kit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/NSObjects/go-kit/config"
+)
+
+// Component is a connected dependency of a service, as reported in the
+// startup summary.
+type Component struct {
+	// Name identifies the component, e.g. "database" or "redis".
+	Name string `json:"name"`
+	// Kind is its driver or flavor, e.g. "mysql".
+	Kind string `json:"kind,omitempty"`
+	// Endpoint is where it is connected, with credentials masked (see
+	// config.RedactEndpoint).
+	Endpoint string `json:"endpoint"`
+}
+
+// ComponentLister lists the connected components of a service;
+// db.Manager implements it.
+type ComponentLister interface {
+	Components() []Component
+}
+
+// InfoLogger is the logger of LogStartupSummary; log.Logger implements it.
+type InfoLogger interface {
+	Info(msg string, attrs ...slog.Attr)
+}
+
+// LogSummary describes the logging of a service.
+type LogSummary struct {
+	Level  string `json:"level"`
+	Format string `json:"format"`
+	Output string `json:"output"`
+	File   string `json:"file,omitempty"`
+}
+
+// OtelSummary describes the tracing of a service.
+type OtelSummary struct {
+	Enabled       bool    `json:"enabled"`
+	Exporter      string  `json:"exporter,omitempty"`
+	Endpoint      string  `json:"endpoint,omitempty"`
+	SamplingRatio float64 `json:"sampling_ratio,omitempty"`
+}
+
+// StartupSummary is what a service runs with, logged once at startup.
+// It holds no secrets: endpoints are masked and only non-sensitive
+// settings are read from the config.
+type StartupSummary struct {
+	Service    string      `json:"service"`
+	Version    string      `json:"version"`
+	Env        string      `json:"env"`
+	Revision   string      `json:"revision,omitempty"`
+	Listen     string      `json:"listen"`
+	TLS        bool        `json:"tls"`
+	Middleware []string    `json:"middleware"`
+	Components []Component `json:"components"`
+	Log        LogSummary  `json:"log"`
+	Otel       OtelSummary `json:"otel"`
+}
+
+// NewStartupSummary assembles the summary of the service configured by
+// cfg. components may be nil; middleware comes from setup, recorded by
+// middleware.Setup, as "name" for global and "name@scope" for group
+// middleware.
+func NewStartupSummary(cfg config.Config, components ComponentLister, setup SetupRecord) StartupSummary {
+	build := readBuild()
+	profile := Resolve(cfg)
+	s := StartupSummary{
+		Service:    cfg.System.Name,
+		Version:    cfg.System.Version,
+		Env:        cfg.System.Env,
+		Revision:   build.Revision,
+		TLS:        cfg.System.TLS.CertFile != "" || cfg.System.TLS.Autocert.Enabled,
+		Middleware: []string{},
+		Components: []Component{},
+		Log: LogSummary{
+			Level:  profile.LogLevel,
+			Format: profile.LogFormat,
+			Output: cfg.Log.Output,
+			File:   cfg.Log.File.Filename,
+		},
+		Otel: OtelSummary{Enabled: cfg.Otel.Enabled},
+	}
+	if s.Version == "" {
+		s.Version = build.Module
+	}
+	if port := strings.TrimPrefix(cfg.System.Port, ":"); port != "" {
+		s.Listen = net.JoinHostPort(cfg.System.Host, port)
+	}
+	if s.Log.Output == "" {
+		s.Log.Output = "stdout"
+	}
+	for _, m := range setup.Middleware {
+		name := m.Name
+		if m.Scope != "" && m.Scope != "global" {
+			name += "@" + m.Scope
+		}
+		s.Middleware = append(s.Middleware, name)
+	}
+	if components != nil {
+		for _, c := range components.Components() {
+			c.Endpoint = config.RedactEndpoint(c.Endpoint)
+			s.Components = append(s.Components, c)
+		}
+	}
+	if cfg.Otel.Enabled {
+		s.Otel.Exporter = cfg.Otel.ExporterType
+		s.Otel.Endpoint = config.RedactEndpoint(cfg.Otel.OTLPEndpoint)
+		s.Otel.SamplingRatio = cfg.Otel.SamplingRatio
+	}
+	return s
+}
+
+// Attrs returns the summary as log attributes.
+func (s StartupSummary) Attrs() []slog.Attr {
+	components := make([]any, 0, len(s.Components))
+	for _, c := range s.Components {
+		components = append(components, slog.Group(c.Name, slog.String("kind", c.Kind), slog.String("endpoint", c.Endpoint)))
+	}
+	attrs := []slog.Attr{
+		slog.String("service", s.Service),
+		slog.String("version", s.Version),
+		slog.String("env", s.Env),
+	}
+	if s.Revision != "" {
+		attrs = append(attrs, slog.String("revision", s.Revision))
+	}
+	return append(attrs,
+		slog.String("listen", s.Listen),
+		slog.Bool("tls", s.TLS),
+		slog.Any("middleware", s.Middleware),
+		slog.Group("components", components...),
+		slog.Group("log",
+			slog.String("level", s.Log.Level),
+			slog.String("format", s.Log.Format),
+			slog.String("output", s.Log.Output),
+			slog.String("file", s.Log.File)),
+		slog.Group("otel",
+			slog.Bool("enabled", s.Otel.Enabled),
+			slog.String("exporter", s.Otel.Exporter),
+			slog.String("endpoint", s.Otel.Endpoint),
+			slog.Float64("sampling_ratio", s.Otel.SamplingRatio)),
+	)
+}
+
+// WriteText writes the summary as an aligned multi-line banner.
+func (s StartupSummary) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s %s (%s)\n", s.Service, s.Version, s.Env)
+	listen := s.Listen
+	if s.TLS {
+		listen += " (tls)"
+	}
+	fmt.Fprintf(tw, "  listen\t%s\n", listen)
+	fmt.Fprintf(tw, "  middleware\t%s\n", strings.Join(s.Middleware, ", "))
+	for _, c := range s.Components {
+		fmt.Fprintf(tw, "  %s\t%s %s\n", c.Name, c.Kind, c.Endpoint)
+	}
+	logDest := s.Log.Output
+	if s.Log.File != "" {
+		logDest += ", " + s.Log.File
+	}
+	fmt.Fprintf(tw, "  log\t%s %s to %s\n", s.Log.Level, s.Log.Format, logDest)
+	if s.Otel.Enabled {
+		fmt.Fprintf(tw, "  otel\t%s %s (sampling %g)\n", s.Otel.Exporter, s.Otel.Endpoint, s.Otel.SamplingRatio)
+	} else {
+		fmt.Fprintf(tw, "  otel\tdisabled\n")
+	}
+	return tw.Flush()
+}
+
+// LogTo logs the summary as one "Service started" record to logger
+// (default slog.Default()). In the dev environment the banner of WriteText
+// is also written to stderr.
+func (s StartupSummary) LogTo(logger InfoLogger) {
+	if logger == nil {
+		logger = slogInfo{slog.Default()}
+	}
+	logger.Info("Service started", s.Attrs()...)
+	if s.Env == "dev" {
+		_ = s.WriteText(os.Stderr)
+	}
+}
+
+// LogStartupSummary logs the summary of the service configured by cfg
+// (see NewStartupSummary and StartupSummary.LogTo). server.Runner does it
+// after a successful start when given the summary:
+//
+//	groups := middleware.Setup(e, deps)
+//	runner.WithStartupSummary(logger, kit.NewStartupSummary(cfg, manager, groups.Installed))
+func LogStartupSummary(logger InfoLogger, cfg config.Config, components ComponentLister, setup SetupRecord) {
+	NewStartupSummary(cfg, components, setup).LogTo(logger)
+}
+
+// slogInfo adapts a *slog.Logger to InfoLogger.
+type slogInfo struct{ l *slog.Logger }
+
+func (s slogInfo) Info(msg string, attrs ...slog.Attr) {
+	s.l.LogAttrs(context.Background(), slog.LevelInfo, msg, attrs...)
+}
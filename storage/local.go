@@ -0,0 +1,315 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/labstack/echo/v4"
+)
+
+// LocalStoreOptions configures presigned URLs of a LocalStore.
+type LocalStoreOptions struct {
+	// BaseURL is the public URL Handler is mounted at, e.g.
+	// "https://api.example.com/files".
+	BaseURL string
+	// SigningKey signs the presigned URLs.
+	SigningKey []byte
+}
+
+// LocalStore stores objects as files below a directory. Files are written
+// to a temporary file first and renamed into place, so readers never see
+// partial objects. The content type and metadata are kept in a hidden
+// sidecar file next to the object.
+type LocalStore struct {
+	dir        string
+	baseURL    string
+	signingKey []byte
+}
+
+// NewLocalStore creates a store rooted at dir.
+func NewLocalStore(dir string, opts ...LocalStoreOptions) *LocalStore {
+	s := &LocalStore{dir: dir}
+	if len(opts) > 0 {
+		s.baseURL = strings.TrimRight(opts[0].BaseURL, "/")
+		s.signingKey = opts[0].SigningKey
+	}
+	return s
+}
+
+// localMeta is the sidecar file of an object.
+type localMeta struct {
+	ContentType string            `json:"content_type,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// Put implements Store.
+func (s *LocalStore) Put(_ context.Context, key string, r io.Reader, opts PutOptions) error {
+	dst, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(dst)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return failed(err, "put", key)
+	}
+
+	meta, err := json.Marshal(localMeta{ContentType: opts.ContentType, Metadata: opts.Metadata})
+	if err != nil {
+		return failed(err, "put", key)
+	}
+	metaTmp, err := writeTemp(dir, bytes.NewReader(meta))
+	if err != nil {
+		return failed(err, "put", key)
+	}
+	defer os.Remove(metaTmp)
+	tmp, err := writeTemp(dir, r)
+	if err != nil {
+		return failed(err, "put", key)
+	}
+	defer os.Remove(tmp)
+
+	if err := os.Rename(tmp, dst); err != nil {
+		return failed(err, "put", key)
+	}
+	if err := os.Rename(metaTmp, metaPath(dst)); err != nil {
+		return failed(err, "put", key)
+	}
+	return nil
+}
+
+// writeTemp streams r to a new temporary file in dir and returns its path.
+func writeTemp(dir string, r io.Reader) (string, error) {
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// Get implements Store. The Object reads an *os.File, which is also an
+// io.Seeker.
+func (s *LocalStore) Get(_ context.Context, key string) (*Object, error) {
+	dst, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(dst)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, notFound(err, key)
+	}
+	if err != nil {
+		return nil, failed(err, "get", key)
+	}
+	st, err := f.Stat()
+	if err == nil && st.IsDir() {
+		err = fs.ErrNotExist
+	}
+	if err != nil {
+		f.Close()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, notFound(err, key)
+		}
+		return nil, failed(err, "get", key)
+	}
+
+	info := ObjectInfo{Key: key, Size: st.Size(), LastModified: st.ModTime()}
+	var meta localMeta
+	if data, err := os.ReadFile(metaPath(dst)); err == nil {
+		_ = json.Unmarshal(data, &meta)
+	}
+	info.ContentType, info.Metadata = meta.ContentType, meta.Metadata
+	if info.ContentType == "" {
+		info.ContentType = mime.TypeByExtension(path.Ext(key))
+	}
+	if info.ContentType == "" {
+		info.ContentType = defaultContentType
+	}
+	return &Object{ReadCloser: f, Info: info}, nil
+}
+
+// Delete implements Store.
+func (s *LocalStore) Delete(_ context.Context, key string) error {
+	dst, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	for _, p := range []string{dst, metaPath(dst)} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return failed(err, "delete", key)
+		}
+	}
+	return nil
+}
+
+// List implements Store. Hidden files, such as sidecars and partial
+// writes, are skipped.
+func (s *LocalStore) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	// Walk the deepest directory the prefix names only.
+	root := s.dir
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		dir, err := s.path(prefix[:i])
+		if err != nil {
+			return err
+		}
+		root = dir
+	}
+
+	var fnErr error
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if d.IsDir() {
+			// Skip directories outside the prefix.
+			if !strings.HasPrefix(key+"/", prefix) && !strings.HasPrefix(prefix, key+"/") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		st, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if fnErr = fn(ObjectInfo{Key: key, Size: st.Size(), LastModified: st.ModTime()}); fnErr != nil {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	switch {
+	case fnErr != nil:
+		return fnErr
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case err != nil:
+		return failed(err, "list", prefix)
+	}
+	return nil
+}
+
+// Presign implements Store. The URL points below the configured BaseURL
+// and is served by Handler.
+func (s *LocalStore) Presign(_ context.Context, key string, ttl time.Duration) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	if s.baseURL == "" || len(s.signingKey) == 0 {
+		return "", errors.New("storage: presigning requires a base URL and signing key")
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	segs := strings.Split(key, "/")
+	for i, seg := range segs {
+		segs[i] = url.PathEscape(seg)
+	}
+	q := url.Values{"expires": {expires}, "signature": {s.sign(key, expires)}}
+	return s.baseURL + "/" + strings.Join(segs, "/") + "?" + q.Encode(), nil
+}
+
+func (s *LocalStore) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(mac, "%s\n%s", key, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Handler serves the objects of presigned URLs, with range requests. Mount
+// it at the path of BaseURL with a wildcard:
+//
+//	e.GET("/files/*", store.Handler())
+//
+// Missing or expired signatures are rejected with code.ErrForbidden (403).
+func (s *LocalStore) Handler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Echo routes on the raw path when it has one, leaving the key
+		// escaped.
+		key := c.Param("*")
+		if c.Request().URL.RawPath != "" {
+			k, err := url.PathUnescape(key)
+			if err != nil {
+				return code.NewError(code.ErrForbidden, "storage: invalid signature")
+			}
+			key = k
+		}
+		expires, signature := c.QueryParam("expires"), c.QueryParam("signature")
+		if len(s.signingKey) == 0 || !hmac.Equal([]byte(signature), []byte(s.sign(key, expires))) {
+			return code.NewError(code.ErrForbidden, "storage: invalid signature")
+		}
+		if unix, err := strconv.ParseInt(expires, 10, 64); err != nil || time.Now().Unix() > unix {
+			return code.NewError(code.ErrForbidden, "storage: presigned URL expired")
+		}
+
+		obj, err := s.Get(c.Request().Context(), key)
+		if err != nil {
+			return err
+		}
+		defer obj.Close()
+		c.Response().Header().Set(echo.HeaderContentType, obj.Info.ContentType)
+		http.ServeContent(c.Response(), c.Request(), path.Base(key), obj.Info.LastModified, obj.ReadCloser.(io.ReadSeeker))
+		return nil
+	}
+}
+
+// path maps key to a file below dir, rejecting keys that escape it.
+func (s *LocalStore) path(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	clean := filepath.FromSlash(key)
+	if !filepath.IsLocal(clean) {
+		return "", invalidKey(key)
+	}
+	return filepath.Join(s.dir, clean), nil
+}
+
+// metaPath returns the sidecar file of the object stored at file.
+func metaPath(file string) string {
+	return filepath.Join(filepath.Dir(file), "."+filepath.Base(file)+".meta")
+}
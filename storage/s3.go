@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/NSObjects/go-kit/config"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	// DefaultS3PartSize is the multipart chunk of uploads of unknown size.
+	// The client buffers one chunk per upload.
+	DefaultS3PartSize = 16 << 20
+	// s3CredentialsTimeout bounds the resolution of secret keys, which the
+	// client asks for without a context.
+	s3CredentialsTimeout = 10 * time.Second
+)
+
+// S3Store stores objects in a bucket of an S3-compatible service (AWS S3,
+// MinIO, ...).
+type S3Store struct {
+	client   *minio.Client
+	bucket   string
+	partSize uint64
+}
+
+// NewS3Store creates an S3Store. Secret references in the keys are
+// resolved with secrets (which may be nil) as requests are signed, so
+// rotated keys are picked up once the resolver cache expires.
+func NewS3Store(cfg config.S3Config, secrets *config.SecretResolver) (*S3Store, error) {
+	var creds *credentials.Credentials
+	if secrets.IsRef(cfg.AccessKey) || secrets.IsRef(cfg.SecretKey) {
+		creds = credentials.New(&secretCredentials{accessKey: cfg.AccessKey, secretKey: cfg.SecretKey, secrets: secrets})
+	} else {
+		creds = credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, "")
+	}
+	lookup := minio.BucketLookupAuto
+	if cfg.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:        creds,
+		Secure:       !cfg.Insecure,
+		Region:       cfg.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("storage: create s3 client: %w", err)
+	}
+	partSize := uint64(DefaultS3PartSize)
+	if cfg.PartSize > 0 {
+		partSize = uint64(cfg.PartSize)
+	}
+	return &S3Store{client: client, bucket: cfg.Bucket, partSize: partSize}, nil
+}
+
+// Client returns the underlying client, for operations Store lacks.
+func (s *S3Store) Client() *minio.Client {
+	return s.client
+}
+
+// Put implements Store. Data of unknown size is uploaded in parts of the
+// configured part size.
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, opts PutOptions) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	size := opts.Size
+	if size <= 0 {
+		size = -1
+	}
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType:  opts.ContentType,
+		UserMetadata: opts.Metadata,
+		PartSize:     s.partSize,
+	})
+	if err != nil {
+		return s.error(err, "put", key)
+	}
+	return nil
+}
+
+// Get implements Store.
+func (s *S3Store) Get(ctx context.Context, key string) (*Object, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s.error(err, "get", key)
+	}
+	// GetObject is lazy; Stat sends the request.
+	st, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, s.error(err, "get", key)
+	}
+	info := objectInfo(st)
+	info.Key = key
+	if info.ContentType == "" {
+		info.ContentType = defaultContentType
+	}
+	return &Object{ReadCloser: obj, Info: info}, nil
+}
+
+// Delete implements Store.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return s.error(err, "delete", key)
+	}
+	return nil
+}
+
+// Presign implements Store. ttl must be between 1s and 7 days.
+func (s *S3Store) Presign(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, ttl, nil)
+	if err != nil {
+		return "", s.error(err, "presign", key)
+	}
+	return u.String(), nil
+}
+
+// List implements Store.
+func (s *S3Store) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	// Canceling stops the listing goroutine when fn fails.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return s.error(obj.Err, "list", prefix)
+		}
+		if err := fn(objectInfo(obj)); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+func objectInfo(o minio.ObjectInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:          o.Key,
+		Size:         o.Size,
+		ContentType:  o.ContentType,
+		ETag:         o.ETag,
+		LastModified: o.LastModified,
+		Metadata:     o.UserMetadata,
+	}
+}
+
+// error maps a missing object to code.ErrNotFound.
+func (s *S3Store) error(err error, op, key string) error {
+	if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+		return notFound(err, key)
+	}
+	return failed(err, op, key)
+}
+
+// secretCredentials resolves the keys of an S3Store when requests are
+// signed. The resolver caches them, so they are reported as always expired.
+type secretCredentials struct {
+	accessKey, secretKey string
+	secrets              *config.SecretResolver
+}
+
+func (p *secretCredentials) Retrieve() (credentials.Value, error) {
+	return p.RetrieveWithCredContext(nil)
+}
+
+func (p *secretCredentials) RetrieveWithCredContext(*credentials.CredContext) (credentials.Value, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3CredentialsTimeout)
+	defer cancel()
+	accessKey, err := p.secrets.Resolve(ctx, p.accessKey)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	secretKey, err := p.secrets.Resolve(ctx, p.secretKey)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	return credentials.Value{AccessKeyID: accessKey, SecretAccessKey: secretKey, SignerType: credentials.SignatureV4}, nil
+}
+
+func (p *secretCredentials) IsExpired() bool {
+	return true
+}
@@ -0,0 +1,127 @@
+// Package storage stores blobs (export files, archives, dead-lettered
+// payloads) under slash-separated keys, on local disk (LocalStore) or in
+// an S3-compatible bucket (S3Store). Objects are streamed in and out,
+// never buffered whole.
+//
+//	store, err := storage.New(ctx, cfg.Storage, secrets)
+//
+//	err = store.Put(ctx, "exports/orders.csv", r, storage.PutOptions{ContentType: "text/csv"})
+//
+//	obj, err := store.Get(ctx, "exports/orders.csv")
+//	if err != nil {
+//	    return err // code.ErrNotFound (404) when missing
+//	}
+//	defer obj.Close()
+//
+// Failures are coded errors: code.ErrNotFound for missing objects,
+// code.ErrBadRequest for invalid keys and code.ErrInternalServer
+// otherwise.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+)
+
+// Store stores objects under keys.
+type Store interface {
+	// Put stores the data of r under key, replacing any object. It must not
+	// buffer r entirely in memory.
+	Put(ctx context.Context, key string, r io.Reader, opts PutOptions) error
+	// Get opens the object stored under key. The caller must close it.
+	Get(ctx context.Context, key string) (*Object, error)
+	// Delete removes key; deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// Presign returns a URL downloading key without credentials until ttl
+	// elapses.
+	Presign(ctx context.Context, key string, ttl time.Duration) (string, error)
+	// List calls fn with the objects whose key starts with prefix, in no
+	// particular order, and stops at the first error fn returns. Only Key,
+	// Size and LastModified are set (and ETag by S3Store).
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+}
+
+// PutOptions are the options of Store.Put.
+type PutOptions struct {
+	// ContentType is stored with the object; default
+	// "application/octet-stream".
+	ContentType string
+	// Size is the length of the data when known, which lets S3Store upload
+	// it in a single request; 0 is unknown.
+	Size int64
+	// Metadata is stored with the object. S3 canonicalizes the keys
+	// ("owner" is read back as "Owner").
+	Metadata map[string]string
+}
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key          string            `json:"key"`
+	Size         int64             `json:"size"`
+	ContentType  string            `json:"content_type,omitempty"`
+	ETag         string            `json:"etag,omitempty"`
+	LastModified time.Time         `json:"last_modified"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+// Object is an object opened by Store.Get.
+type Object struct {
+	io.ReadCloser
+	Info ObjectInfo
+}
+
+// defaultContentType is the content type of objects stored without one.
+const defaultContentType = "application/octet-stream"
+
+// New creates the Store selected by cfg.Driver, resolving secret
+// references of cfg with secrets (which may be nil).
+func New(ctx context.Context, cfg config.StorageConfig, secrets *config.SecretResolver) (Store, error) {
+	switch cfg.Driver {
+	case "", "local":
+		if cfg.Local.Dir == "" {
+			return nil, fmt.Errorf("storage: local dir required")
+		}
+		key, err := secrets.Resolve(ctx, cfg.Local.SigningKey)
+		if err != nil {
+			return nil, err
+		}
+		return NewLocalStore(cfg.Local.Dir, LocalStoreOptions{BaseURL: cfg.Local.BaseURL, SigningKey: []byte(key)}), nil
+	case "s3":
+		return NewS3Store(cfg.S3, secrets)
+	default:
+		return nil, fmt.Errorf("storage: unsupported driver: %s", cfg.Driver)
+	}
+}
+
+// checkKey rejects keys that are not relative slash-separated paths, or
+// have a segment starting with "." (reserved by LocalStore), so that keys
+// mean the same on every Store.
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.ContainsAny(key, "\\\x00") {
+		return invalidKey(key)
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || strings.HasPrefix(seg, ".") {
+			return invalidKey(key)
+		}
+	}
+	return nil
+}
+
+func invalidKey(key string) error {
+	return code.NewErrorf(code.ErrBadRequest, "storage: invalid key %q", key)
+}
+
+func notFound(err error, key string) error {
+	return code.WrapErrorf(err, code.ErrNotFound, "storage: object %s not found", key)
+}
+
+func failed(err error, op, key string) error {
+	return code.WrapErrorf(err, code.ErrInternalServer, "storage: %s %s", op, key)
+}
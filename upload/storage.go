@@ -0,0 +1,116 @@
+package upload
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/storage"
+)
+
+// LocalStorage stores files below a directory on local disk. Files are
+// written to a temporary file first and renamed into place, so readers
+// never see partial uploads.
+type LocalStorage struct {
+	dir string
+}
+
+// NewLocalStorage creates a storage rooted at dir.
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{dir: dir}
+}
+
+// Put implements Storage.
+func (s *LocalStorage) Put(_ context.Context, key string, r io.Reader, _ string) error {
+	dst, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// Delete implements Storage.
+func (s *LocalStorage) Delete(_ context.Context, key string) error {
+	dst, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Open opens the file stored under key.
+func (s *LocalStorage) Open(key string) (*os.File, error) {
+	dst, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(dst)
+}
+
+// path maps key to a file below dir, rejecting keys that escape it.
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if !filepath.IsLocal(clean) || strings.HasPrefix(filepath.Base(clean), ".") {
+		return "", errors.Errorf("upload: invalid key %q", key)
+	}
+	return filepath.Join(s.dir, clean), nil
+}
+
+// NewS3Storage creates a Storage writing to an S3-compatible bucket (AWS
+// S3, MinIO, ...) with minio-go, streaming each file in parts, so files
+// are never buffered whole in memory. It is FromStore of a
+// storage.S3Store; secrets may be nil.
+//
+//	store, err := upload.NewS3Storage(cfg.Storage.S3, secrets)
+//	files, err := upload.Parse(c, store, upload.Config{MaxSize: 10 << 20})
+func NewS3Storage(cfg config.S3Config, secrets *config.SecretResolver) (Storage, error) {
+	s3, err := storage.NewS3Store(cfg, secrets)
+	if err != nil {
+		return nil, err
+	}
+	return FromStore(s3), nil
+}
+
+// FromStore adapts a storage.Store, such as storage.S3Store, to Storage:
+//
+//	files, err := upload.Parse(c, upload.FromStore(store), cfg)
+func FromStore(s storage.Store) Storage {
+	return storeAdapter{s}
+}
+
+type storeAdapter struct {
+	store storage.Store
+}
+
+func (a storeAdapter) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	return a.store.Put(ctx, key, r, storage.PutOptions{ContentType: contentType})
+}
+
+func (a storeAdapter) Delete(ctx context.Context, key string) error {
+	return a.store.Delete(ctx, key)
+}
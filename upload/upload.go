@@ -0,0 +1,247 @@
+// Package upload parses multipart file uploads by streaming each file to a
+// Storage, with size limits enforced while streaming, content types sniffed
+// from the data (the file extension is never trusted) and generated storage
+// keys.
+//
+//	store := upload.NewLocalStorage("/var/lib/app/uploads") // or upload.NewS3Storage
+//
+//	func uploadAvatar(c echo.Context) error {
+//	    files, err := upload.Parse(c, store, upload.Config{
+//	        MaxSize:     2 << 20,
+//	        AllowedMIME: []string{"image/png", "image/jpeg"},
+//	        MaxFiles:    1,
+//	    })
+//	    if err != nil {
+//	        return err // 400, 413 or 415 coded errors
+//	    }
+//	    return resp.OneDataResponse(c, files[0])
+//	}
+package upload
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// Defaults applied by Parse.
+const (
+	DefaultMaxSize  = 10 << 20
+	DefaultMaxFiles = 10
+	// maxFieldBytes bounds the text fields read alongside the files.
+	maxFieldBytes = 1 << 20
+	// sniffLen is the number of bytes http.DetectContentType looks at.
+	sniffLen = 512
+)
+
+// Config configures Parse.
+type Config struct {
+	// MaxSize is the maximum size of each file in bytes; default
+	// DefaultMaxSize.
+	MaxSize int64
+	// AllowedMIME lists the accepted sniffed content types, e.g.
+	// "image/png" or "image/*". Empty accepts any type.
+	AllowedMIME []string
+	// MaxFiles is the maximum number of files; default DefaultMaxFiles.
+	MaxFiles int
+	// KeyPrefix is prepended to the generated storage keys, e.g. "avatars/".
+	KeyPrefix string
+}
+
+// File is an uploaded file stored by Parse.
+type File struct {
+	// Field is the form field name.
+	Field string `json:"field"`
+	// Name is the base name of the client's file name, for display only.
+	Name string `json:"name"`
+	// Key is the generated storage key.
+	Key string `json:"key"`
+	// ContentType is sniffed from the file data.
+	ContentType string `json:"content_type"`
+	// Size is the file size in bytes.
+	Size int64 `json:"size"`
+}
+
+// Storage stores uploaded files.
+type Storage interface {
+	// Put stores the data of r under key. It must not buffer r entirely
+	// in memory and must return the error of r when reading fails.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Delete removes key; deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Parse streams the files of the multipart request body to store and
+// returns them in request order. Text fields are made available through
+// c.FormValue. On error, files already stored by this call are deleted.
+//
+// Violations return coded errors: code.ErrPayloadTooLarge (413) for files
+// larger than MaxSize, code.ErrUnsupportedMediaType (415) for types not in
+// AllowedMIME and code.ErrBadRequest (400) for malformed bodies, no files
+// or more than MaxFiles files.
+func Parse(c echo.Context, store Storage, cfg Config) ([]File, error) {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultMaxSize
+	}
+	if cfg.MaxFiles <= 0 {
+		cfg.MaxFiles = DefaultMaxFiles
+	}
+
+	req := c.Request()
+	ctx := req.Context()
+	mr, err := req.MultipartReader()
+	if err != nil {
+		return nil, code.WrapError(err, code.ErrBadRequest, "multipart body expected")
+	}
+
+	var files []File
+	values := make(url.Values)
+	fieldBytes := 0
+	fail := func(err error) ([]File, error) {
+		for _, f := range files {
+			_ = store.Delete(context.WithoutCancel(ctx), f.Key)
+		}
+		return nil, err
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(code.WrapError(err, code.ErrBadRequest, "read multipart body"))
+		}
+
+		if part.FileName() == "" {
+			n, err := readField(part, values, maxFieldBytes-fieldBytes)
+			fieldBytes += n
+			part.Close()
+			if err != nil {
+				return fail(err)
+			}
+			continue
+		}
+
+		if len(files) == cfg.MaxFiles {
+			part.Close()
+			return fail(code.NewErrorf(code.ErrBadRequest, "at most %d files allowed", cfg.MaxFiles))
+		}
+		f, err := storePart(ctx, store, part, cfg)
+		part.Close()
+		if err != nil {
+			return fail(err)
+		}
+		files = append(files, f)
+	}
+
+	if len(files) == 0 {
+		return nil, code.NewError(code.ErrBadRequest, "no file uploaded")
+	}
+	req.Form = values
+	req.PostForm = values
+	return files, nil
+}
+
+// storePart sniffs the content type of part and streams it to store.
+func storePart(ctx context.Context, store Storage, part *multipart.Part, cfg Config) (File, error) {
+	f := File{Field: part.FormName(), Name: filepath.Base(filepath.Clean("/" + part.FileName()))}
+
+	br := bufio.NewReaderSize(part, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		return f, code.WrapError(err, code.ErrBadRequest, "read uploaded file")
+	}
+	f.ContentType, _, _ = mime.ParseMediaType(http.DetectContentType(head))
+	if !allowed(cfg.AllowedMIME, f.ContentType) {
+		return f, code.NewErrorf(code.ErrUnsupportedMediaType, "file %q has unsupported type %s", f.Name, f.ContentType)
+	}
+
+	f.Key = cfg.KeyPrefix + utils.NewULID() + extension(f.ContentType, f.Name)
+	lr := &limitReader{r: br, remaining: cfg.MaxSize}
+	if err := store.Put(ctx, f.Key, lr, f.ContentType); err != nil {
+		_ = store.Delete(context.WithoutCancel(ctx), f.Key)
+		if lr.exceeded {
+			return f, code.NewErrorf(code.ErrPayloadTooLarge, "file %q exceeds %d bytes", f.Name, cfg.MaxSize)
+		}
+		return f, code.WrapError(err, code.ErrInternalServer, "store uploaded file")
+	}
+	f.Size = cfg.MaxSize - lr.remaining
+	return f, nil
+}
+
+// readField adds a text field to values, reading at most limit bytes.
+func readField(part *multipart.Part, values url.Values, limit int) (int, error) {
+	data, err := io.ReadAll(io.LimitReader(part, int64(limit)+1))
+	if err != nil {
+		return len(data), code.WrapError(err, code.ErrBadRequest, "read form field")
+	}
+	if len(data) > limit {
+		return len(data), code.NewErrorf(code.ErrPayloadTooLarge, "form fields exceed %d bytes", maxFieldBytes)
+	}
+	values.Add(part.FormName(), string(data))
+	return len(data), nil
+}
+
+// allowed reports whether contentType matches one of the patterns.
+func allowed(patterns []string, contentType string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	major, _, _ := strings.Cut(contentType, "/")
+	return slices.ContainsFunc(patterns, func(p string) bool {
+		return p == contentType || p == major+"/*"
+	})
+}
+
+// extension returns the extension of the client's file name when it fits
+// contentType, else a registered extension of contentType.
+func extension(contentType, name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if t, _, _ := mime.ParseMediaType(mime.TypeByExtension(ext)); ext != "" && t == contentType {
+		return ext
+	}
+	exts, _ := mime.ExtensionsByType(contentType)
+	if len(exts) == 0 {
+		return ""
+	}
+	slices.Sort(exts)
+	return exts[0]
+}
+
+// limitReader fails once more than remaining bytes are read, so oversized
+// files are rejected while streaming.
+type limitReader struct {
+	r         io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+var errTooLarge = errors.New("upload: file too large")
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.remaining {
+		l.exceeded = true
+		l.remaining = 0
+		return 0, errTooLarge
+	}
+	l.remaining -= int64(n)
+	return n, err
+}
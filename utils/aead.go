@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// AEAD algorithms.
+const (
+	AEADAES256GCM         = "aes-256-gcm"
+	AEADXChaCha20Poly1305 = "xchacha20-poly1305"
+)
+
+// aeadVersion is the ciphertext format version.
+const aeadVersion = "v1"
+
+// Short algorithm identifiers embedded in ciphertexts.
+var aeadAlgIDs = map[string]string{
+	AEADAES256GCM:         "g",
+	AEADXChaCha20Poly1305: "x",
+}
+
+// ErrDecrypt is returned when a ciphertext cannot be authenticated or decoded.
+var ErrDecrypt = errors.New("decrypt failed")
+
+// AEADOptions configures an AEAD.
+type AEADOptions struct {
+	// Algorithm: "aes-256-gcm" (default) or "xchacha20-poly1305".
+	Algorithm string
+	// KeyID identifies the primary key inside ciphertexts; default "k1".
+	KeyID string
+	// Keys are additional (retired) keys by key ID, used for decryption only.
+	Keys map[string][]byte
+}
+
+// AEAD encrypts small values (tokens, secrets) for storage at rest.
+//
+// Ciphertext format:
+//
+//	v1.<alg>.<keyID>.<base64url(nonce || sealed)>
+//
+// The header is authenticated together with the caller's additional data,
+// so swapping key IDs or algorithms is detected. New encryptions always use
+// the primary key; decryption selects the key by its embedded ID, which allows
+// key rotation without re-encrypting existing data.
+type AEAD struct {
+	alg     string
+	primary string
+	ciphers map[string]cipher.AEAD
+}
+
+// NewAEAD creates an AEAD with key as the primary 32-byte key.
+func NewAEAD(key []byte, opts AEADOptions) (*AEAD, error) {
+	alg := opts.Algorithm
+	if alg == "" {
+		alg = AEADAES256GCM
+	}
+	if _, ok := aeadAlgIDs[alg]; !ok {
+		return nil, fmt.Errorf("unsupported aead algorithm: %s", alg)
+	}
+
+	primary := opts.KeyID
+	if primary == "" {
+		primary = "k1"
+	}
+
+	a := &AEAD{
+		alg:     alg,
+		primary: primary,
+		ciphers: make(map[string]cipher.AEAD, len(opts.Keys)+1),
+	}
+
+	keys := make(map[string][]byte, len(opts.Keys)+1)
+	for id, k := range opts.Keys {
+		keys[id] = k
+	}
+	keys[primary] = key
+
+	for id, k := range keys {
+		if id == "" || strings.Contains(id, ".") {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+		c, err := newAEADCipher(alg, k)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		a.ciphers[id] = c
+	}
+
+	return a, nil
+}
+
+func newAEADCipher(alg string, key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+	switch alg {
+	case AEADXChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	default:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	}
+}
+
+// Encrypt encrypts plaintext with the primary key. additionalData is
+// authenticated but not encrypted and must be supplied again to Decrypt.
+func (a *AEAD) Encrypt(plaintext, additionalData []byte) (string, error) {
+	c := a.ciphers[a.primary]
+	header := aeadVersion + "." + aeadAlgIDs[a.alg] + "." + a.primary
+
+	nonce := make([]byte, c.NonceSize(), c.NonceSize()+len(plaintext)+c.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := c.Seal(nonce, nonce, plaintext, aeadAD(header, additionalData))
+	return header + "." + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a ciphertext produced by Encrypt.
+// Returns ErrDecrypt if the ciphertext is malformed or has been tampered with.
+func (a *AEAD) Decrypt(ciphertext string, additionalData []byte) ([]byte, error) {
+	parts := strings.SplitN(ciphertext, ".", 4)
+	if len(parts) != 4 || parts[0] != aeadVersion || parts[1] != aeadAlgIDs[a.alg] {
+		return nil, fmt.Errorf("%w: malformed ciphertext", ErrDecrypt)
+	}
+
+	c, ok := a.ciphers[parts[2]]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key id %q", ErrDecrypt, parts[2])
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil || len(sealed) < c.NonceSize()+c.Overhead() {
+		return nil, fmt.Errorf("%w: malformed payload", ErrDecrypt)
+	}
+
+	header := parts[0] + "." + parts[1] + "." + parts[2]
+	nonce, body := sealed[:c.NonceSize()], sealed[c.NonceSize():]
+	plaintext, err := c.Open(nil, nonce, body, aeadAD(header, additionalData))
+	if err != nil {
+		return nil, fmt.Errorf("%w: authentication failed", ErrDecrypt)
+	}
+	return plaintext, nil
+}
+
+// EncryptString is a convenience wrapper around Encrypt without additional data.
+func (a *AEAD) EncryptString(plaintext string) (string, error) {
+	return a.Encrypt([]byte(plaintext), nil)
+}
+
+// DecryptString is a convenience wrapper around Decrypt without additional data.
+func (a *AEAD) DecryptString(ciphertext string) (string, error) {
+	b, err := a.Decrypt(ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// aeadAD binds the ciphertext header to the caller's additional data.
+func aeadAD(header string, additionalData []byte) []byte {
+	ad := make([]byte, 0, len(header)+1+len(additionalData))
+	ad = append(ad, header...)
+	ad = append(ad, 0)
+	return append(ad, additionalData...)
+}
+
+// LoadKeyFromEnv loads a base64 encoded encryption key from an environment variable.
+// Returns nil if the variable is not set.
+func LoadKeyFromEnv(envKey string) ([]byte, error) {
+	if envKey == "" {
+		envKey = "DATA_KEY_B64"
+	}
+	v := strings.TrimSpace(os.Getenv(envKey))
+	if v == "" {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(v)
+}
+
+// LoadKeyFromFile loads a base64 encoded encryption key from a file
+// (e.g. a mounted Kubernetes secret).
+func LoadKeyFromFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+}
@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Timer is a timer of a TimerClock, like time.Timer.
+type Timer interface {
+	// C returns the channel the time is sent on when the timer fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing and reports whether it stopped
+	// it.
+	Stop() bool
+	// Reset changes the timer to fire after d and reports whether it had
+	// been active.
+	Reset(d time.Duration) bool
+}
+
+// Ticker is a ticker of a TimerClock, like time.Ticker.
+type Ticker interface {
+	// C returns the channel the ticks are sent on.
+	C() <-chan time.Time
+	// Stop turns off the ticker.
+	Stop()
+}
+
+// TimerClock is a Clock that also waits, implemented by RealClock and
+// FakeClock. Components taking a Clock wait with NewTimer, NewTicker and
+// Sleep, which use the clock when it is a TimerClock and real timers
+// otherwise.
+type TimerClock interface {
+	Clock
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// NewTimer implements TimerClock with time.NewTimer.
+func (RealClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+// NewTicker implements TimerClock with time.NewTicker.
+func (RealClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+type realTimer struct{ t *time.Timer }
+
+func (t realTimer) C() <-chan time.Time        { return t.t.C }
+func (t realTimer) Stop() bool                 { return t.t.Stop() }
+func (t realTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
+
+// NewTimer returns a timer of c firing after d: a timer of c when it is
+// a TimerClock, a real timer otherwise, also for a nil c.
+func NewTimer(c Clock, d time.Duration) Timer {
+	if tc, ok := c.(TimerClock); ok {
+		return tc.NewTimer(d)
+	}
+	return RealClock{}.NewTimer(d)
+}
+
+// NewTicker returns a ticker of c ticking every d, like NewTimer.
+func NewTicker(c Clock, d time.Duration) Ticker {
+	if tc, ok := c.(TimerClock); ok {
+		return tc.NewTicker(d)
+	}
+	return RealClock{}.NewTicker(d)
+}
+
+// Sleep waits d on c, like NewTimer, or until ctx is done, and returns
+// ctx.Err() in that case.
+func Sleep(ctx context.Context, c Clock, d time.Duration) error {
+	t := NewTimer(c, d)
+	select {
+	case <-ctx.Done():
+		t.Stop()
+		return ctx.Err()
+	case <-t.C():
+		return nil
+	}
+}
+
+// FakeClock is a TimerClock for tests, whose time only moves with Advance:
+//
+//	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+//	go worker.Run(ctx) // waits with utils.NewTimer(clock, time.Minute)
+//	clock.BlockUntil(1) // the worker is waiting
+//	clock.Advance(time.Minute)
+//
+// Timers and tickers fire in order of their time during Advance; like
+// real ones, their channels hold one value and drop the ticks that a slow
+// reader misses. It is safe for concurrent use.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+	changed chan struct{} // closed when waiters change
+}
+
+type fakeWaiter struct {
+	clock  *FakeClock
+	at     time.Time
+	period time.Duration // tickers only
+	c      chan time.Time
+}
+
+// NewFakeClock creates a FakeClock at now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, changed: make(chan struct{})}
+}
+
+// Now implements Clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the time forward by d, firing the timers and tickers due
+// on the way in order, each at its time.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.waiters, func(i, j int) bool { return c.waiters[i].at.Before(c.waiters[j].at) })
+		if len(c.waiters) == 0 || c.waiters[0].at.After(end) {
+			break
+		}
+		w := c.waiters[0]
+		c.now = w.at
+		select {
+		case w.c <- w.at:
+		default:
+		}
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			c.remove(w)
+		}
+	}
+	c.now = end
+}
+
+// BlockUntil blocks until n timers and tickers wait on the clock, e.g.
+// until the goroutine under test waits before Advance.
+func (c *FakeClock) BlockUntil(n int) {
+	for {
+		c.mu.Lock()
+		waiting, changed := len(c.waiters), c.changed
+		c.mu.Unlock()
+		if waiting >= n {
+			return
+		}
+		<-changed
+	}
+}
+
+// NewTimer implements TimerClock.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{clock: c, c: make(chan time.Time, 1)}
+	c.add(w, d)
+	return (*fakeTimer)(w)
+}
+
+// NewTicker implements TimerClock. It panics when d is not positive, as
+// time.NewTicker does.
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("utils: non-positive interval for FakeClock.NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{clock: c, period: d, c: make(chan time.Time, 1)}
+	c.add(w, d)
+	return (*fakeTicker)(w)
+}
+
+// add schedules w after d; a timer due already fires at once. c.mu must
+// be held.
+func (c *FakeClock) add(w *fakeWaiter, d time.Duration) {
+	w.at = c.now.Add(d)
+	if d <= 0 && w.period == 0 {
+		select {
+		case w.c <- w.at:
+		default:
+		}
+		return
+	}
+	c.waiters = append(c.waiters, w)
+	c.notify()
+}
+
+// remove unschedules w and reports whether it was scheduled; c.mu must be
+// held.
+func (c *FakeClock) remove(w *fakeWaiter) bool {
+	for i, other := range c.waiters {
+		if other == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			c.notify()
+			return true
+		}
+	}
+	return false
+}
+
+// notify wakes BlockUntil; c.mu must be held.
+func (c *FakeClock) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+type fakeTimer fakeWaiter
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.remove((*fakeWaiter)(t))
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	active := c.remove((*fakeWaiter)(t))
+	c.add((*fakeWaiter)(t), d)
+	return active
+}
+
+type fakeTicker fakeWaiter
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Stop() {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove((*fakeWaiter)(t))
+}
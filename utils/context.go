@@ -5,6 +5,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/NSObjects/go-kit/session"
 	"github.com/labstack/echo/v4"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -19,6 +20,30 @@ const (
 	KeyUserID ContextKey = "user_id"
 	// KeyStartTime is the context key for request start time.
 	KeyStartTime ContextKey = "start_time"
+	// KeyClientIP is the context key for the client IP address.
+	KeyClientIP ContextKey = "client_ip"
+	// KeyUserAgent is the context key for the client user agent.
+	KeyUserAgent ContextKey = "user_agent"
+	// KeyRoles is the context key for the authenticated user's roles.
+	KeyRoles ContextKey = "roles"
+	// KeyTenantID is the context key for the tenant ID.
+	KeyTenantID ContextKey = "tenant_id"
+	// KeySession is the context key for the server-side session.
+	KeySession ContextKey = "session"
+	// KeyCacheBypass is the context key of the cache bypass flag.
+	KeyCacheBypass ContextKey = "cache_bypass"
+	// KeyQueryCache is the context key of the query cache hint.
+	KeyQueryCache ContextKey = "query_cache"
+	// KeyLocale is the context key of the locale of the request.
+	KeyLocale ContextKey = "locale"
+	// KeyWriteOverride is the context key of the read-only override flag.
+	KeyWriteOverride ContextKey = "write_override"
+	// KeyClientVersion is the context key of the client app version.
+	KeyClientVersion ContextKey = "client_version"
+	// KeyClientPlatform is the context key of the client platform.
+	KeyClientPlatform ContextKey = "client_platform"
+	// KeyAPIVersion is the context key of the API version of the request.
+	KeyAPIVersion ContextKey = "api_version"
 )
 
 // TraceContext contains trace and request information from a request.
@@ -30,6 +55,10 @@ type TraceContext struct {
 	RequestID string    // RequestID from Echo middleware (middleware.RequestID)
 	UserID    string    // UserID from authenticated user (if available)
 	StartTime time.Time // Request start time
+	ClientIP  string    // ClientIP resolved by echo's IPExtractor (X-Forwarded-For aware)
+	UserAgent string    // UserAgent from the request header
+	Roles     []string  // Roles from authenticated user (if available)
+	TenantID  string    // TenantID from tenant resolution middleware (if available)
 }
 
 // ExtractTraceContext extracts trace and request information from echo.Context.
@@ -37,8 +66,15 @@ type TraceContext struct {
 // This function extracts:
 //   - TraceID and SpanID from OpenTelemetry span context (requires otelecho middleware)
 //   - RequestID from Echo middleware (requires middleware.RequestID)
-//   - UserID from echo.Context if authenticated
+//   - UserID, Roles and TenantID from echo.Context if authenticated
+//   - ClientIP via c.RealIP(), which honors echo's IPExtractor
+//   - UserAgent from the request header
 //   - StartTime as current time
+//
+// ClientIP only trusts X-Forwarded-For / X-Real-IP when the Echo instance is
+// configured with a trusted-proxy aware extractor (middleware.Setup does this), e.g.:
+//
+//	e.IPExtractor = proxies.IPExtractor() // proxies from utils.NewTrustedProxies
 func ExtractTraceContext(c echo.Context) *TraceContext {
 	tc := &TraceContext{
 		StartTime: time.Now(),
@@ -51,12 +87,8 @@ func ExtractTraceContext(c echo.Context) *TraceContext {
 	// Extract TraceID and SpanID from OpenTelemetry context
 	// Requires: e.Use(otelecho.Middleware("service-name"))
 	ctx := c.Request().Context()
-	if span := trace.SpanFromContext(ctx); span.IsRecording() {
-		if spanContext := span.SpanContext(); spanContext.IsValid() {
-			tc.TraceID = spanContext.TraceID().String()
-			tc.SpanID = spanContext.SpanID().String()
-		}
-	}
+	tc.TraceID = GetTraceID(ctx)
+	tc.SpanID = GetSpanID(ctx)
 
 	// Extract UserID from echo.Context (set by authentication middleware)
 	if userID := c.Get("user_id"); userID != nil {
@@ -65,6 +97,17 @@ func ExtractTraceContext(c echo.Context) *TraceContext {
 		}
 	}
 
+	// Extract Roles and TenantID from echo.Context (set by authentication middleware)
+	if roles, ok := c.Get(string(KeyRoles)).([]string); ok {
+		tc.Roles = roles
+	}
+	if tenantID, ok := c.Get(string(KeyTenantID)).(string); ok {
+		tc.TenantID = tenantID
+	}
+
+	tc.ClientIP = c.RealIP()
+	tc.UserAgent = c.Request().UserAgent()
+
 	return tc
 }
 
@@ -72,7 +115,10 @@ func ExtractTraceContext(c echo.Context) *TraceContext {
 //
 // This function:
 //   - Preserves the original context (including OpenTelemetry span context)
-//   - Adds business-related values (RequestID, UserID, StartTime) to context
+//   - Adds business-related values (RequestID, UserID, StartTime, ClientIP,
+//     UserAgent, Roles, TenantID) to context
+//   - Keeps the request's deadline and cancellation; use DetachContext for
+//     work that must outlive the request
 //   - Does NOT inject TraceID/SpanID as they are already available via OpenTelemetry
 //
 // Usage:
@@ -90,21 +136,43 @@ func BuildContext(c echo.Context) context.Context {
 	ctx = context.WithValue(ctx, KeyRequestID, tc.RequestID)
 	ctx = context.WithValue(ctx, KeyUserID, tc.UserID)
 	ctx = context.WithValue(ctx, KeyStartTime, tc.StartTime)
+	ctx = context.WithValue(ctx, KeyClientIP, tc.ClientIP)
+	ctx = context.WithValue(ctx, KeyUserAgent, tc.UserAgent)
+	ctx = context.WithValue(ctx, KeyRoles, tc.Roles)
+	ctx = context.WithValue(ctx, KeyTenantID, tc.TenantID)
 
 	return ctx
 }
 
+// ContextWithTimeoutFromRequest builds a request context (see BuildContext)
+// bounded by max. If the request already carries an earlier deadline, that
+// deadline wins. A non-positive max only applies the request's own deadline.
+func ContextWithTimeoutFromRequest(c echo.Context, max time.Duration) (context.Context, context.CancelFunc) {
+	ctx := BuildContext(c)
+	if max <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, max)
+}
+
+// DetachContext returns a context that carries all values of ctx (request ID,
+// user, trace span, ...) but is never canceled and has no deadline.
+//
+// Use it for fire-and-forget goroutines that must outlive the request:
+//
+//	go audit.Record(utils.DetachContext(ctx), event)
+func DetachContext(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
+
 // GetTraceID returns TraceID from context.
-// It extracts from OpenTelemetry span context if available.
+// It extracts from OpenTelemetry span context if available, including
+// non-recording (sampled-out) spans, so logs still correlate.
 //
-// Returns empty string if no valid span context is found.
+// Returns empty string if no trace ID is present.
 func GetTraceID(ctx context.Context) string {
-	span := trace.SpanFromContext(ctx)
-	if !span.IsRecording() {
-		return ""
-	}
-	spanContext := span.SpanContext()
-	if !spanContext.IsValid() {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
 		return ""
 	}
 	return spanContext.TraceID().String()
@@ -113,12 +181,8 @@ func GetTraceID(ctx context.Context) string {
 // GetSpanID returns SpanID from context.
 // It extracts from OpenTelemetry span context if available.
 func GetSpanID(ctx context.Context) string {
-	span := trace.SpanFromContext(ctx)
-	if !span.IsRecording() {
-		return ""
-	}
-	spanContext := span.SpanContext()
-	if !spanContext.IsValid() {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasSpanID() {
 		return ""
 	}
 	return spanContext.SpanID().String()
@@ -162,6 +226,115 @@ func GetStartTime(ctx context.Context) time.Time {
 	return time.Now()
 }
 
+// GetClientIP returns ClientIP from context.
+// Returns empty string if not found.
+func GetClientIP(ctx context.Context) string {
+	if v, ok := ctx.Value(KeyClientIP).(string); ok {
+		return v
+	}
+	return ""
+}
+
+// GetUserAgent returns UserAgent from context.
+// Returns empty string if not found.
+func GetUserAgent(ctx context.Context) string {
+	if v, ok := ctx.Value(KeyUserAgent).(string); ok {
+		return v
+	}
+	return ""
+}
+
+// GetRoles returns Roles from context.
+// Returns nil if not found.
+func GetRoles(ctx context.Context) []string {
+	if v, ok := ctx.Value(KeyRoles).([]string); ok {
+		return v
+	}
+	return nil
+}
+
+// GetTenantID returns TenantID from context.
+// Returns empty string if not found.
+func GetTenantID(ctx context.Context) string {
+	if v, ok := ctx.Value(KeyTenantID).(string); ok {
+		return v
+	}
+	return ""
+}
+
+// GetSession returns the session loaded by middleware.Session, or nil.
+func GetSession(ctx context.Context) *session.Session {
+	if v, ok := ctx.Value(KeySession).(*session.Session); ok {
+		return v
+	}
+	return nil
+}
+
+// WithSession returns a context carrying s.
+func WithSession(ctx context.Context, s *session.Session) context.Context {
+	return context.WithValue(ctx, KeySession, s)
+}
+
+// WithCacheBypass returns a context whose reads skip caches that honor
+// the flag (such as db.CachedRepository), for consistency-critical paths.
+func WithCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, KeyCacheBypass, true)
+}
+
+// CacheBypass reports whether ctx carries the WithCacheBypass flag.
+func CacheBypass(ctx context.Context) bool {
+	v, _ := ctx.Value(KeyCacheBypass).(bool)
+	return v
+}
+
+// WithQueryCache returns a context whose gorm queries may be answered
+// from a cache up to ttl old, when the db.QueryCache plugin is registered.
+// WithCacheBypass wins over it.
+//
+//	var users []User
+//	err := gdb.WithContext(utils.WithQueryCache(ctx, 5*time.Second)).Where("active = ?", true).Find(&users).Error
+func WithQueryCache(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, KeyQueryCache, ttl)
+}
+
+// QueryCacheTTL returns the ttl of the WithQueryCache hint of ctx, or 0
+// when there is none or caches are bypassed.
+func QueryCacheTTL(ctx context.Context) time.Duration {
+	if CacheBypass(ctx) {
+		return 0
+	}
+	ttl, _ := ctx.Value(KeyQueryCache).(time.Duration)
+	return ttl
+}
+
+// WithWriteOverride returns a context whose database writes pass the
+// read-only guard (see db.EnableReadOnlyGuard), for the migration job run
+// during maintenance.
+func WithWriteOverride(ctx context.Context) context.Context {
+	return context.WithValue(ctx, KeyWriteOverride, true)
+}
+
+// WriteOverride reports whether ctx carries the WithWriteOverride flag.
+func WriteOverride(ctx context.Context) bool {
+	v, _ := ctx.Value(KeyWriteOverride).(bool)
+	return v
+}
+
+// WithLocale returns a context carrying locale, a BCP 47 tag such as
+// "en" or "pt-BR", for the translations of i18n.T and error messages.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, KeyLocale, locale)
+}
+
+// GetLocale returns the locale set by WithLocale (see middleware.Locale).
+// Returns empty string if not found.
+func GetLocale(ctx context.Context) string {
+	if v, ok := ctx.Value(KeyLocale).(string); ok {
+		return v
+	}
+	return ""
+}
+
 // WithRequestInfo adds request information to context.
 // This is useful for testing or when you need to manually set request context.
 //
@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// KeyDBStats is the context key of the database statistics of a request.
+const KeyDBStats ContextKey = "db_stats"
+
+// maxDBStatements bounds the distinct statements a DBStats counts; the
+// totals still count the others.
+const maxDBStatements = 256
+
+// DBStats accumulates the database activity of a request: the number of
+// statements, their total duration and rows, and how often each statement
+// fingerprint ran. middleware.DBStats puts one in the request context and
+// the db.RequestStatsPlugin gorm plugin records into it. It is safe for
+// concurrent use; a nil DBStats records nothing.
+type DBStats struct {
+	mu         sync.Mutex
+	queries    int
+	duration   time.Duration
+	rows       int64
+	statements map[string]int
+}
+
+// DBStatsSnapshot is the state of a DBStats.
+type DBStatsSnapshot struct {
+	Queries  int
+	Duration time.Duration
+	Rows     int64
+}
+
+// StatementCount is the number of runs of a statement fingerprint.
+type StatementCount struct {
+	Fingerprint string `json:"fingerprint"`
+	Count       int    `json:"count"`
+}
+
+// NewDBStats creates empty database statistics.
+func NewDBStats() *DBStats {
+	return &DBStats{statements: make(map[string]int)}
+}
+
+// WithDBStats returns a context whose gorm statements are recorded into s.
+func WithDBStats(ctx context.Context, s *DBStats) context.Context {
+	return context.WithValue(ctx, KeyDBStats, s)
+}
+
+// DBStatsFromContext returns the statistics set by WithDBStats, or nil.
+func DBStatsFromContext(ctx context.Context) *DBStats {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(KeyDBStats).(*DBStats)
+	return s
+}
+
+// Record counts a statement with the given fingerprint that took d and
+// returned or affected rows.
+func (s *DBStats) Record(fingerprint string, d time.Duration, rows int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries++
+	s.duration += d
+	if rows > 0 {
+		s.rows += rows
+	}
+	if _, ok := s.statements[fingerprint]; ok || len(s.statements) < maxDBStatements {
+		s.statements[fingerprint]++
+	}
+}
+
+// Snapshot returns the totals recorded so far.
+func (s *DBStats) Snapshot() DBStatsSnapshot {
+	if s == nil {
+		return DBStatsSnapshot{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return DBStatsSnapshot{Queries: s.queries, Duration: s.duration, Rows: s.rows}
+}
+
+// TopStatements returns the n most run statements, most run first and by
+// fingerprint among equals.
+func (s *DBStats) TopStatements(n int) []StatementCount {
+	if s == nil || n <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	top := make([]StatementCount, 0, len(s.statements))
+	for fp, count := range s.statements {
+		top = append(top, StatementCount{Fingerprint: fp, Count: count})
+	}
+	s.mu.Unlock()
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Fingerprint < top[j].Fingerprint
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
@@ -1,13 +1,17 @@
 package utils
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"os"
 	"strings"
 	"time"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -21,11 +25,50 @@ type RealClock struct{}
 
 func (RealClock) Now() time.Time { return time.Now() }
 
+// Password hashing algorithms.
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+// Argon2Params holds Argon2id parameters.
+type Argon2Params struct {
+	// Memory in KiB; default 64 MiB.
+	Memory uint32
+	// Iterations (time cost); default 3.
+	Iterations uint32
+	// Parallelism (threads); default 2.
+	Parallelism uint8
+	// SaltLength in bytes; default 16.
+	SaltLength uint32
+	// KeyLength in bytes; default 32.
+	KeyLength uint32
+}
+
+// DefaultArgon2Params returns the default Argon2id parameters.
+func DefaultArgon2Params() Argon2Params {
+	return Argon2Params{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
 // EncryptConfig holds password hashing configuration.
 type EncryptConfig struct {
+	// Algorithm used for new hashes: "bcrypt" (default) or "argon2id";
+	// Hash fails for any other. Verify auto-detects the algorithm of
+	// stored hashes.
+	Algorithm string
+
 	// BcryptCost: recommended 10-14; default 12.
 	BcryptCost int
 
+	// Argon2: parameters for argon2id hashes.
+	Argon2 Argon2Params
+
 	// EnablePrehash: SHA-256 the password before bcrypt to avoid 72-byte truncation.
 	EnablePrehash bool
 
@@ -36,7 +79,9 @@ type EncryptConfig struct {
 // DefaultEncryptConfig returns the default encryption configuration.
 func DefaultEncryptConfig() EncryptConfig {
 	return EncryptConfig{
+		Algorithm:     AlgorithmBcrypt,
 		BcryptCost:    12,
+		Argon2:        DefaultArgon2Params(),
 		EnablePrehash: true,
 		Pepper:        nil,
 	}
@@ -76,15 +121,28 @@ func NewPasswordHasherWithClock(config EncryptConfig, clock Clock) *PasswordHash
 	}
 }
 
-// Hash generates a bcrypt password hash.
+// Hash generates a password hash using the configured algorithm.
+// Argon2id hashes are encoded in the PHC string format:
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+//
+// It fails for an unknown algorithm and for argon2id parameters out of
+// the bounds Verify accepts, rather than store hashes no password matches.
 func (h *PasswordHasher) Hash(plaintext string) (string, error) {
-	var material []byte
-	if h.config.EnablePrehash {
-		material = prehash(plaintext, h.config.Pepper)
-	} else {
-		material = []byte(plaintext)
+	switch h.algorithm() {
+	case AlgorithmBcrypt:
+	case AlgorithmArgon2id:
+		p := h.argon2Params()
+		if err := checkArgon2Params(p); err != nil {
+			return "", err
+		}
+		return hashArgon2id(h.material(plaintext), p)
+	default:
+		return "", fmt.Errorf("unsupported password hash algorithm: %q", h.config.Algorithm)
 	}
 
+	material := h.material(plaintext)
+
 	hash, err := bcrypt.GenerateFromPassword(material, h.config.BcryptCost)
 	if err != nil {
 		return "", err
@@ -92,6 +150,42 @@ func (h *PasswordHasher) Hash(plaintext string) (string, error) {
 	return string(hash), nil
 }
 
+func (h *PasswordHasher) material(plaintext string) []byte {
+	if h.config.EnablePrehash {
+		return prehash(plaintext, h.config.Pepper)
+	}
+	return []byte(plaintext)
+}
+
+func (h *PasswordHasher) algorithm() string {
+	if h.config.Algorithm == "" {
+		return AlgorithmBcrypt
+	}
+	return h.config.Algorithm
+}
+
+// argon2Params returns the configured parameters with defaults filled in.
+func (h *PasswordHasher) argon2Params() Argon2Params {
+	p := h.config.Argon2
+	def := DefaultArgon2Params()
+	if p.Memory == 0 {
+		p.Memory = def.Memory
+	}
+	if p.Iterations == 0 {
+		p.Iterations = def.Iterations
+	}
+	if p.Parallelism == 0 {
+		p.Parallelism = def.Parallelism
+	}
+	if p.SaltLength == 0 {
+		p.SaltLength = def.SaltLength
+	}
+	if p.KeyLength == 0 {
+		p.KeyLength = def.KeyLength
+	}
+	return p
+}
+
 // Verify checks if plaintext matches stored hash.
 // Returns: ok (match), needRehash (should upgrade cost), err.
 func (h *PasswordHasher) Verify(storedHash, plaintext string) (ok bool, needRehash bool, err error) {
@@ -99,9 +193,15 @@ func (h *PasswordHasher) Verify(storedHash, plaintext string) (ok bool, needReha
 		return false, false, errors.New("empty stored hash")
 	}
 
-	material := []byte(plaintext)
-	if h.config.EnablePrehash {
-		material = prehash(plaintext, h.config.Pepper)
+	material := h.material(plaintext)
+
+	if isArgon2idHash(storedHash) {
+		ok, err := verifyArgon2id(storedHash, material)
+		if err != nil || !ok {
+			return false, false, err
+		}
+		need, _ := h.NeedsRehash(storedHash)
+		return true, need, nil
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(storedHash), material); err != nil {
@@ -116,11 +216,29 @@ func (h *PasswordHasher) Verify(storedHash, plaintext string) (ok bool, needReha
 }
 
 // NeedsRehash checks if a stored hash needs to be re-hashed with current config.
+// It returns true when the stored algorithm differs from the configured one,
+// when the bcrypt cost is lower than configured, or when argon2id parameters
+// differ from the configured ones.
 func (h *PasswordHasher) NeedsRehash(storedHash string) (bool, error) {
+	if isArgon2idHash(storedHash) {
+		if h.algorithm() != AlgorithmArgon2id {
+			return true, nil
+		}
+		p, _, _, err := decodeArgon2id(storedHash)
+		if err != nil {
+			return false, err
+		}
+		want := h.argon2Params()
+		return p != want, nil
+	}
+
 	cost, err := bcrypt.Cost([]byte(storedHash))
 	if err != nil {
 		return false, err
 	}
+	if h.algorithm() != AlgorithmBcrypt {
+		return true, nil
+	}
 	return cost < h.config.BcryptCost, nil
 }
 
@@ -158,3 +276,109 @@ func prehash(password string, pepper []byte) []byte {
 	}
 	return h.Sum(nil)
 }
+
+// ========== Argon2id ==========
+
+const argon2idPrefix = "$argon2id$"
+
+// Bounds of the parameters of argon2id hashes, so that a corrupt or
+// crafted hash cannot crash or exhaust Verify and Hash never writes one
+// Verify rejects: the minimums are those of the Argon2 spec, the maximums
+// far above any sane configuration.
+const (
+	maxArgon2Memory     = 4 * 1024 * 1024 // KiB, 4 GiB
+	maxArgon2Iterations = 100
+	minArgon2SaltLength = 8
+	minArgon2KeyLength  = 4
+	maxArgon2KeyLength  = 1024
+)
+
+func isArgon2idHash(hash string) bool {
+	return strings.HasPrefix(hash, argon2idPrefix)
+}
+
+// hashArgon2id hashes material with a random salt and encodes it as a PHC string.
+func hashArgon2id(material []byte, p Argon2Params) (string, error) {
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey(material, salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return encodeArgon2id(p, salt, key), nil
+}
+
+// encodeArgon2id encodes an argon2id hash in the PHC string format.
+// Salt and key use unpadded standard base64 as required by the PHC spec.
+func encodeArgon2id(p Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))
+}
+
+// decodeArgon2id parses a PHC encoded argon2id hash.
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	var p Argon2Params
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return p, nil, nil, errors.New("invalid argon2id hash format")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, nil, nil, fmt.Errorf("invalid argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("unsupported argon2id version: %d", version)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("invalid argon2id key: %w", err)
+	}
+
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	if err := checkArgon2Params(p); err != nil {
+		return p, nil, nil, err
+	}
+	return p, salt, key, nil
+}
+
+// checkArgon2Params rejects the parameters, configured or decoded from a
+// hash, that argon2.IDKey cannot, or should not, compute.
+func checkArgon2Params(p Argon2Params) error {
+	switch {
+	case p.Iterations == 0 || p.Iterations > maxArgon2Iterations:
+		return fmt.Errorf("invalid argon2id iterations: %d", p.Iterations)
+	case p.Parallelism == 0:
+		return errors.New("invalid argon2id parallelism: 0")
+	case p.Memory < 8*uint32(p.Parallelism) || p.Memory > maxArgon2Memory:
+		return fmt.Errorf("invalid argon2id memory: %d KiB", p.Memory)
+	case p.SaltLength < minArgon2SaltLength:
+		return fmt.Errorf("invalid argon2id salt length: %d", p.SaltLength)
+	case p.KeyLength < minArgon2KeyLength || p.KeyLength > maxArgon2KeyLength:
+		return fmt.Errorf("invalid argon2id key length: %d", p.KeyLength)
+	}
+	return nil
+}
+
+// verifyArgon2id recomputes the key with the stored parameters and compares it.
+// The comparison is constant-time so the match position does not leak timing.
+func verifyArgon2id(hash string, material []byte) (bool, error) {
+	p, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+	other := argon2.IDKey(material, salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+// testArgon2Params are cheap parameters within bounds.
+func testArgon2Params() Argon2Params {
+	return Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+}
+
+func TestHashArgon2idRoundTrip(t *testing.T) {
+	h := NewPasswordHasher(EncryptConfig{Algorithm: AlgorithmArgon2id, Argon2: testArgon2Params()})
+	hash, err := h.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, need, err := h.Verify(hash, "secret"); !ok || need || err != nil {
+		t.Fatalf("Verify(right) = %v, %v, %v; want true, false, nil", ok, need, err)
+	}
+	if ok, _, err := h.Verify(hash, "wrong"); ok || err != nil {
+		t.Fatalf("Verify(wrong) = %v, %v; want false, nil", ok, err)
+	}
+}
+
+func TestHashRejectsArgon2ParamsVerifyRejects(t *testing.T) {
+	tests := map[string]func(*Argon2Params){
+		"short salt":      func(p *Argon2Params) { p.SaltLength = 4 },
+		"short key":       func(p *Argon2Params) { p.KeyLength = 2 },
+		"long key":        func(p *Argon2Params) { p.KeyLength = 2048 },
+		"many iterations": func(p *Argon2Params) { p.Iterations = 101 },
+		"little memory":   func(p *Argon2Params) { p.Memory, p.Parallelism = 8, 4 },
+		"much memory":     func(p *Argon2Params) { p.Memory = 8 * 1024 * 1024 },
+	}
+	for name, edit := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := testArgon2Params()
+			edit(&p)
+			h := NewPasswordHasher(EncryptConfig{Algorithm: AlgorithmArgon2id, Argon2: p})
+			if hash, err := h.Hash("secret"); err == nil {
+				t.Fatalf("Hash = %q, want an error", hash)
+			}
+		})
+	}
+}
+
+func TestHashRejectsUnknownAlgorithm(t *testing.T) {
+	h := NewPasswordHasher(EncryptConfig{Algorithm: "scrypt"})
+	if hash, err := h.Hash("secret"); err == nil {
+		t.Fatalf("Hash = %q, want an error", hash)
+	}
+}
+
+func TestVerifyRejectsMalformedArgon2Hash(t *testing.T) {
+	h := NewPasswordHasher(EncryptConfig{Algorithm: AlgorithmArgon2id, Argon2: testArgon2Params()})
+	hash, err := h.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, params := range []string{"m=64,t=1,p=0", "m=64,t=0,p=1", "m=4,t=1,p=1", "m=64,t=1000,p=1"} {
+		bad := strings.Replace(hash, "m=64,t=1,p=1", params, 1)
+		if ok, _, err := h.Verify(bad, "secret"); ok || err == nil {
+			t.Errorf("Verify(%s) = %v, %v; want false and an error", params, ok, err)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// crockford is the Crockford base32 alphabet used by ULID.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDLength is the length of an encoded ULID.
+const ULIDLength = 26
+
+// IDGenerator generates time-ordered IDs (ULID, UUIDv7).
+// It is safe for concurrent use. IDs generated within the same millisecond
+// are monotonically increasing.
+type IDGenerator struct {
+	mu      sync.Mutex
+	clock   Clock
+	entropy io.Reader
+	lastMs  uint64
+	last    [10]byte
+}
+
+// NewIDGenerator creates an ID generator using the given clock.
+// A nil clock falls back to RealClock.
+func NewIDGenerator(clock Clock) *IDGenerator {
+	return NewIDGeneratorWithEntropy(clock, nil)
+}
+
+// NewIDGeneratorWithEntropy creates an ID generator drawing the random
+// part of the IDs from entropy, e.g. NewSeededRand for reproducible IDs
+// in tests. A nil entropy falls back to crypto/rand.
+func NewIDGeneratorWithEntropy(clock Clock, entropy io.Reader) *IDGenerator {
+	if clock == nil {
+		clock = RealClock{}
+	}
+	if entropy == nil {
+		entropy = rand.Reader
+	}
+	return &IDGenerator{
+		clock:   clock,
+		entropy: entropy,
+	}
+}
+
+var defaultIDGenerator = NewIDGenerator(RealClock{})
+
+// NewULID returns a new ULID string using the default generator.
+func NewULID() string {
+	return defaultIDGenerator.ULID()
+}
+
+// NewUUIDv7 returns a new UUIDv7 string using the default generator.
+func NewUUIDv7() string {
+	return defaultIDGenerator.UUIDv7()
+}
+
+// next returns the timestamp and 80 bits of entropy for the next ID.
+// Within the same millisecond the entropy is incremented to keep ordering.
+func (g *IDGenerator) next() (uint64, [10]byte) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(g.clock.Now().UnixMilli())
+	if ms <= g.lastMs {
+		// Same millisecond or clock moved backwards: stay monotonic.
+		ms = g.lastMs
+		if !increment(g.last[:]) {
+			ms++
+			g.fillEntropy()
+		}
+	} else {
+		g.fillEntropy()
+	}
+	g.lastMs = ms
+	return ms, g.last
+}
+
+func (g *IDGenerator) fillEntropy() {
+	if _, err := io.ReadFull(g.entropy, g.last[:]); err != nil {
+		panic(fmt.Sprintf("utils: read entropy: %v", err))
+	}
+}
+
+// increment adds one to a big-endian byte slice.
+// Returns false on overflow.
+func increment(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ULID returns a new ULID string (26 chars, Crockford base32).
+func (g *IDGenerator) ULID() string {
+	ms, entropy := g.next()
+
+	var raw [16]byte
+	raw[0] = byte(ms >> 40)
+	raw[1] = byte(ms >> 32)
+	raw[2] = byte(ms >> 24)
+	raw[3] = byte(ms >> 16)
+	raw[4] = byte(ms >> 8)
+	raw[5] = byte(ms)
+	copy(raw[6:], entropy[:])
+
+	return encodeULID(raw)
+}
+
+// UUIDv7 returns a new RFC 9562 version 7 UUID string.
+func (g *IDGenerator) UUIDv7() string {
+	ms, entropy := g.next()
+
+	var u [16]byte
+	u[0] = byte(ms >> 40)
+	u[1] = byte(ms >> 32)
+	u[2] = byte(ms >> 24)
+	u[3] = byte(ms >> 16)
+	u[4] = byte(ms >> 8)
+	u[5] = byte(ms)
+	copy(u[6:], entropy[:])
+	u[6] = (u[6] & 0x0f) | 0x70 // version 7
+	u[8] = (u[8] & 0x3f) | 0x80 // variant 10
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// encodeULID encodes 128 bits into 26 Crockford base32 characters.
+func encodeULID(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[0:8])
+	lo := binary.BigEndian.Uint64(id[8:16])
+
+	var out [ULIDLength]byte
+	for i := ULIDLength - 1; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = (lo >> 5) | (hi << 59)
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// IsULID reports whether s is a well-formed ULID.
+func IsULID(s string) bool {
+	if len(s) != ULIDLength {
+		return false
+	}
+	// The first character can only encode 3 bits (128 = 26*5 - 2).
+	if s[0] > '7' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(crockford, upper(s[i])) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func upper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+// ========== Prefixed IDs ==========
+
+// prefixSeparator separates the prefix from the ULID in prefixed IDs.
+const prefixSeparator = "_"
+
+// ErrInvalidPrefixedID is returned when a prefixed ID cannot be parsed.
+var ErrInvalidPrefixedID = errors.New("invalid prefixed id")
+
+// PrefixedID returns an ID like "ord_01HX..." using the default generator.
+func PrefixedID(prefix string) string {
+	return defaultIDGenerator.PrefixedID(prefix)
+}
+
+// PrefixedID returns an ID composed of prefix, "_" and a ULID.
+func (g *IDGenerator) PrefixedID(prefix string) string {
+	return prefix + prefixSeparator + g.ULID()
+}
+
+// ParsePrefixedID validates that id has the expected prefix and a well-formed
+// ULID body, and returns the ULID part.
+func ParsePrefixedID(id, prefix string) (string, error) {
+	body, ok := strings.CutPrefix(id, prefix+prefixSeparator)
+	if !ok {
+		return "", fmt.Errorf("%w: expected prefix %q", ErrInvalidPrefixedID, prefix)
+	}
+	if !IsULID(body) {
+		return "", fmt.Errorf("%w: malformed body %q", ErrInvalidPrefixedID, body)
+	}
+	return body, nil
+}
+
+// ========== Snowflake ==========
+
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+
+	// MaxSnowflakeNodeID is the largest node ID a Snowflake accepts.
+	MaxSnowflakeNodeID = 1<<snowflakeNodeBits - 1
+
+	snowflakeMaxSequence = 1<<snowflakeSequenceBits - 1
+)
+
+// SnowflakeEpoch is the custom epoch (2024-01-01 UTC) for Snowflake IDs.
+var SnowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Snowflake generates 63-bit, time-ordered integer IDs.
+// Layout: 41 bits milliseconds since SnowflakeEpoch | 10 bits node | 12 bits sequence.
+//
+// If the clock moves backwards, the generator keeps issuing IDs from the last
+// observed timestamp instead of producing duplicates.
+type Snowflake struct {
+	mu       sync.Mutex
+	clock    Clock
+	nodeID   int64
+	lastMs   int64
+	sequence int64
+}
+
+// NewSnowflake creates a Snowflake generator for the given node ID.
+func NewSnowflake(nodeID int64) (*Snowflake, error) {
+	return NewSnowflakeWithClock(nodeID, RealClock{})
+}
+
+// NewSnowflakeWithClock creates a Snowflake generator with a custom clock,
+// e.g. a FakeClock for reproducible IDs in tests.
+func NewSnowflakeWithClock(nodeID int64, clock Clock) (*Snowflake, error) {
+	if nodeID < 0 || nodeID > MaxSnowflakeNodeID {
+		return nil, fmt.Errorf("snowflake node id %d out of range [0, %d]", nodeID, MaxSnowflakeNodeID)
+	}
+	if clock == nil {
+		clock = RealClock{}
+	}
+	return &Snowflake{clock: clock, nodeID: nodeID}, nil
+}
+
+// Next returns the next ID.
+func (s *Snowflake) Next() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now().Sub(SnowflakeEpoch).Milliseconds()
+	if now <= s.lastMs {
+		// Same millisecond or clock drift: borrow from the last timestamp.
+		now = s.lastMs
+		s.sequence = (s.sequence + 1) & snowflakeMaxSequence
+		if s.sequence == 0 {
+			now++
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.lastMs = now
+
+	return now<<(snowflakeNodeBits+snowflakeSequenceBits) |
+		s.nodeID<<snowflakeSequenceBits |
+		s.sequence
+}
+
+// SnowflakeTime returns the generation time encoded in a Snowflake ID.
+func SnowflakeTime(id int64) time.Time {
+	ms := id >> (snowflakeNodeBits + snowflakeSequenceBits)
+	return SnowflakeEpoch.Add(time.Duration(ms) * time.Millisecond)
+}
+
+// NodeIDFromIP derives a Snowflake node ID from the low bits of an IP
+// address. It fails for nil and malformed addresses, which are neither 4
+// nor 16 bytes long.
+func NodeIDFromIP(ip net.IP) (int64, error) {
+	if ip == nil {
+		return 0, errors.New("nil ip")
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	} else if ip = ip.To16(); ip == nil {
+		return 0, errors.New("invalid ip")
+	}
+	n := len(ip)
+	return (int64(ip[n-2])<<8 | int64(ip[n-1])) & MaxSnowflakeNodeID, nil
+}
+
+// NodeIDFromPodIP derives a Snowflake node ID from the POD_IP environment
+// variable (Kubernetes downward API), falling back to the first non-loopback
+// interface address.
+func NodeIDFromPodIP() (int64, error) {
+	if v := strings.TrimSpace(os.Getenv("POD_IP")); v != "" {
+		ip := net.ParseIP(v)
+		if ip == nil {
+			return 0, fmt.Errorf("invalid POD_IP %q", v)
+		}
+		return NodeIDFromIP(ip)
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return 0, err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			return NodeIDFromIP(ipNet.IP)
+		}
+	}
+	return 0, errors.New("no usable ip address found")
+}
@@ -0,0 +1,238 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// Mask kinds supported by the `mask` struct tag.
+const (
+	MaskKindPhone    = "phone"
+	MaskKindEmail    = "email"
+	MaskKindIDCard   = "idcard"
+	MaskKindBankCard = "bankcard"
+	MaskKindSecret   = "secret"
+	MaskKindMiddle   = "middle"
+)
+
+// maskRune is the replacement character for masked runes.
+const maskRune = '*'
+
+// redacted is the replacement for fully redacted values.
+const redacted = "******"
+
+// MaskMiddle keeps keepPrefix leading and keepSuffix trailing runes and masks
+// everything in between. If the string is too short to keep anything hidden,
+// it is fully masked.
+func MaskMiddle(s string, keepPrefix, keepSuffix int) string {
+	runes := []rune(s)
+	n := len(runes)
+	if n == 0 {
+		return s
+	}
+	if keepPrefix < 0 {
+		keepPrefix = 0
+	}
+	if keepSuffix < 0 {
+		keepSuffix = 0
+	}
+	if keepPrefix+keepSuffix >= n {
+		return strings.Repeat(string(maskRune), n)
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	b.WriteString(string(runes[:keepPrefix]))
+	b.WriteString(strings.Repeat(string(maskRune), n-keepPrefix-keepSuffix))
+	b.WriteString(string(runes[n-keepSuffix:]))
+	return b.String()
+}
+
+// MaskPhone masks a phone number, e.g. 13812341234 -> 138****1234.
+func MaskPhone(phone string) string {
+	if utf8.RuneCountInString(phone) < 7 {
+		return MaskMiddle(phone, 0, 0)
+	}
+	return MaskMiddle(phone, 3, 4)
+}
+
+// MaskEmail masks the local part of an email, e.g. alice.b@example.com -> a***b@example.com.
+func MaskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return MaskMiddle(email, 0, 0)
+	}
+
+	local, domain := email[:at], email[at:]
+	runes := []rune(local)
+	if len(runes) <= 2 {
+		return string(runes[0]) + strings.Repeat(string(maskRune), 3) + domain
+	}
+	return string(runes[0]) + strings.Repeat(string(maskRune), 3) + string(runes[len(runes)-1]) + domain
+}
+
+// MaskIDCard masks an identity card number keeping the first and last 2 runes.
+func MaskIDCard(id string) string {
+	return MaskMiddle(id, 2, 2)
+}
+
+// MaskBankCard masks a bank card number keeping the last 4 runes.
+func MaskBankCard(card string) string {
+	return MaskMiddle(card, 0, 4)
+}
+
+// MaskSecret fully redacts a secret. The output length does not reveal the
+// original length.
+func MaskSecret(s string) string {
+	if s == "" {
+		return s
+	}
+	return redacted
+}
+
+// Mask masks s according to the given kind. Unknown kinds are fully redacted.
+func Mask(kind, s string) string {
+	switch kind {
+	case MaskKindPhone:
+		return MaskPhone(s)
+	case MaskKindEmail:
+		return MaskEmail(s)
+	case MaskKindIDCard:
+		return MaskIDCard(s)
+	case MaskKindBankCard:
+		return MaskBankCard(s)
+	case MaskKindMiddle:
+		n := utf8.RuneCountInString(s) / 4
+		return MaskMiddle(s, n, n)
+	default:
+		return MaskSecret(s)
+	}
+}
+
+// MaskStruct returns a masked deep copy of v. String fields tagged with
+// `mask:"<kind>"` are masked using Mask; nested structs, pointers, slices and
+// maps are traversed. v itself is never modified, and values referring to
+// themselves are copied with the same cycles.
+//
+// Example:
+//
+//	type User struct {
+//	    Name  string `json:"name"`
+//	    Phone string `json:"phone" mask:"phone"`
+//	    Email string `json:"email" mask:"email"`
+//	}
+//	masked := utils.MaskStruct(user).(User)
+func MaskStruct(v any) any {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	m := masker{copies: map[maskRef]reflect.Value{}}
+	return m.mask(rv, "").Interface()
+}
+
+// maskRef identifies a pointer, slice or map being copied under a mask
+// kind. The type tells apart a struct from its first field, the length a
+// slice from its prefixes.
+type maskRef struct {
+	t    reflect.Type
+	ptr  uintptr
+	len  int
+	kind string
+}
+
+// masker copies values once each, so that shared and cyclic references
+// are copied as such instead of recursing forever.
+type masker struct {
+	copies map[maskRef]reflect.Value
+}
+
+// mask returns a masked copy of v. kind is the mask tag inherited from
+// the enclosing field, applied to string values.
+func (m *masker) mask(v reflect.Value, kind string) reflect.Value {
+	switch v.Kind() {
+	case reflect.String:
+		if kind == "" {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.SetString(Mask(kind, v.String()))
+		return out
+
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		ref := maskRef{t: v.Type(), ptr: v.Pointer(), kind: kind}
+		if out, ok := m.copies[ref]; ok {
+			return out
+		}
+		out := reflect.New(v.Type().Elem())
+		m.copies[ref] = out
+		out.Elem().Set(m.mask(v.Elem(), kind))
+		return out
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(m.mask(v.Elem(), kind))
+		return out
+
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			out.Field(i).Set(m.mask(v.Field(i), field.Tag.Get("mask")))
+		}
+		return out
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		ref := maskRef{t: v.Type(), ptr: v.Pointer(), len: v.Len(), kind: kind}
+		if out, ok := m.copies[ref]; ok {
+			return out
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		m.copies[ref] = out
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(m.mask(v.Index(i), kind))
+		}
+		return out
+
+	case reflect.Array:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(m.mask(v.Index(i), kind))
+		}
+		return out
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		ref := maskRef{t: v.Type(), ptr: v.Pointer(), kind: kind}
+		if out, ok := m.copies[ref]; ok {
+			return out
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		m.copies[ref] = out
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), m.mask(iter.Value(), kind))
+		}
+		return out
+
+	default:
+		return v
+	}
+}
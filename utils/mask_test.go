@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// fuzzMask checks that mask never returns s whole, for the inputs where
+// that is possible: not empty and without the mask rune itself, which a
+// fully masked input would give back unchanged.
+func fuzzMask(f *testing.F, mask func(string) string, seeds ...string) {
+	for _, s := range seeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if s == "" || strings.ContainsRune(s, maskRune) {
+			t.Skip()
+		}
+		if out := mask(s); strings.Contains(out, s) {
+			t.Fatalf("mask(%q) = %q leaks the value", s, out)
+		}
+	})
+}
+
+func FuzzMaskPhone(f *testing.F) {
+	fuzzMask(f, MaskPhone, "13812341234", "+86 138 1234 1234", "12345", "一二三四五六七八")
+}
+
+func FuzzMaskEmail(f *testing.F) {
+	fuzzMask(f, MaskEmail, "alice.b@example.com", "a@b", "@example.com", "用户@例子.中国")
+}
+
+func FuzzMaskIDCard(f *testing.F) {
+	fuzzMask(f, MaskIDCard, "110101199003071234", "1234", "身份证号码")
+}
+
+func FuzzMaskBankCard(f *testing.F) {
+	fuzzMask(f, MaskBankCard, "6222021234567890123", "1234", "卡号一二三四五")
+}
+
+func FuzzMaskSecret(f *testing.F) {
+	fuzzMask(f, MaskSecret, "hunter2", "s", "密码")
+}
+
+func FuzzMaskMiddle(f *testing.F) {
+	fuzzMask(f, func(s string) string { return Mask(MaskKindMiddle, s) }, "abcdefgh", "ab", "中文字符串")
+}
+
+func FuzzMaskStruct(f *testing.F) {
+	type account struct {
+		Phone string `mask:"phone"`
+		Card  string `mask:"bankcard"`
+		Token string `mask:"secret"`
+	}
+	f.Add("13812341234")
+	f.Add("一二三四五六七八")
+	f.Fuzz(func(t *testing.T, s string) {
+		if s == "" || strings.ContainsRune(s, maskRune) {
+			t.Skip()
+		}
+		in := &account{Phone: s, Card: s, Token: s}
+		out := MaskStruct(in).(*account)
+		for name, got := range map[string]string{"Phone": out.Phone, "Card": out.Card, "Token": out.Token} {
+			if strings.Contains(got, s) {
+				t.Fatalf("%s of %q: %q leaks the value", name, s, got)
+			}
+		}
+		if in.Phone != s || in.Card != s || in.Token != s {
+			t.Fatal("MaskStruct modified its argument")
+		}
+	})
+}
+
+func TestMaskMiddleKeepsRunes(t *testing.T) {
+	s := "张三丰的身份证"
+	out := MaskMiddle(s, 1, 1)
+	if !utf8.ValidString(out) || utf8.RuneCountInString(out) != utf8.RuneCountInString(s) {
+		t.Fatalf("MaskMiddle(%q) = %q", s, out)
+	}
+	if want := "张*****证"; out != want {
+		t.Fatalf("MaskMiddle(%q) = %q, want %q", s, out, want)
+	}
+}
+
+type maskNode struct {
+	Phone    string `mask:"phone"`
+	Parent   *maskNode
+	Children []*maskNode
+	Attrs    map[string]any
+}
+
+func TestMaskStructCycles(t *testing.T) {
+	n := &maskNode{Phone: "13812341234", Attrs: map[string]any{}}
+	n.Parent = n
+	n.Children = []*maskNode{n}
+	n.Attrs["self"] = n.Attrs
+
+	out := MaskStruct(n).(*maskNode)
+	if out == n {
+		t.Fatal("MaskStruct returned its argument")
+	}
+	if out.Phone != "138****1234" {
+		t.Fatalf("Phone = %q", out.Phone)
+	}
+	if out.Parent != out || out.Children[0] != out {
+		t.Fatal("the cycles of the copy do not point to the copy")
+	}
+	if n.Phone != "13812341234" {
+		t.Fatal("MaskStruct modified its argument")
+	}
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"github.com/NSObjects/go-kit/errors"
+)
+
+// KeyRequestCache is the context key of the request's RequestCache.
+const KeyRequestCache ContextKey = "request_cache"
+
+// RequestCache memoizes lookups for the duration of one request, so that
+// handlers and the services they call load the same row once. It lives in
+// memory and is dropped with the request; use cache.Cache for values
+// shared between requests.
+//
+// middleware.RequestScope attaches one to every request context; use Memo
+// to read through it.
+type RequestCache struct {
+	mu      sync.Mutex
+	entries map[memoKey]*memoEntry
+}
+
+// memoKey scopes keys by value type, so that Memo calls with the same key
+// and different types never see each other's values.
+type memoKey struct {
+	typ reflect.Type
+	key string
+}
+
+type memoEntry struct {
+	done  chan struct{}
+	value any
+	err   error
+}
+
+// NewRequestCache creates an empty RequestCache.
+func NewRequestCache() *RequestCache {
+	return &RequestCache{entries: make(map[memoKey]*memoEntry)}
+}
+
+// Forget drops the values of key, e.g. after the request updated the row
+// behind it.
+func (rc *RequestCache) Forget(key string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for k := range rc.entries {
+		if k.key == key {
+			delete(rc.entries, k)
+		}
+	}
+}
+
+// WithRequestCache returns a context carrying rc.
+func WithRequestCache(ctx context.Context, rc *RequestCache) context.Context {
+	return context.WithValue(ctx, KeyRequestCache, rc)
+}
+
+// GetRequestCache returns the RequestCache of ctx, or nil.
+func GetRequestCache(ctx context.Context) *RequestCache {
+	rc, _ := ctx.Value(KeyRequestCache).(*RequestCache)
+	return rc
+}
+
+// Memo returns the value of key memoized in the RequestCache of ctx,
+// calling loader on first use. Concurrent calls for the same key, such as
+// from goroutines of utils.Parallel, wait for a single loader call.
+// Errors are shared with the waiting calls but not memoized, so a later
+// call loads again. Without a RequestCache in ctx, loader is always
+// called.
+//
+//	user, err := utils.Memo(ctx, "user:"+id, func(ctx context.Context) (*User, error) {
+//	    return repo.FindByID(ctx, id)
+//	})
+func Memo[T any](ctx context.Context, key string, loader func(ctx context.Context) (T, error)) (T, error) {
+	rc := GetRequestCache(ctx)
+	if rc == nil {
+		return loader(ctx)
+	}
+	k := memoKey{typ: reflect.TypeFor[T](), key: key}
+
+	rc.mu.Lock()
+	if e, ok := rc.entries[k]; ok {
+		rc.mu.Unlock()
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+		if e.err != nil {
+			var zero T
+			return zero, e.err
+		}
+		return e.value.(T), nil
+	}
+	e := &memoEntry{done: make(chan struct{})}
+	rc.entries[k] = e
+	rc.mu.Unlock()
+
+	finish := func() {
+		if e.err != nil {
+			rc.mu.Lock()
+			if rc.entries[k] == e {
+				delete(rc.entries, k)
+			}
+			rc.mu.Unlock()
+		}
+		close(e.done)
+	}
+	defer func() {
+		// Release the waiting calls before the panic propagates.
+		if p := recover(); p != nil {
+			e.err = errors.FromPanic(p)
+			finish()
+			panic(p)
+		}
+	}()
+	value, err := loader(ctx)
+	e.value, e.err = value, err
+	finish()
+	return value, err
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"context"
+	"sync"
+
+	"github.com/NSObjects/go-kit/errors"
+)
+
+// Parallel runs fns concurrently with at most limit running at once.
+// It is fail-fast: the first error cancels the context passed to the
+// remaining functions, and that error is returned.
+// A non-positive limit means no limit.
+//
+// Usage:
+//
+//	err := utils.Parallel(ctx, 3,
+//	    func(ctx context.Context) error { return loadUser(ctx) },
+//	    func(ctx context.Context) error { return loadOrders(ctx) },
+//	)
+func Parallel(ctx context.Context, limit int, fns ...func(ctx context.Context) error) error {
+	return runParallel(ctx, limit, true, fns)
+}
+
+// ParallelAll is like Parallel but does not stop on the first error.
+// All functions run to completion and every error is returned, joined with
+// errors.Join.
+func ParallelAll(ctx context.Context, limit int, fns ...func(ctx context.Context) error) error {
+	return runParallel(ctx, limit, false, fns)
+}
+
+// MapConcurrent applies fn to every item with at most limit calls in flight and
+// returns the results in input order. It is fail-fast: on the first error the
+// context is canceled and the error is returned with a nil result slice.
+func MapConcurrent[T, R any](ctx context.Context, items []T, limit int, fn func(ctx context.Context, item T) (R, error)) ([]R, error) {
+	results := make([]R, len(items))
+	fns := make([]func(ctx context.Context) error, len(items))
+	for i := range items {
+		fns[i] = func(ctx context.Context) error {
+			r, err := fn(ctx, items[i])
+			if err != nil {
+				return err
+			}
+			results[i] = r
+			return nil
+		}
+	}
+
+	if err := runParallel(ctx, limit, true, fns); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func runParallel(ctx context.Context, limit int, failFast bool, fns []func(ctx context.Context) error) error {
+	if len(fns) == 0 {
+		return nil
+	}
+	if limit <= 0 || limit > len(fns) {
+		limit = len(fns)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+		sem  = make(chan struct{}, limit)
+	)
+
+	for _, fn := range fns {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			// Fail-fast already triggered or parent canceled; stop scheduling.
+			wg.Wait()
+			mu.Lock()
+			defer mu.Unlock()
+			if len(errs) == 0 {
+				return ctx.Err()
+			}
+			if failFast {
+				return errs[0]
+			}
+			return errors.Join(errs...)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := safeCall(ctx, fn); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				if failFast {
+					cancel()
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if len(errs) == 0 {
+		return nil
+	}
+	if failFast {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// safeCall runs fn and converts a panic into a coded error (see errors.FromPanic).
+func safeCall(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.FromPanic(r)
+		}
+	}()
+	return fn(ctx)
+}
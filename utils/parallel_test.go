@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NSObjects/go-kit/errors"
+)
+
+func TestParallelLimit(t *testing.T) {
+	var running, peak atomic.Int32
+	fns := make([]func(context.Context) error, 20)
+	for i := range fns {
+		fns[i] = func(context.Context) error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			return nil
+		}
+	}
+	if err := Parallel(context.Background(), 3, fns...); err != nil {
+		t.Fatal(err)
+	}
+	if got := peak.Load(); got > 3 {
+		t.Fatalf("peak concurrency = %d, want at most 3", got)
+	}
+}
+
+func TestParallelFailFast(t *testing.T) {
+	errFirst := errors.New("first")
+	var canceled atomic.Bool
+	err := Parallel(context.Background(), 0,
+		func(context.Context) error { return errFirst },
+		func(ctx context.Context) error {
+			select {
+			case <-ctx.Done():
+				canceled.Store(true)
+			case <-time.After(5 * time.Second):
+			}
+			return nil
+		},
+	)
+	if !errors.Is(err, errFirst) {
+		t.Fatalf("err = %v, want %v", err, errFirst)
+	}
+	if !canceled.Load() {
+		t.Fatal("the other function's context was not canceled")
+	}
+}
+
+func TestParallelAllJoinsErrors(t *testing.T) {
+	errA, errB := errors.New("a"), errors.New("b")
+	var ran atomic.Int32
+	err := ParallelAll(context.Background(), 2,
+		func(context.Context) error { ran.Add(1); return errA },
+		func(context.Context) error { ran.Add(1); return nil },
+		func(context.Context) error { ran.Add(1); return errB },
+	)
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Fatalf("err = %v, want both errors", err)
+	}
+	if got := ran.Load(); got != 3 {
+		t.Fatalf("ran %d functions, want 3", got)
+	}
+}
+
+func TestParallelPanic(t *testing.T) {
+	err := Parallel(context.Background(), 1, func(context.Context) error { panic("boom") })
+	if !errors.IsPanic(err) {
+		t.Fatalf("err = %v, want a panic error", err)
+	}
+}
+
+func TestMapConcurrentOrder(t *testing.T) {
+	items := make([]int, 100)
+	for i := range items {
+		items[i] = i
+	}
+	got, err := MapConcurrent(context.Background(), items, 8, func(_ context.Context, i int) (int, error) {
+		time.Sleep(time.Duration(100-i) * time.Microsecond)
+		return i * i, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range got {
+		if v != i*i {
+			t.Fatalf("result %d = %d, want %d", i, v, i*i)
+		}
+	}
+}
+
+func TestMapConcurrentError(t *testing.T) {
+	errOdd := errors.New("odd")
+	got, err := MapConcurrent(context.Background(), []int{2, 4, 5}, 2, func(_ context.Context, i int) (int, error) {
+		if i%2 == 1 {
+			return 0, errOdd
+		}
+		return i, nil
+	})
+	if !errors.Is(err, errOdd) || got != nil {
+		t.Fatalf("MapConcurrent = %v, %v; want nil, %v", got, err, errOdd)
+	}
+}
+
+func BenchmarkMapConcurrent(b *testing.B) {
+	items := make([]int, 64)
+	for b.Loop() {
+		_, _ = MapConcurrent(context.Background(), items, 8, func(_ context.Context, i int) (int, error) {
+			return i + 1, nil
+		})
+	}
+}
+
+// BenchmarkNaiveGoroutines is the baseline of BenchmarkMapConcurrent: one
+// goroutine per item, no limit, no panic recovery.
+func BenchmarkNaiveGoroutines(b *testing.B) {
+	items := make([]int, 64)
+	for b.Loop() {
+		results := make([]int, len(items))
+		var wg sync.WaitGroup
+		for i := range items {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = items[i] + 1
+			}()
+		}
+		wg.Wait()
+	}
+}
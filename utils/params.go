@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/labstack/echo/v4"
+)
+
+// Path and query parameter helpers. Failures are code.ValidationErrors
+// (ErrBadRequest) naming the parameter and the expected format, so they
+// render as 400 responses instead of surfacing as 500s. Combine several
+// extractions with MustParams:
+//
+//	id, err1 := utils.ParamInt64(c, "id")
+//	status, err2 := utils.ParamEnum(c, "status", "open", "closed")
+//	size, err3 := utils.QueryInt(c, "size", 20)
+//	if err := utils.MustParams(err1, err2, err3); err != nil {
+//	    return err
+//	}
+
+// ParamInt64 returns the path parameter name as an int64.
+func ParamInt64(c echo.Context, name string) (int64, error) {
+	s, err := requiredParam(c, name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, code.NewFieldError(name, "expected 64-bit integer")
+	}
+	return n, nil
+}
+
+// ParamULID returns the path parameter name as an upper-case ULID.
+func ParamULID(c echo.Context, name string) (string, error) {
+	s, err := requiredParam(c, name)
+	if err != nil {
+		return "", err
+	}
+	if !IsULID(s) {
+		return "", code.NewFieldError(name, "expected ULID")
+	}
+	return strings.ToUpper(s), nil
+}
+
+// ParamUUID returns the path parameter name as a lower-case UUID.
+func ParamUUID(c echo.Context, name string) (string, error) {
+	s, err := requiredParam(c, name)
+	if err != nil {
+		return "", err
+	}
+	if !IsUUID(s) {
+		return "", code.NewFieldError(name, "expected UUID")
+	}
+	return strings.ToLower(s), nil
+}
+
+// ParamEnum returns the path parameter name when it is one of allowed.
+func ParamEnum(c echo.Context, name string, allowed ...string) (string, error) {
+	s, err := requiredParam(c, name)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(allowed, s) {
+		return "", code.NewFieldError(name, "expected one of "+strings.Join(allowed, ", "))
+	}
+	return s, nil
+}
+
+// QueryInt returns the query parameter name as an int, or def when it is
+// absent or empty.
+func QueryInt(c echo.Context, name string, def int) (int, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def, code.NewFieldError(name, "expected integer")
+	}
+	return n, nil
+}
+
+// QueryBool returns the query parameter name as a bool (1, t, true, 0, f,
+// false, ...), or def when it is absent or empty.
+func QueryBool(c echo.Context, name string, def bool) (bool, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return def, code.NewFieldError(name, "expected boolean")
+	}
+	return b, nil
+}
+
+// QueryTime returns the query parameter name parsed by ParseTime, or def
+// when it is absent or empty.
+func QueryTime(c echo.Context, name string, def time.Time) (time.Time, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return def, nil
+	}
+	t, err := ParseTime(s)
+	if err != nil {
+		return def, code.NewFieldError(name, "expected RFC3339 time, date or unix timestamp")
+	}
+	return t, nil
+}
+
+// MustParams aggregates the errors of several parameter extractions into
+// one ValidationErrors. Errors that are not field errors are returned as
+// is. It returns nil when every err is nil.
+func MustParams(errs ...error) error {
+	var fields code.ValidationErrors
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		var fe code.ValidationErrors
+		if !errors.As(err, &fe) {
+			return err
+		}
+		fields = append(fields, fe...)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// ParseTime accepts RFC3339, a date (2006-01-02), or unix epoch seconds or
+// milliseconds.
+func ParseTime(s string) (time.Time, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n > 1e12 || n < -1e12 {
+			return time.UnixMilli(n), nil
+		}
+		return time.Unix(n, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+// IsUUID reports whether s is a UUID in the canonical 8-4-4-4-12 form.
+func IsUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !isHex(s[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func requiredParam(c echo.Context, name string) (string, error) {
+	s := c.Param(name)
+	if s == "" {
+		return "", code.NewFieldError(name, "required")
+	}
+	return s, nil
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// KeyOriginRequestID is the context key for the request ID of the work that
+// triggered the current execution (see WithNewRequestID).
+const KeyOriginRequestID ContextKey = "origin_request_id"
+
+// PropagationHeaders returns the headers that carry ctx's correlation across
+// a process boundary: X-Request-ID and the OpenTelemetry trace context
+// (traceparent, tracestate, baggage) of the global propagator.
+//
+//	for k, vs := range utils.PropagationHeaders(ctx) {
+//	    req.Header[k] = vs
+//	}
+func PropagationHeaders(ctx context.Context) http.Header {
+	h := make(http.Header)
+	if rid := GetRequestID(ctx); rid != "" {
+		h.Set(echo.HeaderXRequestID, rid)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(h))
+	return h
+}
+
+// ContextFromHeaders restores the request ID and trace context written by
+// PropagationHeaders into ctx. A request ID already in ctx is kept when the
+// headers carry none.
+func ContextFromHeaders(ctx context.Context, h http.Header) context.Context {
+	if rid := h.Get(echo.HeaderXRequestID); rid != "" {
+		ctx = context.WithValue(ctx, KeyRequestID, rid)
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(h))
+}
+
+// WithNewRequestID returns a context with a fresh ULID request ID, for
+// executions not driven by a request (scheduled jobs, relays). The request
+// ID found in ctx, if any, is kept as the origin request ID.
+//
+//	ctx = utils.WithNewRequestID(ctx)
+//	slog.InfoContext(ctx, "Job started",
+//	    slog.String("request_id", utils.GetRequestID(ctx)),
+//	    slog.String("origin_request_id", utils.GetOriginRequestID(ctx)))
+func WithNewRequestID(ctx context.Context) context.Context {
+	if origin := GetRequestID(ctx); origin != "" {
+		ctx = context.WithValue(ctx, KeyOriginRequestID, origin)
+	}
+	return context.WithValue(ctx, KeyRequestID, NewULID())
+}
+
+// GetOriginRequestID returns the origin request ID set by WithNewRequestID.
+// Returns empty string if not found.
+func GetOriginRequestID(ctx context.Context) string {
+	if v, ok := ctx.Value(KeyOriginRequestID).(string); ok {
+		return v
+	}
+	return ""
+}
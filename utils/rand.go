@@ -0,0 +1,68 @@
+package utils
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand/v2"
+	"sync"
+)
+
+// Rand is a source of randomness, for jitter, sampling and ID entropy.
+// RealRand is the default; NewSeededRand makes runs reproducible in
+// tests.
+type Rand interface {
+	// Float64 returns a number in [0, 1).
+	Float64() float64
+	// Int64N returns a number in [0, n); it panics when n <= 0.
+	Int64N(n int64) int64
+	// Read fills p with random bytes; it never fails.
+	Read(p []byte) (int, error)
+}
+
+// RealRand is the default Rand: math/rand/v2 for numbers and crypto/rand
+// for bytes.
+type RealRand struct{}
+
+// Float64 implements Rand.
+func (RealRand) Float64() float64 { return rand.Float64() }
+
+// Int64N implements Rand.
+func (RealRand) Int64N(n int64) int64 { return rand.Int64N(n) }
+
+// Read implements Rand.
+func (RealRand) Read(p []byte) (int, error) { return crand.Read(p) }
+
+// seededRand is a Rand drawing from a seeded ChaCha8.
+type seededRand struct {
+	mu  sync.Mutex
+	src *rand.ChaCha8
+	rnd *rand.Rand
+}
+
+// NewSeededRand returns a Rand that yields the same values for the same
+// seed, for tests. It is safe for concurrent use; it is not suitable for
+// secrets.
+func NewSeededRand(seed uint64) Rand {
+	var s [32]byte
+	binary.LittleEndian.PutUint64(s[:], seed)
+	src := rand.NewChaCha8(s)
+	return &seededRand{src: src, rnd: rand.New(src)}
+}
+
+func (r *seededRand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.Float64()
+}
+
+func (r *seededRand) Int64N(n int64) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.Int64N(n)
+}
+
+func (r *seededRand) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.src.Read(p)
+}
@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Trusted proxy presets accepted by NewTrustedProxies.
+var trustedProxyPresets = map[string][]string{
+	"loopback": {"127.0.0.0/8", "::1/128"},
+	"private": {
+		"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "127.0.0.0/8",
+		"fc00::/7", "::1/128",
+	},
+	// https://www.cloudflare.com/ips/
+	"cloudflare": {
+		"173.245.48.0/20", "103.21.244.0/22", "103.22.200.0/22", "103.31.4.0/22",
+		"141.101.64.0/18", "108.162.192.0/18", "190.93.240.0/20", "188.114.96.0/20",
+		"197.234.240.0/22", "198.41.128.0/17", "162.158.0.0/15", "104.16.0.0/13",
+		"104.24.0.0/14", "172.64.0.0/13", "131.0.72.0/22",
+		"2400:cb00::/32", "2606:4700::/32", "2803:f800::/32", "2405:b500::/32",
+		"2405:8100::/32", "2a06:98c0::/29", "2c0f:f248::/32",
+	},
+}
+
+// maxForwardedHops bounds the X-Forwarded-For entries inspected.
+const maxForwardedHops = 32
+
+// TrustedProxies is the set of proxies whose forwarding headers are
+// believed. A nil *TrustedProxies trusts nothing.
+type TrustedProxies struct {
+	prefixes []netip.Prefix
+}
+
+// NewTrustedProxies parses IPs, CIDRs and the presets "loopback",
+// "private" and "cloudflare".
+//
+//	proxies, err := utils.NewTrustedProxies(cfg.System.TrustedProxies)
+//	e.IPExtractor = proxies.IPExtractor()
+func NewTrustedProxies(entries []string) (*TrustedProxies, error) {
+	t := &TrustedProxies{}
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if preset, ok := trustedProxyPresets[strings.ToLower(e)]; ok {
+			for _, p := range preset {
+				t.prefixes = append(t.prefixes, netip.MustParsePrefix(p))
+			}
+			continue
+		}
+		p, err := ParsePrefix(e)
+		if err != nil {
+			return nil, err
+		}
+		t.prefixes = append(t.prefixes, p)
+	}
+	return t, nil
+}
+
+// ParsePrefix parses a CIDR or a single IP (as a full-length prefix).
+func ParsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR %q: %w", s, err)
+		}
+		return p.Masked(), nil
+	}
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP %q: %w", s, err)
+	}
+	ip = ip.Unmap()
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
+
+// Contains reports whether ip is a trusted proxy.
+func (t *TrustedProxies) Contains(ip netip.Addr) bool {
+	if t == nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, p := range t.prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the client IP of a request. Forwarding headers are only
+// honored when the peer is trusted: X-Forwarded-For is walked right to left
+// past trusted hops, then X-Real-IP is used. Malformed or implausible
+// (unspecified, multicast) forwarded values are treated as spoofed and the
+// peer is returned. It reports false when the peer address is unparsable.
+func (t *TrustedProxies) Resolve(remoteAddr string, h http.Header) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	peer = peer.WithZone("").Unmap()
+	if !t.Contains(peer) {
+		return peer, true
+	}
+
+	if xff := strings.Join(h[echo.HeaderXForwardedFor], ","); xff != "" {
+		hops := strings.Split(xff, ",")
+		if len(hops) > maxForwardedHops {
+			hops = hops[len(hops)-maxForwardedHops:]
+		}
+		client := peer
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip, ok := parseForwarded(hops[i])
+			if !ok {
+				return peer, true
+			}
+			client = ip
+			if !t.Contains(ip) {
+				break
+			}
+		}
+		return client, true
+	}
+	if xr := h[echo.HeaderXRealIP]; len(xr) > 0 {
+		if ip, ok := parseForwarded(xr[0]); ok {
+			return ip, true
+		}
+	}
+	return peer, true
+}
+
+// IPExtractor returns an echo.IPExtractor using Resolve, so c.RealIP()
+// honors the trusted proxies.
+func (t *TrustedProxies) IPExtractor() echo.IPExtractor {
+	return func(r *http.Request) string {
+		ip, ok := t.Resolve(r.RemoteAddr, r.Header)
+		if !ok {
+			return ""
+		}
+		return ip.String()
+	}
+}
+
+// RealIP returns the client IP of c resolved against trusted (nil trusts
+// no proxy). It returns an empty string when no address can be determined.
+func RealIP(c echo.Context, trusted *TrustedProxies) string {
+	ip, ok := trusted.Resolve(c.Request().RemoteAddr, c.Request().Header)
+	if !ok {
+		return ""
+	}
+	return ip.String()
+}
+
+// parseForwarded parses one forwarded address, allowing "[v6]" and an
+// optional port, and rejects implausible values.
+func parseForwarded(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		s = ap.Addr().String()
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	ip, err := netip.ParseAddr(s)
+	if err != nil || ip.Zone() != "" {
+		return netip.Addr{}, false
+	}
+	ip = ip.Unmap()
+	if ip.IsUnspecified() || ip.IsMulticast() || ip == netip.AddrFrom4([4]byte{255, 255, 255, 255}) {
+		return netip.Addr{}, false
+	}
+	return ip, true
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RetryPolicy controls retry behavior.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first; default 3.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry; default 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts; default 2s.
+	MaxBackoff time.Duration
+	// Multiplier grows the backoff after each attempt; default 2.
+	Multiplier float64
+	// Jitter randomizes each delay by up to this fraction (0-1).
+	Jitter float64
+	// RetryIf reports whether an error is retryable. Nil retries every error
+	// except those marked with Permanent.
+	RetryIf func(err error) bool
+	// Clock waits between attempts (see NewTimer); default RealClock.
+	Clock Clock
+	// Rand draws the jitter; default RealRand.
+	Rand Rand
+}
+
+// DefaultRetryPolicy returns the default retry policy.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// permanentError marks an error as non-retryable.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that Retry stops immediately and returns err.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Retry calls fn until it succeeds, returns a non-retryable error, the
+// attempts are exhausted, or ctx is done. The last error is returned.
+func Retry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	policy = policy.withDefaults()
+
+	backoff := policy.InitialBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn(ctx)
+		if err == nil {
+			return nil
+		}
+
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+		if policy.RetryIf != nil && !policy.RetryIf(err) {
+			return err
+		}
+		if attempt >= policy.MaxAttempts {
+			return err
+		}
+
+		if Sleep(ctx, policy.Clock, policy.jittered(backoff)) != nil {
+			return err
+		}
+
+		backoff = time.Duration(float64(backoff) * policy.Multiplier)
+		if backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	def := DefaultRetryPolicy()
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = def.MaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = def.InitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = def.MaxBackoff
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = def.Multiplier
+	}
+	if p.Clock == nil {
+		p.Clock = RealClock{}
+	}
+	if p.Rand == nil {
+		p.Rand = RealRand{}
+	}
+	return p
+}
+
+func (p RetryPolicy) jittered(d time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return d
+	}
+	delta := (p.Rand.Float64()*2 - 1) * p.Jitter * float64(d)
+	return d + time.Duration(delta)
+}
@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+)
+
+// Output formats of TimeConfig.Format; any other value is a time layout.
+const (
+	TimeFormatRFC3339   = "rfc3339"
+	TimeFormatUnix      = "unix"
+	TimeFormatUnixMilli = "unix_milli"
+)
+
+// TimeConfig is the API time handling, typically SystemConfig.TimeZone and
+// SystemConfig.TimeFormat (middleware.Setup applies them).
+type TimeConfig struct {
+	// Location is the IANA name of the location times are rendered in and
+	// client times without an offset are read in; default UTC.
+	Location string
+	// Format is the JSON format of Time: TimeFormatRFC3339 (default),
+	// TimeFormatUnix, TimeFormatUnixMilli or a time layout.
+	Format string
+}
+
+type timeSettings struct {
+	loc    *time.Location
+	format string
+}
+
+var currentTime atomic.Pointer[timeSettings]
+
+// SetTimeConfig sets the process-wide time handling.
+func SetTimeConfig(cfg TimeConfig) error {
+	loc := time.UTC
+	if cfg.Location != "" {
+		var err error
+		if loc, err = time.LoadLocation(cfg.Location); err != nil {
+			return fmt.Errorf("time location: %w", err)
+		}
+	}
+	format := cfg.Format
+	if format == "" {
+		format = TimeFormatRFC3339
+	}
+	currentTime.Store(&timeSettings{loc: loc, format: format})
+	return nil
+}
+
+func timeConfig() *timeSettings {
+	if s := currentTime.Load(); s != nil {
+		return s
+	}
+	return &timeSettings{loc: time.UTC, format: TimeFormatRFC3339}
+}
+
+// Location returns the configured location (see SetTimeConfig).
+func Location() *time.Location {
+	return timeConfig().loc
+}
+
+// NowIn returns the current time in the configured location.
+func NowIn() time.Time {
+	return time.Now().In(Location())
+}
+
+// clientLayouts are the layouts ParseClientTime accepts, besides Unix
+// timestamps. Layouts without an offset are read in the configured location.
+var clientLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// ParseClientTime parses a time sent by a client: RFC 3339, a date and time
+// without offset (read in the configured location), a date, or a Unix
+// timestamp in seconds or milliseconds. Local times in a DST gap or overlap
+// resolve as in time.Date. Failures are code.ErrBadRequest.
+func ParseClientTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	cfg := timeConfig()
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return unixTime(n, cfg), nil
+	}
+	layouts := clientLayouts
+	if !isNamedTimeFormat(cfg.format) {
+		layouts = append([]string{cfg.format}, clientLayouts...)
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, cfg.loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, code.NewErrorf(code.ErrBadRequest,
+		"invalid time %q: expected RFC 3339, YYYY-MM-DD[ HH:MM:SS] or a Unix timestamp", s)
+}
+
+// unixTime reads n as milliseconds with TimeFormatUnixMilli or when it is
+// too large for seconds, and as seconds otherwise.
+func unixTime(n int64, cfg *timeSettings) time.Time {
+	if cfg.format == TimeFormatUnixMilli || n >= 1e12 || n <= -1e12 {
+		return time.UnixMilli(n).In(cfg.loc)
+	}
+	return time.Unix(n, 0).In(cfg.loc)
+}
+
+func isNamedTimeFormat(format string) bool {
+	switch format {
+	case TimeFormatRFC3339, TimeFormatUnix, TimeFormatUnixMilli:
+		return true
+	}
+	return false
+}
+
+// Time is a time.Time rendered in JSON with the configured location and
+// format (see SetTimeConfig) and read from any format of ParseClientTime.
+// The zero Time is null in JSON and NULL in the database.
+//
+//	type Order struct {
+//	    ID        uint
+//	    DeliverAt utils.Time `json:"deliver_at"`
+//	}
+type Time struct {
+	time.Time
+}
+
+// NewTime returns t as a Time.
+func NewTime(t time.Time) Time {
+	return Time{Time: t}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	cfg := timeConfig()
+	switch cfg.format {
+	case TimeFormatUnix:
+		return strconv.AppendInt(nil, t.Unix(), 10), nil
+	case TimeFormatUnixMilli:
+		return strconv.AppendInt(nil, t.UnixMilli(), 10), nil
+	case TimeFormatRFC3339:
+		return strconv.AppendQuote(nil, t.In(cfg.loc).Format(time.RFC3339)), nil
+	default:
+		return strconv.AppendQuote(nil, t.In(cfg.loc).Format(cfg.format)), nil
+	}
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Time) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		if unquoted == "" {
+			t.Time = time.Time{}
+			return nil
+		}
+		s = unquoted
+	}
+	parsed, err := ParseClientTime(s)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (t Time) Value() (driver.Value, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	return t.Time, nil
+}
+
+// dbLayouts are the text forms drivers (SQLite) return times in.
+var dbLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// Scan implements sql.Scanner.
+func (t *Time) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		t.Time = time.Time{}
+		return nil
+	case time.Time:
+		t.Time = v
+		return nil
+	case []byte:
+		return t.scanText(string(v))
+	case string:
+		return t.scanText(v)
+	}
+	return fmt.Errorf("utils.Time: cannot scan %T", src)
+}
+
+func (t *Time) scanText(s string) error {
+	for _, layout := range dbLayouts {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("utils.Time: cannot parse %q", s)
+}
+
+// GormDataType maps Time to the dialect's time column type.
+func (Time) GormDataType() string {
+	return "time"
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/NSObjects/go-kit/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultTracerName is used until SetTracerName is called.
+const defaultTracerName = "github.com/NSObjects/go-kit"
+
+var tracerName atomic.Value // string
+
+// SetTracerName sets the tracer name used by StartSpan, typically
+// SystemConfig.Name (middleware.Setup does this).
+func SetTracerName(name string) {
+	if name != "" {
+		tracerName.Store(name)
+	}
+}
+
+// StartSpan starts a span from the global tracer provider with the
+// request_id, user_id and tenant_id context values attached as attributes.
+//
+//	ctx, span := utils.StartSpan(ctx, "orders.Create", attribute.String("order.id", id))
+//	defer span.End()
+//	if err := repo.Create(ctx, order); err != nil {
+//	    utils.SpanFromError(span, err)
+//	    return err
+//	}
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tn, _ := tracerName.Load().(string)
+	if tn == "" {
+		tn = defaultTracerName
+	}
+
+	all := make([]attribute.KeyValue, 0, len(attrs)+3)
+	if v := GetRequestID(ctx); v != "" {
+		all = append(all, attribute.String("request_id", v))
+	}
+	if v := GetUserID(ctx); v != "" {
+		all = append(all, attribute.String("user_id", v))
+	}
+	if v := GetTenantID(ctx); v != "" {
+		all = append(all, attribute.String("tenant_id", v))
+	}
+	all = append(all, attrs...)
+
+	return otel.Tracer(tn).Start(ctx, name, trace.WithAttributes(all...))
+}
+
+// SpanFromError records err on span, with its numeric code as the
+// error.code attribute for coded errors, and sets the span status to
+// Error. A nil err is ignored.
+func SpanFromError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	var opts []trace.EventOption
+	if c := errors.GetCode(err); c != 0 {
+		attr := attribute.Int("error.code", c)
+		span.SetAttributes(attr)
+		opts = append(opts, trace.WithAttributes(attr))
+	}
+	span.RecordError(err, opts...)
+	span.SetStatus(codes.Error, err.Error())
+}
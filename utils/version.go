@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version (semver 2.0).
+type Version struct {
+	Major, Minor, Patch int
+	// Prerelease is the dot-separated part after "-", e.g. "beta.2".
+	Prerelease string
+	// Build is the metadata after "+"; it does not take part in
+	// comparisons.
+	Build string
+}
+
+// ParseVersion parses a semantic version. It is lenient with what clients
+// send: a leading "v" and surrounding spaces are ignored, and a missing
+// minor or patch number is 0 ("2" is 2.0.0, "2.1" is 2.1.0).
+//
+//	v, err := utils.ParseVersion("v2.4.0-rc.1+build.77")
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	raw := s
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	s, build, hasBuild := strings.Cut(s, "+")
+	if hasBuild && !validIdentifiers(build, false) {
+		return Version{}, fmt.Errorf("invalid version %q: bad build metadata", raw)
+	}
+	s, pre, hasPre := strings.Cut(s, "-")
+	if hasPre && !validIdentifiers(pre, true) {
+		return Version{}, fmt.Errorf("invalid version %q: bad prerelease", raw)
+	}
+	v.Prerelease, v.Build = pre, build
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid version %q: too many numbers", raw)
+	}
+	nums := [3]*int{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		n, err := parseVersionNumber(p)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version %q: %w", raw, err)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+// MustParseVersion is ParseVersion that panics on invalid versions, for
+// constants.
+func MustParseVersion(s string) Version {
+	v, err := ParseVersion(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func parseVersionNumber(s string) (int, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty number")
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("non-numeric %q", s)
+		}
+	}
+	return strconv.Atoi(s)
+}
+
+// validIdentifiers reports whether s is a dot-separated list of non-empty
+// [0-9A-Za-z-] identifiers; numeric prerelease identifiers must not have
+// leading zeros.
+func validIdentifiers(s string, prerelease bool) bool {
+	if s == "" {
+		return false
+	}
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		numeric := true
+		for _, r := range id {
+			switch {
+			case r >= '0' && r <= '9':
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '-':
+				numeric = false
+			default:
+				return false
+			}
+		}
+		if prerelease && numeric && len(id) > 1 && id[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare returns -1, 0 or +1 as v precedes, equals or follows o in
+// semver precedence: a prerelease precedes its release, and build
+// metadata is ignored.
+func (v Version) Compare(o Version) int {
+	if c := cmp.Compare(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	switch {
+	case v.Prerelease == o.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case o.Prerelease == "":
+		return -1
+	}
+	a, b := strings.Split(v.Prerelease, "."), strings.Split(o.Prerelease, ".")
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := comparePrerelease(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// comparePrerelease compares prerelease identifiers: numbers numerically
+// and before alphanumerics, which compare in ASCII order.
+func comparePrerelease(a, b string) int {
+	na, errA := parseVersionNumber(a)
+	nb, errB := parseVersionNumber(b)
+	switch {
+	case errA == nil && errB == nil:
+		return cmp.Compare(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// Less reports whether v precedes o.
+func (v Version) Less(o Version) bool {
+	return v.Compare(o) < 0
+}
+
+// String formats v as MAJOR.MINOR.PATCH[-PRERELEASE][+BUILD].
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// WithClientVersion returns a context carrying the app version of the
+// client (see middleware.ClientVersion).
+func WithClientVersion(ctx context.Context, v Version) context.Context {
+	return context.WithValue(ctx, KeyClientVersion, v)
+}
+
+// GetClientVersion returns the version set by WithClientVersion, and false
+// when the client sent none or an invalid one.
+func GetClientVersion(ctx context.Context) (Version, bool) {
+	v, ok := ctx.Value(KeyClientVersion).(Version)
+	return v, ok
+}
+
+// WithClientPlatform returns a context carrying the platform of the
+// client, such as "ios" or "android", lower-cased.
+func WithClientPlatform(ctx context.Context, platform string) context.Context {
+	return context.WithValue(ctx, KeyClientPlatform, strings.ToLower(platform))
+}
+
+// GetClientPlatform returns the platform set by WithClientPlatform.
+// Returns empty string if not found.
+func GetClientPlatform(ctx context.Context) string {
+	if v, ok := ctx.Value(KeyClientPlatform).(string); ok {
+		return v
+	}
+	return ""
+}
+
+// WithAPIVersion returns a context carrying the API version of the
+// request, such as "v2" (see middleware.APIVersion).
+func WithAPIVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, KeyAPIVersion, version)
+}
+
+// GetAPIVersion returns the version set by WithAPIVersion.
+// Returns empty string if not found.
+func GetAPIVersion(ctx context.Context) string {
+	if v, ok := ctx.Value(KeyAPIVersion).(string); ok {
+		return v
+	}
+	return ""
+}
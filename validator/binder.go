@@ -0,0 +1,284 @@
+package validator
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// Binder is an echo.Binder with richer query parameter binding. Fields
+// tagged `query` support:
+//
+//   - slices from repeated (?ids=1&ids=2) or comma-separated (?ids=1,2) values
+//   - time.Time from RFC3339, a date (2006-01-02) or unix epoch seconds/milliseconds
+//   - time.Duration from Go duration strings (e.g. "1m30s")
+//   - pointer fields, left nil when the parameter is absent
+//   - a `default:"..."` tag applied when the parameter is absent or empty
+//
+// Query parameters are bound for every method; path params and the body
+// are bound as by echo.DefaultBinder. All failures are ErrBind errors; query
+// failures wrap a code.ValidationErrors naming every invalid parameter and
+// its expected type, rendered as field errors by resp.APIError.
+//
+//	type ListReq struct {
+//	    IDs   []int64    `query:"ids"`
+//	    Since *time.Time `query:"since"`
+//	    Size  int        `query:"size" default:"20"`
+//	}
+//
+// With BinderOptions.StrictJSON, JSON bodies are checked by CheckJSON
+// before they are bound, and its coded errors are returned as they are.
+type Binder struct {
+	echo.DefaultBinder
+	opts BinderOptions
+}
+
+// BinderOptions configure a Binder.
+type BinderOptions struct {
+	// StrictJSON rejects JSON bodies with duplicate keys or beyond
+	// JSONLimits (see CheckJSON).
+	StrictJSON bool
+	// JSONLimits bound the JSON bodies checked by StrictJSON.
+	JSONLimits JSONLimits
+}
+
+// NewBinder creates a Binder.
+func NewBinder(opts ...BinderOptions) *Binder {
+	var o BinderOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	return &Binder{opts: o}
+}
+
+// Bind binds path params, query params and the request body into i.
+func (b *Binder) Bind(i any, c echo.Context) error {
+	if err := b.BindPathParams(c, i); err != nil {
+		return code.WrapError(err, code.ErrBind, "invalid path parameter")
+	}
+	if err := BindQuery(c.QueryParams(), i); err != nil {
+		return code.WrapError(err, code.ErrBind, "invalid query parameters")
+	}
+	if err := b.BindBody(c, i); err != nil {
+		if errors.GetCode(err) != 0 {
+			return err
+		}
+		return code.WrapError(err, code.ErrBind, "invalid request body")
+	}
+	return nil
+}
+
+// BindBody binds the request body into i, checking JSON bodies first with
+// StrictJSON.
+func (b *Binder) BindBody(c echo.Context, i any) error {
+	if b.opts.StrictJSON {
+		if err := b.checkJSONBody(c); err != nil {
+			return err
+		}
+	}
+	return b.DefaultBinder.BindBody(c, i)
+}
+
+// checkJSONBody checks a JSON request body with CheckJSON, bounded by the
+// request context, and restores it for binding.
+func (b *Binder) checkJSONBody(c echo.Context) error {
+	req := c.Request()
+	if req.ContentLength == 0 || !strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		return nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return code.WrapError(err, code.ErrBind, "read request body")
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return CheckJSON(req.Context(), body, b.opts.JSONLimits)
+}
+
+// BindQuery binds values into the `query` tagged fields of the struct
+// pointed to by i. Non-struct targets are ignored. Invalid values are
+// reported together as a code.ValidationErrors.
+func BindQuery(values url.Values, i any) error {
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	var errs code.ValidationErrors
+	bindStruct(values, v.Elem(), &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func bindStruct(values url.Values, v reflect.Value, errs *code.ValidationErrors) {
+	t := v.Type()
+	for idx := 0; idx < t.NumField(); idx++ {
+		f := t.Field(idx)
+		fv := v.Field(idx)
+		tag := f.Tag.Get("query")
+
+		if tag == "" {
+			if f.Anonymous && fv.Kind() == reflect.Struct {
+				bindStruct(values, fv, errs)
+			}
+			continue
+		}
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		raw := nonEmpty(values[name])
+		if len(raw) == 0 {
+			def, ok := f.Tag.Lookup("default")
+			if !ok {
+				continue
+			}
+			raw = []string{def}
+		}
+		if err := setField(fv, raw); err != nil {
+			*errs = append(*errs, code.FieldError{Field: name, Message: "expected " + typeName(f.Type)})
+		}
+	}
+}
+
+// setField sets fv from the raw parameter values.
+func setField(fv reflect.Value, raw []string) error {
+	if fv.Kind() == reflect.Pointer {
+		p := reflect.New(fv.Type().Elem())
+		if err := setField(p.Elem(), raw); err != nil {
+			return err
+		}
+		fv.Set(p)
+		return nil
+	}
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+		var items []string
+		for _, r := range raw {
+			for _, item := range strings.Split(r, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		}
+		s := reflect.MakeSlice(fv.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setScalar(s.Index(i), item); err != nil {
+				return err
+			}
+		}
+		fv.Set(s)
+		return nil
+	}
+	return setScalar(fv, raw[0])
+}
+
+// setScalar parses s into fv.
+func setScalar(fv reflect.Value, s string) error {
+	switch fv.Type() {
+	case timeType:
+		t, err := utils.ParseTime(s)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+	if u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+// typeName describes t for bind error messages.
+func typeName(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer {
+		return typeName(t.Elem())
+	}
+	switch t {
+	case timeType:
+		return "RFC3339 time, date or unix timestamp"
+	case durationType:
+		return "duration"
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		if t.Elem().Kind() != reflect.Uint8 {
+			return "list of " + typeName(t.Elem())
+		}
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "unsigned integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return t.String()
+}
+
+// nonEmpty drops empty values so "?size=" counts as absent.
+func nonEmpty(vals []string) []string {
+	out := vals[:0:0]
+	for _, v := range vals {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,432 @@
+package validator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/errors"
+)
+
+// Defaults of JSONLimits.
+const (
+	DefaultJSONMaxDepth        = 64
+	DefaultJSONMaxStringLength = 1 << 20
+	DefaultJSONMaxTokens       = 1 << 17
+	DefaultJSONDecodeTimeout   = 2 * time.Second
+)
+
+// JSONLimits bound the JSON documents accepted by CheckJSON. Zero values
+// use the defaults.
+type JSONLimits struct {
+	// MaxDepth is the deepest nesting of objects and arrays; default
+	// DefaultJSONMaxDepth.
+	MaxDepth int
+	// MaxStringLength is the longest string or key, in encoded bytes;
+	// default DefaultJSONMaxStringLength.
+	MaxStringLength int
+	// MaxTokens is the most keys and values in a document; default
+	// DefaultJSONMaxTokens.
+	MaxTokens int
+	// Timeout bounds the check, within the deadline of its context;
+	// default DefaultJSONDecodeTimeout.
+	Timeout time.Duration
+}
+
+func (l JSONLimits) withDefaults() JSONLimits {
+	if l.MaxDepth <= 0 {
+		l.MaxDepth = DefaultJSONMaxDepth
+	}
+	if l.MaxStringLength <= 0 {
+		l.MaxStringLength = DefaultJSONMaxStringLength
+	}
+	if l.MaxTokens <= 0 {
+		l.MaxTokens = DefaultJSONMaxTokens
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = DefaultJSONDecodeTimeout
+	}
+	return l
+}
+
+// CheckJSON reports whether data is a single JSON document within limits
+// and without duplicate keys, which encoding/json would silently resolve
+// to the last value, letting a value smuggled past one parser reach
+// another. Keys are compared after unescaping, so "a" and "\u0061" are
+// duplicates; keys differing in case are not.
+//
+// Duplicate keys and too deep nesting fail with code.ErrBadRequest, naming
+// the key or the path in the "key" and "path" details; too long strings and
+// too many tokens with code.ErrPayloadTooLarge; malformed JSON with
+// code.ErrBind. Running past the timeout or the deadline of ctx fails with
+// code.ErrTimeout, a canceled ctx with code.ErrClientClosedRequest.
+func CheckJSON(ctx context.Context, data []byte, limits JSONLimits) error {
+	limits = limits.withDefaults()
+	s := &jsonScanner{data: data, limits: limits, ctx: ctx, deadline: time.Now().Add(limits.Timeout)}
+	if d, ok := ctx.Deadline(); ok && d.Before(s.deadline) {
+		s.deadline = d
+	}
+	s.skipSpace()
+	if err := s.value(0); err != nil {
+		return err
+	}
+	s.skipSpace()
+	if s.pos < len(s.data) {
+		return s.syntaxError("data after the top-level value")
+	}
+	return nil
+}
+
+// jsonCheckInterval is how many tokens pass between deadline checks.
+const jsonCheckInterval = 1024
+
+// smallObject is the key count up to which duplicates are found by a
+// linear scan rather than a map.
+const smallObject = 16
+
+// pathElem is a key or an array index of the path to the current value.
+type pathElem struct {
+	key   []byte
+	index int
+}
+
+// jsonScanner validates a JSON document in one pass, without building
+// values.
+type jsonScanner struct {
+	data     []byte
+	pos      int
+	limits   JSONLimits
+	ctx      context.Context
+	deadline time.Time
+	tokens   int
+	path     []pathElem
+	keys     [][]byte // keys of the open objects, innermost last
+}
+
+func (s *jsonScanner) value(depth int) error {
+	if err := s.count(); err != nil {
+		return err
+	}
+	if s.pos >= len(s.data) {
+		return s.syntaxError("unexpected end of input")
+	}
+	switch c := s.data[s.pos]; {
+	case c == '{':
+		return s.object(depth + 1)
+	case c == '[':
+		return s.array(depth + 1)
+	case c == '"':
+		_, err := s.str(false)
+		return err
+	case c == '-' || (c >= '0' && c <= '9'):
+		return s.number()
+	case c == 't':
+		return s.literal("true")
+	case c == 'f':
+		return s.literal("false")
+	case c == 'n':
+		return s.literal("null")
+	default:
+		return s.syntaxError("invalid character " + strconv.QuoteRune(rune(c)))
+	}
+}
+
+func (s *jsonScanner) object(depth int) error {
+	if depth > s.limits.MaxDepth {
+		return s.depthError()
+	}
+	s.pos++ // {
+	s.skipSpace()
+	if s.pos < len(s.data) && s.data[s.pos] == '}' {
+		s.pos++
+		return nil
+	}
+
+	start := len(s.keys)
+	defer func() { s.keys = s.keys[:start] }()
+	var set map[string]struct{}
+	s.path = append(s.path, pathElem{})
+	defer func() { s.path = s.path[:len(s.path)-1] }()
+
+	for {
+		if s.pos >= len(s.data) || s.data[s.pos] != '"' {
+			return s.syntaxError("expected object key")
+		}
+		if err := s.count(); err != nil {
+			return err
+		}
+		key, err := s.str(true)
+		if err != nil {
+			return err
+		}
+		s.path[len(s.path)-1].key = key
+
+		switch n := len(s.keys) - start; {
+		case n < smallObject:
+			for _, k := range s.keys[start:] {
+				if bytes.Equal(k, key) {
+					return s.duplicateError(key)
+				}
+			}
+			s.keys = append(s.keys, key)
+		case n == smallObject && set == nil:
+			set = make(map[string]struct{}, 2*smallObject)
+			for _, k := range s.keys[start:] {
+				set[string(k)] = struct{}{}
+			}
+			fallthrough
+		default:
+			if _, dup := set[string(key)]; dup {
+				return s.duplicateError(key)
+			}
+			set[string(key)] = struct{}{}
+		}
+
+		s.skipSpace()
+		if s.pos >= len(s.data) || s.data[s.pos] != ':' {
+			return s.syntaxError("expected colon after object key")
+		}
+		s.pos++
+		s.skipSpace()
+		if err := s.value(depth); err != nil {
+			return err
+		}
+		s.skipSpace()
+		if s.pos >= len(s.data) {
+			return s.syntaxError("unexpected end of input")
+		}
+		switch s.data[s.pos] {
+		case ',':
+			s.pos++
+			s.skipSpace()
+		case '}':
+			s.pos++
+			return nil
+		default:
+			return s.syntaxError("expected comma or end of object")
+		}
+	}
+}
+
+func (s *jsonScanner) array(depth int) error {
+	if depth > s.limits.MaxDepth {
+		return s.depthError()
+	}
+	s.pos++ // [
+	s.skipSpace()
+	if s.pos < len(s.data) && s.data[s.pos] == ']' {
+		s.pos++
+		return nil
+	}
+
+	s.path = append(s.path, pathElem{})
+	defer func() { s.path = s.path[:len(s.path)-1] }()
+
+	for i := 0; ; i++ {
+		s.path[len(s.path)-1].index = i
+		if err := s.value(depth); err != nil {
+			return err
+		}
+		s.skipSpace()
+		if s.pos >= len(s.data) {
+			return s.syntaxError("unexpected end of input")
+		}
+		switch s.data[s.pos] {
+		case ',':
+			s.pos++
+			s.skipSpace()
+		case ']':
+			s.pos++
+			return nil
+		default:
+			return s.syntaxError("expected comma or end of array")
+		}
+	}
+}
+
+// str scans a string and returns its value, unescaped if unescape is set.
+func (s *jsonScanner) str(unescape bool) ([]byte, error) {
+	start := s.pos
+	s.pos++ // "
+	escaped := false
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		switch {
+		case c == '"':
+			s.pos++
+			if s.pos-start-2 > s.limits.MaxStringLength {
+				return nil, s.tooLargeError("JSON string longer than %d bytes", s.limits.MaxStringLength)
+			}
+			raw := s.data[start+1 : s.pos-1]
+			if !escaped || !unescape {
+				return raw, nil
+			}
+			var v string
+			if err := json.Unmarshal(s.data[start:s.pos], &v); err != nil {
+				return nil, s.syntaxError("invalid string escape")
+			}
+			return []byte(v), nil
+		case c == '\\':
+			escaped = true
+			s.pos++
+			if s.pos >= len(s.data) {
+				return nil, s.syntaxError("unexpected end of input")
+			}
+			switch s.data[s.pos] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				s.pos++
+			case 'u':
+				if s.pos+4 >= len(s.data) || !isHex4(s.data[s.pos+1:s.pos+5]) {
+					return nil, s.syntaxError("invalid unicode escape")
+				}
+				s.pos += 5
+			default:
+				return nil, s.syntaxError("invalid string escape")
+			}
+		case c < 0x20:
+			return nil, s.syntaxError("control character in string")
+		default:
+			s.pos++
+		}
+	}
+	return nil, s.syntaxError("unterminated string")
+}
+
+func (s *jsonScanner) number() error {
+	d := s.data
+	p := s.pos
+	if d[p] == '-' {
+		p++
+	}
+	switch {
+	case p < len(d) && d[p] == '0':
+		p++
+	case p < len(d) && d[p] >= '1' && d[p] <= '9':
+		p = skipDigits(d, p)
+	default:
+		s.pos = p
+		return s.syntaxError("invalid number")
+	}
+	if p < len(d) && d[p] == '.' {
+		q := skipDigits(d, p+1)
+		if q == p+1 {
+			s.pos = q
+			return s.syntaxError("invalid number")
+		}
+		p = q
+	}
+	if p < len(d) && (d[p] == 'e' || d[p] == 'E') {
+		p++
+		if p < len(d) && (d[p] == '+' || d[p] == '-') {
+			p++
+		}
+		q := skipDigits(d, p)
+		if q == p {
+			s.pos = q
+			return s.syntaxError("invalid number")
+		}
+		p = q
+	}
+	if p-s.pos > s.limits.MaxStringLength {
+		return s.tooLargeError("JSON number longer than %d bytes", s.limits.MaxStringLength)
+	}
+	s.pos = p
+	return nil
+}
+
+func (s *jsonScanner) literal(lit string) error {
+	if !bytes.HasPrefix(s.data[s.pos:], []byte(lit)) {
+		return s.syntaxError("invalid literal")
+	}
+	s.pos += len(lit)
+	return nil
+}
+
+func (s *jsonScanner) skipSpace() {
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\n', '\r':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+// count counts a token against MaxTokens and checks the deadline every
+// jsonCheckInterval tokens.
+func (s *jsonScanner) count() error {
+	s.tokens++
+	if s.tokens > s.limits.MaxTokens {
+		return s.tooLargeError("JSON document with more than %d tokens", s.limits.MaxTokens)
+	}
+	if s.tokens%jsonCheckInterval == 0 {
+		if err := s.ctx.Err(); err != nil {
+			return errors.FromContextError(err)
+		}
+		if time.Now().After(s.deadline) {
+			return code.NewError(code.ErrTimeout, "JSON body took too long to decode")
+		}
+	}
+	return nil
+}
+
+func skipDigits(d []byte, p int) int {
+	for p < len(d) && d[p] >= '0' && d[p] <= '9' {
+		p++
+	}
+	return p
+}
+
+func isHex4(b []byte) bool {
+	for _, c := range b {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// pathString renders the path to the current value, e.g. items[2].name.
+func (s *jsonScanner) pathString() string {
+	var b strings.Builder
+	for _, e := range s.path {
+		if e.key != nil {
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.Write(e.key)
+		} else {
+			b.WriteByte('[')
+			b.WriteString(strconv.Itoa(e.index))
+			b.WriteByte(']')
+		}
+	}
+	return b.String()
+}
+
+func (s *jsonScanner) duplicateError(key []byte) error {
+	path := s.pathString()
+	err := code.NewErrorf(code.ErrBadRequest, "duplicate JSON key %q at %s", key, path)
+	return errors.WithDetails(err, map[string]any{"key": string(key), "path": path})
+}
+
+func (s *jsonScanner) depthError() error {
+	path := s.pathString()
+	err := code.NewErrorf(code.ErrBadRequest, "JSON nested deeper than %d levels at %s", s.limits.MaxDepth, path)
+	return errors.WithDetails(err, map[string]any{"path": path})
+}
+
+func (s *jsonScanner) tooLargeError(format string, limit int) error {
+	path := s.pathString()
+	err := code.NewErrorf(code.ErrPayloadTooLarge, format+" at %s", limit, path)
+	return errors.WithDetails(err, map[string]any{"path": path})
+}
+
+func (s *jsonScanner) syntaxError(msg string) error {
+	return code.NewErrorf(code.ErrBind, "invalid JSON at offset %d: %s", s.pos, msg)
+}
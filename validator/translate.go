@@ -0,0 +1,97 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/i18n"
+	"github.com/go-playground/validator/v10"
+)
+
+// defaultMessages are the English messages of common tags, with the field
+// as %[1]s and the parameter as %[2]s.
+var defaultMessages = map[string]string{
+	"required":  "%[1]s is required",
+	"email":     "%[1]s must be a valid email address",
+	"url":       "%[1]s must be a valid URL",
+	"uuid":      "%[1]s must be a valid UUID",
+	"ulid":      "%[1]s must be a valid ULID",
+	"min":       "%[1]s must be at least %[2]s",
+	"max":       "%[1]s must be at most %[2]s",
+	"len":       "%[1]s must have length %[2]s",
+	"gt":        "%[1]s must be greater than %[2]s",
+	"gte":       "%[1]s must be at least %[2]s",
+	"lt":        "%[1]s must be less than %[2]s",
+	"lte":       "%[1]s must be at most %[2]s",
+	"eq":        "%[1]s must equal %[2]s",
+	"ne":        "%[1]s must not equal %[2]s",
+	"oneof":     "%[1]s must be one of [%[2]s]",
+	"alpha":     "%[1]s must contain letters only",
+	"alphanum":  "%[1]s must contain letters and digits only",
+	"numeric":   "%[1]s must be numeric",
+	"gtfield":   "%[1]s must be after %[2]s",
+	"gtefield":  "%[1]s must not be before %[2]s",
+	"ltfield":   "%[1]s must be before %[2]s",
+	"ltefield":  "%[1]s must not be after %[2]s",
+	"eqfield":   "%[1]s must match %[2]s",
+	"necsfield": "%[1]s must differ from %[2]s",
+}
+
+// FieldMessage is the data of the "validation.<tag>" message templates.
+type FieldMessage struct {
+	// Field is the display name of the field: the "field.<name>" message
+	// of the bundle, or the name of the field (its json name).
+	Field string
+	// Param is the parameter of the tag, e.g. 3 of min=3.
+	Param string
+	// Tag is the failed tag.
+	Tag string
+	// Value is the value of the field.
+	Value any
+}
+
+// TranslateError turns validation errors returned by Validate into a
+// code.ValidationErrors listing every invalid field with a message in the
+// locale of ctx: the "validation.<tag>" message of the default i18n
+// bundle, rendered with a FieldMessage, or an English message. Other
+// errors are returned unchanged.
+//
+//	# locales/de.yaml
+//	validation:
+//	  required: "{{.Field}} ist erforderlich"
+//	field:
+//	  email: "E-Mail"
+func TranslateError(ctx context.Context, err error) error {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+	out := make(code.ValidationErrors, 0, len(errs))
+	for _, fe := range errs {
+		name, ok := i18n.Lookup(ctx, "field."+fe.Field(), nil)
+		if !ok {
+			name = fe.Field()
+		}
+		data := FieldMessage{
+			Field: name,
+			Param: fe.Param(),
+			Tag:   fe.Tag(),
+			Value: fe.Value(),
+		}
+		out = append(out, code.FieldError{
+			Field:   fe.Field(),
+			Message: i18n.Message(ctx, "validation."+fe.Tag(), data, defaultMessage(data)),
+		})
+	}
+	return out
+}
+
+// defaultMessage is the English message of a failed tag.
+func defaultMessage(data FieldMessage) string {
+	if format, ok := defaultMessages[data.Tag]; ok {
+		return fmt.Sprintf(format, data.Field, data.Param)
+	}
+	return fmt.Sprintf("%s failed on the '%s' tag", data.Field, data.Tag)
+}
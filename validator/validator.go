@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"strings"
 
+	"github.com/NSObjects/go-kit/utils"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -13,7 +14,9 @@ type CustomValidator struct {
 	Validator *validator.Validate
 }
 
-// New creates a new validator with common customizations.
+// New creates a new validator with common customizations. Besides the
+// built-in tags (uuid, oneof, ...) it registers "ulid", matching
+// utils.ParamULID, and compares utils.Time fields as time.Time.
 func New() *CustomValidator {
 	v := validator.New()
 
@@ -26,6 +29,18 @@ func New() *CustomValidator {
 		return name
 	})
 
+	_ = v.RegisterValidation("ulid", func(fl validator.FieldLevel) bool {
+		return utils.IsULID(fl.Field().String())
+	})
+
+	// Validate utils.Time as time.Time, e.g. for gtfield and required.
+	v.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		if t, ok := field.Interface().(utils.Time); ok && !t.IsZero() {
+			return t.Time
+		}
+		return nil
+	}, utils.Time{})
+
 	return &CustomValidator{Validator: v}
 }
 
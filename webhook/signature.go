@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Delivery headers.
+const (
+	// HeaderID carries the delivery ID; receivers use it to drop
+	// duplicates, as delivery is at-least-once.
+	HeaderID = "X-Webhook-ID"
+	// HeaderTimestamp carries the signing time in unix seconds.
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderSignature carries "v1=" and the hex HMAC-SHA256 of
+	// "<timestamp>.<body>".
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const signaturePrefix = "v1="
+
+// Sign returns the HeaderSignature value for body signed at ts.
+func Sign(secret []byte, ts time.Time, body []byte) string {
+	return signaturePrefix + hex.EncodeToString(mac(secret, strconv.FormatInt(ts.Unix(), 10), body))
+}
+
+// Verify checks the signature and timestamp headers of a received
+// webhook. The timestamp must be within tolerance of now, which bounds
+// replays.
+//
+//	body, _ := io.ReadAll(c.Request().Body)
+//	h := c.Request().Header
+//	if !webhook.Verify(secret, h.Get(webhook.HeaderSignature), h.Get(webhook.HeaderTimestamp), body, 5*time.Minute) {
+//	    return code.NewError(code.ErrSignatureInvalid, "invalid webhook signature")
+//	}
+func Verify(secret []byte, signature, timestamp string, body []byte, tolerance time.Duration) bool {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return false
+	}
+	hexSig, ok := strings.CutPrefix(signature, signaturePrefix)
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(hexSig)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(got, mac(secret, timestamp, body))
+}
+
+func mac(secret []byte, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
@@ -0,0 +1,391 @@
+// Package webhook delivers events to customer-supplied URLs with HMAC
+// signing, persistent exponential retries and a dead-letter table.
+//
+// Deliveries are stored in the database, so retries survive restarts, and
+// are delivered at least once: receivers should deduplicate on HeaderID and
+// verify requests with Verify.
+//
+//	// once: gdb.AutoMigrate(&webhook.Delivery{}, &webhook.DeadLetter{})
+//	d := webhook.New(m.DB, httpclient.New(), lookupEndpoint, webhook.Options{
+//	    Metrics: metrics.NewWebhookMetrics("app"),
+//	})
+//	go d.Run(ctx)
+//
+//	err := d.Enqueue(ctx, endpointID, OrderPaid{OrderID: id})
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/db"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/httpclient"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/metrics"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Endpoint is a webhook receiver.
+type Endpoint struct {
+	ID     string
+	URL    string
+	Secret []byte
+}
+
+// EndpointLookup resolves an endpoint ID. It is called on every attempt,
+// so URL and secret changes apply to pending deliveries. Returning a
+// code.ErrNotFound error dead-letters the delivery without retrying.
+type EndpointLookup func(ctx context.Context, id string) (Endpoint, error)
+
+// Delivery is a pending webhook delivery.
+type Delivery struct {
+	ID            uint64    `gorm:"primaryKey;autoIncrement"`
+	EndpointID    string    `gorm:"size:64;not null;index"`
+	Payload       []byte    `gorm:"not null"`
+	Attempts      int       `gorm:"not null"`
+	NextAttemptAt time.Time `gorm:"not null;index"`
+	LastStatus    int
+	LastResponse  string `gorm:"type:text"`
+	LastError     string `gorm:"type:text"`
+	CreatedAt     time.Time
+}
+
+// TableName implements gorm's Tabler.
+func (Delivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// DeadLetter is a delivery whose attempts were exhausted, with the last
+// response (truncated) for diagnosis. Redrive re-queues it.
+type DeadLetter struct {
+	ID           uint64 `gorm:"primaryKey;autoIncrement"`
+	DeliveryID   uint64 `gorm:"not null;index"`
+	EndpointID   string `gorm:"size:64;not null;index"`
+	Payload      []byte `gorm:"not null"`
+	Attempts     int    `gorm:"not null"`
+	LastStatus   int
+	LastResponse string    `gorm:"type:text"`
+	LastError    string    `gorm:"type:text"`
+	CreatedAt    time.Time // when the delivery was enqueued
+	FailedAt     time.Time `gorm:"not null;index"`
+}
+
+// TableName implements gorm's Tabler.
+func (DeadLetter) TableName() string {
+	return "webhook_dead_letters"
+}
+
+// Options configures a Deliverer. Zero values use the defaults.
+type Options struct {
+	// Retry is the retry schedule: MaxAttempts default 8, InitialBackoff
+	// 30s, MaxBackoff 6h, Multiplier 2; Rand draws the jitter. RetryIf and
+	// Clock are ignored.
+	Retry utils.RetryPolicy
+	// AttemptTimeout bounds each delivery attempt; default 10s.
+	AttemptTimeout time.Duration
+	// Lease hides claimed deliveries from other workers while they are
+	// attempted; default 2m. A worker crash delays them by at most Lease.
+	Lease time.Duration
+	// BatchSize is the number of deliveries claimed per poll; default 50.
+	BatchSize int
+	// Concurrency is the number of parallel attempts; default 8.
+	Concurrency int
+	// PollInterval between polls when nothing is due; default 1s.
+	PollInterval time.Duration
+	// MaxResponseBody truncates the captured response body; default 4KiB.
+	MaxResponseBody int
+	// Clock schedules retries and paces polls; default the real clock.
+	Clock utils.Clock
+	// Metrics records attempts, outcomes and latency per host (optional).
+	Metrics *metrics.WebhookMetrics
+}
+
+func (o Options) withDefaults() Options {
+	if o.Retry.MaxAttempts <= 0 {
+		o.Retry.MaxAttempts = 8
+	}
+	if o.Retry.InitialBackoff <= 0 {
+		o.Retry.InitialBackoff = 30 * time.Second
+	}
+	if o.Retry.MaxBackoff <= 0 {
+		o.Retry.MaxBackoff = 6 * time.Hour
+	}
+	if o.Retry.Multiplier < 1 {
+		o.Retry.Multiplier = 2
+	}
+	if o.AttemptTimeout <= 0 {
+		o.AttemptTimeout = 10 * time.Second
+	}
+	if o.Lease <= 0 {
+		o.Lease = 2 * time.Minute
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 50
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 8
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = time.Second
+	}
+	if o.MaxResponseBody <= 0 {
+		o.MaxResponseBody = 4 << 10
+	}
+	if o.Retry.Rand == nil {
+		o.Retry.Rand = utils.RealRand{}
+	}
+	if o.Clock == nil {
+		o.Clock = utils.RealClock{}
+	}
+	return o
+}
+
+// Deliverer enqueues and delivers webhooks.
+type Deliverer struct {
+	db     *gorm.DB
+	client *httpclient.Client
+	lookup EndpointLookup
+	opts   Options
+}
+
+// New creates a Deliverer. client should not retry on its own; the
+// Deliverer schedules retries.
+func New(gdb *gorm.DB, client *httpclient.Client, lookup EndpointLookup, opts Options) *Deliverer {
+	return &Deliverer{db: gdb, client: client, lookup: lookup, opts: opts.withDefaults()}
+}
+
+// Enqueue stores event, encoded as JSON, for delivery to endpointID.
+func (d *Deliverer) Enqueue(ctx context.Context, endpointID string, event any) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return code.WrapError(err, code.ErrEncodingJSON, "encode webhook event")
+	}
+	delivery := Delivery{
+		EndpointID:    endpointID,
+		Payload:       payload,
+		NextAttemptAt: d.opts.Clock.Now(),
+	}
+	return db.TranslateError(d.db.WithContext(ctx).Create(&delivery).Error, "webhook enqueue")
+}
+
+// Redrive moves the dead letter with id back to the delivery queue with
+// a fresh retry schedule. Returns code.ErrNotFound for unknown IDs.
+func (d *Deliverer) Redrive(ctx context.Context, id uint64) error {
+	return db.WithTransaction(ctx, d.db, func(tx *gorm.DB) error {
+		var dl DeadLetter
+		if err := tx.First(&dl, id).Error; err != nil {
+			return db.TranslateError(err, "webhook redrive")
+		}
+		delivery := Delivery{
+			EndpointID:    dl.EndpointID,
+			Payload:       dl.Payload,
+			NextAttemptAt: d.opts.Clock.Now(),
+			CreatedAt:     dl.CreatedAt,
+		}
+		if err := tx.Create(&delivery).Error; err != nil {
+			return db.TranslateError(err, "webhook redrive")
+		}
+		return db.TranslateError(tx.Delete(&dl).Error, "webhook redrive")
+	})
+}
+
+// Run delivers due webhooks until ctx is canceled.
+//
+// Due deliveries are claimed in batches with SELECT ... FOR UPDATE SKIP
+// LOCKED (on MySQL and PostgreSQL) and leased for Options.Lease, so
+// several replicas can run concurrently.
+func (d *Deliverer) Run(ctx context.Context) error {
+	for {
+		n, err := d.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Webhook delivery failed", log.Err(err))
+		}
+		// Keep draining while full batches are returned.
+		if n == d.opts.BatchSize && err == nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			continue
+		}
+
+		if utils.Sleep(ctx, d.opts.Clock, d.opts.PollInterval) != nil {
+			return nil
+		}
+	}
+}
+
+// RunOnce claims and attempts one batch of due deliveries and returns how
+// many were attempted.
+func (d *Deliverer) RunOnce(ctx context.Context) (int, error) {
+	batch, err := d.claim(ctx)
+	if err != nil || len(batch) == 0 {
+		return 0, err
+	}
+	fns := make([]func(ctx context.Context) error, len(batch))
+	for i := range batch {
+		fns[i] = func(ctx context.Context) error { return d.attempt(ctx, &batch[i]) }
+	}
+	return len(batch), utils.ParallelAll(ctx, d.opts.Concurrency, fns...)
+}
+
+// claim selects due deliveries and leases them.
+func (d *Deliverer) claim(ctx context.Context) ([]Delivery, error) {
+	now := d.opts.Clock.Now()
+	var batch []Delivery
+	err := db.WithTransaction(ctx, d.db, func(tx *gorm.DB) error {
+		q := tx.Where("next_attempt_at <= ?", now).Order("next_attempt_at, id").Limit(d.opts.BatchSize)
+		if tx.Dialector.Name() != "sqlite" {
+			q = q.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		if err := q.Find(&batch).Error; err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		ids := make([]uint64, len(batch))
+		for i, del := range batch {
+			ids[i] = del.ID
+		}
+		return tx.Model(&Delivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(d.opts.Lease)).Error
+	})
+	return batch, err
+}
+
+// attempt delivers del once and records the outcome.
+func (d *Deliverer) attempt(ctx context.Context, del *Delivery) error {
+	del.Attempts++
+	status, body, host, err := d.send(ctx, del)
+	del.LastStatus, del.LastResponse = status, body
+	del.LastError = ""
+	if err != nil {
+		del.LastError = err.Error()
+	}
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	if d.opts.Metrics != nil {
+		d.opts.Metrics.Attempts.WithLabelValues(host, result).Inc()
+	}
+
+	switch {
+	case err == nil:
+		if d.opts.Metrics != nil {
+			d.opts.Metrics.Deliveries.WithLabelValues(host, "delivered").Inc()
+		}
+		return db.TranslateError(d.db.WithContext(ctx).Delete(&Delivery{}, del.ID).Error, "webhook complete")
+	case del.Attempts >= d.opts.Retry.MaxAttempts || errors.IsCode(err, code.ErrNotFound):
+		slog.Warn("Webhook delivery dead-lettered",
+			slog.Uint64("delivery_id", del.ID),
+			slog.String("endpoint_id", del.EndpointID),
+			slog.Int("attempts", del.Attempts),
+			log.Err(err),
+		)
+		if d.opts.Metrics != nil {
+			d.opts.Metrics.Deliveries.WithLabelValues(host, "dead_letter").Inc()
+		}
+		return d.deadLetter(ctx, del)
+	default:
+		del.NextAttemptAt = d.opts.Clock.Now().Add(d.backoff(del.Attempts))
+		err := d.db.WithContext(ctx).Model(&Delivery{}).Where("id = ?", del.ID).Updates(map[string]any{
+			"attempts":        del.Attempts,
+			"next_attempt_at": del.NextAttemptAt,
+			"last_status":     del.LastStatus,
+			"last_response":   del.LastResponse,
+			"last_error":      del.LastError,
+		}).Error
+		return db.TranslateError(err, "webhook reschedule")
+	}
+}
+
+// send posts the payload to the endpoint and returns the status, the
+// truncated response body and the endpoint host.
+func (d *Deliverer) send(ctx context.Context, del *Delivery) (int, string, string, error) {
+	ep, err := d.lookup(ctx, del.EndpointID)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("lookup endpoint %s: %w", del.EndpointID, err)
+	}
+	host := ""
+	if u, err := url.Parse(ep.URL); err == nil {
+		host = u.Host
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.opts.AttemptTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, "", host, err
+	}
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(HeaderID, strconv.FormatUint(del.ID, 10))
+	now := time.Now()
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(ep.Secret, now, del.Payload))
+
+	start := time.Now()
+	res, err := d.client.Do(ctx, req)
+	if d.opts.Metrics != nil {
+		d.opts.Metrics.Latency.WithLabelValues(host).Observe(time.Since(start).Seconds())
+	}
+	if err != nil {
+		return 0, "", host, err
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, int64(d.opts.MaxResponseBody)))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, string(body), host, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return res.StatusCode, string(body), host, nil
+}
+
+// deadLetter moves del to the dead-letter table.
+func (d *Deliverer) deadLetter(ctx context.Context, del *Delivery) error {
+	return db.WithTransaction(ctx, d.db, func(tx *gorm.DB) error {
+		dl := DeadLetter{
+			DeliveryID:   del.ID,
+			EndpointID:   del.EndpointID,
+			Payload:      del.Payload,
+			Attempts:     del.Attempts,
+			LastStatus:   del.LastStatus,
+			LastResponse: del.LastResponse,
+			LastError:    del.LastError,
+			CreatedAt:    del.CreatedAt,
+			FailedAt:     d.opts.Clock.Now(),
+		}
+		if err := tx.Create(&dl).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Delivery{}, del.ID).Error
+	})
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (d *Deliverer) backoff(attempts int) time.Duration {
+	p := d.opts.Retry
+	delay := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempts-1))
+	if delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		delay *= 1 + p.Jitter*(2*p.Rand.Float64()-1)
+	}
+	return time.Duration(delay)
+}
@@ -0,0 +1,152 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/NSObjects/go-kit/cache"
+	"github.com/NSObjects/go-kit/pubsub"
+	"github.com/NSObjects/go-kit/scheduler"
+	"github.com/redis/go-redis/v9"
+)
+
+// PubSub consumes topic from sub. For Kafka consumer groups, pass the
+// application's Kafka-backed pubsub.Subscriber (see db/kafka.go). With
+// Concurrency 1 messages are handled in order and errors are returned to
+// the subscriber; otherwise they are handled in parallel.
+func PubSub(sub pubsub.Subscriber, topic string) Source {
+	return pubsubSource{sub: sub, topic: topic}
+}
+
+type pubsubSource struct {
+	sub   pubsub.Subscriber
+	topic string
+}
+
+func (s pubsubSource) Consume(ctx context.Context, concurrency int, handle HandleFunc) error {
+	if concurrency <= 1 {
+		return s.sub.Subscribe(ctx, s.topic, func(ctx context.Context, m pubsub.Message) error {
+			return handle(ctx, fromPubSub(m))
+		})
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	sem := make(chan struct{}, concurrency)
+	return s.sub.Subscribe(ctx, s.topic, func(ctx context.Context, m pubsub.Message) error {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			_ = handle(ctx, fromPubSub(m))
+		}()
+		return nil
+	})
+}
+
+func fromPubSub(m pubsub.Message) Message {
+	return Message{
+		Topic:   m.Topic,
+		Key:     m.Key,
+		Payload: m.Payload,
+		Headers: m.Metadata,
+	}
+}
+
+// Stream consumes a Redis stream in consumer group. Concurrency runs that
+// many consumers named "<consumer>-<n>" in the group. The "payload" field
+// becomes Message.Payload; other string fields become headers. Failed
+// messages are redelivered and eventually dead-lettered by the consumer
+// (see cache.StreamConsumer). Stream sources report the group's lag.
+func Stream(client *redis.Client, stream, group, consumer string, opts cache.StreamConsumerOptions) Source {
+	return &streamSource{client: client, stream: stream, group: group, consumer: consumer, opts: opts}
+}
+
+type streamSource struct {
+	client   *redis.Client
+	stream   string
+	group    string
+	consumer string
+	opts     cache.StreamConsumerOptions
+}
+
+func (s *streamSource) Consume(ctx context.Context, concurrency int, handle HandleFunc) error {
+	errs := make(chan error, concurrency)
+	var wg sync.WaitGroup
+	for i := range concurrency {
+		c := cache.NewStreamConsumer(s.client, s.stream, s.group, fmt.Sprintf("%s-%d", s.consumer, i), s.opts)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.Run(ctx, func(ctx context.Context, m cache.StreamMessage) error {
+				return handle(ctx, fromStream(m))
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lag implements Lagger.
+func (s *streamSource) Lag(ctx context.Context) (int64, error) {
+	return cache.NewStreamConsumer(s.client, s.stream, s.group, s.consumer, s.opts).Lag(ctx)
+}
+
+func fromStream(m cache.StreamMessage) Message {
+	msg := Message{ID: m.ID, Topic: m.Stream, Headers: make(map[string]string, len(m.Values))}
+	for k, v := range m.Values {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if k == "payload" {
+			msg.Payload = []byte(s)
+		} else {
+			msg.Headers[k] = s
+		}
+	}
+	return msg
+}
+
+// Cron delivers an empty message on the given schedule: a 5-field cron
+// expression or "@every <duration>". Ticks are skipped while the previous
+// run is in progress; Concurrency is ignored. The message ID is the tick
+// time in unix seconds.
+func Cron(spec string) Source {
+	return cronSource{spec: spec}
+}
+
+type cronSource struct {
+	spec string
+}
+
+func (s cronSource) Consume(ctx context.Context, _ int, handle HandleFunc) error {
+	sched := scheduler.New(scheduler.Options{})
+	err := sched.Register(s.spec, s.spec, func(ctx context.Context) error {
+		return handle(ctx, Message{ID: strconv.FormatInt(time.Now().Unix(), 10), Topic: s.spec})
+	}, scheduler.JobOptions{})
+	if err != nil {
+		return err
+	}
+	if err := sched.Start(ctx); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return sched.Stop(context.Background())
+}
@@ -0,0 +1,407 @@
+// Package worker runs queue consumers and scheduled jobs in processes
+// without an API listener, with the same bootstrap as HTTP services:
+// logging, tracing, metrics, health checks, panic recovery and graceful
+// drain on SIGINT/SIGTERM.
+//
+//	err := worker.Run(ctx, cfg,
+//	    worker.Handler{
+//	        Name:        "order-paid",
+//	        Source:      worker.Stream(rdb, "orders.paid", "billing", hostname, cache.StreamConsumerOptions{}),
+//	        Concurrency: 4,
+//	        Handle:      worker.JSON(onOrderPaid),
+//	    },
+//	    worker.Handler{Name: "cleanup", Source: worker.Cron("*/5 * * * *"), Handle: cleanup},
+//	)
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/NSObjects/go-kit/code"
+	"github.com/NSObjects/go-kit/config"
+	"github.com/NSObjects/go-kit/errors"
+	"github.com/NSObjects/go-kit/health"
+	"github.com/NSObjects/go-kit/lifecycle"
+	"github.com/NSObjects/go-kit/log"
+	"github.com/NSObjects/go-kit/metrics"
+	"github.com/NSObjects/go-kit/observability"
+	"github.com/NSObjects/go-kit/pubsub"
+	"github.com/NSObjects/go-kit/server"
+	"github.com/NSObjects/go-kit/utils"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Message is a unit of work delivered by a Source.
+type Message struct {
+	ID      string
+	Topic   string
+	Key     string
+	Payload []byte
+	// Headers carry request and trace metadata (see
+	// pubsub.MetadataFromContext).
+	Headers map[string]string
+}
+
+// HandleFunc processes a message.
+type HandleFunc func(ctx context.Context, msg Message) error
+
+// Source delivers messages to a handler.
+type Source interface {
+	// Consume calls handle for each message, with at most concurrency
+	// calls in flight, until ctx is canceled. It returns once the calls
+	// in flight have returned. An error returned by handle is reported to
+	// the source (e.g. the message is not acknowledged).
+	Consume(ctx context.Context, concurrency int, handle HandleFunc) error
+}
+
+// Lagger is implemented by sources that can report their backlog.
+type Lagger interface {
+	Lag(ctx context.Context) (int64, error)
+}
+
+// Handler binds a Source to a HandleFunc.
+type Handler struct {
+	// Name identifies the handler in logs, spans, metrics and health.
+	Name string
+	// Source delivers the messages.
+	Source Source
+	// Concurrency is the number of messages handled in parallel; default 1.
+	Concurrency int
+	// Handle processes a message. Panics are recovered as errors.
+	Handle HandleFunc
+	// Retry retries failing messages in process before reporting the
+	// failure to the source; default utils.DefaultRetryPolicy. Panics are
+	// not retried.
+	Retry utils.RetryPolicy
+	// Timeout bounds each attempt; 0 means no timeout.
+	Timeout time.Duration
+	// MaxLag reports the handler degraded when the source's backlog
+	// exceeds it; 0 disables the check.
+	MaxLag int64
+}
+
+// JSON adapts a typed handler: the payload is decoded into T. Payloads that
+// cannot be decoded fail without retries.
+func JSON[T any](fn func(ctx context.Context, payload T) error) HandleFunc {
+	return func(ctx context.Context, msg Message) error {
+		var v T
+		if err := json.Unmarshal(msg.Payload, &v); err != nil {
+			return utils.Permanent(code.WrapError(err, code.ErrDecodingJSON, "decode message payload"))
+		}
+		return fn(ctx, v)
+	}
+}
+
+// Run starts the handlers and blocks until ctx is canceled, a termination
+// signal arrives, or a source fails. It then stops consuming and waits up
+// to cfg.System.ShutdownTimeout for messages in flight; their context is
+// only canceled when that time runs out. Background workers of the kit
+// still running after as long again are reported (see
+// lifecycle.WaitWorkers).
+//
+// Run sets up logging from cfg.Log and tracing from cfg.Otel. When
+// cfg.System.Port is set it serves /health, /health/live, /health/ready
+// and /metrics there.
+func Run(ctx context.Context, cfg config.Config, handlers ...Handler) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	cfg.System = server.WithDefaults(cfg.System)
+
+	if err := validate(handlers); err != nil {
+		return err
+	}
+	setupLogging(cfg.Log)
+	shutdownTracing, err := observability.Setup(ctx, cfg.Otel, cfg.System)
+	if err != nil {
+		return code.WrapError(err, code.ErrStartup, "setup tracing")
+	}
+	defer func() { _ = shutdownTracing(context.Background()) }()
+
+	w := &runtime{
+		metrics: workerMetrics(metricsNamespace(cfg.System.Name)),
+		health:  health.NewRegistry(),
+		tracer:  otel.Tracer("github.com/NSObjects/go-kit/worker"),
+	}
+	w.health.Register(w.health.StartupChecker())
+
+	ops, err := startOps(cfg.System, w.health)
+	if err != nil {
+		return err
+	}
+
+	// Handlers keep running while the sources drain; their context is
+	// canceled only when the drain times out.
+	handlerCtx, cancelHandlers := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelHandlers()
+	consumeCtx, cancelConsume := context.WithCancel(ctx)
+	defer cancelConsume()
+
+	dones := make([]<-chan struct{}, 0, len(handlers))
+	failed := make(chan error, len(handlers))
+	for i := range handlers {
+		h := &handlers[i]
+		chk := &checker{handler: h, metrics: w.metrics}
+		w.health.Register(chk)
+
+		wk := lifecycle.Go(consumeCtx, "worker."+h.Name, func(ctx context.Context) error {
+			slog.Info("Worker handler starting", slog.String("handler", h.Name), slog.Int("concurrency", h.Concurrency))
+			err := h.Source.Consume(ctx, h.Concurrency, func(_ context.Context, msg Message) error {
+				return w.process(handlerCtx, h, msg)
+			})
+			if err != nil && ctx.Err() == nil {
+				chk.failed.Store(&err)
+				select {
+				case failed <- fmt.Errorf("handler %s: %w", h.Name, err):
+				default: // a restarted handler failing again
+				}
+			}
+			return nil
+		})
+		dones = append(dones, wk.Done())
+	}
+	w.health.SetReady(true)
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+	case runErr = <-failed:
+		slog.Error("Worker handler failed", log.Err(runErr))
+	}
+
+	slog.Info("Worker draining", slog.Duration("timeout", cfg.System.ShutdownTimeout))
+	w.health.BeginShutdown()
+	cancelConsume()
+
+	drained := make(chan struct{})
+	go func() {
+		for _, done := range dones {
+			<-done
+		}
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(cfg.System.ShutdownTimeout):
+		cancelHandlers()
+		if runErr == nil {
+			runErr = code.NewError(code.ErrTimeout, "worker drain timed out")
+		}
+		slog.Warn("Worker drain timed out; canceled messages in flight")
+	}
+
+	if ops != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.System.ShutdownTimeout)
+		defer cancel()
+		_ = ops.Shutdown(shutdownCtx)
+	}
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), cfg.System.ShutdownTimeout)
+	defer cancelWait()
+	if err := lifecycle.WaitWorkers(waitCtx); err != nil && runErr == nil {
+		runErr = err
+	}
+	slog.Info("Worker stopped")
+	return runErr
+}
+
+func validate(handlers []Handler) error {
+	if len(handlers) == 0 {
+		return code.NewError(code.ErrStartup, "worker: no handlers")
+	}
+	seen := make(map[string]bool, len(handlers))
+	for i := range handlers {
+		h := &handlers[i]
+		switch {
+		case h.Name == "":
+			return code.NewErrorf(code.ErrStartup, "worker: handler %d has no name", i)
+		case seen[h.Name]:
+			return code.NewErrorf(code.ErrStartup, "worker: duplicate handler %q", h.Name)
+		case h.Source == nil || h.Handle == nil:
+			return code.NewErrorf(code.ErrStartup, "worker: handler %q needs a Source and Handle", h.Name)
+		}
+		seen[h.Name] = true
+		if h.Concurrency <= 0 {
+			h.Concurrency = 1
+		}
+		if h.Retry.MaxAttempts <= 0 {
+			h.Retry = utils.DefaultRetryPolicy()
+		}
+	}
+	return nil
+}
+
+// setupLogging installs the configured logger as the global and slog
+// default logger.
+func setupLogging(cfg config.LogConfig) {
+	logger := log.New(cfg)
+	log.SetGlobalLogger(logger)
+	if dl, ok := logger.(*log.DefaultLogger); ok {
+		slog.SetDefault(dl.Slog())
+	}
+}
+
+// startOps serves health and metrics endpoints when a port is configured.
+func startOps(sys config.SystemConfig, reg *health.Registry) (*http.Server, error) {
+	if sys.Port == "" {
+		return nil, nil
+	}
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.GET("/health", health.Handler(reg))
+	e.GET("/health/live", health.LivenessHandler())
+	e.GET("/health/ready", health.ReadinessHandler(reg))
+	e.GET("/metrics", metrics.Handler())
+
+	srv, err := server.NewHTTPServer(sys, e)
+	if err != nil {
+		return nil, code.WrapError(err, code.ErrStartup, "worker ops server")
+	}
+	lifecycle.Go(context.Background(), "worker.ops_server", func(context.Context) error {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Worker ops server failed", log.Err(err))
+		}
+		return nil
+	}, lifecycle.WorkerOptions{Once: true})
+	return srv, nil
+}
+
+type runtime struct {
+	metrics *metrics.WorkerMetrics
+	health  *health.Registry
+	tracer  trace.Tracer
+}
+
+// process handles msg with tracing, retries, panic recovery and metrics.
+// The request ID of the message headers is restored; messages without one
+// (e.g. cron ticks) get a fresh one.
+func (w *runtime) process(ctx context.Context, h *Handler, msg Message) error {
+	ctx = pubsub.ContextWithMetadata(ctx, msg.Headers)
+	if utils.GetRequestID(ctx) == "" {
+		ctx = utils.WithNewRequestID(ctx)
+	}
+	ctx, span := w.tracer.Start(ctx, "process "+h.Name,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.destination.name", msg.Topic),
+			attribute.String("messaging.message.id", msg.ID),
+		),
+	)
+	defer span.End()
+
+	start := time.Now()
+	err := utils.Retry(ctx, h.Retry, func(ctx context.Context) error {
+		return attempt(ctx, h, msg)
+	})
+	w.metrics.Processed.WithLabelValues(h.Name).Inc()
+	w.metrics.Duration.WithLabelValues(h.Name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		w.metrics.Failed.WithLabelValues(h.Name).Inc()
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+		slog.ErrorContext(ctx, "Message handling failed",
+			slog.String("handler", h.Name),
+			slog.String("topic", msg.Topic),
+			slog.String("message_id", msg.ID),
+			slog.String("request_id", utils.GetRequestID(ctx)),
+			log.Err(err),
+		)
+	}
+	return err
+}
+
+// attempt runs one bounded, panic-safe call of the handler.
+func attempt(ctx context.Context, h *Handler, msg Message) (err error) {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = utils.Permanent(errors.FromPanic(r))
+		}
+	}()
+	return h.Handle(ctx, msg)
+}
+
+// checker reports a handler unhealthy once its source failed, and degraded
+// while its backlog exceeds MaxLag.
+type checker struct {
+	handler *Handler
+	metrics *metrics.WorkerMetrics
+	failed  atomic.Pointer[error]
+}
+
+func (c *checker) Name() string { return "worker:" + c.handler.Name }
+
+func (c *checker) Check(ctx context.Context) health.Check {
+	start := time.Now()
+	check := health.Check{Name: c.Name(), Status: health.StatusHealthy}
+	defer func() { check.Latency = time.Since(start) }()
+
+	if err := c.failed.Load(); err != nil {
+		check.Status = health.StatusUnhealthy
+		check.Message = (*err).Error()
+		return check
+	}
+	lagger, ok := c.handler.Source.(Lagger)
+	if !ok {
+		return check
+	}
+	lag, err := lagger.Lag(ctx)
+	if err != nil {
+		check.Status = health.StatusDegraded
+		check.Message = "lag unavailable: " + err.Error()
+		return check
+	}
+	c.metrics.Lag.WithLabelValues(c.handler.Name).Set(float64(lag))
+	check.Message = fmt.Sprintf("lag %d", lag)
+	if c.handler.MaxLag > 0 && lag > c.handler.MaxLag {
+		check.Status = health.StatusDegraded
+		check.Message = fmt.Sprintf("lag %d exceeds %d", lag, c.handler.MaxLag)
+	}
+	return check
+}
+
+var (
+	metricsMu    sync.Mutex
+	metricsCache = make(map[string]*metrics.WorkerMetrics)
+)
+
+// workerMetrics returns the metrics of namespace, registering them once
+// per process.
+func workerMetrics(namespace string) *metrics.WorkerMetrics {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	if m, ok := metricsCache[namespace]; ok {
+		return m
+	}
+	m := metrics.NewWorkerMetrics(namespace)
+	metricsCache[namespace] = m
+	return m
+}
+
+// metricsNamespace turns a service name into a Prometheus namespace.
+func metricsNamespace(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}